@@ -37,7 +37,10 @@ fn generate_protobuf() -> Result<(), Box<dyn Error>> {
         .build_server(false)
         .out_dir("src/proto/integration")
         .compile(
-            &["../../../message/opentelemetry/opentelemetry/proto/trace/v1/trace.proto"],
+            &[
+                "../../../message/opentelemetry/opentelemetry/proto/trace/v1/trace.proto",
+                "../../../message/opentelemetry/opentelemetry/proto/metrics/v1/metrics.proto",
+            ],
             &["../../../message/opentelemetry"],
         )?;
 
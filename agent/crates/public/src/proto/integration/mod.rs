@@ -26,6 +26,11 @@ pub mod opentelemetry {
                 include!("opentelemetry.proto.trace.v1.rs");
             }
         }
+        pub mod metrics {
+            pub mod v1 {
+                include!("opentelemetry.proto.metrics.v1.rs");
+            }
+        }
         pub mod resource {
             pub mod v1 {
                 include!("opentelemetry.proto.resource.v1.rs");
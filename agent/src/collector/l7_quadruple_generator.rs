@@ -704,6 +704,7 @@ impl L7QuadrupleGenerator {
                     rrt_max: stats.rrt_max,
                     rrt_sum: stats.rrt_sum as u64,
                     rrt_count: stats.rrt_count,
+                    rrt_sketch: stats.rrt_sketch,
                 },
                 anomaly: AppAnomaly {
                     client_error: stats.err_client_count,
@@ -39,7 +39,7 @@ use super::{
 
 use crate::{
     common::{endpoint::EPC_INTERNET, timestamp_to_micros, Timestamp},
-    metric::document::Direction,
+    metric::{document::Direction, meter::LatencySketch},
 };
 use crate::{
     flow_generator::protocol_logs::to_string_format,
@@ -582,7 +582,7 @@ pub struct L7Stats {
     pub biz_type: u8,
 }
 
-#[derive(Serialize, Debug, Default, Clone, PartialEq, Eq)]
+#[derive(Serialize, Debug, Default, Clone)]
 pub struct L7PerfStats {
     #[serde(rename = "l7_request")]
     pub request_count: u32,
@@ -598,8 +598,28 @@ pub struct L7PerfStats {
     pub rrt_sum: u64,   // us RRT(Request Response Time)
     pub rrt_max: u32,   // us agent保证在3600s以内
     pub tls_rtt: u32,
+    #[serde(skip)]
+    pub rrt_sketch: LatencySketch,
+}
+
+// rrt_sketch is built from the same rrts as rrt_count, rrt_sum and rrt_max,
+// so it is left out of the comparison
+impl PartialEq for L7PerfStats {
+    fn eq(&self, other: &Self) -> bool {
+        self.request_count == other.request_count
+            && self.response_count == other.response_count
+            && self.err_client_count == other.err_client_count
+            && self.err_server_count == other.err_server_count
+            && self.err_timeout == other.err_timeout
+            && self.rrt_count == other.rrt_count
+            && self.rrt_sum == other.rrt_sum
+            && self.rrt_max == other.rrt_max
+            && self.tls_rtt == other.tls_rtt
+    }
 }
 
+impl Eq for L7PerfStats {}
+
 impl L7PerfStats {
     pub fn sequential_merge(&mut self, other: &L7PerfStats) {
         self.request_count += other.request_count;
@@ -612,6 +632,7 @@ impl L7PerfStats {
         if self.rrt_max < other.rrt_max {
             self.rrt_max = other.rrt_max
         }
+        self.rrt_sketch.merge(&other.rrt_sketch);
         self.tls_rtt += other.tls_rtt;
     }
 
@@ -633,6 +654,7 @@ impl L7PerfStats {
             self.rrt_max = self.rrt_max.max(rrt as u32);
             self.rrt_sum += rrt;
             self.rrt_count += 1;
+            self.rrt_sketch.add(rrt as u32);
         }
         if tls_rtt != 0 {
             self.tls_rtt += tls_rtt as u32;
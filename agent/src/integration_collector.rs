@@ -70,6 +70,7 @@ use public::{
                 any_value::Value::{IntValue, StringValue},
                 AnyValue, KeyValue,
             },
+            metrics::v1::MetricsData,
             trace::v1::{span::SpanKind, Span, TracesData},
         },
         metric,
@@ -116,6 +117,23 @@ impl Sendable for OpenTelemetryCompressed {
     }
 }
 
+// OpenTelemetry metrics in protobuf
+// ingester uses https://github.com/open-telemetry/opentelemetry-proto/blob/main/opentelemetry/proto/collector/metrics/v1/metrics_service.proto to parse it
+#[derive(Debug, PartialEq)]
+pub struct OpenTelemetryMetrics(Vec<u8>);
+
+impl Sendable for OpenTelemetryMetrics {
+    fn encode(mut self, buf: &mut Vec<u8>) -> Result<usize, prost::EncodeError> {
+        let length = self.0.len();
+        buf.append(&mut self.0);
+        Ok(length)
+    }
+
+    fn message_type(&self) -> SendMessageType {
+        SendMessageType::OpenTelemetryMetrics
+    }
+}
+
 /// Prometheus metrics, in snappy compressed petabytes of data
 /// You can refer to https://github.com/prometheus/prometheus/tree/main/documentation/examples/remote_storage/example_write_adapter to parse
 pub struct PrometheusExtra {
@@ -238,6 +256,32 @@ impl Sendable for ApplicationLog {
     }
 }
 
+// The same as traces, resources of metrics sent by sdk directly have no "app.host.ip" attribute,
+// fill in the peer ip so that ingester can look up platform data with it.
+fn decode_otel_metrics_data(peer_addr: SocketAddr, data: Vec<u8>) -> Result<Vec<u8>, GenericError> {
+    let mut d = MetricsData::decode(data.as_slice())?;
+    let ip = get_ip(peer_addr.ip()).to_string();
+    for resource_metric in d.resource_metrics.iter_mut() {
+        let resource = resource_metric
+            .resource
+            .get_or_insert_with(Default::default);
+        if resource
+            .attributes
+            .iter()
+            .any(|attr| attr.key.as_str() == "app.host.ip")
+        {
+            continue;
+        }
+        resource.attributes.push(KeyValue {
+            key: "app.host.ip".into(),
+            value: Some(AnyValue {
+                value: Some(StringValue(ip.clone())),
+            }),
+        });
+    }
+    Ok(d.encode_to_vec())
+}
+
 fn decode_otel_trace_data(
     peer_addr: SocketAddr,
     data: Vec<u8>,
@@ -493,7 +537,7 @@ fn fill_l7_stats(
     } else {
         0
     };
-    let stats = L7PerfStats {
+    let mut stats = L7PerfStats {
         request_count: 1,
         response_count: 1, // otel data is all session logs, so the number of requests is the same as the number of responses
         err_client_count: if status == L7ResponseStatus::ClientError {
@@ -512,6 +556,9 @@ fn fill_l7_stats(
         rrt_max: if rrt > 0 { rrt as u32 } else { 0 },
         ..Default::default()
     };
+    if rrt > 0 {
+        stats.rrt_sketch.add(rrt as u32);
+    }
     let flow_perf_stats = FlowPerfStats {
         tcp: Default::default(),
         l7: AHashMap::new(),
@@ -589,6 +636,7 @@ async fn handler(
     req: Request<Body>,
     otel_sender: DebugSender<OpenTelemetry>,
     compressed_otel_sender: DebugSender<OpenTelemetryCompressed>,
+    otel_metrics_sender: DebugSender<OpenTelemetryMetrics>,
     otel_l7_stats_sender: DebugSender<BatchedBox<L7Stats>>,
     prometheus_sender: DebugSender<BoxedPrometheusExtra>,
     telegraf_sender: DebugSender<TelegrafMetric>,
@@ -672,6 +720,29 @@ async fn handler(
 
             Ok(Response::builder().body(Body::empty()).unwrap())
         }
+        // OpenTelemetry metrics integration
+        (&Method::POST, "/api/v1/otel/metrics") => {
+            if external_metric_integration_disabled {
+                return Ok(Response::builder().body(Body::empty()).unwrap());
+            }
+            let (part, body) = req.into_parts();
+            let whole_body = match aggregate_with_catch_exception(body, &exception_handler).await {
+                Ok(b) => b,
+                Err(e) => {
+                    return Ok(e);
+                }
+            };
+            let metrics_data = decode_metric(whole_body, &part.headers)?;
+            let decode_data = decode_otel_metrics_data(peer_addr, metrics_data).map_err(|e| {
+                debug!("decode otel metrics data error: {}", e);
+                e
+            })?;
+            if let Err(e) = otel_metrics_sender.send(OpenTelemetryMetrics(decode_data)) {
+                warn!("otel_metrics_sender failed to send data, because {:?}", e);
+            }
+
+            Ok(Response::builder().body(Body::empty()).unwrap())
+        }
         // Prometheus integration
         (&Method::POST, "/api/v1/prometheus") => {
             if external_metric_integration_disabled {
@@ -902,6 +973,7 @@ pub struct MetricServer {
     thread: Arc<Mutex<Option<JoinHandle<()>>>>,
     otel_sender: DebugSender<OpenTelemetry>,
     compressed_otel_sender: DebugSender<OpenTelemetryCompressed>,
+    otel_metrics_sender: DebugSender<OpenTelemetryMetrics>,
     otel_l7_stats_sender: DebugSender<BatchedBox<L7Stats>>,
     prometheus_sender: DebugSender<BoxedPrometheusExtra>,
     telegraf_sender: DebugSender<TelegrafMetric>,
@@ -928,6 +1000,7 @@ impl MetricServer {
         runtime: Arc<Runtime>,
         otel_sender: DebugSender<OpenTelemetry>,
         compressed_otel_sender: DebugSender<OpenTelemetryCompressed>,
+        otel_metrics_sender: DebugSender<OpenTelemetryMetrics>,
         otel_l7_stats_sender: DebugSender<BatchedBox<L7Stats>>,
         prometheus_sender: DebugSender<BoxedPrometheusExtra>,
         telegraf_sender: DebugSender<TelegrafMetric>,
@@ -955,6 +1028,7 @@ impl MetricServer {
                 compressed: Arc::new(AtomicBool::new(compressed)),
                 otel_sender,
                 compressed_otel_sender,
+                otel_metrics_sender,
                 prometheus_sender,
                 telegraf_sender,
                 profile_sender,
@@ -999,6 +1073,7 @@ impl MetricServer {
 
         let otel_sender = self.otel_sender.clone();
         let compressed_otel_sender = self.compressed_otel_sender.clone();
+        let otel_metrics_sender = self.otel_metrics_sender.clone();
         let otel_l7_stats_sender = self.otel_l7_stats_sender.clone();
         let prometheus_sender = self.prometheus_sender.clone();
         let telegraf_sender = self.telegraf_sender.clone();
@@ -1070,6 +1145,7 @@ impl MetricServer {
 
                     let otel_sender = otel_sender.clone();
                     let compressed_otel_sender = compressed_otel_sender.clone();
+                    let otel_metrics_sender = otel_metrics_sender.clone();
                     let otel_l7_stats_sender = otel_l7_stats_sender.clone();
                     let prometheus_sender = prometheus_sender.clone();
                     let telegraf_sender = telegraf_sender.clone();
@@ -1086,6 +1162,7 @@ impl MetricServer {
                     let service = make_service_fn(move |conn: &AddrStream| {
                         let otel_sender = otel_sender.clone();
                         let compressed_otel_sender = compressed_otel_sender.clone();
+                        let otel_metrics_sender = otel_metrics_sender.clone();
                         let otel_l7_stats_sender = otel_l7_stats_sender.clone();
                         let prometheus_sender = prometheus_sender.clone();
                         let telegraf_sender = telegraf_sender.clone();
@@ -1108,6 +1185,7 @@ impl MetricServer {
                                     req,
                                     otel_sender.clone(),
                                     compressed_otel_sender.clone(),
+                                    otel_metrics_sender.clone(),
                                     otel_l7_stats_sender.clone(),
                                     prometheus_sender.clone(),
                                     telegraf_sender.clone(),
@@ -14,7 +14,7 @@
  * limitations under the License.
  */
 
-use std::mem::swap;
+use std::{fmt, mem::swap};
 
 use serde::Serialize;
 
@@ -491,6 +491,8 @@ pub struct AppLatency {
     pub rrt_max: u32,
     pub rrt_sum: u64,
     pub rrt_count: u32,
+    #[serde(skip)]
+    pub rrt_sketch: LatencySketch,
 }
 
 impl AppLatency {
@@ -500,15 +502,113 @@ impl AppLatency {
         }
         self.rrt_sum += other.rrt_sum;
         self.rrt_count += other.rrt_count;
+        self.rrt_sketch.merge(&other.rrt_sketch);
     }
 }
 
 impl From<AppLatency> for metric::AppLatency {
     fn from(m: AppLatency) -> Self {
+        let (rrt_bucket_indexes, rrt_bucket_counts) = m
+            .rrt_sketch
+            .iter()
+            .map(|(index, count)| (index as u32, count))
+            .unzip();
         metric::AppLatency {
             rrt_max: m.rrt_max,
             rrt_sum: m.rrt_sum,
             rrt_count: m.rrt_count,
+            rrt_bucket_indexes,
+            rrt_bucket_counts,
+        }
+    }
+}
+
+const LATENCY_SKETCH_SUB_BUCKETS: f64 = 8.0;
+const LATENCY_SKETCH_CAPACITY: usize = 64;
+
+// A mergeable log-bucket histogram of latencies in microseconds, the bucket
+// layout is the same as LatencySketch of deepflow-server:
+// bucket 0 holds latencies below 1us, bucket i (i >= 1) holds latencies in
+// [2^((i-1)/8), 2^(i/8)), and the last bucket is 255.
+//
+// Meters are Copy, so only LATENCY_SKETCH_CAPACITY non-empty buckets (8 powers
+// of two) are kept in fixed arrays sorted by index. When a sketch is full, the
+// lowest bucket is collapsed into the next one, which keeps high quantiles
+// accurate.
+#[derive(Clone, Copy, PartialEq, Eq)]
+pub struct LatencySketch {
+    len: usize,
+    indexes: [u8; LATENCY_SKETCH_CAPACITY],
+    counts: [u32; LATENCY_SKETCH_CAPACITY],
+}
+
+impl Default for LatencySketch {
+    fn default() -> Self {
+        Self {
+            len: 0,
+            indexes: [0; LATENCY_SKETCH_CAPACITY],
+            counts: [0; LATENCY_SKETCH_CAPACITY],
+        }
+    }
+}
+
+impl fmt::Debug for LatencySketch {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        f.debug_map().entries(self.iter()).finish()
+    }
+}
+
+impl LatencySketch {
+    pub fn bucket_index(us: u32) -> u8 {
+        if us == 0 {
+            return 0;
+        }
+        let index = ((us as f64).log2() * LATENCY_SKETCH_SUB_BUCKETS).floor() as u32 + 1;
+        index.min(u8::MAX as u32) as u8
+    }
+
+    pub fn add(&mut self, us: u32) {
+        self.add_bucket(Self::bucket_index(us), 1);
+    }
+
+    pub fn merge(&mut self, other: &LatencySketch) {
+        for (index, count) in other.iter() {
+            self.add_bucket(index, count);
+        }
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.len == 0
+    }
+
+    pub fn iter(&self) -> impl Iterator<Item = (u8, u32)> + '_ {
+        self.indexes[..self.len]
+            .iter()
+            .copied()
+            .zip(self.counts[..self.len].iter().copied())
+    }
+
+    fn add_bucket(&mut self, index: u8, count: u32) {
+        match self.indexes[..self.len].binary_search(&index) {
+            Ok(i) => self.counts[i] = self.counts[i].saturating_add(count),
+            Err(i) if self.len < LATENCY_SKETCH_CAPACITY => {
+                self.indexes.copy_within(i..self.len, i + 1);
+                self.counts.copy_within(i..self.len, i + 1);
+                self.indexes[i] = index;
+                self.counts[i] = count;
+                self.len += 1;
+            }
+            // full and lower than all buckets, collapse into the lowest bucket
+            Err(0) => self.counts[0] = self.counts[0].saturating_add(count),
+            // full, collapse the lowest bucket into the next one to make room
+            Err(i) => {
+                let lowest = self.counts[0];
+                self.indexes.copy_within(1..i, 0);
+                self.counts.copy_within(1..i, 0);
+                self.indexes[i - 1] = index;
+                self.counts[i - 1] = count;
+                self.counts[0] = self.counts[0].saturating_add(lowest);
+            }
         }
     }
 }
@@ -584,3 +684,60 @@ impl From<UsageMeter> for metric::UsageMeter {
         }
     }
 }
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn latency_sketch_bucket_index() {
+        assert_eq!(LatencySketch::bucket_index(0), 0);
+        assert_eq!(LatencySketch::bucket_index(1), 1);
+        assert_eq!(LatencySketch::bucket_index(2), 9);
+        assert_eq!(LatencySketch::bucket_index(3), 13);
+        assert_eq!(LatencySketch::bucket_index(1024), 81);
+        assert_eq!(LatencySketch::bucket_index(u32::MAX), 255);
+    }
+
+    #[test]
+    fn latency_sketch_merge() {
+        let mut a = LatencySketch::default();
+        a.add(2);
+        a.add(1024);
+        let mut b = LatencySketch::default();
+        b.add(2);
+        b.add(0);
+        a.merge(&b);
+        assert_eq!(a.iter().collect::<Vec<_>>(), vec![(0, 1), (9, 2), (81, 1)]);
+    }
+
+    #[test]
+    fn latency_sketch_collapse_lowest() {
+        let mut sketch = LatencySketch::default();
+        for i in 1..=LATENCY_SKETCH_CAPACITY + 2 {
+            sketch.add_bucket(i as u8, 1);
+        }
+        sketch.add_bucket(0, 1);
+        let buckets = sketch.iter().collect::<Vec<_>>();
+        assert_eq!(buckets.len(), LATENCY_SKETCH_CAPACITY);
+        assert_eq!(buckets[0], (3, 4));
+        assert_eq!(
+            buckets[LATENCY_SKETCH_CAPACITY - 1],
+            (LATENCY_SKETCH_CAPACITY as u8 + 2, 1)
+        );
+        assert_eq!(
+            buckets.iter().map(|(_, c)| c).sum::<u32>(),
+            LATENCY_SKETCH_CAPACITY as u32 + 3
+        );
+    }
+
+    #[test]
+    fn app_latency_to_pb() {
+        let mut latency = AppLatency::default();
+        latency.rrt_sketch.add(1024);
+        latency.rrt_sketch.add(2);
+        let pb: metric::AppLatency = latency.into();
+        assert_eq!(pb.rrt_bucket_indexes, vec![9, 81]);
+        assert_eq!(pb.rrt_bucket_counts, vec![1, 1]);
+    }
+}
@@ -78,7 +78,7 @@ use crate::{
     handler::{NpbBuilder, PacketHandlerBuilder},
     integration_collector::{
         ApplicationLog, BoxedPrometheusExtra, MetricServer, OpenTelemetry, OpenTelemetryCompressed,
-        Profile, TelegrafMetric,
+        OpenTelemetryMetrics, Profile, TelegrafMetric,
     },
     metric::document::BoxedDocument,
     monitor::Monitor,
@@ -1526,6 +1526,7 @@ pub struct AgentComponents {
     pub metrics_sender: DebugSender<BoxedDocument>,
     pub npb_bps_limit: Arc<LeakyBucket>,
     pub compressed_otel_uniform_sender: UniformSenderThread<OpenTelemetryCompressed>,
+    pub otel_metrics_uniform_sender: UniformSenderThread<OpenTelemetryMetrics>,
     pub pcap_batch_uniform_sender: UniformSenderThread<BoxedPcapBatch>,
     pub policy_setter: PolicySetter,
     pub policy_getter: PolicyGetter,
@@ -2501,10 +2502,33 @@ impl AgentComponents {
             None,
         );
 
+        let otel_metrics_queue_name = "1-otel-metrics-to-sender";
+        let (otel_metrics_sender, otel_metrics_receiver, counter) = queue::bounded_with_debug(
+            yaml_config.external_metrics_sender_queue_size,
+            otel_metrics_queue_name,
+            &queue_debugger,
+        );
+        stats_collector.register_countable(
+            &QueueStats {
+                module: otel_metrics_queue_name,
+                ..Default::default()
+            },
+            Countable::Owned(Box::new(counter)),
+        );
+        let otel_metrics_uniform_sender = UniformSenderThread::new(
+            otel_metrics_queue_name,
+            Arc::new(otel_metrics_receiver),
+            config_handler.sender(),
+            stats_collector.clone(),
+            exception_handler.clone(),
+            None,
+        );
+
         let (external_metrics_server, external_metrics_counter) = MetricServer::new(
             runtime.clone(),
             otel_sender,
             compressed_otel_sender,
+            otel_metrics_sender,
             l7_stats_sender,
             prometheus_sender,
             telegraf_sender,
@@ -2586,6 +2610,7 @@ impl AgentComponents {
             packet_sequence_uniform_sender, // Enterprise Edition Feature: packet-sequence
             npb_bps_limit,
             compressed_otel_uniform_sender,
+            otel_metrics_uniform_sender,
             pcap_batch_uniform_sender,
             proto_log_sender,
             pcap_batch_sender,
@@ -2662,6 +2687,7 @@ impl AgentComponents {
         if matches!(self.agent_mode, RunningMode::Managed) {
             self.otel_uniform_sender.start();
             self.compressed_otel_uniform_sender.start();
+            self.otel_metrics_uniform_sender.start();
             self.prometheus_uniform_sender.start();
             self.telegraf_uniform_sender.start();
             self.profile_uniform_sender.start();
@@ -2719,6 +2745,9 @@ impl AgentComponents {
         if let Some(h) = self.compressed_otel_uniform_sender.notify_stop() {
             join_handles.push(h);
         }
+        if let Some(h) = self.otel_metrics_uniform_sender.notify_stop() {
+            join_handles.push(h);
+        }
         if let Some(h) = self.prometheus_uniform_sender.notify_stop() {
             join_handles.push(h);
         }
@@ -128,6 +128,8 @@ deepflow-ctl agent rebalance --type=analyzer`,
 	agent.AddCommand(update)
 	agent.AddCommand(updateExample)
 	agent.AddCommand(rebalanceCmd)
+	agent.AddCommand(registerAgentExecCommand())
+	agent.AddCommand(registerAgentExecAuditCommand())
 	return agent
 }
 
@@ -0,0 +1,219 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ctl
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+
+	"github.com/deepflowio/deepflow/cli/ctl/common"
+	"github.com/deepflowio/deepflow/cli/ctl/common/table"
+)
+
+type agentExecParams struct {
+	group        string
+	selector     string
+	params       []string
+	nsPid        uint32
+	commandIdent string
+	concurrency  int
+	agentTimeout int
+	output       string
+}
+
+func registerAgentExecCommand() *cobra.Command {
+	p := agentExecParams{}
+	exec := &cobra.Command{
+		Use:   "exec <cmd>",
+		Short: "run command on agents selected by agent group or label selector",
+		Example: `deepflow-ctl agent exec ps --group default
+deepflow-ctl agent exec ping -l type=7,state=1 --param addr=10.1.1.1 --concurrency 10 --agent-timeout 10
+deepflow-ctl --timeout 5m agent exec java-dump-stack --group g-xxx --param pid=1234 -o yaml`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := execAgentCMD(cmd, args, p); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		},
+	}
+	exec.Flags().StringVarP(&p.group, "group", "g", "", "agent group name or lcuuid")
+	exec.Flags().StringVarP(&p.selector, "selector", "l", "", "agent label selector, supports '=' and '!=', e.g.: type=7,region!=xxx")
+	exec.Flags().StringArrayVarP(&p.params, "param", "p", nil, "command parameter in key=value format, can be repeated")
+	exec.Flags().Uint32VarP(&p.nsPid, "ns-pid", "", 0, "run command in the linux namespace of this pid")
+	exec.Flags().StringVarP(&p.commandIdent, "command-ident", "", "", "command ident, default is the command name")
+	exec.Flags().IntVarP(&p.concurrency, "concurrency", "c", 0, "max number of agents running command at the same time, default 20")
+	exec.Flags().IntVarP(&p.agentTimeout, "agent-timeout", "", 0, "timeout of each agent in seconds, default is agent-cmd-timeout of server, at most 10 times of it")
+	exec.Flags().StringVarP(&p.output, "output", "o", "", "output format, supports yaml")
+	return exec
+}
+
+func registerAgentExecAuditCommand() *cobra.Command {
+	var cmdName string
+	var limit int
+	execAudit := &cobra.Command{
+		Use:     "exec-audit [id]",
+		Short:   "list audits of agent command execution, show detail if id is specified",
+		Example: "deepflow-ctl agent exec-audit --cmd ps\ndeepflow-ctl agent exec-audit 1",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := listAgentExecAudit(cmd, args, cmdName, limit); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		},
+	}
+	execAudit.Flags().StringVarP(&cmdName, "cmd", "", "", "filter by command")
+	execAudit.Flags().IntVarP(&limit, "limit", "", 0, "max number of audits, default 100")
+	return execAudit
+}
+
+func execAgentCMD(cmd *cobra.Command, args []string, p agentExecParams) error {
+	if len(args) == 0 {
+		return fmt.Errorf("must specify command.\nExample: %s", cmd.Example)
+	}
+	if p.group == "" && p.selector == "" {
+		return fmt.Errorf("must specify --group or --selector.\nExample: %s", cmd.Example)
+	}
+	body := map[string]interface{}{
+		"cmd":           args[0],
+		"command_ident": args[0],
+	}
+	if p.commandIdent != "" {
+		body["command_ident"] = p.commandIdent
+	}
+	if p.group != "" {
+		body["agent_group"] = p.group
+	}
+	if p.selector != "" {
+		body["label_selector"] = p.selector
+	}
+	if p.nsPid != 0 {
+		body["linux_ns_pid"] = p.nsPid
+	}
+	if p.concurrency != 0 {
+		body["concurrency"] = p.concurrency
+	}
+	if p.agentTimeout != 0 {
+		body["timeout"] = p.agentTimeout
+	}
+	var params []map[string]string
+	for _, param := range p.params {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid param (%s), key=value is expected", param)
+		}
+		params = append(params, map[string]string{"key": kv[0], "value": kv[1]})
+	}
+	if len(params) > 0 {
+		body["params"] = params
+	}
+
+	server := common.GetServerInfo(cmd)
+	url := fmt.Sprintf("http://%s:%d/v1/agents/cmd/run", server.IP, server.Port)
+	response, err := common.CURLPerform("POST", url, body, "",
+		[]common.HTTPOption{common.WithTimeout(common.GetTimeout(cmd)), common.WithORGID(common.GetORGID(cmd))}...)
+	if err != nil {
+		return err
+	}
+
+	data := response.Get("DATA")
+	if p.output == "yaml" {
+		dataJson, _ := data.MarshalJSON()
+		dataYaml, _ := yaml.JSONToYAML(dataJson)
+		fmt.Printf(string(dataYaml))
+		return nil
+	}
+
+	t := table.New()
+	t.SetHeader([]string{"AGENT_ID", "AGENT_NAME", "CTRL_IP", "STATUS", "DURATION(ms)"})
+	results := data.Get("RESULTS")
+	for i := range results.MustArray() {
+		r := results.GetIndex(i)
+		t.Append([]string{
+			strconv.Itoa(r.Get("AGENT_ID").MustInt()),
+			r.Get("AGENT_NAME").MustString(),
+			r.Get("CTRL_IP").MustString(),
+			r.Get("STATUS").MustString(),
+			strconv.Itoa(r.Get("DURATION").MustInt()),
+		})
+	}
+	t.Render()
+	fmt.Printf("audit id: %d, total: %d, success: %d, failure: %d\n",
+		data.Get("AUDIT_ID").MustInt(), data.Get("TOTAL").MustInt(), data.Get("SUCCESS").MustInt(), data.Get("FAILURE").MustInt())
+
+	for i := range results.MustArray() {
+		r := results.GetIndex(i)
+		fmt.Printf("\n===== agent: %s (%s) =====\n", r.Get("AGENT_NAME").MustString(), r.Get("STATUS").MustString())
+		if stdout := r.Get("STDOUT").MustString(); stdout != "" {
+			fmt.Println(stdout)
+		}
+		if stderr := r.Get("STDERR").MustString(); stderr != "" {
+			fmt.Fprintln(os.Stderr, stderr)
+		}
+	}
+	return nil
+}
+
+func listAgentExecAudit(cmd *cobra.Command, args []string, cmdName string, limit int) error {
+	server := common.GetServerInfo(cmd)
+	url := fmt.Sprintf("http://%s:%d/v1/agent-cmd-audits/", server.IP, server.Port)
+	if len(args) > 0 {
+		url += args[0] + "/"
+	} else {
+		var query []string
+		if cmdName != "" {
+			query = append(query, "cmd="+cmdName)
+		}
+		if limit != 0 {
+			query = append(query, fmt.Sprintf("limit=%d", limit))
+		}
+		if len(query) > 0 {
+			url += "?" + strings.Join(query, "&")
+		}
+	}
+	response, err := common.CURLPerform("GET", url, nil, "",
+		[]common.HTTPOption{common.WithTimeout(common.GetTimeout(cmd)), common.WithORGID(common.GetORGID(cmd))}...)
+	if err != nil {
+		return err
+	}
+
+	if len(args) > 0 {
+		dataJson, _ := response.Get("DATA").MarshalJSON()
+		dataYaml, _ := yaml.JSONToYAML(dataJson)
+		fmt.Printf(string(dataYaml))
+		return nil
+	}
+	t := table.New()
+	t.SetHeader([]string{"ID", "CMD", "USER_ID", "SOURCE_IP", "AGENT_COUNT", "SUCCESS", "FAILURE", "CREATED_AT"})
+	for i := range response.Get("DATA").MustArray() {
+		a := response.Get("DATA").GetIndex(i)
+		t.Append([]string{
+			strconv.Itoa(a.Get("ID").MustInt()),
+			a.Get("CMD").MustString(),
+			strconv.Itoa(a.Get("USER_ID").MustInt()),
+			a.Get("SOURCE_IP").MustString(),
+			strconv.Itoa(a.Get("AGENT_COUNT").MustInt()),
+			strconv.Itoa(a.Get("SUCCESS_COUNT").MustInt()),
+			strconv.Itoa(a.Get("FAILURE_COUNT").MustInt()),
+			a.Get("CREATED_AT").MustString(),
+		})
+	}
+	t.Render()
+	return nil
+}
@@ -69,6 +69,10 @@ func Execute(version string) {
 	root.AddCommand(RegisterPluginCommand())
 	root.AddCommand(RegisterPrometheusCommand())
 	root.AddCommand(RegisterPromQLCommand())
+	root.AddCommand(RegisterQueryCommand())
+	root.AddCommand(RegisterPcapCommand())
+	root.AddCommand(RegisterPcapPolicyCommand())
+	root.AddCommand(RegisterNpbPolicyCommand())
 
 	cmd.RegisterIngesterCommand(root)
 
@@ -0,0 +1,48 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package table
+
+import "math"
+
+var sparkTicks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline 将一组数值渲染为单行字符图，用于在终端中粗略查看时序数据的变化趋势
+// NaN 和 Inf 渲染为空格
+func Sparkline(values []float64) string {
+	minV, maxV := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			continue
+		}
+		minV = math.Min(minV, v)
+		maxV = math.Max(maxV, v)
+	}
+
+	line := make([]rune, 0, len(values))
+	for _, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			line = append(line, ' ')
+			continue
+		}
+		index := 0
+		if maxV > minV {
+			index = int((v - minV) / (maxV - minV) * float64(len(sparkTicks)-1))
+		}
+		line = append(line, sparkTicks[index])
+	}
+	return string(line)
+}
@@ -0,0 +1,33 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package table
+
+import (
+	"fmt"
+	"math"
+)
+
+func ExampleSparkline() {
+	fmt.Println(Sparkline([]float64{1, 2, 3, 4, 5, 6, 7, 8}))
+	fmt.Println(Sparkline([]float64{0, 10, math.NaN(), 10, 0}))
+	fmt.Println(Sparkline([]float64{3, 3, 3}))
+
+	// Output:
+	// ▁▂▃▄▅▆▇█
+	// ▁█ █▁
+	// ▁▁▁
+}
@@ -88,7 +88,7 @@ func RegisterDomainCommand() *cobra.Command {
 		Use:     "example domain_type",
 		Short:   "example domain create yaml",
 		Long:    "supported types: " + strings.Trim(fmt.Sprint(common.DomainTypes), "[]"),
-		Example: "deepflow-ctl domain example agent_sync \nsupport example type: aliyun | aws | baidu_bce | filereader | agent_sync | \nhuawei | kubernetes | qingcloud | tencent | volcengine",
+		Example: "deepflow-ctl domain example agent_sync \nsupport example type: aliyun | aws | azure | baidu_bce | filereader | agent_sync | \nhuawei | kubernetes | qingcloud | tencent | volcengine",
 		Run: func(cmd *cobra.Command, args []string) {
 			exampleDomainConfig(cmd, args)
 		},
@@ -299,6 +299,8 @@ func exampleDomainConfig(cmd *cobra.Command, args []string) {
 		fmt.Printf(string(example.YamlDomainAliYun))
 	case common.DOMAIN_TYPE_AWS:
 		fmt.Printf(string(example.YamlDomainAws))
+	case common.DOMAIN_TYPE_AZURE:
+		fmt.Printf(string(example.YamlDomainAzure))
 	case common.DOMAIN_TYPE_TENCENT:
 		fmt.Printf(string(example.YamlDomainTencent))
 	case common.DOMAIN_TYPE_HUAWEI:
@@ -24,6 +24,9 @@ var YamlDomainAliYun []byte
 //go:embed domain_aws.yaml
 var YamlDomainAws []byte
 
+//go:embed domain_azure.yaml
+var YamlDomainAzure []byte
+
 //go:embed domain_baidubce.yaml
 var YamlDomainBaiduBce []byte
 
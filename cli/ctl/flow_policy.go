@@ -0,0 +1,339 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ctl
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	simplejson "github.com/bitly/go-simplejson"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+
+	"github.com/deepflowio/deepflow/cli/ctl/common"
+	"github.com/deepflowio/deepflow/cli/ctl/common/table"
+)
+
+type policyType struct {
+	name     string // command name
+	resource string // api resource path
+	npb      bool
+}
+
+var (
+	pcapPolicyType = policyType{name: "pcap-policy", resource: "pcap-policies"}
+	npbPolicyType  = policyType{name: "npb-policy", resource: "npb-policies", npb: true}
+)
+
+func RegisterPcapPolicyCommand() *cobra.Command {
+	return registerPolicyCommand(pcapPolicyType)
+}
+
+func RegisterNpbPolicyCommand() *cobra.Command {
+	return registerPolicyCommand(npbPolicyType)
+}
+
+func registerPolicyCommand(pt policyType) *cobra.Command {
+	policy := &cobra.Command{
+		Use:   pt.name,
+		Short: pt.name + " operation commands",
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Printf("please run with 'list | create | update | delete'.\n")
+		},
+	}
+
+	var listOutput string
+	list := &cobra.Command{
+		Use:     "list [name]",
+		Short:   "list " + pt.name + " info",
+		Example: fmt.Sprintf("deepflow-ctl %s list\ndeepflow-ctl %s list capture-frontend -o yaml", pt.name, pt.name),
+		Run: func(cmd *cobra.Command, args []string) {
+			listPolicy(cmd, args, pt, listOutput)
+		},
+	}
+	list.Flags().StringVarP(&listOutput, "output", "o", "", "output format, support: yaml")
+
+	example := fmt.Sprintf("deepflow-ctl %s create capture-frontend --dst-group-ids 12 --protocol 6 --dst-ports 80,8000-8080 --agent-groups g-1yhIguXABC --payload-slice 128 --ttl 30m", pt.name)
+	if pt.npb {
+		example += " --npb-tunnel-id 1 --vni 100"
+	}
+	create := &cobra.Command{
+		Use:     "create <name>",
+		Short:   "create " + pt.name,
+		Example: example,
+		Run: func(cmd *cobra.Command, args []string) {
+			createPolicy(cmd, args, pt)
+		},
+	}
+	addPolicyFlags(create, pt)
+
+	update := &cobra.Command{
+		Use:     "update <name>",
+		Short:   "update " + pt.name + ", only specified flags are changed",
+		Example: fmt.Sprintf("deepflow-ctl %s update capture-frontend --ttl 1h\ndeepflow-ctl %s update capture-frontend --state 0", pt.name, pt.name),
+		Run: func(cmd *cobra.Command, args []string) {
+			updatePolicy(cmd, args, pt)
+		},
+	}
+	update.Flags().String("name", "", "new name")
+	addPolicyFlags(update, pt)
+
+	delete := &cobra.Command{
+		Use:     "delete <name>",
+		Short:   "delete " + pt.name,
+		Example: fmt.Sprintf("deepflow-ctl %s delete capture-frontend", pt.name),
+		Run: func(cmd *cobra.Command, args []string) {
+			deletePolicy(cmd, args, pt)
+		},
+	}
+
+	policy.AddCommand(list)
+	policy.AddCommand(create)
+	policy.AddCommand(update)
+	policy.AddCommand(delete)
+	return policy
+}
+
+func addPolicyFlags(cmd *cobra.Command, pt policyType) {
+	cmd.Flags().Int("state", 1, "0-disable; 1-enable")
+	cmd.Flags().Int("team-id", 0, "team id, default: 1")
+	cmd.Flags().Int("tap-type", 3, "tap type, 1-WAN; 3-LAN")
+	cmd.Flags().IntSlice("src-group-ids", nil, "source resource group ids, empty means any")
+	cmd.Flags().IntSlice("dst-group-ids", nil, "destination resource group ids, empty means any")
+	cmd.Flags().Int("protocol", 0, "ip protocol number, e.g.: 6-TCP, 17-UDP, default: any")
+	cmd.Flags().String("src-ports", "", "source ports, e.g.: 80,8000-8080")
+	cmd.Flags().String("dst-ports", "", "destination ports, e.g.: 80,8000-8080")
+	cmd.Flags().Int("vlan", 0, "vlan id, 0 means any")
+	cmd.Flags().StringSlice("agent-groups", nil, "agent group ids the policy applies to, empty means all agents")
+	cmd.Flags().Int("payload-slice", 65535, "max bytes of each packet to capture or distribute")
+	cmd.Flags().Int("acl-gid", 0, "acl group id, allocated automatically if not specified")
+	cmd.Flags().Duration("ttl", 0, "the policy expires after this duration, e.g.: 30m, 0 means never expire")
+	if pt.npb {
+		cmd.Flags().Int("npb-tunnel-id", 0, "npb tunnel id")
+		cmd.Flags().Int("vni", 0, "tunnel vni")
+		cmd.Flags().Int("direction", 1, "1-all; 2-forward; 3-backward")
+		cmd.Flags().Int("distribute", 1, "0-drop; 1-distribute")
+	}
+	cmd.Flags().SortFlags = false
+}
+
+// 只将命令行中指定的参数转换为API请求参数
+func getPolicyBody(cmd *cobra.Command, pt policyType) map[string]interface{} {
+	body := make(map[string]interface{})
+	flags := cmd.Flags()
+	intFlags := map[string]string{
+		"state": "STATE", "team-id": "TEAM_ID", "tap-type": "TAP_TYPE", "protocol": "PROTOCOL", "vlan": "VLAN",
+		"payload-slice": "PAYLOAD_SLICE", "acl-gid": "ACL_GID",
+	}
+	if pt.npb {
+		for k, v := range map[string]string{"npb-tunnel-id": "NPB_TUNNEL_ID", "vni": "VNI", "direction": "DIRECTION", "distribute": "DISTRIBUTE"} {
+			intFlags[k] = v
+		}
+	}
+	for flag, key := range intFlags {
+		if flags.Changed(flag) {
+			body[key], _ = flags.GetInt(flag)
+		}
+	}
+	for flag, key := range map[string]string{"src-group-ids": "SRC_GROUP_IDS", "dst-group-ids": "DST_GROUP_IDS"} {
+		if flags.Changed(flag) {
+			body[key], _ = flags.GetIntSlice(flag)
+		}
+	}
+	for flag, key := range map[string]string{"name": "NAME", "src-ports": "SRC_PORTS", "dst-ports": "DST_PORTS"} {
+		if flags.Lookup(flag) != nil && flags.Changed(flag) {
+			body[key], _ = flags.GetString(flag)
+		}
+	}
+	if flags.Changed("agent-groups") {
+		body["VTAP_GROUP_IDS"], _ = flags.GetStringSlice("agent-groups")
+	}
+	if flags.Changed("ttl") {
+		ttl, _ := flags.GetDuration("ttl")
+		body["TTL"] = int(ttl / time.Second)
+	}
+	return body
+}
+
+func listPolicy(cmd *cobra.Command, args []string, pt policyType, output string) {
+	server := common.GetServerInfo(cmd)
+	url := fmt.Sprintf("http://%s:%d/v1/%s/", server.IP, server.Port, pt.resource)
+	if len(args) > 0 {
+		url += fmt.Sprintf("?name=%s", args[0])
+	}
+	response, err := common.CURLPerform("GET", url, nil, "",
+		[]common.HTTPOption{common.WithTimeout(common.GetTimeout(cmd)), common.WithORGID(common.GetORGID(cmd))}...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+
+	if output == "yaml" {
+		dataJson, _ := response.Get("DATA").MarshalJSON()
+		dataYaml, _ := yaml.JSONToYAML(dataJson)
+		fmt.Printf(string(dataYaml))
+		return
+	}
+	header := []string{"NAME", "STATE", "ACL_GID", "PROTOCOL", "DST_PORTS", "AGENTS", "PAYLOAD_SLICE", "EXPIRED_AT"}
+	if pt.npb {
+		header = append(header, "NPB_TUNNEL_ID")
+	}
+	t := table.New()
+	t.SetHeader(header)
+	tableItems := [][]string{}
+	for i := range response.Get("DATA").MustArray() {
+		p := response.Get("DATA").GetIndex(i)
+		state := "enable"
+		if p.Get("STATE").MustInt() == 0 {
+			state = "disable"
+		}
+		protocol := "any"
+		if v, err := p.Get("PROTOCOL").Int(); err == nil {
+			protocol = strconv.Itoa(v)
+		}
+		agents := "all"
+		if vtapIDs := p.Get("VTAP_IDS").MustArray(); len(vtapIDs) > 0 || len(p.Get("VTAP_GROUPS").MustArray()) > 0 {
+			agents = strconv.Itoa(len(vtapIDs))
+		}
+		payloadSlice := "65535"
+		if v, err := p.Get("PAYLOAD_SLICE").Int(); err == nil {
+			payloadSlice = strconv.Itoa(v)
+		}
+		expiredAt := p.Get("EXPIRED_AT").MustString()
+		if p.Get("EXPIRED").MustBool() {
+			expiredAt += " (expired)"
+		}
+		item := []string{
+			p.Get("NAME").MustString(),
+			state,
+			strconv.Itoa(p.Get("ACL_GID").MustInt()),
+			protocol,
+			p.Get("DST_PORTS").MustString(),
+			agents,
+			payloadSlice,
+			expiredAt,
+		}
+		if pt.npb {
+			item = append(item, strconv.Itoa(p.Get("NPB_TUNNEL_ID").MustInt()))
+		}
+		tableItems = append(tableItems, item)
+	}
+	t.AppendBulk(tableItems)
+	t.Render()
+}
+
+func createPolicy(cmd *cobra.Command, args []string, pt policyType) {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "must specify name.\nExample: %s\n", cmd.Example)
+		return
+	}
+	body := getPolicyBody(cmd, pt)
+	body["NAME"] = args[0]
+	if pt.npb {
+		if _, ok := body["NPB_TUNNEL_ID"]; !ok {
+			fmt.Fprintf(os.Stderr, "must specify --npb-tunnel-id.\nExample: %s\n", cmd.Example)
+			return
+		}
+	}
+
+	server := common.GetServerInfo(cmd)
+	url := fmt.Sprintf("http://%s:%d/v1/%s/", server.IP, server.Port, pt.resource)
+	response, err := common.CURLPerform("POST", url, body, "",
+		[]common.HTTPOption{common.WithTimeout(common.GetTimeout(cmd)), common.WithORGID(common.GetORGID(cmd))}...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	printPolicyResult(response, "created")
+}
+
+func updatePolicy(cmd *cobra.Command, args []string, pt policyType) {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "must specify name.\nExample: %s\n", cmd.Example)
+		return
+	}
+	body := getPolicyBody(cmd, pt)
+	if len(body) == 0 {
+		fmt.Fprintf(os.Stderr, "nothing to update.\nExample: %s\n", cmd.Example)
+		return
+	}
+	lcuuid, err := getPolicyLcuuid(cmd, pt, args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+
+	server := common.GetServerInfo(cmd)
+	url := fmt.Sprintf("http://%s:%d/v1/%s/%s/", server.IP, server.Port, pt.resource, lcuuid)
+	response, err := common.CURLPerform("PATCH", url, body, "",
+		[]common.HTTPOption{common.WithTimeout(common.GetTimeout(cmd)), common.WithORGID(common.GetORGID(cmd))}...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	printPolicyResult(response, "updated")
+}
+
+func deletePolicy(cmd *cobra.Command, args []string, pt policyType) {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "must specify name.\nExample: %s\n", cmd.Example)
+		return
+	}
+	lcuuid, err := getPolicyLcuuid(cmd, pt, args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+
+	server := common.GetServerInfo(cmd)
+	url := fmt.Sprintf("http://%s:%d/v1/%s/%s/", server.IP, server.Port, pt.resource, lcuuid)
+	_, err = common.CURLPerform("DELETE", url, nil, "",
+		[]common.HTTPOption{common.WithTimeout(common.GetTimeout(cmd)), common.WithORGID(common.GetORGID(cmd))}...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	fmt.Printf("%s (%s) deleted\n", pt.name, args[0])
+}
+
+func getPolicyLcuuid(cmd *cobra.Command, pt policyType, name string) (string, error) {
+	server := common.GetServerInfo(cmd)
+	url := fmt.Sprintf("http://%s:%d/v1/%s/?name=%s", server.IP, server.Port, pt.resource, name)
+	response, err := common.CURLPerform("GET", url, nil, "",
+		[]common.HTTPOption{common.WithTimeout(common.GetTimeout(cmd)), common.WithORGID(common.GetORGID(cmd))}...)
+	if err != nil {
+		return "", err
+	}
+	policies := response.Get("DATA").MustArray()
+	if len(policies) == 0 {
+		return "", fmt.Errorf("%s (%s) not found", pt.name, name)
+	}
+	if len(policies) > 1 {
+		return "", fmt.Errorf("%s name (%s) is not unique", pt.name, name)
+	}
+	return response.Get("DATA").GetIndex(0).Get("LCUUID").MustString(), nil
+}
+
+func printPolicyResult(response *simplejson.Json, action string) {
+	data := response.Get("DATA")
+	fmt.Printf("%s (%s) %s, acl_gid: %d\n", data.Get("NAME").MustString(), data.Get("LCUUID").MustString(), action, data.Get("ACL_GID").MustInt())
+	if expiredAt := data.Get("EXPIRED_AT").MustString(); expiredAt != "" {
+		fmt.Printf("expires at %s\n", expiredAt)
+	}
+}
@@ -0,0 +1,144 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ctl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deepflowio/deepflow/cli/ctl/common"
+	ctrlcommon "github.com/deepflowio/deepflow/server/controller/common"
+)
+
+func RegisterPcapCommand() *cobra.Command {
+	pcap := &cobra.Command{
+		Use:   "pcap",
+		Short: "pcap operation commands",
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Println("please run with 'download'.")
+		},
+	}
+	pcap.PersistentFlags().Uint32P("querier-port", "", 30416, "deepflow-server querier node port")
+
+	pcap.AddCommand(pcapDownloadCommand())
+	return pcap
+}
+
+func pcapDownloadCommand() *cobra.Command {
+	var flowIDs []uint64
+	var queryCondition, output string
+	var aclGid uint16
+	download := &cobra.Command{
+		Use:   "download",
+		Short: "download packets stored by pcap policies as a pcapng file",
+		Example: `deepflow-ctl pcap download --flow-id 7131293582393298944 --since 1h
+deepflow-ctl pcap download --query "pod_0='frontend' AND server_port=80" --from 2024-01-01T00:00:00Z --to 2024-01-01T01:00:00Z -O frontend.pcapng
+deepflow-ctl pcap download --acl-gid 3 --since 10m -O - | tshark -r -`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(flowIDs) == 0 && queryCondition == "" && aclGid == 0 {
+				fmt.Fprintln(os.Stderr, "please specify one of --flow-id, --query and --acl-gid")
+				return
+			}
+			from, to, err := getQueryTime(cmd)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "parse time error: %v\n", err)
+				return
+			}
+			body := map[string]interface{}{
+				"flow_ids":        flowIDs,
+				"query_condition": queryCondition,
+				"acl_gid":         aclGid,
+				"time_start":      from,
+				"time_end":        to,
+			}
+			if output == "" {
+				output = fmt.Sprintf("deepflow-%d-%d.pcapng", from, to)
+			}
+			if err := pcapDownload(cmd, body, output); err != nil {
+				fmt.Fprintf(os.Stderr, "pcap download error: %v\n", err)
+			}
+		},
+	}
+	download.Flags().Uint64SliceVarP(&flowIDs, "flow-id", "", nil, "flow_id of l4_flow_log, can be specified multiple times")
+	download.Flags().StringVarP(&queryCondition, "query", "q", "", "l4_flow_log filter to select flows, e.g.: pod_0='frontend' AND server_port=80")
+	download.Flags().Uint16VarP(&aclGid, "acl-gid", "", 0, "pcap policy acl group id")
+	download.Flags().String("since", "1h", "download packets since time duration like [5m,1h], default: 1h")
+	download.Flags().String("from", "", "download packets from a specific time(RFC3339), e.g.: 2000-01-01T00:00:00Z")
+	download.Flags().String("to", "", "download packets to a specific time(RFC3339), e.g.: 2000-01-01T00:00:00Z")
+	download.Flags().StringVarP(&output, "output", "O", "", "output file, use '-' to write to stdout, default: deepflow-<from>-<to>.pcapng")
+	return download
+}
+
+func pcapDownload(cmd *cobra.Command, body map[string]interface{}, output string) error {
+	server := common.GetServerInfo(cmd)
+	port, _ := cmd.Flags().GetUint32("querier-port")
+	url := fmt.Sprintf("http://%s:%d/v1/pcap/download", server.IP, port)
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(ctrlcommon.HEADER_KEY_X_ORG_ID, strconv.Itoa(common.GetORGID(cmd)))
+
+	client := &http.Client{Timeout: common.GetTimeout(cmd)}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("curl (%s) failed, (%v)", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respBytes, _ := ioutil.ReadAll(resp.Body)
+		var errResp struct {
+			Description string `json:"DESCRIPTION"`
+		}
+		if json.Unmarshal(respBytes, &errResp) == nil && errResp.Description != "" {
+			return errors.New(errResp.Description)
+		}
+		return fmt.Errorf("curl (%s) failed, (%d %s)", url, resp.StatusCode, string(respBytes))
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "-" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	n, err := io.Copy(w, resp.Body)
+	if err != nil {
+		return err
+	}
+	if output != "-" {
+		fmt.Printf("%d bytes saved to %s\n", n, output)
+	}
+	return nil
+}
@@ -0,0 +1,416 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ctl
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	simplejson "github.com/bitly/go-simplejson"
+	"github.com/spf13/cobra"
+
+	"github.com/deepflowio/deepflow/cli/ctl/common"
+	"github.com/deepflowio/deepflow/cli/ctl/common/table"
+)
+
+const (
+	QUERY_OUTPUT_TABLE     = "table"
+	QUERY_OUTPUT_CSV       = "csv"
+	QUERY_OUTPUT_JSON      = "json"
+	QUERY_OUTPUT_SPARKLINE = "sparkline"
+)
+
+func RegisterQueryCommand() *cobra.Command {
+	query := &cobra.Command{
+		Use:   "query",
+		Short: "query deepflow-server querier with sql or promql",
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Println("please run with 'sql | promql'.")
+		},
+	}
+	query.PersistentFlags().Uint32P("querier-port", "", 30416, "deepflow-server querier node port")
+	query.PersistentFlags().StringP("output", "o", QUERY_OUTPUT_TABLE, "output format, support: table, csv, json, sparkline")
+	query.PersistentFlags().StringP("file", "f", "", "read query from file, use '-' to read from stdin")
+	query.PersistentFlags().Bool("debug", false, "print querier debug info (translated sql, query time, etc.) to stderr")
+
+	query.AddCommand(querySQLCommand())
+	query.AddCommand(queryPromQLCommand())
+	query.ParseFlags(os.Args[1:])
+	return query
+}
+
+func querySQLCommand() *cobra.Command {
+	var db, dataPrecision string
+	sql := &cobra.Command{
+		Use:   "sql [SQL]",
+		Short: "execute sql query",
+		Example: `deepflow-ctl query sql --db flow_log "SELECT request_domain, Count(row) FROM l7_flow_log WHERE time>now()-300 GROUP BY request_domain LIMIT 10"
+echo "SHOW tables" | deepflow-ctl query sql --db flow_metrics -f - -o csv`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if db == "" {
+				fmt.Fprintln(os.Stderr, "please specify the database with --db")
+				return
+			}
+			sqlText, err := readQueryText(cmd, args)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return
+			}
+			if err = sqlQuery(cmd, db, dataPrecision, sqlText); err != nil {
+				fmt.Fprintf(os.Stderr, "sql query error: %v\n", err)
+			}
+		},
+	}
+	sql.Flags().StringVarP(&db, "db", "", "", "database to query, e.g.: flow_log, flow_metrics, ext_metrics")
+	sql.Flags().StringVarP(&dataPrecision, "data-precision", "", "", "data precision of flow_metrics, e.g.: 1s, 1m")
+	return sql
+}
+
+func queryPromQLCommand() *cobra.Command {
+	var start, end, since, step string
+	promql := &cobra.Command{
+		Use:   "promql [QUERY]",
+		Short: "execute promql range query",
+		Example: `deepflow-ctl query promql --since 30m --step 1m "sum(rate(flow_metrics__network__byte[1m])) by (pod)" -o sparkline
+deepflow-ctl query promql --start 2024-01-01T00:00:00Z --end 2024-01-01T01:00:00Z -f query.promql`,
+		Run: func(cmd *cobra.Command, args []string) {
+			promqlText, err := readQueryText(cmd, args)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return
+			}
+			startTime, endTime, err := getRangeQueryTime(start, end, since)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "parse time error: %v\n", err)
+				return
+			}
+			if err = promQLRangeQuery(cmd, promqlText, startTime, endTime, step); err != nil {
+				fmt.Fprintf(os.Stderr, "promql query error: %v\n", err)
+			}
+		},
+	}
+	promql.Flags().StringVarP(&start, "start", "", "", "query start time, RFC3339 or unix timestamp, e.g.: 2000-01-01T00:00:00Z")
+	promql.Flags().StringVarP(&end, "end", "", "", "query end time, RFC3339 or unix timestamp, default: now")
+	promql.Flags().StringVarP(&since, "since", "", "1h", "query since time duration like [5m,1h], only used when --start is not specified")
+	promql.Flags().StringVarP(&step, "step", "", "1m", "query resolution step width, e.g.: 15s, 1m")
+	return promql
+}
+
+// readQueryText 按优先级从 --file、标准输入 ('-') 或命令行参数读取查询语句
+func readQueryText(cmd *cobra.Command, args []string) (string, error) {
+	file, _ := cmd.Flags().GetString("file")
+	if file == "" && len(args) == 1 && args[0] == "-" {
+		file = "-"
+	}
+
+	var text string
+	if file != "" {
+		var content []byte
+		var err error
+		if file == "-" {
+			content, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			content, err = ioutil.ReadFile(file)
+		}
+		if err != nil {
+			return "", fmt.Errorf("read query from %s failed: %v", file, err)
+		}
+		text = string(content)
+	} else {
+		text = strings.Join(args, " ")
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", errors.New("query is empty, please specify it as an argument or with --file")
+	}
+	return text, nil
+}
+
+func getRangeQueryTime(start, end, since string) (time.Time, time.Time, error) {
+	var startTime, endTime time.Time
+	var err error
+	if end != "" {
+		if endTime, err = parseQueryTime(end); err != nil {
+			return startTime, endTime, err
+		}
+	} else {
+		endTime = time.Now()
+	}
+	if start != "" {
+		if startTime, err = parseQueryTime(start); err != nil {
+			return startTime, endTime, err
+		}
+	} else {
+		sinceDuration, err := time.ParseDuration(since)
+		if err != nil {
+			return startTime, endTime, err
+		}
+		startTime = endTime.Add(-sinceDuration)
+	}
+	if startTime.After(endTime) {
+		return startTime, endTime, fmt.Errorf("query time start: %d should not greater than end: %d", startTime.Unix(), endTime.Unix())
+	}
+	return startTime, endTime, nil
+}
+
+func parseQueryTime(s string) (time.Time, error) {
+	if ts, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(ts, 0), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func getQuerierURL(cmd *cobra.Command, path string) string {
+	server := common.GetServerInfo(cmd)
+	port, _ := cmd.Flags().GetUint32("querier-port")
+	return fmt.Sprintf("http://%s:%d%s", server.IP, port, path)
+}
+
+func sqlQuery(cmd *cobra.Command, db, dataPrecision, sqlText string) error {
+	debug, _ := cmd.Flags().GetBool("debug")
+	queryURL := getQuerierURL(cmd, "/v1/query/")
+	if debug {
+		queryURL += "?debug=true"
+	}
+	form := url.Values{}
+	form.Set("db", db)
+	form.Set("sql", sqlText)
+	if dataPrecision != "" {
+		form.Set("data_precision", dataPrecision)
+	}
+	response, err := common.CURLPerform("POST", queryURL, nil, form.Encode(),
+		[]common.HTTPOption{common.WithTimeout(common.GetTimeout(cmd)), common.WithORGID(common.GetORGID(cmd))}...)
+	if debug {
+		printQueryDebug(response.Get("debug"))
+	}
+	if err != nil {
+		return err
+	}
+
+	result := response.Get("result")
+	columns := result.Get("columns").MustStringArray()
+	values := result.Get("values")
+	rows := make([][]string, 0, len(values.MustArray()))
+	for i := range values.MustArray() {
+		row := make([]string, 0, len(columns))
+		for j := range columns {
+			row = append(row, formatQueryValue(values.GetIndex(i).GetIndex(j).Interface()))
+		}
+		rows = append(rows, row)
+	}
+
+	output, _ := cmd.Flags().GetString("output")
+	switch output {
+	case QUERY_OUTPUT_JSON:
+		printQueryJson(result)
+	case QUERY_OUTPUT_SPARKLINE:
+		// 每个数值列渲染为一条 sparkline，非数值列忽略
+		t := table.New()
+		t.SetHeader([]string{"COLUMN", "MIN", "MAX", "SPARKLINE"})
+		for j, column := range columns {
+			points, ok := parseFloatColumn(rows, j)
+			if !ok {
+				continue
+			}
+			t.Append(append([]string{column}, formatSparkline(points)...))
+		}
+		t.Render()
+	default:
+		return printQueryRows(output, columns, rows)
+	}
+	return nil
+}
+
+func promQLRangeQuery(cmd *cobra.Command, promqlText string, start, end time.Time, step string) error {
+	debug, _ := cmd.Flags().GetBool("debug")
+	params := url.Values{}
+	params.Set("query", promqlText)
+	params.Set("start", strconv.FormatInt(start.Unix(), 10))
+	params.Set("end", strconv.FormatInt(end.Unix(), 10))
+	params.Set("step", step)
+	if debug {
+		params.Set("debug", "true")
+	}
+	queryURL := getQuerierURL(cmd, "/prom/api/v1/query_range")
+	response, err := common.CURLPerform("POST", queryURL, nil, params.Encode(),
+		[]common.HTTPOption{common.WithTimeout(common.GetTimeout(cmd)), common.WithORGID(common.GetORGID(cmd))}...)
+	if debug {
+		printQueryDebug(response.Get("stats"))
+	}
+	if err != nil {
+		return err
+	}
+	if status := response.Get("status").MustString(); status != "success" {
+		return fmt.Errorf("%s: %s", response.Get("errorType").MustString(), response.Get("error").MustString())
+	}
+
+	output, _ := cmd.Flags().GetString("output")
+	if output == QUERY_OUTPUT_JSON {
+		printQueryJson(response.Get("data"))
+		return nil
+	}
+
+	// 矩阵结果中每条时间序列展开为 (series, time, value) 行
+	series := response.Get("data").Get("result")
+	rows := [][]string{}
+	sparkRows := [][]string{}
+	for i := range series.MustArray() {
+		s := series.GetIndex(i)
+		name := formatSeriesLabels(s.Get("metric").MustMap())
+		values := s.Get("values")
+		points := make([]float64, 0, len(values.MustArray()))
+		for j := range values.MustArray() {
+			point := values.GetIndex(j)
+			ts, _ := point.GetIndex(0).Float64()
+			value := point.GetIndex(1).MustString()
+			v, _ := strconv.ParseFloat(value, 64)
+			points = append(points, v)
+			rows = append(rows, []string{name, time.Unix(int64(ts), 0).Format(time.RFC3339), value})
+		}
+		sparkRows = append(sparkRows, append([]string{name}, formatSparkline(points)...))
+	}
+
+	if output == QUERY_OUTPUT_SPARKLINE {
+		t := table.New()
+		t.SetHeader([]string{"SERIES", "MIN", "MAX", "SPARKLINE"})
+		t.AppendBulk(sparkRows)
+		t.Render()
+		return nil
+	}
+	return printQueryRows(output, []string{"SERIES", "TIME", "VALUE"}, rows)
+}
+
+func printQueryRows(output string, columns []string, rows [][]string) error {
+	switch output {
+	case QUERY_OUTPUT_TABLE:
+		t := table.New()
+		t.SetHeader(columns)
+		t.AppendBulk(rows)
+		t.Render()
+	case QUERY_OUTPUT_CSV:
+		w := csv.NewWriter(os.Stdout)
+		w.Write(columns)
+		w.WriteAll(rows)
+		return w.Error()
+	default:
+		return fmt.Errorf("unsupported output format: %s", output)
+	}
+	return nil
+}
+
+func printQueryJson(data *simplejson.Json) {
+	dataByte, err := data.MarshalJSON()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	str, err := common.JsonFormat(dataByte)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	fmt.Println(str)
+}
+
+// printQueryDebug 将 debug 信息输出到标准错误，不影响标准输出中的查询结果被重定向或管道处理
+func printQueryDebug(debug *simplejson.Json) {
+	if debug == nil || debug.Interface() == nil {
+		return
+	}
+	debugByte, err := debug.MarshalJSON()
+	if err != nil {
+		return
+	}
+	str, err := common.JsonFormat(debugByte)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(os.Stderr, str)
+}
+
+func formatQueryValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	default:
+		b, _ := json.Marshal(val)
+		return string(b)
+	}
+}
+
+func formatSeriesLabels(labels map[string]interface{}) string {
+	name, _ := labels["__name__"].(string)
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		if k != "__name__" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", k, labels[k]))
+	}
+	return name + "{" + strings.Join(pairs, ", ") + "}"
+}
+
+func parseFloatColumn(rows [][]string, index int) ([]float64, bool) {
+	points := make([]float64, 0, len(rows))
+	for _, row := range rows {
+		v, err := strconv.ParseFloat(row[index], 64)
+		if err != nil {
+			return nil, false
+		}
+		points = append(points, v)
+	}
+	return points, len(points) > 0
+}
+
+// formatSparkline 返回 min、max 及 sparkline 三列
+func formatSparkline(points []float64) []string {
+	if len(points) == 0 {
+		return []string{"", "", ""}
+	}
+	minV, maxV := points[0], points[0]
+	for _, p := range points[1:] {
+		if p < minV {
+			minV = p
+		}
+		if p > maxV {
+			maxV = p
+		}
+	}
+	return []string{
+		strconv.FormatFloat(minV, 'g', -1, 64),
+		strconv.FormatFloat(maxV, 'g', -1, 64),
+		table.Sparkline(points),
+	}
+}
@@ -36,6 +36,7 @@ import (
 	"github.com/deepflowio/deepflow/server/ingester/ingesterctl"
 	"github.com/deepflowio/deepflow/server/libs/debug"
 	"github.com/deepflowio/deepflow/server/libs/logger"
+	prometheus "github.com/deepflowio/deepflow/server/querier/app/prometheus/service"
 	"github.com/deepflowio/deepflow/server/querier/querier"
 
 	logging "github.com/op/go-logging"
@@ -102,12 +103,13 @@ func main() {
 	}()
 
 	report.SetServerInfo(Branch, RevCount, Revision)
+	prometheus.SetBuildInfo(Branch, Revision, CompileTime)
 
 	shared := common.NewControllerIngesterShared()
 
 	go controller.Start(ctx, *configPath, cfg.LogFile, shared)
 
-	go querier.Start(*configPath, cfg.LogFile, shared)
+	go querier.Start(ctx, *configPath, cfg.LogFile, shared)
 	closers := ingester.Start(*configPath, shared)
 
 	common.NewMonitor(cfg.MonitorPaths)
@@ -0,0 +1,235 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bitly/go-simplejson"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/config"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	mysqlmodel "github.com/deepflowio/deepflow/server/controller/db/mysql/model"
+	"github.com/deepflowio/deepflow/server/controller/statsd"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+var log = logger.MustGetLogger("cloud.azure")
+
+const (
+	API_VERSION_SUBSCRIPTION = "2022-12-01"
+	API_VERSION_NETWORK      = "2023-09-01"
+	API_VERSION_COMPUTE      = "2023-09-01"
+)
+
+type Azure struct {
+	orgID          int
+	teamID         int
+	lcuuid         string
+	lcuuidGenerate string
+	name           string
+	httpTimeout    int
+	config         *Config
+	token          *Token
+	toolDataSet    *ToolDataSet       // 处理资源数据时，构建的需要提供给其他资源使用的工具数据
+	cloudStatsd    statsd.CloudStatsd // 性能监控
+	debugger       *cloudcommon.Debugger
+}
+
+func NewAzure(orgID int, domain mysqlmodel.Domain, globalCloudCfg config.CloudConfig) (*Azure, error) {
+	conf := &Config{}
+	err := conf.LoadFromString(orgID, domain.Config)
+	if err != nil {
+		return nil, err
+	}
+	return newAzure(orgID, domain, globalCloudCfg, conf), nil
+}
+
+func newAzure(orgID int, domain mysqlmodel.Domain, globalCloudCfg config.CloudConfig, conf *Config) *Azure {
+	return &Azure{
+		orgID:  orgID,
+		teamID: domain.TeamID,
+		lcuuid: domain.Lcuuid,
+		// TODO: display_name后期需要修改为uuid_generate
+		lcuuidGenerate: domain.DisplayName,
+		name:           domain.Name,
+		httpTimeout:    globalCloudCfg.HTTPTimeout,
+		config:         conf,
+		debugger:       cloudcommon.NewDebugger(domain.Name),
+	}
+}
+
+func (a *Azure) ClearDebugLog() {
+	a.debugger.Clear()
+}
+
+func (a *Azure) CheckAuth() error {
+	token, err := a.createToken()
+	if err != nil {
+		return err
+	}
+	a.token = token
+	// token 申请成功不代表服务主体有订阅的读权限，需要再请求一次订阅信息
+	_, err = requestGet(
+		fmt.Sprintf("%s/subscriptions/%s?api-version=%s", a.config.ResourceManagerEndpoint, a.config.SubscriptionID, API_VERSION_SUBSCRIPTION),
+		token.token, time.Duration(a.httpTimeout),
+	)
+	return err
+}
+
+func (a *Azure) GetCloudData() (model.Resource, error) {
+	a.cloudStatsd = statsd.NewCloudStatsd()
+	a.toolDataSet = NewToolDataSet()
+	var resource model.Resource
+
+	regions, err := a.getRegions()
+	if err != nil {
+		return resource, err
+	}
+
+	err = a.getPublicIPs()
+	if err != nil {
+		return resource, err
+	}
+
+	vpcs, vrouters, networks, subnets, vifs, err := a.getVPCs()
+	if err != nil {
+		return resource, err
+	}
+	resource.VPCs = append(resource.VPCs, vpcs...)
+	resource.VRouters = append(resource.VRouters, vrouters...)
+	resource.Networks = append(resource.Networks, networks...)
+	resource.Subnets = append(resource.Subnets, subnets...)
+	resource.VInterfaces = append(resource.VInterfaces, vifs...)
+
+	peers, err := a.getPeerConnections()
+	if err != nil {
+		return resource, err
+	}
+	resource.PeerConnections = append(resource.PeerConnections, peers...)
+
+	// 云主机的 VPC 和 IP 取自其主网卡，需要先获取网卡
+	vifs, ips, fIPs, err := a.getVInterfaces()
+	if err != nil {
+		return resource, err
+	}
+	resource.VInterfaces = append(resource.VInterfaces, vifs...)
+	resource.IPs = append(resource.IPs, ips...)
+	resource.FloatingIPs = append(resource.FloatingIPs, fIPs...)
+
+	vms, err := a.getVMs()
+	if err != nil {
+		return resource, err
+	}
+	resource.VMs = append(resource.VMs, vms...)
+
+	ngws, vifs, ips, err := a.getNATGateways()
+	if err != nil {
+		return resource, err
+	}
+	resource.NATGateways = append(resource.NATGateways, ngws...)
+	resource.VInterfaces = append(resource.VInterfaces, vifs...)
+	resource.IPs = append(resource.IPs, ips...)
+
+	lbs, listeners, targetServers, vifs, ips, err := a.getLBs()
+	if err != nil {
+		return resource, err
+	}
+	resource.LBs = append(resource.LBs, lbs...)
+	resource.LBListeners = append(resource.LBListeners, listeners...)
+	resource.LBTargetServers = append(resource.LBTargetServers, targetServers...)
+	resource.VInterfaces = append(resource.VInterfaces, vifs...)
+	resource.IPs = append(resource.IPs, ips...)
+
+	log.Debugf("region resource num info: %v", a.toolDataSet.regionLcuuidToResourceNum, logger.NewORGPrefix(a.orgID))
+	log.Debugf("az resource num info: %v", a.toolDataSet.azLcuuidToResourceNum, logger.NewORGPrefix(a.orgID))
+	resource.Regions = cloudcommon.EliminateEmptyRegions(regions, a.toolDataSet.regionLcuuidToResourceNum)
+	resource.AZs = cloudcommon.EliminateEmptyAZs(a.toolDataSet.azs, a.toolDataSet.azLcuuidToResourceNum)
+
+	a.cloudStatsd.ResCount = statsd.GetResCount(resource)
+	statsd.MetaStatsd.RegisterStatsdTable(a)
+
+	a.debugger.Refresh()
+	return resource, nil
+}
+
+func (a *Azure) GetStatter() statsd.StatsdStatter {
+	globalTags := map[string]string{
+		"domain_name": a.name,
+		"domain":      a.lcuuid,
+		"platform":    common.AZURE_EN,
+	}
+
+	return statsd.StatsdStatter{
+		OrgID:      a.orgID,
+		TeamID:     a.teamID,
+		GlobalTags: globalTags,
+		Element:    statsd.GetCloudStatsd(a.cloudStatsd),
+	}
+}
+
+// getRawData 请求订阅下某类资源的列表，按照 nextLink 翻页直到获取全部数据
+// path 为订阅下的资源路径及查询参数，例如 providers/Microsoft.Network/virtualNetworks?api-version=2023-09-01
+func (a *Azure) getRawData(key, path string) ([]*simplejson.Json, error) {
+	statsdAPIStartTime := time.Now()
+	var jsonList []*simplejson.Json
+
+	firstURL := fmt.Sprintf("%s/subscriptions/%s/%s", a.config.ResourceManagerEndpoint, a.config.SubscriptionID, path)
+	for url := firstURL; url != ""; {
+		token, err := a.getToken()
+		if err != nil {
+			return nil, err
+		}
+		resp, err := requestGet(url, token, time.Duration(a.httpTimeout))
+		if err != nil {
+			log.Errorf("request azure api (%s) failed: %s", key, err.Error(), logger.NewORGPrefix(a.orgID))
+			return nil, err
+		}
+		jData := resp.Get("value")
+		for i := range jData.MustArray() {
+			jsonList = append(jsonList, jData.GetIndex(i))
+		}
+		url = resp.Get("nextLink").MustString()
+	}
+	a.cloudStatsd.RefreshAPIMoniter(key, len(jsonList), statsdAPIStartTime)
+
+	a.debugger.WriteJson(key, firstURL, jsonList)
+	return jsonList, nil
+}
+
+// azure 资源 ID 大小写不敏感，同一资源在不同接口中返回的 resourceGroups 等部分大小写可能不一致
+func (a *Azure) generateLcuuid(id string) string {
+	return common.GetUUIDByOrgID(a.orgID, strings.ToLower(id))
+}
+
+// parentID 返回子资源所属资源的 ID，例如 subnet ID 对应的 virtualNetwork ID，
+// ipConfiguration ID 对应的 networkInterface ID
+func parentID(id string) string {
+	index := strings.LastIndex(id, "/")
+	if index < 0 {
+		return id
+	}
+	index = strings.LastIndex(id[:index], "/")
+	if index < 0 {
+		return id
+	}
+	return id[:index]
+}
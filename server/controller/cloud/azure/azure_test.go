@@ -0,0 +1,188 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/deepflowio/deepflow/server/controller/cloud/config"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	mysqlcommon "github.com/deepflowio/deepflow/server/controller/db/mysql/common"
+	mysqlmodel "github.com/deepflowio/deepflow/server/controller/db/mysql/model"
+	"github.com/deepflowio/deepflow/server/controller/statsd"
+	statsdcfg "github.com/deepflowio/deepflow/server/controller/statsd/config"
+)
+
+// newARMTestServer 使用 testdata 中录制的 ARM 接口返回数据模拟 azure
+func newARMTestServer() *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/tenant-1/oauth2/v2.0/token" {
+			r.ParseForm()
+			if r.Form.Get("client_secret") != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error": "invalid_client"}`))
+				return
+			}
+			w.Write([]byte(`{"token_type": "Bearer", "expires_in": 3599, "access_token": "test-token"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var file string
+		switch {
+		case r.URL.Path == "/subscriptions/sub-1":
+			w.Write([]byte(`{"subscriptionId": "sub-1", "state": "Enabled"}`))
+			return
+		case r.URL.Path == "/subscriptions/sub-1/providers/Microsoft.Compute/virtualMachines" && r.URL.Query().Get("statusOnly") == "true":
+			file = "virtualMachineStates"
+		case r.URL.Path == "/subscriptions/sub-1/providers/Microsoft.Compute/virtualMachines" && r.URL.Query().Get("$skiptoken") == "page2":
+			file = "virtualMachines_page2"
+		default:
+			file = r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		}
+		data, err := ioutil.ReadFile("testdata/" + file + ".json")
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(strings.ReplaceAll(string(data), "{{server}}", server.URL)))
+	}))
+	return server
+}
+
+func newTestAzure(endpoint, secret string) *Azure {
+	return newAzure(
+		mysqlcommon.DEFAULT_ORG_ID,
+		mysqlmodel.Domain{Name: "test_azure", DisplayName: "test_azure"},
+		config.CloudConfig{HTTPTimeout: 5},
+		&Config{
+			TenantID:                "tenant-1",
+			ClientID:                "client-1",
+			ClientSecret:            secret,
+			SubscriptionID:          "sub-1",
+			LoginEndpoint:           endpoint,
+			ResourceManagerEndpoint: endpoint,
+			ExcludeRegions:          []string{"westeurope"},
+		},
+	)
+}
+
+func TestAzureCheckAuth(t *testing.T) {
+	server := newARMTestServer()
+	defer server.Close()
+
+	Convey("TestAzureCheckAuth", t, func() {
+		So(newTestAzure(server.URL, "secret").CheckAuth(), ShouldBeNil)
+		So(newTestAzure(server.URL, "wrong").CheckAuth(), ShouldNotBeNil)
+	})
+}
+
+func TestAzureGetCloudData(t *testing.T) {
+	server := newARMTestServer()
+	defer server.Close()
+	config.SetCloudGlobalConfig(config.CloudConfig{})
+	statsd.NewStatsdMonitor(statsdcfg.StatsdConfig{})
+
+	Convey("TestAzureGetCloudData", t, func() {
+		azure := newTestAzure(server.URL, "secret")
+		data, err := azure.GetCloudData()
+		So(err, ShouldBeNil)
+
+		Convey("regions and azs without resources should be eliminated", func() {
+			So(len(data.Regions), ShouldEqual, 1)
+			So(data.Regions[0].Name, ShouldEqual, "East US")
+			// default az of eastus and eastus-1 of vm1
+			So(len(data.AZs), ShouldEqual, 2)
+		})
+
+		Convey("vnets should be mapped to vpcs, vrouters, networks and subnets", func() {
+			So(len(data.VPCs), ShouldEqual, 2)
+			So(data.VPCs[0].CIDR, ShouldEqual, "10.0.0.0/16")
+			So(len(data.VRouters), ShouldEqual, 2)
+			So(len(data.Networks), ShouldEqual, 3)
+			So(len(data.Subnets), ShouldEqual, 4)
+		})
+
+		Convey("peerings of both sides should be merged, peering to other subscription should be ignored", func() {
+			So(len(data.PeerConnections), ShouldEqual, 1)
+			So(data.PeerConnections[0].LocalVPCLcuuid, ShouldEqual, data.VPCs[0].Lcuuid)
+			So(data.PeerConnections[0].RemoteVPCLcuuid, ShouldEqual, data.VPCs[1].Lcuuid)
+		})
+
+		Convey("vms of all pages should be synced", func() {
+			So(len(data.VMs), ShouldEqual, 2)
+			vm1, vm2 := data.VMs[0], data.VMs[1]
+			So(vm1.State, ShouldEqual, common.VM_STATE_RUNNING)
+			So(vm1.IP, ShouldEqual, "10.0.0.4")
+			So(vm1.Hostname, ShouldEqual, "vm1-host")
+			So(vm1.VPCLcuuid, ShouldEqual, data.VPCs[0].Lcuuid)
+			So(vm1.CloudTags["env"], ShouldEqual, "prod")
+			So(vm1.AZLcuuid, ShouldNotEqual, vm2.AZLcuuid)
+			So(vm2.State, ShouldEqual, common.VM_STATE_STOPPED)
+		})
+
+		Convey("nics of vms should be synced and public ips should be floating ips", func() {
+			vmVIFs := 0
+			for _, vif := range data.VInterfaces {
+				if vif.DeviceType == common.VIF_DEVICE_TYPE_VM {
+					vmVIFs++
+					// resource group in vm id of nic-vm1 is in upper case
+					So(vif.DeviceLcuuid, ShouldBeIn, []string{data.VMs[0].Lcuuid, data.VMs[1].Lcuuid})
+				}
+			}
+			So(vmVIFs, ShouldEqual, 2)
+			So(len(data.FloatingIPs), ShouldEqual, 1)
+			So(data.FloatingIPs[0].IP, ShouldEqual, "20.1.1.1")
+			So(data.FloatingIPs[0].VMLcuuid, ShouldEqual, data.VMs[0].Lcuuid)
+		})
+
+		Convey("nat gateways not associated with subnet should be ignored", func() {
+			So(len(data.NATGateways), ShouldEqual, 1)
+			So(data.NATGateways[0].FloatingIPs, ShouldEqual, "20.1.1.2")
+			So(data.NATGateways[0].VPCLcuuid, ShouldEqual, data.VPCs[0].Lcuuid)
+		})
+
+		Convey("lbs should be synced with listeners and target servers", func() {
+			So(len(data.LBs), ShouldEqual, 2)
+			So(data.LBs[0].VIP, ShouldEqual, "20.1.1.3")
+			So(data.LBs[0].VPCLcuuid, ShouldEqual, data.VPCs[0].Lcuuid)
+			So(data.LBs[1].VIP, ShouldEqual, "10.0.1.10")
+			So(len(data.LBListeners), ShouldEqual, 2)
+			So(data.LBListeners[0].Protocol, ShouldEqual, "TCP")
+			So(data.LBListeners[0].Port, ShouldEqual, 80)
+
+			So(len(data.LBTargetServers), ShouldEqual, 3)
+			for _, ts := range data.LBTargetServers[:2] {
+				So(ts.Type, ShouldEqual, common.LB_SERVER_TYPE_VM)
+				So(ts.Port, ShouldEqual, 8080)
+			}
+			So(data.LBTargetServers[0].VMLcuuid, ShouldEqual, data.VMs[0].Lcuuid)
+			So(data.LBTargetServers[2].Type, ShouldEqual, common.LB_SERVER_TYPE_IP)
+			So(data.LBTargetServers[2].IP, ShouldEqual, "10.1.0.8")
+		})
+	})
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bitly/go-simplejson"
+)
+
+func newErr(url, msg string) error {
+	return errors.New(fmt.Sprintf("request url: %s, %s", url, msg))
+}
+
+func doRequest(req *http.Request, timeout time.Duration) (*simplejson.Json, error) {
+	client := &http.Client{Timeout: time.Second * timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, newErr(req.URL.String(), fmt.Sprintf("failed: %s", err.Error()))
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, newErr(req.URL.String(), fmt.Sprintf("read failed: %s", err.Error()))
+	}
+	if resp.StatusCode != http.StatusOK {
+		// azure returns error details in body, e.g.: {"error": {"code": "AuthorizationFailed", "message": "..."}}
+		return nil, newErr(req.URL.String(), fmt.Sprintf("failed: status (%d), body (%s)", resp.StatusCode, string(respBody)))
+	}
+	jsonResp, err := simplejson.NewJson(respBody)
+	if err != nil {
+		return nil, newErr(req.URL.String(), fmt.Sprintf("JSONiz failed: %s", err.Error()))
+	}
+	return jsonResp, nil
+}
+
+func requestGet(reqURL, token string, timeout time.Duration) (*simplejson.Json, error) {
+	log.Debugf("url: %s", reqURL)
+	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, newErr(reqURL, fmt.Sprintf("new request failed: %s", err.Error()))
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	return doRequest(req, timeout)
+}
+
+func requestPostForm(reqURL string, form url.Values, timeout time.Duration) (*simplejson.Json, error) {
+	log.Debugf("url: %s", reqURL)
+	req, err := http.NewRequest(http.MethodPost, reqURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, newErr(reqURL, fmt.Sprintf("new request failed: %s", err.Error()))
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	return doRequest(req, timeout)
+}
@@ -0,0 +1,272 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bitly/go-simplejson"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// 负载均衡器的前端 IP
+type lbFrontend struct {
+	ip            string
+	public        bool
+	subnetID      string
+	networkLcuuid string
+}
+
+func (a *Azure) getLBs() (
+	lbs []model.LB, lbListeners []model.LBListener, lbTargetServers []model.LBTargetServer, vifs []model.VInterface, ips []model.IP, err error,
+) {
+	jLBs, err := a.getRawData("loadBalancers", "providers/Microsoft.Network/loadBalancers?api-version="+API_VERSION_NETWORK)
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+
+	for i := range jLBs {
+		jLB := jLBs[i]
+		name := jLB.Get("name").MustString()
+		if !cloudcommon.CheckJsonAttributes(jLB, []string{"id", "name", "location", "properties"}) {
+			log.Infof("exclude lb: %s, missing attr", name, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		regionLcuuid, ok := a.getRegionLcuuid(jLB.Get("location").MustString())
+		if !ok {
+			continue
+		}
+		properties := jLB.Get("properties")
+		frontends := a.formatLBFrontends(properties.Get("frontendIPConfigurations"))
+		vpcLcuuid := a.getLBVPCLcuuid(frontends, properties.Get("backendAddressPools"))
+		if vpcLcuuid == "" {
+			log.Infof("exclude lb: %s, vpc not found", name, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+
+		lbLcuuid := a.generateLcuuid(jLB.Get("id").MustString())
+		lbModel := cloudcommon.LB_MODEL_INTERNAL
+		var vips []string
+		for _, frontend := range frontends {
+			if frontend.public {
+				lbModel = cloudcommon.LB_MODEL_EXTERNAL
+			}
+			vips = append(vips, frontend.ip)
+		}
+		sort.Strings(vips)
+		lbs = append(lbs, model.LB{
+			Lcuuid:       lbLcuuid,
+			Name:         name,
+			Label:        properties.Get("resourceGuid").MustString(),
+			Model:        lbModel,
+			VIP:          strings.Join(vips, ","),
+			VPCLcuuid:    vpcLcuuid,
+			RegionLcuuid: regionLcuuid,
+		})
+		a.toolDataSet.regionLcuuidToResourceNum[regionLcuuid]++
+
+		lbVIFs, lbIPs := a.formatLBVInterfaces(lbLcuuid, vpcLcuuid, regionLcuuid, frontends)
+		vifs = append(vifs, lbVIFs...)
+		ips = append(ips, lbIPs...)
+
+		listeners, targetServers := a.formatLBListeners(lbLcuuid, vpcLcuuid, properties, frontends)
+		lbListeners = append(lbListeners, listeners...)
+		lbTargetServers = append(lbTargetServers, targetServers...)
+	}
+	return
+}
+
+// formatLBFrontends 返回前端 IP 配置 ID 到前端 IP 的映射，公网负载均衡器的前端 IP 为公网 IP
+func (a *Azure) formatLBFrontends(jFrontends *simplejson.Json) map[string]lbFrontend {
+	frontends := map[string]lbFrontend{}
+	for i := range jFrontends.MustArray() {
+		jf := jFrontends.GetIndex(i)
+		jp := jf.Get("properties")
+		var frontend lbFrontend
+		if publicIP, ok := a.getPublicIP(jp.Get("publicIPAddress").Get("id").MustString()); ok {
+			frontend = lbFrontend{ip: publicIP, public: true, networkLcuuid: common.NETWORK_ISP_LCUUID}
+		} else {
+			ip := jp.Get("privateIPAddress").MustString()
+			subnetID := jp.Get("subnet").Get("id").MustString()
+			network, ok := a.toolDataSet.subnetIDToNetwork[strings.ToLower(subnetID)]
+			if ip == "" || !ok {
+				continue
+			}
+			frontend = lbFrontend{ip: ip, subnetID: subnetID, networkLcuuid: network.Lcuuid}
+		}
+		frontends[strings.ToLower(jf.Get("id").MustString())] = frontend
+	}
+	return frontends
+}
+
+// getLBVPCLcuuid 内网负载均衡器取前端子网所属的 VPC，公网负载均衡器取后端网卡所属的 VPC
+func (a *Azure) getLBVPCLcuuid(frontends map[string]lbFrontend, jPools *simplejson.Json) string {
+	for _, frontend := range frontends {
+		if network, ok := a.toolDataSet.subnetIDToNetwork[strings.ToLower(frontend.subnetID)]; ok {
+			return network.VPCLcuuid
+		}
+	}
+	for i := range jPools.MustArray() {
+		jp := jPools.GetIndex(i).Get("properties")
+		jConfigs := jp.Get("backendIPConfigurations")
+		for j := range jConfigs.MustArray() {
+			if nIP, ok := a.toolDataSet.ipConfigIDToNICIP[strings.ToLower(jConfigs.GetIndex(j).Get("id").MustString())]; ok {
+				return nIP.vpcLcuuid
+			}
+		}
+		jAddresses := jp.Get("loadBalancerBackendAddresses")
+		for j := range jAddresses.MustArray() {
+			vnetID := jAddresses.GetIndex(j).Get("properties").Get("virtualNetwork").Get("id").MustString()
+			if _, ok := a.toolDataSet.vnetIDToRegionLcuuid[strings.ToLower(vnetID)]; ok {
+				return a.generateLcuuid(vnetID)
+			}
+		}
+	}
+	return ""
+}
+
+func (a *Azure) formatLBVInterfaces(lbLcuuid, vpcLcuuid, regionLcuuid string, frontends map[string]lbFrontend) (vifs []model.VInterface, ips []model.IP) {
+	// 同一网络中的前端 IP 共用一个网卡
+	networkToVIFLcuuid := map[string]string{}
+	for _, frontend := range frontends {
+		vifLcuuid, ok := networkToVIFLcuuid[frontend.networkLcuuid]
+		if !ok {
+			vifLcuuid = common.GenerateUUIDByOrgID(a.orgID, lbLcuuid+frontend.networkLcuuid)
+			networkToVIFLcuuid[frontend.networkLcuuid] = vifLcuuid
+			vifType := common.VIF_TYPE_LAN
+			if frontend.public {
+				vifType = common.VIF_TYPE_WAN
+			}
+			vifs = append(vifs, model.VInterface{
+				Lcuuid:        vifLcuuid,
+				Type:          vifType,
+				Mac:           common.VIF_DEFAULT_MAC,
+				DeviceType:    common.VIF_DEVICE_TYPE_LB,
+				DeviceLcuuid:  lbLcuuid,
+				NetworkLcuuid: frontend.networkLcuuid,
+				VPCLcuuid:     vpcLcuuid,
+				RegionLcuuid:  regionLcuuid,
+			})
+		}
+		ip := model.IP{
+			Lcuuid:           common.GenerateUUIDByOrgID(a.orgID, vifLcuuid+frontend.ip),
+			VInterfaceLcuuid: vifLcuuid,
+			IP:               frontend.ip,
+			RegionLcuuid:     regionLcuuid,
+		}
+		if !frontend.public {
+			ip.SubnetLcuuid = a.getSubnetLcuuid(frontend.subnetID, frontend.ip)
+		}
+		ips = append(ips, ip)
+	}
+	return
+}
+
+// formatLBListeners 将负载均衡规则映射为监听器，规则关联的后端池中的网卡或 IP 映射为后端主机
+func (a *Azure) formatLBListeners(lbLcuuid, vpcLcuuid string, properties *simplejson.Json, frontends map[string]lbFrontend) (
+	listeners []model.LBListener, targetServers []model.LBTargetServer,
+) {
+	poolIDToPool := map[string]*simplejson.Json{}
+	jPools := properties.Get("backendAddressPools")
+	for i := range jPools.MustArray() {
+		poolIDToPool[strings.ToLower(jPools.GetIndex(i).Get("id").MustString())] = jPools.GetIndex(i)
+	}
+
+	jRules := properties.Get("loadBalancingRules")
+	for i := range jRules.MustArray() {
+		jr := jRules.GetIndex(i)
+		name := jr.Get("name").MustString()
+		jrp := jr.Get("properties")
+		frontend, ok := frontends[strings.ToLower(jrp.Get("frontendIPConfiguration").Get("id").MustString())]
+		if !ok {
+			log.Infof("exclude lb listener: %s, missing frontend ip", name, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		protocol := strings.ToUpper(jrp.Get("protocol").MustString())
+		port := jrp.Get("frontendPort").MustInt()
+		listenerLcuuid := a.generateLcuuid(jr.Get("id").MustString())
+		listeners = append(listeners, model.LBListener{
+			Lcuuid:   listenerLcuuid,
+			LBLcuuid: lbLcuuid,
+			Name:     name,
+			Label:    protocol + ":" + strconv.Itoa(port),
+			IPs:      frontend.ip,
+			Protocol: protocol,
+			Port:     port,
+		})
+
+		// 规则的后端端口为 0 时表示 HA 端口规则，后端端口与前端端口一致
+		backendPort := jrp.Get("backendPort").MustInt()
+		if backendPort == 0 {
+			backendPort = port
+		}
+		jPool, ok := poolIDToPool[strings.ToLower(jrp.Get("backendAddressPool").Get("id").MustString())]
+		if !ok {
+			continue
+		}
+		jConfigs := jPool.Get("properties").Get("backendIPConfigurations")
+		for j := range jConfigs.MustArray() {
+			configID := strings.ToLower(jConfigs.GetIndex(j).Get("id").MustString())
+			nIP, ok := a.toolDataSet.ipConfigIDToNICIP[configID]
+			if !ok {
+				log.Infof("lb target server (%s) ip not found", configID, logger.NewORGPrefix(a.orgID))
+				continue
+			}
+			targetServers = append(targetServers, model.LBTargetServer{
+				Lcuuid:           common.GenerateUUIDByOrgID(a.orgID, listenerLcuuid+configID),
+				LBLcuuid:         lbLcuuid,
+				LBListenerLcuuid: listenerLcuuid,
+				Type:             common.LB_SERVER_TYPE_VM,
+				IP:               nIP.ip,
+				VMLcuuid:         nIP.vmLcuuid,
+				Protocol:         protocol,
+				Port:             backendPort,
+				VPCLcuuid:        vpcLcuuid,
+			})
+		}
+		// 基于 IP 地址配置的后端池
+		jAddresses := jPool.Get("properties").Get("loadBalancerBackendAddresses")
+		for j := range jAddresses.MustArray() {
+			jap := jAddresses.GetIndex(j).Get("properties")
+			// 基于网卡配置的后端池也会在此返回，已在 backendIPConfigurations 中处理
+			if _, ok := jap.CheckGet("networkInterfaceIPConfiguration"); ok {
+				continue
+			}
+			ip := jap.Get("ipAddress").MustString()
+			if ip == "" {
+				continue
+			}
+			targetServers = append(targetServers, model.LBTargetServer{
+				Lcuuid:           common.GenerateUUIDByOrgID(a.orgID, listenerLcuuid+ip),
+				LBLcuuid:         lbLcuuid,
+				LBListenerLcuuid: listenerLcuuid,
+				Type:             common.LB_SERVER_TYPE_IP,
+				IP:               ip,
+				Protocol:         protocol,
+				Port:             backendPort,
+				VPCLcuuid:        vpcLcuuid,
+			})
+		}
+	}
+	return
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"strings"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+func (a *Azure) getNATGateways() (natGateways []model.NATGateway, vifs []model.VInterface, ips []model.IP, err error) {
+	jNATGateways, err := a.getRawData("natGateways", "providers/Microsoft.Network/natGateways?api-version="+API_VERSION_NETWORK)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	for i := range jNATGateways {
+		jNAT := jNATGateways[i]
+		name := jNAT.Get("name").MustString()
+		if !cloudcommon.CheckJsonAttributes(jNAT, []string{"id", "name", "location", "properties"}) {
+			log.Infof("exclude nat gateway: %s, missing attr", name, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		regionLcuuid, ok := a.getRegionLcuuid(jNAT.Get("location").MustString())
+		if !ok {
+			continue
+		}
+		properties := jNAT.Get("properties")
+		// NAT 网关本身不属于 VNet，通过关联的子网确定所属 VPC
+		jSubnets := properties.Get("subnets")
+		if len(jSubnets.MustArray()) == 0 {
+			log.Infof("exclude nat gateway: %s, not associated with subnet", name, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		vnetID := parentID(jSubnets.GetIndex(0).Get("id").MustString())
+		if _, ok := a.toolDataSet.vnetIDToRegionLcuuid[strings.ToLower(vnetID)]; !ok {
+			log.Infof("exclude nat gateway: %s, vpc not found", name, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		vpcLcuuid := a.generateLcuuid(vnetID)
+
+		var floatingIPs []string
+		jPublicIPs := properties.Get("publicIpAddresses")
+		for j := range jPublicIPs.MustArray() {
+			if ip, ok := a.getPublicIP(jPublicIPs.GetIndex(j).Get("id").MustString()); ok {
+				floatingIPs = append(floatingIPs, ip)
+			}
+		}
+		natGatewayLcuuid := a.generateLcuuid(jNAT.Get("id").MustString())
+		natGateways = append(natGateways, model.NATGateway{
+			Lcuuid:       natGatewayLcuuid,
+			Name:         name,
+			Label:        properties.Get("resourceGuid").MustString(),
+			FloatingIPs:  strings.Join(floatingIPs, ","),
+			VPCLcuuid:    vpcLcuuid,
+			RegionLcuuid: regionLcuuid,
+		})
+		a.toolDataSet.regionLcuuidToResourceNum[regionLcuuid]++
+
+		vifLcuuid := common.GenerateUUIDByOrgID(a.orgID, natGatewayLcuuid)
+		vifs = append(vifs, model.VInterface{
+			Lcuuid:        vifLcuuid,
+			Type:          common.VIF_TYPE_WAN,
+			Mac:           common.VIF_DEFAULT_MAC,
+			DeviceType:    common.VIF_DEVICE_TYPE_NAT_GATEWAY,
+			DeviceLcuuid:  natGatewayLcuuid,
+			NetworkLcuuid: common.NETWORK_ISP_LCUUID,
+			VPCLcuuid:     vpcLcuuid,
+			RegionLcuuid:  regionLcuuid,
+		})
+		for _, ip := range floatingIPs {
+			ips = append(ips, model.IP{
+				Lcuuid:           common.GenerateUUIDByOrgID(a.orgID, vifLcuuid+ip),
+				VInterfaceLcuuid: vifLcuuid,
+				IP:               ip,
+				RegionLcuuid:     regionLcuuid,
+			})
+		}
+	}
+	return
+}
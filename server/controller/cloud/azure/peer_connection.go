@@ -0,0 +1,69 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+func (a *Azure) getPeerConnections() ([]model.PeerConnection, error) {
+	var peerConnections []model.PeerConnection
+	added := map[string]bool{}
+	for _, p := range a.toolDataSet.vnetPeerings {
+		name := p.jPeering.Get("name").MustString()
+		properties := p.jPeering.Get("properties")
+		if state := properties.Get("peeringState").MustString(); state != "Connected" {
+			log.Infof("exclude peer connection: %s, state (%s) invalid", name, state, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		localID := strings.ToLower(p.localVNetID)
+		remoteID := strings.ToLower(properties.Get("remoteVirtualNetwork").Get("id").MustString())
+		localRegionLcuuid, ok := a.toolDataSet.vnetIDToRegionLcuuid[localID]
+		if !ok {
+			continue
+		}
+		// 对端 VNet 属于其他订阅或不在同步区域内时无法关联 VPC
+		remoteRegionLcuuid, ok := a.toolDataSet.vnetIDToRegionLcuuid[remoteID]
+		if !ok {
+			log.Infof("exclude peer connection: %s, remote vnet (%s) not found", name, remoteID, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		// 两端 VNet 中的对等连接记录只保留一条
+		pair := []string{localID, remoteID}
+		sort.Strings(pair)
+		key := strings.Join(pair, ",")
+		if added[key] {
+			continue
+		}
+		added[key] = true
+		peerConnections = append(peerConnections, model.PeerConnection{
+			Lcuuid:             a.generateLcuuid(key),
+			Name:               name,
+			Label:              p.jPeering.Get("id").MustString(),
+			LocalVPCLcuuid:     a.generateLcuuid(localID),
+			RemoteVPCLcuuid:    a.generateLcuuid(remoteID),
+			LocalRegionLcuuid:  localRegionLcuuid,
+			RemoteRegionLcuuid: remoteRegionLcuuid,
+		})
+		a.toolDataSet.regionLcuuidToResourceNum[localRegionLcuuid]++
+	}
+	return peerConnections, nil
+}
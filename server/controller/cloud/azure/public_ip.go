@@ -0,0 +1,44 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"strings"
+)
+
+// getPublicIPs 获取公网 IP 的地址，供网卡、NAT 网关、负载均衡器使用
+func (a *Azure) getPublicIPs() error {
+	jPublicIPs, err := a.getRawData("publicIPAddresses", "providers/Microsoft.Network/publicIPAddresses?api-version="+API_VERSION_NETWORK)
+	if err != nil {
+		return err
+	}
+	for i := range jPublicIPs {
+		jp := jPublicIPs[i]
+		ip := jp.Get("properties").Get("ipAddress").MustString()
+		if ip == "" {
+			// 动态分配的公网 IP 在未绑定资源时没有地址
+			continue
+		}
+		a.toolDataSet.publicIPIDToIP[strings.ToLower(jp.Get("id").MustString())] = ip
+	}
+	return nil
+}
+
+func (a *Azure) getPublicIP(publicIPID string) (string, bool) {
+	ip, ok := a.toolDataSet.publicIPIDToIP[strings.ToLower(publicIPID)]
+	return ip, ok
+}
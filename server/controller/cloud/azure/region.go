@@ -0,0 +1,94 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"strings"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+func (a *Azure) getRegions() ([]model.Region, error) {
+	jRegions, err := a.getRawData("locations", "locations?api-version="+API_VERSION_SUBSCRIPTION)
+	if err != nil {
+		return nil, err
+	}
+
+	var regions []model.Region
+	for i := range jRegions {
+		jr := jRegions[i]
+		if !cloudcommon.CheckJsonAttributes(jr, []string{"name"}) {
+			continue
+		}
+		name := jr.Get("name").MustString()
+		if len(a.config.IncludeRegions) > 0 && !common.Contains(a.config.IncludeRegions, name) {
+			log.Infof("exclude region: %s, not included", name, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		if common.Contains(a.config.ExcludeRegions, name) {
+			log.Infof("exclude region: %s", name, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		displayName := jr.Get("displayName").MustString()
+		if displayName == "" {
+			displayName = name
+		}
+		region := model.Region{
+			Lcuuid: common.GenerateUUIDByOrgID(a.orgID, name+"_"+a.lcuuidGenerate),
+			Label:  name,
+			Name:   displayName,
+		}
+		regions = append(regions, region)
+		a.toolDataSet.regionNameToLcuuid[name] = region.Lcuuid
+	}
+	return regions, nil
+}
+
+// getRegionLcuuid 返回资源 location 对应的区域，location 不在同步范围内时返回 false
+func (a *Azure) getRegionLcuuid(location string) (string, bool) {
+	regionLcuuid, ok := a.toolDataSet.regionNameToLcuuid[strings.ToLower(location)]
+	if !ok {
+		return "", false
+	}
+	if a.config.RegionLcuuid != "" {
+		return a.config.RegionLcuuid, true
+	}
+	return regionLcuuid, true
+}
+
+// getAZLcuuid 返回资源所在的可用区，azure 中 VNet、子网等资源跨可用区，
+// 未部署在可用区中的资源都归属到以区域命名的默认可用区
+func (a *Azure) getAZLcuuid(location, zone, regionLcuuid string) string {
+	name := strings.ToLower(location)
+	if zone != "" {
+		name += "-" + zone
+	}
+	lcuuid := common.GenerateUUIDByOrgID(a.orgID, name+"_az_"+a.lcuuidGenerate)
+	if !a.toolDataSet.azLcuuids[lcuuid] {
+		a.toolDataSet.azLcuuids[lcuuid] = true
+		a.toolDataSet.azs = append(a.toolDataSet.azs, model.AZ{
+			Lcuuid:       lcuuid,
+			Label:        name,
+			Name:         name,
+			RegionLcuuid: regionLcuuid,
+		})
+	}
+	return lcuuid
+}
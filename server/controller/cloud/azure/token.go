@@ -0,0 +1,74 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+type Token struct {
+	token     string
+	expiresAt time.Time
+}
+
+// 离失效时间小于5m时认为token已过期，需要重新申请
+func (t *Token) isExpired() bool {
+	return time.Now().Add(5 * time.Minute).After(t.expiresAt)
+}
+
+func (a *Azure) getToken() (string, error) {
+	if a.token == nil || a.token.isExpired() {
+		token, err := a.createToken()
+		if err != nil {
+			return "", err
+		}
+		a.token = token
+	}
+	return a.token.token, nil
+}
+
+// createToken 使用服务主体 (service principal) 的 client credentials 申请访问 ARM 的 token
+func (a *Azure) createToken() (*Token, error) {
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", a.config.ClientID)
+	form.Set("client_secret", a.config.ClientSecret)
+	form.Set("scope", a.config.ResourceManagerEndpoint+"/.default")
+	resp, err := requestPostForm(
+		fmt.Sprintf("%s/%s/oauth2/v2.0/token", a.config.LoginEndpoint, a.config.TenantID), form, time.Duration(a.httpTimeout),
+	)
+	if err != nil {
+		log.Errorf("create token failed: %s", err.Error(), logger.NewORGPrefix(a.orgID))
+		return nil, err
+	}
+	token := resp.Get("access_token").MustString()
+	if token == "" {
+		return nil, errors.New("create token failed: access_token not found in response")
+	}
+	// expires_in is a number in v2.0 endpoint, but a string in some sovereign clouds
+	expiresIn, err := resp.Get("expires_in").Int64()
+	if err != nil {
+		expiresIn, _ = strconv.ParseInt(resp.Get("expires_in").MustString(), 10, 64)
+	}
+	return &Token{token: token, expiresAt: time.Now().Add(time.Duration(expiresIn) * time.Second)}, nil
+}
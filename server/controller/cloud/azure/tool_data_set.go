@@ -0,0 +1,69 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"github.com/bitly/go-simplejson"
+
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+)
+
+// 以下 map 的 key 均为小写的 azure 资源 ID
+type ToolDataSet struct {
+	regionNameToLcuuid   map[string]string
+	azs                  []model.AZ
+	azLcuuids            map[string]bool
+	vnetIDToRegionLcuuid map[string]string
+	vnetPeerings         []vnetPeering
+	subnetIDToNetwork    map[string]model.Network
+	subnetIDToSubnets    map[string][]model.Subnet
+	publicIPIDToIP       map[string]string
+	vmIDToPrimaryNICIP   map[string]nicIP
+	ipConfigIDToNICIP    map[string]nicIP
+
+	regionLcuuidToResourceNum map[string]int
+	azLcuuidToResourceNum     map[string]int
+}
+
+// VNet 对等连接在两端的 VNet 中各有一条记录
+type vnetPeering struct {
+	localVNetID string
+	jPeering    *simplejson.Json
+}
+
+// 网卡 ipConfiguration 的信息，供负载均衡器后端、公网 IP 使用
+type nicIP struct {
+	vinterfaceLcuuid string
+	vmLcuuid         string
+	vpcLcuuid        string
+	ip               string
+}
+
+func NewToolDataSet() *ToolDataSet {
+	return &ToolDataSet{
+		regionNameToLcuuid:        make(map[string]string),
+		azLcuuids:                 make(map[string]bool),
+		vnetIDToRegionLcuuid:      make(map[string]string),
+		subnetIDToNetwork:         make(map[string]model.Network),
+		subnetIDToSubnets:         make(map[string][]model.Subnet),
+		publicIPIDToIP:            make(map[string]string),
+		vmIDToPrimaryNICIP:        make(map[string]nicIP),
+		ipConfigIDToNICIP:         make(map[string]nicIP),
+		regionLcuuidToResourceNum: make(map[string]int),
+		azLcuuidToResourceNum:     make(map[string]int),
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"strings"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// getVInterfaces 获取云主机的网卡及其 IP，网卡绑定的公网 IP 映射为浮动 IP
+func (a *Azure) getVInterfaces() (vifs []model.VInterface, ips []model.IP, floatingIPs []model.FloatingIP, err error) {
+	jNICs, err := a.getRawData("networkInterfaces", "providers/Microsoft.Network/networkInterfaces?api-version="+API_VERSION_NETWORK)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	for i := range jNICs {
+		jn := jNICs[i]
+		name := jn.Get("name").MustString()
+		if !cloudcommon.CheckJsonAttributes(jn, []string{"id", "name", "location", "properties"}) {
+			log.Infof("exclude vinterface: %s, missing attr", name, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		properties := jn.Get("properties")
+		// 只同步云主机的网卡，private endpoint 等资源的网卡不关联设备
+		vmID := properties.Get("virtualMachine").Get("id").MustString()
+		if vmID == "" {
+			log.Debugf("exclude vinterface: %s, not attached to vm", name, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		regionLcuuid, ok := a.getRegionLcuuid(jn.Get("location").MustString())
+		if !ok {
+			continue
+		}
+		jIPConfigs := properties.Get("ipConfigurations")
+		if len(jIPConfigs.MustArray()) == 0 {
+			continue
+		}
+		// 网卡的所有 ipConfiguration 都属于同一个 VNet，网卡所属网络取第一个 ipConfiguration 的子网
+		network, ok := a.toolDataSet.subnetIDToNetwork[strings.ToLower(jIPConfigs.GetIndex(0).Get("properties").Get("subnet").Get("id").MustString())]
+		if !ok {
+			log.Infof("exclude vinterface: %s, missing network info", name, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+
+		vifLcuuid := a.generateLcuuid(jn.Get("id").MustString())
+		vmLcuuid := a.generateLcuuid(vmID)
+		vifs = append(vifs, model.VInterface{
+			Lcuuid:        vifLcuuid,
+			Name:          name,
+			Type:          common.VIF_TYPE_LAN,
+			Mac:           formatMac(properties.Get("macAddress").MustString()),
+			DeviceType:    common.VIF_DEVICE_TYPE_VM,
+			DeviceLcuuid:  vmLcuuid,
+			NetworkLcuuid: network.Lcuuid,
+			VPCLcuuid:     network.VPCLcuuid,
+			RegionLcuuid:  regionLcuuid,
+		})
+
+		for j := range jIPConfigs.MustArray() {
+			jc := jIPConfigs.GetIndex(j)
+			ip := jc.Get("properties").Get("privateIPAddress").MustString()
+			if ip == "" {
+				continue
+			}
+			ips = append(ips, model.IP{
+				Lcuuid:           common.GenerateUUIDByOrgID(a.orgID, vifLcuuid+ip),
+				VInterfaceLcuuid: vifLcuuid,
+				IP:               ip,
+				SubnetLcuuid:     a.getSubnetLcuuid(jc.Get("properties").Get("subnet").Get("id").MustString(), ip),
+				RegionLcuuid:     regionLcuuid,
+			})
+			nIP := nicIP{vinterfaceLcuuid: vifLcuuid, vmLcuuid: vmLcuuid, vpcLcuuid: network.VPCLcuuid, ip: ip}
+			a.toolDataSet.ipConfigIDToNICIP[strings.ToLower(jc.Get("id").MustString())] = nIP
+			if properties.Get("primary").MustBool() && jc.Get("properties").Get("primary").MustBool() {
+				a.toolDataSet.vmIDToPrimaryNICIP[strings.ToLower(vmID)] = nIP
+			} else if _, ok := a.toolDataSet.vmIDToPrimaryNICIP[strings.ToLower(vmID)]; !ok {
+				a.toolDataSet.vmIDToPrimaryNICIP[strings.ToLower(vmID)] = nIP
+			}
+
+			publicIP, ok := a.getPublicIP(jc.Get("properties").Get("publicIPAddress").Get("id").MustString())
+			if !ok {
+				continue
+			}
+			floatingIPs = append(floatingIPs, model.FloatingIP{
+				Lcuuid:        common.GenerateUUIDByOrgID(a.orgID, vifLcuuid+publicIP),
+				IP:            publicIP,
+				VMLcuuid:      vmLcuuid,
+				NetworkLcuuid: common.NETWORK_ISP_LCUUID,
+				VPCLcuuid:     network.VPCLcuuid,
+				RegionLcuuid:  regionLcuuid,
+			})
+		}
+	}
+	return
+}
+
+// azure 返回的 mac 地址格式为 00-0D-3A-1B-2C-3D
+func formatMac(mac string) string {
+	if mac == "" {
+		return common.VIF_DEFAULT_MAC
+	}
+	return strings.ToLower(strings.ReplaceAll(mac, "-", ":"))
+}
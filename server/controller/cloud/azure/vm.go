@@ -0,0 +1,127 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"strings"
+	"time"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+func (a *Azure) getVMs() ([]model.VM, error) {
+	var vms []model.VM
+	jVMs, err := a.getRawData("virtualMachines", "providers/Microsoft.Compute/virtualMachines?api-version="+API_VERSION_COMPUTE)
+	if err != nil {
+		return nil, err
+	}
+	vmIDToState, err := a.getVMStates()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range jVMs {
+		jVM := jVMs[i]
+		name := jVM.Get("name").MustString()
+		if !cloudcommon.CheckJsonAttributes(jVM, []string{"id", "name", "location", "properties"}) {
+			log.Infof("exclude vm: %s, missing attr", name, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		regionLcuuid, ok := a.getRegionLcuuid(jVM.Get("location").MustString())
+		if !ok {
+			log.Infof("exclude vm: %s, region not included", name, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		id := strings.ToLower(jVM.Get("id").MustString())
+		nIP, ok := a.toolDataSet.vmIDToPrimaryNICIP[id]
+		if !ok {
+			log.Infof("exclude vm: %s, missing vinterface info", name, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		var zone string
+		if zones := jVM.Get("zones").MustStringArray(); len(zones) > 0 {
+			zone = zones[0]
+		}
+		azLcuuid := a.getAZLcuuid(jVM.Get("location").MustString(), zone, regionLcuuid)
+		state, ok := vmIDToState[id]
+		if !ok {
+			state = common.VM_STATE_EXCEPTION
+		}
+		properties := jVM.Get("properties")
+		createdAt, _ := time.Parse(time.RFC3339, properties.Get("timeCreated").MustString())
+		vms = append(vms, model.VM{
+			Lcuuid:       a.generateLcuuid(id),
+			Name:         name,
+			Label:        properties.Get("vmId").MustString(),
+			IP:           nIP.ip,
+			Hostname:     properties.Get("osProfile").Get("computerName").MustString(),
+			HType:        common.VM_HTYPE_VM_C,
+			State:        state,
+			CreatedAt:    createdAt,
+			VPCLcuuid:    nIP.vpcLcuuid,
+			AZLcuuid:     azLcuuid,
+			RegionLcuuid: regionLcuuid,
+			CloudTags:    a.formatCloudTags(jVM.Get("tags").MustMap()),
+		})
+		a.toolDataSet.azLcuuidToResourceNum[azLcuuid]++
+		a.toolDataSet.regionLcuuidToResourceNum[regionLcuuid]++
+	}
+	return vms, nil
+}
+
+// getVMStates 获取云主机的运行状态，运行状态只在 instanceView 中返回，
+// 订阅维度的列表接口需要通过 statusOnly=true 获取 instanceView
+func (a *Azure) getVMStates() (map[string]int, error) {
+	states := map[string]int{
+		"PowerState/running":     common.VM_STATE_RUNNING,
+		"PowerState/stopped":     common.VM_STATE_STOPPED,
+		"PowerState/deallocated": common.VM_STATE_STOPPED,
+	}
+	jVMs, err := a.getRawData("virtualMachineStates", "providers/Microsoft.Compute/virtualMachines?api-version="+API_VERSION_COMPUTE+"&statusOnly=true")
+	if err != nil {
+		return nil, err
+	}
+
+	vmIDToState := map[string]int{}
+	for i := range jVMs {
+		jStatuses := jVMs[i].Get("properties").Get("instanceView").Get("statuses")
+		for j := range jStatuses.MustArray() {
+			code := jStatuses.GetIndex(j).Get("code").MustString()
+			if !strings.HasPrefix(code, "PowerState/") {
+				continue
+			}
+			state, ok := states[code]
+			if !ok {
+				state = common.VM_STATE_EXCEPTION
+			}
+			vmIDToState[strings.ToLower(jVMs[i].Get("id").MustString())] = state
+		}
+	}
+	return vmIDToState, nil
+}
+
+func (a *Azure) formatCloudTags(tags map[string]interface{}) map[string]string {
+	cloudTags := map[string]string{}
+	for key, value := range tags {
+		v, _ := value.(string)
+		cloudTags[key] = v
+	}
+	return cloudTags
+}
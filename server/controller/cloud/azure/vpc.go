@@ -0,0 +1,160 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package azure
+
+import (
+	"strings"
+
+	"github.com/bitly/go-simplejson"
+
+	cloudcommon "github.com/deepflowio/deepflow/server/controller/cloud/common"
+	"github.com/deepflowio/deepflow/server/controller/cloud/model"
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// getVPCs 将 VNet 映射为 VPC 和 VRouter，VNet 中的子网映射为 Network 和 Subnet
+func (a *Azure) getVPCs() (
+	vpcs []model.VPC, vrouters []model.VRouter, networks []model.Network, subnets []model.Subnet, vifs []model.VInterface, err error,
+) {
+	jVNets, err := a.getRawData("virtualNetworks", "providers/Microsoft.Network/virtualNetworks?api-version="+API_VERSION_NETWORK)
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+
+	for i := range jVNets {
+		jv := jVNets[i]
+		name := jv.Get("name").MustString()
+		if !cloudcommon.CheckJsonAttributes(jv, []string{"id", "name", "location", "properties"}) {
+			log.Infof("exclude vpc: %s, missing attr", name, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		regionLcuuid, ok := a.getRegionLcuuid(jv.Get("location").MustString())
+		if !ok {
+			log.Infof("exclude vpc: %s, region not included", name, logger.NewORGPrefix(a.orgID))
+			continue
+		}
+		id := jv.Get("id").MustString()
+		vpcLcuuid := a.generateLcuuid(id)
+		vpcs = append(vpcs, model.VPC{
+			Lcuuid:       vpcLcuuid,
+			Name:         name,
+			Label:        jv.Get("properties").Get("resourceGuid").MustString(),
+			CIDR:         strings.Join(jv.Get("properties").Get("addressSpace").Get("addressPrefixes").MustStringArray(), ","),
+			RegionLcuuid: regionLcuuid,
+		})
+		a.toolDataSet.regionLcuuidToResourceNum[regionLcuuid]++
+		a.toolDataSet.vnetIDToRegionLcuuid[strings.ToLower(id)] = regionLcuuid
+
+		// azure VNet 中没有路由器资源，为每个 VNet 生成一个 VRouter 承载子网间的互通
+		vrouterLcuuid := common.GenerateUUIDByOrgID(a.orgID, vpcLcuuid)
+		vrouters = append(vrouters, model.VRouter{
+			Lcuuid:       vrouterLcuuid,
+			Name:         name,
+			VPCLcuuid:    vpcLcuuid,
+			RegionLcuuid: regionLcuuid,
+		})
+
+		azLcuuid := a.getAZLcuuid(jv.Get("location").MustString(), "", regionLcuuid)
+		jSubnets := jv.Get("properties").Get("subnets")
+		for j := range jSubnets.MustArray() {
+			network, nSubnets, ok := a.formatNetwork(jSubnets.GetIndex(j), vpcLcuuid, azLcuuid, regionLcuuid)
+			if !ok {
+				continue
+			}
+			networks = append(networks, network)
+			subnets = append(subnets, nSubnets...)
+			a.toolDataSet.azLcuuidToResourceNum[azLcuuid]++
+
+			vifs = append(vifs, model.VInterface{
+				Lcuuid:        common.GenerateUUIDByOrgID(a.orgID, network.Lcuuid+vpcLcuuid),
+				Type:          common.VIF_TYPE_LAN,
+				Mac:           common.VIF_DEFAULT_MAC,
+				DeviceType:    common.VIF_DEVICE_TYPE_VROUTER,
+				DeviceLcuuid:  vrouterLcuuid,
+				NetworkLcuuid: network.Lcuuid,
+				VPCLcuuid:     vpcLcuuid,
+				RegionLcuuid:  regionLcuuid,
+			})
+		}
+
+		jPeerings := jv.Get("properties").Get("virtualNetworkPeerings")
+		for j := range jPeerings.MustArray() {
+			a.toolDataSet.vnetPeerings = append(a.toolDataSet.vnetPeerings, vnetPeering{localVNetID: id, jPeering: jPeerings.GetIndex(j)})
+		}
+	}
+	return
+}
+
+func (a *Azure) formatNetwork(js *simplejson.Json, vpcLcuuid, azLcuuid, regionLcuuid string) (model.Network, []model.Subnet, bool) {
+	name := js.Get("name").MustString()
+	if !cloudcommon.CheckJsonAttributes(js, []string{"id", "name", "properties"}) {
+		log.Infof("exclude network: %s, missing attr", name, logger.NewORGPrefix(a.orgID))
+		return model.Network{}, nil, false
+	}
+	id := js.Get("id").MustString()
+	network := model.Network{
+		Lcuuid:         a.generateLcuuid(id),
+		Name:           name,
+		SegmentationID: 1,
+		Shared:         false,
+		External:       false,
+		NetType:        common.NETWORK_TYPE_LAN,
+		VPCLcuuid:      vpcLcuuid,
+		AZLcuuid:       azLcuuid,
+		RegionLcuuid:   regionLcuuid,
+	}
+	a.toolDataSet.subnetIDToNetwork[strings.ToLower(id)] = network
+
+	// 子网只有一个网段时返回 addressPrefix，同时有 IPv4/IPv6 网段时返回 addressPrefixes
+	cidrs := js.Get("properties").Get("addressPrefixes").MustStringArray()
+	if cidr := js.Get("properties").Get("addressPrefix").MustString(); cidr != "" {
+		cidrs = append([]string{cidr}, cidrs...)
+	}
+	var subnets []model.Subnet
+	for _, cidr := range cidrs {
+		subnet := model.Subnet{
+			Lcuuid:        common.GenerateUUIDByOrgID(a.orgID, network.Lcuuid+cidr),
+			Name:          name,
+			CIDR:          cidr,
+			NetworkLcuuid: network.Lcuuid,
+			VPCLcuuid:     vpcLcuuid,
+		}
+		if strings.Contains(cidr, ":") {
+			subnet.Name = name + "_v6"
+		}
+		subnets = append(subnets, subnet)
+	}
+	if len(subnets) > 0 {
+		a.toolDataSet.subnetIDToSubnets[strings.ToLower(id)] = subnets
+	}
+	return network, subnets, true
+}
+
+// getSubnetLcuuid 根据 IP 所属的网段返回子网，子网 ID 不存在时返回空
+func (a *Azure) getSubnetLcuuid(subnetID, ip string) string {
+	subnets := a.toolDataSet.subnetIDToSubnets[strings.ToLower(subnetID)]
+	for _, subnet := range subnets {
+		if cloudcommon.IsIPInCIDR(ip, subnet.CIDR) {
+			return subnet.Lcuuid
+		}
+	}
+	if len(subnets) > 0 {
+		return subnets[0].Lcuuid
+	}
+	return ""
+}
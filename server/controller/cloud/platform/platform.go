@@ -22,6 +22,7 @@ import (
 
 	"github.com/deepflowio/deepflow/server/controller/cloud/aliyun"
 	"github.com/deepflowio/deepflow/server/controller/cloud/aws"
+	"github.com/deepflowio/deepflow/server/controller/cloud/azure"
 	"github.com/deepflowio/deepflow/server/controller/cloud/baidubce"
 	"github.com/deepflowio/deepflow/server/controller/cloud/config"
 	"github.com/deepflowio/deepflow/server/controller/cloud/filereader"
@@ -71,6 +72,8 @@ func NewPlatform(domain mysqlmodel.Domain, cfg config.CloudConfig, db *mysql.DB)
 		platform, err = filereader.NewFileReader(db.ORGID, domain)
 	case common.VOLCENGINE:
 		platform, err = volcengine.NewVolcEngine(db.ORGID, domain, cfg)
+	case common.AZURE:
+		platform, err = azure.NewAzure(db.ORGID, domain, cfg)
 	// TODO: other platform
 	default:
 		return nil, errors.New(fmt.Sprintf("domain type (%d) not supported", domain.Type))
@@ -17,6 +17,7 @@
 package config
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"strconv"
@@ -73,6 +74,10 @@ type ControllerConfig struct {
 	GrpcNodePort                   string `default:"30035" yaml:"grpc-node-port"`
 	Kubeconfig                     string `yaml:"kubeconfig"`
 	ElectionName                   string `default:"deepflow-server" yaml:"election-name"`
+	ElectionBackend                string `default:"kubernetes" yaml:"election-backend"`
+	ElectionLeaseDuration          int    `default:"15" yaml:"election-lease-duration"`
+	ElectionRenewDeadline          int    `default:"10" yaml:"election-renew-deadline"`
+	ElectionRetryPeriod            int    `default:"2" yaml:"election-retry-period"`
 	ReportingDisabled              bool   `default:"false" yaml:"reporting-disabled"`
 	BillingMethod                  string `default:"license" yaml:"billing-method"`
 	PodClusterInternalIPToIngester int    `default:"0" yaml:"pod-cluster-internal-ip-to-ingester"`
@@ -106,6 +111,14 @@ type Config struct {
 }
 
 func (c *Config) Validate() error {
+	cfg := c.ControllerConfig
+	if cfg.ElectionBackend != "kubernetes" && cfg.ElectionBackend != "mysql" {
+		return fmt.Errorf("election-backend (%s) is not supported, only kubernetes and mysql are allowed", cfg.ElectionBackend)
+	}
+	if cfg.ElectionRetryPeriod <= 0 || cfg.ElectionRenewDeadline <= cfg.ElectionRetryPeriod || cfg.ElectionLeaseDuration <= cfg.ElectionRenewDeadline {
+		return fmt.Errorf("election-lease-duration (%d) must be greater than election-renew-deadline (%d), which must be greater than election-retry-period (%d)",
+			cfg.ElectionLeaseDuration, cfg.ElectionRenewDeadline, cfg.ElectionRetryPeriod)
+	}
 	return nil
 }
 
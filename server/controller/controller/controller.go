@@ -75,8 +75,9 @@ func Start(ctx context.Context, configPath, serverLogFile string, shared *server
 
 	router.SetInitStageForHealthChecker("Election init")
 	// start election
-	if common.IsStandaloneRunningMode() == false {
-		// in standalone mode, We have no way to elect because there is no k8s module
+	election.SetBackend(cfg.ElectionBackend)
+	if common.IsStandaloneRunningMode() == false || election.IsMySQLBackend() {
+		// in standalone mode, We have no way to elect by k8s, but controllers sharing a mysql can elect by it
 		go election.Start(ctx, cfg)
 	}
 
@@ -136,6 +137,9 @@ func Start(ctx context.Context, configPath, serverLogFile string, shared *server
 		time.Sleep(time.Second)
 		os.Exit(0)
 	}
+	// start resource history and change sink before manager for the same reason as tagrecorder
+	recorderResource.History.Start()
+	recorderResource.Sink.Start()
 
 	router.SetInitStageForHealthChecker("Manager init")
 	// 启动resource manager
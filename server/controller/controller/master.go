@@ -65,6 +65,11 @@ func IsMasterController(cfg *config.ControllerConfig) bool {
 
 // migrate db by master region master controller
 func migrateMySQL(cfg *config.ControllerConfig) {
+	// the leadership may have changed hands since it was observed
+	if err := election.CheckFencingToken(); err != nil {
+		log.Warningf("skip migrating mysql: %s", err.Error())
+		return
+	}
 	err := migrator.Migrate(cfg.MySqlCfg)
 	if err != nil {
 		log.Errorf("migrate mysql failed: %s", err.Error())
@@ -157,6 +162,10 @@ func checkAndStartMasterFunctions(
 
 				// 资源数据清理
 				recorderResource.Cleaners.Start(sCtx)
+				// 资源历史快照清理
+				recorderResource.History.StartCleaner(sCtx)
+				// 资源变更消息推送
+				recorderResource.Sink.StartForwarders(sCtx)
 
 				// domain检查及自愈
 				domainChecker.Start(sCtx)
@@ -182,6 +191,8 @@ func checkAndStartMasterFunctions(
 				// stop prometheus related
 				// stop http task mananger
 				// stop resource cleaner
+				// stop resource history cleaner
+				// stop resource change sink forwarders
 				// stop delete org checker
 				if sCancel != nil {
 					sCancel()
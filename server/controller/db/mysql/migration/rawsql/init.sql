@@ -42,6 +42,45 @@ CREATE TABLE IF NOT EXISTS resource_event (
 )ENGINE=innodb AUTO_INCREMENT=1 DEFAULT CHARSET=utf8;
 TRUNCATE TABLE resource_event;
 
+CREATE TABLE IF NOT EXISTS resource_snapshot (
+    id                  BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    resource_type       VARCHAR(64) NOT NULL,
+    resource_id         INTEGER DEFAULT 0,
+    resource_lcuuid     CHAR(64) NOT NULL,
+    resource_name       VARCHAR(256) DEFAULT '',
+    ip                  VARCHAR(64) DEFAULT '',
+    domain              CHAR(64) DEFAULT '',
+    version             INTEGER DEFAULT 1,
+    content             MEDIUMTEXT,
+    valid_from          DATETIME NOT NULL,
+    valid_to            DATETIME DEFAULT NULL,
+    INDEX lcuuid_index(resource_lcuuid),
+    INDEX type_id_index(resource_type, resource_id),
+    INDEX ip_index(ip),
+    INDEX valid_to_index(valid_to)
+)ENGINE=innodb AUTO_INCREMENT=1 DEFAULT CHARSET=utf8;
+TRUNCATE TABLE resource_snapshot;
+
+CREATE TABLE IF NOT EXISTS resource_change_message (
+    id                  BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    resource_type       VARCHAR(64) NOT NULL,
+    resource_lcuuid     CHAR(64) DEFAULT '',
+    domain              CHAR(64) DEFAULT '',
+    content             MEDIUMTEXT,
+    created_at          DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX created_at_index(created_at)
+)ENGINE=innodb AUTO_INCREMENT=1 DEFAULT CHARSET=utf8;
+TRUNCATE TABLE resource_change_message;
+
+CREATE TABLE IF NOT EXISTS resource_change_sink_offset (
+    id                  INTEGER NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    sink                VARCHAR(256) NOT NULL,
+    last_message_id     BIGINT DEFAULT 0,
+    updated_at          DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE INDEX sink_index(sink)
+)ENGINE=innodb AUTO_INCREMENT=1 DEFAULT CHARSET=utf8;
+TRUNCATE TABLE resource_change_sink_offset;
+
 CREATE TABLE IF NOT EXISTS domain_additional_resource (
     id                  INTEGER NOT NULL AUTO_INCREMENT PRIMARY KEY,
     domain              CHAR(64) DEFAULT '',
@@ -1087,6 +1126,33 @@ CREATE TABLE IF NOT EXISTS vtap_group (
 ) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8;
 TRUNCATE TABLE vtap_group;
 
+CREATE TABLE IF NOT EXISTS agent_cmd_audit (
+    id                  INTEGER NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id             INTEGER DEFAULT 0,
+    user_type           INTEGER DEFAULT 0,
+    source_ip           VARCHAR(64) DEFAULT '',
+    cmd                 VARCHAR(256) NOT NULL,
+    request             TEXT,
+    agent_count         INTEGER DEFAULT 0,
+    success_count       INTEGER DEFAULT 0,
+    failure_count       INTEGER DEFAULT 0,
+    result              MEDIUMTEXT,
+    created_at          DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at         DATETIME DEFAULT NULL,
+    INDEX created_at_index(created_at)
+)ENGINE=innodb AUTO_INCREMENT=1 DEFAULT CHARSET=utf8;
+TRUNCATE TABLE agent_cmd_audit;
+
+CREATE TABLE IF NOT EXISTS election_lease (
+    name                VARCHAR(64) NOT NULL PRIMARY KEY,
+    holder_identity     VARCHAR(256) NOT NULL DEFAULT '',
+    fencing_token       BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    revision            BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    lease_duration      INTEGER NOT NULL DEFAULT 0 COMMENT 'unit: s',
+    acquire_time        BIGINT NOT NULL DEFAULT 0 COMMENT 'unix timestamp, unit: s',
+    renew_time          BIGINT NOT NULL DEFAULT 0 COMMENT 'unix timestamp, unit: ms'
+)ENGINE=innodb DEFAULT CHARSET=utf8 COMMENT='lease of controller leader election, created by the election before migration';
+
 CREATE TABLE IF NOT EXISTS topo_position (
     id                      INTEGER NOT NULL AUTO_INCREMENT PRIMARY KEY,
     type                    INTEGER DEFAULT 1 COMMENT '3-link topo',
@@ -1165,6 +1231,8 @@ CREATE TABLE IF NOT EXISTS npb_policy (
     acl_id                 INTEGER,
     policy_acl_group_id    INTEGER,
     vtap_ids               TEXT COMMENT 'separated by ,',
+    vtap_group_lcuuids     TEXT COMMENT 'separated by ,',
+    expired_at             DATETIME DEFAULT NULL,
     created_at             TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
     updated_at             TIMESTAMP NOT NULL ON UPDATE CURRENT_TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     lcuuid                 CHAR(64)
@@ -1178,9 +1246,11 @@ CREATE TABLE IF NOT EXISTS pcap_policy (
     business_id            INTEGER NOT NULL,
     acl_id                 INTEGER,
     vtap_ids               TEXT COMMENT 'separated by ,',
+    vtap_group_lcuuids     TEXT COMMENT 'separated by ,',
     payload_slice          INTEGER,
     policy_acl_group_id    INTEGER,
     user_id                INTEGER,
+    expired_at             DATETIME DEFAULT NULL,
     created_at             TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
     updated_at             TIMESTAMP NOT NULL ON UPDATE CURRENT_TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     lcuuid                 CHAR(64),
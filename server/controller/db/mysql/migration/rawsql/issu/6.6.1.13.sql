@@ -0,0 +1,34 @@
+DROP PROCEDURE IF EXISTS AddColumnIfNotExists;
+
+CREATE PROCEDURE AddColumnIfNotExists(
+    IN tableName VARCHAR(255),
+    IN colName VARCHAR(255),
+    IN colType VARCHAR(255),
+    IN afterCol VARCHAR(255)
+)
+BEGIN
+    DECLARE column_count INT;
+
+    SELECT COUNT(*)
+    INTO column_count
+    FROM information_schema.columns
+    WHERE TABLE_SCHEMA = DATABASE()
+    AND TABLE_NAME = tableName
+    AND column_name = colName;
+
+    IF column_count = 0 THEN
+        SET @sql = CONCAT('ALTER TABLE ', tableName, ' ADD COLUMN ', colName, ' ', colType, ' AFTER ', afterCol);
+        PREPARE stmt FROM @sql;
+        EXECUTE stmt;
+        DEALLOCATE PREPARE stmt;
+    END IF;
+END;
+
+CALL AddColumnIfNotExists('npb_policy', 'expired_at', 'DATETIME DEFAULT NULL', 'vtap_ids');
+CALL AddColumnIfNotExists('pcap_policy', 'expired_at', 'DATETIME DEFAULT NULL', 'user_id');
+
+DROP PROCEDURE AddColumnIfNotExists;
+
+-- whether default db or not, update db_version to latest, remember update DB_VERSION_EXPECT in migrate/init.go
+UPDATE db_version SET version='6.6.1.13';
+-- modify end
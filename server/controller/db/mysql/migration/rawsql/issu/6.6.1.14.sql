@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS resource_snapshot (
+    id                  BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    resource_type       VARCHAR(64) NOT NULL,
+    resource_id         INTEGER DEFAULT 0,
+    resource_lcuuid     CHAR(64) NOT NULL,
+    resource_name       VARCHAR(256) DEFAULT '',
+    ip                  VARCHAR(64) DEFAULT '',
+    domain              CHAR(64) DEFAULT '',
+    version             INTEGER DEFAULT 1,
+    content             MEDIUMTEXT,
+    valid_from          DATETIME NOT NULL,
+    valid_to            DATETIME DEFAULT NULL,
+    INDEX lcuuid_index(resource_lcuuid),
+    INDEX type_id_index(resource_type, resource_id),
+    INDEX ip_index(ip),
+    INDEX valid_to_index(valid_to)
+)ENGINE=innodb AUTO_INCREMENT=1 DEFAULT CHARSET=utf8;
+
+-- whether default db or not, update db_version to latest, remember update DB_VERSION_EXPECT in migrate/init.go
+UPDATE db_version SET version='6.6.1.14';
+-- modify end
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS resource_change_message (
+    id                  BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    resource_type       VARCHAR(64) NOT NULL,
+    resource_lcuuid     CHAR(64) DEFAULT '',
+    domain              CHAR(64) DEFAULT '',
+    content             MEDIUMTEXT,
+    created_at          DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX created_at_index(created_at)
+)ENGINE=innodb AUTO_INCREMENT=1 DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS resource_change_sink_offset (
+    id                  INTEGER NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    sink                VARCHAR(256) NOT NULL,
+    last_message_id     BIGINT DEFAULT 0,
+    updated_at          DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE INDEX sink_index(sink)
+)ENGINE=innodb AUTO_INCREMENT=1 DEFAULT CHARSET=utf8;
+
+-- whether default db or not, update db_version to latest, remember update DB_VERSION_EXPECT in migrate/init.go
+UPDATE db_version SET version='6.6.1.15';
+-- modify end
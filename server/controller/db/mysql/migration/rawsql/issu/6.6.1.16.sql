@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS agent_cmd_audit (
+    id                  INTEGER NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id             INTEGER DEFAULT 0,
+    user_type           INTEGER DEFAULT 0,
+    source_ip           VARCHAR(64) DEFAULT '',
+    cmd                 VARCHAR(256) NOT NULL,
+    request             TEXT,
+    agent_count         INTEGER DEFAULT 0,
+    success_count       INTEGER DEFAULT 0,
+    failure_count       INTEGER DEFAULT 0,
+    result              MEDIUMTEXT,
+    created_at          DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at         DATETIME DEFAULT NULL,
+    INDEX created_at_index(created_at)
+)ENGINE=innodb AUTO_INCREMENT=1 DEFAULT CHARSET=utf8;
+
+-- whether default db or not, update db_version to latest, remember update DB_VERSION_EXPECT in migrate/init.go
+UPDATE db_version SET version='6.6.1.16';
+-- modify end
@@ -0,0 +1,39 @@
+DROP PROCEDURE IF EXISTS AddColumnIfNotExists;
+
+CREATE PROCEDURE AddColumnIfNotExists(
+    IN tableName VARCHAR(255),
+    IN colName VARCHAR(255),
+    IN colType VARCHAR(255),
+    IN afterCol VARCHAR(255)
+)
+BEGIN
+    DECLARE column_count INT;
+
+    SELECT COUNT(*)
+    INTO column_count
+    FROM information_schema.columns
+    WHERE TABLE_SCHEMA = DATABASE()
+    AND TABLE_NAME = tableName
+    AND column_name = colName;
+
+    IF column_count = 0 THEN
+        SET @sql = CONCAT('ALTER TABLE ', tableName, ' ADD COLUMN ', colName, ' ', colType, ' AFTER ', afterCol);
+        PREPARE stmt FROM @sql;
+        EXECUTE stmt;
+        DEALLOCATE PREPARE stmt;
+    END IF;
+END;
+
+CALL AddColumnIfNotExists('npb_policy', 'vtap_group_lcuuids', 'TEXT COMMENT \'separated by ,\'', 'vtap_ids');
+CALL AddColumnIfNotExists('pcap_policy', 'vtap_group_lcuuids', 'TEXT COMMENT \'separated by ,\'', 'vtap_ids');
+
+DROP PROCEDURE AddColumnIfNotExists;
+
+-- acl group ids allocated before are recorded in policy_acl_group, which keeps them unique
+INSERT IGNORE INTO policy_acl_group (id, acl_ids, `count`)
+    SELECT policy_acl_group_id, CAST(acl_id AS CHAR), 1 FROM npb_policy WHERE policy_acl_group_id > 0
+    UNION SELECT policy_acl_group_id, CAST(acl_id AS CHAR), 1 FROM pcap_policy WHERE policy_acl_group_id > 0;
+
+-- whether default db or not, update db_version to latest, remember update DB_VERSION_EXPECT in migrate/init.go
+UPDATE db_version SET version='6.6.1.17';
+-- modify end
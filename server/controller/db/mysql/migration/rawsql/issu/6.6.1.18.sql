@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS election_lease (
+    name                VARCHAR(64) NOT NULL PRIMARY KEY,
+    holder_identity     VARCHAR(256) NOT NULL DEFAULT '',
+    fencing_token       BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    revision            BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    lease_duration      INTEGER NOT NULL DEFAULT 0 COMMENT 'unit: s',
+    acquire_time        BIGINT NOT NULL DEFAULT 0 COMMENT 'unix timestamp, unit: s',
+    renew_time          BIGINT NOT NULL DEFAULT 0 COMMENT 'unix timestamp, unit: ms'
+)ENGINE=innodb DEFAULT CHARSET=utf8 COMMENT='lease of controller leader election, created by the election before migration';
+
+-- whether default db or not, update db_version to latest, remember update DB_VERSION_EXPECT in migrate/init.go
+UPDATE db_version SET version='6.6.1.18';
+-- modify end
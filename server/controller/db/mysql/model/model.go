@@ -222,6 +222,26 @@ func (v VTap) GetLcuuid() string {
 	return v.Lcuuid
 }
 
+// AgentCMDAudit records who ran which remote command on which agents, and the results
+type AgentCMDAudit struct {
+	ID           int        `gorm:"primaryKey;autoIncrement;unique;column:id;type:int;not null" json:"ID"`
+	UserID       int        `gorm:"column:user_id;type:int;default:0" json:"USER_ID"`
+	UserType     int        `gorm:"column:user_type;type:int;default:0" json:"USER_TYPE"`
+	SourceIP     string     `gorm:"column:source_ip;type:varchar(64);default:''" json:"SOURCE_IP"`
+	CMD          string     `gorm:"column:cmd;type:varchar(256);not null" json:"CMD"`
+	Request      string     `gorm:"column:request;type:text" json:"REQUEST"` // json of the whole request, including params and targets
+	AgentCount   int        `gorm:"column:agent_count;type:int;default:0" json:"AGENT_COUNT"`
+	SuccessCount int        `gorm:"column:success_count;type:int;default:0" json:"SUCCESS_COUNT"`
+	FailureCount int        `gorm:"column:failure_count;type:int;default:0" json:"FAILURE_COUNT"`
+	Result       string     `gorm:"column:result;type:mediumtext" json:"RESULT"` // json of results of each agent, outputs are truncated
+	CreatedAt    time.Time  `gorm:"autoCreateTime;column:created_at;type:datetime" json:"CREATED_AT"`
+	FinishedAt   *time.Time `gorm:"column:finished_at;type:datetime;default:null" json:"FINISHED_AT"`
+}
+
+func (AgentCMDAudit) TableName() string {
+	return "agent_cmd_audit"
+}
+
 type VTapGroup struct {
 	ID               int       `gorm:"primaryKey;column:id;type:int;not null" json:"ID"`
 	Name             string    `gorm:"column:name;type:varchar(64);not null" json:"NAME"`
@@ -346,22 +366,24 @@ func (ResourceGroupExtraInfo) TableName() string {
 
 // NpbPolicy [...]
 type NpbPolicy struct {
-	ID               int       `gorm:"primaryKey;column:id;type:int;not null" json:"ID"`
-	Name             string    `gorm:"column:name;type:char(64);default:null" json:"NAME"`
-	State            int       `gorm:"column:state;type:int;default:0" json:"STATE"` // 0-disable; 1-enable
-	BusinessID       int       `gorm:"column:business_id;type:int;not null" json:"BUSINESS_ID"`
-	Direction        int       `gorm:"column:direction;type:int;default:1" json:"DIRECTION"` // 1-two way; 2-server to client
-	Vni              *int      `gorm:"column:vni;type:int;default:null" json:"VNI"`
-	NpbTunnelID      int       `gorm:"column:npb_tunnel_id;type:int;default:null" json:"NPB_TUNNEL_ID"`
-	Distribute       int       `gorm:"column:distribute;type:int;default:0" json:"distribute"` // 0-drop, 1-distribute
-	PayloadSlice     *int      `gorm:"column:payload_slice;type:int;default:null" json:"PAYLOAD_SLICE"`
-	ACLID            int       `gorm:"column:acl_id;type:int;default:null" json:"ACL_ID"`
-	PolicyACLGroupID int       `gorm:"column:policy_acl_group_id;type:int;default:null" json:"POLICY_ACL_GROUP_ID"`
-	VtapIDs          string    `gorm:"column:vtap_ids;type:text;default:null" json:"VTAP_IDS"` // separated by ,
-	CreatedAt        time.Time `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP" json:"CREATED_AT"`
-	UpdatedAt        time.Time `gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP" json:"UPDATED_AT"`
-	Lcuuid           string    `gorm:"column:lcuuid;type:char(64);default:null" json:"LCUUID"`
-	TeamID           int       `gorm:"column:team_id;type:int;default:1" json:"TEAM_ID"`
+	ID               int        `gorm:"primaryKey;column:id;type:int;not null" json:"ID"`
+	Name             string     `gorm:"column:name;type:char(64);default:null" json:"NAME"`
+	State            int        `gorm:"column:state;type:int;default:0" json:"STATE"` // 0-disable; 1-enable
+	BusinessID       int        `gorm:"column:business_id;type:int;not null" json:"BUSINESS_ID"`
+	Direction        int        `gorm:"column:direction;type:int;default:1" json:"DIRECTION"` // 1-all; 2-forward; 3-backward
+	Vni              *int       `gorm:"column:vni;type:int;default:null" json:"VNI"`
+	NpbTunnelID      int        `gorm:"column:npb_tunnel_id;type:int;default:null" json:"NPB_TUNNEL_ID"`
+	Distribute       int        `gorm:"column:distribute;type:int;default:0" json:"distribute"` // 0-drop, 1-distribute
+	PayloadSlice     *int       `gorm:"column:payload_slice;type:int;default:null" json:"PAYLOAD_SLICE"`
+	ACLID            int        `gorm:"column:acl_id;type:int;default:null" json:"ACL_ID"`
+	PolicyACLGroupID int        `gorm:"column:policy_acl_group_id;type:int;default:null" json:"POLICY_ACL_GROUP_ID"`
+	VtapIDs          string     `gorm:"column:vtap_ids;type:text;default:null" json:"VTAP_IDS"`                     // separated by ,
+	VtapGroupLcuuids string     `gorm:"column:vtap_group_lcuuids;type:text;default:null" json:"VTAP_GROUP_LCUUIDS"` // separated by ,, resolved to vtap ids when policies are pushed
+	ExpiredAt        *time.Time `gorm:"column:expired_at;type:datetime;default:null" json:"EXPIRED_AT"`             // null means never expire
+	CreatedAt        time.Time  `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP" json:"CREATED_AT"`
+	UpdatedAt        time.Time  `gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP" json:"UPDATED_AT"`
+	Lcuuid           string     `gorm:"column:lcuuid;type:char(64);default:null" json:"LCUUID"`
+	TeamID           int        `gorm:"column:team_id;type:int;default:1" json:"TEAM_ID"`
 }
 
 func (NpbPolicy) TableName() string {
@@ -387,19 +409,21 @@ func (NpbTunnel) TableName() string {
 
 // PcapPolicy [...]
 type PcapPolicy struct {
-	ID               int       `gorm:"primaryKey;column:id;type:int;not null" json:"ID"`
-	Name             string    `gorm:"column:name;type:char(64);default:null" json:"NAME"`
-	State            int       `gorm:"column:state;type:int;default:0" json:"STATE"` // 0-disable; 1-enable
-	BusinessID       int       `gorm:"column:business_id;type:int;not null" json:"BUSINESS_ID"`
-	ACLID            int       `gorm:"column:acl_id;type:int;default:null" json:"ACL_ID"`
-	VtapIDs          string    `gorm:"column:vtap_ids;type:text;default:null" json:"VTAP_IDS"` // separated by ,
-	PayloadSlice     *int      `gorm:"column:payload_slice;type:int;default:null" json:"PAYLOAD_SLICE"`
-	PolicyACLGroupID int       `gorm:"column:policy_acl_group_id;type:int;default:null" json:"POLICY_ACL_GROUP_ID"`
-	UserID           int       `gorm:"column:user_id;type:int;default:null" json:"USER_ID"`
-	CreatedAt        time.Time `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP" json:"CREATED_AT"`
-	UpdatedAt        time.Time `gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP" json:"UPDATED_AT"`
-	Lcuuid           string    `gorm:"column:lcuuid;type:char(64);default:null" json:"LCUUID"`
-	TeamID           int       `gorm:"column:team_id;type:int;default:1" json:"TEAM_ID"`
+	ID               int        `gorm:"primaryKey;column:id;type:int;not null" json:"ID"`
+	Name             string     `gorm:"column:name;type:char(64);default:null" json:"NAME"`
+	State            int        `gorm:"column:state;type:int;default:0" json:"STATE"` // 0-disable; 1-enable
+	BusinessID       int        `gorm:"column:business_id;type:int;not null" json:"BUSINESS_ID"`
+	ACLID            int        `gorm:"column:acl_id;type:int;default:null" json:"ACL_ID"`
+	VtapIDs          string     `gorm:"column:vtap_ids;type:text;default:null" json:"VTAP_IDS"`                     // separated by ,
+	VtapGroupLcuuids string     `gorm:"column:vtap_group_lcuuids;type:text;default:null" json:"VTAP_GROUP_LCUUIDS"` // separated by ,, resolved to vtap ids when policies are pushed
+	PayloadSlice     *int       `gorm:"column:payload_slice;type:int;default:null" json:"PAYLOAD_SLICE"`
+	PolicyACLGroupID int        `gorm:"column:policy_acl_group_id;type:int;default:null" json:"POLICY_ACL_GROUP_ID"`
+	UserID           int        `gorm:"column:user_id;type:int;default:null" json:"USER_ID"`
+	ExpiredAt        *time.Time `gorm:"column:expired_at;type:datetime;default:null" json:"EXPIRED_AT"` // null means never expire
+	CreatedAt        time.Time  `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP" json:"CREATED_AT"`
+	UpdatedAt        time.Time  `gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP" json:"UPDATED_AT"`
+	Lcuuid           string     `gorm:"column:lcuuid;type:char(64);default:null" json:"LCUUID"`
+	TeamID           int        `gorm:"column:team_id;type:int;default:1" json:"TEAM_ID"`
 }
 
 func (PcapPolicy) TableName() string {
@@ -31,6 +31,36 @@ type ResourceEvent struct {
 	CreatedAt      time.Time `gorm:"autoCreateTime;column:created_at;type:datetime" json:"CREATED_AT"`
 }
 
+type ResourceSnapshot struct {
+	ID             int        `gorm:"primaryKey;autoIncrement;unique;column:id;type:bigint;not null" json:"ID"`
+	ResourceType   string     `gorm:"column:resource_type;type:varchar(64);not null" json:"RESOURCE_TYPE"`
+	ResourceID     int        `gorm:"column:resource_id;type:int;default:0" json:"RESOURCE_ID"`
+	ResourceLcuuid string     `gorm:"column:resource_lcuuid;type:char(64);not null" json:"RESOURCE_LCUUID"`
+	ResourceName   string     `gorm:"column:resource_name;type:varchar(256);default:''" json:"RESOURCE_NAME"`
+	IP             string     `gorm:"column:ip;type:varchar(64);default:''" json:"IP"`
+	Domain         string     `gorm:"column:domain;type:char(64);default:''" json:"DOMAIN"`
+	Version        int        `gorm:"column:version;type:int;default:1" json:"VERSION"`
+	Content        string     `gorm:"column:content;type:mediumtext" json:"CONTENT"`
+	ValidFrom      time.Time  `gorm:"column:valid_from;type:datetime;not null" json:"VALID_FROM"`
+	ValidTo        *time.Time `gorm:"column:valid_to;type:datetime;default:null" json:"VALID_TO"`
+}
+
+type ResourceChangeMessage struct {
+	ID             int       `gorm:"primaryKey;autoIncrement;unique;column:id;type:bigint;not null" json:"ID"`
+	ResourceType   string    `gorm:"column:resource_type;type:varchar(64);not null" json:"RESOURCE_TYPE"`
+	ResourceLcuuid string    `gorm:"column:resource_lcuuid;type:char(64);default:''" json:"RESOURCE_LCUUID"`
+	Domain         string    `gorm:"column:domain;type:char(64);default:''" json:"DOMAIN"`
+	Content        string    `gorm:"column:content;type:mediumtext" json:"CONTENT"`
+	CreatedAt      time.Time `gorm:"autoCreateTime;column:created_at;type:datetime" json:"CREATED_AT"`
+}
+
+type ResourceChangeSinkOffset struct {
+	ID            int       `gorm:"primaryKey;autoIncrement;unique;column:id;type:int;not null" json:"ID"`
+	Sink          string    `gorm:"unique;column:sink;type:varchar(256);not null" json:"SINK"`
+	LastMessageID int       `gorm:"column:last_message_id;type:bigint;default:0" json:"LAST_MESSAGE_ID"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime;column:updated_at;type:datetime" json:"UPDATED_AT"`
+}
+
 type DomainAdditionalResource struct {
 	ID                int             `gorm:"primaryKey;autoIncrement;unique;column:id;type:int;not null" json:"ID"`
 	Domain            string          `gorm:"column:domain;type:char(64);default:''" json:"DOMAIN"`
@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	logging "github.com/op/go-logging"
@@ -45,8 +46,29 @@ var (
 	startTime   = time.Now().Unix()
 )
 
+const (
+	ELECTION_BACKEND_KUBERNETES = "kubernetes"
+	ELECTION_BACKEND_MYSQL      = "mysql"
+)
+
+var backend = ELECTION_BACKEND_KUBERNETES
+
+// SetBackend must be called before Start and any leader query
+func SetBackend(name string) {
+	backend = name
+}
+
+func IsMySQLBackend() bool {
+	return backend == ELECTION_BACKEND_MYSQL
+}
+
+// isLocalLeader is true when there is no election and the local machine is the master node
+func isLocalLeader() bool {
+	return common.IsStandaloneRunningMode() && !IsMySQLBackend()
+}
+
 func GetAcquireTime() int64 {
-	if common.IsStandaloneRunningMode() {
+	if isLocalLeader() {
 		// in standalone mode, the local machine is the master node because of all in one deployment
 		return startTime
 	}
@@ -114,13 +136,22 @@ func getID() string {
 }
 
 func GetLeader() string {
-	if common.IsStandaloneRunningMode() {
+	if isLocalLeader() {
 		// in standalone mode, the local machine is the master node because of all in one deployment
 		return getID()
 	}
 	return leaderData.GetLeader()
 }
 
+var fencingToken uint64
+
+// GetFencingToken returns the fencing token of the current leadership when elected by mysql,
+// it increases every time the leadership changes hands, 0 means not the leader or not supported.
+// Leader-only writes are fenced with it by FencedTransaction or CheckFencingToken.
+func GetFencingToken() uint64 {
+	return atomic.LoadUint64(&fencingToken)
+}
+
 func getCurrentLeader(ctx context.Context, lock *resourcelock.LeaseLock) string {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -173,6 +204,10 @@ func checkLeaderValid(ctx context.Context, lock *resourcelock.LeaseLock) {
 }
 
 func Start(ctx context.Context, cfg *config.ControllerConfig) {
+	if IsMySQLBackend() {
+		startMySQLElection(ctx, cfg)
+		return
+	}
 	kubeconfig := cfg.Kubeconfig
 	electionName := cfg.ElectionName
 	electionNamespace := common.GetNameSpace()
@@ -0,0 +1,484 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package election
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/controller/config"
+	mysqlcommon "github.com/deepflowio/deepflow/server/controller/db/mysql/common"
+	migratorcommon "github.com/deepflowio/deepflow/server/controller/db/mysql/migrator/common"
+	"github.com/deepflowio/deepflow/server/controller/trisolaris/utils"
+)
+
+const ELECTION_LEASE_TABLE = "election_lease"
+
+var ErrStaleFencingToken = errors.New("stale fencing token")
+
+// ElectionLease is the lease row shared by all controllers, the holder renews it periodically.
+// Expiration is judged by the time each candidate observes the row unchanged rather than by
+// the renew time written in it, so the clocks of controllers are not required to be in sync.
+type ElectionLease struct {
+	Name           string `gorm:"column:name;type:varchar(64);primaryKey"`
+	HolderIdentity string `gorm:"column:holder_identity;type:varchar(256);not null;default:''"`
+	// incremented each time the leadership changes hands, writes guarded by the lease can carry it
+	// and be rejected if it is older than the one in the row
+	FencingToken  uint64 `gorm:"column:fencing_token;not null;default:0"`
+	Revision      uint64 `gorm:"column:revision;not null;default:0"`       // incremented on every write, used for compare-and-swap
+	LeaseDuration int    `gorm:"column:lease_duration;not null;default:0"` // unit: s
+	AcquireTime   int64  `gorm:"column:acquire_time;not null;default:0"`   // unix timestamp, unit: s
+	RenewTime     int64  `gorm:"column:renew_time;not null;default:0"`     // unix timestamp, unit: ms
+}
+
+func (ElectionLease) TableName() string {
+	return ELECTION_LEASE_TABLE
+}
+
+type leaseStore interface {
+	// get returns nil if the lease does not exist
+	get(name string) (*ElectionLease, error)
+	// create returns false if the lease already exists
+	create(lease *ElectionLease) (bool, error)
+	// update returns false if the revision of the lease is not the expected one
+	update(lease *ElectionLease, revision uint64) (bool, error)
+}
+
+type mysqlLeaseStore struct {
+	db *gorm.DB
+}
+
+func (s *mysqlLeaseStore) get(name string) (*ElectionLease, error) {
+	var leases []*ElectionLease
+	if err := s.db.Where("name = ?", name).Limit(1).Find(&leases).Error; err != nil {
+		return nil, err
+	}
+	if len(leases) == 0 {
+		return nil, nil
+	}
+	return leases[0], nil
+}
+
+func (s *mysqlLeaseStore) create(lease *ElectionLease) (bool, error) {
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(lease)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (s *mysqlLeaseStore) update(lease *ElectionLease, revision uint64) (bool, error) {
+	result := s.db.Model(&ElectionLease{}).Where("name = ? AND revision = ?", lease.Name, revision).Updates(map[string]interface{}{
+		"holder_identity": lease.HolderIdentity,
+		"fencing_token":   lease.FencingToken,
+		"revision":        lease.Revision,
+		"lease_duration":  lease.LeaseDuration,
+		"acquire_time":    lease.AcquireTime,
+		"renew_time":      lease.RenewTime,
+	})
+	return result.RowsAffected == 1, result.Error
+}
+
+type leaseCallbacks struct {
+	onStartedLeading func(lease ElectionLease)
+	onStoppedLeading func()
+	// called with an empty identity when the leader released the lease
+	onNewLeader func(lease ElectionLease)
+}
+
+type leaseElector struct {
+	name          string
+	id            string
+	store         leaseStore
+	leaseDuration time.Duration
+	renewDeadline time.Duration
+	retryPeriod   time.Duration
+	callbacks     leaseCallbacks
+	now           func() time.Time
+
+	mutex          sync.RWMutex
+	isLeader       bool
+	observedLease  ElectionLease
+	observedTime   time.Time
+	lastRenewTime  time.Time
+	reportedLeader string
+}
+
+func newLeaseElector(name, id string, store leaseStore, leaseDuration, renewDeadline, retryPeriod time.Duration, callbacks leaseCallbacks) *leaseElector {
+	return &leaseElector{
+		name:          name,
+		id:            id,
+		store:         store,
+		leaseDuration: leaseDuration,
+		renewDeadline: renewDeadline,
+		retryPeriod:   retryPeriod,
+		callbacks:     callbacks,
+		now:           time.Now,
+	}
+}
+
+func (e *leaseElector) observe(lease ElectionLease, now time.Time) {
+	e.observedLease = lease
+	e.observedTime = now
+}
+
+// tryAcquireOrRenew creates the lease if it does not exist, renews it if it is held by self,
+// takes it over if it has been released or not renewed for the lease duration
+func (e *leaseElector) tryAcquireOrRenew() (bool, error) {
+	now := e.now()
+	lease, err := e.store.get(e.name)
+	if err != nil {
+		return false, err
+	}
+	if lease == nil {
+		newLease := ElectionLease{
+			Name:           e.name,
+			HolderIdentity: e.id,
+			FencingToken:   1,
+			Revision:       1,
+			LeaseDuration:  int(e.leaseDuration / time.Second),
+			AcquireTime:    now.Unix(),
+			RenewTime:      now.UnixMilli(),
+		}
+		ok, err := e.store.create(&newLease)
+		if ok {
+			e.observe(newLease, now)
+		}
+		return ok, err
+	}
+
+	if lease.Revision != e.observedLease.Revision || lease.HolderIdentity != e.observedLease.HolderIdentity {
+		e.observe(*lease, now)
+	}
+	leaseDuration := e.leaseDuration
+	if lease.LeaseDuration > 0 {
+		leaseDuration = time.Duration(lease.LeaseDuration) * time.Second
+	}
+	if lease.HolderIdentity != "" && lease.HolderIdentity != e.id && now.Before(e.observedTime.Add(leaseDuration)) {
+		return false, nil
+	}
+
+	newLease := *lease
+	newLease.Revision++
+	newLease.LeaseDuration = int(e.leaseDuration / time.Second)
+	newLease.RenewTime = now.UnixMilli()
+	if lease.HolderIdentity != e.id {
+		if lease.HolderIdentity != "" {
+			log.Infof("lease %s held by %s expired, take it over", e.name, lease.HolderIdentity)
+		}
+		newLease.HolderIdentity = e.id
+		newLease.FencingToken++
+		newLease.AcquireTime = now.Unix()
+	}
+	ok, err := e.store.update(&newLease, lease.Revision)
+	if ok {
+		e.observe(newLease, now)
+	}
+	return ok, err
+}
+
+func (e *leaseElector) tick() {
+	succeeded, err := e.tryAcquireOrRenew()
+	if err != nil {
+		log.Warningf("acquire or renew lease %s failed: %s", e.name, err)
+	}
+	now := e.now()
+
+	e.mutex.Lock()
+	startedLeading, stoppedLeading := false, false
+	if succeeded {
+		e.lastRenewTime = now
+		if !e.isLeader {
+			e.isLeader = true
+			startedLeading = true
+		}
+	} else if e.isLeader {
+		// keep the leadership when mysql is unreachable until the renew deadline, which is shorter
+		// than the lease duration, so no one else can have taken over the lease in the meantime
+		if err == nil || now.Sub(e.lastRenewTime) > e.renewDeadline {
+			e.isLeader = false
+			stoppedLeading = true
+		}
+	}
+	lease := e.observedLease
+	if !e.isLeader && lease.HolderIdentity == e.id {
+		// stepped down without knowing who holds the lease now
+		lease.HolderIdentity = ""
+	}
+	newLeader := lease.HolderIdentity != e.reportedLeader
+	e.reportedLeader = lease.HolderIdentity
+	e.mutex.Unlock()
+
+	if stoppedLeading {
+		log.Infof("leader lost: %s", e.id)
+		if e.callbacks.onStoppedLeading != nil {
+			e.callbacks.onStoppedLeading()
+		}
+	}
+	if startedLeading {
+		log.Infof("%s is the leader, fencing token %d", e.id, lease.FencingToken)
+		if e.callbacks.onStartedLeading != nil {
+			e.callbacks.onStartedLeading(lease)
+		}
+	}
+	if newLeader && e.callbacks.onNewLeader != nil {
+		e.callbacks.onNewLeader(lease)
+	}
+}
+
+// release gives up the lease so that other candidates can take it over at once instead of waiting for it to expire
+func (e *leaseElector) release() {
+	e.mutex.Lock()
+	isLeader := e.isLeader
+	e.isLeader = false
+	e.mutex.Unlock()
+	if !isLeader {
+		return
+	}
+
+	lease := e.observedLease
+	newLease := lease
+	newLease.HolderIdentity = ""
+	newLease.Revision++
+	newLease.RenewTime = e.now().UnixMilli()
+	ok, err := e.store.update(&newLease, lease.Revision)
+	if err != nil {
+		log.Warningf("release lease %s failed: %s", e.name, err)
+	} else if !ok {
+		log.Warningf("release lease %s failed: lease has been modified by others", e.name)
+	} else {
+		log.Infof("lease %s released by %s", e.name, e.id)
+	}
+	if e.callbacks.onStoppedLeading != nil {
+		e.callbacks.onStoppedLeading()
+	}
+}
+
+func (e *leaseElector) IsLeader() bool {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.isLeader
+}
+
+// FencingToken returns the fencing token of the current leadership, 0 if not the leader
+func (e *leaseElector) FencingToken() uint64 {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	if !e.isLeader {
+		return 0
+	}
+	return e.observedLease.FencingToken
+}
+
+func (e *leaseElector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.retryPeriod)
+	defer ticker.Stop()
+	for {
+		e.tick()
+		select {
+		case <-ctx.Done():
+			e.release()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func newMySQLLeaseStore(cfg *config.ControllerConfig) (*mysqlLeaseStore, error) {
+	// the election runs before mysql migration, create the database if it does not exist,
+	// migration treats a database without db_version table as a new deployment
+	connector, err := mysqlcommon.GetConnector(cfg.MySqlCfg, false, cfg.MySqlCfg.TimeOut, false)
+	if err != nil {
+		return nil, err
+	}
+	db, err := mysqlcommon.InitSession(cfg.MySqlCfg, connector)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Exec(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`", cfg.MySqlCfg.Database)).Error
+	if sqlDB, e := db.DB(); e == nil {
+		sqlDB.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	db, err = mysqlcommon.GetSession(cfg.MySqlCfg)
+	if err != nil {
+		return nil, err
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.SetMaxIdleConns(1)
+		sqlDB.SetMaxOpenConns(2)
+	}
+	if err = createLeaseTableIfNotExists(db); err != nil {
+		return nil, err
+	}
+	return &mysqlLeaseStore{db: db}, nil
+}
+
+// the lease table is created by migration as other tables, but the election runs before migration,
+// so it is created here with the same statement in init.sql if it does not exist yet
+func createLeaseTableIfNotExists(db *gorm.DB) error {
+	initSQL, err := os.ReadFile(fmt.Sprintf("%s/init.sql", migratorcommon.SQL_FILE_DIR))
+	if err != nil {
+		return err
+	}
+	statement, err := getLeaseTableStatement(string(initSQL))
+	if err != nil {
+		return err
+	}
+	return db.Exec(statement).Error
+}
+
+func getLeaseTableStatement(initSQL string) (string, error) {
+	start := strings.Index(initSQL, "CREATE TABLE IF NOT EXISTS "+ELECTION_LEASE_TABLE+" (")
+	if start < 0 {
+		return "", fmt.Errorf("statement of table %s not found in init.sql", ELECTION_LEASE_TABLE)
+	}
+	end := strings.Index(initSQL[start:], ";")
+	if end < 0 {
+		return "", fmt.Errorf("statement of table %s in init.sql is not terminated", ELECTION_LEASE_TABLE)
+	}
+	return initSQL[start : start+end], nil
+}
+
+// fencer checks the fencing token of leader-only writes against the lease row
+type fencer struct {
+	db *gorm.DB
+	// qualified by the database of the lease, so that the row can be locked in transactions of other databases
+	table string
+	name  string
+}
+
+func (f *fencer) check(db *gorm.DB, token uint64) error {
+	if token == 0 {
+		return fmt.Errorf("%w: not the leader", ErrStaleFencingToken)
+	}
+	var lease ElectionLease
+	if err := db.Table(f.table).Where("name = ?", f.name).Take(&lease).Error; err != nil {
+		return err
+	}
+	if lease.FencingToken != token {
+		return fmt.Errorf("%w: %d, the latest is %d", ErrStaleFencingToken, token, lease.FencingToken)
+	}
+	return nil
+}
+
+var currentFencer atomic.Value // *fencer, stored when the mysql election starts
+
+func getFencer() (*fencer, error) {
+	f, ok := currentFencer.Load().(*fencer)
+	if !ok {
+		return nil, fmt.Errorf("%w: election not started", ErrStaleFencingToken)
+	}
+	return f, nil
+}
+
+// CheckFencingToken returns ErrStaleFencingToken if the leadership of this controller has changed hands,
+// it is for leader-only writes which can not be done in a transaction, e.g. DDL of migration.
+// Always returns nil when elected by kubernetes, which has no fencing token.
+func CheckFencingToken() error {
+	if !IsMySQLBackend() {
+		return nil
+	}
+	f, err := getFencer()
+	if err != nil {
+		return err
+	}
+	return f.check(f.db, GetFencingToken())
+}
+
+// FencedTransaction runs fc in a transaction of db, which locks the lease row and checks the fencing
+// token first, so the leader-only writes in fc are never committed after the leadership has changed hands.
+// db can be of any database in the same mysql as the lease. fc runs with db directly when elected by kubernetes.
+func FencedTransaction(db *gorm.DB, fc func(tx *gorm.DB) error) error {
+	if !IsMySQLBackend() {
+		return fc(db)
+	}
+	f, err := getFencer()
+	if err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := f.check(tx.Clauses(clause.Locking{Strength: "UPDATE"}), GetFencingToken()); err != nil {
+			return err
+		}
+		return fc(tx)
+	})
+}
+
+func startMySQLElection(ctx context.Context, cfg *config.ControllerConfig) {
+	id := getID()
+	log.Infof("election id is %s, backend is mysql", id)
+	if common.GetPodIP() == "127.0.0.1" {
+		// the leader is identified by pod ip, which must be reachable by the other controllers
+		log.Warningf("env %s is not set, controllers on different machines can not tell each other apart", common.POD_IP_KEY)
+	}
+
+	var store *mysqlLeaseStore
+	var err error
+	for {
+		if store, err = newMySQLLeaseStore(cfg); err == nil {
+			break
+		}
+		log.Errorf("init mysql election lease failed: %s", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+
+	currentFencer.Store(&fencer{
+		db:    store.db,
+		table: fmt.Sprintf("`%s`.%s", cfg.MySqlCfg.Database, ELECTION_LEASE_TABLE),
+		name:  cfg.ElectionName,
+	})
+	elector := newLeaseElector(cfg.ElectionName, id, store,
+		time.Duration(cfg.ElectionLeaseDuration)*time.Second,
+		time.Duration(cfg.ElectionRenewDeadline)*time.Second,
+		time.Duration(cfg.ElectionRetryPeriod)*time.Second,
+		leaseCallbacks{
+			onStartedLeading: func(lease ElectionLease) {
+				acquireTime = lease.AcquireTime
+				atomic.StoreUint64(&fencingToken, lease.FencingToken)
+				leaderData.SetLeader(id)
+			},
+			onStoppedLeading: func() {
+				atomic.StoreUint64(&fencingToken, 0)
+			},
+			onNewLeader: func(lease ElectionLease) {
+				if lease.HolderIdentity != "" {
+					acquireTime = lease.AcquireTime
+				}
+				leaderData.SetLeader(lease.HolderIdentity)
+				log.Infof("new leader elected: %s", lease.HolderIdentity)
+			},
+		})
+	wg := utils.GetWaitGroupInCtx(ctx)
+	wg.Add(1)
+	defer wg.Done()
+	elector.Run(ctx)
+}
@@ -0,0 +1,370 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package election
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type memLeaseStore struct {
+	mutex  sync.Mutex
+	leases map[string]ElectionLease
+	err    error
+}
+
+func newMemLeaseStore() *memLeaseStore {
+	return &memLeaseStore{leases: make(map[string]ElectionLease)}
+}
+
+func (s *memLeaseStore) get(name string) (*ElectionLease, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	lease, ok := s.leases[name]
+	if !ok {
+		return nil, nil
+	}
+	return &lease, nil
+}
+
+func (s *memLeaseStore) create(lease *ElectionLease) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.err != nil {
+		return false, s.err
+	}
+	if _, ok := s.leases[lease.Name]; ok {
+		return false, nil
+	}
+	s.leases[lease.Name] = *lease
+	return true, nil
+}
+
+func (s *memLeaseStore) update(lease *ElectionLease, revision uint64) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.err != nil {
+		return false, s.err
+	}
+	if old, ok := s.leases[lease.Name]; !ok || old.Revision != revision {
+		return false, nil
+	}
+	s.leases[lease.Name] = *lease
+	return true, nil
+}
+
+func newSQLiteLeaseStore(t *testing.T) *mysqlLeaseStore {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "election.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&ElectionLease{}); err != nil {
+		t.Fatal(err)
+	}
+	return &mysqlLeaseStore{db: db}
+}
+
+func TestMySQLLeaseStore(t *testing.T) {
+	store := newSQLiteLeaseStore(t)
+
+	lease, err := store.get("deepflow-server")
+	assert.Nil(t, err)
+	assert.Nil(t, lease)
+
+	ok, err := store.create(&ElectionLease{Name: "deepflow-server", HolderIdentity: "a", FencingToken: 1, Revision: 1, LeaseDuration: 15, AcquireTime: 1700000000, RenewTime: 1700000000000})
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	// the one who loses the race to create the lease gets false instead of a duplicate key error
+	ok, err = store.create(&ElectionLease{Name: "deepflow-server", HolderIdentity: "b", FencingToken: 1, Revision: 1})
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	lease, err = store.get("deepflow-server")
+	assert.Nil(t, err)
+	assert.Equal(t, ElectionLease{Name: "deepflow-server", HolderIdentity: "a", FencingToken: 1, Revision: 1, LeaseDuration: 15, AcquireTime: 1700000000, RenewTime: 1700000000000}, *lease)
+
+	renewed := *lease
+	renewed.Revision = 2
+	renewed.RenewTime = 1700000002000
+	ok, err = store.update(&renewed, 1)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	// a write based on a stale revision is rejected
+	stale := *lease
+	stale.HolderIdentity = "b"
+	stale.Revision = 2
+	ok, err = store.update(&stale, 1)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	lease, _ = store.get("deepflow-server")
+	assert.Equal(t, renewed, *lease)
+
+	// zero values are written as well
+	released := renewed
+	released.HolderIdentity = ""
+	released.Revision = 3
+	ok, err = store.update(&released, 2)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	lease, _ = store.get("deepflow-server")
+	assert.Equal(t, released, *lease)
+
+	ok, err = store.update(&ElectionLease{Name: "not-exist", Revision: 1}, 0)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func TestGetLeaseTableStatement(t *testing.T) {
+	initSQL, err := os.ReadFile("../db/mysql/migration/rawsql/init.sql")
+	assert.Nil(t, err)
+	statement, err := getLeaseTableStatement(string(initSQL))
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(statement, "CREATE TABLE IF NOT EXISTS election_lease ("))
+	assert.True(t, strings.HasSuffix(statement, "DEFAULT CHARSET=utf8 COMMENT='lease of controller leader election, created by the election before migration'"))
+	// upgraded databases get the same table
+	issu, err := os.ReadFile("../db/mysql/migration/rawsql/issu/6.6.1.18.sql")
+	assert.Nil(t, err)
+	assert.Contains(t, string(issu), statement+";")
+
+	_, err = getLeaseTableStatement("CREATE TABLE IF NOT EXISTS db_version (version CHAR(64));")
+	assert.NotNil(t, err)
+}
+
+func TestFencedTransaction(t *testing.T) {
+	store := newSQLiteLeaseStore(t)
+	ok, err := store.create(&ElectionLease{Name: "deepflow-server", HolderIdentity: "a", FencingToken: 2, Revision: 1})
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Nil(t, store.db.Exec("CREATE TABLE leader_write (id INTEGER PRIMARY KEY)").Error)
+
+	defer SetBackend(backend)
+	SetBackend(ELECTION_BACKEND_MYSQL)
+	defer atomic.StoreUint64(&fencingToken, 0)
+	currentFencer.Store(&fencer{db: store.db, table: ELECTION_LEASE_TABLE, name: "deepflow-server"})
+	write := func(id int) error {
+		return FencedTransaction(store.db, func(tx *gorm.DB) error {
+			return tx.Exec("INSERT INTO leader_write (id) VALUES (?)", id).Error
+		})
+	}
+
+	atomic.StoreUint64(&fencingToken, 2)
+	assert.Nil(t, CheckFencingToken())
+	assert.Nil(t, write(1))
+
+	// the leadership has been taken over by another controller
+	atomic.StoreUint64(&fencingToken, 1)
+	assert.True(t, errors.Is(CheckFencingToken(), ErrStaleFencingToken))
+	assert.True(t, errors.Is(write(2), ErrStaleFencingToken))
+	atomic.StoreUint64(&fencingToken, 0)
+	assert.True(t, errors.Is(write(3), ErrStaleFencingToken))
+
+	var ids []int
+	store.db.Raw("SELECT id FROM leader_write").Scan(&ids)
+	assert.Equal(t, []int{1}, ids)
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Step(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+type electorRecorder struct {
+	started int
+	stopped int
+	leaders []string
+}
+
+func newTestElector(id string, store leaseStore, clock *fakeClock, recorder *electorRecorder) *leaseElector {
+	e := newLeaseElector("deepflow-server", id, store, 15*time.Second, 10*time.Second, 2*time.Second, leaseCallbacks{
+		onStartedLeading: func(lease ElectionLease) { recorder.started++ },
+		onStoppedLeading: func() { recorder.stopped++ },
+		onNewLeader:      func(lease ElectionLease) { recorder.leaders = append(recorder.leaders, lease.HolderIdentity) },
+	})
+	e.now = clock.Now
+	return e
+}
+
+func TestLeaseElectorAcquire(t *testing.T) {
+	store := newMemLeaseStore()
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	ra, rb := &electorRecorder{}, &electorRecorder{}
+	a := newTestElector("a", store, clock, ra)
+	b := newTestElector("b", store, clock, rb)
+
+	a.tick()
+	assert.True(t, a.IsLeader())
+	assert.Equal(t, uint64(1), a.FencingToken())
+	assert.Equal(t, 1, ra.started)
+	assert.Equal(t, []string{"a"}, ra.leaders)
+
+	b.tick()
+	assert.False(t, b.IsLeader())
+	assert.Equal(t, uint64(0), b.FencingToken())
+	assert.Equal(t, []string{"a"}, rb.leaders)
+
+	// renewing keeps the fencing token and the acquire time
+	clock.Step(2 * time.Second)
+	a.tick()
+	b.tick()
+	assert.True(t, a.IsLeader())
+	assert.False(t, b.IsLeader())
+	lease, _ := store.get("deepflow-server")
+	assert.Equal(t, "a", lease.HolderIdentity)
+	assert.Equal(t, uint64(1), lease.FencingToken)
+	assert.Equal(t, uint64(2), lease.Revision)
+	assert.Equal(t, int64(1700000000), lease.AcquireTime)
+	assert.Equal(t, int64(1700000002000), lease.RenewTime)
+	assert.Equal(t, 1, ra.started)
+}
+
+func TestLeaseElectorTakeOverExpiredLease(t *testing.T) {
+	store := newMemLeaseStore()
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	ra, rb := &electorRecorder{}, &electorRecorder{}
+	a := newTestElector("a", store, clock, ra)
+	b := newTestElector("b", store, clock, rb)
+
+	a.tick()
+	b.tick()
+	// a stops renewing, b waits for the lease duration since it last saw the lease change
+	clock.Step(14 * time.Second)
+	b.tick()
+	assert.False(t, b.IsLeader())
+	clock.Step(2 * time.Second)
+	b.tick()
+	assert.True(t, b.IsLeader())
+	assert.Equal(t, uint64(2), b.FencingToken())
+	assert.Equal(t, []string{"a", "b"}, rb.leaders)
+
+	// a finds it has lost the lease and steps down at once
+	a.tick()
+	assert.False(t, a.IsLeader())
+	assert.Equal(t, 1, ra.stopped)
+	assert.Equal(t, []string{"a", "b"}, ra.leaders)
+	lease, _ := store.get("deepflow-server")
+	assert.Equal(t, int64(1700000016), lease.AcquireTime)
+}
+
+func TestLeaseElectorRenewedLeaseNotExpired(t *testing.T) {
+	store := newMemLeaseStore()
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	a := newTestElector("a", store, clock, &electorRecorder{})
+	b := newTestElector("b", store, clock, &electorRecorder{})
+
+	for i := 0; i < 20; i++ {
+		a.tick()
+		b.tick()
+		clock.Step(2 * time.Second)
+	}
+	assert.True(t, a.IsLeader())
+	assert.False(t, b.IsLeader())
+	assert.Equal(t, uint64(1), a.FencingToken())
+}
+
+func TestLeaseElectorRelease(t *testing.T) {
+	store := newMemLeaseStore()
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	ra, rb := &electorRecorder{}, &electorRecorder{}
+	a := newTestElector("a", store, clock, ra)
+	b := newTestElector("b", store, clock, rb)
+
+	a.tick()
+	b.tick()
+	a.release()
+	assert.False(t, a.IsLeader())
+	assert.Equal(t, 1, ra.stopped)
+	lease, _ := store.get("deepflow-server")
+	assert.Equal(t, "", lease.HolderIdentity)
+	assert.Equal(t, uint64(1), lease.FencingToken)
+
+	// a released lease is taken over without waiting for it to expire
+	b.tick()
+	assert.True(t, b.IsLeader())
+	assert.Equal(t, uint64(2), b.FencingToken())
+	assert.Equal(t, []string{"a", "b"}, rb.leaders)
+
+	// releasing by a non leader changes nothing
+	a.release()
+	assert.Equal(t, 1, ra.stopped)
+	lease, _ = store.get("deepflow-server")
+	assert.Equal(t, "b", lease.HolderIdentity)
+}
+
+func TestLeaseElectorWithMySQLLeaseStore(t *testing.T) {
+	store := newSQLiteLeaseStore(t)
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	a := newTestElector("a", store, clock, &electorRecorder{})
+	b := newTestElector("b", store, clock, &electorRecorder{})
+
+	a.tick()
+	b.tick()
+	assert.True(t, a.IsLeader())
+	assert.False(t, b.IsLeader())
+	clock.Step(16 * time.Second)
+	b.tick()
+	a.tick()
+	assert.False(t, a.IsLeader())
+	assert.True(t, b.IsLeader())
+	assert.Equal(t, uint64(2), b.FencingToken())
+	lease, _ := store.get("deepflow-server")
+	assert.Equal(t, "b", lease.HolderIdentity)
+	assert.Equal(t, uint64(2), lease.Revision)
+}
+
+func TestLeaseElectorRenewDeadline(t *testing.T) {
+	store := newMemLeaseStore()
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	ra := &electorRecorder{}
+	a := newTestElector("a", store, clock, ra)
+
+	a.tick()
+	store.err = errors.New("connection refused")
+	clock.Step(10 * time.Second)
+	a.tick()
+	assert.True(t, a.IsLeader())
+	clock.Step(2 * time.Second)
+	a.tick()
+	assert.False(t, a.IsLeader())
+	assert.Equal(t, 1, ra.stopped)
+	assert.Equal(t, []string{"a", ""}, ra.leaders)
+
+	store.err = nil
+	a.tick()
+	assert.True(t, a.IsLeader())
+	assert.Equal(t, 2, ra.started)
+	assert.Equal(t, uint64(1), a.FencingToken())
+	assert.Equal(t, []string{"a", "", "a"}, ra.leaders)
+}
@@ -19,7 +19,6 @@ package election
 import (
 	"errors"
 	"fmt"
-	"os"
 	"strings"
 
 	"github.com/deepflowio/deepflow/server/controller/common"
@@ -28,11 +27,11 @@ import (
 // 功能：判断当前控制器是否为masterController
 func IsMasterController() (bool, error) {
 	// in standalone mode, the local machine is the master node because of all in one deployment
-	if common.IsStandaloneRunningMode() == true {
+	if isLocalLeader() {
 		return true, nil
 	}
 	// get self host_ip
-	hostIP := os.Getenv(common.POD_IP_KEY)
+	hostIP := common.GetPodIP()
 	if len(hostIP) == 0 {
 		log.Error("pod_ip is null")
 		return false, errors.New("pod_ip is null")
@@ -50,11 +49,11 @@ func IsMasterController() (bool, error) {
 
 func IsMasterControllerAndReturnIP() (bool, string, error) {
 	// in standalone mode, the local machine is the master node because of all in one deployment
-	if common.IsStandaloneRunningMode() == true {
+	if isLocalLeader() {
 		return true, common.GetPodIP(), nil
 	}
 	// get self host_ip
-	hostIP := os.Getenv(common.POD_IP_KEY)
+	hostIP := common.GetPodIP()
 	if len(hostIP) == 0 {
 		log.Error("pod_ip is null")
 		return false, "", errors.New("pod_ip is null")
@@ -25,5 +25,7 @@ import (
 func GetRegistrants(cfg *config.ControllerConfig) []registrant.Registrant {
 	return []registrant.Registrant{
 		resource.NewVPC(),
+		resource.NewInventory(),
+		resource.NewHistory(),
 	}
 }
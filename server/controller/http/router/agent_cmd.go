@@ -86,6 +86,10 @@ func (a *AgentCMD) RegisterTo(e *gin.Engine) {
 
 	agentRoutes.GET("/cmd", forwardToServerConnectedByAgent(), a.getCMDAndNamespaceHandler())
 	agentRoutes.POST("/cmd/run", forwardToServerConnectedByAgent(), a.cmdRunHandler())
+
+	e.POST("/v1/agents/cmd/run", a.batchCMDRunHandler())
+	e.GET("/v1/agent-cmd-audits/", a.getCMDAuditsHandler())
+	e.GET("/v1/agent-cmd-audits/:id/", a.getCMDAuditHandler())
 }
 
 func forwardToServerConnectedByAgent() gin.HandlerFunc {
@@ -258,15 +262,9 @@ func (a *AgentCMD) cmdRunHandler() gin.HandlerFunc {
 			BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
 			return
 		}
-		// Profile commands and probe commands are available to everyone.
-		userType, _ := c.Get(common.HEADER_KEY_X_USER_TYPE)
-		if !(userType == common.USER_TYPE_SUPER_ADMIN || userType == common.USER_TYPE_ADMIN) {
-			_, ok1 := profileCommandMap[req.CMD]
-			_, ok2 := probeCommandMap[req.CMD]
-			if !(ok1 || ok2) {
-				StatusForbiddenResponse(c, fmt.Sprintf("only super admin and admin can operate command(%s)", req.CMD))
-				return
-			}
+		if !isCMDPermitted(c, req.CMD) {
+			StatusForbiddenResponse(c, fmt.Sprintf("only super admin and admin can operate command(%s)", req.CMD))
+			return
 		}
 
 		agentReq := trident.RemoteExecRequest{
@@ -288,7 +286,12 @@ func (a *AgentCMD) cmdRunHandler() gin.HandlerFunc {
 			BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
 			return
 		}
-		content, err := service.RunAgentCMD(a.cfg.AgentCommandTimeout, orgID.(int), agentID, &agentReq, req.CMD)
+		timeout, err := service.GetAgentCMDTimeout(a.cfg.AgentCommandTimeout, req.Timeout)
+		if err != nil {
+			BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+			return
+		}
+		content, err := service.RunAgentCMD(timeout, orgID.(int), agentID, &agentReq, req.CMD)
 		if err != nil {
 			InternalErrorResponse(c, content, httpcommon.SERVER_ERROR, err.Error())
 			return
@@ -302,6 +305,71 @@ func (a *AgentCMD) cmdRunHandler() gin.HandlerFunc {
 	}
 }
 
+// isCMDPermitted returns whether the user can run the command,
+// profile commands and probe commands are available to everyone.
+func isCMDPermitted(c *gin.Context, cmd string) bool {
+	userType, _ := c.Get(common.HEADER_KEY_X_USER_TYPE)
+	if userType == common.USER_TYPE_SUPER_ADMIN || userType == common.USER_TYPE_ADMIN {
+		return true
+	}
+	_, ok1 := profileCommandMap[cmd]
+	_, ok2 := probeCommandMap[cmd]
+	return ok1 || ok2
+}
+
+func getCMDOperator(c *gin.Context) service.AgentCMDOperator {
+	userID, _ := c.Get(common.HEADER_KEY_X_USER_ID)
+	userType, _ := c.Get(common.HEADER_KEY_X_USER_TYPE)
+	operator := service.AgentCMDOperator{SourceIP: c.ClientIP()}
+	operator.UserID, _ = userID.(int)
+	operator.UserType, _ = userType.(int)
+	return operator
+}
+
+func (a *AgentCMD) batchCMDRunHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req := model.AgentBatchExecReq{}
+		if err := c.ShouldBindBodyWith(&req, binding.JSON); err != nil {
+			BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+			return
+		}
+		if !isCMDPermitted(c, req.CMD) {
+			StatusForbiddenResponse(c, fmt.Sprintf("only super admin and admin can operate command(%s)", req.CMD))
+			return
+		}
+
+		orgID, _ := c.Get(common.HEADER_KEY_X_ORG_ID)
+		data, err := service.RunAgentBatchCMD(a.cfg.AgentCommandTimeout, orgID.(int), getCMDOperator(c), &req)
+		JsonResponse(c, data, err)
+	}
+}
+
+func (a *AgentCMD) getCMDAuditsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := model.AgentCMDAuditQuery{}
+		if err := c.ShouldBindQuery(&query); err != nil {
+			BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+			return
+		}
+		orgID, _ := c.Get(common.HEADER_KEY_X_ORG_ID)
+		data, err := service.GetAgentCMDAudits(orgID.(int), getCMDOperator(c), query)
+		JsonResponse(c, data, err)
+	}
+}
+
+func (a *AgentCMD) getCMDAuditHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+			return
+		}
+		orgID, _ := c.Get(common.HEADER_KEY_X_ORG_ID)
+		data, err := service.GetAgentCMDAudit(orgID.(int), getCMDOperator(c), id)
+		JsonResponse(c, data, err)
+	}
+}
+
 func sendAsFile(c *gin.Context, fileName string, content *bytes.Buffer) {
 	c.Writer.Header().Set("Content-Type", "application/octet-stream")
 	if fileName != "" {
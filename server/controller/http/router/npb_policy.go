@@ -0,0 +1,83 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	httpcommon "github.com/deepflowio/deepflow/server/controller/http/common"
+	. "github.com/deepflowio/deepflow/server/controller/http/router/common"
+	"github.com/deepflowio/deepflow/server/controller/http/service"
+	"github.com/deepflowio/deepflow/server/controller/model"
+)
+
+type NpbPolicy struct{}
+
+func NewNpbPolicy() *NpbPolicy {
+	return new(NpbPolicy)
+}
+
+func (p *NpbPolicy) RegisterTo(e *gin.Engine) {
+	e.GET("/v1/npb-policies/:lcuuid/", getNpbPolicy)
+	e.GET("/v1/npb-policies/", getNpbPolicies)
+	e.POST("/v1/npb-policies/", createNpbPolicy)
+	e.PATCH("/v1/npb-policies/:lcuuid/", updateNpbPolicy)
+	e.DELETE("/v1/npb-policies/:lcuuid/", deleteNpbPolicy)
+}
+
+func getNpbPolicy(c *gin.Context) {
+	args := map[string]interface{}{"lcuuid": c.Param("lcuuid")}
+	data, err := service.NewNpbPolicy(httpcommon.GetUserInfo(c)).Get(args)
+	JsonResponse(c, data, err)
+}
+
+func getNpbPolicies(c *gin.Context) {
+	args := make(map[string]interface{})
+	for _, key := range []string{"name", "state", "team_id"} {
+		if value, ok := c.GetQuery(key); ok {
+			args[key] = value
+		}
+	}
+	data, err := service.NewNpbPolicy(httpcommon.GetUserInfo(c)).Get(args)
+	JsonResponse(c, data, err)
+}
+
+func createNpbPolicy(c *gin.Context) {
+	var policyCreate model.NpbPolicyCreate
+	if err := c.ShouldBindBodyWith(&policyCreate, binding.JSON); err != nil {
+		BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+		return
+	}
+	data, err := service.NewNpbPolicy(httpcommon.GetUserInfo(c)).Create(policyCreate)
+	JsonResponse(c, data, err)
+}
+
+func updateNpbPolicy(c *gin.Context) {
+	var policyUpdate model.NpbPolicyUpdate
+	if err := c.ShouldBindBodyWith(&policyUpdate, binding.JSON); err != nil {
+		BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+		return
+	}
+	data, err := service.NewNpbPolicy(httpcommon.GetUserInfo(c)).Update(c.Param("lcuuid"), policyUpdate)
+	JsonResponse(c, data, err)
+}
+
+func deleteNpbPolicy(c *gin.Context) {
+	data, err := service.NewNpbPolicy(httpcommon.GetUserInfo(c)).Delete(c.Param("lcuuid"))
+	JsonResponse(c, data, err)
+}
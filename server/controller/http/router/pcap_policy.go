@@ -0,0 +1,83 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	httpcommon "github.com/deepflowio/deepflow/server/controller/http/common"
+	. "github.com/deepflowio/deepflow/server/controller/http/router/common"
+	"github.com/deepflowio/deepflow/server/controller/http/service"
+	"github.com/deepflowio/deepflow/server/controller/model"
+)
+
+type PcapPolicy struct{}
+
+func NewPcapPolicy() *PcapPolicy {
+	return new(PcapPolicy)
+}
+
+func (p *PcapPolicy) RegisterTo(e *gin.Engine) {
+	e.GET("/v1/pcap-policies/:lcuuid/", getPcapPolicy)
+	e.GET("/v1/pcap-policies/", getPcapPolicies)
+	e.POST("/v1/pcap-policies/", createPcapPolicy)
+	e.PATCH("/v1/pcap-policies/:lcuuid/", updatePcapPolicy)
+	e.DELETE("/v1/pcap-policies/:lcuuid/", deletePcapPolicy)
+}
+
+func getPcapPolicy(c *gin.Context) {
+	args := map[string]interface{}{"lcuuid": c.Param("lcuuid")}
+	data, err := service.NewPcapPolicy(httpcommon.GetUserInfo(c)).Get(args)
+	JsonResponse(c, data, err)
+}
+
+func getPcapPolicies(c *gin.Context) {
+	args := make(map[string]interface{})
+	for _, key := range []string{"name", "state", "team_id"} {
+		if value, ok := c.GetQuery(key); ok {
+			args[key] = value
+		}
+	}
+	data, err := service.NewPcapPolicy(httpcommon.GetUserInfo(c)).Get(args)
+	JsonResponse(c, data, err)
+}
+
+func createPcapPolicy(c *gin.Context) {
+	var policyCreate model.PcapPolicyCreate
+	if err := c.ShouldBindBodyWith(&policyCreate, binding.JSON); err != nil {
+		BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+		return
+	}
+	data, err := service.NewPcapPolicy(httpcommon.GetUserInfo(c)).Create(policyCreate)
+	JsonResponse(c, data, err)
+}
+
+func updatePcapPolicy(c *gin.Context) {
+	var policyUpdate model.PcapPolicyUpdate
+	if err := c.ShouldBindBodyWith(&policyUpdate, binding.JSON); err != nil {
+		BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+		return
+	}
+	data, err := service.NewPcapPolicy(httpcommon.GetUserInfo(c)).Update(c.Param("lcuuid"), policyUpdate)
+	JsonResponse(c, data, err)
+}
+
+func deletePcapPolicy(c *gin.Context) {
+	data, err := service.NewPcapPolicy(httpcommon.GetUserInfo(c)).Delete(c.Param("lcuuid"))
+	JsonResponse(c, data, err)
+}
@@ -0,0 +1,61 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resource
+
+import (
+	"github.com/gin-gonic/gin"
+
+	ctrlcommon "github.com/deepflowio/deepflow/server/controller/common"
+	httpcommon "github.com/deepflowio/deepflow/server/controller/http/common"
+	"github.com/deepflowio/deepflow/server/controller/http/router/common"
+	"github.com/deepflowio/deepflow/server/controller/http/service/resource"
+	"github.com/deepflowio/deepflow/server/controller/model"
+)
+
+// History provides point-in-time query of resources from snapshots saved by recorder
+type History struct{}
+
+func NewHistory() *History {
+	return new(History)
+}
+
+func (h *History) RegisterTo(e *gin.Engine) {
+	e.GET("/v2/resource-history/:type/", getResourceHistory)
+	e.GET("/v2/ip-history/", getIPHistory)
+}
+
+func getResourceHistory(c *gin.Context) {
+	var query model.ResourceHistoryQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		common.BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+		return
+	}
+	orgID, _ := c.Get(ctrlcommon.HEADER_KEY_X_ORG_ID)
+	data, err := resource.GetResourceHistory(orgID.(int), c.Param("type"), query)
+	common.JsonResponse(c, data, err)
+}
+
+func getIPHistory(c *gin.Context) {
+	var query model.IPHistoryQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		common.BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+		return
+	}
+	orgID, _ := c.Get(ctrlcommon.HEADER_KEY_X_ORG_ID)
+	data, err := resource.GetIPHistory(orgID.(int), query)
+	common.JsonResponse(c, data, err)
+}
@@ -0,0 +1,54 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resource
+
+import (
+	"github.com/gin-gonic/gin"
+
+	ctrlcommon "github.com/deepflowio/deepflow/server/controller/common"
+	httpcommon "github.com/deepflowio/deepflow/server/controller/http/common"
+	"github.com/deepflowio/deepflow/server/controller/http/router/common"
+	"github.com/deepflowio/deepflow/server/controller/http/service/resource"
+	"github.com/deepflowio/deepflow/server/controller/model"
+)
+
+// Inventory provides read-only access to all resources recorded by recorder
+type Inventory struct{}
+
+func NewInventory() *Inventory {
+	return new(Inventory)
+}
+
+func (i *Inventory) RegisterTo(e *gin.Engine) {
+	e.GET("/v2/inventory/", getInventoryTypes)
+	e.GET("/v2/inventory/:type/", getInventory)
+}
+
+func getInventoryTypes(c *gin.Context) {
+	common.JsonResponse(c, resource.GetInventoryTypes(), nil)
+}
+
+func getInventory(c *gin.Context) {
+	var query model.InventoryQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		common.BadRequestResponse(c, httpcommon.INVALID_PARAMETERS, err.Error())
+		return
+	}
+	orgID, _ := c.Get(ctrlcommon.HEADER_KEY_X_ORG_ID)
+	data, err := resource.GetInventory(orgID.(int), c.Param("type"), query)
+	common.JsonResponse(c, data, err)
+}
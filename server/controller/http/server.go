@@ -113,6 +113,8 @@ func (s *Server) appendRegistrant() []registrant.Registrant {
 		router.NewVtapRepo(),
 		router.NewPlugin(),
 		router.NewMail(),
+		router.NewPcapPolicy(),
+		router.NewNpbPolicy(),
 		router.NewDatabase(s.controllerConfig),
 		router.NewAgentCMD(s.controllerConfig),
 		// icon
@@ -0,0 +1,405 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/deepflowio/deepflow/message/trident"
+	ctrlcommon "github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	mysqlmodel "github.com/deepflowio/deepflow/server/controller/db/mysql/model"
+	httpcommon "github.com/deepflowio/deepflow/server/controller/http/common"
+	servicecommon "github.com/deepflowio/deepflow/server/controller/http/service/common"
+	"github.com/deepflowio/deepflow/server/controller/model"
+)
+
+const (
+	AGENT_BATCH_CMD_CONCURRENCY_DEFAULT = 20
+	AGENT_CMD_AUDIT_OUTPUT_LIMIT        = 1024
+	AGENT_CMD_AUDIT_LIMIT_DEFAULT       = 100
+	AGENT_CMD_TIMEOUT_MAX_MULTIPLE      = 10
+
+	AGENT_EXEC_STATUS_SUCCESS = "SUCCESS"
+	AGENT_EXEC_STATUS_FAILED  = "FAILED"
+)
+
+// AgentCMDOperator is who runs the command, recorded in audit
+type AgentCMDOperator struct {
+	UserID   int
+	UserType int
+	SourceIP string
+}
+
+// GetAgentCMDTimeout returns timeout, or defaultTimeout (agent-cmd-timeout) if it is not set,
+// timeout is at most AGENT_CMD_TIMEOUT_MAX_MULTIPLE times of defaultTimeout.
+func GetAgentCMDTimeout(defaultTimeout, timeout int) (int, error) {
+	if timeout <= 0 {
+		return defaultTimeout, nil
+	}
+	if max := defaultTimeout * AGENT_CMD_TIMEOUT_MAX_MULTIPLE; timeout > max {
+		return 0, servicecommon.NewError(httpcommon.INVALID_PARAMETERS,
+			fmt.Sprintf("timeout (%ds) exceeds %d times of agent-cmd-timeout (%ds)", timeout, AGENT_CMD_TIMEOUT_MAX_MULTIPLE, defaultTimeout))
+	}
+	return timeout, nil
+}
+
+type agentExecutor func(agent *mysqlmodel.VTap) (string, error)
+
+// RunAgentBatchCMD runs the same command on all agents selected by agent group and label selector,
+// at most `concurrency` agents at the same time, the whole execution is recorded in agent_cmd_audit.
+func RunAgentBatchCMD(defaultTimeout, orgID int, operator AgentCMDOperator, req *model.AgentBatchExecReq) (*model.AgentBatchExecResp, error) {
+	if req.AgentGroup == "" && req.LabelSelector == "" {
+		return nil, servicecommon.NewError(httpcommon.INVALID_PARAMETERS, "agent_group or label_selector is required")
+	}
+	requirements, err := parseAgentSelector(req.LabelSelector)
+	if err != nil {
+		return nil, servicecommon.NewError(httpcommon.INVALID_PARAMETERS, err.Error())
+	}
+	dbInfo, err := mysql.GetDB(orgID)
+	if err != nil {
+		return nil, err
+	}
+	agents, err := selectAgents(dbInfo, req.AgentGroup, requirements)
+	if err != nil {
+		return nil, err
+	}
+	if len(agents) == 0 {
+		return nil, servicecommon.NewError(httpcommon.RESOURCE_NOT_FOUND, "no agent matched")
+	}
+
+	timeout, err := GetAgentCMDTimeout(defaultTimeout, req.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	concurrency := req.Concurrency
+	if concurrency <= 0 {
+		concurrency = AGENT_BATCH_CMD_CONCURRENCY_DEFAULT
+	}
+
+	reqBytes, _ := json.Marshal(req)
+	audit := &mysqlmodel.AgentCMDAudit{
+		UserID:     operator.UserID,
+		UserType:   operator.UserType,
+		SourceIP:   operator.SourceIP,
+		CMD:        req.CMD,
+		Request:    string(reqBytes),
+		AgentCount: len(agents),
+	}
+	if err := dbInfo.Create(audit).Error; err != nil {
+		return nil, fmt.Errorf("failed to create agent command audit: %s", err.Error())
+	}
+	log.Infof("user(id: %d, type: %d, ip: %s) runs command(%s) on %d agents, audit id: %d",
+		operator.UserID, operator.UserType, operator.SourceIP, req.CMD, len(agents), audit.ID, dbInfo.LogPrefixORGID)
+
+	results := fanOutAgentCMD(agents, concurrency, func(agent *mysqlmodel.VTap) (string, error) {
+		return runAgentCMDAnywhere(timeout, orgID, operator, agent, req)
+	})
+	resp := &model.AgentBatchExecResp{AuditID: audit.ID, CMD: req.CMD, Total: len(results), Results: results}
+	for _, r := range results {
+		if r.Status == AGENT_EXEC_STATUS_SUCCESS {
+			resp.Success++
+		} else {
+			resp.Failure++
+		}
+	}
+
+	finishedAt := time.Now()
+	audit.SuccessCount = resp.Success
+	audit.FailureCount = resp.Failure
+	audit.Result = auditResult(results)
+	audit.FinishedAt = &finishedAt
+	if err := dbInfo.Save(audit).Error; err != nil {
+		log.Errorf("failed to save agent command audit(id: %d): %s", audit.ID, err.Error(), dbInfo.LogPrefixORGID)
+	}
+	return resp, nil
+}
+
+func fanOutAgentCMD(agents []*mysqlmodel.VTap, concurrency int, exec agentExecutor) []*model.AgentExecResult {
+	results := make([]*model.AgentExecResult, len(agents))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, agent := range agents {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, agent *mysqlmodel.VTap) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			start := time.Now()
+			content, err := exec(agent)
+			result := &model.AgentExecResult{
+				AgentID:   agent.ID,
+				AgentName: agent.Name,
+				CtrlIP:    agent.CtrlIP,
+				Status:    AGENT_EXEC_STATUS_SUCCESS,
+				Stdout:    content,
+				Duration:  time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				result.Status = AGENT_EXEC_STATUS_FAILED
+				result.Stderr = err.Error()
+			}
+			results[i] = result
+		}(i, agent)
+	}
+	wg.Wait()
+	return results
+}
+
+// runAgentCMDAnywhere runs command directly if the agent is connected to this controller,
+// otherwise forwards to the controller the agent connected to.
+func runAgentCMDAnywhere(timeout, orgID int, operator AgentCMDOperator, agent *mysqlmodel.VTap, req *model.AgentBatchExecReq) (string, error) {
+	if GetAgentCMDManager(agent.CtrlIP+"-"+agent.CtrlMac) != nil {
+		agentReq := trident.RemoteExecRequest{
+			ExecType:     trident.ExecutionType_RUN_COMMAND.Enum(),
+			CommandIdent: req.CommandIdent,
+			LinuxNsPid:   req.LinuxNsPid,
+			Params:       req.Params,
+		}
+		return RunAgentCMD(timeout, orgID, agent.ID, &agentReq, req.CMD)
+	}
+
+	host := agent.CurControllerIP
+	if host == "" || host == ctrlcommon.NodeIP {
+		host = agent.ControllerIP
+	}
+	if host == "" || host == ctrlcommon.NodeIP {
+		return "", fmt.Errorf("agent(name: %s) is not connected to any controller", agent.Name)
+	}
+	return forwardAgentCMD(host, timeout, orgID, operator, agent.ID, req)
+}
+
+func forwardAgentCMD(host string, timeout, orgID int, operator AgentCMDOperator, agentID int, req *model.AgentBatchExecReq) (string, error) {
+	body, err := json.Marshal(model.RemoteExecReq{
+		RemoteExecRequest: trident.RemoteExecRequest{
+			CommandIdent: req.CommandIdent,
+			LinuxNsPid:   req.LinuxNsPid,
+			Params:       req.Params,
+		},
+		OutputFormat: trident.OutputFormat_TEXT.Enum(),
+		CMD:          req.CMD,
+		Timeout:      timeout,
+	})
+	if err != nil {
+		return "", err
+	}
+	url := fmt.Sprintf("http://%s:%d/v1/agent/%d/cmd/run", host, ctrlcommon.GConfig.HTTPNodePort, agentID)
+	httpReq, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set(ctrlcommon.HEADER_KEY_X_ORG_ID, strconv.Itoa(orgID))
+	httpReq.Header.Set(ctrlcommon.HEADER_KEY_X_USER_ID, strconv.Itoa(operator.UserID))
+	httpReq.Header.Set(ctrlcommon.HEADER_KEY_X_USER_TYPE, strconv.Itoa(operator.UserType))
+
+	client := &http.Client{Timeout: time.Duration(timeout+10) * time.Second}
+	httpResp, err := client.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to forward command to controller(%s): %s", host, err.Error())
+	}
+	defer httpResp.Body.Close()
+	var resp struct {
+		OptStatus   string `json:"OPT_STATUS"`
+		Description string `json:"DESCRIPTION"`
+		Data        string `json:"DATA"`
+	}
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return "", fmt.Errorf("failed to decode response of controller(%s): %s", host, err.Error())
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return resp.Data, errors.New(resp.Description)
+	}
+	return resp.Data, nil
+}
+
+func auditResult(results []*model.AgentExecResult) string {
+	truncated := make([]model.AgentExecResult, 0, len(results))
+	for _, r := range results {
+		t := *r
+		t.Stdout = truncateOutput(t.Stdout, AGENT_CMD_AUDIT_OUTPUT_LIMIT)
+		t.Stderr = truncateOutput(t.Stderr, AGENT_CMD_AUDIT_OUTPUT_LIMIT)
+		truncated = append(truncated, t)
+	}
+	b, _ := json.Marshal(truncated)
+	return string(b)
+}
+
+func truncateOutput(output string, limit int) string {
+	if len(output) <= limit {
+		return output
+	}
+	return output[:limit] + fmt.Sprintf("...(%d bytes truncated)", len(output)-limit)
+}
+
+type agentRequirement struct {
+	key      string
+	notEqual bool
+	value    string
+}
+
+var agentSelectorKeys = map[string]struct{}{
+	"name": {}, "type": {}, "state": {}, "group": {}, "group_lcuuid": {},
+	"ctrl_ip": {}, "ctrl_mac": {}, "controller_ip": {}, "cur_controller_ip": {}, "analyzer_ip": {},
+	"region": {}, "az": {}, "arch": {}, "os": {}, "revision": {}, "launch_server": {},
+}
+
+// parseAgentSelector parses equality-based selector of agent attributes, e.g.: type=7,state=1,region!=xxx
+func parseAgentSelector(selector string) ([]agentRequirement, error) {
+	var requirements []agentRequirement
+	for _, term := range strings.Split(selector, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		var r agentRequirement
+		if parts := strings.SplitN(term, "!=", 2); len(parts) == 2 {
+			r = agentRequirement{key: parts[0], notEqual: true, value: parts[1]}
+		} else if parts := strings.SplitN(strings.Replace(term, "==", "=", 1), "=", 2); len(parts) == 2 {
+			r = agentRequirement{key: parts[0], value: parts[1]}
+		} else {
+			return nil, fmt.Errorf("invalid term (%s) in label selector, key=value or key!=value is expected", term)
+		}
+		r.key, r.value = strings.TrimSpace(r.key), strings.TrimSpace(r.value)
+		if _, ok := agentSelectorKeys[r.key]; !ok {
+			keys := make([]string, 0, len(agentSelectorKeys))
+			for k := range agentSelectorKeys {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			return nil, fmt.Errorf("invalid key (%s) in label selector, supported: %s", r.key, strings.Join(keys, ", "))
+		}
+		requirements = append(requirements, r)
+	}
+	return requirements, nil
+}
+
+func agentAttributes(agent *mysqlmodel.VTap, groupLcuuidToName map[string]string) map[string]string {
+	return map[string]string{
+		"name":              agent.Name,
+		"type":              strconv.Itoa(agent.Type),
+		"state":             strconv.Itoa(agent.State),
+		"group":             groupLcuuidToName[agent.VtapGroupLcuuid],
+		"group_lcuuid":      agent.VtapGroupLcuuid,
+		"ctrl_ip":           agent.CtrlIP,
+		"ctrl_mac":          agent.CtrlMac,
+		"controller_ip":     agent.ControllerIP,
+		"cur_controller_ip": agent.CurControllerIP,
+		"analyzer_ip":       agent.AnalyzerIP,
+		"region":            agent.Region,
+		"az":                agent.AZ,
+		"arch":              agent.Arch,
+		"os":                agent.Os,
+		"revision":          agent.Revision,
+		"launch_server":     agent.LaunchServer,
+	}
+}
+
+func matchAgent(attributes map[string]string, requirements []agentRequirement) bool {
+	for _, r := range requirements {
+		if (attributes[r.key] == r.value) == r.notEqual {
+			return false
+		}
+	}
+	return true
+}
+
+func selectAgents(dbInfo *mysql.DB, agentGroup string, requirements []agentRequirement) ([]*mysqlmodel.VTap, error) {
+	var groups []*mysqlmodel.VTapGroup
+	if err := dbInfo.Find(&groups).Error; err != nil {
+		return nil, err
+	}
+	groupLcuuidToName := make(map[string]string, len(groups))
+	groupLcuuid := ""
+	for _, g := range groups {
+		groupLcuuidToName[g.Lcuuid] = g.Name
+		if agentGroup != "" && (g.Lcuuid == agentGroup || g.Name == agentGroup) {
+			groupLcuuid = g.Lcuuid
+		}
+	}
+	if agentGroup != "" && groupLcuuid == "" {
+		return nil, servicecommon.NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("agent group (%s) not found", agentGroup))
+	}
+
+	db := dbInfo.DB
+	if groupLcuuid != "" {
+		db = db.Where("vtap_group_lcuuid = ?", groupLcuuid)
+	}
+	var agents []*mysqlmodel.VTap
+	if err := db.Order("id").Find(&agents).Error; err != nil {
+		return nil, err
+	}
+	selected := make([]*mysqlmodel.VTap, 0, len(agents))
+	for _, agent := range agents {
+		if matchAgent(agentAttributes(agent, groupLcuuidToName), requirements) {
+			selected = append(selected, agent)
+		}
+	}
+	return selected, nil
+}
+
+func GetAgentCMDAudits(orgID int, operator AgentCMDOperator, query model.AgentCMDAuditQuery) ([]*mysqlmodel.AgentCMDAudit, error) {
+	dbInfo, err := mysql.GetDB(orgID)
+	if err != nil {
+		return nil, err
+	}
+	db := dbInfo.DB
+	if !isAdminUser(operator.UserType) {
+		db = db.Where("user_id = ?", operator.UserID)
+	} else if query.UserID != nil {
+		db = db.Where("user_id = ?", *query.UserID)
+	}
+	if query.CMD != "" {
+		db = db.Where("cmd = ?", query.CMD)
+	}
+	limit := query.Limit
+	if limit <= 0 {
+		limit = AGENT_CMD_AUDIT_LIMIT_DEFAULT
+	}
+	var audits []*mysqlmodel.AgentCMDAudit
+	if err := db.Order("id DESC").Limit(limit).Find(&audits).Error; err != nil {
+		return nil, err
+	}
+	return audits, nil
+}
+
+func GetAgentCMDAudit(orgID int, operator AgentCMDOperator, id int) (*mysqlmodel.AgentCMDAudit, error) {
+	dbInfo, err := mysql.GetDB(orgID)
+	if err != nil {
+		return nil, err
+	}
+	var audit mysqlmodel.AgentCMDAudit
+	if err := dbInfo.Where("id = ?", id).First(&audit).Error; err != nil || (!isAdminUser(operator.UserType) && audit.UserID != operator.UserID) {
+		return nil, servicecommon.NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("agent command audit (id: %d) not found", id))
+	}
+	return &audit, nil
+}
+
+func isAdminUser(userType int) bool {
+	return userType == ctrlcommon.USER_TYPE_SUPER_ADMIN || userType == ctrlcommon.USER_TYPE_ADMIN
+}
@@ -0,0 +1,169 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	mysqlmodel "github.com/deepflowio/deepflow/server/controller/db/mysql/model"
+)
+
+func Test_parseAgentSelector(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector string
+		want     []agentRequirement
+		wantErr  bool
+	}{
+		{
+			name:     "empty",
+			selector: "",
+			want:     nil,
+		},
+		{
+			name:     "equal and not equal",
+			selector: "type=7, state==1,region!=r1",
+			want: []agentRequirement{
+				{key: "type", value: "7"},
+				{key: "state", value: "1"},
+				{key: "region", notEqual: true, value: "r1"},
+			},
+		},
+		{
+			name:     "unknown key",
+			selector: "foo=bar",
+			wantErr:  true,
+		},
+		{
+			name:     "no operator",
+			selector: "type",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAgentSelector(tt.selector)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseAgentSelector() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseAgentSelector() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("parseAgentSelector()[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func Test_matchAgent(t *testing.T) {
+	agent := &mysqlmodel.VTap{Name: "a1", Type: 7, State: 1, Region: "r1", VtapGroupLcuuid: "g1"}
+	attributes := agentAttributes(agent, map[string]string{"g1": "default"})
+	tests := []struct {
+		selector string
+		want     bool
+	}{
+		{"", true},
+		{"type=7,state=1", true},
+		{"group=default", true},
+		{"group_lcuuid=g2", false},
+		{"region!=r1", false},
+		{"region!=r2,name=a1", true},
+	}
+	for _, tt := range tests {
+		requirements, err := parseAgentSelector(tt.selector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := matchAgent(attributes, requirements); got != tt.want {
+			t.Errorf("matchAgent(%s) = %v, want %v", tt.selector, got, tt.want)
+		}
+	}
+}
+
+func Test_fanOutAgentCMD(t *testing.T) {
+	var agents []*mysqlmodel.VTap
+	for i := 1; i <= 10; i++ {
+		agents = append(agents, &mysqlmodel.VTap{ID: i})
+	}
+	var running, maxRunning int32
+	results := fanOutAgentCMD(agents, 3, func(agent *mysqlmodel.VTap) (string, error) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		if agent.ID%2 == 0 {
+			return "", errors.New("failed")
+		}
+		return "ok", nil
+	})
+	if maxRunning > 3 {
+		t.Errorf("max running executions = %d, want <= 3", maxRunning)
+	}
+	for i, r := range results {
+		if r.AgentID != i+1 {
+			t.Errorf("results[%d].AgentID = %d, want %d", i, r.AgentID, i+1)
+		}
+		wantStatus := AGENT_EXEC_STATUS_SUCCESS
+		if r.AgentID%2 == 0 {
+			wantStatus = AGENT_EXEC_STATUS_FAILED
+		}
+		if r.Status != wantStatus {
+			t.Errorf("results[%d].Status = %s, want %s", i, r.Status, wantStatus)
+		}
+	}
+}
+
+func Test_truncateOutput(t *testing.T) {
+	if got := truncateOutput("abc", 3); got != "abc" {
+		t.Errorf("truncateOutput() = %s, want abc", got)
+	}
+	got := truncateOutput(strings.Repeat("a", 10), 4)
+	if want := "aaaa...(6 bytes truncated)"; got != want {
+		t.Errorf("truncateOutput() = %s, want %s", got, want)
+	}
+}
+
+func Test_GetAgentCMDTimeout(t *testing.T) {
+	tests := []struct {
+		timeout int
+		want    int
+		wantErr bool
+	}{
+		{timeout: 0, want: 30},
+		{timeout: 5, want: 5},
+		{timeout: 300, want: 300},
+		{timeout: 301, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := GetAgentCMDTimeout(30, tt.timeout)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("GetAgentCMDTimeout(30, %d) = %d, %v", tt.timeout, got, err)
+		}
+	}
+}
@@ -0,0 +1,384 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/deepflowio/deepflow/server/controller/common"
+	mysqlmodel "github.com/deepflowio/deepflow/server/controller/db/mysql/model"
+	httpcommon "github.com/deepflowio/deepflow/server/controller/http/common"
+	. "github.com/deepflowio/deepflow/server/controller/http/service/common"
+	"github.com/deepflowio/deepflow/server/controller/model"
+	"github.com/deepflowio/deepflow/server/controller/trisolaris/refresh"
+)
+
+// pcap和npb策略共用的acl及下发范围处理
+
+const (
+	POLICY_ACL_DEFAULT_BUSINESS_ID = 1
+	POLICY_ACL_DEFAULT_TAP_TYPE    = 3 // LAN
+	POLICY_ACL_TYPE_CUSTOM         = 2
+	POLICY_STATE_DISABLE           = 0
+	POLICY_STATE_ENABLE            = 1
+
+	POLICY_ACL_GROUP_ID_MAX      = 65535
+	POLICY_ACL_GROUP_ALLOC_RETRY = 3
+)
+
+func newPolicyACL(db *gorm.DB, name string, application int, cond model.PolicyACL) (*mysqlmodel.ACL, error) {
+	if err := validatePolicyACL(db, cond.SrcGroupIDs, cond.DstGroupIDs, cond.SrcPorts, cond.DstPorts); err != nil {
+		return nil, err
+	}
+	tapType := cond.TapType
+	if tapType == 0 {
+		tapType = POLICY_ACL_DEFAULT_TAP_TYPE
+	}
+	return &mysqlmodel.ACL{
+		BusinessID:   POLICY_ACL_DEFAULT_BUSINESS_ID,
+		Name:         name,
+		Type:         POLICY_ACL_TYPE_CUSTOM,
+		TapType:      tapType,
+		State:        common.ACL_STATE_ENABLE,
+		Applications: strconv.Itoa(application),
+		SrcGroupIDs:  intSliceToString(cond.SrcGroupIDs),
+		DstGroupIDs:  intSliceToString(cond.DstGroupIDs),
+		Protocol:     cond.Protocol,
+		SrcPorts:     cond.SrcPorts,
+		DstPorts:     cond.DstPorts,
+		Vlan:         cond.Vlan,
+	}, nil
+}
+
+func getPolicyACLUpdateMap(db *gorm.DB, acl *mysqlmodel.ACL, update model.PolicyACLUpdate) (map[string]interface{}, error) {
+	srcGroupIDs, dstGroupIDs := stringToIntSlice(acl.SrcGroupIDs), stringToIntSlice(acl.DstGroupIDs)
+	srcPorts, dstPorts := acl.SrcPorts, acl.DstPorts
+	updateMap := make(map[string]interface{})
+	if update.TapType != nil {
+		updateMap["tap_type"] = *update.TapType
+	}
+	if update.SrcGroupIDs != nil {
+		srcGroupIDs = *update.SrcGroupIDs
+		updateMap["src_group_ids"] = intSliceToString(srcGroupIDs)
+	}
+	if update.DstGroupIDs != nil {
+		dstGroupIDs = *update.DstGroupIDs
+		updateMap["dst_group_ids"] = intSliceToString(dstGroupIDs)
+	}
+	if update.Protocol != nil {
+		updateMap["protocol"] = *update.Protocol
+	}
+	if update.SrcPorts != nil {
+		srcPorts = *update.SrcPorts
+		updateMap["src_ports"] = srcPorts
+	}
+	if update.DstPorts != nil {
+		dstPorts = *update.DstPorts
+		updateMap["dst_ports"] = dstPorts
+	}
+	if update.Vlan != nil {
+		updateMap["vlan"] = *update.Vlan
+	}
+	if len(updateMap) == 0 {
+		return updateMap, nil
+	}
+	return updateMap, validatePolicyACL(db, srcGroupIDs, dstGroupIDs, srcPorts, dstPorts)
+}
+
+func policyACLToModel(acl *mysqlmodel.ACL) model.PolicyACL {
+	if acl == nil {
+		return model.PolicyACL{}
+	}
+	return model.PolicyACL{
+		TapType:     acl.TapType,
+		SrcGroupIDs: stringToIntSlice(acl.SrcGroupIDs),
+		DstGroupIDs: stringToIntSlice(acl.DstGroupIDs),
+		Protocol:    acl.Protocol,
+		SrcPorts:    acl.SrcPorts,
+		DstPorts:    acl.DstPorts,
+		Vlan:        acl.Vlan,
+	}
+}
+
+func validatePolicyACL(db *gorm.DB, srcGroupIDs, dstGroupIDs []int, srcPorts, dstPorts string) error {
+	groupIDs := append(append([]int{}, srcGroupIDs...), dstGroupIDs...)
+	if len(groupIDs) > 0 {
+		var groups []mysqlmodel.ResourceGroup
+		if err := db.Select("id").Where("id IN (?)", groupIDs).Find(&groups).Error; err != nil {
+			return err
+		}
+		exists := make(map[int]struct{}, len(groups))
+		for _, group := range groups {
+			exists[group.ID] = struct{}{}
+		}
+		for _, id := range groupIDs {
+			if _, ok := exists[id]; !ok {
+				return NewError(httpcommon.INVALID_POST_DATA, fmt.Sprintf("resource group (%d) not found", id))
+			}
+		}
+	}
+	for _, ports := range []string{srcPorts, dstPorts} {
+		if err := validatePolicyPorts(ports); err != nil {
+			return NewError(httpcommon.INVALID_POST_DATA, err.Error())
+		}
+	}
+	return nil
+}
+
+// ports支持单个端口和端口段，以逗号分隔，如: 80,8000-8080
+func validatePolicyPorts(ports string) error {
+	if ports == "" {
+		return nil
+	}
+	for _, item := range strings.Split(ports, ",") {
+		bounds := strings.Split(item, "-")
+		if len(bounds) > 2 {
+			return fmt.Errorf("invalid port range (%s)", item)
+		}
+		var values []int
+		for _, bound := range bounds {
+			port, err := strconv.Atoi(strings.TrimSpace(bound))
+			if err != nil || port < 0 || port > 65535 {
+				return fmt.Errorf("invalid port (%s)", item)
+			}
+			values = append(values, port)
+		}
+		if len(values) == 2 && values[0] > values[1] {
+			return fmt.Errorf("invalid port range (%s)", item)
+		}
+	}
+	return nil
+}
+
+// 将采集器组转换为lcuuid列表保存，下发策略时再解析为组内的采集器，为空表示下发给所有采集器
+func getPolicyVtapGroupLcuuids(db *gorm.DB, vtapGroupIDs []string) (string, error) {
+	if len(vtapGroupIDs) == 0 {
+		return "", nil
+	}
+	var vtapGroups []mysqlmodel.VTapGroup
+	if err := db.Where("short_uuid IN (?) OR lcuuid IN (?)", vtapGroupIDs, vtapGroupIDs).Find(&vtapGroups).Error; err != nil {
+		return "", err
+	}
+	groupLcuuids := make([]string, 0, len(vtapGroups))
+	for _, id := range vtapGroupIDs {
+		found := false
+		for _, vtapGroup := range vtapGroups {
+			if vtapGroup.ShortUUID == id || vtapGroup.Lcuuid == id {
+				found = true
+				groupLcuuids = append(groupLcuuids, vtapGroup.Lcuuid)
+				break
+			}
+		}
+		if !found {
+			return "", NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("vtap_group (%s) not found", id))
+		}
+	}
+	return strings.Join(groupLcuuids, ","), nil
+}
+
+// 查询策略的采集器组当前包含的采集器
+func getPolicyGroupVtapIDs(db *gorm.DB, vtapGroupLcuuids []string) (map[string][]int, error) {
+	groupToVtapIDs := make(map[string][]int)
+	lcuuids := []string{}
+	for _, groupLcuuids := range vtapGroupLcuuids {
+		if groupLcuuids != "" {
+			lcuuids = append(lcuuids, strings.Split(groupLcuuids, ",")...)
+		}
+	}
+	if len(lcuuids) == 0 {
+		return groupToVtapIDs, nil
+	}
+	var vtaps []mysqlmodel.VTap
+	if err := db.Select("id", "vtap_group_lcuuid").Where("vtap_group_lcuuid IN (?)", lcuuids).Order("id").Find(&vtaps).Error; err != nil {
+		return nil, err
+	}
+	for _, vtap := range vtaps {
+		groupToVtapIDs[vtap.VtapGroupLcuuid] = append(groupToVtapIDs[vtap.VtapGroupLcuuid], vtap.ID)
+	}
+	return groupToVtapIDs, nil
+}
+
+func policyVtapIDs(vtapIDs, vtapGroupLcuuids string, groupToVtapIDs map[string][]int) []int {
+	if vtapGroupLcuuids == "" {
+		return stringToIntSlice(vtapIDs)
+	}
+	ids := []int{}
+	for _, lcuuid := range strings.Split(vtapGroupLcuuids, ",") {
+		ids = append(ids, groupToVtapIDs[lcuuid]...)
+	}
+	return ids
+}
+
+func stringToStringSlice(value string) []string {
+	if value == "" {
+		return []string{}
+	}
+	return strings.Split(value, ",")
+}
+
+func getPolicyExpiredAt(ttl int) *time.Time {
+	if ttl <= 0 {
+		return nil
+	}
+	expiredAt := time.Now().Add(time.Duration(ttl) * time.Second)
+	return &expiredAt
+}
+
+func formatPolicyExpiredAt(expiredAt *time.Time) (string, bool) {
+	if expiredAt == nil {
+		return "", false
+	}
+	return expiredAt.Format(common.GO_BIRTHDAY), !time.Now().Before(*expiredAt)
+}
+
+// 已分配的acl group id及其acl记录在policy_acl_group表中，通过主键保证并发创建的策略不会使用相同的id，
+// 未指定时分配最大值加一，超过上限后分配最小的空闲id
+func allocPolicyACLGroupID(tx *gorm.DB, aclGID, aclID int) (int, error) {
+	if aclGID != 0 {
+		ok, err := insertPolicyACLGroup(tx, aclGID, aclID)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			return 0, NewError(httpcommon.RESOURCE_ALREADY_EXIST, fmt.Sprintf("acl_gid (%d) is in use", aclGID))
+		}
+		return aclGID, nil
+	}
+	for i := 0; i < POLICY_ACL_GROUP_ALLOC_RETRY; i++ {
+		// 加锁读取已提交的最大值，使并发分配串行执行
+		var maxID int
+		if err := tx.Model(&mysqlmodel.PolicyACLGroup{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("COALESCE(MAX(id), 0)").Scan(&maxID).Error; err != nil {
+			return 0, err
+		}
+		id := maxID + 1
+		if id > POLICY_ACL_GROUP_ID_MAX {
+			var ids []int
+			if err := tx.Model(&mysqlmodel.PolicyACLGroup{}).Order("id").Pluck("id", &ids).Error; err != nil {
+				return 0, err
+			}
+			if id = firstFreePolicyACLGroupID(ids); id == 0 {
+				return 0, NewError(httpcommon.RESOURCE_NUM_EXCEEDED, fmt.Sprintf("acl_gid exceeds %d", POLICY_ACL_GROUP_ID_MAX))
+			}
+		}
+		ok, err := insertPolicyACLGroup(tx, id, aclID)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			return id, nil
+		}
+	}
+	return 0, NewError(httpcommon.SERVER_ERROR, "allocate acl_gid failed, please retry")
+}
+
+func firstFreePolicyACLGroupID(sortedIDs []int) int {
+	id := 1
+	for _, used := range sortedIDs {
+		if used > id {
+			break
+		}
+		if used == id {
+			id++
+		}
+	}
+	if id > POLICY_ACL_GROUP_ID_MAX {
+		return 0
+	}
+	return id
+}
+
+func insertPolicyACLGroup(tx *gorm.DB, id, aclID int) (bool, error) {
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&mysqlmodel.PolicyACLGroup{ID: id, ACLIDs: strconv.Itoa(aclID), COUNT: 1})
+	return result.RowsAffected > 0, result.Error
+}
+
+// acl group id只在没有其他策略使用时释放
+func releasePolicyACLGroupID(tx *gorm.DB, aclGID int) error {
+	var pcapCount, npbCount int64
+	if err := tx.Model(&mysqlmodel.PcapPolicy{}).Where("policy_acl_group_id = ?", aclGID).Count(&pcapCount).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&mysqlmodel.NpbPolicy{}).Where("policy_acl_group_id = ?", aclGID).Count(&npbCount).Error; err != nil {
+		return err
+	}
+	if pcapCount+npbCount > 0 {
+		return nil
+	}
+	return tx.Where("id = ?", aclGID).Delete(&mysqlmodel.PolicyACLGroup{}).Error
+}
+
+// acl只在没有其他策略引用时删除
+func deletePolicyACL(tx *gorm.DB, aclID int) error {
+	var pcapCount, npbCount int64
+	if err := tx.Model(&mysqlmodel.PcapPolicy{}).Where("acl_id = ?", aclID).Count(&pcapCount).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&mysqlmodel.NpbPolicy{}).Where("acl_id = ?", aclID).Count(&npbCount).Error; err != nil {
+		return err
+	}
+	if pcapCount+npbCount > 0 {
+		return nil
+	}
+	return tx.Where("id = ?", aclID).Delete(&mysqlmodel.ACL{}).Error
+}
+
+func getPolicyACLs(db *gorm.DB, aclIDs []int) (map[int]*mysqlmodel.ACL, error) {
+	idToACL := make(map[int]*mysqlmodel.ACL, len(aclIDs))
+	if len(aclIDs) == 0 {
+		return idToACL, nil
+	}
+	var acls []*mysqlmodel.ACL
+	if err := db.Where("id IN (?)", aclIDs).Find(&acls).Error; err != nil {
+		return nil, err
+	}
+	for _, acl := range acls {
+		idToACL[acl.ID] = acl
+	}
+	return idToACL, nil
+}
+
+func refreshPolicy(orgID int) {
+	refresh.RefreshCache(orgID, []common.DataChanged{common.DATA_CHANGED_FLOW_ACL})
+}
+
+func intSliceToString(values []int) string {
+	items := make([]string, 0, len(values))
+	for _, value := range values {
+		items = append(items, strconv.Itoa(value))
+	}
+	return strings.Join(items, ",")
+}
+
+func stringToIntSlice(value string) []int {
+	values := []int{}
+	if value == "" {
+		return values
+	}
+	for _, item := range strings.Split(value, ",") {
+		if v, err := strconv.Atoi(strings.TrimSpace(item)); err == nil {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidatePolicyPorts(t *testing.T) {
+	tests := []struct {
+		name    string
+		ports   string
+		wantErr bool
+	}{
+		{name: "empty", ports: "", wantErr: false},
+		{name: "single port", ports: "80", wantErr: false},
+		{name: "ports and range", ports: "80,443,8000-8080", wantErr: false},
+		{name: "not a number", ports: "http", wantErr: true},
+		{name: "out of range", ports: "65536", wantErr: true},
+		{name: "reversed range", ports: "8080-8000", wantErr: true},
+		{name: "too many bounds", ports: "1-2-3", wantErr: true},
+		{name: "empty item", ports: "80,", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validatePolicyPorts(tt.ports); (err != nil) != tt.wantErr {
+				t.Errorf("validatePolicyPorts(%s) error = %v, wantErr %v", tt.ports, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIntSliceString(t *testing.T) {
+	values := []int{3, 1, 20}
+	if got := intSliceToString(values); got != "3,1,20" {
+		t.Errorf("intSliceToString() = %s, want 3,1,20", got)
+	}
+	if got := stringToIntSlice("3,1,20"); !reflect.DeepEqual(got, values) {
+		t.Errorf("stringToIntSlice() = %v, want %v", got, values)
+	}
+	if got := stringToIntSlice(""); len(got) != 0 {
+		t.Errorf("stringToIntSlice(\"\") = %v, want empty", got)
+	}
+}
+
+func TestFirstFreePolicyACLGroupID(t *testing.T) {
+	full := make([]int, 0, POLICY_ACL_GROUP_ID_MAX)
+	for i := 1; i <= POLICY_ACL_GROUP_ID_MAX; i++ {
+		full = append(full, i)
+	}
+	tests := []struct {
+		name string
+		ids  []int
+		want int
+	}{
+		{name: "empty", ids: nil, want: 1},
+		{name: "gap", ids: []int{1, 2, 4, POLICY_ACL_GROUP_ID_MAX}, want: 3},
+		{name: "first is free", ids: []int{2, 3}, want: 1},
+		{name: "exhausted", ids: full, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := firstFreePolicyACLGroupID(tt.ids); got != tt.want {
+				t.Errorf("firstFreePolicyACLGroupID() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyVtapIDs(t *testing.T) {
+	groupToVtapIDs := map[string][]int{"g1": {1, 2}, "g2": {5}}
+	if got := policyVtapIDs("", "g1,g2", groupToVtapIDs); !reflect.DeepEqual(got, []int{1, 2, 5}) {
+		t.Errorf("policyVtapIDs() = %v, want [1 2 5]", got)
+	}
+	// groups without agents are not pushed to all agents
+	if got := policyVtapIDs("", "g3", groupToVtapIDs); got == nil || len(got) != 0 {
+		t.Errorf("policyVtapIDs() = %v, want empty", got)
+	}
+	if got := policyVtapIDs("3,4", "", groupToVtapIDs); !reflect.DeepEqual(got, []int{3, 4}) {
+		t.Errorf("policyVtapIDs() = %v, want [3 4]", got)
+	}
+}
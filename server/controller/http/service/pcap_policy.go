@@ -0,0 +1,250 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	mysqlmodel "github.com/deepflowio/deepflow/server/controller/db/mysql/model"
+	httpcommon "github.com/deepflowio/deepflow/server/controller/http/common"
+	. "github.com/deepflowio/deepflow/server/controller/http/service/common"
+	"github.com/deepflowio/deepflow/server/controller/model"
+	tcommon "github.com/deepflowio/deepflow/server/controller/trisolaris/common"
+)
+
+type PcapPolicy struct {
+	userInfo *httpcommon.UserInfo
+}
+
+func NewPcapPolicy(userInfo *httpcommon.UserInfo) *PcapPolicy {
+	return &PcapPolicy{userInfo: userInfo}
+}
+
+func (p *PcapPolicy) Get(filter map[string]interface{}) ([]model.PcapPolicy, error) {
+	dbInfo, err := mysql.GetDB(p.userInfo.ORGID)
+	if err != nil {
+		return nil, err
+	}
+	db := dbInfo.DB
+	for _, param := range []string{"lcuuid", "name", "state", "team_id"} {
+		if value, ok := filter[param]; ok {
+			db = db.Where(fmt.Sprintf("%s = ?", param), value)
+		}
+	}
+	var policies []mysqlmodel.PcapPolicy
+	if err := db.Order("id").Find(&policies).Error; err != nil {
+		return nil, err
+	}
+	aclIDs := make([]int, 0, len(policies))
+	for _, policy := range policies {
+		aclIDs = append(aclIDs, policy.ACLID)
+	}
+	idToACL, err := getPolicyACLs(dbInfo.DB, aclIDs)
+	if err != nil {
+		return nil, err
+	}
+	vtapGroupLcuuids := make([]string, 0, len(policies))
+	for _, policy := range policies {
+		vtapGroupLcuuids = append(vtapGroupLcuuids, policy.VtapGroupLcuuids)
+	}
+	groupToVtapIDs, err := getPolicyGroupVtapIDs(dbInfo.DB, vtapGroupLcuuids)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]model.PcapPolicy, 0, len(policies))
+	for _, policy := range policies {
+		expiredAt, expired := formatPolicyExpiredAt(policy.ExpiredAt)
+		response = append(response, model.PcapPolicy{
+			ID:           policy.ID,
+			Name:         policy.Name,
+			State:        policy.State,
+			TeamID:       policy.TeamID,
+			ACLID:        policy.ACLID,
+			ACLGID:       policy.PolicyACLGroupID,
+			VtapIDs:      policyVtapIDs(policy.VtapIDs, policy.VtapGroupLcuuids, groupToVtapIDs),
+			VtapGroups:   stringToStringSlice(policy.VtapGroupLcuuids),
+			PayloadSlice: policy.PayloadSlice,
+			ExpiredAt:    expiredAt,
+			Expired:      expired,
+			CreatedAt:    policy.CreatedAt.Format(common.GO_BIRTHDAY),
+			UpdatedAt:    policy.UpdatedAt.Format(common.GO_BIRTHDAY),
+			Lcuuid:       policy.Lcuuid,
+			PolicyACL:    policyACLToModel(idToACL[policy.ACLID]),
+		})
+	}
+	return response, nil
+}
+
+func (p *PcapPolicy) Create(policyCreate model.PcapPolicyCreate) (model.PcapPolicy, error) {
+	dbInfo, err := mysql.GetDB(p.userInfo.ORGID)
+	if err != nil {
+		return model.PcapPolicy{}, err
+	}
+	db := dbInfo.DB
+
+	acl, err := newPolicyACL(db, policyCreate.Name, tcommon.APPLICATION_PCAP, policyCreate.PolicyACL)
+	if err != nil {
+		return model.PcapPolicy{}, err
+	}
+	vtapGroupLcuuids, err := getPolicyVtapGroupLcuuids(db, policyCreate.VtapGroupIDs)
+	if err != nil {
+		return model.PcapPolicy{}, err
+	}
+	state := POLICY_STATE_ENABLE
+	if policyCreate.State != nil {
+		state = *policyCreate.State
+	}
+	teamID := policyCreate.TeamID
+	if teamID == 0 {
+		teamID = common.DEFAULT_TEAM_ID
+	}
+	acl.Lcuuid = uuid.New().String()
+	policy := mysqlmodel.PcapPolicy{
+		Name:             policyCreate.Name,
+		State:            state,
+		BusinessID:       POLICY_ACL_DEFAULT_BUSINESS_ID,
+		VtapGroupLcuuids: vtapGroupLcuuids,
+		PayloadSlice:     policyCreate.PayloadSlice,
+		UserID:           p.userInfo.ID,
+		ExpiredAt:        getPolicyExpiredAt(policyCreate.TTL),
+		Lcuuid:           uuid.New().String(),
+		TeamID:           teamID,
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(acl).Error; err != nil {
+			return err
+		}
+		policy.ACLID = acl.ID
+		if policy.PolicyACLGroupID, err = allocPolicyACLGroupID(tx, policyCreate.ACLGID, acl.ID); err != nil {
+			return err
+		}
+		return tx.Create(&policy).Error
+	})
+	if err != nil {
+		return model.PcapPolicy{}, err
+	}
+	log.Infof("create pcap_policy (%s) acl_gid: %d", policy.Name, policy.PolicyACLGroupID, dbInfo.LogPrefixORGID)
+
+	refreshPolicy(p.userInfo.ORGID)
+	response, err := p.Get(map[string]interface{}{"lcuuid": policy.Lcuuid})
+	if err != nil {
+		return model.PcapPolicy{}, err
+	}
+	return response[0], nil
+}
+
+func (p *PcapPolicy) Update(lcuuid string, policyUpdate model.PcapPolicyUpdate) (model.PcapPolicy, error) {
+	dbInfo, err := mysql.GetDB(p.userInfo.ORGID)
+	if err != nil {
+		return model.PcapPolicy{}, err
+	}
+	db := dbInfo.DB
+
+	var policy mysqlmodel.PcapPolicy
+	if err := db.Where("lcuuid = ?", lcuuid).First(&policy).Error; err != nil {
+		return model.PcapPolicy{}, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("pcap_policy (%s) not found", lcuuid))
+	}
+	var acl mysqlmodel.ACL
+	if err := db.Where("id = ?", policy.ACLID).First(&acl).Error; err != nil {
+		return model.PcapPolicy{}, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("acl (%d) of pcap_policy (%s) not found", policy.ACLID, lcuuid))
+	}
+	aclUpdateMap, err := getPolicyACLUpdateMap(db, &acl, policyUpdate.PolicyACLUpdate)
+	if err != nil {
+		return model.PcapPolicy{}, err
+	}
+
+	updateMap := make(map[string]interface{})
+	if policyUpdate.Name != nil {
+		updateMap["name"] = *policyUpdate.Name
+		aclUpdateMap["name"] = *policyUpdate.Name
+	}
+	if policyUpdate.State != nil {
+		updateMap["state"] = *policyUpdate.State
+	}
+	if policyUpdate.PayloadSlice != nil {
+		updateMap["payload_slice"] = *policyUpdate.PayloadSlice
+	}
+	if policyUpdate.TTL != nil {
+		updateMap["expired_at"] = getPolicyExpiredAt(*policyUpdate.TTL)
+	}
+	if policyUpdate.VtapGroupIDs != nil {
+		if updateMap["vtap_group_lcuuids"], err = getPolicyVtapGroupLcuuids(db, *policyUpdate.VtapGroupIDs); err != nil {
+			return model.PcapPolicy{}, err
+		}
+		updateMap["vtap_ids"] = ""
+	}
+	log.Infof("update pcap_policy (%s) config %v, acl %v", policy.Name, updateMap, aclUpdateMap, dbInfo.LogPrefixORGID)
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if len(aclUpdateMap) > 0 {
+			if err := tx.Model(&acl).Updates(aclUpdateMap).Error; err != nil {
+				return err
+			}
+		}
+		if len(updateMap) > 0 {
+			return tx.Model(&policy).Updates(updateMap).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return model.PcapPolicy{}, err
+	}
+
+	refreshPolicy(p.userInfo.ORGID)
+	response, err := p.Get(map[string]interface{}{"lcuuid": lcuuid})
+	if err != nil {
+		return model.PcapPolicy{}, err
+	}
+	return response[0], nil
+}
+
+func (p *PcapPolicy) Delete(lcuuid string) (map[string]string, error) {
+	dbInfo, err := mysql.GetDB(p.userInfo.ORGID)
+	if err != nil {
+		return nil, err
+	}
+	db := dbInfo.DB
+
+	var policy mysqlmodel.PcapPolicy
+	if err := db.Where("lcuuid = ?", lcuuid).First(&policy).Error; err != nil {
+		return nil, NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("pcap_policy (%s) not found", lcuuid))
+	}
+	log.Infof("delete pcap_policy (%s)", policy.Name, dbInfo.LogPrefixORGID)
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&policy).Error; err != nil {
+			return err
+		}
+		if err := releasePolicyACLGroupID(tx, policy.PolicyACLGroupID); err != nil {
+			return err
+		}
+		return deletePolicyACL(tx, policy.ACLID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	refreshPolicy(p.userInfo.ORGID)
+	return map[string]string{"LCUUID": lcuuid}, nil
+}
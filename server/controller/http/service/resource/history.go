@@ -0,0 +1,179 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resource
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+
+	ctrlcommon "github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	mysqlmodel "github.com/deepflowio/deepflow/server/controller/db/mysql/model"
+	httpcommon "github.com/deepflowio/deepflow/server/controller/http/common"
+	servicecommon "github.com/deepflowio/deepflow/server/controller/http/service/common"
+	"github.com/deepflowio/deepflow/server/controller/model"
+)
+
+// GetResourceHistory returns the snapshot of a resource which was valid at the query time
+func GetResourceHistory(orgID int, resourceType string, query model.ResourceHistoryQuery) (*model.ResourceSnapshot, error) {
+	if _, ok := inventoryModels[resourceType]; !ok {
+		return nil, servicecommon.NewError(httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("resource type (%s) not found", resourceType))
+	}
+	if query.Lcuuid == "" && query.ResourceID == 0 {
+		return nil, servicecommon.NewError(httpcommon.INVALID_PARAMETERS, "lcuuid or resource_id is required")
+	}
+	at, err := parseHistoryTime(query.Time, time.Now())
+	if err != nil {
+		return nil, servicecommon.NewError(httpcommon.INVALID_PARAMETERS, err.Error())
+	}
+	dbInfo, err := mysql.GetDB(orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	db := validSnapshotsAt(dbInfo.DB, at).Where("resource_type = ?", resourceType)
+	if query.Lcuuid != "" {
+		db = db.Where("resource_lcuuid = ?", query.Lcuuid)
+	} else {
+		db = db.Where("resource_id = ?", query.ResourceID)
+	}
+	snapshot, err := firstSnapshot(db)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot == nil {
+		return nil, servicecommon.NewError(
+			httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("%s snapshot not found at %s", resourceType, at.Format(ctrlcommon.GO_BIRTHDAY)),
+		)
+	}
+	return toResourceSnapshot(snapshot)
+}
+
+// GetIPHistory returns all the resources owning the ip at the query time, along with the snapshots of ip and vinterface
+func GetIPHistory(orgID int, query model.IPHistoryQuery) ([]*model.IPOwnerSnapshot, error) {
+	at, err := parseHistoryTime(query.Time, time.Now())
+	if err != nil {
+		return nil, servicecommon.NewError(httpcommon.INVALID_PARAMETERS, err.Error())
+	}
+	dbInfo, err := mysql.GetDB(orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	var ipSnapshots []*mysqlmodel.ResourceSnapshot
+	if err := validSnapshotsAt(dbInfo.DB, at).Where(
+		"resource_type IN ? AND ip = ?", []string{ctrlcommon.RESOURCE_TYPE_LAN_IP_EN, ctrlcommon.RESOURCE_TYPE_WAN_IP_EN}, query.IP,
+	).Find(&ipSnapshots).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]*model.IPOwnerSnapshot, 0, len(ipSnapshots))
+	for _, ipSnapshot := range ipSnapshots {
+		owner := new(model.IPOwnerSnapshot)
+		if owner.IP, err = toResourceSnapshot(ipSnapshot); err != nil {
+			return nil, err
+		}
+		result = append(result, owner)
+
+		vifID := intField(owner.IP.Content, "VINTERFACE_ID")
+		if vifID == 0 {
+			continue
+		}
+		if owner.VInterface, err = findSnapshotByID(dbInfo.DB, ctrlcommon.RESOURCE_TYPE_VINTERFACE_EN, vifID, at); err != nil {
+			return nil, err
+		}
+		if owner.VInterface == nil {
+			continue
+		}
+		deviceType, ok := ctrlcommon.VIF_DEVICE_TYPE_TO_RESOURCE_TYPE[intField(owner.VInterface.Content, "DEVICE_TYPE")]
+		if !ok {
+			continue
+		}
+		if owner.Device, err = findSnapshotByID(dbInfo.DB, deviceType, intField(owner.VInterface.Content, "DEVICE_ID"), at); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// parseHistoryTime parses unix timestamp in seconds or RFC3339 time, returns now if empty
+func parseHistoryTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return now, nil
+	}
+	if ts, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(ts, 0), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time (%s), unix timestamp in seconds or RFC3339 is expected", value)
+	}
+	return t, nil
+}
+
+func validSnapshotsAt(db *gorm.DB, at time.Time) *gorm.DB {
+	return db.Model(&mysqlmodel.ResourceSnapshot{}).Where("valid_from <= ? AND (valid_to IS NULL OR valid_to > ?)", at, at)
+}
+
+func firstSnapshot(db *gorm.DB) (*mysqlmodel.ResourceSnapshot, error) {
+	var snapshot mysqlmodel.ResourceSnapshot
+	if err := db.Order("version DESC").First(&snapshot).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+func findSnapshotByID(db *gorm.DB, resourceType string, id int, at time.Time) (*model.ResourceSnapshot, error) {
+	snapshot, err := firstSnapshot(validSnapshotsAt(db, at).Where("resource_type = ? AND resource_id = ?", resourceType, id))
+	if err != nil || snapshot == nil {
+		return nil, err
+	}
+	return toResourceSnapshot(snapshot)
+}
+
+func toResourceSnapshot(snapshot *mysqlmodel.ResourceSnapshot) (*model.ResourceSnapshot, error) {
+	result := &model.ResourceSnapshot{
+		ResourceType:   snapshot.ResourceType,
+		ResourceID:     snapshot.ResourceID,
+		ResourceLcuuid: snapshot.ResourceLcuuid,
+		ResourceName:   snapshot.ResourceName,
+		Version:        snapshot.Version,
+		ValidFrom:      snapshot.ValidFrom.Format(ctrlcommon.GO_BIRTHDAY),
+	}
+	if snapshot.ValidTo != nil {
+		result.ValidTo = snapshot.ValidTo.Format(ctrlcommon.GO_BIRTHDAY)
+	}
+	if err := json.Unmarshal([]byte(snapshot.Content), &result.Content); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s snapshot (id: %d): %s", snapshot.ResourceType, snapshot.ID, err.Error())
+	}
+	return result, nil
+}
+
+func intField(fields map[string]interface{}, key string) int {
+	if v, ok := fields[key].(float64); ok {
+		return int(v)
+	}
+	return 0
+}
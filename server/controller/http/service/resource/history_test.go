@@ -0,0 +1,79 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resource
+
+import (
+	"testing"
+	"time"
+
+	mysqlmodel "github.com/deepflowio/deepflow/server/controller/db/mysql/model"
+)
+
+func Test_parseHistoryTime(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "empty", value: "", want: now},
+		{name: "unix", value: "1690000000", want: time.Unix(1690000000, 0)},
+		{name: "rfc3339", value: "2023-07-22T03:12:00Z", want: time.Date(2023, 7, 22, 3, 12, 0, 0, time.UTC)},
+		{name: "invalid", value: "last night", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseHistoryTime(tt.value, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseHistoryTime() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Equal(tt.want) {
+				t.Errorf("parseHistoryTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_toResourceSnapshot(t *testing.T) {
+	validTo := time.Date(2023, 7, 22, 3, 12, 0, 0, time.Local)
+	snapshot := &mysqlmodel.ResourceSnapshot{
+		ResourceType:   "vinterface",
+		ResourceID:     10,
+		ResourceLcuuid: "vif-lcuuid",
+		Version:        2,
+		Content:        `{"ID":10,"DEVICE_TYPE":10,"DEVICE_ID":3}`,
+		ValidFrom:      validTo.Add(-time.Hour),
+		ValidTo:        &validTo,
+	}
+	got, err := toResourceSnapshot(snapshot)
+	if err != nil {
+		t.Fatalf("toResourceSnapshot() error = %v", err)
+	}
+	if got.ValidFrom != "2023-07-22 02:12:00" || got.ValidTo != "2023-07-22 03:12:00" {
+		t.Errorf("toResourceSnapshot() valid from %s to %s", got.ValidFrom, got.ValidTo)
+	}
+	if intField(got.Content, "DEVICE_TYPE") != 10 || intField(got.Content, "DEVICE_ID") != 3 || intField(got.Content, "NAME") != 0 {
+		t.Errorf("toResourceSnapshot() content = %v", got.Content)
+	}
+
+	snapshot.ValidTo = nil
+	snapshot.Content = "{"
+	if _, err := toResourceSnapshot(snapshot); err == nil {
+		t.Errorf("toResourceSnapshot() expected error for invalid content")
+	}
+}
@@ -0,0 +1,381 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resource
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
+	ctrlcommon "github.com/deepflowio/deepflow/server/controller/common"
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	mysqlmodel "github.com/deepflowio/deepflow/server/controller/db/mysql/model"
+	httpcommon "github.com/deepflowio/deepflow/server/controller/http/common"
+	servicecommon "github.com/deepflowio/deepflow/server/controller/http/service/common"
+	"github.com/deepflowio/deepflow/server/controller/model"
+)
+
+const (
+	INVENTORY_PAGE_SIZE_DEFAULT = 1000
+)
+
+// resources recorded by recorder, domains are provided by /v2/domains/
+var inventoryModels = map[string]interface{}{
+	ctrlcommon.RESOURCE_TYPE_REGION_EN:                   mysqlmodel.Region{},
+	ctrlcommon.RESOURCE_TYPE_AZ_EN:                       mysqlmodel.AZ{},
+	ctrlcommon.RESOURCE_TYPE_SUB_DOMAIN_EN:               mysqlmodel.SubDomain{},
+	ctrlcommon.RESOURCE_TYPE_HOST_EN:                     mysqlmodel.Host{},
+	ctrlcommon.RESOURCE_TYPE_VM_EN:                       mysqlmodel.VM{},
+	ctrlcommon.RESOURCE_TYPE_VPC_EN:                      mysqlmodel.VPC{},
+	ctrlcommon.RESOURCE_TYPE_NETWORK_EN:                  mysqlmodel.Network{},
+	ctrlcommon.RESOURCE_TYPE_SUBNET_EN:                   mysqlmodel.Subnet{},
+	ctrlcommon.RESOURCE_TYPE_VROUTER_EN:                  mysqlmodel.VRouter{},
+	ctrlcommon.RESOURCE_TYPE_ROUTING_TABLE_EN:            mysqlmodel.RoutingTable{},
+	ctrlcommon.RESOURCE_TYPE_DHCP_PORT_EN:                mysqlmodel.DHCPPort{},
+	ctrlcommon.RESOURCE_TYPE_VINTERFACE_EN:               mysqlmodel.VInterface{},
+	ctrlcommon.RESOURCE_TYPE_FLOATING_IP_EN:              mysqlmodel.FloatingIP{},
+	ctrlcommon.RESOURCE_TYPE_WAN_IP_EN:                   mysqlmodel.WANIP{},
+	ctrlcommon.RESOURCE_TYPE_LAN_IP_EN:                   mysqlmodel.LANIP{},
+	ctrlcommon.RESOURCE_TYPE_VIP_EN:                      mysqlmodel.VIP{},
+	ctrlcommon.RESOURCE_TYPE_NAT_GATEWAY_EN:              mysqlmodel.NATGateway{},
+	ctrlcommon.RESOURCE_TYPE_NAT_RULE_EN:                 mysqlmodel.NATRule{},
+	ctrlcommon.RESOURCE_TYPE_NAT_VM_CONNECTION_EN:        mysqlmodel.NATVMConnection{},
+	ctrlcommon.RESOURCE_TYPE_LB_EN:                       mysqlmodel.LB{},
+	ctrlcommon.RESOURCE_TYPE_LB_LISTENER_EN:              mysqlmodel.LBListener{},
+	ctrlcommon.RESOURCE_TYPE_LB_TARGET_SERVER_EN:         mysqlmodel.LBTargetServer{},
+	ctrlcommon.RESOURCE_TYPE_LB_VM_CONNECTION_EN:         mysqlmodel.LBVMConnection{},
+	ctrlcommon.RESOURCE_TYPE_PEER_CONNECTION_EN:          mysqlmodel.PeerConnection{},
+	ctrlcommon.RESOURCE_TYPE_CEN_EN:                      mysqlmodel.CEN{},
+	ctrlcommon.RESOURCE_TYPE_RDS_INSTANCE_EN:             mysqlmodel.RDSInstance{},
+	ctrlcommon.RESOURCE_TYPE_REDIS_INSTANCE_EN:           mysqlmodel.RedisInstance{},
+	ctrlcommon.RESOURCE_TYPE_POD_CLUSTER_EN:              mysqlmodel.PodCluster{},
+	ctrlcommon.RESOURCE_TYPE_POD_NODE_EN:                 mysqlmodel.PodNode{},
+	ctrlcommon.RESOURCE_TYPE_VM_POD_NODE_CONNECTION_EN:   mysqlmodel.VMPodNodeConnection{},
+	ctrlcommon.RESOURCE_TYPE_POD_NAMESPACE_EN:            mysqlmodel.PodNamespace{},
+	ctrlcommon.RESOURCE_TYPE_POD_INGRESS_EN:              mysqlmodel.PodIngress{},
+	ctrlcommon.RESOURCE_TYPE_POD_INGRESS_RULE_EN:         mysqlmodel.PodIngressRule{},
+	ctrlcommon.RESOURCE_TYPE_POD_INGRESS_RULE_BACKEND_EN: mysqlmodel.PodIngressRuleBackend{},
+	ctrlcommon.RESOURCE_TYPE_POD_SERVICE_EN:              mysqlmodel.PodService{},
+	ctrlcommon.RESOURCE_TYPE_POD_SERVICE_PORT_EN:         mysqlmodel.PodServicePort{},
+	ctrlcommon.RESOURCE_TYPE_POD_GROUP_EN:                mysqlmodel.PodGroup{},
+	ctrlcommon.RESOURCE_TYPE_POD_GROUP_PORT_EN:           mysqlmodel.PodGroupPort{},
+	ctrlcommon.RESOURCE_TYPE_POD_REPLICA_SET_EN:          mysqlmodel.PodReplicaSet{},
+	ctrlcommon.RESOURCE_TYPE_POD_EN:                      mysqlmodel.Pod{},
+	ctrlcommon.RESOURCE_TYPE_PROCESS_EN:                  mysqlmodel.Process{},
+}
+
+// label selector is only supported by resources whose label is recorded as `key:value, key:value`
+var inventoryLabelSelectorTypes = map[string]bool{
+	ctrlcommon.RESOURCE_TYPE_POD_EN: true,
+}
+
+var inventorySchemaCache = &sync.Map{}
+
+func GetInventoryTypes() []string {
+	types := make([]string, 0, len(inventoryModels))
+	for t := range inventoryModels {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func GetInventory(orgID int, resourceType string, query model.InventoryQuery) (*model.InventoryResources, error) {
+	resourceModel, ok := inventoryModels[resourceType]
+	if !ok {
+		return nil, servicecommon.NewError(
+			httpcommon.RESOURCE_NOT_FOUND, fmt.Sprintf("resource type (%s) not found, supported: %s", resourceType, strings.Join(GetInventoryTypes(), ", ")),
+		)
+	}
+	dbInfo, err := mysql.GetDB(orgID)
+	if err != nil {
+		return nil, err
+	}
+	return queryInventory(dbInfo.DB, resourceType, resourceModel, query)
+}
+
+func queryInventory(orgDB *gorm.DB, resourceType string, resourceModel interface{}, query model.InventoryQuery) (*model.InventoryResources, error) {
+	resourceSchema, err := schema.Parse(resourceModel, inventorySchemaCache, orgDB.NamingStrategy)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := getInventoryColumns(resourceSchema, splitInventoryValues(query.Fields))
+	if err != nil {
+		return nil, err
+	}
+	filters, err := getInventoryFilters(resourceType, resourceSchema, query)
+	if err != nil {
+		return nil, err
+	}
+	newQuery := func() *gorm.DB {
+		db := orgDB.Model(resourceModel)
+		for _, filter := range filters {
+			db = filter(db)
+		}
+		return db
+	}
+
+	result := &model.InventoryResources{
+		Type:      resourceType,
+		PageIndex: query.PageIndex,
+		PageSize:  query.PageSize,
+	}
+	if result.PageIndex == 0 {
+		result.PageIndex = 1
+	}
+	if result.PageSize == 0 {
+		result.PageSize = INVENTORY_PAGE_SIZE_DEFAULT
+	}
+	if err := newQuery().Count(&result.Total).Error; err != nil {
+		return nil, err
+	}
+
+	items := reflect.New(reflect.SliceOf(reflect.TypeOf(resourceModel)))
+	db := newQuery()
+	if len(columns) > 0 {
+		db = db.Select(columns)
+	}
+	err = db.Order("id").Offset((result.PageIndex - 1) * result.PageSize).Limit(result.PageSize).Find(items.Interface()).Error
+	if err != nil {
+		return nil, err
+	}
+	result.Resources, err = projectInventory(items.Interface(), query.Fields)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// values could be repeated or separated by ,
+func splitInventoryValues(values []string) []string {
+	var result []string
+	for _, value := range values {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				result = append(result, v)
+			}
+		}
+	}
+	return result
+}
+
+// convert json field names to db columns
+func getInventoryColumns(resourceSchema *schema.Schema, fields []string) ([]string, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	jsonToColumn := make(map[string]string, len(resourceSchema.Fields))
+	for _, field := range resourceSchema.Fields {
+		if field.DBName == "" {
+			continue
+		}
+		jsonName := strings.Split(field.Tag.Get("json"), ",")[0]
+		if jsonName != "" && jsonName != "-" {
+			jsonToColumn[jsonName] = field.DBName
+		}
+	}
+	columns := make([]string, 0, len(fields))
+	for _, field := range fields {
+		column, ok := jsonToColumn[field]
+		if !ok {
+			return nil, servicecommon.NewError(httpcommon.INVALID_PARAMETERS, fmt.Sprintf("field (%s) not found", field))
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+type inventoryFilter func(db *gorm.DB) *gorm.DB
+
+func getInventoryFilters(resourceType string, resourceSchema *schema.Schema, query model.InventoryQuery) ([]inventoryFilter, error) {
+	var filters []inventoryFilter
+	addFilter := func(param, column string, value interface{}) error {
+		if _, ok := resourceSchema.FieldsByDBName[column]; !ok {
+			return servicecommon.NewError(
+				httpcommon.INVALID_PARAMETERS, fmt.Sprintf("resource type (%s) does not support filter (%s)", resourceType, param),
+			)
+		}
+		filters = append(filters, func(db *gorm.DB) *gorm.DB {
+			return db.Where(fmt.Sprintf("%s IN (?)", column), value)
+		})
+		return nil
+	}
+
+	if lcuuids := splitInventoryValues(query.Lcuuids); len(lcuuids) > 0 {
+		if err := addFilter("lcuuid", "lcuuid", lcuuids); err != nil {
+			return nil, err
+		}
+	}
+	if names := splitInventoryValues(query.Names); len(names) > 0 {
+		if err := addFilter("name", "name", names); err != nil {
+			return nil, err
+		}
+	}
+	if query.Domain != "" {
+		if err := addFilter("domain", "domain", query.Domain); err != nil {
+			return nil, err
+		}
+	}
+	if query.SubDomain != "" {
+		if err := addFilter("sub_domain", "sub_domain", query.SubDomain); err != nil {
+			return nil, err
+		}
+	}
+	if query.Region != "" {
+		if err := addFilter("region", "region", query.Region); err != nil {
+			return nil, err
+		}
+	}
+	if query.VPCID != nil {
+		column := "epc_id"
+		if resourceType == ctrlcommon.RESOURCE_TYPE_VPC_EN {
+			column = "id"
+		}
+		if err := addFilter("vpc_id", column, *query.VPCID); err != nil {
+			return nil, err
+		}
+	}
+	if query.LabelSelector != "" {
+		if !inventoryLabelSelectorTypes[resourceType] {
+			return nil, servicecommon.NewError(
+				httpcommon.INVALID_PARAMETERS, fmt.Sprintf("resource type (%s) does not support filter (label_selector)", resourceType),
+			)
+		}
+		requirements, err := parseLabelSelector(query.LabelSelector)
+		if err != nil {
+			return nil, servicecommon.NewError(httpcommon.INVALID_PARAMETERS, err.Error())
+		}
+		for _, requirement := range requirements {
+			condition, pattern := requirement.toCondition("label")
+			filters = append(filters, func(db *gorm.DB) *gorm.DB {
+				return db.Where(condition, pattern)
+			})
+		}
+	}
+	return filters, nil
+}
+
+// items is a pointer to slice of resource models, returns json objects with the fields only
+func projectInventory(items interface{}, fields []string) ([]map[string]interface{}, error) {
+	data, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	var resources []map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&resources); err != nil {
+		return nil, err
+	}
+	if resources == nil {
+		resources = []map[string]interface{}{}
+	}
+	fields = splitInventoryValues(fields)
+	if len(fields) == 0 {
+		return resources, nil
+	}
+	keep := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		keep[field] = true
+	}
+	for _, resource := range resources {
+		for key := range resource {
+			if !keep[key] {
+				delete(resource, key)
+			}
+		}
+	}
+	return resources, nil
+}
+
+const (
+	labelOperatorEqual     = "="
+	labelOperatorNotEqual  = "!="
+	labelOperatorExists    = "exists"
+	labelOperatorNotExists = "!exists"
+)
+
+var (
+	labelKeyRegex   = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9_./]*[A-Za-z0-9])?$`)
+	labelValueRegex = regexp.MustCompile(`^([A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?)?$`)
+	likeEscaper     = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+)
+
+type labelRequirement struct {
+	key      string
+	operator string
+	value    string
+}
+
+// parse kubernetes equality-based label selector, e.g.: app=web,tier!=db,env,!canary
+func parseLabelSelector(selector string) ([]labelRequirement, error) {
+	var requirements []labelRequirement
+	for _, term := range strings.Split(selector, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		var r labelRequirement
+		switch {
+		case strings.Contains(term, "!="):
+			parts := strings.SplitN(term, "!=", 2)
+			r = labelRequirement{key: parts[0], operator: labelOperatorNotEqual, value: parts[1]}
+		case strings.Contains(term, "=="):
+			parts := strings.SplitN(term, "==", 2)
+			r = labelRequirement{key: parts[0], operator: labelOperatorEqual, value: parts[1]}
+		case strings.Contains(term, "="):
+			parts := strings.SplitN(term, "=", 2)
+			r = labelRequirement{key: parts[0], operator: labelOperatorEqual, value: parts[1]}
+		case strings.HasPrefix(term, "!"):
+			r = labelRequirement{key: term[1:], operator: labelOperatorNotExists}
+		default:
+			r = labelRequirement{key: term, operator: labelOperatorExists}
+		}
+		r.key, r.value = strings.TrimSpace(r.key), strings.TrimSpace(r.value)
+		if !labelKeyRegex.MatchString(r.key) {
+			return nil, fmt.Errorf("invalid label key (%s) in label selector (%s)", r.key, selector)
+		}
+		if !labelValueRegex.MatchString(r.value) {
+			return nil, fmt.Errorf("invalid label value (%s) in label selector (%s)", r.value, selector)
+		}
+		requirements = append(requirements, r)
+	}
+	return requirements, nil
+}
+
+// labels are recorded as `key:value, key:value`
+func (r labelRequirement) toCondition(column string) (string, string) {
+	field := fmt.Sprintf("CONCAT(', ', %s, ', ')", column)
+	switch r.operator {
+	case labelOperatorEqual:
+		return field + " LIKE ?", "%, " + likeEscaper.Replace(r.key+":"+r.value) + ", %"
+	case labelOperatorNotEqual:
+		return field + " NOT LIKE ?", "%, " + likeEscaper.Replace(r.key+":"+r.value) + ", %"
+	case labelOperatorExists:
+		return field + " LIKE ?", "%, " + likeEscaper.Replace(r.key+":") + "%"
+	default:
+		return field + " NOT LIKE ?", "%, " + likeEscaper.Replace(r.key+":") + "%"
+	}
+}
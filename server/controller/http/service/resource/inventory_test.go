@@ -0,0 +1,117 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resource
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm/schema"
+
+	mysqlmodel "github.com/deepflowio/deepflow/server/controller/db/mysql/model"
+)
+
+func Test_parseLabelSelector(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector string
+		want     []labelRequirement
+		wantErr  bool
+	}{
+		{
+			name:     "equality",
+			selector: "app=web, tier==db,env!=prod",
+			want: []labelRequirement{
+				{key: "app", operator: labelOperatorEqual, value: "web"},
+				{key: "tier", operator: labelOperatorEqual, value: "db"},
+				{key: "env", operator: labelOperatorNotEqual, value: "prod"},
+			},
+		},
+		{
+			name:     "existence",
+			selector: "app.kubernetes.io/name,!canary",
+			want: []labelRequirement{
+				{key: "app.kubernetes.io/name", operator: labelOperatorExists},
+				{key: "canary", operator: labelOperatorNotExists},
+			},
+		},
+		{
+			name:     "invalid key",
+			selector: "a%b=c",
+			wantErr:  true,
+		},
+		{
+			name:     "invalid value",
+			selector: "app=we b",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLabelSelector(tt.selector)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseLabelSelector() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseLabelSelector() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_labelRequirement_toCondition(t *testing.T) {
+	condition, pattern := labelRequirement{key: "app_name", operator: labelOperatorEqual, value: "web"}.toCondition("label")
+	if condition != "CONCAT(', ', label, ', ') LIKE ?" || pattern != `%, app\_name:web, %` {
+		t.Errorf("toCondition() = %s, %s", condition, pattern)
+	}
+	condition, pattern = labelRequirement{key: "canary", operator: labelOperatorNotExists}.toCondition("label")
+	if condition != "CONCAT(', ', label, ', ') NOT LIKE ?" || pattern != "%, canary:%" {
+		t.Errorf("toCondition() = %s, %s", condition, pattern)
+	}
+}
+
+func Test_getInventoryColumns(t *testing.T) {
+	podSchema, err := schema.Parse(mysqlmodel.Pod{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	columns, err := getInventoryColumns(podSchema, []string{"ID", "LCUUID", "POD_NAMESPACE_ID", "VPC_ID"})
+	if err != nil || !reflect.DeepEqual(columns, []string{"id", "lcuuid", "pod_namespace_id", "epc_id"}) {
+		t.Errorf("getInventoryColumns() = %v, err: %v", columns, err)
+	}
+	if _, err := getInventoryColumns(podSchema, []string{"UNKNOWN"}); err == nil {
+		t.Error("getInventoryColumns() of unknown field should fail")
+	}
+}
+
+func Test_projectInventory(t *testing.T) {
+	pods := []mysqlmodel.Pod{{Name: "pod-1", Label: "app:web"}}
+	pods[0].ID = 9007199254740993
+	resources, err := projectInventory(&pods, []string{"ID,NAME"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resources) != 1 || len(resources[0]) != 2 || resources[0]["NAME"] != "pod-1" || resources[0]["ID"].(interface{ String() string }).String() != "9007199254740993" {
+		t.Errorf("projectInventory() = %v", resources)
+	}
+	empty := []mysqlmodel.Pod{}
+	if resources, _ := projectInventory(&empty, nil); resources == nil || len(resources) != 0 {
+		t.Errorf("projectInventory() of empty items = %v", resources)
+	}
+}
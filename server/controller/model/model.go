@@ -767,6 +767,7 @@ type RemoteExecReq struct {
 	OutputFormat   *trident.OutputFormat `json:"output_format"` // 0: "TEXT", 1: "BINARY"
 	OutputFilename string                `json:"output_filename"`
 	CMD            string                `json:"cmd" binding:"required"`
+	Timeout        int                   `json:"timeout" binding:"omitempty,min=1"` // unit: second, default: agent-cmd-timeout in server.yaml, max: 10 times of it
 }
 
 type RemoteExecResp struct {
@@ -775,3 +776,196 @@ type RemoteExecResp struct {
 	RemoteCommand  []*trident.RemoteCommand  `json:"remote_commands,omitempty"`  // LIST_COMMAND
 	LinuxNamespace []*trident.LinuxNamespace `json:"linux_namespaces,omitempty"` // LIST_NAMESPACE
 }
+
+// PolicyACL is the flow matching condition shared by pcap and npb policies
+type PolicyACL struct {
+	TapType     int    `json:"TAP_TYPE" binding:"omitempty,min=1,max=255"`
+	SrcGroupIDs []int  `json:"SRC_GROUP_IDS"`
+	DstGroupIDs []int  `json:"DST_GROUP_IDS"`
+	Protocol    *int   `json:"PROTOCOL" binding:"omitempty,min=0,max=255"`
+	SrcPorts    string `json:"SRC_PORTS"` // e.g.: 80,8000-8080
+	DstPorts    string `json:"DST_PORTS"`
+	Vlan        int    `json:"VLAN" binding:"min=0,max=4095"`
+}
+
+type PolicyACLUpdate struct {
+	TapType     *int    `json:"TAP_TYPE" binding:"omitempty,min=1,max=255"`
+	SrcGroupIDs *[]int  `json:"SRC_GROUP_IDS"`
+	DstGroupIDs *[]int  `json:"DST_GROUP_IDS"`
+	Protocol    *int    `json:"PROTOCOL" binding:"omitempty,min=0,max=255"`
+	SrcPorts    *string `json:"SRC_PORTS"`
+	DstPorts    *string `json:"DST_PORTS"`
+	Vlan        *int    `json:"VLAN" binding:"omitempty,min=0,max=4095"`
+}
+
+type PcapPolicyCreate struct {
+	Name         string   `json:"NAME" binding:"required,max=64"`
+	State        *int     `json:"STATE" binding:"omitempty,oneof=0 1"`
+	TeamID       int      `json:"TEAM_ID"`
+	VtapGroupIDs []string `json:"VTAP_GROUP_IDS"` // short uuid or lcuuid, empty means all agents
+	PayloadSlice *int     `json:"PAYLOAD_SLICE" binding:"omitempty,min=0,max=65535"`
+	ACLGID       int      `json:"ACL_GID" binding:"min=0,max=65535"` // allocated automatically if not specified
+	TTL          int      `json:"TTL" binding:"min=0"`               // seconds, 0 means never expire
+	PolicyACL
+}
+
+type PcapPolicyUpdate struct {
+	Name         *string   `json:"NAME" binding:"omitempty,max=64"`
+	State        *int      `json:"STATE" binding:"omitempty,oneof=0 1"`
+	VtapGroupIDs *[]string `json:"VTAP_GROUP_IDS"`
+	PayloadSlice *int      `json:"PAYLOAD_SLICE" binding:"omitempty,min=0,max=65535"`
+	TTL          *int      `json:"TTL" binding:"omitempty,min=0"` // restart the countdown from now, 0 means never expire
+	PolicyACLUpdate
+}
+
+type PcapPolicy struct {
+	ID           int      `json:"ID"`
+	Name         string   `json:"NAME"`
+	State        int      `json:"STATE"`
+	TeamID       int      `json:"TEAM_ID"`
+	ACLID        int      `json:"ACL_ID"`
+	ACLGID       int      `json:"ACL_GID"`
+	VtapIDs      []int    `json:"VTAP_IDS"`    // current agents of VTAP_GROUPS if it is not empty
+	VtapGroups   []string `json:"VTAP_GROUPS"` // lcuuids
+	PayloadSlice *int     `json:"PAYLOAD_SLICE"`
+	ExpiredAt    string   `json:"EXPIRED_AT"`
+	Expired      bool     `json:"EXPIRED"`
+	CreatedAt    string   `json:"CREATED_AT"`
+	UpdatedAt    string   `json:"UPDATED_AT"`
+	Lcuuid       string   `json:"LCUUID"`
+	PolicyACL
+}
+
+type NpbPolicyCreate struct {
+	Name         string   `json:"NAME" binding:"required,max=64"`
+	State        *int     `json:"STATE" binding:"omitempty,oneof=0 1"`
+	TeamID       int      `json:"TEAM_ID"`
+	VtapGroupIDs []string `json:"VTAP_GROUP_IDS"` // short uuid or lcuuid, empty means all agents
+	PayloadSlice *int     `json:"PAYLOAD_SLICE" binding:"omitempty,min=0,max=65535"`
+	ACLGID       int      `json:"ACL_GID" binding:"min=0,max=65535"` // allocated automatically if not specified
+	TTL          int      `json:"TTL" binding:"min=0"`               // seconds, 0 means never expire
+	NpbTunnelID  int      `json:"NPB_TUNNEL_ID" binding:"required"`
+	Vni          *int     `json:"VNI" binding:"omitempty,min=0,max=16777215"`
+	Direction    int      `json:"DIRECTION" binding:"omitempty,oneof=1 2 3"` // 1-all; 2-forward; 3-backward
+	Distribute   *int     `json:"DISTRIBUTE" binding:"omitempty,oneof=0 1"`  // 0-drop, 1-distribute
+	PolicyACL
+}
+
+type NpbPolicyUpdate struct {
+	Name         *string   `json:"NAME" binding:"omitempty,max=64"`
+	State        *int      `json:"STATE" binding:"omitempty,oneof=0 1"`
+	VtapGroupIDs *[]string `json:"VTAP_GROUP_IDS"`
+	PayloadSlice *int      `json:"PAYLOAD_SLICE" binding:"omitempty,min=0,max=65535"`
+	TTL          *int      `json:"TTL" binding:"omitempty,min=0"` // restart the countdown from now, 0 means never expire
+	NpbTunnelID  *int      `json:"NPB_TUNNEL_ID"`
+	Vni          *int      `json:"VNI" binding:"omitempty,min=0,max=16777215"`
+	Direction    *int      `json:"DIRECTION" binding:"omitempty,oneof=1 2 3"`
+	Distribute   *int      `json:"DISTRIBUTE" binding:"omitempty,oneof=0 1"`
+	PolicyACLUpdate
+}
+
+type NpbPolicy struct {
+	ID           int      `json:"ID"`
+	Name         string   `json:"NAME"`
+	State        int      `json:"STATE"`
+	TeamID       int      `json:"TEAM_ID"`
+	ACLID        int      `json:"ACL_ID"`
+	ACLGID       int      `json:"ACL_GID"`
+	VtapIDs      []int    `json:"VTAP_IDS"`    // current agents of VTAP_GROUPS if it is not empty
+	VtapGroups   []string `json:"VTAP_GROUPS"` // lcuuids
+	PayloadSlice *int     `json:"PAYLOAD_SLICE"`
+	NpbTunnelID  int      `json:"NPB_TUNNEL_ID"`
+	Vni          *int     `json:"VNI"`
+	Direction    int      `json:"DIRECTION"`
+	Distribute   int      `json:"DISTRIBUTE"`
+	ExpiredAt    string   `json:"EXPIRED_AT"`
+	Expired      bool     `json:"EXPIRED"`
+	CreatedAt    string   `json:"CREATED_AT"`
+	UpdatedAt    string   `json:"UPDATED_AT"`
+	Lcuuid       string   `json:"LCUUID"`
+	PolicyACL
+}
+
+type InventoryQuery struct {
+	Lcuuids       []string `form:"lcuuid"` // repeated or separated by ,
+	Names         []string `form:"name"`
+	Domain        string   `form:"domain"` // domain lcuuid
+	SubDomain     string   `form:"sub_domain"`
+	Region        string   `form:"region"` // region lcuuid
+	VPCID         *int     `form:"vpc_id"`
+	LabelSelector string   `form:"label_selector"` // kubernetes style, e.g.: app=web,tier!=db,env
+	Fields        []string `form:"fields"`         // json field names, separated by ,
+	PageIndex     int      `form:"page_index" binding:"omitempty,min=1"`
+	PageSize      int      `form:"page_size" binding:"omitempty,min=1,max=10000"`
+}
+
+type InventoryResources struct {
+	Type      string                   `json:"TYPE"`
+	Total     int64                    `json:"TOTAL"`
+	PageIndex int                      `json:"PAGE_INDEX"`
+	PageSize  int                      `json:"PAGE_SIZE"`
+	Resources []map[string]interface{} `json:"RESOURCES"`
+}
+
+type ResourceHistoryQuery struct {
+	Lcuuid     string `form:"lcuuid"`
+	ResourceID int    `form:"resource_id"`
+	Time       string `form:"time"` // unix timestamp in seconds or RFC3339, default: now
+}
+
+type IPHistoryQuery struct {
+	IP   string `form:"ip" binding:"required"`
+	Time string `form:"time"` // unix timestamp in seconds or RFC3339, default: now
+}
+
+type ResourceSnapshot struct {
+	ResourceType   string                 `json:"RESOURCE_TYPE"`
+	ResourceID     int                    `json:"RESOURCE_ID"`
+	ResourceLcuuid string                 `json:"RESOURCE_LCUUID"`
+	ResourceName   string                 `json:"RESOURCE_NAME"`
+	Version        int                    `json:"VERSION"`
+	ValidFrom      string                 `json:"VALID_FROM"`
+	ValidTo        string                 `json:"VALID_TO"` // empty if the snapshot is still valid
+	Content        map[string]interface{} `json:"CONTENT"`
+}
+
+type IPOwnerSnapshot struct {
+	IP         *ResourceSnapshot `json:"IP"`
+	VInterface *ResourceSnapshot `json:"VINTERFACE"`
+	Device     *ResourceSnapshot `json:"DEVICE"`
+}
+
+type AgentBatchExecReq struct {
+	trident.RemoteExecRequest
+
+	CMD           string `json:"cmd" binding:"required"`
+	AgentGroup    string `json:"agent_group"`    // agent group name or lcuuid
+	LabelSelector string `json:"label_selector"` // selects agents by attributes, e.g.: type=7,state=1,region!=xxx
+	Concurrency   int    `json:"concurrency" binding:"omitempty,min=1,max=100"`
+	Timeout       int    `json:"timeout" binding:"omitempty,min=1"` // timeout of each agent, unit: second, max: 10 times of agent-cmd-timeout
+}
+
+type AgentExecResult struct {
+	AgentID   int    `json:"AGENT_ID"`
+	AgentName string `json:"AGENT_NAME"`
+	CtrlIP    string `json:"CTRL_IP"`
+	Status    string `json:"STATUS"` // SUCCESS or FAILED
+	Stdout    string `json:"STDOUT"`
+	Stderr    string `json:"STDERR"`
+	Duration  int64  `json:"DURATION"` // unit: millisecond
+}
+
+type AgentBatchExecResp struct {
+	AuditID int                `json:"AUDIT_ID"`
+	CMD     string             `json:"CMD"`
+	Total   int                `json:"TOTAL"`
+	Success int                `json:"SUCCESS"`
+	Failure int                `json:"FAILURE"`
+	Results []*AgentExecResult `json:"RESULTS"`
+}
+
+type AgentCMDAuditQuery struct {
+	CMD    string `form:"cmd"`
+	UserID *int   `form:"user_id"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=1000"`
+}
@@ -24,10 +24,12 @@ import (
 	"time"
 
 	"golang.org/x/exp/slices"
+	"gorm.io/gorm"
 
 	ctrlrcommon "github.com/deepflowio/deepflow/server/controller/common"
 	"github.com/deepflowio/deepflow/server/controller/db/mysql"
 	mysqlmodel "github.com/deepflowio/deepflow/server/controller/db/mysql/model"
+	"github.com/deepflowio/deepflow/server/controller/election"
 	"github.com/deepflowio/deepflow/server/controller/recorder/common"
 	"github.com/deepflowio/deepflow/server/controller/recorder/config"
 	"github.com/deepflowio/deepflow/server/controller/recorder/constraint"
@@ -287,7 +289,7 @@ func (c *Cleaner) cleanVMDirty() {
 		var vifs []*mysqlmodel.VInterface
 		c.org.DB.Where("devicetype = ? AND deviceid NOT IN ?", ctrlrcommon.VIF_DEVICE_TYPE_VM, vmIDs).Find(&vifs)
 		if len(vifs) != 0 {
-			c.org.DB.Delete(&vifs)
+			deleteFenced(c.org.DB, &vifs)
 			log.Error(formatLogDeleteABecauseBHasGone(ctrlrcommon.RESOURCE_TYPE_VINTERFACE_EN, ctrlrcommon.RESOURCE_TYPE_VM_EN, vifs), c.org.LogPrefix)
 		}
 	}
@@ -299,7 +301,7 @@ func (c *Cleaner) cleanNetworkDirty() {
 		var subnets []*mysqlmodel.Subnet
 		c.org.DB.Where("vl2id NOT IN ?", networkIDs).Find(&subnets)
 		if len(subnets) != 0 {
-			c.org.DB.Delete(&subnets)
+			deleteFenced(c.org.DB, &subnets)
 			log.Error(formatLogDeleteABecauseBHasGone(ctrlrcommon.RESOURCE_TYPE_SUBNET_EN, ctrlrcommon.RESOURCE_TYPE_NETWORK_EN, subnets), c.org.LogPrefix)
 		}
 	}
@@ -311,7 +313,7 @@ func (c *Cleaner) cleanVRouterDirty() {
 		var rts []*mysqlmodel.RoutingTable
 		c.org.DB.Where("vnet_id NOT IN ?", vrouterIDs).Find(&rts)
 		if len(rts) != 0 {
-			c.org.DB.Delete(&rts)
+			deleteFenced(c.org.DB, &rts)
 			log.Error(formatLogDeleteABecauseBHasGone(ctrlrcommon.RESOURCE_TYPE_ROUTING_TABLE_EN, ctrlrcommon.RESOURCE_TYPE_VROUTER_EN, rts), c.org.LogPrefix)
 		}
 	}
@@ -323,14 +325,14 @@ func (c *Cleaner) cleanPodIngressDirty() {
 		var podIngressRules []*mysqlmodel.PodIngressRule
 		c.org.DB.Where("pod_ingress_id NOT IN ?", podIngressIDs).Find(&podIngressRules)
 		if len(podIngressRules) != 0 {
-			c.org.DB.Delete(&podIngressRules)
+			deleteFenced(c.org.DB, &podIngressRules)
 			log.Error(formatLogDeleteABecauseBHasGone(ctrlrcommon.RESOURCE_TYPE_POD_INGRESS_RULE_EN, ctrlrcommon.RESOURCE_TYPE_POD_INGRESS_EN, podIngressRules), c.org.LogPrefix)
 		}
 
 		var podIngressRuleBkds []*mysqlmodel.PodIngressRuleBackend
 		c.org.DB.Where("pod_ingress_id NOT IN ?", podIngressIDs).Find(&podIngressRuleBkds)
 		if len(podIngressRuleBkds) != 0 {
-			c.org.DB.Delete(&podIngressRuleBkds)
+			deleteFenced(c.org.DB, &podIngressRuleBkds)
 			log.Error(formatLogDeleteABecauseBHasGone(ctrlrcommon.RESOURCE_TYPE_POD_INGRESS_RULE_BACKEND_EN, ctrlrcommon.RESOURCE_TYPE_POD_INGRESS_EN, podIngressRuleBkds), c.org.LogPrefix)
 		}
 	}
@@ -342,21 +344,21 @@ func (c *Cleaner) cleanPodServiceDirty() {
 		var podServicePorts []*mysqlmodel.PodServicePort
 		c.org.DB.Where("pod_service_id NOT IN ?", podServiceIDs).Find(&podServicePorts)
 		if len(podServicePorts) != 0 {
-			c.org.DB.Delete(&podServicePorts)
+			deleteFenced(c.org.DB, &podServicePorts)
 			log.Error(formatLogDeleteABecauseBHasGone(ctrlrcommon.RESOURCE_TYPE_POD_SERVICE_PORT_EN, ctrlrcommon.RESOURCE_TYPE_POD_SERVICE_EN, podServicePorts), c.org.LogPrefix)
 		}
 
 		var podGroupPorts []*mysqlmodel.PodGroupPort
 		c.org.DB.Where("pod_service_id NOT IN ?", podServiceIDs).Find(&podGroupPorts)
 		if len(podGroupPorts) != 0 {
-			c.org.DB.Delete(&podGroupPorts)
+			deleteFenced(c.org.DB, &podGroupPorts)
 			log.Error(formatLogDeleteABecauseBHasGone(ctrlrcommon.RESOURCE_TYPE_POD_GROUP_PORT_EN, ctrlrcommon.RESOURCE_TYPE_POD_SERVICE_EN, podGroupPorts), c.org.LogPrefix)
 		}
 
 		var vifs []*mysqlmodel.VInterface
 		c.org.DB.Where("devicetype = ? AND deviceid NOT IN ?", ctrlrcommon.VIF_DEVICE_TYPE_POD_SERVICE, podServiceIDs).Find(&vifs)
 		if len(vifs) != 0 {
-			c.org.DB.Delete(&vifs)
+			deleteFenced(c.org.DB, &vifs)
 			log.Error(formatLogDeleteABecauseBHasGone(ctrlrcommon.RESOURCE_TYPE_VINTERFACE_EN, ctrlrcommon.RESOURCE_TYPE_POD_SERVICE_EN, vifs), c.org.LogPrefix)
 		}
 	}
@@ -368,14 +370,14 @@ func (c *Cleaner) cleanPodGroupDirty() {
 		var podGroupPorts []*mysqlmodel.PodGroupPort
 		c.org.DB.Where("pod_group_id NOT IN ?", podGroupIDs).Find(&podGroupPorts)
 		if len(podGroupPorts) != 0 {
-			c.org.DB.Delete(&podGroupPorts)
+			deleteFenced(c.org.DB, &podGroupPorts)
 			log.Error(formatLogDeleteABecauseBHasGone(ctrlrcommon.RESOURCE_TYPE_POD_GROUP_PORT_EN, ctrlrcommon.RESOURCE_TYPE_POD_GROUP_EN, podGroupPorts), c.org.LogPrefix)
 		}
 
 		var pods []*mysqlmodel.Pod
 		c.org.DB.Where("pod_group_id NOT IN ?", podGroupIDs).Find(&pods)
 		if len(pods) != 0 {
-			c.org.DB.Delete(&pods)
+			deleteFenced(c.org.DB, &pods)
 			publishTagrecorder(c.org.DB, pods, ctrlrcommon.RESOURCE_TYPE_POD_EN, c.toolData)
 			log.Error(formatLogDeleteABecauseBHasGone(ctrlrcommon.RESOURCE_TYPE_POD_EN, ctrlrcommon.RESOURCE_TYPE_POD_GROUP_EN, pods), c.org.LogPrefix)
 		}
@@ -388,21 +390,21 @@ func (c *Cleaner) cleanPodNodeDirty() {
 		var vifs []*mysqlmodel.VInterface
 		c.org.DB.Where("devicetype = ? AND deviceid NOT IN ?", ctrlrcommon.VIF_DEVICE_TYPE_POD_NODE, podNodeIDs).Find(&vifs)
 		if len(vifs) != 0 {
-			c.org.DB.Delete(&vifs)
+			deleteFenced(c.org.DB, &vifs)
 			log.Error(formatLogDeleteABecauseBHasGone(ctrlrcommon.RESOURCE_TYPE_VINTERFACE_EN, ctrlrcommon.RESOURCE_TYPE_POD_NODE_EN, vifs), c.org.LogPrefix)
 		}
 
 		var vmPodNodeConns []*mysqlmodel.VMPodNodeConnection
 		c.org.DB.Where("pod_node_id NOT IN ?", podNodeIDs).Find(&vmPodNodeConns)
 		if len(vmPodNodeConns) != 0 {
-			c.org.DB.Delete(&vmPodNodeConns)
+			deleteFenced(c.org.DB, &vmPodNodeConns)
 			log.Error(formatLogDeleteABecauseBHasGone(ctrlrcommon.RESOURCE_TYPE_VM_POD_NODE_CONNECTION_EN, ctrlrcommon.RESOURCE_TYPE_POD_NODE_EN, vmPodNodeConns), c.org.LogPrefix)
 		}
 
 		var pods []*mysqlmodel.Pod
 		c.org.DB.Where("pod_node_id != 0 AND pod_node_id NOT IN ?", podNodeIDs).Find(&pods)
 		if len(pods) != 0 {
-			c.org.DB.Delete(&pods)
+			deleteFenced(c.org.DB, &pods)
 			publishTagrecorder(c.org.DB, pods, ctrlrcommon.RESOURCE_TYPE_POD_EN, c.toolData)
 			log.Error(formatLogDeleteABecauseBHasGone(ctrlrcommon.RESOURCE_TYPE_POD_EN, ctrlrcommon.RESOURCE_TYPE_POD_NODE_EN, pods), c.org.LogPrefix)
 		}
@@ -415,7 +417,7 @@ func (c *Cleaner) cleanPodDirty() {
 		var vifs []*mysqlmodel.VInterface
 		c.org.DB.Where("devicetype = ? AND deviceid NOT IN ?", ctrlrcommon.VIF_DEVICE_TYPE_POD, podIDs).Find(&vifs)
 		if len(vifs) != 0 {
-			c.org.DB.Delete(&vifs)
+			deleteFenced(c.org.DB, &vifs)
 			log.Error(formatLogDeleteABecauseBHasGone(ctrlrcommon.RESOURCE_TYPE_VINTERFACE_EN, ctrlrcommon.RESOURCE_TYPE_POD_EN, vifs), c.org.LogPrefix)
 		}
 	}
@@ -427,13 +429,13 @@ func (c *Cleaner) cleanVInterfaceDirty() {
 		var lanIPs []*mysqlmodel.LANIP
 		c.org.DB.Where("vifid NOT IN ?", vifIDs).Find(&lanIPs)
 		if len(lanIPs) != 0 {
-			c.org.DB.Delete(&lanIPs)
+			deleteFenced(c.org.DB, &lanIPs)
 			log.Error(formatLogDeleteABecauseBHasGone(ctrlrcommon.RESOURCE_TYPE_LAN_IP_EN, ctrlrcommon.RESOURCE_TYPE_VINTERFACE_EN, lanIPs), c.org.LogPrefix)
 		}
 		var wanIPs []*mysqlmodel.WANIP
 		c.org.DB.Where("vifid NOT IN ?", vifIDs).Find(&wanIPs)
 		if len(wanIPs) != 0 {
-			c.org.DB.Delete(&wanIPs)
+			deleteFenced(c.org.DB, &wanIPs)
 			log.Error(formatLogDeleteABecauseBHasGone(ctrlrcommon.RESOURCE_TYPE_WAN_IP_EN, ctrlrcommon.RESOURCE_TYPE_VINTERFACE_EN, wanIPs), c.org.LogPrefix)
 		}
 	}
@@ -457,13 +459,26 @@ func deleteExpired[MT constraint.MySQLSoftDeleteModel](db *mysql.DB, expiredAt t
 	if len(dbItems) == 0 {
 		return nil
 	}
-	if err := db.Unscoped().Delete(&dbItems).Error; err != nil {
+	err = election.FencedTransaction(db.DB, func(tx *gorm.DB) error {
+		return tx.Unscoped().Delete(&dbItems).Error
+	})
+	if err != nil {
 		log.Errorf("mysql delete resource failed: %s", err.Error(), db.LogPrefixORGID)
 		return nil
 	}
 	return dbItems
 }
 
+// cleaning is leader-only, deletes are fenced so that they are never done after the leadership has changed hands
+func deleteFenced(db *mysql.DB, value interface{}) {
+	err := election.FencedTransaction(db.DB, func(tx *gorm.DB) error {
+		return tx.Delete(value).Error
+	})
+	if err != nil {
+		log.Errorf("mysql delete resource failed: %s", err.Error(), db.LogPrefixORGID)
+	}
+}
+
 func getIDs[MT constraint.MySQLModel](db *mysql.DB) (ids []int) {
 	var dbItems []*MT
 	db.Select("id").Find(&dbItems)
@@ -25,7 +25,9 @@ type RecorderConfig struct {
 	ResourceMaxID0               int    `default:"64000" yaml:"resource_max_id_0"`
 	ResourceMaxID1               int    `default:"499999" yaml:"resource_max_id_1"`
 
-	LogDebug LogDebugConfig `yaml:"log_debug"`
+	LogDebug        LogDebugConfig        `yaml:"log_debug"`
+	ResourceHistory ResourceHistoryConfig `yaml:"resource_history"`
+	EventSink       EventSinkConfig       `yaml:"event_sink"`
 }
 
 func Get() *RecorderConfig {
@@ -41,3 +43,32 @@ type LogDebugConfig struct {
 	DetailEnabled bool     `default:"false" yaml:"detail_enabled"`
 	ResourceTypes []string `default:"" yaml:"resource_type"`
 }
+
+type ResourceHistoryConfig struct {
+	Enabled       bool   `default:"true" yaml:"enabled"`
+	CleanInterval uint16 `default:"1" yaml:"clean_interval"`
+	RetentionTime uint16 `default:"168" yaml:"retention_time"`
+}
+
+type EventSinkConfig struct {
+	Enabled       bool         `default:"false" yaml:"enabled"`
+	FlushInterval int          `default:"5" yaml:"flush_interval"` // unit: second
+	BatchSize     int          `default:"100" yaml:"batch_size"`
+	MaxRetries    int          `default:"5" yaml:"max_retries"`
+	RetryBackoff  int          `default:"1" yaml:"retry_backoff"`   // unit: second, doubled on each retry
+	RetentionTime int          `default:"24" yaml:"retention_time"` // unit: hour
+	CommitWindow  int          `default:"10" yaml:"commit_window"`  // unit: second
+	Sinks         []SinkConfig `yaml:"sinks"`
+}
+
+type SinkConfig struct {
+	Name          string            `yaml:"name"`
+	Type          string            `yaml:"type"` // webhook or kafka
+	URL           string            `yaml:"url"`
+	Headers       map[string]string `yaml:"headers"`
+	Timeout       int               `yaml:"timeout"` // unit: second, default: 10
+	Brokers       []string          `yaml:"brokers"`
+	Topic         string            `yaml:"topic"`
+	ResourceTypes []string          `yaml:"resource_types"` // empty means all
+	Domains       []string          `yaml:"domains"`        // domain lcuuids, empty means all
+}
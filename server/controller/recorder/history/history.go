@@ -0,0 +1,131 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package history persists the change stream of recorder as versioned resource snapshots,
+// so that the state of a resource at any point in time within retention can be queried.
+package history
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	mysqlmodel "github.com/deepflowio/deepflow/server/controller/db/mysql/model"
+	"github.com/deepflowio/deepflow/server/controller/election"
+	"github.com/deepflowio/deepflow/server/controller/recorder/config"
+	"github.com/deepflowio/deepflow/server/controller/recorder/mysqlchange"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+var log = logger.MustGetLogger("recorder.history")
+
+var (
+	historyOnce sync.Once
+	history     *History
+)
+
+type History struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	cfg    config.ResourceHistoryConfig
+
+	subscriber *mysqlchange.Subscriber
+}
+
+func GetHistory() *History {
+	historyOnce.Do(func() {
+		history = new(History)
+	})
+	return history
+}
+
+func (h *History) Init(ctx context.Context, cfg config.RecorderConfig) {
+	h.ctx, h.cancel = context.WithCancel(ctx)
+	h.cfg = cfg.ResourceHistory
+	h.subscriber = mysqlchange.NewSubscriber("resource history", h.handle)
+}
+
+// Start subscribes to all resource pubsubs, it should be called before recorder starts publishing messages.
+func (h *History) Start() {
+	if !h.cfg.Enabled {
+		log.Info("resource history disabled")
+		return
+	}
+	h.subscriber.Start(h.ctx)
+	log.Info("resource history started")
+}
+
+// StartCleaner backfills baseline snapshots and starts timed cleaning of expired snapshots, it only runs on master controller.
+func (h *History) StartCleaner(sContext context.Context) {
+	if !h.cfg.Enabled {
+		return
+	}
+	go func() {
+		h.backfill(sContext)
+		h.clean()
+		ticker := time.NewTicker(time.Duration(int(h.cfg.CleanInterval)) * time.Hour)
+		defer ticker.Stop()
+
+	LOOP:
+		for {
+			select {
+			case <-ticker.C:
+				h.clean()
+			case <-sContext.Done():
+				break LOOP
+			case <-h.ctx.Done():
+				break LOOP
+			}
+		}
+	}()
+}
+
+func (h *History) Stop() {
+	if h.cancel != nil {
+		h.cancel()
+	}
+	log.Info("resource history stopped")
+}
+
+func (h *History) clean() {
+	orgIDs, err := mysql.GetORGIDs()
+	if err != nil {
+		log.Errorf("failed to get org ids: %s", err.Error())
+		return
+	}
+	expiredAt := time.Now().Add(time.Duration(-int(h.cfg.RetentionTime)) * time.Hour)
+	for _, orgID := range orgIDs {
+		db, err := mysql.GetDB(orgID)
+		if err != nil {
+			log.Errorf("failed to get db: %s", err.Error(), logger.NewORGPrefix(orgID))
+			continue
+		}
+		var cleaned int64
+		err = election.FencedTransaction(db.DB, func(tx *gorm.DB) error {
+			result := tx.Where("valid_to < ?", expiredAt).Delete(&mysqlmodel.ResourceSnapshot{})
+			cleaned = result.RowsAffected
+			return result.Error
+		})
+		if err != nil {
+			log.Errorf("failed to clean resource snapshots (valid_to < %s): %s", expiredAt, err.Error(), db.LogPrefixORGID)
+			continue
+		}
+		log.Infof("cleaned %d resource snapshots (valid_to < %s)", cleaned, expiredAt, db.LogPrefixORGID)
+	}
+}
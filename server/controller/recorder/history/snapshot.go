@@ -0,0 +1,99 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	mysqlmodel "github.com/deepflowio/deepflow/server/controller/db/mysql/model"
+	"github.com/deepflowio/deepflow/server/controller/recorder/mysqlchange"
+)
+
+// newSnapshot converts a resource MySQL item to an open snapshot which is valid from the given time,
+// name, ip and domain are extracted from the json content to be indexed for querying.
+func newSnapshot(resourceType string, item mysqlchange.Item, validFrom time.Time) (*mysqlmodel.ResourceSnapshot, error) {
+	content, err := json.Marshal(item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s (lcuuid: %s): %s", resourceType, item.GetLcuuid(), err.Error())
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s (lcuuid: %s): %s", resourceType, item.GetLcuuid(), err.Error())
+	}
+	return &mysqlmodel.ResourceSnapshot{
+		ResourceType:   resourceType,
+		ResourceID:     item.GetID(),
+		ResourceLcuuid: item.GetLcuuid(),
+		ResourceName:   stringField(fields, "NAME"),
+		IP:             stringField(fields, "IP"),
+		Domain:         stringField(fields, "DOMAIN"),
+		Version:        1,
+		Content:        string(content),
+		ValidFrom:      validFrom,
+	}, nil
+}
+
+func stringField(fields map[string]interface{}, key string) string {
+	if v, ok := fields[key].(string); ok {
+		return v
+	}
+	return ""
+}
+
+// saveSnapshots closes the currently valid snapshots of the same resources and saves the new ones as their next versions.
+func saveSnapshots(db *mysql.DB, resourceType string, snapshots []*mysqlmodel.ResourceSnapshot, at time.Time) error {
+	if len(snapshots) == 0 {
+		return nil
+	}
+	lcuuids := make([]string, 0, len(snapshots))
+	for _, s := range snapshots {
+		lcuuids = append(lcuuids, s.ResourceLcuuid)
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		var versions []struct {
+			ResourceLcuuid string
+			Version        int
+		}
+		if err := tx.Model(&mysqlmodel.ResourceSnapshot{}).Select("resource_lcuuid, MAX(version) AS version").
+			Where("resource_type = ? AND resource_lcuuid IN ?", resourceType, lcuuids).
+			Group("resource_lcuuid").Scan(&versions).Error; err != nil {
+			return err
+		}
+		lcuuidToVersion := make(map[string]int, len(versions))
+		for _, v := range versions {
+			lcuuidToVersion[v.ResourceLcuuid] = v.Version
+		}
+		if err := closeOpenSnapshots(tx, resourceType, lcuuids, at); err != nil {
+			return err
+		}
+		for _, s := range snapshots {
+			s.Version = lcuuidToVersion[s.ResourceLcuuid] + 1
+		}
+		return tx.CreateInBatches(snapshots, 100).Error
+	})
+}
+
+func closeOpenSnapshots(db *gorm.DB, resourceType string, lcuuids []string, at time.Time) error {
+	return db.Model(&mysqlmodel.ResourceSnapshot{}).
+		Where("resource_type = ? AND resource_lcuuid IN ? AND valid_to IS NULL", resourceType, lcuuids).
+		Update("valid_to", at).Error
+}
@@ -0,0 +1,52 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package history
+
+import (
+	"testing"
+	"time"
+
+	mysqlmodel "github.com/deepflowio/deepflow/server/controller/db/mysql/model"
+)
+
+func Test_newSnapshot(t *testing.T) {
+	now := time.Now()
+	ip := &mysqlmodel.LANIP{IP: "10.1.1.1", Domain: "domain-lcuuid", VInterfaceID: 3}
+	ip.ID = 1
+	ip.Lcuuid = "ip-lcuuid"
+	s, err := newSnapshot("lan_ip", ip, now)
+	if err != nil {
+		t.Fatalf("newSnapshot() error = %v", err)
+	}
+	if s.ResourceID != 1 || s.ResourceLcuuid != "ip-lcuuid" || s.IP != "10.1.1.1" || s.Domain != "domain-lcuuid" || s.ResourceName != "" {
+		t.Errorf("newSnapshot() = %+v", s)
+	}
+	if s.Version != 1 || !s.ValidFrom.Equal(now) || s.ValidTo != nil {
+		t.Errorf("newSnapshot() version/validity = %d %v %v", s.Version, s.ValidFrom, s.ValidTo)
+	}
+
+	pod := &mysqlmodel.Pod{Name: "web-0", Domain: "domain-lcuuid"}
+	pod.ID = 2
+	pod.Lcuuid = "pod-lcuuid"
+	s, err = newSnapshot("pod", pod, now)
+	if err != nil {
+		t.Fatalf("newSnapshot() error = %v", err)
+	}
+	if s.ResourceName != "web-0" || s.IP != "" {
+		t.Errorf("newSnapshot() = %+v", s)
+	}
+}
@@ -0,0 +1,147 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package history
+
+import (
+	"context"
+	"time"
+
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	mysqlmodel "github.com/deepflowio/deepflow/server/controller/db/mysql/model"
+	"github.com/deepflowio/deepflow/server/controller/recorder/mysqlchange"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+const BACKFILL_BATCH_SIZE = 1000
+
+// handle saves snapshots of added or updated resources and closes snapshots of deleted resources,
+// it is called by the subscriber goroutine, so that recorder is never blocked by MySQL writes.
+func (h *History) handle(change *mysqlchange.Change) {
+	db, err := mysql.GetDB(change.ORGID)
+	if err != nil {
+		log.Errorf("failed to get db: %s", err.Error(), logger.NewORGPrefix(change.ORGID))
+		return
+	}
+	now := time.Now()
+	if change.Action == mysqlchange.ACTION_DELETED {
+		lcuuids := make([]string, 0, len(change.Items))
+		for _, item := range change.Items {
+			lcuuids = append(lcuuids, item.GetLcuuid())
+		}
+		if err := closeOpenSnapshots(db.DB, change.ResourceType, lcuuids, now); err != nil {
+			log.Errorf("failed to close %s snapshots: %s", change.ResourceType, err.Error(), db.LogPrefixORGID)
+		}
+		return
+	}
+	if err := saveSnapshots(db, change.ResourceType, newSnapshots(db, change.ResourceType, change.Items, now), now); err != nil {
+		log.Errorf("failed to save %s snapshots: %s", change.ResourceType, err.Error(), db.LogPrefixORGID)
+	}
+}
+
+func newSnapshots(db *mysql.DB, resourceType string, items []mysqlchange.Item, now time.Time) []*mysqlmodel.ResourceSnapshot {
+	snapshots := make([]*mysqlmodel.ResourceSnapshot, 0, len(items))
+	for _, item := range items {
+		snapshot, err := newSnapshot(resourceType, item, now)
+		if err != nil {
+			log.Error(err.Error(), db.LogPrefixORGID)
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots
+}
+
+// backfill saves baseline snapshots of existing resources which have no valid snapshot, such as resources
+// recorded before resource history is deployed, or whose changes are dropped, and closes valid snapshots of
+// resources which no longer exist, whose deletions are dropped, it runs once on master controller start.
+func (h *History) backfill(ctx context.Context) {
+	orgIDs, err := mysql.GetORGIDs()
+	if err != nil {
+		log.Errorf("failed to get org ids: %s", err.Error())
+		return
+	}
+	for _, orgID := range orgIDs {
+		db, err := mysql.GetDB(orgID)
+		if err != nil {
+			log.Errorf("failed to get db: %s", err.Error(), logger.NewORGPrefix(orgID))
+			continue
+		}
+		for _, resourceType := range mysqlchange.ResourceTypes() {
+			if ctx.Err() != nil || h.ctx.Err() != nil {
+				return
+			}
+			saved, closed, err := backfillResourceType(db, resourceType)
+			if err != nil {
+				log.Errorf("failed to backfill %s snapshots: %s", resourceType, err.Error(), db.LogPrefixORGID)
+				continue
+			}
+			if saved > 0 || closed > 0 {
+				log.Infof("backfilled %d %s snapshots, closed %d", saved, resourceType, closed, db.LogPrefixORGID)
+			}
+		}
+	}
+}
+
+func backfillResourceType(db *mysql.DB, resourceType string) (saved, closed int, err error) {
+	var lcuuids []string
+	if err := db.Model(&mysqlmodel.ResourceSnapshot{}).Where("resource_type = ? AND valid_to IS NULL", resourceType).
+		Pluck("resource_lcuuid", &lcuuids).Error; err != nil {
+		return 0, 0, err
+	}
+	// lcuuids left in notFound after all resources are iterated are the ones which have been deleted
+	notFound := make(map[string]struct{}, len(lcuuids))
+	for _, lcuuid := range lcuuids {
+		notFound[lcuuid] = struct{}{}
+	}
+	err = mysqlchange.FindInBatches(db.DB, resourceType, BACKFILL_BATCH_SIZE, func(items []mysqlchange.Item) error {
+		missing := make([]mysqlchange.Item, 0, len(items))
+		for _, item := range items {
+			if _, ok := notFound[item.GetLcuuid()]; ok {
+				delete(notFound, item.GetLcuuid())
+			} else {
+				missing = append(missing, item)
+			}
+		}
+		now := time.Now()
+		snapshots := newSnapshots(db, resourceType, missing, now)
+		if err := saveSnapshots(db, resourceType, snapshots, now); err != nil {
+			return err
+		}
+		saved += len(snapshots)
+		return nil
+	})
+	if err != nil {
+		return saved, 0, err
+	}
+
+	deleted := make([]string, 0, len(notFound))
+	for lcuuid := range notFound {
+		deleted = append(deleted, lcuuid)
+	}
+	now := time.Now()
+	for start := 0; start < len(deleted); start += BACKFILL_BATCH_SIZE {
+		end := start + BACKFILL_BATCH_SIZE
+		if end > len(deleted) {
+			end = len(deleted)
+		}
+		if err := closeOpenSnapshots(db.DB, resourceType, deleted[start:end], now); err != nil {
+			return saved, closed, err
+		}
+		closed += end - start
+	}
+	return saved, closed, nil
+}
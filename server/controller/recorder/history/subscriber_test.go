@@ -0,0 +1,84 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package history
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	mysqlmodel "github.com/deepflowio/deepflow/server/controller/db/mysql/model"
+)
+
+func Test_backfillResourceType(t *testing.T) {
+	gormDB, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "history.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gormDB.AutoMigrate(&mysqlmodel.Pod{}); err != nil {
+		t.Fatal(err)
+	}
+	// bigint primary key of the model is not auto incremented by SQLite
+	if err := gormDB.Exec(`CREATE TABLE resource_snapshots (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		resource_type VARCHAR(64) NOT NULL,
+		resource_id INT DEFAULT 0,
+		resource_lcuuid CHAR(64) NOT NULL,
+		resource_name VARCHAR(256) DEFAULT '',
+		ip VARCHAR(64) DEFAULT '',
+		domain CHAR(64) DEFAULT '',
+		version INT DEFAULT 1,
+		content TEXT,
+		valid_from DATETIME NOT NULL,
+		valid_to DATETIME DEFAULT NULL)`).Error; err != nil {
+		t.Fatal(err)
+	}
+	db := &mysql.DB{DB: gormDB}
+	for _, lcuuid := range []string{"recorded-lcuuid", "missing-lcuuid"} {
+		pod := &mysqlmodel.Pod{Name: lcuuid}
+		pod.Lcuuid = lcuuid
+		if err := db.Create(pod).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	for _, lcuuid := range []string{"recorded-lcuuid", "deleted-lcuuid"} {
+		if err := db.Create(&mysqlmodel.ResourceSnapshot{ResourceType: "pod", ResourceLcuuid: lcuuid, Version: 1, ValidFrom: now}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	saved, closed, err := backfillResourceType(db, "pod")
+	if err != nil {
+		t.Fatalf("backfillResourceType() error = %v", err)
+	}
+	if saved != 1 || closed != 1 {
+		t.Errorf("backfillResourceType() saved %d, closed %d", saved, closed)
+	}
+	var open []string
+	if err := db.Model(&mysqlmodel.ResourceSnapshot{}).Where("valid_to IS NULL").Order("resource_lcuuid").
+		Pluck("resource_lcuuid", &open).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(open) != 2 || open[0] != "missing-lcuuid" || open[1] != "recorded-lcuuid" {
+		t.Errorf("open snapshots = %v", open)
+	}
+}
@@ -0,0 +1,145 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mysqlchange
+
+import (
+	"gorm.io/gorm"
+
+	ctrlrcommon "github.com/deepflowio/deepflow/server/controller/common"
+	mysqlmodel "github.com/deepflowio/deepflow/server/controller/db/mysql/model"
+	"github.com/deepflowio/deepflow/server/controller/recorder/constraint"
+	"github.com/deepflowio/deepflow/server/controller/recorder/pubsub"
+	"github.com/deepflowio/deepflow/server/controller/recorder/pubsub/message"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+type resource interface {
+	resourceType() string
+	subscribe(s *Subscriber)
+	findInBatches(db *gorm.DB, batchSize int, f func(items []Item) error) error
+}
+
+var resources = []resource{
+	newResource[*mysqlmodel.Region](ctrlrcommon.RESOURCE_TYPE_REGION_EN),
+	newResource[*mysqlmodel.AZ](ctrlrcommon.RESOURCE_TYPE_AZ_EN),
+	newResource[*mysqlmodel.SubDomain](ctrlrcommon.RESOURCE_TYPE_SUB_DOMAIN_EN),
+	newResource[*mysqlmodel.Host](ctrlrcommon.RESOURCE_TYPE_HOST_EN),
+	newResource[*mysqlmodel.VM](ctrlrcommon.RESOURCE_TYPE_VM_EN),
+	newResource[*mysqlmodel.VPC](ctrlrcommon.RESOURCE_TYPE_VPC_EN),
+	newResource[*mysqlmodel.Network](ctrlrcommon.RESOURCE_TYPE_NETWORK_EN),
+	newResource[*mysqlmodel.Subnet](ctrlrcommon.RESOURCE_TYPE_SUBNET_EN),
+	newResource[*mysqlmodel.VRouter](ctrlrcommon.RESOURCE_TYPE_VROUTER_EN),
+	newResource[*mysqlmodel.RoutingTable](ctrlrcommon.RESOURCE_TYPE_ROUTING_TABLE_EN),
+	newResource[*mysqlmodel.DHCPPort](ctrlrcommon.RESOURCE_TYPE_DHCP_PORT_EN),
+	newResource[*mysqlmodel.VInterface](ctrlrcommon.RESOURCE_TYPE_VINTERFACE_EN),
+	newResource[*mysqlmodel.WANIP](ctrlrcommon.RESOURCE_TYPE_WAN_IP_EN),
+	newResource[*mysqlmodel.LANIP](ctrlrcommon.RESOURCE_TYPE_LAN_IP_EN),
+	newResource[*mysqlmodel.FloatingIP](ctrlrcommon.RESOURCE_TYPE_FLOATING_IP_EN),
+	newResource[*mysqlmodel.VIP](ctrlrcommon.RESOURCE_TYPE_VIP_EN),
+	newResource[*mysqlmodel.NATGateway](ctrlrcommon.RESOURCE_TYPE_NAT_GATEWAY_EN),
+	newResource[*mysqlmodel.NATRule](ctrlrcommon.RESOURCE_TYPE_NAT_RULE_EN),
+	newResource[*mysqlmodel.NATVMConnection](ctrlrcommon.RESOURCE_TYPE_NAT_VM_CONNECTION_EN),
+	newResource[*mysqlmodel.LB](ctrlrcommon.RESOURCE_TYPE_LB_EN),
+	newResource[*mysqlmodel.LBListener](ctrlrcommon.RESOURCE_TYPE_LB_LISTENER_EN),
+	newResource[*mysqlmodel.LBTargetServer](ctrlrcommon.RESOURCE_TYPE_LB_TARGET_SERVER_EN),
+	newResource[*mysqlmodel.LBVMConnection](ctrlrcommon.RESOURCE_TYPE_LB_VM_CONNECTION_EN),
+	newResource[*mysqlmodel.CEN](ctrlrcommon.RESOURCE_TYPE_CEN_EN),
+	newResource[*mysqlmodel.PeerConnection](ctrlrcommon.RESOURCE_TYPE_PEER_CONNECTION_EN),
+	newResource[*mysqlmodel.RDSInstance](ctrlrcommon.RESOURCE_TYPE_RDS_INSTANCE_EN),
+	newResource[*mysqlmodel.RedisInstance](ctrlrcommon.RESOURCE_TYPE_REDIS_INSTANCE_EN),
+	newResource[*mysqlmodel.PodCluster](ctrlrcommon.RESOURCE_TYPE_POD_CLUSTER_EN),
+	newResource[*mysqlmodel.PodNode](ctrlrcommon.RESOURCE_TYPE_POD_NODE_EN),
+	newResource[*mysqlmodel.VMPodNodeConnection](ctrlrcommon.RESOURCE_TYPE_VM_POD_NODE_CONNECTION_EN),
+	newResource[*mysqlmodel.PodNamespace](ctrlrcommon.RESOURCE_TYPE_POD_NAMESPACE_EN),
+	newResource[*mysqlmodel.PodIngress](ctrlrcommon.RESOURCE_TYPE_POD_INGRESS_EN),
+	newResource[*mysqlmodel.PodIngressRule](ctrlrcommon.RESOURCE_TYPE_POD_INGRESS_RULE_EN),
+	newResource[*mysqlmodel.PodIngressRuleBackend](ctrlrcommon.RESOURCE_TYPE_POD_INGRESS_RULE_BACKEND_EN),
+	newResource[*mysqlmodel.PodService](ctrlrcommon.RESOURCE_TYPE_POD_SERVICE_EN),
+	newResource[*mysqlmodel.PodServicePort](ctrlrcommon.RESOURCE_TYPE_POD_SERVICE_PORT_EN),
+	newResource[*mysqlmodel.PodGroup](ctrlrcommon.RESOURCE_TYPE_POD_GROUP_EN),
+	newResource[*mysqlmodel.PodGroupPort](ctrlrcommon.RESOURCE_TYPE_POD_GROUP_PORT_EN),
+	newResource[*mysqlmodel.PodReplicaSet](ctrlrcommon.RESOURCE_TYPE_POD_REPLICA_SET_EN),
+	newResource[*mysqlmodel.Pod](ctrlrcommon.RESOURCE_TYPE_POD_EN),
+	newResource[*mysqlmodel.Process](ctrlrcommon.RESOURCE_TYPE_PROCESS_EN),
+}
+
+// modelResource converts pubsub messages of one type of resource to changes
+type modelResource[MPT constraint.MySQLModelPtr[MT], MT constraint.MySQLModel] struct {
+	rType string
+}
+
+func newResource[MPT constraint.MySQLModelPtr[MT], MT constraint.MySQLModel](resourceType string) *modelResource[MPT, MT] {
+	return &modelResource[MPT, MT]{rType: resourceType}
+}
+
+func (r *modelResource[MPT, MT]) resourceType() string {
+	return r.rType
+}
+
+func (r *modelResource[MPT, MT]) subscribe(s *Subscriber) {
+	l := &listener[MPT, MT]{resource: r, subscriber: s}
+	pubsub.Subscribe(r.rType, pubsub.TopicResourceBatchAddedMySQL, l)
+	pubsub.Subscribe(r.rType, pubsub.TopicResourceUpdatedMessageUpdate, l)
+	pubsub.Subscribe(r.rType, pubsub.TopicResourceBatchDeletedMySQL, l)
+}
+
+func (r *modelResource[MPT, MT]) findInBatches(db *gorm.DB, batchSize int, f func(items []Item) error) error {
+	var items []*MT
+	return db.FindInBatches(&items, batchSize, func(tx *gorm.DB, batch int) error {
+		return f(r.toItems(items))
+	}).Error
+}
+
+func (r *modelResource[MPT, MT]) toItems(items []*MT) []Item {
+	result := make([]Item, 0, len(items))
+	for _, item := range items {
+		if item != nil {
+			result = append(result, MPT(item))
+		}
+	}
+	return result
+}
+
+type listener[MPT constraint.MySQLModelPtr[MT], MT constraint.MySQLModel] struct {
+	resource   *modelResource[MPT, MT]
+	subscriber *Subscriber
+}
+
+func (l *listener[MPT, MT]) enqueue(md *message.Metadata, action string, items []*MT) {
+	l.subscriber.enqueue(&Change{ORGID: md.ORGID, ResourceType: l.resource.rType, Action: action, Items: l.resource.toItems(items)})
+}
+
+// OnResourceBatchAdded implements interface Subscriber in recorder/pubsub/subscriber.go
+func (l *listener[MPT, MT]) OnResourceBatchAdded(md *message.Metadata, msg interface{}) {
+	l.enqueue(md, ACTION_ADDED, msg.([]*MT))
+}
+
+// OnResourceUpdated implements interface Subscriber in recorder/pubsub/subscriber.go,
+// all update messages embed message.MySQLData[MT] which carries the updated item
+func (l *listener[MPT, MT]) OnResourceUpdated(md *message.Metadata, msg interface{}) {
+	update, ok := msg.(interface{ GetNewMySQL() *MT })
+	if !ok {
+		log.Errorf("%s update message (%T) has no new mysql item", l.resource.rType, msg, logger.NewORGPrefix(md.ORGID))
+		return
+	}
+	l.enqueue(md, ACTION_UPDATED, []*MT{update.GetNewMySQL()})
+}
+
+// OnResourceBatchDeleted implements interface Subscriber in recorder/pubsub/subscriber.go
+func (l *listener[MPT, MT]) OnResourceBatchDeleted(md *message.Metadata, msg interface{}) {
+	l.enqueue(md, ACTION_DELETED, msg.([]*MT))
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package mysqlchange subscribes to MySQL items of all resource types added, updated or deleted by recorder,
+// and hands them to a handler in a separate goroutine, so that slow handlers never block recorder.
+package mysqlchange
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+var log = logger.MustGetLogger("recorder.mysqlchange")
+
+const (
+	ACTION_ADDED   = "added"
+	ACTION_UPDATED = "updated"
+	ACTION_DELETED = "deleted"
+
+	QUEUE_SIZE = 4096
+)
+
+// Item is a resource MySQL item
+type Item interface {
+	GetID() int
+	GetLcuuid() string
+}
+
+// Change is a batch of items of one resource type added, updated or deleted together
+type Change struct {
+	ORGID        int
+	ResourceType string
+	Action       string
+	Items        []Item
+}
+
+type Handler func(change *Change)
+
+// Subscriber queues changes of all resource types and calls handler with them one by one,
+// changes are dropped if handler falls behind by more than QUEUE_SIZE batches.
+type Subscriber struct {
+	name    string
+	handler Handler
+	queue   chan *Change
+}
+
+func NewSubscriber(name string, handler Handler) *Subscriber {
+	return &Subscriber{
+		name:    name,
+		handler: handler,
+		queue:   make(chan *Change, QUEUE_SIZE),
+	}
+}
+
+// Start subscribes to all resource pubsubs and starts handling changes until ctx is done,
+// it should be called before recorder starts publishing messages.
+func (s *Subscriber) Start(ctx context.Context) {
+	for _, r := range resources {
+		r.subscribe(s)
+	}
+	go s.run(ctx)
+}
+
+func (s *Subscriber) run(ctx context.Context) {
+	for {
+		select {
+		case change := <-s.queue:
+			s.handler(change)
+		case <-ctx.Done():
+			log.Infof("%s subscriber stopped, %d changes are not handled", s.name, len(s.queue))
+			return
+		}
+	}
+}
+
+func (s *Subscriber) enqueue(change *Change) {
+	if len(change.Items) == 0 {
+		return
+	}
+	select {
+	case s.queue <- change:
+	default:
+		log.Errorf("%s subscriber queue is full, %d %s %s items are dropped",
+			s.name, len(change.Items), change.ResourceType, change.Action, logger.NewORGPrefix(change.ORGID))
+	}
+}
+
+// ResourceTypes returns all subscribed resource types
+func ResourceTypes() []string {
+	types := make([]string, 0, len(resources))
+	for _, r := range resources {
+		types = append(types, r.resourceType())
+	}
+	return types
+}
+
+// FindInBatches finds all items of resourceType in db and calls f with every batch of them
+func FindInBatches(db *gorm.DB, resourceType string, batchSize int, f func(items []Item) error) error {
+	for _, r := range resources {
+		if r.resourceType() == resourceType {
+			return r.findInBatches(db, batchSize, f)
+		}
+	}
+	return nil
+}
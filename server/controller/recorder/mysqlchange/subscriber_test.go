@@ -0,0 +1,63 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mysqlchange
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type testItem struct {
+	id int
+}
+
+func (i testItem) GetID() int        { return i.id }
+func (i testItem) GetLcuuid() string { return "" }
+
+func TestSubscriberQueue(t *testing.T) {
+	handled := make(chan *Change)
+	s := NewSubscriber("test", func(change *Change) {
+		handled <- change
+	})
+	// changes beyond QUEUE_SIZE are dropped instead of blocking recorder when the handler falls behind
+	for i := 0; i < QUEUE_SIZE+2; i++ {
+		s.enqueue(&Change{ResourceType: "vm", Action: ACTION_ADDED, Items: []Item{testItem{id: i}}})
+	}
+	s.enqueue(&Change{ResourceType: "vm", Action: ACTION_ADDED})
+	if len(s.queue) != QUEUE_SIZE {
+		t.Fatalf("unexpected queue length %d", len(s.queue))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.queue = make(chan *Change, QUEUE_SIZE)
+	go s.run(ctx)
+	for i := 0; i < 3; i++ {
+		s.enqueue(&Change{ResourceType: "vm", Action: ACTION_UPDATED, Items: []Item{testItem{id: i}}})
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case change := <-handled:
+			if change.Items[0].GetID() != i {
+				t.Fatalf("change %d is handled out of order", change.Items[0].GetID())
+			}
+		case <-time.After(time.Second):
+			t.Fatal("change is not handled")
+		}
+	}
+}
@@ -110,6 +110,14 @@ func (m *MySQLData[MT]) SetNewMySQL(new *MT) {
 	m.new = new
 }
 
+func (m *MySQLData[MT]) GetNewMySQLItem() interface{} {
+	return m.new
+}
+
+func (m *MySQLData[MT]) SetNewMySQLItem(new interface{}) {
+	m.new = new.(*MT)
+}
+
 func (m *MySQLData[MT]) GetOldMySQL() *MT {
 	return m.old
 }
@@ -22,6 +22,8 @@ import (
 
 	"github.com/deepflowio/deepflow/server/controller/recorder/config"
 	"github.com/deepflowio/deepflow/server/controller/recorder/db/idmng"
+	"github.com/deepflowio/deepflow/server/controller/recorder/history"
+	"github.com/deepflowio/deepflow/server/controller/recorder/sink"
 )
 
 var (
@@ -32,6 +34,8 @@ var (
 type Resource struct {
 	Cleaners   *Cleaners
 	IDManagers *idmng.IDManagers
+	History    *history.History
+	Sink       *sink.Sink
 }
 
 func GetResource() *Resource {
@@ -39,6 +43,8 @@ func GetResource() *Resource {
 		resource = &Resource{
 			Cleaners:   GetCleaners(),
 			IDManagers: idmng.GetIDManagers(),
+			History:    history.GetHistory(),
+			Sink:       sink.GetSink(),
 		}
 	})
 	return resource
@@ -47,5 +53,7 @@ func GetResource() *Resource {
 func (r *Resource) Init(ctx context.Context, cfg config.RecorderConfig) *Resource {
 	r.Cleaners.Init(ctx, cfg)
 	r.IDManagers.Init(ctx, cfg)
+	r.History.Init(ctx, cfg)
+	r.Sink.Init(ctx, cfg)
 	return r
 }
@@ -0,0 +1,89 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sink
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	mysqlmodel "github.com/deepflowio/deepflow/server/controller/db/mysql/model"
+	"github.com/deepflowio/deepflow/server/controller/recorder/mysqlchange"
+)
+
+const (
+	CLOUDEVENTS_SPEC_VERSION       = "1.0"
+	CLOUDEVENTS_SOURCE             = "deepflow/controller/recorder"
+	CLOUDEVENTS_TYPE_PREFIX        = "io.deepflow.resource"
+	CONTENT_TYPE_JSON              = "application/json"
+	CONTENT_TYPE_CLOUDEVENTS       = "application/cloudevents+json"
+	CONTENT_TYPE_CLOUDEVENTS_BATCH = "application/cloudevents-batch+json"
+)
+
+// CloudEvent is the structured mode json format of CloudEvents v1.0,
+// resourcetype, domain and orgid are extension attributes.
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject"`
+	Time            string          `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	ResourceType    string          `json:"resourcetype"`
+	Domain          string          `json:"domain,omitempty"`
+	ORGID           int             `json:"orgid"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// newMessage converts a changed resource to a CloudEvent, wrapped in a message to be saved and forwarded later
+func newMessage(orgID int, resourceType, action string, item mysqlchange.Item, at time.Time) (*mysqlmodel.ResourceChangeMessage, error) {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s (lcuuid: %s): %s", resourceType, item.GetLcuuid(), err.Error())
+	}
+	var fields struct {
+		Domain string `json:"DOMAIN"`
+	}
+	json.Unmarshal(data, &fields)
+
+	event := CloudEvent{
+		SpecVersion:     CLOUDEVENTS_SPEC_VERSION,
+		ID:              uuid.NewString(),
+		Source:          CLOUDEVENTS_SOURCE,
+		Type:            fmt.Sprintf("%s.%s.%s", CLOUDEVENTS_TYPE_PREFIX, resourceType, action),
+		Subject:         item.GetLcuuid(),
+		Time:            at.UTC().Format(time.RFC3339Nano),
+		DataContentType: CONTENT_TYPE_JSON,
+		ResourceType:    resourceType,
+		Domain:          fields.Domain,
+		ORGID:           orgID,
+		Data:            data,
+	}
+	content, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal cloudevent of %s (lcuuid: %s): %s", resourceType, item.GetLcuuid(), err.Error())
+	}
+	return &mysqlmodel.ResourceChangeMessage{
+		ResourceType:   resourceType,
+		ResourceLcuuid: item.GetLcuuid(),
+		Domain:         fields.Domain,
+		Content:        string(content),
+	}, nil
+}
@@ -0,0 +1,58 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sink
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	mysqlmodel "github.com/deepflowio/deepflow/server/controller/db/mysql/model"
+	"github.com/deepflowio/deepflow/server/controller/recorder/mysqlchange"
+)
+
+func Test_newMessage(t *testing.T) {
+	ns := &mysqlmodel.PodNamespace{Name: "default", Domain: "domain-lcuuid"}
+	ns.ID = 1
+	ns.Lcuuid = "ns-lcuuid"
+	at := time.Date(2024, 5, 1, 3, 12, 0, 0, time.UTC)
+	msg, err := newMessage(1, "pod_namespace", mysqlchange.ACTION_ADDED, ns, at)
+	if err != nil {
+		t.Fatalf("newMessage() error = %v", err)
+	}
+	if msg.ResourceType != "pod_namespace" || msg.ResourceLcuuid != "ns-lcuuid" || msg.Domain != "domain-lcuuid" {
+		t.Errorf("newMessage() = %+v", msg)
+	}
+
+	var event CloudEvent
+	if err := json.Unmarshal([]byte(msg.Content), &event); err != nil {
+		t.Fatalf("unmarshal cloudevent error = %v", err)
+	}
+	if event.SpecVersion != CLOUDEVENTS_SPEC_VERSION || event.ID == "" || event.Source != CLOUDEVENTS_SOURCE {
+		t.Errorf("cloudevent required attributes = %+v", event)
+	}
+	if event.Type != "io.deepflow.resource.pod_namespace.added" || event.Subject != "ns-lcuuid" || event.Time != "2024-05-01T03:12:00Z" {
+		t.Errorf("cloudevent attributes = %+v", event)
+	}
+	if event.ResourceType != "pod_namespace" || event.Domain != "domain-lcuuid" || event.ORGID != 1 {
+		t.Errorf("cloudevent extension attributes = %+v", event)
+	}
+	var data mysqlmodel.PodNamespace
+	if err := json.Unmarshal(event.Data, &data); err != nil || data.Name != "default" {
+		t.Errorf("cloudevent data = %s, error = %v", string(event.Data), err)
+	}
+}
@@ -0,0 +1,234 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sink
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	mysqlmodel "github.com/deepflowio/deepflow/server/controller/db/mysql/model"
+	"github.com/deepflowio/deepflow/server/controller/recorder/config"
+)
+
+const RETRY_BACKOFF_MAX = time.Minute
+
+// forwarder forwards saved messages to one sink in the order of their ids,
+// the id of the last forwarded message is persisted, so no message is lost across restarts.
+type forwarder struct {
+	cfg     config.EventSinkConfig
+	sinkCfg config.SinkConfig
+	// sender is created on the first send and the creation is retried as sending, so that
+	// a sink unreachable on start still forwards its messages after it recovers.
+	sender    sender
+	newSender func(cfg config.SinkConfig) (sender, error)
+
+	resourceTypes map[string]struct{}
+	domains       map[string]struct{}
+}
+
+func newForwarder(cfg config.EventSinkConfig, sinkCfg config.SinkConfig) *forwarder {
+	f := &forwarder{
+		cfg:           cfg,
+		sinkCfg:       sinkCfg,
+		newSender:     newSender,
+		resourceTypes: make(map[string]struct{}),
+		domains:       make(map[string]struct{}),
+	}
+	for _, t := range sinkCfg.ResourceTypes {
+		f.resourceTypes[t] = struct{}{}
+	}
+	for _, d := range sinkCfg.Domains {
+		f.domains[d] = struct{}{}
+	}
+	return f
+}
+
+func (f *forwarder) Start(sContext context.Context) {
+	go func() {
+		ticker := time.NewTicker(time.Duration(f.cfg.FlushInterval) * time.Second)
+		defer ticker.Stop()
+		defer func() {
+			if f.sender != nil {
+				f.sender.Close()
+			}
+		}()
+
+	LOOP:
+		for {
+			select {
+			case <-ticker.C:
+				f.forward(sContext)
+			case <-sContext.Done():
+				break LOOP
+			}
+		}
+		log.Infof("sink (%s) forwarder stopped", f.sinkCfg.Name)
+	}()
+	log.Infof("sink (%s) forwarder started", f.sinkCfg.Name)
+}
+
+func (f *forwarder) forward(ctx context.Context) {
+	orgIDs, err := mysql.GetORGIDs()
+	if err != nil {
+		log.Errorf("failed to get org ids: %s", err.Error())
+		return
+	}
+	for _, orgID := range orgIDs {
+		db, err := mysql.GetDB(orgID)
+		if err != nil {
+			log.Errorf("failed to get db: %s", err.Error())
+			continue
+		}
+		if err := f.forwardORG(ctx, db); err != nil {
+			log.Errorf("sink (%s) failed to forward messages: %s", f.sinkCfg.Name, err.Error(), db.LogPrefixORGID)
+		}
+	}
+}
+
+func (f *forwarder) forwardORG(ctx context.Context, db *mysql.DB) error {
+	offset, err := f.loadOffset(db.DB)
+	if err != nil {
+		return err
+	}
+	for {
+		var msgs []*mysqlmodel.ResourceChangeMessage
+		if err := db.Where("id > ?", offset).Order("id").Limit(f.cfg.BatchSize).Find(&msgs).Error; err != nil {
+			return err
+		}
+		committed := committedMessages(msgs, time.Now().Add(-time.Duration(f.cfg.CommitWindow)*time.Second))
+		if len(committed) == 0 {
+			return nil
+		}
+		if matched := f.filter(committed); len(matched) > 0 {
+			if err := f.sendWithRetry(ctx, matched); err != nil {
+				return err
+			}
+		}
+		offset = committed[len(committed)-1].ID
+		if err := f.saveOffset(db.DB, offset); err != nil {
+			return err
+		}
+		if len(committed) < f.cfg.BatchSize {
+			return nil
+		}
+	}
+}
+
+// committedMessages returns messages before the first one saved after cutoff. Ids are allocated before
+// transactions commit, a message with a smaller id may become visible later than the ones after it,
+// messages saved within the commit window are not forwarded, so that the offset never skips them.
+func committedMessages(msgs []*mysqlmodel.ResourceChangeMessage, cutoff time.Time) []*mysqlmodel.ResourceChangeMessage {
+	for i, msg := range msgs {
+		if msg.CreatedAt.After(cutoff) {
+			return msgs[:i]
+		}
+	}
+	return msgs
+}
+
+func (f *forwarder) filter(msgs []*mysqlmodel.ResourceChangeMessage) []*mysqlmodel.ResourceChangeMessage {
+	if len(f.resourceTypes) == 0 && len(f.domains) == 0 {
+		return msgs
+	}
+	matched := make([]*mysqlmodel.ResourceChangeMessage, 0, len(msgs))
+	for _, msg := range msgs {
+		if len(f.resourceTypes) != 0 {
+			if _, ok := f.resourceTypes[msg.ResourceType]; !ok {
+				continue
+			}
+		}
+		if len(f.domains) != 0 {
+			if _, ok := f.domains[msg.Domain]; !ok {
+				continue
+			}
+		}
+		matched = append(matched, msg)
+	}
+	return matched
+}
+
+// sendWithRetry creates the sender if absent and retries at most MaxRetries times with exponential backoff,
+// messages failed at last will be retried in the next round as the offset is not moved.
+func (f *forwarder) sendWithRetry(ctx context.Context, msgs []*mysqlmodel.ResourceChangeMessage) error {
+	for attempt := 0; ; attempt++ {
+		s, err := f.getSender()
+		if err == nil {
+			err = s.Send(msgs)
+		}
+		if err == nil {
+			return nil
+		}
+		if attempt >= f.cfg.MaxRetries {
+			return err
+		}
+		backoff := retryBackoff(f.cfg.RetryBackoff, attempt)
+		log.Warningf("sink (%s) failed to send %d messages, retry after %s: %s", f.sinkCfg.Name, len(msgs), backoff, err.Error())
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (f *forwarder) getSender() (sender, error) {
+	if f.sender == nil {
+		s, err := f.newSender(f.sinkCfg)
+		if err != nil {
+			return nil, err
+		}
+		f.sender = s
+	}
+	return f.sender, nil
+}
+
+func retryBackoff(base, attempt int) time.Duration {
+	if base <= 0 {
+		base = 1
+	}
+	backoff := time.Duration(base) * time.Second
+	for i := 0; i < attempt && backoff < RETRY_BACKOFF_MAX; i++ {
+		backoff *= 2
+	}
+	if backoff > RETRY_BACKOFF_MAX {
+		backoff = RETRY_BACKOFF_MAX
+	}
+	return backoff
+}
+
+func (f *forwarder) loadOffset(db *gorm.DB) (int, error) {
+	var offset mysqlmodel.ResourceChangeSinkOffset
+	if err := db.Where("sink = ?", f.sinkCfg.Name).First(&offset).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return offset.LastMessageID, nil
+}
+
+func (f *forwarder) saveOffset(db *gorm.DB, lastMessageID int) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "sink"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_message_id", "updated_at"}),
+	}).Create(&mysqlmodel.ResourceChangeSinkOffset{Sink: f.sinkCfg.Name, LastMessageID: lastMessageID}).Error
+}
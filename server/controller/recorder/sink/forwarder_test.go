@@ -0,0 +1,219 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mysqlmodel "github.com/deepflowio/deepflow/server/controller/db/mysql/model"
+	"github.com/deepflowio/deepflow/server/controller/recorder/config"
+)
+
+type fakeSender struct {
+	failures int
+	calls    int
+}
+
+func (s *fakeSender) Send(msgs []*mysqlmodel.ResourceChangeMessage) error {
+	s.calls++
+	if s.calls <= s.failures {
+		return errors.New("unavailable")
+	}
+	return nil
+}
+
+func (s *fakeSender) Close() {}
+
+func Test_forwarder_filter(t *testing.T) {
+	msgs := []*mysqlmodel.ResourceChangeMessage{
+		{ID: 1, ResourceType: "pod_namespace", Domain: "d1"},
+		{ID: 2, ResourceType: "lb", Domain: "d2"},
+		{ID: 3, ResourceType: "pod", Domain: "d1"},
+	}
+	tests := []struct {
+		name    string
+		sinkCfg config.SinkConfig
+		want    []int
+	}{
+		{name: "all", sinkCfg: config.SinkConfig{}, want: []int{1, 2, 3}},
+		{name: "resource types", sinkCfg: config.SinkConfig{ResourceTypes: []string{"pod_namespace", "lb"}}, want: []int{1, 2}},
+		{name: "domains", sinkCfg: config.SinkConfig{Domains: []string{"d1"}}, want: []int{1, 3}},
+		{name: "both", sinkCfg: config.SinkConfig{ResourceTypes: []string{"lb"}, Domains: []string{"d1"}}, want: []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newForwarder(config.EventSinkConfig{}, tt.sinkCfg).filter(msgs)
+			if len(got) != len(tt.want) {
+				t.Fatalf("filter() got %d messages, want %d", len(got), len(tt.want))
+			}
+			for i, msg := range got {
+				if msg.ID != tt.want[i] {
+					t.Errorf("filter()[%d] = %d, want %d", i, msg.ID, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func Test_retryBackoff(t *testing.T) {
+	tests := []struct {
+		base, attempt int
+		want          time.Duration
+	}{
+		{base: 1, attempt: 0, want: time.Second},
+		{base: 1, attempt: 3, want: 8 * time.Second},
+		{base: 0, attempt: 1, want: 2 * time.Second},
+		{base: 10, attempt: 10, want: RETRY_BACKOFF_MAX},
+	}
+	for _, tt := range tests {
+		if got := retryBackoff(tt.base, tt.attempt); got != tt.want {
+			t.Errorf("retryBackoff(%d, %d) = %s, want %s", tt.base, tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func Test_forwarder_sendWithRetry(t *testing.T) {
+	msgs := []*mysqlmodel.ResourceChangeMessage{{ID: 1}}
+
+	s := &fakeSender{failures: 1}
+	f := newForwarder(config.EventSinkConfig{MaxRetries: 2}, config.SinkConfig{Name: "test"})
+	f.sender = s
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(1500 * time.Millisecond)
+		cancel()
+	}()
+	if err := f.sendWithRetry(ctx, msgs); err != nil || s.calls != 2 {
+		t.Errorf("sendWithRetry() error = %v, calls = %d", err, s.calls)
+	}
+
+	s = &fakeSender{failures: 10}
+	f = newForwarder(config.EventSinkConfig{MaxRetries: 0}, config.SinkConfig{Name: "test"})
+	f.sender = s
+	if err := f.sendWithRetry(context.Background(), msgs); err == nil || s.calls != 1 {
+		t.Errorf("sendWithRetry() error = %v, calls = %d", err, s.calls)
+	}
+
+	// sender failed to be created is created again in the retries
+	s = &fakeSender{}
+	created := 0
+	f = newForwarder(config.EventSinkConfig{MaxRetries: 2}, config.SinkConfig{Name: "test"})
+	f.newSender = func(cfg config.SinkConfig) (sender, error) {
+		created++
+		if created == 1 {
+			return nil, errors.New("unreachable")
+		}
+		return s, nil
+	}
+	if err := f.sendWithRetry(context.Background(), msgs); err != nil || created != 2 || s.calls != 1 {
+		t.Errorf("sendWithRetry() error = %v, created = %d, calls = %d", err, created, s.calls)
+	}
+	if err := f.sendWithRetry(context.Background(), msgs); err != nil || created != 2 || s.calls != 2 {
+		t.Errorf("sendWithRetry() error = %v, created = %d, calls = %d", err, created, s.calls)
+	}
+}
+
+func Test_committedMessages(t *testing.T) {
+	now := time.Now()
+	cutoff := now.Add(-10 * time.Second)
+	msgs := []*mysqlmodel.ResourceChangeMessage{
+		{ID: 1, CreatedAt: now.Add(-time.Minute)},
+		{ID: 3, CreatedAt: now.Add(-20 * time.Second)},
+		{ID: 4, CreatedAt: now.Add(-time.Second)},
+		// saved by another controller with a slower clock, it is not forwarded before id 4
+		{ID: 5, CreatedAt: now.Add(-time.Minute)},
+	}
+	if got := committedMessages(msgs, cutoff); len(got) != 2 || got[1].ID != 3 {
+		t.Errorf("committedMessages() got %d messages", len(got))
+	}
+	if got := committedMessages(msgs[2:], cutoff); len(got) != 0 {
+		t.Errorf("committedMessages() got %d messages, want 0", len(got))
+	}
+}
+
+func Test_minOffset(t *testing.T) {
+	offsets := []mysqlmodel.ResourceChangeSinkOffset{
+		{Sink: "a", LastMessageID: 10},
+		{Sink: "b", LastMessageID: 5},
+		{Sink: "removed", LastMessageID: 1},
+	}
+	tests := []struct {
+		name      string
+		sinkNames []string
+		want      int
+	}{
+		{name: "all forwarded", sinkNames: []string{"a", "b"}, want: 5},
+		{name: "removed sink is ignored", sinkNames: []string{"a"}, want: 10},
+		{name: "new sink", sinkNames: []string{"a", "c"}, want: 0},
+		{name: "no sink", sinkNames: nil, want: 0},
+	}
+	for _, tt := range tests {
+		if got := minOffset(offsets, tt.sinkNames); got != tt.want {
+			t.Errorf("%s: minOffset() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_webhookSender_Send(t *testing.T) {
+	var (
+		contentType string
+		token       string
+		events      []CloudEvent
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		token = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&events); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	s, err := newWebhookSender(config.SinkConfig{Name: "test", URL: server.URL, Headers: map[string]string{"Authorization": "Bearer t"}})
+	if err != nil {
+		t.Fatalf("newWebhookSender() error = %v", err)
+	}
+	defer s.Close()
+	msgs := []*mysqlmodel.ResourceChangeMessage{
+		{Content: `{"specversion":"1.0","id":"1","subject":"a"}`},
+		{Content: `{"specversion":"1.0","id":"2","subject":"b"}`},
+	}
+	if err := s.Send(msgs); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if contentType != CONTENT_TYPE_CLOUDEVENTS_BATCH || token != "Bearer t" {
+		t.Errorf("Send() headers = %s, %s", contentType, token)
+	}
+	if len(events) != 2 || events[0].ID != "1" || events[1].Subject != "b" {
+		t.Errorf("Send() events = %+v", events)
+	}
+
+	failed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failed.Close()
+	s.url = failed.URL
+	if err := s.Send(msgs); err == nil {
+		t.Errorf("Send() expected error for status 503")
+	}
+}
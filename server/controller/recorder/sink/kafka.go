@@ -0,0 +1,77 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sink
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/IBM/sarama"
+
+	mysqlmodel "github.com/deepflowio/deepflow/server/controller/db/mysql/model"
+	"github.com/deepflowio/deepflow/server/controller/recorder/config"
+)
+
+// kafkaSender produces each message as a structured mode CloudEvent keyed by resource lcuuid,
+// so that the changes of one resource are kept in order in the same partition.
+type kafkaSender struct {
+	topic    string
+	producer sarama.SyncProducer
+}
+
+func newKafkaSender(cfg config.SinkConfig) (*kafkaSender, error) {
+	if len(cfg.Brokers) == 0 || cfg.Topic == "" {
+		return nil, fmt.Errorf("sink (%s) brokers and topic are required", cfg.Name)
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = SINK_TIMEOUT_DEFAULT
+	}
+	kafkaCfg := sarama.NewConfig()
+	kafkaCfg.Producer.RequiredAcks = sarama.WaitForAll
+	kafkaCfg.Producer.Return.Successes = true
+	kafkaCfg.Producer.Partitioner = sarama.NewHashPartitioner
+	kafkaCfg.Producer.Timeout = time.Duration(timeout) * time.Second
+	kafkaCfg.Net.DialTimeout = time.Duration(timeout) * time.Second
+
+	producer, err := sarama.NewSyncProducer(cfg.Brokers, kafkaCfg)
+	if err != nil {
+		return nil, fmt.Errorf("sink (%s) failed to create kafka producer: %s", cfg.Name, err.Error())
+	}
+	return &kafkaSender{topic: cfg.Topic, producer: producer}, nil
+}
+
+func (k *kafkaSender) Send(msgs []*mysqlmodel.ResourceChangeMessage) error {
+	kafkaMsgs := make([]*sarama.ProducerMessage, 0, len(msgs))
+	for _, msg := range msgs {
+		kafkaMsgs = append(kafkaMsgs, &sarama.ProducerMessage{
+			Topic: k.topic,
+			Key:   sarama.StringEncoder(msg.ResourceLcuuid),
+			Value: sarama.StringEncoder(msg.Content),
+			Headers: []sarama.RecordHeader{
+				{Key: []byte("content-type"), Value: []byte(CONTENT_TYPE_CLOUDEVENTS)},
+			},
+		})
+	}
+	return k.producer.SendMessages(kafkaMsgs)
+}
+
+func (k *kafkaSender) Close() {
+	if err := k.producer.Close(); err != nil {
+		log.Errorf("failed to close kafka producer: %s", err.Error())
+	}
+}
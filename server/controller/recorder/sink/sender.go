@@ -0,0 +1,48 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sink
+
+import (
+	"fmt"
+
+	mysqlmodel "github.com/deepflowio/deepflow/server/controller/db/mysql/model"
+	"github.com/deepflowio/deepflow/server/controller/recorder/config"
+)
+
+const (
+	SINK_TYPE_WEBHOOK = "webhook"
+	SINK_TYPE_KAFKA   = "kafka"
+
+	SINK_TIMEOUT_DEFAULT = 10 // unit: second
+)
+
+// sender delivers a batch of messages to an external system, the batch is either fully delivered or failed
+type sender interface {
+	Send(msgs []*mysqlmodel.ResourceChangeMessage) error
+	Close()
+}
+
+func newSender(cfg config.SinkConfig) (sender, error) {
+	switch cfg.Type {
+	case SINK_TYPE_WEBHOOK:
+		return newWebhookSender(cfg)
+	case SINK_TYPE_KAFKA:
+		return newKafkaSender(cfg)
+	default:
+		return nil, fmt.Errorf("sink (%s) type (%s) not supported, supported: %s, %s", cfg.Name, cfg.Type, SINK_TYPE_WEBHOOK, SINK_TYPE_KAFKA)
+	}
+}
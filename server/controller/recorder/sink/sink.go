@@ -0,0 +1,200 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package sink forwards resource change messages published by recorder to external systems,
+// such as webhooks and kafka, in CloudEvents json format.
+//
+// Messages are saved to MySQL by subscribers on every controller running recorder,
+// and forwarded to sinks by forwarders on master controller only.
+package sink
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	mysqlmodel "github.com/deepflowio/deepflow/server/controller/db/mysql/model"
+	"github.com/deepflowio/deepflow/server/controller/recorder/config"
+	"github.com/deepflowio/deepflow/server/controller/recorder/mysqlchange"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+var log = logger.MustGetLogger("recorder.sink")
+
+var (
+	sinkOnce sync.Once
+	sink     *Sink
+)
+
+type Sink struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	cfg    config.EventSinkConfig
+
+	subscriber *mysqlchange.Subscriber
+	// names of all configured sinks, messages are cleaned only after all of them have forwarded
+	sinkNames []string
+}
+
+func GetSink() *Sink {
+	sinkOnce.Do(func() {
+		sink = new(Sink)
+	})
+	return sink
+}
+
+func (s *Sink) Init(ctx context.Context, cfg config.RecorderConfig) {
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	s.cfg = cfg.EventSink
+	if s.cfg.FlushInterval <= 0 {
+		s.cfg.FlushInterval = 5
+	}
+	if s.cfg.BatchSize <= 0 {
+		s.cfg.BatchSize = 100
+	}
+	if s.cfg.RetentionTime <= 0 {
+		s.cfg.RetentionTime = 24
+	}
+	if s.cfg.CommitWindow <= 0 {
+		s.cfg.CommitWindow = 10
+	}
+	s.subscriber = mysqlchange.NewSubscriber("resource change sink", s.handle)
+}
+
+func (s *Sink) enabled() bool {
+	return s.cfg.Enabled && len(s.cfg.Sinks) != 0
+}
+
+// Start subscribes to all resource pubsubs, it should be called before recorder starts publishing messages.
+func (s *Sink) Start() {
+	if !s.enabled() {
+		return
+	}
+	s.subscriber.Start(s.ctx)
+	log.Info("resource change sink started")
+}
+
+// StartForwarders starts forwarders of all sinks and timed cleaning of expired messages, it only runs on master controller.
+func (s *Sink) StartForwarders(sContext context.Context) {
+	if !s.enabled() {
+		return
+	}
+	ctx, cancel := context.WithCancel(sContext)
+	go func() {
+		select {
+		case <-s.ctx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	names := make(map[string]struct{})
+	s.sinkNames = s.sinkNames[:0]
+	for _, sinkCfg := range s.cfg.Sinks {
+		if _, ok := names[sinkCfg.Name]; ok || sinkCfg.Name == "" {
+			log.Errorf("sink name (%s) is empty or duplicated, ignored", sinkCfg.Name)
+			continue
+		}
+		names[sinkCfg.Name] = struct{}{}
+		// sinks whose sender fails to be created are kept, so that their messages are not cleaned before forwarded
+		newForwarder(s.cfg, sinkCfg).Start(ctx)
+		s.sinkNames = append(s.sinkNames, sinkCfg.Name)
+	}
+	s.timedClean(ctx)
+}
+
+func (s *Sink) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	log.Info("resource change sink stopped")
+}
+
+func (s *Sink) timedClean(ctx context.Context) {
+	s.clean()
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+	LOOP:
+		for {
+			select {
+			case <-ticker.C:
+				s.clean()
+			case <-ctx.Done():
+				break LOOP
+			}
+		}
+	}()
+}
+
+func (s *Sink) clean() {
+	orgIDs, err := mysql.GetORGIDs()
+	if err != nil {
+		log.Errorf("failed to get org ids: %s", err.Error())
+		return
+	}
+	expiredAt := time.Now().Add(time.Duration(-s.cfg.RetentionTime) * time.Hour)
+	for _, orgID := range orgIDs {
+		db, err := mysql.GetDB(orgID)
+		if err != nil {
+			log.Errorf("failed to get db: %s", err.Error(), logger.NewORGPrefix(orgID))
+			continue
+		}
+		// messages not yet forwarded by any sink are kept even if expired
+		offset, err := minSinkOffset(db.DB, s.sinkNames)
+		if err != nil {
+			log.Errorf("failed to get sink offsets: %s", err.Error(), db.LogPrefixORGID)
+			continue
+		}
+		if err := db.Where("created_at < ? AND id <= ?", expiredAt, offset).Delete(&mysqlmodel.ResourceChangeMessage{}).Error; err != nil {
+			log.Errorf("failed to clean resource change messages (created_at < %s, id <= %d): %s", expiredAt, offset, err.Error(), db.LogPrefixORGID)
+		}
+	}
+}
+
+// minSinkOffset returns the smallest id of messages forwarded by all sinks, it is 0 if any sink has not forwarded yet
+func minSinkOffset(db *gorm.DB, sinkNames []string) (int, error) {
+	if len(sinkNames) == 0 {
+		return 0, nil
+	}
+	var offsets []mysqlmodel.ResourceChangeSinkOffset
+	if err := db.Where("sink IN ?", sinkNames).Find(&offsets).Error; err != nil {
+		return 0, err
+	}
+	return minOffset(offsets, sinkNames), nil
+}
+
+func minOffset(offsets []mysqlmodel.ResourceChangeSinkOffset, sinkNames []string) int {
+	sinkToOffset := make(map[string]int, len(offsets))
+	for _, o := range offsets {
+		sinkToOffset[o.Sink] = o.LastMessageID
+	}
+	min := -1
+	for _, name := range sinkNames {
+		offset := sinkToOffset[name]
+		if min < 0 || offset < min {
+			min = offset
+		}
+	}
+	if min < 0 {
+		return 0
+	}
+	return min
+}
@@ -0,0 +1,52 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sink
+
+import (
+	"time"
+
+	"github.com/deepflowio/deepflow/server/controller/db/mysql"
+	mysqlmodel "github.com/deepflowio/deepflow/server/controller/db/mysql/model"
+	"github.com/deepflowio/deepflow/server/controller/recorder/mysqlchange"
+	"github.com/deepflowio/deepflow/server/libs/logger"
+)
+
+// handle saves change messages to be forwarded to sinks by forwarders,
+// it is called by the subscriber goroutine, so that recorder is never blocked by MySQL writes.
+func (s *Sink) handle(change *mysqlchange.Change) {
+	db, err := mysql.GetDB(change.ORGID)
+	if err != nil {
+		log.Errorf("failed to get db: %s", err.Error(), logger.NewORGPrefix(change.ORGID))
+		return
+	}
+	now := time.Now()
+	msgs := make([]*mysqlmodel.ResourceChangeMessage, 0, len(change.Items))
+	for _, item := range change.Items {
+		msg, err := newMessage(change.ORGID, change.ResourceType, change.Action, item, now)
+		if err != nil {
+			log.Error(err.Error(), db.LogPrefixORGID)
+			continue
+		}
+		msgs = append(msgs, msg)
+	}
+	if len(msgs) == 0 {
+		return
+	}
+	if err := db.CreateInBatches(msgs, 100).Error; err != nil {
+		log.Errorf("failed to save %s %s messages: %s", change.ResourceType, change.Action, err.Error(), db.LogPrefixORGID)
+	}
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sink
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	mysqlmodel "github.com/deepflowio/deepflow/server/controller/db/mysql/model"
+	"github.com/deepflowio/deepflow/server/controller/recorder/config"
+)
+
+// webhookSender posts messages as a CloudEvents json batch
+type webhookSender struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func newWebhookSender(cfg config.SinkConfig) (*webhookSender, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("sink (%s) url is required", cfg.Name)
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = SINK_TIMEOUT_DEFAULT
+	}
+	return &webhookSender{
+		url:     cfg.URL,
+		headers: cfg.Headers,
+		client:  &http.Client{Timeout: time.Duration(timeout) * time.Second},
+	}, nil
+}
+
+func (w *webhookSender) Send(msgs []*mysqlmodel.ResourceChangeMessage) error {
+	var body bytes.Buffer
+	body.WriteByte('[')
+	for i, msg := range msgs {
+		if i > 0 {
+			body.WriteByte(',')
+		}
+		body.WriteString(msg.Content)
+	}
+	body.WriteByte(']')
+
+	req, err := http.NewRequest(http.MethodPost, w.url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", CONTENT_TYPE_CLOUDEVENTS_BATCH)
+	for k, v := range w.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook (%s) responded %d: %s", w.url, resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+func (w *webhookSender) Close() {
+	w.client.CloseIdleConnections()
+}
@@ -237,6 +237,7 @@ func (u *UpdaterBase[CT, BT, MPT, MT, MAPT, MAT, MUPT, MUT, MFUPT, MFUT, MDPT, M
 		msgData.SetFields(structInfo)
 		msgData.SetDiffBase(diffBase)
 		msgData.SetCloudItem(cloudItem)
+		msgData.SetNewMySQLItem(dbItem)
 		u.pubsub.PublishUpdated(u.msgMetadata, msgData)
 		u.Changed = true
 	}
@@ -23,6 +23,7 @@ import (
 	"strconv"
 	"strings"
 	"sync/atomic"
+	"time"
 
 	mapset "github.com/deckarep/golang-set"
 	"github.com/deepflowio/deepflow/message/trident"
@@ -41,6 +42,8 @@ type PolicyRawData struct {
 	aclIDToPcapPolices map[int][]*models.PcapPolicy
 	idToNpbPolicy      map[int]*models.NpbPolicy
 	idToPcapPolicy     map[int]*models.PcapPolicy
+	// vtap groups of policies are resolved to the current vtaps when policies are generated
+	vtapGroupLcuuidToVTapIDs map[string][]int
 }
 
 func newPolicyRawData() *PolicyRawData {
@@ -51,6 +54,8 @@ func newPolicyRawData() *PolicyRawData {
 		aclIDToPcapPolices: make(map[int][]*models.PcapPolicy),
 		idToNpbPolicy:      make(map[int]*models.NpbPolicy),
 		idToPcapPolicy:     make(map[int]*models.PcapPolicy),
+
+		vtapGroupLcuuidToVTapIDs: make(map[string][]int),
 	}
 }
 
@@ -260,6 +265,8 @@ type PolicyDataOP struct {
 	//whether the policy initializes the identity
 	init          bool
 	billingMethod string
+	// regenerates policies when the earliest policy expires
+	expireTimer *time.Timer
 
 	ORGID
 }
@@ -384,7 +391,32 @@ func (op *PolicyDataOP) generateRawData() {
 		rawData.idToACL[acl.ID] = acl
 	}
 
+	for _, vtap := range dbDataCache.GetVTapsIDAndName() {
+		if vtap.VtapGroupLcuuid != "" {
+			rawData.vtapGroupLcuuidToVTapIDs[vtap.VtapGroupLcuuid] = append(
+				rawData.vtapGroupLcuuidToVTapIDs[vtap.VtapGroupLcuuid], vtap.ID)
+		}
+	}
+
+	// expired policies are skipped, policies are regenerated when the earliest one of others expires
+	now := time.Now()
+	var nextExpiredAt *time.Time
+	isExpired := func(expiredAt *time.Time) bool {
+		if expiredAt == nil {
+			return false
+		}
+		if !now.Before(*expiredAt) {
+			return true
+		}
+		if nextExpiredAt == nil || expiredAt.Before(*nextExpiredAt) {
+			nextExpiredAt = expiredAt
+		}
+		return false
+	}
 	for _, npbPolicy := range npbPolicies {
+		if isExpired(npbPolicy.ExpiredAt) {
+			continue
+		}
 		rawData.idToNpbPolicy[npbPolicy.ID] = npbPolicy
 		if _, ok := rawData.aclIDToNpbPolices[npbPolicy.ACLID]; ok {
 			rawData.aclIDToNpbPolices[npbPolicy.ACLID] = append(
@@ -395,6 +427,9 @@ func (op *PolicyDataOP) generateRawData() {
 	}
 
 	for _, pcapPolicy := range pcapPolicies {
+		if isExpired(pcapPolicy.ExpiredAt) {
+			continue
+		}
 		rawData.idToPcapPolicy[pcapPolicy.ID] = pcapPolicy
 		if _, ok := rawData.aclIDToPcapPolices[pcapPolicy.ACLID]; ok {
 			rawData.aclIDToPcapPolices[pcapPolicy.ACLID] = append(
@@ -405,6 +440,41 @@ func (op *PolicyDataOP) generateRawData() {
 	}
 
 	op.updateRawData(rawData)
+	op.resetExpireTimer(nextExpiredAt)
+}
+
+func (op *PolicyDataOP) resetExpireTimer(expiredAt *time.Time) {
+	if op.expireTimer != nil {
+		op.expireTimer.Stop()
+		op.expireTimer = nil
+	}
+	if expiredAt != nil {
+		op.expireTimer = time.AfterFunc(time.Until(*expiredAt), op.metaData.PutChPolicy)
+	}
+}
+
+// getPolicyVTapIDs returns the vtap ids which the policy is pushed to, all vtaps if both vtapIDs and
+// vtapGroupLcuuids are empty. A policy of vtap groups without vtaps is not pushed to any vtap.
+func (op *PolicyDataOP) getPolicyVTapIDs(vtapIDs, vtapGroupLcuuids string) (ids []int, all bool) {
+	rawData := op.GetRawData()
+	if vtapGroupLcuuids != "" {
+		for _, lcuuid := range strings.Split(vtapGroupLcuuids, ",") {
+			ids = append(ids, rawData.vtapGroupLcuuidToVTapIDs[lcuuid]...)
+		}
+		return ids, false
+	}
+	if len(vtapIDs) == 0 {
+		return nil, true
+	}
+	for _, vtapIDStr := range strings.Split(vtapIDs, ",") {
+		vtapIDInt, err := strconv.Atoi(vtapIDStr)
+		if err != nil {
+			log.Errorf(op.Logf("err: %s, vtapIDs: %s", err, vtapIDs))
+			continue
+		}
+		ids = append(ids, vtapIDInt)
+	}
+	return ids, false
 }
 
 type GroupIDs struct {
@@ -623,17 +693,12 @@ func (op *PolicyDataOP) generateProtoActions(acl *models.ACL) (map[int][]*triden
 				NpbAclGroupId: proto.Uint32(uint32(npbPolicy.PolicyACLGroupID)),
 				Direction:     &direction,
 			}
-			if len(npbPolicy.VtapIDs) == 0 {
+			vtapIDs, all := op.getPolicyVTapIDs(npbPolicy.VtapIDs, npbPolicy.VtapGroupLcuuids)
+			if all {
 				allVTapNpbActions = append(allVTapNpbActions, npbAction)
-			} else {
-				for _, vtapIDStr := range strings.Split(npbPolicy.VtapIDs, ",") {
-					vtapIDInt, err := strconv.Atoi(vtapIDStr)
-					if err != nil {
-						log.Errorf(op.Logf("err: %s, vtapIDs: %s", err, npbPolicy.VtapIDs))
-						continue
-					}
-					vtapIDToNpbActions[vtapIDInt] = append(vtapIDToNpbActions[vtapIDInt], npbAction)
-				}
+			}
+			for _, vtapID := range vtapIDs {
+				vtapIDToNpbActions[vtapID] = append(vtapIDToNpbActions[vtapID], npbAction)
 			}
 		}
 	case APPLICATION_PCAP:
@@ -650,17 +715,12 @@ func (op *PolicyDataOP) generateProtoActions(acl *models.ACL) (map[int][]*triden
 				PayloadSlice:  proto.Uint32(uint32(payloadSlice)),
 				NpbAclGroupId: proto.Uint32(uint32(pcapPolicy.PolicyACLGroupID)),
 			}
-			if len(pcapPolicy.VtapIDs) == 0 {
+			vtapIDs, all := op.getPolicyVTapIDs(pcapPolicy.VtapIDs, pcapPolicy.VtapGroupLcuuids)
+			if all {
 				allVTapNpbActions = append(allVTapNpbActions, npbAction)
-			} else {
-				for _, vtapIDStr := range strings.Split(pcapPolicy.VtapIDs, ",") {
-					vtapIDInt, err := strconv.Atoi(vtapIDStr)
-					if err != nil {
-						log.Errorf(op.Logf("err: %s, vtapIDs: %s", err, pcapPolicy.VtapIDs))
-						continue
-					}
-					vtapIDToNpbActions[vtapIDInt] = append(vtapIDToNpbActions[vtapIDInt], npbAction)
-				}
+			}
+			for _, vtapID := range vtapIDs {
+				vtapIDToNpbActions[vtapID] = append(vtapIDToNpbActions[vtapID], npbAction)
 			}
 		}
 	}
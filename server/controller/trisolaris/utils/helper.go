@@ -27,11 +27,10 @@ import (
 
 	"github.com/deepflowio/deepflow/server/controller/common"
 	"github.com/deepflowio/deepflow/server/libs/logger"
+	"github.com/deepflowio/deepflow/server/libs/utils"
 	"github.com/vishvananda/netlink"
 )
 
-type ctxKeyWaitGroup struct{}
-
 const ORG_ID_INDEX_MAX = common.ORG_ID_MAX + 1 // 0 index not used
 
 func CheckOrgID(orgID int) bool {
@@ -39,15 +38,11 @@ func CheckOrgID(orgID int) bool {
 }
 
 func GetWaitGroupInCtx(ctx context.Context) *sync.WaitGroup {
-	if wg, ok := ctx.Value(ctxKeyWaitGroup{}).(*sync.WaitGroup); ok {
-		return wg
-	}
-
-	return nil
+	return utils.GetWaitGroupInCtx(ctx)
 }
 
 func NewWaitGroupCtx() (context.Context, context.CancelFunc) {
-	return context.WithCancel(context.WithValue(context.Background(), ctxKeyWaitGroup{}, new(sync.WaitGroup)))
+	return utils.NewWaitGroupCtx()
 }
 
 type Number interface {
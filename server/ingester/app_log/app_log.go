@@ -18,14 +18,20 @@ package app_log
 
 import (
 	"strconv"
+	"sync/atomic"
 	"time"
 
+	logging "github.com/op/go-logging"
+	collectorlogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+
 	"github.com/deepflowio/deepflow/server/ingester/app_log/config"
 	"github.com/deepflowio/deepflow/server/ingester/app_log/dbwriter"
 	"github.com/deepflowio/deepflow/server/ingester/app_log/decoder"
+	"github.com/deepflowio/deepflow/server/ingester/app_log/listener"
 	dropletqueue "github.com/deepflowio/deepflow/server/ingester/droplet/queue"
 	"github.com/deepflowio/deepflow/server/ingester/ingesterctl"
 	"github.com/deepflowio/deepflow/server/ingester/pkg/ckwriter"
+	"github.com/deepflowio/deepflow/server/ingester/pkg/otlpreceiver"
 	"github.com/deepflowio/deepflow/server/libs/datatype"
 	"github.com/deepflowio/deepflow/server/libs/grpc"
 	"github.com/deepflowio/deepflow/server/libs/queue"
@@ -33,12 +39,29 @@ import (
 	"github.com/deepflowio/deepflow/server/libs/receiver"
 )
 
+var log = logging.MustGetLogger("app_log")
+
 type ApplicationLogger struct {
 	Config      *config.Config
 	Ckwriter    *ckwriter.CKWriter
 	SysLogger   *Logger
 	AgentLogger *Logger
 	AppLogger   *Logger
+
+	ExternalLogger *ExternalLogger
+}
+
+// ExternalLogger receives logs by the ingester's own listeners (syslog, fluent forward) and the otlp receiver
+type ExternalLogger struct {
+	Config                *config.Config
+	SyslogListener        *listener.SyslogListener
+	FluentForwardListener *listener.FluentForwardListener
+	Decoders              []*decoder.Decoder
+	PlatformDatas         []*grpc.PlatformInfoTable
+
+	decodeQueues *dropletqueue.MultiQueue
+	queueCount   int
+	putCount     uint64
 }
 
 type Logger struct {
@@ -47,10 +70,12 @@ type Logger struct {
 	PlatformDatas []*grpc.PlatformInfoTable
 }
 
+// otlpReceiver is nil if the otlp receiver of ingester is disabled
 func NewApplicationLogger(
 	config *config.Config,
 	recv *receiver.Receiver,
 	platformDataManager *grpc.PlatformDataManager,
+	otlpReceiver *otlpreceiver.Receiver,
 ) (*ApplicationLogger, error) {
 	manager := dropletqueue.NewManager(ingesterctl.INGESTERCTL_APPLICATION_LOG_QUEUE)
 
@@ -71,12 +96,21 @@ func NewApplicationLogger(
 		return nil, err
 	}
 
+	var externalLogger *ExternalLogger
+	if config.SyslogListener.Enabled || config.FluentForwardListener.Enabled || otlpReceiver != nil {
+		externalLogger, err = NewExternalLogger(config, manager, platformDataManager, ckwriter, otlpReceiver)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &ApplicationLogger{
-		Config:      config,
-		Ckwriter:    ckwriter,
-		SysLogger:   sysLogger,
-		AgentLogger: agentLogger,
-		AppLogger:   appLogger,
+		Config:         config,
+		Ckwriter:       ckwriter,
+		SysLogger:      sysLogger,
+		AgentLogger:    agentLogger,
+		AppLogger:      appLogger,
+		ExternalLogger: externalLogger,
 	}, nil
 }
 
@@ -85,12 +119,18 @@ func (l *ApplicationLogger) Start() {
 	l.SysLogger.Start()
 	l.AgentLogger.Start()
 	l.AppLogger.Start()
+	if l.ExternalLogger != nil {
+		l.ExternalLogger.Start()
+	}
 }
 
 func (l *ApplicationLogger) Close() error {
 	l.SysLogger.Close()
 	l.AgentLogger.Close()
 	l.AppLogger.Close()
+	if l.ExternalLogger != nil {
+		l.ExternalLogger.Close()
+	}
 	l.Ckwriter.Close()
 	return nil
 }
@@ -117,7 +157,7 @@ func NewLogger(
 	decoders := make([]*decoder.Decoder, queueCount)
 	platformDatas := make([]*grpc.PlatformInfoTable, queueCount)
 	for i := 0; i < queueCount; i++ {
-		logWriter, err := dbwriter.NewAppLogWriter(i, msgType, config, ckwriter)
+		logWriter, err := dbwriter.NewAppLogWriter(i, msgType.String(), config, ckwriter)
 		if err != nil {
 			return nil, err
 		}
@@ -161,3 +201,98 @@ func (l *Logger) Close() error {
 	}
 	return nil
 }
+
+func NewExternalLogger(
+	config *config.Config,
+	manager *dropletqueue.Manager,
+	platformDataManager *grpc.PlatformDataManager,
+	ckwriter *ckwriter.CKWriter,
+	otlpReceiver *otlpreceiver.Receiver,
+) (*ExternalLogger, error) {
+	queueCount := config.DecoderQueueCount
+	decodeQueues := manager.NewQueues(
+		"1-listen-to-decode-"+decoder.EXTERNAL_LOG_NAME,
+		config.DecoderQueueSize,
+		queueCount,
+		1,
+		libqueue.OptionFlushIndicator(3*time.Second))
+
+	decoders := make([]*decoder.Decoder, queueCount)
+	platformDatas := make([]*grpc.PlatformInfoTable, queueCount)
+	for i := 0; i < queueCount; i++ {
+		logWriter, err := dbwriter.NewAppLogWriter(i, decoder.EXTERNAL_LOG_NAME, config, ckwriter)
+		if err != nil {
+			return nil, err
+		}
+		platformDatas[i], err = platformDataManager.NewPlatformInfoTable("app-log-" + decoder.EXTERNAL_LOG_NAME + "-" + strconv.Itoa(i))
+		if err != nil {
+			return nil, err
+		}
+		decoders[i] = decoder.NewExternalLogDecoder(
+			i,
+			queue.QueueReader(decodeQueues.FixedMultiQueue[i]),
+			logWriter,
+			platformDatas[i],
+			config,
+		)
+	}
+
+	externalLogger := &ExternalLogger{
+		Config:        config,
+		Decoders:      decoders,
+		PlatformDatas: platformDatas,
+		decodeQueues:  decodeQueues,
+		queueCount:    queueCount,
+	}
+	if config.SyslogListener.Enabled {
+		externalLogger.SyslogListener = listener.NewSyslogListener(&config.SyslogListener, decodeQueues, queueCount)
+	}
+	if config.FluentForwardListener.Enabled {
+		externalLogger.FluentForwardListener = listener.NewFluentForwardListener(&config.FluentForwardListener, decodeQueues, queueCount)
+	}
+	if otlpReceiver != nil {
+		otlpReceiver.RegisterLogsHandler(externalLogger.putOTelLogs)
+	}
+	return externalLogger, nil
+}
+
+// putOTelLogs is called by the otlp receiver, the requests are distributed to the decoders in turn
+func (l *ExternalLogger) putOTelLogs(source *otlpreceiver.Source, request *collectorlogs.ExportLogsServiceRequest) {
+	index := atomic.AddUint64(&l.putCount, 1) % uint64(l.queueCount)
+	l.decodeQueues.Put(queue.HashKey(index), &decoder.OTelLogs{Source: source, Request: request})
+}
+
+func (l *ExternalLogger) Start() {
+	for _, decoder := range l.Decoders {
+		go decoder.Run()
+	}
+	for _, platformData := range l.PlatformDatas {
+		platformData.Start()
+	}
+	if l.SyslogListener != nil {
+		if err := l.SyslogListener.Start(); err != nil {
+			log.Error(err)
+		}
+	}
+	if l.FluentForwardListener != nil {
+		if err := l.FluentForwardListener.Start(); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+func (l *ExternalLogger) Close() error {
+	if l.SyslogListener != nil {
+		l.SyslogListener.Close()
+	}
+	if l.FluentForwardListener != nil {
+		l.FluentForwardListener.Close()
+	}
+	for _, decoder := range l.Decoders {
+		decoder.Close()
+	}
+	for _, platformData := range l.PlatformDatas {
+		platformData.ClosePlatformInfoTable()
+	}
+	return nil
+}
@@ -21,6 +21,7 @@ import (
 	"os"
 
 	"github.com/deepflowio/deepflow/server/ingester/config"
+	"github.com/deepflowio/deepflow/server/libs/ckdb"
 
 	logging "github.com/op/go-logging"
 	yaml "gopkg.in/yaml.v2"
@@ -32,14 +33,37 @@ const (
 	DefaultDecoderQueueCount = 2
 	DefaultDecoderQueueSize  = 16384
 	DefaultTTL               = 720 // hour
+
+	DefaultSyslogPort        = 514
+	DefaultFluentForwardPort = 24224
+	DefaultMaxMessageSize    = 65536
 )
 
+type SyslogListener struct {
+	Enabled        bool  `yaml:"enabled"`
+	UDPPort        int   `yaml:"udp-port"` // 0 means not listening on UDP
+	TCPPort        int   `yaml:"tcp-port"` // 0 means not listening on TCP
+	MaxMessageSize int   `yaml:"max-message-size"`
+	OrgID          int   `yaml:"org-id"`
+	L3EpcID        int32 `yaml:"l3-epc-id"`
+}
+
+type FluentForwardListener struct {
+	Enabled bool  `yaml:"enabled"`
+	Port    int   `yaml:"port"`
+	OrgID   int   `yaml:"org-id"`
+	L3EpcID int32 `yaml:"l3-epc-id"`
+}
+
 type Config struct {
 	Base              *config.Config
 	CKWriterConfig    config.CKWriterConfig `yaml:"application-log-ck-writer"`
 	DecoderQueueCount int                   `yaml:"application-log-decoder-queue-count"`
 	DecoderQueueSize  int                   `yaml:"application-log-decoder-queue-size"`
 	TTL               int                   `yaml:"application-log-ttl-hour"`
+
+	SyslogListener        SyslogListener        `yaml:"application-log-syslog-listener"`
+	FluentForwardListener FluentForwardListener `yaml:"application-log-fluent-forward-listener"`
 }
 
 type ApplicationLogConfig struct {
@@ -53,6 +77,15 @@ func (c *Config) Validate() error {
 	if c.DecoderQueueSize == 0 {
 		c.DecoderQueueSize = DefaultDecoderQueueSize
 	}
+	if c.SyslogListener.MaxMessageSize <= 0 {
+		c.SyslogListener.MaxMessageSize = DefaultMaxMessageSize
+	}
+	if c.SyslogListener.OrgID <= 0 {
+		c.SyslogListener.OrgID = ckdb.DEFAULT_ORG_ID
+	}
+	if c.FluentForwardListener.OrgID <= 0 {
+		c.FluentForwardListener.OrgID = ckdb.DEFAULT_ORG_ID
+	}
 
 	return nil
 }
@@ -65,6 +98,16 @@ func Load(base *config.Config, path string) *Config {
 			DecoderQueueCount: DefaultDecoderQueueCount,
 			DecoderQueueSize:  DefaultDecoderQueueSize,
 			TTL:               DefaultTTL,
+			SyslogListener: SyslogListener{
+				UDPPort:        DefaultSyslogPort,
+				TCPPort:        DefaultSyslogPort,
+				MaxMessageSize: DefaultMaxMessageSize,
+				OrgID:          ckdb.DEFAULT_ORG_ID,
+			},
+			FluentForwardListener: FluentForwardListener{
+				Port:  DefaultFluentForwardPort,
+				OrgID: ckdb.DEFAULT_ORG_ID,
+			},
 		},
 	}
 	if _, err := os.Stat(path); os.IsNotExist(err) {
@@ -93,6 +93,11 @@ var SeverityMap = map[string]uint8{
 	"DEBU":     SEVERITY_DEBUG,
 	"FATA":     SEVERITY_FATAL,
 	"CRIT":     SEVERITY_FATAL,
+
+	// syslog severities
+	"EMERG":  SEVERITY_FATAL,
+	"ALERT":  SEVERITY_FATAL,
+	"NOTICE": SEVERITY_INFO,
 }
 
 func StringToSeverity(str string) uint8 {
@@ -112,6 +117,7 @@ type Counter struct {
 type Decoder struct {
 	index             int
 	msgType           datatype.MessageType
+	name              string
 	platformData      *grpc.PlatformInfoTable
 	inQueue           queue.QueueReader
 	logWriter         *dbwriter.AppLogWriter
@@ -135,6 +141,7 @@ func NewDecoder(
 	return &Decoder{
 		index:             index,
 		msgType:           msgType,
+		name:              msgType.String(),
 		platformData:      platformData,
 		inQueue:           inQueue,
 		debugEnabled:      log.IsEnabledFor(logging.DEBUG),
@@ -145,6 +152,20 @@ func NewDecoder(
 	}
 }
 
+// NewExternalLogDecoder creates a decoder for logs received by the ingester's own listeners and the
+// otlp receiver, the items of inQueue are *ExternalLog or *OTelLogs
+func NewExternalLogDecoder(
+	index int,
+	inQueue queue.QueueReader,
+	logWriter *dbwriter.AppLogWriter,
+	platformData *grpc.PlatformInfoTable,
+	config *config.Config,
+) *Decoder {
+	d := NewDecoder(index, datatype.MESSAGE_TYPE_APPLICATION_LOG, inQueue, logWriter, platformData, config)
+	d.name = EXTERNAL_LOG_NAME
+	return d
+}
+
 func (d *Decoder) GetCounter() interface{} {
 	var counter *Counter
 	counter, d.counter = d.counter, &Counter{}
@@ -152,10 +173,10 @@ func (d *Decoder) GetCounter() interface{} {
 }
 
 func (d *Decoder) Run() {
-	log.Infof("application log (%s-%d) decoder run", d.msgType.String(), d.index)
+	log.Infof("application log (%s-%d) decoder run", d.name, d.index)
 	ingestercommon.RegisterCountableForIngester("decoder", d, stats.OptionStatTags{
 		"thread":   strconv.Itoa(d.index),
-		"msg_type": d.msgType.String()})
+		"msg_type": d.name})
 	buffer := make([]interface{}, BUFFER_SIZE)
 	decoder := &codec.SimpleDecoder{}
 	for {
@@ -165,6 +186,14 @@ func (d *Decoder) Run() {
 				continue
 			}
 			d.counter.InCount++
+			if externalLog, ok := buffer[i].(*ExternalLog); ok {
+				d.handleExternalLog(externalLog)
+				continue
+			}
+			if otelLogs, ok := buffer[i].(*OTelLogs); ok {
+				d.handleOTelLogs(otelLogs)
+				continue
+			}
 			recvBytes, ok := buffer[i].(*receiver.RecvBuffer)
 			if !ok {
 				log.Warning("get application log decode queue data type wrong")
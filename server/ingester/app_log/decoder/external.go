@@ -0,0 +1,126 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package decoder
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/deepflowio/deepflow/server/ingester/app_log/dbwriter"
+	ingestercommon "github.com/deepflowio/deepflow/server/ingester/common"
+	"github.com/deepflowio/deepflow/server/libs/ckdb"
+	flow_metrics "github.com/deepflowio/deepflow/server/libs/flow-metrics"
+	"github.com/deepflowio/deepflow/server/libs/grpc"
+	"github.com/deepflowio/deepflow/server/libs/utils"
+)
+
+const EXTERNAL_LOG_NAME = "external_log"
+
+// ExternalLog is a log received by the ingester's own listeners (syslog, fluent forward) instead of
+// deepflow-agent, so there is no agent id and resource info can only be looked up by the sender ip
+type ExternalLog struct {
+	OrgId      uint16
+	L3EpcID    int32
+	IP         net.IP // address of the log sender
+	Timestamp  time.Time
+	Severity   string
+	AppService string
+	Body       string
+
+	AttributeNames  []string
+	AttributeValues []string
+}
+
+func (l *ExternalLog) AddAttribute(name, value string) {
+	l.AttributeNames = append(l.AttributeNames, name)
+	l.AttributeValues = append(l.AttributeValues, value)
+}
+
+func (d *Decoder) handleExternalLog(l *ExternalLog) {
+	log.Debugf("recv external log: %+v", l)
+	if err := d.WriteExternalLog(l); err != nil {
+		if d.counter.ErrorCount == 0 {
+			log.Warningf("external log decode failed: %s", err)
+		}
+		d.counter.ErrorCount++
+		return
+	}
+	d.counter.OutCount++
+}
+
+func (d *Decoder) WriteExternalLog(l *ExternalLog) error {
+	if l.Body == "" {
+		return fmt.Errorf("external log body is empty. app service: %s, ip: %s", l.AppService, l.IP)
+	}
+	s := dbwriter.AcquireApplicationLogStore()
+	s.Type = dbwriter.LOG_TYPE_USER
+	s.Time = uint32(l.Timestamp.Unix())
+	s.Timestamp = l.Timestamp.UnixMicro()
+	s.SetId(s.Time, d.platformData.QueryAnalyzerID())
+	s.OrgId, s.TeamID = l.OrgId, ckdb.INVALID_TEAM_ID
+	s.SeverityNumber = StringToSeverity(l.Severity)
+	s.AppService = l.AppService
+	s.Body = l.Body
+	s.AttributeNames = append(s.AttributeNames, l.AttributeNames...)
+	s.AttributeValues = append(s.AttributeValues, l.AttributeValues...)
+
+	d.fillPlatformInfoWithIP(s, l.L3EpcID, l.IP)
+
+	d.logWriter.Write(s)
+	return nil
+}
+
+// fillPlatformInfoWithIP looks up the resource info of logs without agent id by the ip of the instance
+func (d *Decoder) fillPlatformInfoWithIP(s *dbwriter.ApplicationLogStore, l3EpcID int32, ip net.IP) {
+	s.L3EpcID = l3EpcID
+	var info *grpc.Info
+	if ip4 := ip.To4(); ip4 != nil {
+		s.IsIPv4 = true
+		s.IP4 = utils.IpToUint32(ip4)
+		info = d.platformData.QueryIPV4Infos(s.OrgId, s.L3EpcID, s.IP4)
+	} else if ip != nil {
+		s.IsIPv4 = false
+		s.IP6 = ip
+		info = d.platformData.QueryIPV6Infos(s.OrgId, s.L3EpcID, s.IP6)
+	}
+
+	podGroupType := uint8(0)
+	if info != nil {
+		s.RegionID = uint16(info.RegionID)
+		s.AZID = uint16(info.AZID)
+		s.L3EpcID = info.EpcID
+		s.HostID = uint16(info.HostID)
+		s.PodID = info.PodID
+		s.PodNodeID = info.PodNodeID
+		s.PodNSID = uint16(info.PodNSID)
+		s.PodClusterID = uint16(info.PodClusterID)
+		s.PodGroupID = info.PodGroupID
+		podGroupType = info.PodGroupType
+		s.L3DeviceType = uint8(info.DeviceType)
+		s.L3DeviceID = info.DeviceID
+		s.SubnetID = uint16(info.SubnetID)
+		// if it is just Pod Node, there is no need to match the service
+		if ingestercommon.IsPodServiceIP(flow_metrics.DeviceType(s.L3DeviceType), s.PodID, 0) {
+			s.ServiceID = d.platformData.QueryService(s.OrgId,
+				s.PodID, s.PodNodeID, uint32(s.PodClusterID), s.PodGroupID, s.L3EpcID, !s.IsIPv4, s.IP4, s.IP6, 0, 0)
+		}
+	}
+
+	s.AutoInstanceID, s.AutoInstanceType = ingestercommon.GetAutoInstance(s.PodID, 0, s.PodNodeID, s.L3DeviceID, uint32(s.SubnetID), uint8(s.L3DeviceType), s.L3EpcID)
+	s.AutoServiceID, s.AutoServiceType = ingestercommon.GetAutoService(s.ServiceID, s.PodGroupID, 0, s.PodNodeID, s.L3DeviceID, uint32(s.SubnetID), uint8(s.L3DeviceType), podGroupType, s.L3EpcID)
+}
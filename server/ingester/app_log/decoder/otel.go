@@ -0,0 +1,181 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package decoder
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"time"
+
+	collectorlogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonv1 "go.opentelemetry.io/proto/otlp/common/v1"
+	logsv1 "go.opentelemetry.io/proto/otlp/logs/v1"
+
+	"github.com/deepflowio/deepflow/server/ingester/app_log/dbwriter"
+	"github.com/deepflowio/deepflow/server/ingester/pkg/otlpreceiver"
+	"github.com/deepflowio/deepflow/server/libs/ckdb"
+)
+
+const (
+	OTEL_SERVICE_NAME = "service.name"
+
+	// the attribute names follow the OpenTelemetry conventions for non-OTLP formats
+	OTEL_SCOPE_NAME    = "otel.scope.name"
+	OTEL_SCOPE_VERSION = "otel.scope.version"
+	OTEL_SEVERITY_TEXT = "severity_text"
+)
+
+// OTelLogs is an OTLP logs request received by the otlp receiver of ingester, it is decoded by the
+// external log decoders
+type OTelLogs struct {
+	Source  *otlpreceiver.Source
+	Request *collectorlogs.ExportLogsServiceRequest
+}
+
+// otelLogResource is the info shared by all log records of a resource
+type otelLogResource struct {
+	appService string
+	ip         net.IP
+	attributes []*commonv1.KeyValue
+}
+
+func parseOTelLogResource(attributes []*commonv1.KeyValue) *otelLogResource {
+	r := &otelLogResource{ip: otlpreceiver.ResourceIP(attributes), attributes: attributes}
+	for _, attr := range attributes {
+		if attr.Key == OTEL_SERVICE_NAME {
+			r.appService = attr.Value.GetStringValue()
+			break
+		}
+	}
+	return r
+}
+
+func (d *Decoder) handleOTelLogs(l *OTelLogs) {
+	for _, resourceLogs := range l.Request.GetResourceLogs() {
+		resource := parseOTelLogResource(resourceLogs.GetResource().GetAttributes())
+		for _, scopeLogs := range resourceLogs.GetScopeLogs() {
+			for _, record := range scopeLogs.GetLogRecords() {
+				if err := d.WriteOTelLog(l.Source, resource, scopeLogs.GetScope(), record); err != nil {
+					if d.counter.ErrorCount == 0 {
+						log.Warningf("otel log decode failed: %s", err)
+					}
+					d.counter.ErrorCount++
+					continue
+				}
+				d.counter.OutCount++
+			}
+		}
+	}
+}
+
+func (d *Decoder) WriteOTelLog(source *otlpreceiver.Source, resource *otelLogResource, scope *commonv1.InstrumentationScope, record *logsv1.LogRecord) error {
+	body := otlpreceiver.ValueString(record.GetBody())
+	// logs of events may carry only attributes
+	if body == "" && len(record.GetAttributes()) == 0 {
+		return fmt.Errorf("otel log body is empty. app service: %s, ip: %s", resource.appService, source.IP)
+	}
+
+	s := dbwriter.AcquireApplicationLogStore()
+	timestamp := record.GetTimeUnixNano()
+	if timestamp == 0 {
+		timestamp = record.GetObservedTimeUnixNano()
+	}
+	if timestamp == 0 {
+		timestamp = uint64(time.Now().UnixNano())
+	}
+	s.Type = dbwriter.LOG_TYPE_USER
+	s.Time = uint32(timestamp / uint64(time.Second))
+	s.Timestamp = int64(timestamp / uint64(time.Microsecond))
+	s.SetId(s.Time, d.platformData.QueryAnalyzerID())
+	s.OrgId, s.TeamID = source.OrgId, ckdb.INVALID_TEAM_ID
+
+	// keep the ids in the same format as l7_flow_log, so that logs can be found by the trace_id/span_id of spans
+	if len(record.GetTraceId()) > 0 {
+		s.TraceID = hex.EncodeToString(record.GetTraceId())
+	}
+	if len(record.GetSpanId()) > 0 {
+		s.SpanID = hex.EncodeToString(record.GetSpanId())
+	}
+	s.TraceFlags = record.GetFlags() & uint32(logsv1.LogRecordFlags_LOG_RECORD_FLAGS_TRACE_FLAGS_MASK)
+
+	s.SeverityNumber = OTelSeverityToSeverity(record.GetSeverityNumber(), record.GetSeverityText())
+	s.AppService = resource.appService
+	s.Body = body
+
+	// attributes of the log record take precedence over those of the resource with the same name
+	for _, attr := range resource.attributes {
+		if indexOfOTelAttribute(record.GetAttributes(), attr.Key) < 0 {
+			s.AttributeNames = append(s.AttributeNames, attr.Key)
+			s.AttributeValues = append(s.AttributeValues, otlpreceiver.ValueString(attr.Value))
+		}
+	}
+	for _, attr := range record.GetAttributes() {
+		s.AttributeNames = append(s.AttributeNames, attr.Key)
+		s.AttributeValues = append(s.AttributeValues, otlpreceiver.ValueString(attr.Value))
+	}
+	if scope.GetName() != "" {
+		s.AttributeNames = append(s.AttributeNames, OTEL_SCOPE_NAME)
+		s.AttributeValues = append(s.AttributeValues, scope.GetName())
+	}
+	if scope.GetVersion() != "" {
+		s.AttributeNames = append(s.AttributeNames, OTEL_SCOPE_VERSION)
+		s.AttributeValues = append(s.AttributeValues, scope.GetVersion())
+	}
+	if record.GetSeverityText() != "" {
+		s.AttributeNames = append(s.AttributeNames, OTEL_SEVERITY_TEXT)
+		s.AttributeValues = append(s.AttributeValues, record.GetSeverityText())
+	}
+
+	ip := resource.ip
+	if ip == nil {
+		ip = source.IP
+	}
+	d.fillPlatformInfoWithIP(s, source.L3EpcID, ip)
+
+	d.logWriter.Write(s)
+	return nil
+}
+
+// OTelSeverityToSeverity maps the severity number ranges of OpenTelemetry to severities of application log,
+// SeverityText is used only if SeverityNumber is unspecified
+func OTelSeverityToSeverity(number logsv1.SeverityNumber, text string) uint8 {
+	switch {
+	case number >= logsv1.SeverityNumber_SEVERITY_NUMBER_FATAL:
+		return SEVERITY_FATAL
+	case number >= logsv1.SeverityNumber_SEVERITY_NUMBER_ERROR:
+		return SEVERITY_ERROR
+	case number >= logsv1.SeverityNumber_SEVERITY_NUMBER_WARN:
+		return SEVERITY_WARN
+	case number >= logsv1.SeverityNumber_SEVERITY_NUMBER_INFO:
+		return SEVERITY_INFO
+	case number >= logsv1.SeverityNumber_SEVERITY_NUMBER_DEBUG:
+		return SEVERITY_DEBUG
+	case number >= logsv1.SeverityNumber_SEVERITY_NUMBER_TRACE:
+		return SEVERITY_TRACE
+	}
+	return StringToSeverity(text)
+}
+
+func indexOfOTelAttribute(attributes []*commonv1.KeyValue, key string) int {
+	for i, attr := range attributes {
+		if attr.Key == key {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package decoder
+
+import (
+	"testing"
+
+	commonv1 "go.opentelemetry.io/proto/otlp/common/v1"
+	logsv1 "go.opentelemetry.io/proto/otlp/logs/v1"
+
+	"github.com/deepflowio/deepflow/server/ingester/pkg/otlpreceiver"
+)
+
+func TestOTelSeverityToSeverity(t *testing.T) {
+	testCases := []struct {
+		number   logsv1.SeverityNumber
+		text     string
+		severity uint8
+	}{
+		{logsv1.SeverityNumber_SEVERITY_NUMBER_TRACE2, "", SEVERITY_TRACE},
+		{logsv1.SeverityNumber_SEVERITY_NUMBER_DEBUG, "INFO", SEVERITY_DEBUG},
+		{logsv1.SeverityNumber_SEVERITY_NUMBER_INFO4, "", SEVERITY_INFO},
+		{logsv1.SeverityNumber_SEVERITY_NUMBER_WARN3, "", SEVERITY_WARN},
+		{logsv1.SeverityNumber_SEVERITY_NUMBER_ERROR, "", SEVERITY_ERROR},
+		{logsv1.SeverityNumber_SEVERITY_NUMBER_FATAL4, "", SEVERITY_FATAL},
+		{logsv1.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED, "warning", SEVERITY_WARN},
+		{logsv1.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED, "", SEVERITY_UNKNOWN},
+	}
+	for _, tc := range testCases {
+		if severity := OTelSeverityToSeverity(tc.number, tc.text); severity != tc.severity {
+			t.Errorf("severity of %s/%s is %d, expected %d", tc.number, tc.text, severity, tc.severity)
+		}
+	}
+}
+
+func TestParseOTelLogResource(t *testing.T) {
+	stringValue := func(v string) *commonv1.AnyValue {
+		return &commonv1.AnyValue{Value: &commonv1.AnyValue_StringValue{StringValue: v}}
+	}
+	r := parseOTelLogResource([]*commonv1.KeyValue{
+		{Key: OTEL_SERVICE_NAME, Value: stringValue("checkout")},
+		{Key: otlpreceiver.ATTRIBUTE_HOST_IP, Value: &commonv1.AnyValue{Value: &commonv1.AnyValue_ArrayValue{ArrayValue: &commonv1.ArrayValue{
+			Values: []*commonv1.AnyValue{stringValue("10.0.0.1")},
+		}}}},
+		{Key: otlpreceiver.ATTRIBUTE_POD_IP, Value: stringValue("10.1.0.1")},
+	})
+	if r.appService != "checkout" || r.ip.String() != "10.1.0.1" || len(r.attributes) != 3 {
+		t.Errorf("unexpected resource %+v", r)
+	}
+	r = parseOTelLogResource([]*commonv1.KeyValue{{Key: otlpreceiver.ATTRIBUTE_HOST_IP, Value: stringValue("10.0.0.1")}})
+	if r.ip.String() != "10.0.0.1" {
+		t.Errorf("unexpected resource %+v", r)
+	}
+}
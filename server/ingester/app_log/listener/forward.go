@@ -0,0 +1,353 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package listener
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/ugorji/go/codec"
+
+	"github.com/deepflowio/deepflow/server/ingester/app_log/config"
+	"github.com/deepflowio/deepflow/server/ingester/app_log/decoder"
+	ingestercommon "github.com/deepflowio/deepflow/server/ingester/common"
+	"github.com/deepflowio/deepflow/server/libs/queue"
+	"github.com/deepflowio/deepflow/server/libs/stats"
+)
+
+const (
+	FORWARD_EVENT_TIME_EXT_TYPE = 0
+	FORWARD_EVENT_TIME_SIZE     = 8
+	FORWARD_TAG_ATTRIBUTE       = "fluent_tag"
+)
+
+// well-known record keys of fluentd/fluent-bit, the first existing one is used
+var (
+	forwardBodyKeys       = []string{"message", "log", "msg"}
+	forwardSeverityKeys   = []string{"level", "severity", "log_level", "loglevel"}
+	forwardAppServiceKeys = []string{"app_service", "service", "service_name"}
+)
+
+var forwardHandle = &codec.MsgpackHandle{WriteExt: true}
+
+func init() {
+	forwardHandle.RawToString = true
+	forwardHandle.MapType = reflect.TypeOf(map[string]interface{}(nil))
+}
+
+// FluentForwardListener receives logs from fluentd/fluent-bit `forward` output by Forward Protocol
+// Specification v1, supports Message, Forward, PackedForward and CompressedPackedForward modes
+// and ack responses. Handshake (shared key authentication) is not supported.
+type FluentForwardListener struct {
+	listener
+	config *config.FluentForwardListener
+}
+
+func NewFluentForwardListener(cfg *config.FluentForwardListener, outQueues queue.MultiQueueWriter, queueCount int) *FluentForwardListener {
+	return &FluentForwardListener{
+		listener: listener{
+			name:       "fluent_forward",
+			orgId:      uint16(cfg.OrgID),
+			l3EpcId:    cfg.L3EpcID,
+			outQueues:  outQueues,
+			queueCount: queueCount,
+			counter:    &Counter{},
+		},
+		config: cfg,
+	}
+}
+
+func (l *FluentForwardListener) Start() error {
+	var err error
+	if l.tcpListener, err = net.Listen("tcp", fmt.Sprintf(":%d", l.config.Port)); err != nil {
+		return fmt.Errorf("fluent forward listen tcp port %d failed: %s", l.config.Port, err)
+	}
+	go l.serveTCP(l.handleTCPConn)
+	ingestercommon.RegisterCountableForIngester("app_log_listener", l, stats.OptionStatTags{"protocol": l.name})
+	log.Infof("fluent forward listener started, tcp port: %d", l.config.Port)
+	return nil
+}
+
+func (l *FluentForwardListener) Close() error {
+	l.close()
+	return nil
+}
+
+func (l *FluentForwardListener) handleTCPConn(conn net.Conn, hash queue.HashKey) {
+	ip := remoteIP(conn.RemoteAddr())
+	dec := codec.NewDecoder(bufio.NewReader(conn), forwardHandle)
+	enc := codec.NewEncoder(conn, forwardHandle)
+	for {
+		conn.SetReadDeadline(time.Now().Add(TCP_READ_TIMEOUT))
+		var message []interface{}
+		if err := dec.Decode(&message); err != nil {
+			if err != io.EOF && !l.Closed() {
+				l.error("read from %s failed: %s", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		logs, chunk, err := ParseForwardMessage(message)
+		if err != nil {
+			// the stream can not be recovered after a malformed message
+			l.error("parse message from %s failed: %s", conn.RemoteAddr(), err)
+			return
+		}
+		for _, externalLog := range logs {
+			externalLog.IP = ip
+			l.put(hash, externalLog)
+		}
+		if chunk != "" {
+			if err := enc.Encode(map[string]string{"ack": chunk}); err != nil {
+				l.error("ack to %s failed: %s", conn.RemoteAddr(), err)
+				return
+			}
+		}
+	}
+}
+
+// ParseForwardMessage converts a forward protocol message into logs, and returns the `chunk` option
+// which should be acked to the client if exists.
+//
+//	Message:                 [tag, time, record, option]
+//	Forward:                 [tag, [[time, record], ...], option]
+//	PackedForward:           [tag, msgpack stream of [time, record], option]
+//	CompressedPackedForward: [tag, gzipped msgpack stream of [time, record], option{compressed: gzip}]
+func ParseForwardMessage(message []interface{}) ([]*decoder.ExternalLog, string, error) {
+	if len(message) < 2 {
+		return nil, "", fmt.Errorf("forward message has %d elements", len(message))
+	}
+	tag, ok := message[0].(string)
+	if !ok {
+		return nil, "", fmt.Errorf("forward message tag type %T invalid", message[0])
+	}
+
+	var logs []*decoder.ExternalLog
+	var option map[string]interface{}
+	switch entries := message[1].(type) {
+	case []interface{}:
+		if len(message) > 2 {
+			option, _ = message[2].(map[string]interface{})
+		}
+		for _, entry := range entries {
+			l, err := parseForwardEntry(tag, entry)
+			if err != nil {
+				return nil, "", err
+			}
+			logs = append(logs, l)
+		}
+	case []byte, string:
+		if len(message) > 2 {
+			option, _ = message[2].(map[string]interface{})
+		}
+		var err error
+		if logs, err = parsePackedForwardEntries(tag, entries, option); err != nil {
+			return nil, "", err
+		}
+	default:
+		if len(message) < 3 {
+			return nil, "", fmt.Errorf("forward message has %d elements", len(message))
+		}
+		if len(message) > 3 {
+			option, _ = message[3].(map[string]interface{})
+		}
+		l, err := parseForwardEntry(tag, []interface{}{message[1], message[2]})
+		if err != nil {
+			return nil, "", err
+		}
+		logs = append(logs, l)
+	}
+
+	chunk, _ := option["chunk"].(string)
+	return logs, chunk, nil
+}
+
+func parsePackedForwardEntries(tag string, entries interface{}, option map[string]interface{}) ([]*decoder.ExternalLog, error) {
+	var data []byte
+	switch v := entries.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	}
+	var reader io.Reader = bytes.NewReader(data)
+	if compressed, _ := option["compressed"].(string); compressed != "" {
+		if compressed != "gzip" {
+			return nil, fmt.Errorf("forward message compression %s not supported", compressed)
+		}
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+
+	var logs []*decoder.ExternalLog
+	dec := codec.NewDecoder(reader, forwardHandle)
+	for {
+		var entry interface{}
+		if err := dec.Decode(&entry); err != nil {
+			if err == io.EOF {
+				return logs, nil
+			}
+			return nil, err
+		}
+		l, err := parseForwardEntry(tag, entry)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, l)
+	}
+}
+
+// entry is [time, record]
+func parseForwardEntry(tag string, entry interface{}) (*decoder.ExternalLog, error) {
+	pair, ok := entry.([]interface{})
+	if !ok || len(pair) < 2 {
+		return nil, fmt.Errorf("forward entry %v invalid", entry)
+	}
+	timestamp, err := parseForwardTime(pair[0])
+	if err != nil {
+		return nil, err
+	}
+	record, ok := pair[1].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("forward record type %T invalid", pair[1])
+	}
+
+	l := &decoder.ExternalLog{Timestamp: timestamp, AppService: tag}
+	if key, value, ok := lookupForwardRecord(record, forwardSeverityKeys); ok {
+		l.Severity = value
+		delete(record, key)
+	}
+	if key, value, ok := lookupForwardRecord(record, forwardAppServiceKeys); ok {
+		l.AppService = value
+		delete(record, key)
+	}
+	l.AddAttribute(FORWARD_TAG_ATTRIBUTE, tag)
+	if key, value, ok := lookupForwardRecord(record, forwardBodyKeys); ok {
+		l.Body = value
+		delete(record, key)
+		addForwardAttributes(l, "", record)
+	} else {
+		// the whole record is used as body if there is no well-known body key
+		body, err := json.Marshal(forwardJSONValue(record))
+		if err != nil {
+			return nil, err
+		}
+		l.Body = string(body)
+	}
+	return l, nil
+}
+
+func parseForwardTime(t interface{}) (time.Time, error) {
+	switch v := t.(type) {
+	case uint64:
+		return time.Unix(int64(v), 0), nil
+	case int64:
+		return time.Unix(v, 0), nil
+	case float64:
+		sec, frac := math.Modf(v)
+		return time.Unix(int64(sec), int64(frac*1e9)), nil
+	case *codec.RawExt:
+		return parseForwardEventTime(v)
+	case codec.RawExt:
+		return parseForwardEventTime(&v)
+	}
+	return time.Time{}, fmt.Errorf("forward time type %T invalid", t)
+}
+
+// EventTime is ext type 0 with 32-bit big-endian seconds and nanoseconds
+func parseForwardEventTime(ext *codec.RawExt) (time.Time, error) {
+	if ext.Tag != FORWARD_EVENT_TIME_EXT_TYPE || len(ext.Data) != FORWARD_EVENT_TIME_SIZE {
+		return time.Time{}, errors.New("forward event time invalid")
+	}
+	return time.Unix(int64(binary.BigEndian.Uint32(ext.Data)), int64(binary.BigEndian.Uint32(ext.Data[4:]))), nil
+}
+
+func lookupForwardRecord(record map[string]interface{}, keys []string) (string, string, bool) {
+	for _, key := range keys {
+		if value, ok := record[key]; ok {
+			switch v := value.(type) {
+			case string:
+				return key, v, true
+			case []byte:
+				return key, string(v), true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// nested maps are flattened with '.', e.g.: `kubernetes.pod_name`
+func addForwardAttributes(l *decoder.ExternalLog, prefix string, record map[string]interface{}) {
+	keys := make([]string, 0, len(record))
+	for key := range record {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		name := prefix + key
+		switch v := record[key].(type) {
+		case map[string]interface{}:
+			addForwardAttributes(l, name+".", v)
+		case string:
+			l.AddAttribute(name, v)
+		case []byte:
+			l.AddAttribute(name, string(v))
+		case nil:
+		case []interface{}:
+			if value, err := json.Marshal(forwardJSONValue(v)); err == nil {
+				l.AddAttribute(name, string(value))
+			}
+		default:
+			l.AddAttribute(name, fmt.Sprintf("%v", v))
+		}
+	}
+}
+
+// converts []byte to string so that it is not encoded as base64
+func forwardJSONValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[key] = forwardJSONValue(value)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, value := range v {
+			s[i] = forwardJSONValue(value)
+		}
+		return s
+	}
+	return value
+}
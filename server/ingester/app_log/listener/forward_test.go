@@ -0,0 +1,188 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package listener
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ugorji/go/codec"
+
+	"github.com/deepflowio/deepflow/server/ingester/app_log/config"
+	"github.com/deepflowio/deepflow/server/ingester/app_log/decoder"
+	"github.com/deepflowio/deepflow/server/libs/queue"
+)
+
+type testQueue struct {
+	sync.Mutex
+	items []interface{}
+}
+
+func (q *testQueue) Put(_ queue.HashKey, items ...interface{}) error {
+	q.Lock()
+	q.items = append(q.items, items...)
+	q.Unlock()
+	return nil
+}
+func (q *testQueue) Puts([]queue.HashKey, []interface{}) error { return nil }
+func (q *testQueue) Len(queue.HashKey) int                     { return 0 }
+func (q *testQueue) Close() error                              { return nil }
+
+func encodeForward(t *testing.T, v interface{}) []byte {
+	var buf []byte
+	if err := codec.NewEncoderBytes(&buf, forwardHandle).Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+// encodes then decodes message as it is received from the wire
+func decodeForward(t *testing.T, v interface{}) []interface{} {
+	var message []interface{}
+	if err := codec.NewDecoderBytes(encodeForward(t, v), forwardHandle).Decode(&message); err != nil {
+		t.Fatal(err)
+	}
+	return message
+}
+
+func eventTime(t time.Time) *codec.RawExt {
+	data := make([]byte, FORWARD_EVENT_TIME_SIZE)
+	binary.BigEndian.PutUint32(data, uint32(t.Unix()))
+	binary.BigEndian.PutUint32(data[4:], uint32(t.Nanosecond()))
+	return &codec.RawExt{Tag: FORWARD_EVENT_TIME_EXT_TYPE, Data: data}
+}
+
+func TestParseForwardMessage(t *testing.T) {
+	ts := time.Unix(1700000000, 123000000)
+	record := map[string]interface{}{
+		"log":   "GET /index.html 200",
+		"level": "warn",
+		"kubernetes": map[string]interface{}{
+			"pod_name":  "web-0",
+			"namespace": "default",
+		},
+		"code": 200,
+	}
+	entry := []interface{}{eventTime(ts), record}
+
+	var packed []byte
+	packed = append(packed, encodeForward(t, entry)...)
+	packed = append(packed, encodeForward(t, []interface{}{ts.Unix(), map[string]interface{}{"message": "second"}})...)
+	var compressed bytes.Buffer
+	gzipWriter := gzip.NewWriter(&compressed)
+	gzipWriter.Write(packed)
+	gzipWriter.Close()
+
+	testCases := []struct {
+		name    string
+		message []interface{}
+		count   int
+		chunk   string
+	}{
+		{"message", []interface{}{"app.web", eventTime(ts), record, map[string]interface{}{"chunk": "c1"}}, 1, "c1"},
+		{"forward", []interface{}{"app.web", []interface{}{entry, entry}}, 2, ""},
+		{"packed forward", []interface{}{"app.web", packed, map[string]interface{}{"size": 2}}, 2, ""},
+		{"compressed packed forward", []interface{}{"app.web", compressed.Bytes(), map[string]interface{}{"compressed": "gzip", "chunk": "c2"}}, 2, "c2"},
+	}
+	for _, tc := range testCases {
+		logs, chunk, err := ParseForwardMessage(decodeForward(t, tc.message))
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", tc.name, err)
+			continue
+		}
+		if len(logs) != tc.count || chunk != tc.chunk {
+			t.Errorf("%s: got %d logs, chunk %s, expected %d, %s", tc.name, len(logs), chunk, tc.count, tc.chunk)
+			continue
+		}
+		l := logs[0]
+		if !l.Timestamp.Equal(ts) || l.Body != "GET /index.html 200" || l.Severity != "warn" || l.AppService != "app.web" {
+			t.Errorf("%s: got %s %q %s %s", tc.name, l.Timestamp, l.Body, l.Severity, l.AppService)
+		}
+		expectedNames := []string{FORWARD_TAG_ATTRIBUTE, "code", "kubernetes.namespace", "kubernetes.pod_name"}
+		expectedValues := []string{"app.web", "200", "default", "web-0"}
+		if len(l.AttributeNames) != len(expectedNames) {
+			t.Errorf("%s: got attributes %v %v", tc.name, l.AttributeNames, l.AttributeValues)
+			continue
+		}
+		for i := range expectedNames {
+			if l.AttributeNames[i] != expectedNames[i] || l.AttributeValues[i] != expectedValues[i] {
+				t.Errorf("%s: got attributes %v %v", tc.name, l.AttributeNames, l.AttributeValues)
+				break
+			}
+		}
+	}
+
+	// the whole record is used as body if there is no well-known body key
+	logs, _, err := ParseForwardMessage(decodeForward(t, []interface{}{"tag", ts.Unix(), map[string]interface{}{"a": "b", "service": "svc"}}))
+	if err != nil || len(logs) != 1 || logs[0].Body != `{"a":"b"}` || logs[0].AppService != "svc" {
+		t.Errorf("ParseForwardMessage() = %+v, %v", logs, err)
+	}
+
+	for _, message := range [][]interface{}{
+		{"tag"},
+		{1, ts.Unix(), record},
+		{"tag", "not a time", record},
+		{"tag", ts.Unix(), "not a record"},
+		{"tag", []byte{0x01}, map[string]interface{}{"compressed": "zstd"}},
+	} {
+		if _, _, err := ParseForwardMessage(decodeForward(t, message)); err == nil {
+			t.Errorf("ParseForwardMessage(%v) expected error", message)
+		}
+	}
+}
+
+func TestFluentForwardListener(t *testing.T) {
+	q := &testQueue{}
+	cfg := &config.FluentForwardListener{Enabled: true, Port: 0, OrgID: 2}
+	l := NewFluentForwardListener(cfg, q, 1)
+	var err error
+	if l.tcpListener, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	go l.serveTCP(l.handleTCPConn)
+	defer l.Close()
+
+	conn, err := net.Dial("tcp", l.tcpListener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	message := []interface{}{"tag", []interface{}{
+		[]interface{}{time.Now().Unix(), map[string]interface{}{"log": "hello"}},
+	}, map[string]interface{}{"chunk": "abc"}}
+	conn.Write(encodeForward(t, message))
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var ack map[string]interface{}
+	if err := codec.NewDecoder(conn, forwardHandle).Decode(&ack); err != nil || ack["ack"] != "abc" {
+		t.Fatalf("ack = %v, %v", ack, err)
+	}
+	q.Lock()
+	defer q.Unlock()
+	if len(q.items) != 1 {
+		t.Fatalf("got %d logs", len(q.items))
+	}
+	externalLog := q.items[0].(*decoder.ExternalLog)
+	if externalLog.Body != "hello" || externalLog.OrgId != 2 || !externalLog.IP.IsLoopback() {
+		t.Errorf("got %+v", externalLog)
+	}
+}
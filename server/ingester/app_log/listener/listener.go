@@ -0,0 +1,130 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package listener
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	logging "github.com/op/go-logging"
+
+	"github.com/deepflowio/deepflow/server/ingester/app_log/decoder"
+	"github.com/deepflowio/deepflow/server/libs/queue"
+)
+
+var log = logging.MustGetLogger("app_log.listener")
+
+const (
+	TCP_READ_TIMEOUT = 5 * time.Minute
+)
+
+type Counter struct {
+	RecvCount  int64 `statsd:"recv-count"`
+	ConnCount  int64 `statsd:"conn-count"`
+	ErrorCount int64 `statsd:"err-count"`
+}
+
+// listener is the common part of syslog and fluent forward listeners, it accepts tcp connections
+// and puts the parsed logs into the decode queues
+type listener struct {
+	name       string
+	orgId      uint16
+	l3EpcId    int32
+	outQueues  queue.MultiQueueWriter
+	queueCount int
+
+	tcpListener net.Listener
+	conns       sync.Map // net.Conn -> struct{}
+	closed      int32
+
+	counter *Counter
+}
+
+func (l *listener) GetCounter() interface{} {
+	counter := &Counter{
+		RecvCount:  atomic.SwapInt64(&l.counter.RecvCount, 0),
+		ConnCount:  atomic.SwapInt64(&l.counter.ConnCount, 0),
+		ErrorCount: atomic.SwapInt64(&l.counter.ErrorCount, 0),
+	}
+	return counter
+}
+
+func (l *listener) Closed() bool {
+	return atomic.LoadInt32(&l.closed) == 1
+}
+
+func (l *listener) put(hash queue.HashKey, externalLog *decoder.ExternalLog) {
+	externalLog.OrgId, externalLog.L3EpcID = l.orgId, l.l3EpcId
+	atomic.AddInt64(&l.counter.RecvCount, 1)
+	l.outQueues.Put(hash%queue.HashKey(l.queueCount), externalLog)
+}
+
+func (l *listener) error(format string, args ...interface{}) {
+	if atomic.AddInt64(&l.counter.ErrorCount, 1) == 1 {
+		log.Warningf(l.name+" "+format, args...)
+	}
+}
+
+func (l *listener) serveTCP(handle func(conn net.Conn, hash queue.HashKey)) {
+	var hash queue.HashKey
+	for {
+		conn, err := l.tcpListener.Accept()
+		if err != nil {
+			if l.Closed() {
+				return
+			}
+			log.Warningf("%s accept failed: %s", l.name, err)
+			time.Sleep(time.Second)
+			continue
+		}
+		atomic.AddInt64(&l.counter.ConnCount, 1)
+		l.conns.Store(conn, struct{}{})
+		hash++
+		go func(conn net.Conn, hash queue.HashKey) {
+			defer func() {
+				l.conns.Delete(conn)
+				conn.Close()
+			}()
+			handle(conn, hash)
+		}(conn, hash)
+	}
+}
+
+func (l *listener) close() {
+	if !atomic.CompareAndSwapInt32(&l.closed, 0, 1) {
+		return
+	}
+	if l.tcpListener != nil {
+		l.tcpListener.Close()
+	}
+	l.conns.Range(func(conn, _ interface{}) bool {
+		conn.(net.Conn).Close()
+		return true
+	})
+}
+
+func remoteIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	}
+	return nil
+}
@@ -0,0 +1,390 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package listener
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/deepflowio/deepflow/server/ingester/app_log/config"
+	"github.com/deepflowio/deepflow/server/ingester/app_log/decoder"
+	ingestercommon "github.com/deepflowio/deepflow/server/ingester/common"
+	"github.com/deepflowio/deepflow/server/libs/queue"
+	"github.com/deepflowio/deepflow/server/libs/stats"
+)
+
+const (
+	SYSLOG_DEFAULT_PRIORITY = 13 // user.notice, used when PRI part is missing as RFC 3164 4.3.3
+	SYSLOG_MAX_PRIORITY     = 191
+	SYSLOG_NIL_VALUE        = "-"
+	SYSLOG_MAX_TAG_LEN      = 48
+)
+
+// indexed by syslog severity code, names are recognized by decoder.StringToSeverity
+var syslogSeverityNames = [8]string{"EMERG", "ALERT", "CRIT", "ERROR", "WARNING", "NOTICE", "INFO", "DEBUG"}
+
+var syslogFacilityNames = [24]string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+var (
+	errEmptySyslog   = errors.New("empty syslog message")
+	utf8BOM          = []byte{0xEF, 0xBB, 0xBF}
+	rfc3164Timestamp = "Jan _2 15:04:05"
+)
+
+// SyslogListener receives RFC 5424 and RFC 3164 syslog messages over UDP and TCP (RFC 6587 octet
+// counting or newline delimited framing)
+type SyslogListener struct {
+	listener
+	config  *config.SyslogListener
+	udpConn *net.UDPConn
+}
+
+func NewSyslogListener(cfg *config.SyslogListener, outQueues queue.MultiQueueWriter, queueCount int) *SyslogListener {
+	return &SyslogListener{
+		listener: listener{
+			name:       "syslog",
+			orgId:      uint16(cfg.OrgID),
+			l3EpcId:    cfg.L3EpcID,
+			outQueues:  outQueues,
+			queueCount: queueCount,
+			counter:    &Counter{},
+		},
+		config: cfg,
+	}
+}
+
+func (l *SyslogListener) Start() error {
+	var err error
+	if l.config.UDPPort > 0 {
+		if l.udpConn, err = net.ListenUDP("udp", &net.UDPAddr{Port: l.config.UDPPort}); err != nil {
+			return fmt.Errorf("syslog listen udp port %d failed: %s", l.config.UDPPort, err)
+		}
+		go l.serveUDP()
+	}
+	if l.config.TCPPort > 0 {
+		if l.tcpListener, err = net.Listen("tcp", fmt.Sprintf(":%d", l.config.TCPPort)); err != nil {
+			l.Close()
+			return fmt.Errorf("syslog listen tcp port %d failed: %s", l.config.TCPPort, err)
+		}
+		go l.serveTCP(l.handleTCPConn)
+	}
+	ingestercommon.RegisterCountableForIngester("app_log_listener", l, stats.OptionStatTags{"protocol": l.name})
+	log.Infof("syslog listener started, udp port: %d, tcp port: %d", l.config.UDPPort, l.config.TCPPort)
+	return nil
+}
+
+func (l *SyslogListener) Close() error {
+	l.close()
+	if l.udpConn != nil {
+		l.udpConn.Close()
+	}
+	return nil
+}
+
+func (l *SyslogListener) serveUDP() {
+	buffer := make([]byte, l.config.MaxMessageSize)
+	for {
+		n, addr, err := l.udpConn.ReadFromUDP(buffer)
+		if err != nil {
+			if l.Closed() {
+				return
+			}
+			l.error("read udp failed: %s", err)
+			continue
+		}
+		l.handleMessage(buffer[:n], addr.IP, queue.HashKey(addr.Port))
+	}
+}
+
+func (l *SyslogListener) handleTCPConn(conn net.Conn, hash queue.HashKey) {
+	ip := remoteIP(conn.RemoteAddr())
+	reader := bufio.NewReaderSize(conn, 4096)
+	for {
+		conn.SetReadDeadline(time.Now().Add(TCP_READ_TIMEOUT))
+		msg, err := ReadSyslogFrame(reader, l.config.MaxMessageSize)
+		if err != nil {
+			if err != io.EOF && !l.Closed() {
+				l.error("read tcp from %s failed: %s", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		l.handleMessage(msg, ip, hash)
+	}
+}
+
+func (l *SyslogListener) handleMessage(msg []byte, ip net.IP, hash queue.HashKey) {
+	externalLog, err := ParseSyslog(msg, time.Now())
+	if err != nil {
+		l.error("parse message from %s failed: %s", ip, err)
+		return
+	}
+	externalLog.IP = ip
+	l.put(hash, externalLog)
+}
+
+// ReadSyslogFrame reads a syslog message from TCP stream, frames are either octet counted
+// (`MSG-LEN SP SYSLOG-MSG`) or terminated by LF as RFC 6587
+func ReadSyslogFrame(reader *bufio.Reader, maxSize int) ([]byte, error) {
+	for {
+		first, err := reader.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		if first[0] < '0' || first[0] > '9' {
+			line, err := reader.ReadSlice('\n')
+			if err == bufio.ErrBufferFull {
+				// message larger than the reader buffer, read the remaining part
+				msg := append([]byte{}, line...)
+				for err == bufio.ErrBufferFull && len(msg) < maxSize {
+					line, err = reader.ReadSlice('\n')
+					msg = append(msg, line...)
+				}
+				if err == bufio.ErrBufferFull {
+					return nil, fmt.Errorf("message size exceeds %d", maxSize)
+				}
+				line = msg
+			}
+			if err != nil && (err != io.EOF || len(line) == 0) {
+				return nil, err
+			}
+			line = bytes.TrimRight(line, "\r\n")
+			if len(line) == 0 {
+				continue
+			}
+			return line, nil
+		}
+
+		lengthStr, err := reader.ReadString(' ')
+		if err != nil {
+			return nil, err
+		}
+		length, err := strconv.Atoi(lengthStr[:len(lengthStr)-1])
+		if err != nil || length <= 0 || length > maxSize {
+			return nil, fmt.Errorf("invalid message length %q", lengthStr)
+		}
+		msg := make([]byte, length)
+		if _, err := io.ReadFull(reader, msg); err != nil {
+			return nil, err
+		}
+		return msg, nil
+	}
+}
+
+// ParseSyslog parses a RFC 5424 or RFC 3164 syslog message. now is used when the message has no
+// timestamp, and to complete the year of RFC 3164 timestamps.
+func ParseSyslog(msg []byte, now time.Time) (*decoder.ExternalLog, error) {
+	msg = bytes.TrimRight(msg, "\r\n\x00")
+	if len(msg) == 0 {
+		return nil, errEmptySyslog
+	}
+	l := &decoder.ExternalLog{Timestamp: now}
+	priority, rest, ok := parseSyslogPriority(msg)
+	if !ok {
+		priority, rest = SYSLOG_DEFAULT_PRIORITY, msg
+	}
+	l.Severity = syslogSeverityNames[priority%8]
+	l.AddAttribute("facility", syslogFacilityNames[priority/8])
+
+	if len(rest) >= 2 && rest[0] == '1' && rest[1] == ' ' {
+		if err := parseRFC5424(rest[2:], l); err != nil {
+			return nil, err
+		}
+	} else {
+		parseRFC3164(rest, now, l)
+	}
+	if l.Body == "" {
+		return nil, errEmptySyslog
+	}
+	return l, nil
+}
+
+func parseSyslogPriority(msg []byte) (int, []byte, bool) {
+	if len(msg) < 3 || msg[0] != '<' {
+		return 0, msg, false
+	}
+	end := bytes.IndexByte(msg, '>')
+	if end < 2 || end > 4 {
+		return 0, msg, false
+	}
+	priority, err := strconv.Atoi(string(msg[1:end]))
+	if err != nil || priority < 0 || priority > SYSLOG_MAX_PRIORITY {
+		return 0, msg, false
+	}
+	return priority, msg[end+1:], true
+}
+
+func nextSyslogField(msg []byte) (string, []byte, bool) {
+	end := bytes.IndexByte(msg, ' ')
+	if end <= 0 {
+		if len(msg) == 0 {
+			return "", nil, false
+		}
+		return string(msg), nil, true
+	}
+	return string(msg[:end]), msg[end+1:], true
+}
+
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA [MSG]
+func parseRFC5424(msg []byte, l *decoder.ExternalLog) error {
+	var fields [5]string
+	for i := range fields {
+		var ok bool
+		if fields[i], msg, ok = nextSyslogField(msg); !ok {
+			return fmt.Errorf("rfc5424 header has %d fields", i)
+		}
+	}
+	if fields[0] != SYSLOG_NIL_VALUE {
+		timestamp, err := time.Parse(time.RFC3339Nano, fields[0])
+		if err != nil {
+			return fmt.Errorf("rfc5424 timestamp %s invalid: %s", fields[0], err)
+		}
+		l.Timestamp = timestamp
+	}
+	if fields[1] != SYSLOG_NIL_VALUE {
+		l.AddAttribute("host", fields[1])
+	}
+	if fields[2] != SYSLOG_NIL_VALUE {
+		l.AppService = fields[2]
+	}
+	if fields[3] != SYSLOG_NIL_VALUE {
+		l.AddAttribute("procid", fields[3])
+	}
+	if fields[4] != SYSLOG_NIL_VALUE {
+		l.AddAttribute("msgid", fields[4])
+	}
+
+	if len(msg) > 0 && msg[0] == '-' {
+		msg = msg[1:]
+	} else {
+		var err error
+		if msg, err = parseStructuredData(msg, l); err != nil {
+			return err
+		}
+	}
+	if len(msg) > 0 && msg[0] == ' ' {
+		msg = msg[1:]
+	}
+	l.Body = string(bytes.TrimPrefix(msg, utf8BOM))
+	return nil
+}
+
+// parses `[SD-ID PARAM-NAME="PARAM-VALUE" ...]...`, params are added as attributes named `SD-ID.PARAM-NAME`
+func parseStructuredData(msg []byte, l *decoder.ExternalLog) ([]byte, error) {
+	if len(msg) == 0 || msg[0] != '[' {
+		return nil, errors.New("rfc5424 structured data invalid")
+	}
+	for len(msg) > 0 && msg[0] == '[' {
+		end := bytes.IndexAny(msg, " ]")
+		if end <= 1 {
+			return nil, errors.New("rfc5424 structured data id invalid")
+		}
+		id := string(msg[1:end])
+		msg = msg[end:]
+		for len(msg) > 0 && msg[0] == ' ' {
+			eq := bytes.IndexByte(msg, '=')
+			if eq <= 1 || eq+1 >= len(msg) || msg[eq+1] != '"' {
+				return nil, fmt.Errorf("rfc5424 structured data %s param invalid", id)
+			}
+			name := string(msg[1:eq])
+			value := make([]byte, 0, 16)
+			i := eq + 2
+			for ; i < len(msg) && msg[i] != '"'; i++ {
+				// only '"', '\' and ']' are escaped
+				if msg[i] == '\\' && i+1 < len(msg) && (msg[i+1] == '"' || msg[i+1] == '\\' || msg[i+1] == ']') {
+					i++
+				}
+				value = append(value, msg[i])
+			}
+			if i >= len(msg) {
+				return nil, fmt.Errorf("rfc5424 structured data %s param %s not terminated", id, name)
+			}
+			l.AddAttribute(id+"."+name, string(value))
+			msg = msg[i+1:]
+		}
+		if len(msg) == 0 || msg[0] != ']' {
+			return nil, fmt.Errorf("rfc5424 structured data %s not terminated", id)
+		}
+		msg = msg[1:]
+	}
+	return msg, nil
+}
+
+// <PRI>Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG
+// RFC 3164 only describes the observed formats, so parts can not be recognized are kept in body.
+func parseRFC3164(msg []byte, now time.Time, l *decoder.ExternalLog) {
+	if timestamp, rest, ok := parseRFC3164Timestamp(msg, now); ok {
+		l.Timestamp = timestamp
+		msg = rest
+		if host, rest, ok := nextSyslogField(msg); ok && len(rest) > 0 {
+			l.AddAttribute("host", host)
+			msg = rest
+		}
+	}
+	msg = bytes.TrimLeft(msg, " ")
+
+	// TAG is alphanumeric characters terminated by '[', ':' or space
+	tagEnd := bytes.IndexAny(msg, "[: ")
+	if tagEnd > 0 && tagEnd <= SYSLOG_MAX_TAG_LEN {
+		tag, rest := string(msg[:tagEnd]), msg[tagEnd:]
+		pid := ""
+		if rest[0] == '[' {
+			if end := bytes.IndexByte(rest, ']'); end > 1 {
+				pid, rest = string(rest[1:end]), rest[end+1:]
+			}
+		}
+		if len(rest) > 0 && rest[0] == ':' {
+			l.AppService = tag
+			if pid != "" {
+				l.AddAttribute("procid", pid)
+			}
+			msg = bytes.TrimPrefix(rest[1:], []byte{' '})
+		}
+	}
+	l.Body = string(msg)
+}
+
+func parseRFC3164Timestamp(msg []byte, now time.Time) (time.Time, []byte, bool) {
+	// some senders use RFC 3339 timestamp instead, e.g. rsyslog with RSYSLOG_ForwardFormat template
+	if field, rest, ok := nextSyslogField(msg); ok && len(field) > len("2006-01-02") {
+		if timestamp, err := time.Parse(time.RFC3339Nano, field); err == nil {
+			return timestamp, rest, true
+		}
+	}
+	if len(msg) < len(rfc3164Timestamp) {
+		return time.Time{}, msg, false
+	}
+	timestamp, err := time.ParseInLocation(rfc3164Timestamp, string(msg[:len(rfc3164Timestamp)]), now.Location())
+	if err != nil {
+		return time.Time{}, msg, false
+	}
+	timestamp = timestamp.AddDate(now.Year(), 0, 0)
+	// messages sent at the end of last year
+	if timestamp.After(now.Add(24 * time.Hour)) {
+		timestamp = timestamp.AddDate(-1, 0, 0)
+	}
+	return timestamp, bytes.TrimPrefix(msg[len(rfc3164Timestamp):], []byte{' '}), true
+}
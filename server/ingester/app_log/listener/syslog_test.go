@@ -0,0 +1,149 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package listener
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSyslog(t *testing.T) {
+	now := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name       string
+		msg        string
+		timestamp  time.Time
+		severity   string
+		appService string
+		body       string
+		attributes map[string]string
+		err        bool
+	}{
+		{
+			name:       "rfc5424",
+			msg:        `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"][examplePriority@32473 class="high"] ` + "\xEF\xBB\xBF" + `An application event log entry...`,
+			timestamp:  time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC),
+			severity:   "NOTICE",
+			appService: "evntslog",
+			body:       "An application event log entry...",
+			attributes: map[string]string{
+				"facility":                      "local4",
+				"host":                          "mymachine.example.com",
+				"msgid":                         "ID47",
+				"exampleSDID@32473.iut":         "3",
+				"exampleSDID@32473.eventSource": "Application",
+				"exampleSDID@32473.eventID":     "1011",
+				"examplePriority@32473.class":   "high",
+			},
+		},
+		{
+			name:       "rfc5424 nil values and escaped structured data",
+			msg:        `<11>1 - - - 1234 - [meta k="a\"b\]c\\"] disk failure`,
+			timestamp:  now,
+			severity:   "ERROR",
+			body:       "disk failure",
+			attributes: map[string]string{"facility": "user", "procid": "1234", "meta.k": `a"b]c\`},
+		},
+		{
+			name:      "rfc5424 without message",
+			msg:       `<14>1 2003-10-11T22:14:15Z host app - - -`,
+			err:       true,
+			timestamp: now,
+		},
+		{
+			name:       "rfc3164",
+			msg:        "<34>Oct 11 22:14:15 mymachine su[230]: 'su root' failed for lonvick on /dev/pts/8\n",
+			timestamp:  time.Date(2023, 10, 11, 22, 14, 15, 0, time.UTC),
+			severity:   "CRIT",
+			appService: "su",
+			body:       "'su root' failed for lonvick on /dev/pts/8",
+			attributes: map[string]string{"facility": "auth", "host": "mymachine", "procid": "230"},
+		},
+		{
+			name:       "rfc3164 with rfc3339 timestamp",
+			msg:        "<30>2024-01-02T09:59:00+08:00 node-1 kubelet: started",
+			timestamp:  time.Date(2024, 1, 2, 1, 59, 0, 0, time.UTC),
+			severity:   "INFO",
+			appService: "kubelet",
+			body:       "started",
+			attributes: map[string]string{"facility": "daemon", "host": "node-1"},
+		},
+		{
+			name:       "rfc3164 without tag",
+			msg:        "<15>Jan  2 09:00:00 node-1 hello world",
+			timestamp:  time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC),
+			severity:   "DEBUG",
+			body:       "hello world",
+			attributes: map[string]string{"facility": "user", "host": "node-1"},
+		},
+		{
+			name:       "without priority",
+			msg:        "plain message",
+			timestamp:  now,
+			severity:   "NOTICE",
+			body:       "plain message",
+			attributes: map[string]string{"facility": "user"},
+		},
+	}
+	for _, tc := range testCases {
+		l, err := ParseSyslog([]byte(tc.msg), now)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%s: expected error, got %+v", tc.name, l)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", tc.name, err)
+			continue
+		}
+		if !l.Timestamp.Equal(tc.timestamp) || l.Severity != tc.severity || l.AppService != tc.appService || l.Body != tc.body {
+			t.Errorf("%s: got %s %s %s %q, expected %s %s %s %q", tc.name,
+				l.Timestamp, l.Severity, l.AppService, l.Body, tc.timestamp, tc.severity, tc.appService, tc.body)
+		}
+		attributes := map[string]string{}
+		for i, name := range l.AttributeNames {
+			attributes[name] = l.AttributeValues[i]
+		}
+		if !reflect.DeepEqual(attributes, tc.attributes) {
+			t.Errorf("%s: got attributes %v, expected %v", tc.name, attributes, tc.attributes)
+		}
+	}
+}
+
+func TestReadSyslogFrame(t *testing.T) {
+	stream := "<14>1 - - - - - - first\n\n<14>hello\r\n28 <14>1 - - - - - - multi\nline<14>last"
+	reader := bufio.NewReaderSize(strings.NewReader(stream), 16)
+	expected := []string{"<14>1 - - - - - - first", "<14>hello", "<14>1 - - - - - - multi\nline", "<14>last"}
+	for _, e := range expected {
+		msg, err := ReadSyslogFrame(reader, 1024)
+		if err != nil || string(msg) != e {
+			t.Errorf("ReadSyslogFrame() = %q, %v, expected %q", msg, err, e)
+		}
+	}
+	if _, err := ReadSyslogFrame(reader, 1024); err == nil {
+		t.Error("expected EOF")
+	}
+
+	reader = bufio.NewReaderSize(strings.NewReader("2000 <14>too long"), 16)
+	if _, err := ReadSyslogFrame(reader, 1024); err == nil {
+		t.Error("expected error of too long message")
+	}
+}
@@ -0,0 +1,410 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ckbackup
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/deepflowio/deepflow/server/ingester/common"
+)
+
+const (
+	FORMAT_TSV           = "TabSeparated"
+	READ_BUFFER_SIZE     = 1 << 20
+	CK_INSERT_TIMEOUT    = time.Hour
+	CK_ERROR_BODY_LENGTH = 512
+)
+
+var ckHTTPClient = &http.Client{Timeout: CK_INSERT_TIMEOUT}
+
+type backupOptions struct {
+	filter    tableFilter
+	startTime time.Time
+	endTime   time.Time
+	scheduled bool
+}
+
+type restoreOptions struct {
+	id     string
+	filter tableFilter
+}
+
+func (b *CKBackup) backup(opts *backupOptions, job *Job) (*Manifest, error) {
+	conn, err := b.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	tables, err := getTables(conn, b.cfg.CKDB.Type, &opts.filter)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	m := &Manifest{
+		ID:        job.BackupID,
+		Scheduled: opts.scheduled,
+		CKVersion: common.CK_VERSION,
+		StartTime: opts.startTime,
+		EndTime:   opts.endTime,
+		CreatedAt: now,
+		Status:    STATUS_RUNNING,
+		Tables:    []*TableBackup{},
+	}
+	if err := saveManifest(b.storage, b.cfg.CKBackup.TempDir, m); err != nil {
+		return nil, err
+	}
+	for i, t := range tables {
+		job.SetProgress(fmt.Sprintf("backing up %d/%d table %s.%s", i+1, len(tables), t.fullDatabase(), t.table))
+		tb, err := b.backupTable(conn, m.ID, t, opts)
+		if err != nil {
+			err = fmt.Errorf("backup table %s.%s failed: %s", t.fullDatabase(), t.table, err)
+			m.Status, m.Error, m.FinishedAt = STATUS_FAILED, err.Error(), time.Now()
+			saveManifest(b.storage, b.cfg.CKBackup.TempDir, m)
+			return m, err
+		}
+		if tb != nil {
+			m.Tables = append(m.Tables, tb)
+		}
+	}
+	m.Status, m.FinishedAt = STATUS_SUCCESS, time.Now()
+	return m, saveManifest(b.storage, b.cfg.CKBackup.TempDir, m)
+}
+
+// backupTable exports rows in the time range as gzipped TabSeparated, returns nil if the table has no 'time' column or no rows
+func (b *CKBackup) backupTable(conn *sql.DB, id string, t *dbTable, opts *backupOptions) (*TableBackup, error) {
+	columns, version, err := getColumns(conn, t.fullDatabase(), t.table)
+	if err != nil {
+		return nil, err
+	}
+	if !hasColumn(columns, TIME_COLUMN) {
+		log.Infof("skip backup table %s.%s without column '%s'", t.fullDatabase(), t.table, TIME_COLUMN)
+		return nil, nil
+	}
+
+	f, err := ioutil.TempFile(b.cfg.CKBackup.TempDir, "backup-*.tsv.gz")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	names := make([]string, 0, len(columns))
+	for _, c := range columns {
+		names = append(names, quoteIdentifier(c.Name))
+	}
+	rows, err := conn.Query(fmt.Sprintf("SELECT formatRow('%s', %s) FROM %s.%s WHERE %s>=toDateTime(%d) AND %s<toDateTime(%d)",
+		FORMAT_TSV, strings.Join(names, ","), quoteIdentifier(t.fullDatabase()), quoteIdentifier(t.table),
+		TIME_COLUMN, opts.startTime.Unix(), TIME_COLUMN, opts.endTime.Unix()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	h := sha256.New()
+	gz := gzip.NewWriter(io.MultiWriter(f, h))
+	tb := &TableBackup{
+		OrgID:    t.orgID,
+		Database: t.database,
+		Table:    t.table,
+		Version:  version,
+		Columns:  columns,
+		Object:   objectName(id, t.orgID, t.database, t.table),
+	}
+	var row string
+	for rows.Next() {
+		if err := rows.Scan(&row); err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(gz, row); err != nil {
+			return nil, err
+		}
+		tb.Rows++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	if tb.Rows == 0 {
+		return nil, nil
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	tb.Size, tb.SHA256 = info.Size(), hex.EncodeToString(h.Sum(nil))
+	if err := b.storage.Put(tb.Object, f); err != nil {
+		return nil, err
+	}
+	log.Infof("backup table %s.%s rows %d size %d to %s", t.fullDatabase(), t.table, tb.Rows, tb.Size, tb.Object)
+	return tb, nil
+}
+
+func (b *CKBackup) restore(opts *restoreOptions, job *Job) (string, error) {
+	m, err := loadManifest(b.storage, opts.id)
+	if err != nil {
+		return "", err
+	}
+	if m.Status != STATUS_SUCCESS {
+		return "", fmt.Errorf("can not restore backup '%s' with status '%s'", m.ID, m.Status)
+	}
+	conn, err := b.connect()
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	result := &strings.Builder{}
+	for i, tb := range m.Tables {
+		t := &dbTable{orgID: tb.OrgID, database: tb.Database, table: tb.Table}
+		if !opts.filter.match(t) {
+			continue
+		}
+		job.SetProgress(fmt.Sprintf("restoring %d/%d table %s.%s", i+1, len(m.Tables), t.fullDatabase(), t.table))
+		skips, err := b.restoreTable(conn, tb)
+		if err != nil {
+			return result.String(), fmt.Errorf("restore table %s.%s failed: %s", t.fullDatabase(), t.table, err)
+		}
+		fmt.Fprintf(result, "restored %s.%s rows %d", t.fullDatabase(), t.table, tb.Rows)
+		if len(skips) > 0 {
+			fmt.Fprintf(result, ", skipped columns not in current schema: %s", strings.Join(skips, ","))
+		}
+		result.WriteString("\n")
+	}
+	return result.String(), nil
+}
+
+// restoreTable downloads and verifies the object, then streams the rows in batches to the table of current schema version
+func (b *CKBackup) restoreTable(conn *sql.DB, tb *TableBackup) ([]string, error) {
+	database, table := currentTableName(tb.Database, tb.Table)
+	fullDatabase := (&dbTable{orgID: tb.OrgID, database: database}).fullDatabase()
+	current, _, err := getColumns(conn, fullDatabase, table)
+	if err != nil {
+		return nil, err
+	}
+	if len(current) == 0 {
+		return nil, fmt.Errorf("table %s.%s does not exist in current schema", fullDatabase, table)
+	}
+	inserts, selects, skips := mapColumns(database, table, tb.Columns, current)
+	if len(inserts) == 0 {
+		return nil, fmt.Errorf("no column of the backup exists in table %s.%s", fullDatabase, table)
+	}
+	// the data is sent as the body of the request instead of being a part of the query
+	query := fmt.Sprintf("INSERT INTO %s.%s (%s) SELECT %s FROM input(%s) FORMAT %s",
+		quoteIdentifier(fullDatabase), quoteIdentifier(table), strings.Join(inserts, ","), strings.Join(selects, ","),
+		quoteString(structure(tb.Columns)), FORMAT_TSV)
+
+	f, err := b.download(tb)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	batchSize := b.cfg.CKBackup.RestoreBatchSize << 20
+	batch := &bytes.Buffer{}
+	insert := func() error {
+		if batch.Len() == 0 {
+			return nil
+		}
+		defer batch.Reset()
+		return b.insertTSV(query, batch)
+	}
+	reader := bufio.NewReaderSize(gz, READ_BUFFER_SIZE)
+	for {
+		line, err := reader.ReadBytes('\n')
+		batch.Write(line)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if batch.Len() >= batchSize {
+			if err := insert(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return skips, insert()
+}
+
+// download saves the object to a temporary file and verifies it, so the object is downloaded only once when restoring
+func (b *CKBackup) download(tb *TableBackup) (*os.File, error) {
+	r, err := b.storage.Get(tb.Object)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	f, err := ioutil.TempFile(b.cfg.CKBackup.TempDir, "restore-*.tsv.gz")
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyObject(io.TeeReader(r, f), tb); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("verify object %s failed: %s", tb.Object, err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return f, nil
+}
+
+func (b *CKBackup) ckHTTPAddr() (string, error) {
+	if len(b.cfg.CKDB.ActualAddrs) == 0 {
+		return "", fmt.Errorf("no clickhouse address")
+	}
+	host, _, err := net.SplitHostPort(b.cfg.CKDB.ActualAddrs[0])
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(b.cfg.CKBackup.CKHTTPPort)), nil
+}
+
+// insertTSV executes the insert query through the HTTP interface of clickhouse with the TabSeparated rows as request body
+func (b *CKBackup) insertTSV(query string, body io.Reader) error {
+	addr, err := b.ckHTTPAddr()
+	if err != nil {
+		return err
+	}
+	u := url.URL{Scheme: "http", Host: addr, Path: "/", RawQuery: url.Values{"query": {query}}.Encode()}
+	req, err := http.NewRequestWithContext(b.ctx, http.MethodPost, u.String(), body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-ClickHouse-User", b.cfg.CKDBAuth.Username)
+	req.Header.Set("X-ClickHouse-Key", b.cfg.CKDBAuth.Password)
+	resp, err := ckHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, CK_ERROR_BODY_LENGTH))
+		return fmt.Errorf("insert through %s failed, status: %s, response: %s", addr, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	_, err = io.Copy(ioutil.Discard, resp.Body)
+	return err
+}
+
+func (b *CKBackup) verify(id string, job *Job) (string, error) {
+	m, err := loadManifest(b.storage, id)
+	if err != nil {
+		return "", err
+	}
+	if m.Status != STATUS_SUCCESS {
+		return "", fmt.Errorf("backup '%s' status is '%s'", m.ID, m.Status)
+	}
+	result := &strings.Builder{}
+	failed := 0
+	for i, tb := range m.Tables {
+		job.SetProgress(fmt.Sprintf("verifying %d/%d object %s", i+1, len(m.Tables), tb.Object))
+		if err := b.verifyTable(tb); err != nil {
+			failed++
+			fmt.Fprintf(result, "%s: %s\n", tb.Object, err)
+		}
+	}
+	fmt.Fprintf(result, "verified %d objects, %d failed", len(m.Tables), failed)
+	if failed > 0 {
+		return result.String(), fmt.Errorf("%d objects of backup '%s' are corrupted", failed, id)
+	}
+	return result.String(), nil
+}
+
+type countingWriter struct {
+	h    hash.Hash
+	size int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.size += int64(len(p))
+	return w.h.Write(p)
+}
+
+// verifyTable checks the size, sha256 and rows of the object
+func (b *CKBackup) verifyTable(tb *TableBackup) error {
+	r, err := b.storage.Get(tb.Object)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return verifyObject(r, tb)
+}
+
+func verifyObject(r io.Reader, tb *TableBackup) error {
+	w := &countingWriter{h: sha256.New()}
+	gz, err := gzip.NewReader(io.TeeReader(r, w))
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	var rows uint64
+	reader := bufio.NewReaderSize(gz, READ_BUFFER_SIZE)
+	for {
+		line, err := reader.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			rows++
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+	// consume the data after gzip stream if any
+	if _, err := io.Copy(ioutil.Discard, io.TeeReader(r, w)); err != nil {
+		return err
+	}
+	if w.size != tb.Size {
+		return fmt.Errorf("size %d mismatch, expected %d", w.size, tb.Size)
+	}
+	if sum := hex.EncodeToString(w.h.Sum(nil)); sum != tb.SHA256 {
+		return fmt.Errorf("sha256 %s mismatch, expected %s", sum, tb.SHA256)
+	}
+	if rows != tb.Rows {
+		return fmt.Errorf("rows %d mismatch, expected %d", rows, tb.Rows)
+	}
+	return nil
+}
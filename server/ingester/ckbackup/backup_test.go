@@ -0,0 +1,202 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ckbackup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deepflowio/deepflow/server/ingester/config"
+)
+
+func TestParseOrgDatabase(t *testing.T) {
+	tests := []struct {
+		name     string
+		orgID    uint16
+		database string
+	}{
+		{"flow_log", 1, "flow_log"},
+		{"0002_flow_log", 2, "flow_log"},
+		{"0000_flow_log", 1, "0000_flow_log"},
+		{"abcd_flow_log", 1, "abcd_flow_log"},
+	}
+	for _, tt := range tests {
+		orgID, database := parseOrgDatabase(tt.name)
+		if orgID != tt.orgID || database != tt.database {
+			t.Errorf("parseOrgDatabase(%s) = %d, %s, want %d, %s", tt.name, orgID, database, tt.orgID, tt.database)
+		}
+	}
+}
+
+func TestTableFilter(t *testing.T) {
+	filter := &tableFilter{orgIDs: []uint16{2}, databases: []string{"flow_log"}, tablesContain: "l7"}
+	tests := []struct {
+		table *dbTable
+		want  bool
+	}{
+		{&dbTable{2, "flow_log", "l7_flow_log"}, true},
+		{&dbTable{1, "flow_log", "l7_flow_log"}, false},
+		{&dbTable{2, "flow_metrics", "application.1m"}, false},
+		{&dbTable{2, "flow_log", "l4_flow_log"}, false},
+	}
+	for _, tt := range tests {
+		if got := filter.match(tt.table); got != tt.want {
+			t.Errorf("match(%+v) = %v, want %v", tt.table, got, tt.want)
+		}
+	}
+	if !(&tableFilter{}).match(&dbTable{3, "profile", "in_process"}) {
+		t.Error("empty filter should match all tables")
+	}
+}
+
+func TestMapColumns(t *testing.T) {
+	backup := []Column{{"time", "DateTime"}, {"vtap_id", "UInt16"}, {"dropped", "String"}, {"packet_batch", "String"}}
+	current := []Column{{"time", "DateTime"}, {"agent_id", "UInt16"}, {"packet_batch", "String"}, {"added", "UInt8"}}
+	inserts, selects, skips := mapColumns("flow_log", "l4_packet", backup, current)
+	if got := strings.Join(inserts, ","); got != "`time`,`agent_id`,`packet_batch`" {
+		t.Errorf("inserts = %s", got)
+	}
+	if got := strings.Join(selects, ","); got != "`time`,`vtap_id`,`packet_batch`" {
+		t.Errorf("selects = %s", got)
+	}
+	if got := strings.Join(skips, ","); got != "dropped" {
+		t.Errorf("skips = %s", got)
+	}
+}
+
+func TestQuoteString(t *testing.T) {
+	if got := quoteString("a'b\\c\x00\td"); got != `'a\'b\\c\0`+"\t"+`d'` {
+		t.Errorf("quoteString = %s", got)
+	}
+	if got := structure([]Column{{"a", "UInt32"}, {"b", "Enum8('x' = 1)"}}); got != "`a` UInt32, `b` Enum8('x' = 1)" {
+		t.Errorf("structure = %s", got)
+	}
+}
+
+func gzipRows(t *testing.T, rows string) ([]byte, string) {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	gz.Write([]byte(rows))
+	gz.Close()
+	sum := sha256.Sum256(buf.Bytes())
+	return buf.Bytes(), hex.EncodeToString(sum[:])
+}
+
+func TestVerifyTable(t *testing.T) {
+	storage := &LocalStorage{path: t.TempDir()}
+	b := &CKBackup{cfg: &config.Config{}, storage: storage}
+	content, sum := gzipRows(t, "1\ta\n2\tb\\nc\n3\tc\n")
+	f := writeTempFile(t, string(content))
+	defer f.Close()
+	tb := &TableBackup{Object: objectName("b1", 1, "flow_log", "l7_flow_log"), Rows: 3, Size: int64(len(content)), SHA256: sum}
+	if err := storage.Put(tb.Object, f); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.verifyTable(tb); err != nil {
+		t.Errorf("verify failed: %s", err)
+	}
+	b.cfg.CKBackup.TempDir = t.TempDir()
+	downloaded, err := b.download(tb)
+	if err != nil {
+		t.Fatalf("download failed: %s", err)
+	}
+	got, _ := ioutil.ReadAll(downloaded)
+	downloaded.Close()
+	if !bytes.Equal(got, content) {
+		t.Errorf("downloaded content mismatch")
+	}
+	tb.Rows = 4
+	if err := b.verifyTable(tb); err == nil || !strings.Contains(err.Error(), "rows") {
+		t.Errorf("rows mismatch is not detected: %v", err)
+	}
+	tb.Rows, tb.SHA256 = 3, strings.Repeat("0", 64)
+	if err := b.verifyTable(tb); err == nil || !strings.Contains(err.Error(), "sha256") {
+		t.Errorf("sha256 mismatch is not detected: %v", err)
+	}
+}
+
+func TestInsertTSV(t *testing.T) {
+	var query, user, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query, user = r.URL.Query().Get("query"), r.Header.Get("X-ClickHouse-User")
+		data, _ := ioutil.ReadAll(r.Body)
+		body = string(data)
+		if strings.Contains(query, "bad") {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Code: 27. DB::Exception: Cannot parse input"))
+		}
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+	port, _ := strconv.Atoi(u.Port())
+	cfg := &config.Config{CKBackup: config.CKBackup{CKHTTPPort: port}}
+	cfg.CKDB.ActualAddrs = []string{net.JoinHostPort(u.Hostname(), "9000")}
+	cfg.CKDBAuth.Username = "default"
+	b := &CKBackup{cfg: cfg, ctx: context.Background()}
+
+	q := "INSERT INTO `flow_log`.`l7_flow_log` (`time`) SELECT `time` FROM input('`time` DateTime') FORMAT TabSeparated"
+	if err := b.insertTSV(q, strings.NewReader("1\n2\n")); err != nil {
+		t.Fatal(err)
+	}
+	if query != q || user != "default" || body != "1\n2\n" {
+		t.Errorf("query = %s, user = %s, body = %q", query, user, body)
+	}
+	if err := b.insertTSV("bad", strings.NewReader("")); err == nil || !strings.Contains(err.Error(), "Cannot parse input") {
+		t.Errorf("insert error is not returned: %v", err)
+	}
+}
+
+func TestManifest(t *testing.T) {
+	storage := &LocalStorage{path: t.TempDir()}
+	now := time.Now().Truncate(time.Second)
+	for i, id := range []string{"b2", "b1"} {
+		m := &Manifest{ID: id, Scheduled: true, Status: STATUS_SUCCESS, CreatedAt: now.Add(time.Duration(i) * -time.Hour),
+			Tables: []*TableBackup{{Rows: 10, Size: 100}, {Rows: 5, Size: 50}}}
+		if err := saveManifest(storage, t.TempDir(), m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manifests, err := listManifests(storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifests) != 2 || manifests[0].ID != "b1" || manifests[1].ID != "b2" {
+		t.Fatalf("manifests are not sorted by creation time: %+v", manifests)
+	}
+	if manifests[0].Rows() != 15 || manifests[0].Size() != 150 {
+		t.Errorf("rows = %d, size = %d", manifests[0].Rows(), manifests[0].Size())
+	}
+
+	b := &CKBackup{cfg: &config.Config{CKBackup: config.CKBackup{KeepCount: 1}}, storage: storage}
+	b.cleanScheduledBackups()
+	if manifests, _ = listManifests(storage); len(manifests) != 1 || manifests[0].ID != "b2" {
+		t.Errorf("the oldest scheduled backup should be deleted: %+v", manifests)
+	}
+}
@@ -0,0 +1,424 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ckbackup
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	logging "github.com/op/go-logging"
+
+	"github.com/deepflowio/deepflow/server/ingester/config"
+	"github.com/deepflowio/deepflow/server/ingester/ingesterctl"
+	"github.com/deepflowio/deepflow/server/libs/debug"
+)
+
+var log = logging.MustGetLogger("ckbackup")
+
+const (
+	JOB_BACKUP  = "backup"
+	JOB_RESTORE = "restore"
+	JOB_VERIFY  = "verify"
+
+	// wait for the delayed data before backing up a scheduled time range
+	SCHEDULE_DELAY          = 10 * time.Minute
+	SCHEDULE_CHECK_INTERVAL = time.Minute
+	BACKUP_ID_FORMAT        = "20060102T150405Z"
+)
+
+// ingesterctl operates
+const (
+	CMD_LIST uint16 = iota
+	CMD_START
+	CMD_RESTORE
+	CMD_VERIFY
+	CMD_STATUS
+)
+
+type Job struct {
+	sync.Mutex
+	Type       string
+	BackupID   string
+	Status     string
+	Progress   string
+	Result     string
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+func (j *Job) SetProgress(progress string) {
+	j.Lock()
+	j.Progress = progress
+	j.Unlock()
+	log.Info(progress)
+}
+
+func (j *Job) String() string {
+	j.Lock()
+	defer j.Unlock()
+	s := fmt.Sprintf("job: %s\nbackup: %s\nstatus: %s\nstarted at: %s\n", j.Type, j.BackupID, j.Status, j.StartedAt.Format(time.RFC3339))
+	if j.Status == STATUS_RUNNING {
+		return s + "progress: " + j.Progress
+	}
+	return s + fmt.Sprintf("finished at: %s\nresult:\n%s", j.FinishedAt.Format(time.RFC3339), j.Result)
+}
+
+type CKBackup struct {
+	cfg     *config.Config
+	storage Storage
+
+	mutex sync.Mutex
+	job   *Job // running or the last finished job
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewCKBackup(cfg *config.Config) (*CKBackup, error) {
+	storage, err := NewStorage(&cfg.CKBackup.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(cfg.CKBackup.TempDir, 0755); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &CKBackup{
+		cfg:     cfg,
+		storage: storage,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	debug.ServerRegisterSimple(ingesterctl.CMD_CK_BACKUP, b)
+	return b, nil
+}
+
+func (b *CKBackup) connect() (*sql.DB, error) {
+	if len(b.cfg.CKDB.ActualAddrs) == 0 {
+		return nil, fmt.Errorf("no clickhouse address")
+	}
+	// backup and restore may take a long time, so no execution time limit
+	connect, err := sql.Open("clickhouse", fmt.Sprintf("//%s@%s?dial_timeout=10s&max_execution_time=0",
+		url.UserPassword(b.cfg.CKDBAuth.Username, b.cfg.CKDBAuth.Password), b.cfg.CKDB.ActualAddrs[0]))
+	if err != nil {
+		return nil, err
+	}
+	if err := connect.Ping(); err != nil {
+		connect.Close()
+		return nil, fmt.Errorf("ck connection ping (%s) failed: %s", b.cfg.CKDB.ActualAddrs[0], err)
+	}
+	return connect, nil
+}
+
+// newJob returns error if there is a running job, only one job can run at the same time
+func (b *CKBackup) newJob(jobType, backupID string) (*Job, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.job != nil {
+		b.job.Lock()
+		running := b.job.Status == STATUS_RUNNING
+		b.job.Unlock()
+		if running {
+			return nil, fmt.Errorf("%s job of backup '%s' is running", b.job.Type, b.job.BackupID)
+		}
+	}
+	b.job = &Job{Type: jobType, BackupID: backupID, Status: STATUS_RUNNING, StartedAt: time.Now()}
+	return b.job, nil
+}
+
+func (b *CKBackup) runJob(job *Job, run func() (string, error)) {
+	log.Infof("start %s job of backup '%s'", job.Type, job.BackupID)
+	result, err := run()
+	job.Lock()
+	job.Status, job.Result, job.FinishedAt = STATUS_SUCCESS, result, time.Now()
+	if err != nil {
+		job.Status = STATUS_FAILED
+		job.Result = strings.TrimSuffix(result+"\n"+err.Error(), "\n")
+		log.Errorf("%s job of backup '%s' failed: %s", job.Type, job.BackupID, err)
+	} else {
+		log.Infof("%s job of backup '%s' finished", job.Type, job.BackupID)
+	}
+	job.Unlock()
+}
+
+func newBackupID(t time.Time) string {
+	return t.UTC().Format(BACKUP_ID_FORMAT)
+}
+
+func (b *CKBackup) defaultFilter() tableFilter {
+	return tableFilter{
+		orgIDs:        b.cfg.CKBackup.OrgIDs,
+		databases:     b.cfg.CKBackup.Databases,
+		tablesContain: b.cfg.CKBackup.TablesContain,
+	}
+}
+
+func (b *CKBackup) Start() {
+	if b.cfg.CKBackup.ScheduleInterval > 0 {
+		go b.schedule()
+	}
+}
+
+func (b *CKBackup) Close() error {
+	b.cancel()
+	return nil
+}
+
+// lastScheduledEnd returns the end time of the last successful scheduled backup
+func (b *CKBackup) lastScheduledEnd() time.Time {
+	var last time.Time
+	manifests, err := listManifests(b.storage)
+	if err != nil {
+		log.Warningf("list backups in %s failed: %s", b.storage, err)
+	}
+	for _, m := range manifests {
+		if m.Scheduled && m.Status == STATUS_SUCCESS && m.EndTime.After(last) {
+			last = m.EndTime
+		}
+	}
+	return last
+}
+
+// schedule backs up data of every 'schedule-interval' hours, the time range missed by restarting is included in the next backup
+func (b *CKBackup) schedule() {
+	interval := time.Duration(b.cfg.CKBackup.ScheduleInterval) * time.Hour
+	lastEnd := b.lastScheduledEnd()
+	if lastEnd.IsZero() {
+		lastEnd = time.Now().Add(-SCHEDULE_DELAY).Truncate(interval).Add(-interval)
+	}
+	ticker := time.NewTicker(SCHEDULE_CHECK_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		end := time.Now().Add(-SCHEDULE_DELAY).Truncate(interval)
+		if !end.After(lastEnd) {
+			continue
+		}
+		job, err := b.newJob(JOB_BACKUP, newBackupID(time.Now()))
+		if err != nil {
+			log.Infof("scheduled backup is delayed: %s", err)
+			continue
+		}
+		opts := &backupOptions{filter: b.defaultFilter(), startTime: lastEnd, endTime: end, scheduled: true}
+		var m *Manifest
+		b.runJob(job, func() (string, error) {
+			m, err = b.backup(opts, job)
+			return backupResult(m), err
+		})
+		if err == nil {
+			lastEnd = end
+			b.cleanScheduledBackups()
+		}
+	}
+}
+
+// cleanScheduledBackups keeps the latest 'keep-count' scheduled backups
+func (b *CKBackup) cleanScheduledBackups() {
+	if b.cfg.CKBackup.KeepCount <= 0 {
+		return
+	}
+	manifests, err := listManifests(b.storage)
+	if err != nil {
+		log.Warningf("list backups in %s failed: %s", b.storage, err)
+		return
+	}
+	scheduled := []*Manifest{}
+	for _, m := range manifests {
+		if m.Scheduled && m.Status != STATUS_RUNNING {
+			scheduled = append(scheduled, m)
+		}
+	}
+	for i := 0; i < len(scheduled)-b.cfg.CKBackup.KeepCount; i++ {
+		log.Infof("delete expired scheduled backup '%s'", scheduled[i].ID)
+		if err := deleteBackup(b.storage, scheduled[i].ID); err != nil {
+			log.Warningf("delete backup '%s' failed: %s", scheduled[i].ID, err)
+		}
+	}
+}
+
+func backupResult(m *Manifest) string {
+	if m == nil {
+		return ""
+	}
+	return fmt.Sprintf("backed up %d tables, rows %d, size %d", len(m.Tables), m.Rows(), m.Size())
+}
+
+func parseTime(s string) (time.Time, error) {
+	if ts, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(ts, 0), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// parseFilter parses filter from the arguments sent by ingesterctl, configured values are used if not set
+func (b *CKBackup) parseFilter(args url.Values) (tableFilter, error) {
+	filter := b.defaultFilter()
+	if orgs, ok := args["org-id"]; ok {
+		filter.orgIDs = nil
+		for _, org := range orgs {
+			orgID, err := strconv.Atoi(org)
+			if err != nil {
+				return filter, fmt.Errorf("invalid org-id '%s'", org)
+			}
+			filter.orgIDs = append(filter.orgIDs, uint16(orgID))
+		}
+	}
+	if databases, ok := args["database"]; ok {
+		filter.databases = databases
+	}
+	if _, ok := args["tables-contain"]; ok {
+		filter.tablesContain = args.Get("tables-contain")
+	}
+	return filter, nil
+}
+
+func (b *CKBackup) startBackup(args url.Values) (string, error) {
+	filter, err := b.parseFilter(args)
+	if err != nil {
+		return "", err
+	}
+	opts := &backupOptions{filter: filter, endTime: time.Now()}
+	if end := args.Get("end"); end != "" {
+		if opts.endTime, err = parseTime(end); err != nil {
+			return "", fmt.Errorf("invalid end time '%s': %s", end, err)
+		}
+	}
+	if start := args.Get("start"); start != "" {
+		if opts.startTime, err = parseTime(start); err != nil {
+			return "", fmt.Errorf("invalid start time '%s': %s", start, err)
+		}
+	} else {
+		opts.startTime = opts.endTime.Add(-time.Hour)
+	}
+	if !opts.endTime.After(opts.startTime) {
+		return "", fmt.Errorf("end time should be after start time")
+	}
+
+	job, err := b.newJob(JOB_BACKUP, newBackupID(time.Now()))
+	if err != nil {
+		return "", err
+	}
+	go b.runJob(job, func() (string, error) {
+		m, err := b.backup(opts, job)
+		return backupResult(m), err
+	})
+	return fmt.Sprintf("backup '%s' started, time range [%s, %s)", job.BackupID,
+		opts.startTime.Format(time.RFC3339), opts.endTime.Format(time.RFC3339)), nil
+}
+
+func (b *CKBackup) startRestore(args url.Values) (string, error) {
+	id := args.Get("id")
+	if id == "" {
+		return "", fmt.Errorf("backup id is required")
+	}
+	// restore all tables in the backup by default
+	filter := tableFilter{}
+	if len(args["org-id"]) > 0 || len(args["database"]) > 0 || args.Get("tables-contain") != "" {
+		var err error
+		if filter, err = b.parseFilter(args); err != nil {
+			return "", err
+		}
+	}
+	opts := &restoreOptions{id: id, filter: filter}
+	job, err := b.newJob(JOB_RESTORE, id)
+	if err != nil {
+		return "", err
+	}
+	go b.runJob(job, func() (string, error) {
+		return b.restore(opts, job)
+	})
+	return fmt.Sprintf("restore of backup '%s' started", id), nil
+}
+
+func (b *CKBackup) startVerify(args url.Values) (string, error) {
+	id := args.Get("id")
+	if id == "" {
+		return "", fmt.Errorf("backup id is required")
+	}
+	job, err := b.newJob(JOB_VERIFY, id)
+	if err != nil {
+		return "", err
+	}
+	go b.runJob(job, func() (string, error) {
+		return b.verify(id, job)
+	})
+	return fmt.Sprintf("verify of backup '%s' started", id), nil
+}
+
+func (b *CKBackup) list() (string, error) {
+	manifests, err := listManifests(b.storage)
+	if err != nil {
+		return "", err
+	}
+	sb := &strings.Builder{}
+	w := tabwriter.NewWriter(sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSCHEDULED\tSTATUS\tSTART_TIME\tEND_TIME\tTABLES\tROWS\tSIZE\tCK_VERSION")
+	for _, m := range manifests {
+		fmt.Fprintf(w, "%s\t%v\t%s\t%s\t%s\t%d\t%d\t%d\t%s\n", m.ID, m.Scheduled, m.Status,
+			m.StartTime.Format(time.RFC3339), m.EndTime.Format(time.RFC3339), len(m.Tables), m.Rows(), m.Size(), m.CKVersion)
+	}
+	w.Flush()
+	return fmt.Sprintf("storage: %s\n%s", b.storage, sb.String()), nil
+}
+
+func (b *CKBackup) status() string {
+	b.mutex.Lock()
+	job := b.job
+	b.mutex.Unlock()
+	if job == nil {
+		return "no job"
+	}
+	return job.String()
+}
+
+func (b *CKBackup) HandleSimpleCommand(operate uint16, arg string) string {
+	args, err := url.ParseQuery(arg)
+	if err != nil {
+		return err.Error()
+	}
+	var result string
+	switch operate {
+	case CMD_LIST:
+		result, err = b.list()
+	case CMD_START:
+		result, err = b.startBackup(args)
+	case CMD_RESTORE:
+		result, err = b.startRestore(args)
+	case CMD_VERIFY:
+		result, err = b.startVerify(args)
+	case CMD_STATUS:
+		result = b.status()
+	default:
+		err = fmt.Errorf("unknown operate %d", operate)
+	}
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	return result
+}
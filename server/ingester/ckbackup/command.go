@@ -0,0 +1,132 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ckbackup
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deepflowio/deepflow/server/ingester/ingesterctl"
+	"github.com/deepflowio/deepflow/server/libs/debug"
+)
+
+type filterFlags struct {
+	orgIDs        []uint
+	databases     []string
+	tablesContain string
+}
+
+func (f *filterFlags) register(cmd *cobra.Command) {
+	cmd.Flags().UintSliceVar(&f.orgIDs, "org-id", nil, "organization ids, default is 'ck-backup.org-ids' in server.yaml")
+	cmd.Flags().StringSliceVar(&f.databases, "database", nil, "database names without organization prefix, default is 'ck-backup.databases' in server.yaml")
+	cmd.Flags().StringVar(&f.tablesContain, "tables-contain", "", "only tables whose name contains the string")
+}
+
+func (f *filterFlags) encode(cmd *cobra.Command, args url.Values) {
+	for _, orgID := range f.orgIDs {
+		args.Add("org-id", strconv.Itoa(int(orgID)))
+	}
+	for _, db := range f.databases {
+		args.Add("database", db)
+	}
+	if cmd.Flags().Changed("tables-contain") {
+		args.Set("tables-contain", f.tablesContain)
+	}
+}
+
+func runCommand(operate uint16, args url.Values) {
+	result, err := debug.CommmandGetResult(ingesterctl.CMD_CK_BACKUP, int(operate), args.Encode())
+	if err != nil {
+		fmt.Println("Get result failed", err)
+		return
+	}
+	fmt.Println(result)
+}
+
+func RegisterClientCommand() *cobra.Command {
+	backupCmd := &cobra.Command{
+		Use:   "ck-backup",
+		Short: "backup and restore clickhouse data",
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "list backups in the backup storage",
+		Run: func(cmd *cobra.Command, args []string) {
+			runCommand(CMD_LIST, url.Values{})
+		},
+	}
+
+	var startFilter filterFlags
+	var startTime, endTime string
+	startCmd := &cobra.Command{
+		Use:   "start",
+		Short: "start a backup of data in the time range",
+		Example: `  ingester ck-backup start --start 2024-07-01T00:00:00Z --end 2024-07-02T00:00:00Z
+  ingester ck-backup start --org-id 1,2 --database flow_log --tables-contain l7 --start 1719792000`,
+		Run: func(cmd *cobra.Command, args []string) {
+			values := url.Values{}
+			startFilter.encode(cmd, values)
+			if startTime != "" {
+				values.Set("start", startTime)
+			}
+			if endTime != "" {
+				values.Set("end", endTime)
+			}
+			runCommand(CMD_START, values)
+		},
+	}
+	startFilter.register(startCmd)
+	startCmd.Flags().StringVar(&startTime, "start", "", "start time, unix seconds or RFC3339, default is 1 hour before end time")
+	startCmd.Flags().StringVar(&endTime, "end", "", "end time (excluded), unix seconds or RFC3339, default is now")
+
+	var restoreFilter filterFlags
+	restoreCmd := &cobra.Command{
+		Use:   "restore <backup-id>",
+		Short: "restore a backup into the current schema version, all tables in the backup are restored by default",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			values := url.Values{"id": {args[0]}}
+			restoreFilter.encode(cmd, values)
+			runCommand(CMD_RESTORE, values)
+		},
+	}
+	restoreFilter.register(restoreCmd)
+
+	verifyCmd := &cobra.Command{
+		Use:   "verify <backup-id>",
+		Short: "verify size, checksum and rows of all objects in a backup",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			runCommand(CMD_VERIFY, url.Values{"id": {args[0]}})
+		},
+	}
+
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "show the running or the last finished job",
+		Run: func(cmd *cobra.Command, args []string) {
+			runCommand(CMD_STATUS, url.Values{})
+		},
+	}
+
+	backupCmd.AddCommand(listCmd, startCmd, restoreCmd, verifyCmd, statusCmd)
+	return backupCmd
+}
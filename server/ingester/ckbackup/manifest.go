@@ -0,0 +1,161 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ckbackup
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	MANIFEST_NAME = "manifest.json"
+
+	STATUS_RUNNING = "running"
+	STATUS_SUCCESS = "success"
+	STATUS_FAILED  = "failed"
+)
+
+type Column struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type TableBackup struct {
+	OrgID    uint16   `json:"org_id"`
+	Database string   `json:"database"` // database name without organization prefix
+	Table    string   `json:"table"`
+	Version  string   `json:"version"` // table version when backing up, see common.CK_VERSION
+	Columns  []Column `json:"columns"`
+	Object   string   `json:"object"` // gzipped TabSeparated rows
+	Rows     uint64   `json:"rows"`
+	Size     int64    `json:"size"`
+	SHA256   string   `json:"sha256"`
+}
+
+type Manifest struct {
+	ID         string         `json:"id"`
+	Scheduled  bool           `json:"scheduled"`
+	CKVersion  string         `json:"ck_version"`
+	StartTime  time.Time      `json:"start_time"` // time range of backed up data, [start_time, end_time)
+	EndTime    time.Time      `json:"end_time"`
+	CreatedAt  time.Time      `json:"created_at"`
+	FinishedAt time.Time      `json:"finished_at"`
+	Status     string         `json:"status"`
+	Error      string         `json:"error,omitempty"`
+	Tables     []*TableBackup `json:"tables"`
+}
+
+func (m *Manifest) Rows() uint64 {
+	var rows uint64
+	for _, t := range m.Tables {
+		rows += t.Rows
+	}
+	return rows
+}
+
+func (m *Manifest) Size() int64 {
+	var size int64
+	for _, t := range m.Tables {
+		size += t.Size
+	}
+	return size
+}
+
+func objectName(id string, orgID uint16, database, table string) string {
+	return path.Join(id, fmt.Sprintf("%04d", orgID), database, table+".tsv.gz")
+}
+
+func manifestName(id string) string {
+	return path.Join(id, MANIFEST_NAME)
+}
+
+func saveManifest(storage Storage, tempDir string, m *Manifest) error {
+	content, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(tempDir, "manifest-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err := f.Write(content); err != nil {
+		return err
+	}
+	return storage.Put(manifestName(m.ID), f)
+}
+
+func loadManifest(storage Storage, id string) (*Manifest, error) {
+	r, err := storage.Get(manifestName(id))
+	if err != nil {
+		return nil, fmt.Errorf("get manifest of backup '%s' failed: %s", id, err)
+	}
+	defer r.Close()
+	m := &Manifest{}
+	if err := json.NewDecoder(r).Decode(m); err != nil {
+		return nil, fmt.Errorf("decode manifest of backup '%s' failed: %s", id, err)
+	}
+	return m, nil
+}
+
+// listManifests returns manifests of all backups in storage, sorted by creation time
+func listManifests(storage Storage) ([]*Manifest, error) {
+	names, err := storage.List("")
+	if err != nil {
+		return nil, err
+	}
+	manifests := []*Manifest{}
+	for _, name := range names {
+		if !strings.HasSuffix(name, "/"+MANIFEST_NAME) {
+			continue
+		}
+		m, err := loadManifest(storage, strings.TrimSuffix(name, "/"+MANIFEST_NAME))
+		if err != nil {
+			log.Warning(err)
+			continue
+		}
+		manifests = append(manifests, m)
+	}
+	sort.Slice(manifests, func(i, j int) bool {
+		return manifests[i].CreatedAt.Before(manifests[j].CreatedAt)
+	})
+	return manifests, nil
+}
+
+// deleteBackup removes the manifest first, so that an interrupted deletion leaves no visible backup
+func deleteBackup(storage Storage, id string) error {
+	if err := storage.Delete(manifestName(id)); err != nil {
+		return err
+	}
+	names, err := storage.List(id + "/")
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := storage.Delete(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ckbackup
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+
+	"github.com/deepflowio/deepflow/server/ingester/config"
+)
+
+const (
+	S3_DEFAULT_REGION    = "us-east-1"
+	S3_UNSIGNED_PAYLOAD  = "UNSIGNED-PAYLOAD"
+	S3_REQUEST_TIMEOUT   = time.Hour
+	S3_LIST_MAX_KEYS     = "1000"
+	S3_ERROR_BODY_LENGTH = 512
+	// objects larger than S3_PART_SIZE are uploaded in parts, since a single PUT is limited to 5GB
+	S3_PART_SIZE      = 128 << 20
+	S3_MAX_PART_COUNT = 10000
+)
+
+// S3Storage is a minimal client of S3-compatible object stores (AWS S3, MinIO, Ceph RGW ...),
+// which uses path-style requests signed with signature v4.
+type S3Storage struct {
+	endpoint    string
+	bucket      string
+	region      string
+	prefix      string
+	credentials aws.Credentials
+	signer      *v4.Signer
+	client      *http.Client
+	partSize    int64
+}
+
+func NewS3Storage(cfg *config.CKBackupS3, prefix string) *S3Storage {
+	endpoint := strings.TrimSuffix(cfg.Endpoint, "/")
+	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		endpoint = "https://" + endpoint
+	}
+	region := cfg.Region
+	if region == "" {
+		region = S3_DEFAULT_REGION
+	}
+	return &S3Storage{
+		endpoint:    endpoint,
+		bucket:      cfg.Bucket,
+		region:      region,
+		prefix:      strings.Trim(prefix, "/"),
+		credentials: aws.Credentials{AccessKeyID: cfg.AccessKey, SecretAccessKey: cfg.SecretKey},
+		signer:      v4.NewSigner(),
+		client:      &http.Client{Timeout: S3_REQUEST_TIMEOUT},
+		partSize:    S3_PART_SIZE,
+	}
+}
+
+func (s *S3Storage) key(name string) string {
+	if s.prefix == "" {
+		return name
+	}
+	return s.prefix + "/" + name
+}
+
+func (s *S3Storage) do(method, key string, query url.Values, body io.Reader, contentLength int64) (*http.Response, error) {
+	u, err := url.Parse(s.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = path.Join(u.Path, "/", s.bucket, key)
+	u.RawQuery = strings.Replace(query.Encode(), "+", "%20", -1)
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = contentLength
+	}
+	req.Header.Set("X-Amz-Content-Sha256", S3_UNSIGNED_PAYLOAD)
+	if err := s.signer.SignHTTP(context.Background(), s.credentials, req, S3_UNSIGNED_PAYLOAD, "s3", s.region, time.Now()); err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, S3_ERROR_BODY_LENGTH))
+		return nil, fmt.Errorf("s3 %s %s failed, status: %s, response: %s", method, key, resp.Status, msg)
+	}
+	return resp, nil
+}
+
+func (s *S3Storage) Put(name string, file *os.File) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() > s.partSize {
+		return s.putMultipart(s.key(name), file, info.Size())
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	resp, err := s.do(http.MethodPut, s.key(name), nil, file, info.Size())
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+type initiateMultipartUploadResult struct {
+	UploadId string `xml:"UploadId"`
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+// partSizeOf returns the part size which keeps the part count within the limit of S3
+func (s *S3Storage) partSizeOf(size int64) int64 {
+	partSize := s.partSize
+	if minSize := (size + S3_MAX_PART_COUNT - 1) / S3_MAX_PART_COUNT; minSize > partSize {
+		partSize = minSize
+	}
+	return partSize
+}
+
+func (s *S3Storage) putMultipart(key string, file *os.File, size int64) error {
+	resp, err := s.do(http.MethodPost, key, url.Values{"uploads": {""}}, nil, 0)
+	if err != nil {
+		return err
+	}
+	initiated := initiateMultipartUploadResult{}
+	err = xml.NewDecoder(resp.Body).Decode(&initiated)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("decode s3 initiate multipart upload result failed: %s", err)
+	}
+	if err := s.uploadParts(key, initiated.UploadId, file, size); err != nil {
+		// abort the upload to free the storage of the uploaded parts
+		if resp, abortErr := s.do(http.MethodDelete, key, url.Values{"uploadId": {initiated.UploadId}}, nil, 0); abortErr != nil {
+			log.Warningf("abort s3 multipart upload of %s failed: %s", key, abortErr)
+		} else {
+			resp.Body.Close()
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *S3Storage) uploadParts(key, uploadID string, file *os.File, size int64) error {
+	partSize := s.partSizeOf(size)
+	complete := completeMultipartUpload{}
+	for offset, number := int64(0), 1; offset < size; offset, number = offset+partSize, number+1 {
+		length := partSize
+		if offset+length > size {
+			length = size - offset
+		}
+		query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+		resp, err := s.do(http.MethodPut, key, query, io.NewSectionReader(file, offset, length), length)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		complete.Parts = append(complete.Parts, completedPart{PartNumber: number, ETag: resp.Header.Get("ETag")})
+	}
+	body, err := xml.Marshal(&complete)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(http.MethodPost, key, url.Values{"uploadId": {uploadID}}, bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// the request of completing may fail after the response status 200 is sent
+	msg, err := ioutil.ReadAll(io.LimitReader(resp.Body, S3_ERROR_BODY_LENGTH))
+	if err != nil {
+		return err
+	}
+	if bytes.Contains(msg, []byte("<Error>")) {
+		return fmt.Errorf("s3 complete multipart upload of %s failed, response: %s", key, msg)
+	}
+	return nil
+}
+
+func (s *S3Storage) Get(name string) (io.ReadCloser, error) {
+	resp, err := s.do(http.MethodGet, s.key(name), nil, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *S3Storage) List(prefix string) ([]string, error) {
+	names := []string{}
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {s.key(prefix)}, "max-keys": {S3_LIST_MAX_KEYS}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do(http.MethodGet, "", query, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		result := listBucketResult{}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("decode s3 list result failed: %s", err)
+		}
+		for _, c := range result.Contents {
+			name := c.Key
+			if s.prefix != "" {
+				name = strings.TrimPrefix(name, s.prefix+"/")
+			}
+			names = append(names, name)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return names, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (s *S3Storage) Delete(name string) error {
+	resp, err := s.do(http.MethodDelete, s.key(name), nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3Storage) String() string {
+	return fmt.Sprintf("s3:%s/%s/%s", s.endpoint, s.bucket, s.prefix)
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ckbackup
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/deepflowio/deepflow/server/ingester/config"
+)
+
+// Storage saves backup objects, object names are '/' separated relative paths
+type Storage interface {
+	Put(name string, file *os.File) error
+	Get(name string) (io.ReadCloser, error)
+	// List returns the names of all objects starting with prefix
+	List(prefix string) ([]string, error)
+	Delete(name string) error
+	String() string
+}
+
+func NewStorage(cfg *config.CKBackupStorage) (Storage, error) {
+	switch cfg.Type {
+	case config.CKBackupStorageLocal:
+		return &LocalStorage{path: filepath.Join(cfg.LocalPath, cfg.Prefix)}, nil
+	case config.CKBackupStorageS3:
+		return NewS3Storage(&cfg.S3, cfg.Prefix), nil
+	default:
+		return nil, fmt.Errorf("unsupported backup storage type '%s'", cfg.Type)
+	}
+}
+
+type LocalStorage struct {
+	path string
+}
+
+func (s *LocalStorage) Put(name string, file *os.File) error {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	path := filepath.Join(s.path, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// write to a temporary file first, so that a partial object is never visible
+	tmpPath := path + ".tmp"
+	dst, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, file); err != nil {
+		dst.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func (s *LocalStorage) Get(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(s.path, filepath.FromSlash(name)))
+}
+
+func (s *LocalStorage) List(prefix string) ([]string, error) {
+	names := []string{}
+	err := filepath.Walk(s.path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || strings.HasSuffix(path, ".tmp") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.path, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+		return nil
+	})
+	sort.Strings(names)
+	return names, err
+}
+
+func (s *LocalStorage) Delete(name string) error {
+	path := filepath.Join(s.path, filepath.FromSlash(name))
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	// remove the empty directories of the backup
+	for dir := filepath.Dir(path); dir != s.path && strings.HasPrefix(dir, s.path); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+func (s *LocalStorage) String() string {
+	return "local:" + s.path
+}
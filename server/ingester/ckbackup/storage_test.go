@@ -0,0 +1,200 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ckbackup
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/deepflowio/deepflow/server/ingester/config"
+)
+
+// fakeS3 is an in-memory S3-compatible server supporting the requests used by S3Storage
+type fakeS3 struct {
+	sync.Mutex
+	bucket  string
+	objects map[string][]byte
+	uploads map[string]map[int][]byte
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=ak/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	key := strings.TrimPrefix(r.URL.Path, "/"+s.bucket)
+	key = strings.TrimPrefix(key, "/")
+	query := r.URL.Query()
+	uploadID := query.Get("uploadId")
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		uploadID = fmt.Sprintf("upload-%d", len(s.uploads))
+		s.uploads[uploadID] = make(map[int][]byte)
+		xml.NewEncoder(w).Encode(struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			UploadId string   `xml:"UploadId"`
+		}{UploadId: uploadID})
+	case r.Method == http.MethodPut && uploadID != "":
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		body, _ := ioutil.ReadAll(r.Body)
+		s.uploads[uploadID][number] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"etag-%d"`, number))
+	case r.Method == http.MethodPost && uploadID != "":
+		complete := completeMultipartUpload{}
+		xml.NewDecoder(r.Body).Decode(&complete)
+		body := []byte{}
+		for i, part := range complete.Parts {
+			if part.PartNumber != i+1 || part.ETag != fmt.Sprintf(`"etag-%d"`, i+1) {
+				fmt.Fprintf(w, "<Error><Code>InvalidPart</Code></Error>")
+				return
+			}
+			body = append(body, s.uploads[uploadID][part.PartNumber]...)
+		}
+		delete(s.uploads, uploadID)
+		s.objects[key] = body
+	case r.Method == http.MethodDelete && uploadID != "":
+		delete(s.uploads, uploadID)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		body, _ := ioutil.ReadAll(r.Body)
+		s.objects[key] = body
+	case r.Method == http.MethodGet && key == "":
+		result := struct {
+			XMLName  xml.Name `xml:"ListBucketResult"`
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+		}{}
+		keys := []string{}
+		for k := range s.objects {
+			if strings.HasPrefix(k, r.URL.Query().Get("prefix")) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			result.Contents = append(result.Contents, struct {
+				Key string `xml:"Key"`
+			}{k})
+		}
+		xml.NewEncoder(w).Encode(result)
+	case r.Method == http.MethodGet:
+		body, ok := s.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(body)
+	case r.Method == http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func writeTempFile(t *testing.T, content string) *os.File {
+	f, err := ioutil.TempFile(t.TempDir(), "object")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(content)
+	return f
+}
+
+func testStorage(t *testing.T, storage Storage) {
+	for _, name := range []string{"b1/manifest.json", "b1/0001/flow_log/l7_flow_log.tsv.gz", "b2/manifest.json"} {
+		f := writeTempFile(t, "content of "+name)
+		if err := storage.Put(name, f); err != nil {
+			t.Fatalf("put %s failed: %s", name, err)
+		}
+		f.Close()
+	}
+
+	names, err := storage.List("b1/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, ",") != "b1/0001/flow_log/l7_flow_log.tsv.gz,b1/manifest.json" {
+		t.Errorf("list b1/ = %v", names)
+	}
+
+	r, err := storage.Get("b2/manifest.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := ioutil.ReadAll(r)
+	r.Close()
+	if !bytes.Equal(content, []byte("content of b2/manifest.json")) {
+		t.Errorf("get b2/manifest.json = %s", content)
+	}
+
+	if err := deleteBackup(storage, "b1"); err != nil {
+		t.Fatal(err)
+	}
+	if names, _ = storage.List(""); strings.Join(names, ",") != "b2/manifest.json" {
+		t.Errorf("list after delete = %v", names)
+	}
+}
+
+func TestLocalStorage(t *testing.T) {
+	testStorage(t, &LocalStorage{path: t.TempDir()})
+}
+
+func TestS3Storage(t *testing.T) {
+	server := httptest.NewServer(&fakeS3{bucket: "backup", objects: make(map[string][]byte), uploads: make(map[string]map[int][]byte)})
+	defer server.Close()
+
+	storage := NewS3Storage(&config.CKBackupS3{
+		Endpoint:  server.URL,
+		Bucket:    "backup",
+		AccessKey: "ak",
+		SecretKey: "sk",
+	}, "deepflow")
+	testStorage(t, storage)
+
+	// objects larger than the part size are uploaded in 5 parts
+	storage.partSize = 4
+	content := "0123456789abcdefghij"
+	f := writeTempFile(t, content)
+	defer f.Close()
+	if err := storage.Put("b3/manifest.json", f); err != nil {
+		t.Fatalf("multipart put failed: %s", err)
+	}
+	r, err := storage.Get("b3/manifest.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := ioutil.ReadAll(r)
+	r.Close()
+	if string(got) != content {
+		t.Errorf("get multipart object = %s", got)
+	}
+	if got := storage.partSizeOf(S3_MAX_PART_COUNT*4 + 1); got != 5 {
+		t.Errorf("part size = %d, want 5", got)
+	}
+}
@@ -0,0 +1,215 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ckbackup
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/deepflowio/deepflow/server/ingester/ckissu"
+	"github.com/deepflowio/deepflow/server/libs/ckdb"
+)
+
+const (
+	TIME_COLUMN        = "time"
+	DISTRIBUTED_ENGINE = "Distributed"
+	BYCONITY_ENGINE    = "Cnch"
+)
+
+type dbTable struct {
+	orgID    uint16
+	database string // without organization prefix
+	table    string
+}
+
+func (t *dbTable) fullDatabase() string {
+	return ckdb.OrgDatabasePrefix(t.orgID) + t.database
+}
+
+// parseOrgDatabase splits database name like '0002_flow_log' into organization id and database name
+func parseOrgDatabase(name string) (uint16, string) {
+	if len(name) > ckdb.ORG_ID_PREFIX_LEN && name[ckdb.ORG_ID_LEN] == '_' {
+		if orgID, err := strconv.Atoi(name[:ckdb.ORG_ID_LEN]); err == nil && ckdb.IsValidOrgID(uint16(orgID)) {
+			return uint16(orgID), name[ckdb.ORG_ID_PREFIX_LEN:]
+		}
+	}
+	return ckdb.DEFAULT_ORG_ID, name
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsOrgID(list []uint16, orgID uint16) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, v := range list {
+		if v == orgID {
+			return true
+		}
+	}
+	return false
+}
+
+type tableFilter struct {
+	orgIDs        []uint16
+	databases     []string
+	tablesContain string
+}
+
+func (f *tableFilter) match(t *dbTable) bool {
+	return containsOrgID(f.orgIDs, t.orgID) &&
+		(len(f.databases) == 0 || containsString(f.databases, t.database)) &&
+		strings.Contains(t.table, f.tablesContain)
+}
+
+// isDataTable returns whether the table is the one which data is written to and queried from,
+// in ClickHouse it is the distributed table, local tables are covered by it.
+func isDataTable(ckdbType, engine string) bool {
+	if ckdbType == ckdb.CKDBTypeByconity {
+		return strings.HasPrefix(engine, BYCONITY_ENGINE)
+	}
+	return engine == DISTRIBUTED_ENGINE
+}
+
+func getTables(conn *sql.DB, ckdbType string, filter *tableFilter) ([]*dbTable, error) {
+	rows, err := conn.Query("SELECT database,name,engine FROM system.tables ORDER BY database,name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tables := []*dbTable{}
+	for rows.Next() {
+		var database, name, engine string
+		if err := rows.Scan(&database, &name, &engine); err != nil {
+			return nil, err
+		}
+		if !isDataTable(ckdbType, engine) {
+			continue
+		}
+		orgID, db := parseOrgDatabase(database)
+		t := &dbTable{orgID: orgID, database: db, table: name}
+		if filter.match(t) {
+			tables = append(tables, t)
+		}
+	}
+	return tables, rows.Err()
+}
+
+// getColumns returns the columns which can be inserted, and the table version recorded in the comment of column 'time'
+func getColumns(conn *sql.DB, database, table string) ([]Column, string, error) {
+	rows, err := conn.Query(fmt.Sprintf("SELECT name,type,comment FROM system.columns WHERE database='%s' AND table='%s' AND default_kind NOT IN ('MATERIALIZED','ALIAS','EPHEMERAL') ORDER BY position",
+		database, table))
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+	columns := []Column{}
+	version := ""
+	for rows.Next() {
+		var name, columnType, comment string
+		if err := rows.Scan(&name, &columnType, &comment); err != nil {
+			return nil, "", err
+		}
+		columns = append(columns, Column{Name: name, Type: columnType})
+		if name == TIME_COLUMN {
+			version = comment
+		}
+	}
+	return columns, version, rows.Err()
+}
+
+func hasColumn(columns []Column, name string) bool {
+	for _, c := range columns {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// currentTableName returns the table name in current schema version, which may be renamed by issu
+func currentTableName(database, table string) (string, string) {
+	for _, r := range ckissu.AllTableRenames {
+		if r.OldDb != database {
+			continue
+		}
+		for i, old := range r.OldTables {
+			if old == table && i < len(r.NewTables) {
+				return r.NewDb, r.NewTables[i]
+			}
+		}
+	}
+	return database, table
+}
+
+// mapColumns maps the backed up columns to the columns of current schema version. columns renamed by issu
+// are mapped to the new names, columns dropped are skipped, columns added are filled with default values.
+func mapColumns(database, table string, backup, current []Column) (inserts, selects, skips []string) {
+	renames := make(map[string]string)
+	for _, versionRenames := range ckissu.AllColumnRenames {
+		for _, r := range versionRenames {
+			if r.Db == database && (r.Table == table || r.Table == table+ckdb.LOCAL_SUBFFIX) {
+				renames[r.OldColumnName] = r.NewColumnName
+			}
+		}
+	}
+	inserted := make(map[string]bool)
+	for _, c := range backup {
+		name := c.Name
+		if !hasColumn(current, name) {
+			if newName, ok := renames[name]; ok && hasColumn(current, newName) {
+				name = newName
+			}
+		}
+		if !hasColumn(current, name) || inserted[name] {
+			skips = append(skips, c.Name)
+			continue
+		}
+		inserted[name] = true
+		inserts = append(inserts, quoteIdentifier(name))
+		selects = append(selects, quoteIdentifier(c.Name))
+	}
+	return
+}
+
+func quoteIdentifier(name string) string {
+	return "`" + strings.Replace(name, "`", "\\`", -1) + "`"
+}
+
+var stringLiteralReplacer = strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\x00", `\0`)
+
+func quoteString(s string) string {
+	return "'" + stringLiteralReplacer.Replace(s) + "'"
+}
+
+// structure returns the table structure argument of table functions, like "`a` UInt32, `b` String"
+func structure(columns []Column) string {
+	defs := make([]string, 0, len(columns))
+	for _, c := range columns {
+		defs = append(defs, quoteIdentifier(c.Name)+" "+c.Type)
+	}
+	return strings.Join(defs, ", ")
+}
@@ -159,6 +159,7 @@ type ColumnDatasourceAdds struct {
 	DefaultValue                                 string
 	IsMetrics                                    bool
 	IsSummable                                   bool
+	AggrFunc                                     string // if set, overrides the aggregation function of the datasource
 }
 
 type ColumnDatasourceAdd struct {
@@ -169,6 +170,7 @@ type ColumnDatasourceAdd struct {
 	DefaultValue                                 string
 	IsMetrics                                    bool
 	IsSummable                                   bool
+	AggrFunc                                     string
 }
 
 func getTables(connect *sql.DB, db, tablePrefix string) ([]string, error) {
@@ -339,6 +341,9 @@ func (i *Issu) addColumnDatasource(index int, d *DatasourceInfo, isMapTable, isA
 		} else if add.IsMetrics {
 			aggrFunc = d.unsummable
 		}
+		if add.IsMetrics && add.AggrFunc != "" {
+			aggrFunc = add.AggrFunc
+		}
 		addColumn := &ColumnAdd{
 			Db:           d.db,
 			Table:        aggTable,
@@ -1121,6 +1126,7 @@ func getColumnDatasourceAdds(columnDatasourceAddss []*ColumnDatasourceAdds) []*C
 				DefaultValue:     columnAdds.DefaultValue,
 				IsMetrics:        columnAdds.IsMetrics,
 				IsSummable:       columnAdds.IsSummable,
+				AggrFunc:         columnAdds.AggrFunc,
 			})
 		}
 	}
@@ -17,6 +17,7 @@
 package ckissu
 
 import (
+	"github.com/deepflowio/deepflow/server/ingester/datasource"
 	"github.com/deepflowio/deepflow/server/libs/ckdb"
 )
 
@@ -27,7 +28,7 @@ var AllColumnRenames = [][]*ColumnRename{getColumnRenames(ColumnRename65)}
 var AllColumnDrops = [][]*ColumnDrop{getColumnDrops(nil)}
 var AllTableModTTLs = [][]*TableModTTL{}
 var AllTableRenames = []*TableRename{}
-var AllDatasourceAdds = [][]*ColumnDatasourceAdd{getColumnDatasourceAdds(ColumnDatasourceAdd65)}
+var AllDatasourceAdds = [][]*ColumnDatasourceAdd{getColumnDatasourceAdds(ColumnDatasourceAdd65), getColumnDatasourceAdds(ColumnDatasourceAdd66)}
 
 var ColumnAdd64 = []*ColumnAdds{
 	{
@@ -375,4 +376,57 @@ var ColumnAdd66 = []*ColumnAdds{
 		ColumnNames: []string{"auto_instance_type", "auto_service_type"},
 		ColumnType:  ckdb.UInt8,
 	},
+	{
+		Dbs: []string{"flow_metrics"},
+		Tables: []string{"application.1m", "application.1m_local", "application_map.1m", "application_map.1m_local",
+			"application.1s", "application.1s_local", "application_map.1s", "application_map.1s_local"},
+		ColumnNames: []string{"rrt_buckets"},
+		ColumnType:  ckdb.MapUInt8UInt64,
+	},
+	{
+		Dbs:         []string{"flow_metrics"},
+		Tables:      []string{"network_map.1m", "network_map.1m_local", "network_map.1s", "network_map.1s_local"},
+		ColumnNames: []string{"as_org_0", "as_org_1", "city_0", "city_1", "country_0", "country_1", "subdivision_0", "subdivision_1"},
+		ColumnType:  ckdb.LowCardinalityString,
+	},
+	{
+		Dbs:         []string{"flow_metrics"},
+		Tables:      []string{"network_map.1m", "network_map.1m_local", "network_map.1s", "network_map.1s_local"},
+		ColumnNames: []string{"asn_0", "asn_1"},
+		ColumnType:  ckdb.UInt32,
+	},
+	{
+		Dbs:         []string{"flow_log"},
+		Tables:      []string{"l4_flow_log", "l4_flow_log_local", "l7_flow_log", "l7_flow_log_local"},
+		ColumnNames: []string{"country_0", "country_1", "subdivision_0", "subdivision_1", "city_0", "city_1", "as_org_0", "as_org_1"},
+		ColumnType:  ckdb.LowCardinalityString,
+	},
+	{
+		Dbs:         []string{"flow_log"},
+		Tables:      []string{"l4_flow_log", "l4_flow_log_local", "l7_flow_log", "l7_flow_log_local"},
+		ColumnNames: []string{"asn_0", "asn_1"},
+		ColumnType:  ckdb.UInt32,
+	},
+}
+
+var ColumnDatasourceAdd66 = []*ColumnDatasourceAdds{
+	{
+		ColumnNames:    []string{"rrt_buckets"},
+		OldColumnNames: []string{""},
+		ColumnTypes:    []ckdb.ColumnType{ckdb.MapUInt8UInt64},
+		OnlyMapTable:   false,
+		OnlyAppTable:   true,
+		IsMetrics:      true,
+		AggrFunc:       datasource.SKETCH_AGGR_FUNC,
+	},
+	{
+		ColumnNames:    []string{"as_org_0", "as_org_1", "asn_0", "asn_1", "city_0", "city_1", "country_0", "country_1", "subdivision_0", "subdivision_1"},
+		OldColumnNames: []string{"", "", "", "", "", "", "", "", "", ""},
+		ColumnTypes: []ckdb.ColumnType{ckdb.LowCardinalityString, ckdb.LowCardinalityString, ckdb.UInt32, ckdb.UInt32,
+			ckdb.LowCardinalityString, ckdb.LowCardinalityString, ckdb.LowCardinalityString, ckdb.LowCardinalityString,
+			ckdb.LowCardinalityString, ckdb.LowCardinalityString},
+		OnlyMapTable:     true,
+		OnlyAppTable:     false,
+		OnlyNetworkTable: true,
+	},
 }
@@ -50,6 +50,12 @@ const (
 	DefaultByconityService          = "byconity-server"
 	DefaultCKDBServicePort          = 9000
 	DefaultListenPort               = 20033
+	DefaultTLSListenPort            = 20034
+	DefaultTLSReloadInterval        = 60 // s
+	DefaultGeoIPReloadInterval      = 60 // s
+	DefaultOTLPGRPCPort             = 4317
+	DefaultOTLPHTTPPort             = 4318
+	DefaultOTLPMaxMessageSize       = 16 // MB
 	DefaultGrpcBufferSize           = 41943040
 	DefaultServiceLabelerLruCap     = 1 << 22
 	DefaultCKDBEndpointTCPPortName  = "tcp-port"
@@ -63,6 +69,12 @@ const (
 	EnvRunningMode                  = "DEEPFLOW_SERVER_RUNNING_MODE"
 	RunningModeStandalone           = "STANDALONE"
 	DefaultByconityStoragePolicy    = "cnch_default_s3"
+	CKBackupStorageLocal            = "local"
+	CKBackupStorageS3               = "s3"
+	DefaultCKBackupLocalPath        = "/var/lib/deepflow/ck-backup"
+	DefaultCKBackupTempDir          = "/tmp/deepflow-ck-backup"
+	DefaultCKBackupRestoreBatchSize = 16 // MB
+	DefaultCKBackupCKHTTPPort       = 8123
 )
 
 type DatabaseTable struct {
@@ -84,6 +96,33 @@ type CKDiskMonitor struct {
 	PriorityDrops    []DatabaseTable `yaml:"priority-drops"`
 }
 
+type CKBackupS3 struct {
+	Endpoint  string `yaml:"endpoint"`
+	Region    string `yaml:"region"`
+	Bucket    string `yaml:"bucket"`
+	AccessKey string `yaml:"access-key"`
+	SecretKey string `yaml:"secret-key"`
+}
+
+type CKBackupStorage struct {
+	Type      string     `yaml:"type"` // local or s3
+	LocalPath string     `yaml:"local-path"`
+	Prefix    string     `yaml:"prefix"`
+	S3        CKBackupS3 `yaml:"s3"`
+}
+
+type CKBackup struct {
+	ScheduleInterval int             `yaml:"schedule-interval"` // hour, 0 means scheduled backup is disabled
+	Databases        []string        `yaml:"databases,flow"`
+	TablesContain    string          `yaml:"tables-contain"`
+	OrgIDs           []uint16        `yaml:"org-ids,flow"`       // empty means all organizations
+	KeepCount        int             `yaml:"keep-count"`         // number of scheduled backups to keep, 0 means keep all
+	RestoreBatchSize int             `yaml:"restore-batch-size"` // MB
+	CKHTTPPort       int             `yaml:"ck-http-port"`       // data is restored through the HTTP interface of clickhouse
+	TempDir          string          `yaml:"temp-dir"`
+	Storage          CKBackupStorage `yaml:"storage"`
+}
+
 type Disk struct {
 	Type string `yaml:"type"`
 	Name string `yaml:"name"`
@@ -118,6 +157,31 @@ type CKWriterConfig struct {
 	FlushTimeout int `yaml:"flush-timeout"`
 }
 
+type TLSReceiver struct {
+	Enabled               bool   `yaml:"enabled"`
+	ListenPort            uint16 `yaml:"listen-port"`
+	CertFile              string `yaml:"cert-file"`
+	KeyFile               string `yaml:"key-file"`
+	ClientCAFile          string `yaml:"client-ca-file"`
+	ReloadInterval        int    `yaml:"reload-interval"` // s
+	IdentityCheckDisabled bool   `yaml:"identity-check-disabled"`
+	PlaintextTCPDisabled  bool   `yaml:"plaintext-tcp-disabled"`
+}
+
+type OTLPReceiver struct {
+	Enabled        bool  `yaml:"enabled"`
+	GRPCPort       int   `yaml:"grpc-port"`        // 0 means not listening on OTLP/gRPC
+	HTTPPort       int   `yaml:"http-port"`        // 0 means not listening on OTLP/HTTP
+	MaxMessageSize int   `yaml:"max-message-size"` // MB
+	OrgID          int   `yaml:"org-id"`
+	L3EpcID        int32 `yaml:"l3-epc-id"`
+}
+
+type GeoIP struct {
+	MMDBFiles      []string `yaml:"mmdb-files"`
+	ReloadInterval int      `yaml:"reload-interval"` // s
+}
+
 type CKDB struct {
 	External            bool   `yaml:"external"`
 	Type                string `yaml:"type"`
@@ -135,6 +199,9 @@ type Config struct {
 	IsRunningModeStandalone  bool
 	StorageDisabled          bool            `yaml:"storage-disabled"`
 	ListenPort               uint16          `yaml:"listen-port"`
+	TLSReceiver              TLSReceiver     `yaml:"tls-receiver"`
+	OTLPReceiver             OTLPReceiver    `yaml:"otlp-receiver"`
+	GeoIP                    GeoIP           `yaml:"geoip"`
 	CKDB                     CKDB            `yaml:"ckdb"`
 	ControllerIPs            []string        `yaml:"controller-ips,flow"`
 	ControllerPort           uint16          `yaml:"controller-port"`
@@ -145,6 +212,7 @@ type Config struct {
 	TCPReaderBuffer          int             `yaml:"tcp-reader-buffer"`
 	CKDiskMonitor            CKDiskMonitor   `yaml:"ck-disk-monitor"`
 	ColdStorage              CKDBColdStorage `yaml:"ckdb-cold-storage"`
+	CKBackup                 CKBackup        `yaml:"ck-backup"`
 	ckdbColdStorages         map[string]*ckdb.ColdStorage
 	NodeIP                   string `yaml:"node-ip"`
 	GrpcBufferSize           int    `yaml:"grpc-buffer-size"`
@@ -346,6 +414,51 @@ func (c *Config) Validate() error {
 		c.CKDB.TimeZone = ckdb.DF_TIMEZONE
 	}
 
+	if c.CKBackup.Storage.Type != CKBackupStorageLocal && c.CKBackup.Storage.Type != CKBackupStorageS3 {
+		log.Errorf("the setting of 'ingester.ck-backup.storage.type' (%s) is invalid, should be '%s' or '%s'", c.CKBackup.Storage.Type, CKBackupStorageLocal, CKBackupStorageS3)
+		sleepAndExit()
+	}
+	if c.CKBackup.Storage.Type == CKBackupStorageS3 && (c.CKBackup.Storage.S3.Endpoint == "" || c.CKBackup.Storage.S3.Bucket == "") {
+		log.Error("'ingester.ck-backup.storage.s3.endpoint' and 'ingester.ck-backup.storage.s3.bucket' should be set when backup storage type is s3")
+		sleepAndExit()
+	}
+	if c.CKBackup.RestoreBatchSize <= 0 {
+		c.CKBackup.RestoreBatchSize = DefaultCKBackupRestoreBatchSize
+	}
+	if c.CKBackup.CKHTTPPort <= 0 {
+		c.CKBackup.CKHTTPPort = DefaultCKBackupCKHTTPPort
+	}
+	if c.CKBackup.TempDir == "" {
+		c.CKBackup.TempDir = DefaultCKBackupTempDir
+	}
+
+	if c.TLSReceiver.Enabled {
+		if c.TLSReceiver.CertFile == "" || c.TLSReceiver.KeyFile == "" {
+			log.Error("'ingester.tls-receiver.cert-file' and 'ingester.tls-receiver.key-file' should be set when tls receiver is enabled")
+			sleepAndExit()
+		}
+		if c.TLSReceiver.ReloadInterval <= 0 {
+			c.TLSReceiver.ReloadInterval = DefaultTLSReloadInterval
+		}
+	}
+
+	if c.GeoIP.ReloadInterval <= 0 {
+		c.GeoIP.ReloadInterval = DefaultGeoIPReloadInterval
+	}
+
+	if c.OTLPReceiver.Enabled {
+		if c.OTLPReceiver.GRPCPort <= 0 && c.OTLPReceiver.HTTPPort <= 0 {
+			log.Error("at least one of 'ingester.otlp-receiver.grpc-port' and 'ingester.otlp-receiver.http-port' should be set when otlp receiver is enabled")
+			sleepAndExit()
+		}
+		if c.OTLPReceiver.MaxMessageSize <= 0 {
+			c.OTLPReceiver.MaxMessageSize = DefaultOTLPMaxMessageSize
+		}
+		if c.OTLPReceiver.OrgID <= 0 {
+			c.OTLPReceiver.OrgID = ckdb.DEFAULT_ORG_ID
+		}
+	}
+
 	var watcher *Watcher
 	var err error
 	for retryTimes := 0; ; retryTimes++ {
@@ -488,7 +601,17 @@ func Load(path string) *Config {
 				},
 				[]DatabaseTable{{"flow_log", ""}, {"flow_metrics", "1s_local"}, {"profile", ""}, {"application_log", ""}},
 			},
+			CKBackup: CKBackup{
+				Databases:        []string{"flow_log", "flow_metrics", "event", "profile", "application_log", "prometheus", "ext_metrics"},
+				RestoreBatchSize: DefaultCKBackupRestoreBatchSize,
+				CKHTTPPort:       DefaultCKBackupCKHTTPPort,
+				TempDir:          DefaultCKBackupTempDir,
+				Storage:          CKBackupStorage{Type: CKBackupStorageLocal, LocalPath: DefaultCKBackupLocalPath},
+			},
 			ListenPort:               DefaultListenPort,
+			TLSReceiver:              TLSReceiver{ListenPort: DefaultTLSListenPort, ReloadInterval: DefaultTLSReloadInterval},
+			GeoIP:                    GeoIP{ReloadInterval: DefaultGeoIPReloadInterval},
+			OTLPReceiver:             OTLPReceiver{GRPCPort: DefaultOTLPGRPCPort, HTTPPort: DefaultOTLPHTTPPort, MaxMessageSize: DefaultOTLPMaxMessageSize, OrgID: ckdb.DEFAULT_ORG_ID},
 			GrpcBufferSize:           DefaultGrpcBufferSize,
 			ServiceLabelerLruCap:     DefaultServiceLabelerLruCap,
 			StatsInterval:            DefaultStatsInterval,
@@ -145,7 +145,17 @@ var unsummableFieldsMap = map[string]struct{}{
 	"rrt_count":        {},
 }
 
+// flow_metrics 的时延分布字段, 无论数据源配置何种聚合方式, 都需要按桶累加才能计算分位数
+var sketchFieldsMap = map[string]struct{}{
+	"rrt_buckets": {},
+}
+
+const SKETCH_AGGR_FUNC = "sumMap"
+
 func getColumnString(column *ckdb.Column, aggrSummable, aggrUnsummable string, t TableType) string {
+	if _, isSketch := sketchFieldsMap[column.Name]; isSketch {
+		aggrSummable, aggrUnsummable = SKETCH_AGGR_FUNC, SKETCH_AGGR_FUNC
+	}
 	_, isUnsummable := unsummableFieldsMap[column.Name]
 	isMaxMinAggr := (aggrUnsummable == aggrStrings[MAX]) || (aggrUnsummable == aggrStrings[MIN])
 	_, isUnsummableMax := unsummableMaxFieldsMap[column.Name]
@@ -112,128 +112,150 @@ func writeK8sLabels(sb *strings.Builder, keyName, valueName string, k8sLabels ut
 	sb.WriteString(valuesBuilder.String())
 }
 
-func EncodeToJson(item EncodeItem, dataSourceId int, exporterCfg *config.ExporterCfg, uTags0, uTags1 *utag.UniversalTags, k8sLabels0, k8sLabels1 utag.Labels) string {
-	var sb = &strings.Builder{}
-	sb.WriteString("{\"datasource\":\"")
-	sb.WriteString(config.DataSourceID(dataSourceId).String())
-	sb.WriteString(`"`)
+type FieldType uint8
 
-	if dataSourceId >= int(config.MAX_DATASOURCE_ID) {
-		log.Errorf("export datasource wrong: datasourceid %d ", dataSourceId)
-		return ""
+const (
+	FIELD_STRING FieldType = iota
+	FIELD_FLOAT64
+	FIELD_STRING_SLICE
+	FIELD_FLOAT64_SLICE
+)
+
+// FieldValue is the value of an exported field after to_string, universal tag and enum translation
+type FieldValue struct {
+	Type         FieldType
+	Value        interface{} // the original value get from item
+	String       string      // also the formatted value of FIELD_FLOAT64, keeps the precision of uint64/int64
+	Float64      float64
+	StringSlice  []string
+	Float64Slice []float64
+}
+
+// ExportFieldName returns the exported key of the field, '_id' is removed if universal tag is translated to name
+func ExportFieldName(structTags *config.StructTags, isMapItem bool, exporterCfg *config.ExporterCfg) string {
+	keyStr := structTags.Name
+	if isMapItem && structTags.MapName != "" {
+		keyStr = structTags.MapName
 	}
+	if structTags.ToStringFuncName == "" && structTags.UniversalTagMapID > 0 && !exporterCfg.UniversalTagTranslateToNameDisabled {
+		// skip '_id'
+		if pos := strings.Index(keyStr, "_id"); pos != -1 {
+			keyStr = (keyStr[:pos]) + keyStr[pos+3:] // 3 is  length of '_id'
+		}
+	}
+	return keyStr
+}
 
+// walkExportFields calls fn with each exported field of item, the index is the position of the field in
+// 'ExportFieldStructTags', empty tags and metrics are skipped according to exporterCfg
+func walkExportFields(item EncodeItem, dataSourceId int, exporterCfg *config.ExporterCfg, uTags0, uTags1 *utag.UniversalTags, fn func(index int, key string, v *FieldValue)) {
 	isMapItem := config.DataSourceID(dataSourceId).IsMap()
-	var isString, isFloat64, isStringSlice, isFloat64Slice bool
-	var keyStr, valueStr string
-	var valueFloat64 float64
-	var stringSlice []string
-	var float64Slice []float64
-	for _, structTags := range exporterCfg.ExportFieldStructTags[dataSourceId] {
-		isString, isFloat64, isStringSlice, isFloat64Slice = false, false, false, false
+	v := &FieldValue{}
+	for i := range exporterCfg.ExportFieldStructTags[dataSourceId] {
+		structTags := &exporterCfg.ExportFieldStructTags[dataSourceId][i]
 		value := item.GetFieldValueByOffsetAndKind(structTags.Offset, structTags.DataKind, structTags.DataType)
 		if utils.IsNil(value) {
 			log.Debugf("%s value is nil", structTags.FieldName)
 			continue
 		}
-		if isMapItem && structTags.MapName != "" {
-			keyStr = structTags.MapName
+		*v = FieldValue{Value: value}
+		if s, ok := value.(string); ok {
+			v.Type, v.String = FIELD_STRING, s
+		} else if s, ok := value.([]string); ok {
+			v.Type, v.StringSlice = FIELD_STRING_SLICE, s
+		} else if f, ok := value.([]float64); ok {
+			v.Type, v.Float64Slice = FIELD_FLOAT64_SLICE, f
+		} else if f, fStr, ok := utils.ConvertToFloat64(value); ok {
+			v.Type, v.Float64, v.String = FIELD_FLOAT64, f, fStr
 		} else {
-			keyStr = structTags.Name
-		}
-		if v, ok := value.(string); ok {
-			isString = true
-			valueStr = v
-		} else if v, ok := value.([]string); ok {
-			isStringSlice = true
-			stringSlice = v
-		} else if v, ok := value.([]float64); ok {
-			isFloat64Slice = true
-			float64Slice = v
-		} else if v, vStr, ok := utils.ConvertToFloat64(value); ok {
-			isFloat64 = true
-			valueFloat64 = v
-			valueStr = vStr
-		} else {
-			isString = true
-			valueStr = fmt.Sprintf("%v", value)
+			v.Type, v.String = FIELD_STRING, fmt.Sprintf("%v", value)
 		}
 
 		if structTags.ToStringFuncName != "" {
 			ret := structTags.ToStringFunc.Call([]reflect.Value{reflect.ValueOf(value)})
-			valueStr = ret[0].String()
-			isString = true
+			v.Type, v.String = FIELD_STRING, ret[0].String()
 		} else if structTags.UniversalTagMapID > 0 && !exporterCfg.UniversalTagTranslateToNameDisabled {
-			// skip '_id'
-			if pos := strings.Index(keyStr, "_id"); pos != -1 {
-				keyStr = (keyStr[:pos]) + keyStr[pos+3:] // 3 is  length of '_id'
-			}
 			if strings.HasSuffix(structTags.Name, "_1") {
-				valueStr = uTags1.GetTagValue(structTags.UniversalTagMapID)
+				v.String = uTags1.GetTagValue(structTags.UniversalTagMapID)
 			} else {
-				valueStr = uTags0.GetTagValue(structTags.UniversalTagMapID)
+				v.String = uTags0.GetTagValue(structTags.UniversalTagMapID)
 			}
-			isString = true
+			v.Type = FIELD_STRING
 		} else if structTags.EnumFile != "" && !exporterCfg.EnumTranslateToNameDisabled {
-			if isString {
-				valueStr = structTags.EnumStringMap[valueStr]
-			} else if isFloat64 {
-				valueStr = structTags.EnumIntMap[int(valueFloat64)]
+			if v.Type == FIELD_STRING {
+				v.String = structTags.EnumStringMap[v.String]
+			} else if v.Type == FIELD_FLOAT64 {
+				v.String = structTags.EnumIntMap[int(v.Float64)]
 			}
-			isString = true
+			v.Type = FIELD_STRING
 		}
 
 		// not export empty tags
 		if !exporterCfg.ExportEmptyTag &&
 			(structTags.CategoryBit&config.TAG) != 0 &&
-			((isString && valueStr == "") ||
-				(isStringSlice && len(stringSlice) == 0)) {
+			((v.Type == FIELD_STRING && v.String == "") ||
+				(v.Type == FIELD_STRING_SLICE && len(v.StringSlice) == 0)) {
 			continue
 		}
 
 		// not export empty metrics
 		if exporterCfg.ExportEmptyMetricsDisabled &&
 			(structTags.CategoryBit&config.METRICS) != 0 &&
-			((isString && valueStr == "") || (isFloat64 && valueFloat64 == 0) ||
-				(isFloat64Slice && len(float64Slice) == 0)) {
+			((v.Type == FIELD_STRING && v.String == "") || (v.Type == FIELD_FLOAT64 && v.Float64 == 0) ||
+				(v.Type == FIELD_FLOAT64_SLICE && len(v.Float64Slice) == 0)) {
 			continue
 		}
 
+		fn(i, ExportFieldName(structTags, isMapItem, exporterCfg), v)
+	}
+}
+
+func EncodeToJson(item EncodeItem, dataSourceId int, exporterCfg *config.ExporterCfg, uTags0, uTags1 *utag.UniversalTags, k8sLabels0, k8sLabels1 utag.Labels) string {
+	var sb = &strings.Builder{}
+	sb.WriteString("{\"datasource\":\"")
+	sb.WriteString(config.DataSourceID(dataSourceId).String())
+	sb.WriteString(`"`)
+
+	if dataSourceId >= int(config.MAX_DATASOURCE_ID) {
+		log.Errorf("export datasource wrong: datasourceid %d ", dataSourceId)
+		return ""
+	}
+
+	walkExportFields(item, dataSourceId, exporterCfg, uTags0, uTags1, func(_ int, keyStr string, v *FieldValue) {
 		sb.WriteString(`,"`)
 		sb.WriteString(keyStr)
 		sb.WriteString(`":`)
-		if isString {
+		switch v.Type {
+		case FIELD_STRING:
 			sb.WriteString(`"`)
-			sb.WriteString(utils.EscapeJSONString(valueStr))
+			sb.WriteString(utils.EscapeJSONString(v.String))
 			sb.WriteString(`"`)
-		} else if isStringSlice {
+		case FIELD_STRING_SLICE:
 			sb.WriteString("[")
-			for i, v := range stringSlice {
+			for i, s := range v.StringSlice {
 				if i != 0 {
 					sb.WriteString(`,`)
 				}
 				sb.WriteString(`"`)
-				sb.WriteString(v)
+				sb.WriteString(s)
 				sb.WriteString(`"`)
 			}
 			sb.WriteString("]")
-		} else if isFloat64Slice {
+		case FIELD_FLOAT64_SLICE:
 			sb.WriteString("[")
-			for i, v := range float64Slice {
+			for i, f := range v.Float64Slice {
 				if i != 0 {
 					sb.WriteString(`,`)
 				}
-				sb.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+				sb.WriteString(strconv.FormatFloat(f, 'f', -1, 64))
 			}
 			sb.WriteString("]")
-		} else if isFloat64 {
-			sb.WriteString(valueStr)
-		} else {
-			log.Warningf("unreachable")
+		case FIELD_FLOAT64:
+			sb.WriteString(v.String)
 		}
-	}
+	})
 
-	if isMapItem {
+	if config.DataSourceID(dataSourceId).IsMap() {
 		writeK8sLabels(sb, "k8s_label_names_0", "k8s_label_values_0", k8sLabels0)
 		writeK8sLabels(sb, "k8s_label_names_1", "k8s_label_values_1", k8sLabels1)
 	} else {
@@ -247,3 +269,12 @@ func EncodeToJson(item EncodeItem, dataSourceId int, exporterCfg *config.Exporte
 	sb.WriteString("}")
 	return sb.String()
 }
+
+// EncodeToJsonOrRecord encodes item to a json string, or to a *Record if the exporter uses a schema
+// based encoding such as protobuf or avro
+func EncodeToJsonOrRecord(item EncodeItem, dataSourceId int, exporterCfg *config.ExporterCfg, uTags0, uTags1 *utag.UniversalTags, k8sLabels0, k8sLabels1 utag.Labels) interface{} {
+	if exporterCfg.ExportEncoding == config.ENCODING_JSON {
+		return EncodeToJson(item, dataSourceId, exporterCfg, uTags0, uTags1, k8sLabels0, k8sLabels1)
+	}
+	return EncodeToRecord(item, dataSourceId, exporterCfg, uTags0, uTags1, k8sLabels0, k8sLabels1)
+}
@@ -0,0 +1,211 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package common
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/deepflowio/deepflow/server/ingester/exporters/config"
+	utag "github.com/deepflowio/deepflow/server/ingester/exporters/universal_tag"
+	"github.com/deepflowio/deepflow/server/libs/utils"
+)
+
+type SchemaFieldType uint8
+
+const (
+	SCHEMA_STRING SchemaFieldType = iota
+	SCHEMA_LONG
+	SCHEMA_DOUBLE
+	SCHEMA_STRING_ARRAY
+	SCHEMA_DOUBLE_ARRAY
+)
+
+// SchemaField is a field of the schema used by protobuf and avro encoding, all fields are nullable
+type SchemaField struct {
+	Name  string
+	Type  SchemaFieldType
+	Index int // index of the value in Record.Values
+}
+
+// Schema contains the fields of a data source in a fixed order, fields which are not exported
+// by an item are null in its record
+type Schema struct {
+	DataSource config.DataSourceID
+	Fields     []SchemaField
+}
+
+// Record holds the exported values of an item, Values are indexed by 'ExportFieldStructTags' of the
+// data source, followed by the extra fields: time, k8s label names and values
+type Record struct {
+	DataSource  config.DataSourceID
+	TimestampUs int64
+	Values      []FieldValue
+	Valid       []bool
+}
+
+// schemaFieldType returns the type of the field after translation, numbers are encoded as long except float
+func schemaFieldType(structTags *config.StructTags, exporterCfg *config.ExporterCfg) SchemaFieldType {
+	if structTags.ToStringFuncName != "" ||
+		(structTags.UniversalTagMapID > 0 && !exporterCfg.UniversalTagTranslateToNameDisabled) ||
+		(structTags.EnumFile != "" && !exporterCfg.EnumTranslateToNameDisabled) {
+		return SCHEMA_STRING
+	}
+	switch structTags.DataKind {
+	case reflect.String:
+		return SCHEMA_STRING
+	case reflect.Float32, reflect.Float64:
+		return SCHEMA_DOUBLE
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Pointer:
+		return SCHEMA_LONG
+	case reflect.Slice:
+		switch structTags.DataType {
+		case utils.DATATYPE_StringSlice:
+			return SCHEMA_STRING_ARRAY
+		case utils.DATATYPE_Float64Slice:
+			return SCHEMA_DOUBLE_ARRAY
+		}
+	}
+	return SCHEMA_STRING
+}
+
+// SchemaFieldName converts name to a valid field name of protobuf and avro: [A-Za-z_][A-Za-z0-9_]*
+func SchemaFieldName(name string) string {
+	sb := strings.Builder{}
+	for i, c := range name {
+		if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9') {
+			sb.WriteRune(c)
+		} else {
+			sb.WriteByte('_')
+		}
+	}
+	return sb.String()
+}
+
+func k8sLabelFieldNames(dataSourceId config.DataSourceID) []string {
+	if dataSourceId.IsMap() {
+		return []string{"k8s_label_names_0", "k8s_label_values_0", "k8s_label_names_1", "k8s_label_values_1"}
+	}
+	return []string{"k8s_label_names", "k8s_label_values"}
+}
+
+// NewSchema generates the schema of the data source from 'ExportFieldStructTags', it should be called
+// after the struct tags are initialized. Fields with duplicated names keep the first one.
+func NewSchema(dataSourceId config.DataSourceID, exporterCfg *config.ExporterCfg) *Schema {
+	structTags := exporterCfg.ExportFieldStructTags[dataSourceId]
+	isMapItem := dataSourceId.IsMap()
+	schema := &Schema{
+		DataSource: dataSourceId,
+		Fields:     make([]SchemaField, 0, len(structTags)+5),
+	}
+	names := make(map[string]bool, len(structTags)+5)
+	add := func(name string, t SchemaFieldType, index int) {
+		name = SchemaFieldName(name)
+		if names[name] {
+			log.Debugf("datasource %s duplicate schema field %s", dataSourceId, name)
+			return
+		}
+		names[name] = true
+		schema.Fields = append(schema.Fields, SchemaField{Name: name, Type: t, Index: index})
+	}
+
+	add("time", SCHEMA_LONG, len(structTags))
+	for i := range structTags {
+		add(ExportFieldName(&structTags[i], isMapItem, exporterCfg), schemaFieldType(&structTags[i], exporterCfg), i)
+	}
+	for i, name := range k8sLabelFieldNames(dataSourceId) {
+		add(name, SCHEMA_STRING_ARRAY, len(structTags)+1+i)
+	}
+	return schema
+}
+
+func setK8sLabels(names, values *FieldValue, k8sLabels utag.Labels) {
+	if len(k8sLabels) == 0 {
+		return
+	}
+	names.Type, values.Type = FIELD_STRING_SLICE, FIELD_STRING_SLICE
+	names.StringSlice = make([]string, 0, len(k8sLabels))
+	values.StringSlice = make([]string, 0, len(k8sLabels))
+	for k, v := range k8sLabels {
+		names.StringSlice = append(names.StringSlice, k)
+		values.StringSlice = append(values.StringSlice, v)
+	}
+}
+
+// EncodeToRecord is the same as EncodeToJson, but keeps the values in a Record for schema based encodings
+func EncodeToRecord(item EncodeItem, dataSourceId int, exporterCfg *config.ExporterCfg, uTags0, uTags1 *utag.UniversalTags, k8sLabels0, k8sLabels1 utag.Labels) *Record {
+	if dataSourceId >= int(config.MAX_DATASOURCE_ID) {
+		log.Errorf("export datasource wrong: datasourceid %d ", dataSourceId)
+		return nil
+	}
+	fieldCount := len(exporterCfg.ExportFieldStructTags[dataSourceId])
+	extraNames := k8sLabelFieldNames(config.DataSourceID(dataSourceId))
+	record := &Record{
+		DataSource:  config.DataSourceID(dataSourceId),
+		TimestampUs: item.TimestampUs(),
+		Values:      make([]FieldValue, fieldCount+1+len(extraNames)),
+		Valid:       make([]bool, fieldCount+1+len(extraNames)),
+	}
+
+	walkExportFields(item, dataSourceId, exporterCfg, uTags0, uTags1, func(index int, _ string, v *FieldValue) {
+		record.Values[index] = *v
+		record.Valid[index] = true
+	})
+
+	record.Values[fieldCount] = FieldValue{Type: FIELD_FLOAT64, Value: record.TimestampUs}
+	record.Valid[fieldCount] = true
+	labels := fieldCount + 1
+	setK8sLabels(&record.Values[labels], &record.Values[labels+1], k8sLabels0)
+	if len(extraNames) > 2 {
+		setK8sLabels(&record.Values[labels+2], &record.Values[labels+3], k8sLabels1)
+	}
+	for i := labels; i < len(record.Values); i++ {
+		record.Valid[i] = record.Values[i].Type == FIELD_STRING_SLICE
+	}
+	return record
+}
+
+// Int64 converts the original value to int64, uint64 larger than math.MaxInt64 overflows
+func (v *FieldValue) Int64() int64 {
+	switch i := v.Value.(type) {
+	case int64:
+		return i
+	case uint64:
+		return int64(i)
+	case int:
+		return int64(i)
+	case uint:
+		return int64(i)
+	case int32:
+		return int64(i)
+	case uint32:
+		return int64(i)
+	case int16:
+		return int64(i)
+	case uint16:
+		return int64(i)
+	case int8:
+		return int64(i)
+	case uint8:
+		return int64(i)
+	case uintptr:
+		return int64(i)
+	}
+	// bool, pointers and translated values
+	return int64(v.Float64)
+}
@@ -0,0 +1,142 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package common
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/deepflowio/deepflow/server/ingester/exporters/config"
+	"github.com/deepflowio/deepflow/server/libs/utils"
+)
+
+type testItem struct {
+	AppService string   `json:"app_service" category:"$tag"`
+	Endpoint   string   `json:"endpoint" category:"$tag"`
+	Protocol   uint8    `json:"l7_protocol" category:"$tag"`
+	Names      []string `json:"attribute_names" category:"$tag" data_type:"[]string"`
+	Bytes      uint64   `json:"byte" category:"$metrics"`
+	Errors     uint32   `json:"error" category:"$metrics"`
+	Rrt        float64  `json:"rrt" category:"$metrics"`
+	Time       int64
+}
+
+func (t *testItem) GetFieldValueByOffsetAndKind(offset uintptr, kind reflect.Kind, dataType utils.DataType) interface{} {
+	return utils.GetValueByOffsetAndKind(uintptr(unsafe.Pointer(t)), offset, kind, dataType)
+}
+
+func (t *testItem) TimestampUs() int64 {
+	return t.Time
+}
+
+func newTestExporterCfg() *config.ExporterCfg {
+	cfg := &config.ExporterCfg{}
+	typ := reflect.TypeOf(testItem{})
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Tag.Get("json") == "" {
+			continue
+		}
+		structTags := config.StructTags{
+			Name:        field.Tag.Get("json"),
+			FieldName:   field.Name,
+			Offset:      field.Offset,
+			CategoryBit: config.StringToCategoryBit(field.Tag.Get("category")),
+			DataKind:    field.Type.Kind(),
+			DataType:    utils.ToDataType(field.Tag.Get("data_type")),
+		}
+		if structTags.Name == "l7_protocol" {
+			structTags.EnumFile = "l7_protocol"
+			structTags.EnumIntMap = map[int]string{20: "HTTP"}
+		}
+		cfg.ExportFieldStructTags[config.PERF_EVENT] = append(cfg.ExportFieldStructTags[config.PERF_EVENT], structTags)
+	}
+	return cfg
+}
+
+func TestEncodeToJson(t *testing.T) {
+	cfg := newTestExporterCfg()
+	item := &testItem{AppService: `svc"a`, Protocol: 20, Names: []string{"k"}, Bytes: 1 << 60, Rrt: 1.5, Time: 1700000000000000}
+	expect := `{"datasource":"event.perf_event","app_service":"svc\"a","l7_protocol":"HTTP","attribute_names":["k"],"byte":1152921504606846976,"error":0,"rrt":1.5` +
+		`,"time_str":"` + time.UnixMicro(item.Time).String() + `"}`
+	if got := EncodeToJson(item, int(config.PERF_EVENT), cfg, nil, nil, nil, nil); got != expect {
+		t.Errorf("EncodeToJson() = %s, want %s", got, expect)
+	}
+
+	cfg.ExportEmptyTag = true
+	cfg.ExportEmptyMetricsDisabled = true
+	cfg.EnumTranslateToNameDisabled = true
+	expect = `{"datasource":"event.perf_event","app_service":"svc\"a","endpoint":"","l7_protocol":20,"attribute_names":["k"],"byte":1152921504606846976,"rrt":1.5` +
+		`,"k8s_label_names":["app"],"k8s_label_values":["web"],"time_str":"` + time.UnixMicro(item.Time).String() + `"}`
+	if got := EncodeToJson(item, int(config.PERF_EVENT), cfg, nil, nil, map[string]string{"app": "web"}, nil); got != expect {
+		t.Errorf("EncodeToJson() = %s, want %s", got, expect)
+	}
+}
+
+func TestEncodeToRecord(t *testing.T) {
+	cfg := newTestExporterCfg()
+	schema := NewSchema(config.PERF_EVENT, cfg)
+	expectFields := []SchemaField{
+		{"time", SCHEMA_LONG, 7},
+		{"app_service", SCHEMA_STRING, 0},
+		{"endpoint", SCHEMA_STRING, 1},
+		{"l7_protocol", SCHEMA_STRING, 2},
+		{"attribute_names", SCHEMA_STRING_ARRAY, 3},
+		{"byte", SCHEMA_LONG, 4},
+		{"error", SCHEMA_LONG, 5},
+		{"rrt", SCHEMA_DOUBLE, 6},
+		{"k8s_label_names", SCHEMA_STRING_ARRAY, 8},
+		{"k8s_label_values", SCHEMA_STRING_ARRAY, 9},
+	}
+	if !reflect.DeepEqual(schema.Fields, expectFields) {
+		t.Fatalf("NewSchema() = %+v, want %+v", schema.Fields, expectFields)
+	}
+
+	item := &testItem{AppService: "svc", Protocol: 20, Bytes: 1 << 60, Errors: 3, Time: 1700000000000000}
+	record := EncodeToRecord(item, int(config.PERF_EVENT), cfg, nil, nil, map[string]string{"app": "web"}, nil)
+	expectValid := []bool{true, false, true, false, true, true, true, true, true, true}
+	if !reflect.DeepEqual(record.Valid, expectValid) {
+		t.Fatalf("EncodeToRecord() valid %v, want %v", record.Valid, expectValid)
+	}
+	if v := record.Values[2]; v.String != "HTTP" {
+		t.Errorf("l7_protocol = %+v, want HTTP", v)
+	}
+	if v := record.Values[4]; v.Int64() != 1<<60 {
+		t.Errorf("byte = %d, want %d", v.Int64(), int64(1<<60))
+	}
+	if v := record.Values[7]; v.Int64() != item.Time {
+		t.Errorf("time = %d, want %d", v.Int64(), item.Time)
+	}
+	if v := record.Values[9]; !reflect.DeepEqual(v.StringSlice, []string{"web"}) {
+		t.Errorf("k8s_label_values = %v, want [web]", v.StringSlice)
+	}
+}
+
+func TestSchemaFieldName(t *testing.T) {
+	for name, expect := range map[string]string{
+		"byte":           "byte",
+		"1xx":            "_xx",
+		"attribute.name": "attribute_name",
+		"k8s_label_0":    "k8s_label_0",
+	} {
+		if got := SchemaFieldName(name); got != expect {
+			t.Errorf("SchemaFieldName(%s) = %s, want %s", name, got, expect)
+		}
+	}
+}
@@ -39,11 +39,13 @@ import (
 var log = logging.MustGetLogger("exporters_config")
 
 const (
-	DefaultExportQueueCount     = 4
-	DefaultExportQueueSize      = 100000
-	DefaultExportOtlpBatchSize  = 32
-	DefaultExportOtherBatchSize = 1024
-	SecurityProtocol            = "SASL_SSL"
+	DefaultExportQueueCount      = 4
+	DefaultExportQueueSize       = 100000
+	DefaultExportOtlpBatchSize   = 32
+	DefaultExportOtherBatchSize  = 1024
+	DefaultHttpRequestTimeout    = 10 // s
+	DefaultSchemaRegistryTimeout = 10 // s
+	SecurityProtocol             = "SASL_SSL"
 
 	CATEGORY_K8S_LABEL = "$k8s.label"
 	CATEGORY_TAG       = "$tag"
@@ -459,8 +461,27 @@ type ExporterCfg struct {
 	ExtraHeaders map[string]string `yaml:"extra-headers"`
 
 	// kafka private configuration
-	Sasl  Sasl   `yaml:"sasl"`
-	Topic string `yaml:"topic"`
+	Sasl           Sasl           `yaml:"sasl"`
+	Topic          string         `yaml:"topic"`
+	Encoding       string         `yaml:"encoding"`
+	ExportEncoding ExportEncoding // gen by `Encoding`
+	SchemaRegistry SchemaRegistry `yaml:"schema-registry"`
+
+	// http private configuration
+	RequestTimeout int `yaml:"request-timeout"`
+}
+
+type SchemaRegistry struct {
+	URL      string `yaml:"url"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	Timeout  int    `yaml:"timeout"`
+}
+
+func (s *SchemaRegistry) Validate() {
+	if s.Timeout == 0 {
+		s.Timeout = DefaultSchemaRegistryTimeout
+	}
 }
 
 type Sasl struct {
@@ -490,6 +511,7 @@ const (
 	PROTOCOL_OTLP ExportProtocol = iota
 	PROTOCOL_PROMETHEUS
 	PROTOCOL_KAFKA
+	PROTOCOL_HTTP
 
 	MAX_PROTOCOL_ID
 )
@@ -498,6 +520,7 @@ var protocolToStrings = []string{
 	PROTOCOL_OTLP:       "opentelemetry",
 	PROTOCOL_PROMETHEUS: "prometheus",
 	PROTOCOL_KAFKA:      "kafka",
+	PROTOCOL_HTTP:       "http",
 	MAX_PROTOCOL_ID:     "unknown",
 }
 
@@ -515,6 +538,40 @@ func (p ExportProtocol) String() string {
 	return protocolToStrings[p]
 }
 
+// ExportEncoding is the encoding of kafka messages, other protocols always use their own encodings
+type ExportEncoding uint8
+
+const (
+	ENCODING_JSON ExportEncoding = iota
+	ENCODING_PROTOBUF
+	ENCODING_AVRO
+
+	MAX_ENCODING_ID
+)
+
+var encodingToStrings = []string{
+	ENCODING_JSON:     "json",
+	ENCODING_PROTOBUF: "protobuf",
+	ENCODING_AVRO:     "avro",
+	MAX_ENCODING_ID:   "unknown",
+}
+
+func stringToExportEncoding(str string) (ExportEncoding, error) {
+	if str == "" {
+		return ENCODING_JSON, nil
+	}
+	for i, v := range encodingToStrings[:MAX_ENCODING_ID] {
+		if v == str {
+			return ExportEncoding(i), nil
+		}
+	}
+	return MAX_ENCODING_ID, fmt.Errorf("unsupport export encoding: %s, support encodings %v", str, encodingToStrings[:MAX_ENCODING_ID])
+}
+
+func (e ExportEncoding) String() string {
+	return encodingToStrings[e]
+}
+
 func (cfg *ExporterCfg) Validate() error {
 	l := len(cfg.Endpoints)
 	cfg.RandomEndpoints = make([]string, 0, l)
@@ -551,6 +608,22 @@ func (cfg *ExporterCfg) Validate() error {
 	cfg.TagFilterCondition.Validate()
 	cfg.Sasl.Validate()
 
+	encoding, err := stringToExportEncoding(cfg.Encoding)
+	if err != nil {
+		return err
+	}
+	if encoding != ENCODING_JSON && cfg.ExportProtocol != PROTOCOL_KAFKA {
+		return fmt.Errorf("export encoding %s is only supported by protocol %s", encoding, PROTOCOL_KAFKA)
+	}
+	if encoding != ENCODING_JSON && cfg.SchemaRegistry.URL == "" {
+		return fmt.Errorf("export encoding %s requires 'schema-registry.url'", encoding)
+	}
+	cfg.ExportEncoding = encoding
+	cfg.SchemaRegistry.Validate()
+	if cfg.RequestTimeout == 0 {
+		cfg.RequestTimeout = DefaultHttpRequestTimeout
+	}
+
 	return nil
 }
 
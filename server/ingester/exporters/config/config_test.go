@@ -49,3 +49,23 @@ func TestConfig(t *testing.T) {
 		t.Logf("yaml unmarshal, got: %s", string(bytes))
 	}
 }
+
+func TestExportEncoding(t *testing.T) {
+	cases := []struct {
+		cfg      ExporterCfg
+		encoding ExportEncoding
+		valid    bool
+	}{
+		{ExporterCfg{Protocol: "kafka"}, ENCODING_JSON, true},
+		{ExporterCfg{Protocol: "kafka", Encoding: "avro", SchemaRegistry: SchemaRegistry{URL: "http://127.0.0.1:8081"}}, ENCODING_AVRO, true},
+		{ExporterCfg{Protocol: "kafka", Encoding: "protobuf"}, MAX_ENCODING_ID, false},
+		{ExporterCfg{Protocol: "kafka", Encoding: "thrift"}, MAX_ENCODING_ID, false},
+		{ExporterCfg{Protocol: "http", Encoding: "avro", SchemaRegistry: SchemaRegistry{URL: "http://127.0.0.1:8081"}}, MAX_ENCODING_ID, false},
+	}
+	for _, c := range cases {
+		err := c.cfg.Validate()
+		if (err == nil) != c.valid || (c.valid && c.cfg.ExportEncoding != c.encoding) {
+			t.Errorf("Validate(%s %s) = %v, encoding %s", c.cfg.Protocol, c.cfg.Encoding, err, c.cfg.ExportEncoding)
+		}
+	}
+}
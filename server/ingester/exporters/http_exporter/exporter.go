@@ -0,0 +1,288 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http_exporter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	logging "github.com/op/go-logging"
+	"golang.org/x/net/context"
+
+	ingester_common "github.com/deepflowio/deepflow/server/ingester/common"
+	"github.com/deepflowio/deepflow/server/ingester/exporters/common"
+	exporters_cfg "github.com/deepflowio/deepflow/server/ingester/exporters/config"
+	utag "github.com/deepflowio/deepflow/server/ingester/exporters/universal_tag"
+	"github.com/deepflowio/deepflow/server/ingester/ingesterctl"
+	"github.com/deepflowio/deepflow/server/libs/debug"
+	"github.com/deepflowio/deepflow/server/libs/queue"
+	"github.com/deepflowio/deepflow/server/libs/stats"
+	"github.com/deepflowio/deepflow/server/libs/utils"
+)
+
+var log = logging.MustGetLogger("http_exporter")
+
+const (
+	QUEUE_BATCH_COUNT = 1024
+
+	NDJSON_CONTENT_TYPE = "application/x-ndjson"
+)
+
+// HttpExporter posts items as gzip compressed NDJSON (newline delimited json) batches to the endpoints
+type HttpExporter struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	index                 int
+	dataQueues            queue.FixedMultiQueue
+	queueCount            int
+	requestFailedCounters []int
+	client                *http.Client
+
+	universalTagsManager *utag.UniversalTagsManager
+	config               *exporters_cfg.ExporterCfg
+	counter              *Counter
+	lastCounter          Counter
+	running              bool
+
+	utils.Closable
+}
+
+type Counter struct {
+	RecvCounter      int64 `statsd:"recv-count"`
+	SendCounter      int64 `statsd:"send-count"`
+	SendBatchCounter int64 `statsd:"send-batch-count"`
+	SendBytes        int64 `statsd:"send-bytes"`
+	DropCounter      int64 `statsd:"drop-count"`
+	DropBatchCounter int64 `statsd:"drop-batch-count"`
+	ExportUsedTimeNs int64 `statsd:"export-used-time-ns"`
+}
+
+func (e *HttpExporter) GetCounter() interface{} {
+	var counter Counter
+	counter, *e.counter = *e.counter, Counter{}
+	e.lastCounter = counter
+	return &counter
+}
+
+func NewHttpExporter(index int, config *exporters_cfg.ExporterCfg, universalTagsManager *utag.UniversalTagsManager) *HttpExporter {
+	ctx, cancel := context.WithCancel(context.Background())
+	dataQueues := queue.NewOverwriteQueues(
+		fmt.Sprintf("http_exporter_%d", index), queue.HashKey(config.QueueCount), config.QueueSize,
+		queue.OptionFlushIndicator(time.Second),
+		queue.OptionRelease(func(p interface{}) { p.(common.ExportItem).Release() }),
+		ingester_common.QUEUE_STATS_MODULE_INGESTER)
+
+	exporter := &HttpExporter{
+		index:                 index,
+		dataQueues:            dataQueues,
+		queueCount:            config.QueueCount,
+		requestFailedCounters: make([]int, config.QueueCount),
+		client:                &http.Client{Timeout: time.Duration(config.RequestTimeout) * time.Second},
+		universalTagsManager:  universalTagsManager,
+		config:                config,
+		counter:               &Counter{},
+		ctx:                   ctx,
+		cancel:                cancel,
+	}
+	debug.ServerRegisterSimple(ingesterctl.CMD_HTTP_EXPORTER, exporter)
+	ingester_common.RegisterCountableForIngester("exporter", exporter, stats.OptionStatTags{
+		"type": "http", "index": strconv.Itoa(index)})
+	log.Infof("http exporter %d created", index)
+	return exporter
+}
+
+func (e *HttpExporter) Put(items ...interface{}) {
+	e.counter.RecvCounter++
+	e.dataQueues.Put(queue.HashKey(int(e.counter.RecvCounter)%e.queueCount), items...)
+}
+
+func (e *HttpExporter) Start() {
+	if e.running {
+		log.Warningf("http exporter %d already running", e.index)
+		return
+	}
+	e.running = true
+	for i := 0; i < e.queueCount; i++ {
+		go e.queueProcess(int(i))
+	}
+	log.Infof("http exporter %d started %d queue", e.index, e.queueCount)
+}
+
+func (e *HttpExporter) Close() {
+	e.running = false
+	e.Closable.Close()
+	e.cancel()
+	log.Infof("http exporter %d stopping", e.index)
+}
+
+// NdjsonBatch writes json lines to a gzip stream
+type NdjsonBatch struct {
+	buffer *bytes.Buffer
+	writer *gzip.Writer
+	count  int
+}
+
+func NewNdjsonBatch() *NdjsonBatch {
+	buffer := &bytes.Buffer{}
+	return &NdjsonBatch{
+		buffer: buffer,
+		writer: gzip.NewWriter(buffer),
+	}
+}
+
+func (b *NdjsonBatch) Append(line string) error {
+	if _, err := b.writer.Write(utils.Slice(line)); err != nil {
+		return err
+	}
+	if _, err := b.writer.Write([]byte{'\n'}); err != nil {
+		return err
+	}
+	b.count++
+	return nil
+}
+
+func (b *NdjsonBatch) Count() int {
+	return b.count
+}
+
+// Bytes flushes the gzip stream and returns the compressed data, it is valid until Reset
+func (b *NdjsonBatch) Bytes() ([]byte, error) {
+	if err := b.writer.Close(); err != nil {
+		return nil, err
+	}
+	return b.buffer.Bytes(), nil
+}
+
+func (b *NdjsonBatch) Reset() {
+	b.buffer.Reset()
+	b.writer.Reset(b.buffer)
+	b.count = 0
+}
+
+func (e *HttpExporter) queueProcess(queueID int) {
+	items := make([]interface{}, QUEUE_BATCH_COUNT)
+	batch := NewNdjsonBatch()
+
+	doReq := func() {
+		batchCount := batch.Count()
+		if batchCount == 0 {
+			return
+		}
+		now := time.Now()
+		if err := e.sendBatch(queueID, batch); err != nil {
+			if e.counter.DropCounter == 0 {
+				log.Warningf("exporter %d failed to send http request, requestFaildCounter=%d, err: %v", e.index, e.requestFailedCounters[queueID], err)
+			}
+			e.counter.DropCounter += int64(batchCount)
+			e.counter.DropBatchCounter++
+		} else {
+			e.counter.SendCounter += int64(batchCount)
+			e.counter.SendBatchCounter++
+		}
+		e.counter.ExportUsedTimeNs += int64(time.Since(now))
+		batch.Reset()
+	}
+
+	for e.running {
+		n := e.dataQueues.Gets(queue.HashKey(queueID), items)
+		for _, item := range items[:n] {
+			if item == nil {
+				doReq()
+				continue
+			}
+			exportItem, ok := item.(common.ExportItem)
+			if !ok {
+				e.counter.DropCounter++
+				continue
+			}
+
+			encoded, err := exportItem.EncodeTo(exporters_cfg.PROTOCOL_HTTP, e.universalTagsManager, e.config)
+			exportItem.Release()
+			if err != nil {
+				if e.counter.DropCounter == 0 {
+					log.Warningf("http encode failed, err: %s", err)
+				}
+				e.counter.DropCounter++
+				continue
+			}
+			line, ok := encoded.(string)
+			if !ok || line == "" {
+				e.counter.DropCounter++
+				continue
+			}
+			if err := batch.Append(line); err != nil {
+				log.Warningf("http exporter %d write batch failed, err: %s", e.index, err)
+				e.counter.DropCounter += int64(batch.Count())
+				batch.Reset()
+				continue
+			}
+			if batch.Count() >= e.config.BatchSize {
+				doReq()
+			}
+		}
+	}
+}
+
+func (e *HttpExporter) HandleSimpleCommand(op uint16, arg string) string {
+	return fmt.Sprintf("http exporter %d last 10s counter: %+v", e.index, e.lastCounter)
+}
+
+func (e *HttpExporter) getEndpont(queueID int) string {
+	l := len(e.config.RandomEndpoints)
+	return e.config.RandomEndpoints[e.requestFailedCounters[queueID]%l]
+}
+
+func (e *HttpExporter) sendBatch(queueID int, batch *NdjsonBatch) error {
+	data, err := batch.Bytes()
+	if err != nil {
+		return err
+	}
+
+	endpoint := e.getEndpont(queueID)
+	req, err := http.NewRequestWithContext(e.ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		e.requestFailedCounters[queueID]++
+		return err
+	}
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("Content-Type", NDJSON_CONTENT_TYPE)
+	// inject extra headers
+	for k, v := range e.config.ExtraHeaders {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		e.requestFailedCounters[queueID]++
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if resp.StatusCode >= 400 {
+		e.requestFailedCounters[queueID]++
+		return fmt.Errorf("endpoint %s returned HTTP status %v; err = %s: %s", endpoint, resp.Status, err, body)
+	}
+	e.counter.SendBytes += int64(len(data))
+	return nil
+}
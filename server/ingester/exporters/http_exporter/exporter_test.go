@@ -0,0 +1,85 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http_exporter
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	exporters_cfg "github.com/deepflowio/deepflow/server/ingester/exporters/config"
+)
+
+func TestSendBatch(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "gzip" || r.Header.Get("Content-Type") != NDJSON_CONTENT_TYPE || r.Header.Get("X-Token") != "abc" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		reader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			received = append(received, scanner.Text())
+		}
+	}))
+	defer server.Close()
+
+	exporter := &HttpExporter{
+		ctx:                   context.Background(),
+		requestFailedCounters: make([]int, 1),
+		client:                server.Client(),
+		config: &exporters_cfg.ExporterCfg{
+			RandomEndpoints: []string{server.URL + "/ingest"},
+			ExtraHeaders:    map[string]string{"X-Token": "abc"},
+		},
+		counter: &Counter{},
+	}
+
+	batch := NewNdjsonBatch()
+	for round := 0; round < 2; round++ {
+		received = nil
+		batch.Append(`{"datasource":"flow_log.l7_flow_log","endpoint":"/a"}`)
+		batch.Append(`{"datasource":"flow_log.l7_flow_log","endpoint":"/b"}`)
+		if batch.Count() != 2 {
+			t.Errorf("Count() = %d, want 2", batch.Count())
+		}
+		if err := exporter.sendBatch(0, batch); err != nil {
+			t.Fatalf("sendBatch() failed: %s", err)
+		}
+		expect := []string{`{"datasource":"flow_log.l7_flow_log","endpoint":"/a"}`, `{"datasource":"flow_log.l7_flow_log","endpoint":"/b"}`}
+		if !reflect.DeepEqual(received, expect) {
+			t.Errorf("received %v, want %v", received, expect)
+		}
+		// the batch could be reused after reset
+		batch.Reset()
+	}
+
+	exporter.config.ExtraHeaders = nil
+	batch.Append(`{}`)
+	if err := exporter.sendBatch(0, batch); err == nil || exporter.requestFailedCounters[0] != 1 {
+		t.Errorf("sendBatch() err = %v, failed %d times", err, exporter.requestFailedCounters[0])
+	}
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafka_exporter
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/deepflowio/deepflow/server/ingester/exporters/common"
+	exporters_cfg "github.com/deepflowio/deepflow/server/ingester/exporters/config"
+)
+
+// avro specification: https://avro.apache.org/docs/1.11.1/specification/
+
+type avroField struct {
+	Name    string      `json:"name"`
+	Type    interface{} `json:"type"`
+	Default interface{} `json:"default"` // always null
+}
+
+type avroRecord struct {
+	Type      string      `json:"type"`
+	Name      string      `json:"name"`
+	Namespace string      `json:"namespace"`
+	Fields    []avroField `json:"fields"`
+}
+
+func AvroRecordName(dataSourceId exporters_cfg.DataSourceID) string {
+	return common.SchemaFieldName(dataSourceId.String())
+}
+
+func avroType(field *common.SchemaField) interface{} {
+	switch field.Type {
+	case common.SCHEMA_LONG:
+		if field.Name == "time" {
+			return map[string]string{"type": "long", "logicalType": "timestamp-micros"}
+		}
+		return "long"
+	case common.SCHEMA_DOUBLE:
+		return "double"
+	case common.SCHEMA_STRING_ARRAY:
+		return map[string]string{"type": "array", "items": "string"}
+	case common.SCHEMA_DOUBLE_ARRAY:
+		return map[string]string{"type": "array", "items": "double"}
+	default:
+		return "string"
+	}
+}
+
+// AvroSchema returns the avro schema of a record, all fields are unions of null and the value type
+func AvroSchema(schema *common.Schema) string {
+	record := &avroRecord{
+		Type:      "record",
+		Name:      AvroRecordName(schema.DataSource),
+		Namespace: SCHEMA_NAMESPACE,
+		Fields:    make([]avroField, 0, len(schema.Fields)),
+	}
+	for i := range schema.Fields {
+		record.Fields = append(record.Fields, avroField{
+			Name: schema.Fields[i].Name,
+			Type: []interface{}{"null", avroType(&schema.Fields[i])},
+		})
+	}
+	bytes, _ := json.Marshal(record)
+	return string(bytes)
+}
+
+func appendAvroLong(buf []byte, v int64) []byte {
+	return protowire.AppendVarint(buf, protowire.EncodeZigZag(v))
+}
+
+func appendAvroString(buf []byte, s string) []byte {
+	buf = appendAvroLong(buf, int64(len(s)))
+	return append(buf, s...)
+}
+
+func appendAvroDouble(buf []byte, f float64) []byte {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], math.Float64bits(f))
+	return append(buf, b[:]...)
+}
+
+// AppendAvro appends the avro binary encoding of record to buf
+func AppendAvro(buf []byte, schema *common.Schema, record *common.Record) []byte {
+	for i := range schema.Fields {
+		field := &schema.Fields[i]
+		v := &record.Values[field.Index]
+		if !record.Valid[field.Index] ||
+			(field.Type == common.SCHEMA_STRING_ARRAY && v.Type != common.FIELD_STRING_SLICE) ||
+			(field.Type == common.SCHEMA_DOUBLE_ARRAY && v.Type != common.FIELD_FLOAT64_SLICE) {
+			// union branch 0: null
+			buf = appendAvroLong(buf, 0)
+			continue
+		}
+		buf = appendAvroLong(buf, 1)
+		switch field.Type {
+		case common.SCHEMA_STRING:
+			buf = appendAvroString(buf, v.String)
+		case common.SCHEMA_LONG:
+			buf = appendAvroLong(buf, v.Int64())
+		case common.SCHEMA_DOUBLE:
+			buf = appendAvroDouble(buf, v.Float64)
+		case common.SCHEMA_STRING_ARRAY:
+			if len(v.StringSlice) > 0 {
+				buf = appendAvroLong(buf, int64(len(v.StringSlice)))
+				for _, s := range v.StringSlice {
+					buf = appendAvroString(buf, s)
+				}
+			}
+			buf = appendAvroLong(buf, 0)
+		case common.SCHEMA_DOUBLE_ARRAY:
+			if len(v.Float64Slice) > 0 {
+				buf = appendAvroLong(buf, int64(len(v.Float64Slice)))
+				for _, f := range v.Float64Slice {
+					buf = appendAvroDouble(buf, f)
+				}
+			}
+			buf = appendAvroLong(buf, 0)
+		}
+	}
+	return buf
+}
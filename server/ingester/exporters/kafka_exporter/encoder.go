@@ -0,0 +1,136 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafka_exporter
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/deepflowio/deepflow/server/ingester/exporters/common"
+	exporters_cfg "github.com/deepflowio/deepflow/server/ingester/exporters/config"
+)
+
+const (
+	// confluent wire format: https://docs.confluent.io/platform/current/schema-registry/fundamentals/serdes-develop/index.html#wire-format
+	WIRE_FORMAT_MAGIC_BYTE  = 0
+	WIRE_FORMAT_HEADER_SIZE = 5
+
+	SCHEMA_RETRY_INTERVAL = 10 * time.Second
+	SCHEMA_NAMESPACE      = "deepflow"
+)
+
+type registeredSchema struct {
+	id           int
+	schema       *common.Schema
+	fieldNumbers []protowire.Number // protobuf only
+	err          error
+	failedTime   time.Time
+}
+
+// RecordEncoder encodes records with protobuf or avro, the schema of each data source is generated
+// and registered when it is used first time
+type RecordEncoder struct {
+	config   *exporters_cfg.ExporterCfg
+	registry SchemaRegistry
+
+	sync.Mutex
+	schemas map[string]*registeredSchema // key: subject
+}
+
+func NewRecordEncoder(config *exporters_cfg.ExporterCfg, registry SchemaRegistry) *RecordEncoder {
+	return &RecordEncoder{
+		config:   config,
+		registry: registry,
+		schemas:  make(map[string]*registeredSchema),
+	}
+}
+
+// Subject uses the TopicNameStrategy by default. If all data sources are exported to the same
+// topic, the TopicRecordNameStrategy is used, since their schemas are different
+func (e *RecordEncoder) Subject(topic string, dataSourceId exporters_cfg.DataSourceID) string {
+	if e.config.Topic == "" {
+		return topic + "-value"
+	}
+	return topic + "-" + SCHEMA_NAMESPACE + "." + e.recordName(dataSourceId)
+}
+
+func (e *RecordEncoder) recordName(dataSourceId exporters_cfg.DataSourceID) string {
+	if e.config.ExportEncoding == exporters_cfg.ENCODING_PROTOBUF {
+		return ProtobufMessageName(dataSourceId)
+	}
+	return AvroRecordName(dataSourceId)
+}
+
+func (e *RecordEncoder) getSchema(topic string, dataSourceId exporters_cfg.DataSourceID) (*registeredSchema, error) {
+	subject := e.Subject(topic, dataSourceId)
+	e.Lock()
+	defer e.Unlock()
+	s, ok := e.schemas[subject]
+	if ok && (s.err == nil || time.Since(s.failedTime) < SCHEMA_RETRY_INTERVAL) {
+		return s, s.err
+	}
+
+	schema := common.NewSchema(dataSourceId, e.config)
+	var schemaType SchemaType
+	var schemaStr string
+	var fieldNumbers []protowire.Number
+	if e.config.ExportEncoding == exporters_cfg.ENCODING_PROTOBUF {
+		fieldNumbers = ProtobufFieldNumbers(schema)
+		schemaType, schemaStr = SCHEMA_TYPE_PROTOBUF, ProtobufSchema(schema, fieldNumbers)
+	} else {
+		schemaType, schemaStr = SCHEMA_TYPE_AVRO, AvroSchema(schema)
+	}
+	id, err := e.registry.Register(subject, schemaType, schemaStr)
+	s = &registeredSchema{id: id, schema: schema, fieldNumbers: fieldNumbers, err: err}
+	if err != nil {
+		s.failedTime = time.Now()
+		log.Warningf("register %s schema of subject %s failed: %s", schemaType, subject, err)
+	} else {
+		log.Infof("registered %s schema of subject %s, id %d", schemaType, subject, id)
+	}
+	e.schemas[subject] = s
+	return s, err
+}
+
+// Encode encodes the record in confluent wire format: magic byte 0, 4 bytes schema id and the payload
+func (e *RecordEncoder) Encode(topic string, record *common.Record) ([]byte, error) {
+	if record == nil {
+		return nil, errors.New("record is nil")
+	}
+	s, err := e.getSchema(topic, record.DataSource)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, WIRE_FORMAT_HEADER_SIZE, 512)
+	buf[0] = WIRE_FORMAT_MAGIC_BYTE
+	binary.BigEndian.PutUint32(buf[1:], uint32(s.id))
+	switch e.config.ExportEncoding {
+	case exporters_cfg.ENCODING_PROTOBUF:
+		// message indexes, [0] is encoded as a single 0 for the first message in the schema
+		buf = append(buf, 0)
+		return AppendProtobuf(buf, s.schema, s.fieldNumbers, record), nil
+	case exporters_cfg.ENCODING_AVRO:
+		return AppendAvro(buf, s.schema, record), nil
+	default:
+		return nil, fmt.Errorf("unsupport record encoding %s", e.config.ExportEncoding)
+	}
+}
@@ -0,0 +1,302 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafka_exporter
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/deepflowio/deepflow/server/ingester/exporters/common"
+	exporters_cfg "github.com/deepflowio/deepflow/server/ingester/exporters/config"
+)
+
+type fakeSchemaRegistry struct {
+	schemas map[string]string // key: subject
+	calls   int
+	err     error
+}
+
+func (r *fakeSchemaRegistry) Register(subject string, schemaType SchemaType, schema string) (int, error) {
+	r.calls++
+	if r.err != nil {
+		return 0, r.err
+	}
+	if r.schemas == nil {
+		r.schemas = make(map[string]string)
+	}
+	r.schemas[subject] = string(schemaType) + ":" + schema
+	return len(r.schemas), nil
+}
+
+func newTestSchema() (*common.Schema, *common.Record) {
+	schema := &common.Schema{
+		DataSource: exporters_cfg.L7_FLOW_LOG,
+		Fields: []common.SchemaField{
+			{Name: "time", Type: common.SCHEMA_LONG, Index: 0},
+			{Name: "app_service", Type: common.SCHEMA_STRING, Index: 1},
+			{Name: "endpoint", Type: common.SCHEMA_STRING, Index: 2},
+			{Name: "response_code", Type: common.SCHEMA_LONG, Index: 3},
+			{Name: "rrt", Type: common.SCHEMA_DOUBLE, Index: 4},
+			{Name: "attribute_names", Type: common.SCHEMA_STRING_ARRAY, Index: 5},
+			{Name: "metrics_values", Type: common.SCHEMA_DOUBLE_ARRAY, Index: 6},
+		},
+	}
+	record := &common.Record{
+		DataSource: exporters_cfg.L7_FLOW_LOG,
+		Values: []common.FieldValue{
+			{Type: common.FIELD_FLOAT64, Value: int64(1700000000000000)},
+			{Type: common.FIELD_STRING, String: "svc"},
+			{},
+			{Type: common.FIELD_FLOAT64, Value: int32(-1), Float64: -1},
+			{Type: common.FIELD_FLOAT64, Value: 1.5, Float64: 1.5},
+			{Type: common.FIELD_STRING_SLICE, StringSlice: []string{"a", "b"}},
+			{Type: common.FIELD_FLOAT64_SLICE, Float64Slice: []float64{2.5}},
+		},
+		Valid: []bool{true, true, false, true, true, true, true},
+	}
+	return schema, record
+}
+
+// avroReader decodes the avro binary encoding for tests
+type avroReader []byte
+
+func (r *avroReader) long() int64 {
+	v, n := protowire.ConsumeVarint(*r)
+	*r = (*r)[n:]
+	return protowire.DecodeZigZag(v)
+}
+
+func (r *avroReader) str() string {
+	l := int(r.long())
+	s := string((*r)[:l])
+	*r = (*r)[l:]
+	return s
+}
+
+func (r *avroReader) double() float64 {
+	v := math.Float64frombits(binary.LittleEndian.Uint64(*r))
+	*r = (*r)[8:]
+	return v
+}
+
+func TestAppendAvro(t *testing.T) {
+	schema, record := newTestSchema()
+	r := avroReader(AppendAvro(nil, schema, record))
+	var got []interface{}
+	for _, field := range schema.Fields {
+		if r.long() == 0 {
+			got = append(got, nil)
+			continue
+		}
+		switch field.Type {
+		case common.SCHEMA_LONG:
+			got = append(got, r.long())
+		case common.SCHEMA_STRING:
+			got = append(got, r.str())
+		case common.SCHEMA_DOUBLE:
+			got = append(got, r.double())
+		case common.SCHEMA_STRING_ARRAY:
+			items := []string{}
+			for n := r.long(); n > 0; n = r.long() {
+				for i := int64(0); i < n; i++ {
+					items = append(items, r.str())
+				}
+			}
+			got = append(got, items)
+		case common.SCHEMA_DOUBLE_ARRAY:
+			items := []float64{}
+			for n := r.long(); n > 0; n = r.long() {
+				for i := int64(0); i < n; i++ {
+					items = append(items, r.double())
+				}
+			}
+			got = append(got, items)
+		}
+	}
+	expect := []interface{}{int64(1700000000000000), "svc", nil, int64(-1), 1.5, []string{"a", "b"}, []float64{2.5}}
+	if !reflect.DeepEqual(got, expect) || len(r) != 0 {
+		t.Errorf("AppendAvro() decoded %v, remain %d bytes, want %v", got, len(r), expect)
+	}
+
+	avroSchema := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(AvroSchema(schema)), &avroSchema); err != nil {
+		t.Fatalf("AvroSchema() is not json: %s", err)
+	}
+	if avroSchema["name"] != "flow_log_l7_flow_log" || len(avroSchema["fields"].([]interface{})) != len(schema.Fields) {
+		t.Errorf("AvroSchema() = %v", avroSchema)
+	}
+}
+
+func TestAppendProtobuf(t *testing.T) {
+	schema, record := newTestSchema()
+	numbers := ProtobufFieldNumbers(schema)
+	b := AppendProtobuf(nil, schema, numbers, record)
+	got := map[protowire.Number][]interface{}{}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		b = b[n:]
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			got[num] = append(got[num], int64(v))
+			b = b[n:]
+		case protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			got[num] = append(got[num], math.Float64frombits(v))
+			b = b[n:]
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if num == 263 { // packed doubles
+				for ; len(v) > 0; v = v[8:] {
+					got[num] = append(got[num], math.Float64frombits(binary.LittleEndian.Uint64(v)))
+				}
+			} else {
+				got[num] = append(got[num], string(v))
+			}
+			b = b[n:]
+		default:
+			t.Fatalf("unexpected wire type %d", typ)
+		}
+	}
+	// 'rrt' is not in the fixed table, it is numbered by hash
+	rrtNumber := protobufFieldNumber("rrt")
+	if rrtNumber < PROTOBUF_HASH_FIELD_NUMBER_MIN || rrtNumber > protowire.MaxValidNumber {
+		t.Errorf("protobufFieldNumber(rrt) = %d, out of the hash range", rrtNumber)
+	}
+	expect := map[protowire.Number][]interface{}{
+		1:         {int64(1700000000000000)},
+		76:        {"svc"},
+		244:       {int64(-1)},
+		rrtNumber: {1.5},
+		260:       {"a", "b"},
+		263:       {2.5},
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("AppendProtobuf() decoded %v, want %v", got, expect)
+	}
+
+	protoSchema := ProtobufSchema(schema, numbers)
+	for _, line := range []string{"message FlowLogL7FlowLog {", "optional int64 time = 1;", "repeated string attribute_names = 260;", "repeated double metrics_values = 263;"} {
+		if !strings.Contains(protoSchema, line) {
+			t.Errorf("ProtobufSchema() does not contain %s:\n%s", line, protoSchema)
+		}
+	}
+}
+
+func TestProtobufFieldNumbersStable(t *testing.T) {
+	schema, _ := newTestSchema()
+	numbers := ProtobufFieldNumbers(schema)
+	// removing an export field does not renumber the fields after it
+	schema.Fields = append(schema.Fields[:1], schema.Fields[2:]...)
+	removed := ProtobufFieldNumbers(schema)
+	expect := append(append([]protowire.Number{}, numbers[:1]...), numbers[2:]...)
+	if !reflect.DeepEqual(removed, expect) {
+		t.Errorf("ProtobufFieldNumbers() = %v after removing a field, want %v", removed, expect)
+	}
+
+	// field numbers must be unique and out of the hash range
+	names := make(map[protowire.Number]string, len(protobufFieldNumbers))
+	for name, num := range protobufFieldNumbers {
+		if other, ok := names[num]; ok {
+			t.Errorf("protobuf field %s and %s have the same number %d", name, other, num)
+		}
+		if !num.IsValid() || num >= PROTOBUF_HASH_FIELD_NUMBER_MIN {
+			t.Errorf("protobuf field %s has invalid number %d", name, num)
+		}
+		names[num] = name
+	}
+}
+
+func TestRecordEncoder(t *testing.T) {
+	schemaCfg := &exporters_cfg.ExporterCfg{ExportEncoding: exporters_cfg.ENCODING_PROTOBUF}
+	registry := &fakeSchemaRegistry{}
+	encoder := NewRecordEncoder(schemaCfg, registry)
+	// no struct tags, the record only has the extra fields: time and k8s labels
+	record := &common.Record{
+		DataSource: exporters_cfg.L7_FLOW_LOG,
+		Values:     []common.FieldValue{{Type: common.FIELD_FLOAT64, Value: int64(1700000000000000)}, {}, {}, {}, {}},
+		Valid:      []bool{true, false, false, false, false},
+	}
+
+	topic := exporters_cfg.L7_FLOW_LOG.TopicString()
+	for i := 0; i < 3; i++ {
+		data, err := encoder.Encode(topic, record)
+		if err != nil {
+			t.Fatalf("Encode() failed: %s", err)
+		}
+		if data[0] != WIRE_FORMAT_MAGIC_BYTE || binary.BigEndian.Uint32(data[1:5]) != 1 || data[5] != 0 {
+			t.Errorf("Encode() header = %v", data[:6])
+		}
+	}
+	if registry.calls != 1 || !strings.HasPrefix(registry.schemas["deepflow.flow_log.l7_flow_log-value"], "PROTOBUF:") {
+		t.Errorf("registry called %d times, schemas %v", registry.calls, registry.schemas)
+	}
+
+	// the fixed topic uses TopicRecordNameStrategy
+	schemaCfg.Topic = "deepflow"
+	if subject := encoder.Subject("deepflow", exporters_cfg.L7_FLOW_LOG); subject != "deepflow-deepflow.FlowLogL7FlowLog" {
+		t.Errorf("Subject() = %s", subject)
+	}
+
+	// failed registration is not retried immediately
+	registry = &fakeSchemaRegistry{err: errors.New("unavailable")}
+	encoder = NewRecordEncoder(&exporters_cfg.ExporterCfg{ExportEncoding: exporters_cfg.ENCODING_AVRO}, registry)
+	for i := 0; i < 3; i++ {
+		if _, err := encoder.Encode(topic, record); err == nil {
+			t.Error("Encode() should fail")
+		}
+	}
+	if registry.calls != 1 {
+		t.Errorf("registry called %d times, want 1", registry.calls)
+	}
+}
+
+func TestSchemaRegistryClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := &registerSchemaRequest{}
+		user, password, _ := r.BasicAuth()
+		if r.Method != http.MethodPost || r.URL.EscapedPath() != "/subjects/deepflow.flow_log.l7_flow_log-value/versions" ||
+			user != "user" || password != "pass" || json.NewDecoder(r.Body).Decode(request) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if request.SchemaType != SCHEMA_TYPE_AVRO {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(`{"error_code":42201,"message":"Invalid schema"}`))
+			return
+		}
+		w.Write([]byte(`{"id":7}`))
+	}))
+	defer server.Close()
+
+	client := NewSchemaRegistryClient(&exporters_cfg.SchemaRegistry{URL: server.URL + "/", Username: "user", Password: "pass", Timeout: 1})
+	if id, err := client.Register("deepflow.flow_log.l7_flow_log-value", SCHEMA_TYPE_AVRO, `{"type":"record"}`); err != nil || id != 7 {
+		t.Errorf("Register() = %d, %v, want 7", id, err)
+	}
+	if _, err := client.Register("deepflow.flow_log.l7_flow_log-value", SCHEMA_TYPE_PROTOBUF, "syntax"); err == nil || !strings.Contains(err.Error(), "42201") {
+		t.Errorf("Register() err = %v, want error code 42201", err)
+	}
+}
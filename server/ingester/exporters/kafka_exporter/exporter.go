@@ -49,6 +49,7 @@ type KafkaExporter struct {
 	producers            []sarama.SyncProducer
 	universalTagsManager *utag.UniversalTagsManager
 	config               *exporters_cfg.ExporterCfg
+	recordEncoder        *RecordEncoder // nil if encoding is json
 	counter              *Counter
 	lastCounter          Counter
 	running              bool
@@ -89,6 +90,9 @@ func NewKafkaExporter(index int, config *exporters_cfg.ExporterCfg, universalTag
 		config:               config,
 		counter:              &Counter{},
 	}
+	if config.ExportEncoding != exporters_cfg.ENCODING_JSON {
+		exporter.recordEncoder = NewRecordEncoder(config, NewSchemaRegistryClient(&config.SchemaRegistry))
+	}
 	debug.ServerRegisterSimple(ingesterctl.CMD_KAFKA_EXPORTER, exporter)
 	ingester_common.RegisterCountableForIngester("exporter", exporter, stats.OptionStatTags{
 		"type": "kafka", "index": strconv.Itoa(index)})
@@ -165,7 +169,7 @@ func (e *KafkaExporter) queueProcess(queueID int) {
 				continue
 			}
 
-			json, err := exportItem.EncodeTo(exporters_cfg.PROTOCOL_KAFKA, e.universalTagsManager, e.config)
+			encoded, err := exportItem.EncodeTo(exporters_cfg.PROTOCOL_KAFKA, e.universalTagsManager, e.config)
 			if err != nil {
 				if e.counter.DropCounter == 0 {
 					log.Warningf("kafka encode failed, err: %s", err)
@@ -175,21 +179,29 @@ func (e *KafkaExporter) queueProcess(queueID int) {
 				continue
 			}
 
-			jsonStr := json.(string)
 			topic := e.config.Topic
 			if topic == "" {
 				topic = exporters_cfg.DataSourceID(exportItem.DataSource()).TopicString()
 			}
+			value, err := e.encodeValue(topic, encoded)
+			if err != nil {
+				if e.counter.DropCounter == 0 {
+					log.Warningf("kafka encode %s failed, err: %s", e.config.ExportEncoding, err)
+				}
+				e.counter.DropCounter++
+				exportItem.Release()
+				continue
+			}
 			batch = append(batch,
 				&sarama.ProducerMessage{
 					Topic:     topic,
 					Key:       nil,
-					Value:     sarama.ByteEncoder(utils.Slice(jsonStr)),
+					Value:     sarama.ByteEncoder(value),
 					Timestamp: time.UnixMicro(exportItem.TimestampUs()),
 				},
 			)
 			if len(batch) >= e.config.BatchSize {
-				log.Debugf("kafka: %s \n %+v", jsonStr, item)
+				log.Debugf("kafka: %v \n %+v", encoded, item)
 				e.exportBatch(queueID, batch)
 				batch = batch[:0]
 			}
@@ -198,6 +210,21 @@ func (e *KafkaExporter) queueProcess(queueID int) {
 	}
 }
 
+// encodeValue returns the json string directly, or encodes the record by protobuf or avro
+func (e *KafkaExporter) encodeValue(topic string, encoded interface{}) ([]byte, error) {
+	switch v := encoded.(type) {
+	case string:
+		return utils.Slice(v), nil
+	case *common.Record:
+		if e.recordEncoder == nil {
+			return nil, fmt.Errorf("unexpected record of datasource %s", v.DataSource)
+		}
+		return e.recordEncoder.Encode(topic, v)
+	default:
+		return nil, fmt.Errorf("unsupport encoded type %T", encoded)
+	}
+}
+
 func (e *KafkaExporter) exportBatch(queueID int, batch []*sarama.ProducerMessage) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -0,0 +1,138 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafka_exporter
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/deepflowio/deepflow/server/ingester/exporters/common"
+	exporters_cfg "github.com/deepflowio/deepflow/server/ingester/exporters/config"
+	"github.com/deepflowio/deepflow/server/libs/codec"
+)
+
+// ProtobufMessageName converts the data source to CamelCase, e.g.: 'flow_log.l7_flow_log' to 'FlowLogL7FlowLog'
+func ProtobufMessageName(dataSourceId exporters_cfg.DataSourceID) string {
+	sb := strings.Builder{}
+	for _, part := range strings.FieldsFunc(dataSourceId.String(), func(c rune) bool { return c == '_' || c == '.' }) {
+		sb.WriteString(strings.ToUpper(part[:1]))
+		sb.WriteString(part[1:])
+	}
+	return sb.String()
+}
+
+func protobufType(t common.SchemaFieldType) string {
+	switch t {
+	case common.SCHEMA_LONG:
+		return "optional int64"
+	case common.SCHEMA_DOUBLE:
+		return "optional double"
+	case common.SCHEMA_STRING_ARRAY:
+		return "repeated string"
+	case common.SCHEMA_DOUBLE_ARRAY:
+		return "repeated double"
+	default:
+		return "optional string"
+	}
+}
+
+const (
+	// fields missing from protobufFieldNumbers are numbered by the hash of their names in
+	// [PROTOBUF_HASH_FIELD_NUMBER_MIN, protowire.MaxValidNumber], which is above the fixed numbers
+	PROTOBUF_HASH_FIELD_NUMBER_MIN = protowire.Number(1 << 20)
+)
+
+func protobufFieldNumber(name string) protowire.Number {
+	if num, ok := protobufFieldNumbers[name]; ok {
+		return num
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return PROTOBUF_HASH_FIELD_NUMBER_MIN + protowire.Number(h.Sum32()%uint32(protowire.MaxValidNumber-PROTOBUF_HASH_FIELD_NUMBER_MIN+1))
+}
+
+// ProtobufFieldNumbers returns the field numbers of the schema fields, they depend on the field names
+// only, so adding or removing export fields does not renumber others. Fields with conflicting numbers
+// get 0 and are not encoded.
+func ProtobufFieldNumbers(schema *common.Schema) []protowire.Number {
+	numbers := make([]protowire.Number, len(schema.Fields))
+	used := make(map[protowire.Number]string, len(schema.Fields))
+	for i := range schema.Fields {
+		name := schema.Fields[i].Name
+		num := protobufFieldNumber(name)
+		if other, ok := used[num]; ok {
+			log.Warningf("datasource %s protobuf field %s has the same number %d as %s, skip it", schema.DataSource, name, num, other)
+			continue
+		}
+		used[num] = name
+		numbers[i] = num
+	}
+	return numbers
+}
+
+// ProtobufSchema returns the proto3 definition of a record with the field numbers, scalar fields
+// are optional to distinguish null from zero values
+func ProtobufSchema(schema *common.Schema, numbers []protowire.Number) string {
+	sb := strings.Builder{}
+	sb.WriteString("syntax = \"proto3\";\n")
+	sb.WriteString(fmt.Sprintf("package %s;\n\n", SCHEMA_NAMESPACE))
+	sb.WriteString(fmt.Sprintf("message %s {\n", ProtobufMessageName(schema.DataSource)))
+	for i, field := range schema.Fields {
+		if numbers[i] == 0 {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("  %s %s = %d;\n", protobufType(field.Type), field.Name, numbers[i]))
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// AppendProtobuf appends the protobuf binary encoding of record to buf, numbers are from ProtobufFieldNumbers
+func AppendProtobuf(buf []byte, schema *common.Schema, numbers []protowire.Number, record *common.Record) []byte {
+	for i := range schema.Fields {
+		field := &schema.Fields[i]
+		num := numbers[i]
+		if num == 0 || !record.Valid[field.Index] {
+			continue
+		}
+		v := &record.Values[field.Index]
+		switch field.Type {
+		case common.SCHEMA_STRING:
+			buf = codec.AppendProtoString(buf, num, v.String)
+		case common.SCHEMA_LONG:
+			buf = codec.AppendProtoVarint(buf, num, uint64(v.Int64()))
+		case common.SCHEMA_DOUBLE:
+			buf = codec.AppendProtoDouble(buf, num, v.Float64)
+		case common.SCHEMA_STRING_ARRAY:
+			if v.Type != common.FIELD_STRING_SLICE {
+				continue
+			}
+			for _, s := range v.StringSlice {
+				buf = codec.AppendProtoString(buf, num, s)
+			}
+		case common.SCHEMA_DOUBLE_ARRAY:
+			if v.Type != common.FIELD_FLOAT64_SLICE {
+				continue
+			}
+			buf = codec.AppendProtoPackedDoubles(buf, num, v.Float64Slice)
+		}
+	}
+	return buf
+}
@@ -0,0 +1,344 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafka_exporter
+
+import "google.golang.org/protobuf/encoding/protowire"
+
+// protobufFieldNumbers is the fixed field number of each export field name, it is shared by the
+// messages of all data sources. Numbers must never be changed or reused, new fields are appended.
+var protobufFieldNumbers = map[string]protowire.Number{
+	"time":                       1,
+	"ip6":                        2,
+	"ip6_0":                      3,
+	"ip4":                        4,
+	"ip4_0":                      5,
+	"l3_epc_id":                  6,
+	"l3_epc_id_0":                7,
+	"l3_device_id":               8,
+	"l3_device_id_0":             9,
+	"l3_device_type":             10,
+	"l3_device_type_0":           11,
+	"region_id":                  12,
+	"region_id_0":                13,
+	"subnet_id":                  14,
+	"subnet_id_0":                15,
+	"host_id":                    16,
+	"host_id_0":                  17,
+	"pod_node_id":                18,
+	"pod_node_id_0":              19,
+	"az_id":                      20,
+	"az_id_0":                    21,
+	"pod_group_id":               22,
+	"pod_group_id_0":             23,
+	"pod_ns_id":                  24,
+	"pod_ns_id_0":                25,
+	"pod_id":                     26,
+	"pod_id_0":                   27,
+	"pod_cluster_id":             28,
+	"pod_cluster_id_0":           29,
+	"service_id":                 30,
+	"service_id_0":               31,
+	"auto_instance_id":           32,
+	"auto_instance_id_0":         33,
+	"auto_instance_type":         34,
+	"auto_instance_type_0":       35,
+	"auto_service_id":            36,
+	"auto_service_id_0":          37,
+	"auto_service_type":          38,
+	"auto_service_type_0":        39,
+	"gprocess_id":                40,
+	"gprocess_id_0":              41,
+	"ip6_1":                      42,
+	"ip4_1":                      43,
+	"l3_epc_id_1":                44,
+	"l3_device_id_1":             45,
+	"l3_device_type_1":           46,
+	"region_id_1":                47,
+	"subnet_id_1":                48,
+	"host_id_1":                  49,
+	"pod_node_id_1":              50,
+	"az_id_1":                    51,
+	"pod_group_id_1":             52,
+	"pod_ns_id_1":                53,
+	"pod_id_1":                   54,
+	"pod_cluster_id_1":           55,
+	"service_id_1":               56,
+	"auto_instance_id_1":         57,
+	"auto_instance_type_1":       58,
+	"auto_service_id_1":          59,
+	"auto_service_type_1":        60,
+	"gprocess_id_1":              61,
+	"role":                       62,
+	"protocol":                   63,
+	"server_port":                64,
+	"agent_id":                   65,
+	"org_id":                     66,
+	"team_id":                    67,
+	"capture_nic":                68,
+	"capture_nic_type":           69,
+	"nat_source":                 70,
+	"tunnel_type":                71,
+	"observation_point":          72,
+	"capture_network_type_id":    73,
+	"is_ipv4":                    74,
+	"l7_protocol":                75,
+	"app_service":                76,
+	"app_instance":               77,
+	"endpoint":                   78,
+	"biz_type":                   79,
+	"signal_source":              80,
+	"country_0":                  81,
+	"country_1":                  82,
+	"subdivision_0":              83,
+	"subdivision_1":              84,
+	"city_0":                     85,
+	"city_1":                     86,
+	"asn_0":                      87,
+	"asn_1":                      88,
+	"as_org_0":                   89,
+	"as_org_1":                   90,
+	"request":                    91,
+	"response":                   92,
+	"direction_score":            93,
+	"rrt_max":                    94,
+	"rrt_sum":                    95,
+	"rrt_count":                  96,
+	"client_error":               97,
+	"server_error":               98,
+	"timeout":                    99,
+	"packet_tx":                  100,
+	"packet_rx":                  101,
+	"byte_tx":                    102,
+	"byte_rx":                    103,
+	"l3_byte_tx":                 104,
+	"l3_byte_rx":                 105,
+	"l4_byte_tx":                 106,
+	"l4_byte_rx":                 107,
+	"new_flow":                   108,
+	"closed_flow":                109,
+	"l7_request":                 110,
+	"l7_response":                111,
+	"syn_count":                  112,
+	"synack_count":               113,
+	"rtt_max":                    114,
+	"rtt_client_max":             115,
+	"rtt_server_max":             116,
+	"srt_max":                    117,
+	"art_max":                    118,
+	"cit_max":                    119,
+	"rtt_sum":                    120,
+	"rtt_client_sum":             121,
+	"rtt_server_sum":             122,
+	"srt_sum":                    123,
+	"art_sum":                    124,
+	"cit_sum":                    125,
+	"rtt_count":                  126,
+	"rtt_client_count":           127,
+	"rtt_server_count":           128,
+	"srt_count":                  129,
+	"art_count":                  130,
+	"cit_count":                  131,
+	"retrans_tx":                 132,
+	"retrans_rx":                 133,
+	"zero_win_tx":                134,
+	"zero_win_rx":                135,
+	"retrans_syn":                136,
+	"retrans_synack":             137,
+	"client_rst_flow":            138,
+	"server_rst_flow":            139,
+	"server_syn_miss":            140,
+	"client_ack_miss":            141,
+	"client_half_close_flow":     142,
+	"server_half_close_flow":     143,
+	"client_source_port_reuse":   144,
+	"client_establish_other_rst": 145,
+	"server_reset":               146,
+	"server_queue_lack":          147,
+	"server_establish_other_rst": 148,
+	"tcp_timeout":                149,
+	"client_establish_fail":      150,
+	"server_establish_fail":      151,
+	"tcp_establish_fail":         152,
+	"tcp_transfer_fail":          153,
+	"tcp_rst_fail":               154,
+	"l7_client_error":            155,
+	"l7_server_error":            156,
+	"l7_timeout":                 157,
+	"flow_load":                  158,
+	"_id":                        159,
+	"mac_0":                      160,
+	"mac_1":                      161,
+	"eth_type":                   162,
+	"vlan":                       163,
+	"tunnel_tier":                164,
+	"tunnel_tx_id":               165,
+	"tunnel_rx_id":               166,
+	"tunnel_tx_ip4_0":            167,
+	"tunnel_tx_ip4_1":            168,
+	"tunnel_rx_ip4_0":            169,
+	"tunnel_rx_ip4_1":            170,
+	"tunnel_tx_ip6_0":            171,
+	"tunnel_tx_ip6_1":            172,
+	"tunnel_rx_ip6_0":            173,
+	"tunnel_rx_ip6_1":            174,
+	"tunnel_is_ipv4":             175,
+	"tunnel_tx_mac_0":            176,
+	"tunnel_tx_mac_1":            177,
+	"tunnel_rx_mac_0":            178,
+	"tunnel_rx_mac_1":            179,
+	"client_port":                180,
+	"tcp_flags_bit_0":            181,
+	"tcp_flags_bit_1":            182,
+	"syn_seq":                    183,
+	"syn_ack_seq":                184,
+	"last_keepalive_seq":         185,
+	"last_keepalive_ack":         186,
+	"province_0":                 187,
+	"province_1":                 188,
+	"pod_group_type_0":           189,
+	"pod_group_type_1":           190,
+	"epc_id_0":                   191,
+	"epc_id_1":                   192,
+	"close_type":                 193,
+	"flow_id":                    194,
+	"l2_end_0":                   195,
+	"l2_end_1":                   196,
+	"l3_end_0":                   197,
+	"l3_end_1":                   198,
+	"start_time":                 199,
+	"end_time":                   200,
+	"duration":                   201,
+	"is_new_flow":                202,
+	"status":                     203,
+	"nat_real_ip_0":              204,
+	"nat_real_ip_1":              205,
+	"nat_real_port_0":            206,
+	"nat_real_port_1":            207,
+	"request_domain":             208,
+	"total_packet_tx":            209,
+	"total_packet_rx":            210,
+	"total_byte_tx":              211,
+	"total_byte_rx":              212,
+	"l7_parse_failed":            213,
+	"rtt":                        214,
+	"rtt_client":                 215,
+	"rtt_server":                 216,
+	"tls_rtt":                    217,
+	"l7_server_timeout":          218,
+	"l7_error":                   219,
+	"capture_nic_type_category_": 220,
+	"req_tcp_seq":                221,
+	"resp_tcp_seq":               222,
+	"process_id_0":               223,
+	"process_id_1":               224,
+	"process_kname_0":            225,
+	"process_kname_1":            226,
+	"syscall_trace_id_request":   227,
+	"syscall_trace_id_response":  228,
+	"syscall_thread_0":           229,
+	"syscall_thread_1":           230,
+	"syscall_coroutine_0":        231,
+	"syscall_coroutine_1":        232,
+	"syscall_cap_seq_0":          233,
+	"syscall_cap_seq_1":          234,
+	"l7_protocol_str":            235,
+	"version":                    236,
+	"type":                       237,
+	"is_tls":                     238,
+	"request_type":               239,
+	"request_resource":           240,
+	"end_point":                  241,
+	"request_id":                 242,
+	"response_status":            243,
+	"response_code":              244,
+	"response_exception":         245,
+	"response_result":            246,
+	"http_proxy_client":          247,
+	"x_request_id_0":             248,
+	"x_request_id_1":             249,
+	"trace_id":                   250,
+	"span_id":                    251,
+	"parent_span_id":             252,
+	"span_kind":                  253,
+	"response_duration":          254,
+	"request_length":             255,
+	"response_length":            256,
+	"sql_affected_rows":          257,
+	"captured_request_byte":      258,
+	"captured_response_byte":     259,
+	"attribute_names":            260,
+	"attribute_values":           261,
+	"metrics_names":              262,
+	"metrics_values":             263,
+	"events":                     264,
+	"event_type":                 265,
+	"process_kname":              266,
+	"host_node_id":               267,
+	"bytes":                      268,
+	"l3_epc":                     269,
+	"l3_epc_0":                   270,
+	"l3_device":                  271,
+	"l3_device_0":                272,
+	"region":                     273,
+	"region_0":                   274,
+	"subnet":                     275,
+	"subnet_0":                   276,
+	"host":                       277,
+	"host_0":                     278,
+	"pod_node":                   279,
+	"pod_node_0":                 280,
+	"az":                         281,
+	"az_0":                       282,
+	"pod_group":                  283,
+	"pod_group_0":                284,
+	"pod_ns":                     285,
+	"pod_ns_0":                   286,
+	"pod":                        287,
+	"pod_0":                      288,
+	"pod_cluster":                289,
+	"pod_cluster_0":              290,
+	"service":                    291,
+	"service_0":                  292,
+	"auto_instance":              293,
+	"auto_instance_0":            294,
+	"auto_service":               295,
+	"auto_service_0":             296,
+	"gprocess":                   297,
+	"gprocess_0":                 298,
+	"l3_epc_1":                   299,
+	"l3_device_1":                300,
+	"region_1":                   301,
+	"subnet_1":                   302,
+	"host_1":                     303,
+	"pod_node_1":                 304,
+	"az_1":                       305,
+	"pod_group_1":                306,
+	"pod_ns_1":                   307,
+	"pod_1":                      308,
+	"pod_cluster_1":              309,
+	"service_1":                  310,
+	"auto_instance_1":            311,
+	"auto_service_1":             312,
+	"gprocess_1":                 313,
+	"agent":                      314,
+	"k8s_label_names":            315,
+	"k8s_label_values":           316,
+	"k8s_label_names_0":          317,
+	"k8s_label_values_0":         318,
+	"k8s_label_names_1":          319,
+	"k8s_label_values_1":         320,
+}
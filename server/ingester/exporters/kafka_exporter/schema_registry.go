@@ -0,0 +1,109 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafka_exporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	exporters_cfg "github.com/deepflowio/deepflow/server/ingester/exporters/config"
+)
+
+type SchemaType string
+
+const (
+	SCHEMA_TYPE_AVRO     SchemaType = "AVRO"
+	SCHEMA_TYPE_PROTOBUF SchemaType = "PROTOBUF"
+
+	SCHEMA_REGISTRY_CONTENT_TYPE = "application/vnd.schemaregistry.v1+json"
+)
+
+// SchemaRegistry registers schemas of exported records, implemented by the confluent compatible
+// SchemaRegistryClient, and could be replaced by a local fake in tests
+type SchemaRegistry interface {
+	// Register registers schema under subject and returns the schema id, registering the same schema
+	// again returns the same id
+	Register(subject string, schemaType SchemaType, schema string) (int, error)
+}
+
+type SchemaRegistryClient struct {
+	url      string
+	username string
+	password string
+	client   *http.Client
+}
+
+func NewSchemaRegistryClient(config *exporters_cfg.SchemaRegistry) *SchemaRegistryClient {
+	return &SchemaRegistryClient{
+		url:      strings.TrimSuffix(config.URL, "/"),
+		username: config.Username,
+		password: config.Password,
+		client:   &http.Client{Timeout: time.Duration(config.Timeout) * time.Second},
+	}
+}
+
+type registerSchemaRequest struct {
+	Schema     string     `json:"schema"`
+	SchemaType SchemaType `json:"schemaType,omitempty"`
+}
+
+type registerSchemaResponse struct {
+	ID        int    `json:"id"`
+	ErrorCode int    `json:"error_code"`
+	Message   string `json:"message"`
+}
+
+// Register calls `POST /subjects/{subject}/versions` of the schema registry
+func (c *SchemaRegistryClient) Register(subject string, schemaType SchemaType, schema string) (int, error) {
+	body, err := json.Marshal(&registerSchemaRequest{Schema: schema, SchemaType: schemaType})
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/subjects/%s/versions", c.url, url.PathEscape(subject)), bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", SCHEMA_REGISTRY_CONTENT_TYPE)
+	req.Header.Set("Accept", SCHEMA_REGISTRY_CONTENT_TYPE)
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return 0, err
+	}
+	result := &registerSchemaResponse{}
+	if err := json.Unmarshal(respBody, result); err != nil {
+		return 0, fmt.Errorf("register schema of subject %s returned HTTP status %s: %s", subject, resp.Status, respBody)
+	}
+	if resp.StatusCode >= 400 || result.ErrorCode != 0 {
+		return 0, fmt.Errorf("register schema of subject %s failed, error code %d: %s", subject, result.ErrorCode, result.Message)
+	}
+	return result.ID, nil
+}
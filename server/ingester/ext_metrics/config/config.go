@@ -32,6 +32,9 @@ const (
 	DefaultDecoderQueueCount = 2
 	DefaultDecoderQueueSize  = 1 << 17
 	DefaultExtMetricsTTL     = 168 // hour
+
+	DefaultOTelDeltaExpiry    = 600 // s
+	DefaultOTelDeltaMaxSeries = 1 << 20
 )
 
 type Config struct {
@@ -40,6 +43,9 @@ type Config struct {
 	DecoderQueueCount int                   `yaml:"ext-metrics-decoder-queue-count"`
 	DecoderQueueSize  int                   `yaml:"ext-metrics-decoder-queue-size"`
 	TTL               int                   `yaml:"ext-metrics-ttl-hour"`
+
+	OTelDeltaExpiry    int `yaml:"ext-metrics-otel-delta-expiry"` // s
+	OTelDeltaMaxSeries int `yaml:"ext-metrics-otel-delta-max-series"`
 }
 
 type ExtMetricsConfig struct {
@@ -53,6 +59,12 @@ func (c *Config) Validate() error {
 	if c.TTL <= 0 {
 		c.TTL = DefaultExtMetricsTTL
 	}
+	if c.OTelDeltaExpiry <= 0 {
+		c.OTelDeltaExpiry = DefaultOTelDeltaExpiry
+	}
+	if c.OTelDeltaMaxSeries <= 0 {
+		c.OTelDeltaMaxSeries = DefaultOTelDeltaMaxSeries
+	}
 
 	return nil
 }
@@ -60,11 +72,13 @@ func (c *Config) Validate() error {
 func Load(base *config.Config, path string) *Config {
 	config := &ExtMetricsConfig{
 		ExtMetrics: Config{
-			Base:              base,
-			DecoderQueueCount: DefaultDecoderQueueCount,
-			DecoderQueueSize:  DefaultDecoderQueueSize,
-			CKWriterConfig:    config.CKWriterConfig{QueueCount: 1, QueueSize: 100000, BatchSize: 51200, FlushTimeout: 10},
-			TTL:               DefaultExtMetricsTTL,
+			Base:               base,
+			DecoderQueueCount:  DefaultDecoderQueueCount,
+			DecoderQueueSize:   DefaultDecoderQueueSize,
+			CKWriterConfig:     config.CKWriterConfig{QueueCount: 1, QueueSize: 100000, BatchSize: 51200, FlushTimeout: 10},
+			TTL:                DefaultExtMetricsTTL,
+			OTelDeltaExpiry:    DefaultOTelDeltaExpiry,
+			OTelDeltaMaxSeries: DefaultOTelDeltaMaxSeries,
 		},
 	}
 	if _, err := os.Stat(path); os.IsNotExist(err) {
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"net"
 	"strconv"
+	"time"
 
 	"github.com/influxdata/influxdb/models"
 	logging "github.com/op/go-logging"
@@ -53,6 +54,7 @@ type Counter struct {
 	ErrorCount             int64 `statsd:"err-count"`
 	ErrMetrics             int64 `statsd:"err-metrics"`
 	DropUnsupportedMetrics int64 `statsd:"drop-unsupported-metrics"`
+	DropOTelDataPoints     int64 `statsd:"drop-otel-data-points"` // duplicated delta points, or delta series exceed the limit
 }
 
 type Decoder struct {
@@ -72,6 +74,8 @@ type Decoder struct {
 
 	orgId, teamId uint16
 
+	otelConverter *otelConverter
+
 	counter *Counter
 	utils.Closable
 }
@@ -82,6 +86,7 @@ func NewDecoder(
 	inQueue queue.QueueReader,
 	extMetricsWriters [dbwriter.MAX_DB_ID]*dbwriter.ExtMetricsWriter,
 	config *config.Config,
+	otelDeltaAccumulator *OTelDeltaAccumulator, // only used by OpenTelemetry metrics decoders
 ) *Decoder {
 	d := &Decoder{
 		index:             index,
@@ -98,6 +103,9 @@ func NewDecoder(
 		d.instanceIPToUniversalTag[i] = make(map[string]*flow_metrics.UniversalTag)
 		d.vtapIDToUniversalTag[i] = make(map[uint16]*flow_metrics.UniversalTag)
 	}
+	if otelDeltaAccumulator != nil {
+		d.otelConverter = newOTelConverter(otelDeltaAccumulator)
+	}
 	return d
 }
 
@@ -119,9 +127,16 @@ func (d *Decoder) Run() {
 		n := d.inQueue.Gets(buffer)
 		for i := 0; i < n; i++ {
 			if buffer[i] == nil {
+				if d.otelConverter != nil {
+					d.otelConverter.accumulator.expire(time.Now())
+				}
 				continue
 			}
 			d.counter.InCount++
+			if otelMetrics, ok := buffer[i].(*OTelMetrics); ok {
+				d.handleOTelMetricsFromReceiver(otelMetrics)
+				continue
+			}
 			recvBytes, ok := buffer[i].(*receiver.RecvBuffer)
 			if !ok {
 				log.Warning("get decode queue data type wrong")
@@ -133,6 +148,8 @@ func (d *Decoder) Run() {
 				d.handleTelegraf(recvBytes.VtapID, decoder)
 			} else if d.msgType == datatype.MESSAGE_TYPE_DFSTATS || d.msgType == datatype.MESSAGE_TYPE_SERVER_DFSTATS {
 				d.handleDeepflowStats(recvBytes.VtapID, decoder)
+			} else if d.msgType == datatype.MESSAGE_TYPE_OPENTELEMETRY_METRICS {
+				d.handleOTelMetricsFromAgent(recvBytes.VtapID, decoder)
 			}
 			receiver.ReleaseRecvBuffer(recvBytes)
 		}
@@ -247,17 +264,7 @@ func (d *Decoder) fillExtMetricsBase(m *dbwriter.ExtMetrics, vtapID uint16, podN
 	var universalTag *flow_metrics.UniversalTag
 
 	// fast path
-	platformDataVersion := d.platformData.Version(m.OrgId)
-	if platformDataVersion != d.platformDataVersion[m.OrgId] {
-		if d.platformDataVersion[m.OrgId] != 0 {
-			log.Infof("platform data version in ext-metrics-decoder %s-#%d changed from %d to %d",
-				d.msgType, d.index, d.platformDataVersion, platformDataVersion)
-		}
-		d.platformDataVersion[m.OrgId] = platformDataVersion
-		d.podNameToUniversalTag[m.OrgId] = make(map[string]*flow_metrics.UniversalTag)
-		d.instanceIPToUniversalTag[m.OrgId] = make(map[string]*flow_metrics.UniversalTag)
-		d.vtapIDToUniversalTag[m.OrgId] = make(map[uint16]*flow_metrics.UniversalTag)
-	} else {
+	if !d.updatePlatformDataVersion(m.OrgId) {
 		if podName != "" {
 			universalTag, _ = d.podNameToUniversalTag[m.OrgId][podName]
 		} else if fillWithVtapId {
@@ -282,6 +289,23 @@ func (d *Decoder) fillExtMetricsBase(m *dbwriter.ExtMetrics, vtapID uint16, podN
 	}
 }
 
+// updatePlatformDataVersion clears the universal tag caches of the org when its platform data changes, returns whether it changed
+func (d *Decoder) updatePlatformDataVersion(orgId uint16) bool {
+	platformDataVersion := d.platformData.Version(orgId)
+	if platformDataVersion == d.platformDataVersion[orgId] {
+		return false
+	}
+	if d.platformDataVersion[orgId] != 0 {
+		log.Infof("platform data version in ext-metrics-decoder %s-#%d changed from %d to %d",
+			d.msgType, d.index, d.platformDataVersion, platformDataVersion)
+	}
+	d.platformDataVersion[orgId] = platformDataVersion
+	d.podNameToUniversalTag[orgId] = make(map[string]*flow_metrics.UniversalTag)
+	d.instanceIPToUniversalTag[orgId] = make(map[string]*flow_metrics.UniversalTag)
+	d.vtapIDToUniversalTag[orgId] = make(map[uint16]*flow_metrics.UniversalTag)
+	return true
+}
+
 func (d *Decoder) fillExtMetricsBaseSlow(m *dbwriter.ExtMetrics, vtapID uint16, podName string, fillWithVtapId bool) {
 	t := &m.UniversalTag
 	t.VTAPID = vtapID
@@ -303,7 +327,10 @@ func (d *Decoder) fillExtMetricsBaseSlow(m *dbwriter.ExtMetrics, vtapID uint16,
 			t.PodClusterID = uint16(vtapInfo.PodClusterId)
 		}
 	}
+	d.fillUniversalTagWithIP(m.OrgId, t, ip)
+}
 
+func (d *Decoder) fillUniversalTagWithIP(orgId uint16, t *flow_metrics.UniversalTag, ip net.IP) {
 	if ip != nil {
 		if ip4 := ip.To4(); ip4 != nil {
 			t.IsIPv6 = 0
@@ -318,9 +345,9 @@ func (d *Decoder) fillExtMetricsBaseSlow(m *dbwriter.ExtMetrics, vtapID uint16,
 
 	var info *grpc.Info
 	if t.IsIPv6 == 1 {
-		info = d.platformData.QueryIPV6Infos(m.OrgId, t.L3EpcID, t.IP6)
+		info = d.platformData.QueryIPV6Infos(orgId, t.L3EpcID, t.IP6)
 	} else {
-		info = d.platformData.QueryIPV4Infos(m.OrgId, t.L3EpcID, t.IP)
+		info = d.platformData.QueryIPV4Infos(orgId, t.L3EpcID, t.IP)
 	}
 	if info != nil {
 		t.RegionID = uint16(info.RegionID)
@@ -341,7 +368,7 @@ func (d *Decoder) fillExtMetricsBaseSlow(m *dbwriter.ExtMetrics, vtapID uint16,
 		}
 
 		if common.IsPodServiceIP(t.L3DeviceType, t.PodID, t.PodNodeID) {
-			t.ServiceID = d.platformData.QueryService(m.OrgId, t.PodID, t.PodNodeID, uint32(t.PodClusterID), t.PodGroupID, t.L3EpcID, t.IsIPv6 == 1, t.IP, t.IP6, 0, 0)
+			t.ServiceID = d.platformData.QueryService(orgId, t.PodID, t.PodNodeID, uint32(t.PodClusterID), t.PodGroupID, t.L3EpcID, t.IsIPv6 == 1, t.IP, t.IP6, 0, 0)
 		}
 		t.AutoInstanceID, t.AutoInstanceType = common.GetAutoInstance(t.PodID, t.GPID, t.PodNodeID, t.L3DeviceID, uint32(t.SubnetID), uint8(t.L3DeviceType), t.L3EpcID)
 		t.AutoServiceID, t.AutoServiceType = common.GetAutoService(t.ServiceID, t.PodGroupID, t.GPID, uint32(t.PodClusterID), t.L3DeviceID, uint32(t.SubnetID), uint8(t.L3DeviceType), podGroupType, t.L3EpcID)
@@ -0,0 +1,189 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package decoder
+
+import (
+	"net"
+	"strconv"
+	"time"
+
+	collectormetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonv1 "go.opentelemetry.io/proto/otlp/common/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/deepflowio/deepflow/server/ingester/ext_metrics/dbwriter"
+	"github.com/deepflowio/deepflow/server/ingester/pkg/otlpreceiver"
+	"github.com/deepflowio/deepflow/server/libs/ckdb"
+	"github.com/deepflowio/deepflow/server/libs/codec"
+	"github.com/deepflowio/deepflow/server/libs/datatype"
+	flow_metrics "github.com/deepflowio/deepflow/server/libs/flow-metrics"
+	"github.com/deepflowio/deepflow/server/libs/utils"
+)
+
+const (
+	VTABLE_PREFIX_OTEL = "otel."
+
+	OTEL_POD_NAME = "k8s.pod.name"
+)
+
+// OTelMetrics is an OTLP metrics request received by the otlp receiver of ingester
+type OTelMetrics struct {
+	Source  *otlpreceiver.Source
+	Request *collectormetrics.ExportMetricsServiceRequest
+}
+
+// otelResource is the info used to look up platform data, extracted from resource attributes
+type otelResource struct {
+	podName string
+	ip      net.IP
+}
+
+func parseOTelResource(attributes []*commonv1.KeyValue) *otelResource {
+	r := &otelResource{ip: otlpreceiver.ResourceIP(attributes)}
+	for _, attr := range attributes {
+		if attr.Key == OTEL_POD_NAME {
+			r.podName = attr.Value.GetStringValue()
+			break
+		}
+	}
+	return r
+}
+
+func (d *Decoder) handleOTelMetricsFromAgent(vtapID uint16, decoder *codec.SimpleDecoder) {
+	for !decoder.IsEnd() {
+		bytes := decoder.ReadBytes()
+		if decoder.Failed() {
+			if d.counter.ErrorCount == 0 {
+				log.Errorf("OpenTelemetry metrics decode failed, offset=%d len=%d", decoder.Offset(), len(decoder.Bytes()))
+			}
+			d.counter.ErrorCount++
+			return
+		}
+		request := &collectormetrics.ExportMetricsServiceRequest{}
+		if err := proto.Unmarshal(bytes, request); err != nil {
+			if d.counter.ErrorCount == 0 {
+				log.Warningf("OpenTelemetry metrics parse failed, err msg: %s", err)
+			}
+			d.counter.ErrorCount++
+			continue
+		}
+		d.handleOTelMetrics(vtapID, d.orgId, d.teamId, nil, request)
+	}
+}
+
+func (d *Decoder) handleOTelMetricsFromReceiver(m *OTelMetrics) {
+	d.handleOTelMetrics(0, m.Source.OrgId, ckdb.INVALID_TEAM_ID, m.Source, m.Request)
+}
+
+// handleOTelMetrics writes each data point of the request to ext_metrics, source is nil when the data comes from agents
+func (d *Decoder) handleOTelMetrics(vtapID, orgId, teamId uint16, source *otlpreceiver.Source, request *collectormetrics.ExportMetricsServiceRequest) {
+	if d.debugEnabled {
+		log.Debugf("decoder %d vtap %d recv OpenTelemetry metrics: %v", d.index, vtapID, request)
+	}
+	now := time.Now()
+	for _, resourceMetrics := range request.ResourceMetrics {
+		attributes := resourceMetrics.Resource.GetAttributes()
+		base := dbwriter.AcquireExtMetrics()
+		base.OrgId, base.TeamID = orgId, teamId
+		d.fillOTelMetricsBase(base, vtapID, source, parseOTelResource(attributes))
+
+		resourceNames := make([]string, 0, len(attributes))
+		resourceValues := make([]string, 0, len(attributes))
+		for _, attr := range attributes {
+			resourceNames = append(resourceNames, attr.Key)
+			resourceValues = append(resourceValues, otlpreceiver.ValueString(attr.Value))
+		}
+		seriesPrefix := strconv.Itoa(int(orgId)) + "/" + strconv.Itoa(int(vtapID)) + "/"
+		if source != nil && source.IP != nil {
+			seriesPrefix += source.IP.String() + "/"
+		}
+
+		for _, scopeMetrics := range resourceMetrics.ScopeMetrics {
+			for _, metric := range scopeMetrics.Metrics {
+				if metric.Name == "" {
+					d.counter.ErrMetrics++
+					continue
+				}
+				vtableName := VTABLE_PREFIX_OTEL + metric.Name
+				d.counter.DropOTelDataPoints += int64(d.otelConverter.convert(metric, seriesPrefix, resourceNames, resourceValues, now,
+					func(timestamp uint32, tagNames, tagValues, metricsNames []string, metricsValues []float64) {
+						m := dbwriter.AcquireExtMetrics()
+						m.Timestamp = timestamp
+						m.MsgType = datatype.MESSAGE_TYPE_OPENTELEMETRY_METRICS
+						m.VTableName = vtableName
+						m.OrgId, m.TeamID = base.OrgId, base.TeamID
+						m.UniversalTag = base.UniversalTag
+						m.TagNames = append(m.TagNames, tagNames...)
+						m.TagValues = append(m.TagValues, tagValues...)
+						m.MetricsFloatNames = append(m.MetricsFloatNames, metricsNames...)
+						m.MetricsFloatValues = append(m.MetricsFloatValues, metricsValues...)
+						d.extMetricsWriters[int(dbwriter.EXT_METRICS_DB_ID)].Write(m)
+						d.counter.OutCount++
+					}))
+			}
+		}
+		dbwriter.ReleaseExtMetrics(base)
+	}
+}
+
+// fillOTelMetricsBase looks up platform data with resource attributes like OTel traces: by pod name first, then by
+// the instance ip, and finally by the agent itself or the sender address
+func (d *Decoder) fillOTelMetricsBase(m *dbwriter.ExtMetrics, vtapID uint16, source *otlpreceiver.Source, r *otelResource) {
+	if vtapID != 0 && r.podName != "" {
+		d.fillExtMetricsBase(m, vtapID, r.podName, false)
+		if m.UniversalTag.PodID != 0 {
+			return
+		}
+	}
+
+	ip := r.ip
+	if ip == nil && source != nil {
+		ip = source.IP
+	}
+	if ip == nil {
+		if vtapID != 0 {
+			d.fillExtMetricsBase(m, vtapID, "", true)
+		}
+		return
+	}
+
+	var l3EpcID int32
+	if source != nil {
+		l3EpcID = source.L3EpcID
+	} else if ip4 := ip.To4(); ip4 != nil {
+		l3EpcID = d.platformData.QueryVtapEpc1(m.OrgId, vtapID, true, utils.IpToUint32(ip4), nil)
+	} else {
+		l3EpcID = d.platformData.QueryVtapEpc1(m.OrgId, vtapID, false, 0, ip)
+	}
+	d.fillExtMetricsBaseWithIP(m, vtapID, l3EpcID, ip)
+}
+
+func (d *Decoder) fillExtMetricsBaseWithIP(m *dbwriter.ExtMetrics, vtapID uint16, l3EpcID int32, ip net.IP) {
+	d.updatePlatformDataVersion(m.OrgId)
+	key := strconv.Itoa(int(vtapID)) + "-" + strconv.Itoa(int(l3EpcID)) + "-" + ip.String()
+	if universalTag, ok := d.instanceIPToUniversalTag[m.OrgId][key]; ok {
+		m.UniversalTag = *universalTag
+		return
+	}
+
+	m.UniversalTag = flow_metrics.UniversalTag{VTAPID: vtapID, L3EpcID: l3EpcID}
+	d.fillUniversalTagWithIP(m.OrgId, &m.UniversalTag, ip)
+
+	universalTag := &flow_metrics.UniversalTag{}
+	*universalTag = m.UniversalTag
+	d.instanceIPToUniversalTag[m.OrgId][key] = universalTag
+}
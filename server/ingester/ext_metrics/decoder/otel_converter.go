@@ -0,0 +1,493 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package decoder
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	commonv1 "go.opentelemetry.io/proto/otlp/common/v1"
+	metricsv1 "go.opentelemetry.io/proto/otlp/metrics/v1"
+
+	"github.com/deepflowio/deepflow/server/ingester/pkg/otlpreceiver"
+)
+
+const (
+	OTEL_METRICS_VALUE  = "value"
+	OTEL_METRICS_COUNT  = "count"
+	OTEL_METRICS_SUM    = "sum"
+	OTEL_METRICS_MIN    = "min"
+	OTEL_METRICS_MAX    = "max"
+	OTEL_METRICS_BUCKET = "bucket"
+
+	OTEL_TAG_LE       = "le"
+	OTEL_TAG_QUANTILE = "quantile"
+
+	OTEL_DELTA_EXPIRE_INTERVAL = time.Minute
+)
+
+// otelEmitter receives a row converted from OTel metrics, the slices are reused after it returns
+type otelEmitter func(timestamp uint32, tagNames, tagValues, metricsNames []string, metricsValues []float64)
+
+// otelHistogram is the common form of histograms and exponential histograms, bucket counts are not cumulative
+type otelHistogram struct {
+	count          uint64
+	sum, min, max  float64
+	hasSum         bool
+	hasMin, hasMax bool
+
+	bounds []float64 // upper bounds in ascending order
+	counts []uint64
+}
+
+func (h *otelHistogram) appendBucket(bound float64, count uint64) {
+	if n := len(h.bounds); n > 0 && bound <= h.bounds[n-1] {
+		h.counts[n-1] += count
+		return
+	}
+	h.bounds = append(h.bounds, bound)
+	h.counts = append(h.counts, count)
+}
+
+func (h *otelHistogram) merge(o *otelHistogram) {
+	h.count += o.count
+	if o.hasSum {
+		h.sum += o.sum
+		h.hasSum = true
+	}
+	if o.hasMin && (!h.hasMin || o.min < h.min) {
+		h.min, h.hasMin = o.min, true
+	}
+	if o.hasMax && (!h.hasMax || o.max > h.max) {
+		h.max, h.hasMax = o.max, true
+	}
+
+	if equalBounds(h.bounds, o.bounds) {
+		for i := range o.counts {
+			h.counts[i] += o.counts[i]
+		}
+		return
+	}
+	// the bucket layout changed (e.g. scale of exponential histogram), merge the buckets by bound
+	bounds := make([]float64, 0, len(h.bounds)+len(o.bounds))
+	counts := make([]uint64, 0, len(h.bounds)+len(o.bounds))
+	i, j := 0, 0
+	for i < len(h.bounds) || j < len(o.bounds) {
+		switch {
+		case j == len(o.bounds) || (i < len(h.bounds) && h.bounds[i] < o.bounds[j]):
+			bounds, counts = append(bounds, h.bounds[i]), append(counts, h.counts[i])
+			i++
+		case i == len(h.bounds) || o.bounds[j] < h.bounds[i]:
+			bounds, counts = append(bounds, o.bounds[j]), append(counts, o.counts[j])
+			j++
+		default:
+			bounds, counts = append(bounds, h.bounds[i]), append(counts, h.counts[i]+o.counts[j])
+			i++
+			j++
+		}
+	}
+	h.bounds, h.counts = bounds, counts
+}
+
+func (h *otelHistogram) clone() *otelHistogram {
+	c := *h
+	c.bounds = append([]float64(nil), h.bounds...)
+	c.counts = append([]uint64(nil), h.counts...)
+	return &c
+}
+
+func equalBounds(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func newOTelHistogram(count uint64, sum, min, max *float64) *otelHistogram {
+	h := &otelHistogram{count: count}
+	if sum != nil {
+		h.sum, h.hasSum = *sum, true
+	}
+	if min != nil {
+		h.min, h.hasMin = *min, true
+	}
+	if max != nil {
+		h.max, h.hasMax = *max, true
+	}
+	return h
+}
+
+func explicitHistogram(p *metricsv1.HistogramDataPoint) *otelHistogram {
+	h := newOTelHistogram(p.Count, p.Sum, p.Min, p.Max)
+	// bucket_counts has one more element than explicit_bounds, the last one is for (bounds[n-1], +Inf)
+	if len(p.BucketCounts) == 0 || len(p.BucketCounts) != len(p.ExplicitBounds)+1 {
+		return h
+	}
+	for i, count := range p.BucketCounts {
+		bound := math.Inf(1)
+		if i < len(p.ExplicitBounds) {
+			bound = p.ExplicitBounds[i]
+		}
+		h.appendBucket(bound, count)
+	}
+	return h
+}
+
+// exponentialHistogram converts the exponential buckets to explicit bounds, bucket index i covers
+// (base^i, base^(i+1)] for positive values and [-base^(i+1), -base^i) for negative values, where
+// base = 2^(2^-scale). Empty buckets are omitted since they do not change the cumulative counts.
+func exponentialHistogram(p *metricsv1.ExponentialHistogramDataPoint) *otelHistogram {
+	h := newOTelHistogram(p.Count, p.Sum, p.Min, p.Max)
+	// base^i is calculated as 2^(i*2^-scale) to keep exact bounds for powers of 2
+	exponent := math.Exp2(-float64(p.Scale))
+	var total uint64
+	if negative := p.Negative; negative != nil {
+		for k := len(negative.BucketCounts) - 1; k >= 0; k-- {
+			if count := negative.BucketCounts[k]; count > 0 {
+				h.appendBucket(-math.Exp2((float64(negative.Offset)+float64(k))*exponent), count)
+				total += count
+			}
+		}
+	}
+	if p.ZeroCount > 0 {
+		h.appendBucket(p.ZeroThreshold, p.ZeroCount)
+		total += p.ZeroCount
+	}
+	if positive := p.Positive; positive != nil {
+		for k, count := range positive.BucketCounts {
+			if count > 0 {
+				h.appendBucket(math.Exp2((float64(positive.Offset)+float64(k)+1)*exponent), count)
+				total += count
+			}
+		}
+	}
+	if len(h.bounds) > 0 {
+		var remain uint64
+		if p.Count > total {
+			remain = p.Count - total
+		}
+		h.appendBucket(math.Inf(1), remain)
+	}
+	return h
+}
+
+type otelDeltaSeries struct {
+	lastTimeUnixNano uint64
+	lastSeen         time.Time
+
+	sum       float64
+	histogram *otelHistogram
+}
+
+// OTelDeltaAccumulator accumulates delta sums and histograms to cumulative ones, since counters are queried
+// as cumulative values like Prometheus. It is shared by the decoders, as the data points of a series may be
+// distributed to any of them.
+type OTelDeltaAccumulator struct {
+	sync.Mutex
+	series         map[string]*otelDeltaSeries
+	expiry         time.Duration
+	maxSeries      int
+	lastExpireTime time.Time
+}
+
+func NewOTelDeltaAccumulator(expiry time.Duration, maxSeries int) *OTelDeltaAccumulator {
+	return &OTelDeltaAccumulator{
+		series:    make(map[string]*otelDeltaSeries),
+		expiry:    expiry,
+		maxSeries: maxSeries,
+	}
+}
+
+// getSeries returns nil if the point is duplicated, or the series count exceeds the limit. Points out of order
+// (e.g. handled by another decoder concurrently) are still accumulated.
+func (a *OTelDeltaAccumulator) getSeries(key string, timeUnixNano uint64, now time.Time) *otelDeltaSeries {
+	s := a.series[key]
+	if s == nil {
+		if len(a.series) >= a.maxSeries {
+			return nil
+		}
+		s = &otelDeltaSeries{}
+		a.series[key] = s
+	} else if timeUnixNano == s.lastTimeUnixNano {
+		return nil
+	}
+	if timeUnixNano > s.lastTimeUnixNano {
+		s.lastTimeUnixNano = timeUnixNano
+	}
+	s.lastSeen = now
+	return s
+}
+
+func (a *OTelDeltaAccumulator) accumulateSum(key string, timeUnixNano uint64, value float64, now time.Time) (float64, bool) {
+	a.Lock()
+	defer a.Unlock()
+	s := a.getSeries(key, timeUnixNano, now)
+	if s == nil {
+		return 0, false
+	}
+	s.sum += value
+	return s.sum, true
+}
+
+// accumulateHistogram takes over h, and returns a copy of the cumulative histogram
+func (a *OTelDeltaAccumulator) accumulateHistogram(key string, timeUnixNano uint64, h *otelHistogram, now time.Time) (*otelHistogram, bool) {
+	a.Lock()
+	defer a.Unlock()
+	s := a.getSeries(key, timeUnixNano, now)
+	if s == nil {
+		return nil, false
+	}
+	if s.histogram == nil {
+		s.histogram = h
+	} else {
+		s.histogram.merge(h)
+	}
+	return s.histogram.clone(), true
+}
+
+// expire removes the series not updated for a while
+func (a *OTelDeltaAccumulator) expire(now time.Time) {
+	a.Lock()
+	defer a.Unlock()
+	if now.Sub(a.lastExpireTime) < OTEL_DELTA_EXPIRE_INTERVAL {
+		return
+	}
+	a.lastExpireTime = now
+	for key, s := range a.series {
+		if now.Sub(s.lastSeen) > a.expiry {
+			delete(a.series, key)
+		}
+	}
+}
+
+// otelConverter converts the data points of OTel metrics to ext_metrics rows, each decoder has its own
+type otelConverter struct {
+	accumulator *OTelDeltaAccumulator
+
+	// buffers reused between rows
+	tagNames      []string
+	tagValues     []string
+	metricsNames  []string
+	metricsValues []float64
+	keyBuilder    strings.Builder
+}
+
+func newOTelConverter(accumulator *OTelDeltaAccumulator) *otelConverter {
+	return &otelConverter{accumulator: accumulator}
+}
+
+// convert emits the rows of the metric, returns the count of data points dropped by delta accumulation.
+// seriesPrefix distinguishes the series of different senders.
+func (c *otelConverter) convert(metric *metricsv1.Metric, seriesPrefix string, resourceNames, resourceValues []string, now time.Time, emit otelEmitter) int {
+	dropped := 0
+	switch data := metric.Data.(type) {
+	case *metricsv1.Metric_Gauge:
+		for _, p := range data.Gauge.DataPoints {
+			value, ok := numberValue(p)
+			if !ok {
+				continue
+			}
+			c.setTags(resourceNames, resourceValues, p.Attributes)
+			c.emitValue(otelTimestamp(p.TimeUnixNano, now), value, emit)
+		}
+	case *metricsv1.Metric_Sum:
+		delta := data.Sum.AggregationTemporality == metricsv1.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA
+		for _, p := range data.Sum.DataPoints {
+			value, ok := numberValue(p)
+			if !ok {
+				continue
+			}
+			c.setTags(resourceNames, resourceValues, p.Attributes)
+			if delta {
+				if value, ok = c.accumulator.accumulateSum(c.seriesKey(seriesPrefix, metric.Name), p.TimeUnixNano, value, now); !ok {
+					dropped++
+					continue
+				}
+			}
+			c.emitValue(otelTimestamp(p.TimeUnixNano, now), value, emit)
+		}
+	case *metricsv1.Metric_Histogram:
+		delta := data.Histogram.AggregationTemporality == metricsv1.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA
+		for _, p := range data.Histogram.DataPoints {
+			if noRecordedValue(p.Flags) {
+				continue
+			}
+			c.setTags(resourceNames, resourceValues, p.Attributes)
+			if !c.convertHistogram(seriesPrefix, metric.Name, delta, p.TimeUnixNano, explicitHistogram(p), now, emit) {
+				dropped++
+			}
+		}
+	case *metricsv1.Metric_ExponentialHistogram:
+		delta := data.ExponentialHistogram.AggregationTemporality == metricsv1.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA
+		for _, p := range data.ExponentialHistogram.DataPoints {
+			if noRecordedValue(p.Flags) {
+				continue
+			}
+			c.setTags(resourceNames, resourceValues, p.Attributes)
+			if !c.convertHistogram(seriesPrefix, metric.Name, delta, p.TimeUnixNano, exponentialHistogram(p), now, emit) {
+				dropped++
+			}
+		}
+	case *metricsv1.Metric_Summary:
+		// summaries are always cumulative
+		for _, p := range data.Summary.DataPoints {
+			if noRecordedValue(p.Flags) {
+				continue
+			}
+			c.setTags(resourceNames, resourceValues, p.Attributes)
+			c.emitSummary(otelTimestamp(p.TimeUnixNano, now), p, emit)
+		}
+	}
+	return dropped
+}
+
+func (c *otelConverter) convertHistogram(seriesPrefix, name string, delta bool, timeUnixNano uint64, h *otelHistogram, now time.Time, emit otelEmitter) bool {
+	if delta {
+		var ok bool
+		if h, ok = c.accumulator.accumulateHistogram(c.seriesKey(seriesPrefix, name), timeUnixNano, h, now); !ok {
+			return false
+		}
+	}
+	c.emitHistogram(otelTimestamp(timeUnixNano, now), h, emit)
+	return true
+}
+
+// setTags merges resource attributes and data point attributes, the latter takes precedence
+func (c *otelConverter) setTags(resourceNames, resourceValues []string, attributes []*commonv1.KeyValue) {
+	c.tagNames = append(c.tagNames[:0], resourceNames...)
+	c.tagValues = append(c.tagValues[:0], resourceValues...)
+	for _, attr := range attributes {
+		value := otlpreceiver.ValueString(attr.Value)
+		if i := indexOf(c.tagNames, attr.Key); i >= 0 {
+			c.tagValues[i] = value
+		} else {
+			c.tagNames = append(c.tagNames, attr.Key)
+			c.tagValues = append(c.tagValues, value)
+		}
+	}
+}
+
+func (c *otelConverter) seriesKey(seriesPrefix, name string) string {
+	c.keyBuilder.Reset()
+	c.keyBuilder.WriteString(seriesPrefix)
+	c.keyBuilder.WriteString(name)
+	for i, tagName := range c.tagNames {
+		c.keyBuilder.WriteByte(0)
+		c.keyBuilder.WriteString(tagName)
+		c.keyBuilder.WriteByte('=')
+		c.keyBuilder.WriteString(c.tagValues[i])
+	}
+	return c.keyBuilder.String()
+}
+
+func (c *otelConverter) emitValue(timestamp uint32, value float64, emit otelEmitter) {
+	c.metricsNames = append(c.metricsNames[:0], OTEL_METRICS_VALUE)
+	c.metricsValues = append(c.metricsValues[:0], value)
+	emit(timestamp, c.tagNames, c.tagValues, c.metricsNames, c.metricsValues)
+}
+
+// emitHistogram emits a row of count/sum/min/max, and a row with tag 'le' for each bucket like Prometheus
+func (c *otelConverter) emitHistogram(timestamp uint32, h *otelHistogram, emit otelEmitter) {
+	c.metricsNames = append(c.metricsNames[:0], OTEL_METRICS_COUNT)
+	c.metricsValues = append(c.metricsValues[:0], float64(h.count))
+	if h.hasSum {
+		c.metricsNames = append(c.metricsNames, OTEL_METRICS_SUM)
+		c.metricsValues = append(c.metricsValues, h.sum)
+	}
+	if h.hasMin {
+		c.metricsNames = append(c.metricsNames, OTEL_METRICS_MIN)
+		c.metricsValues = append(c.metricsValues, h.min)
+	}
+	if h.hasMax {
+		c.metricsNames = append(c.metricsNames, OTEL_METRICS_MAX)
+		c.metricsValues = append(c.metricsValues, h.max)
+	}
+	emit(timestamp, c.tagNames, c.tagValues, c.metricsNames, c.metricsValues)
+
+	tagNames := append(c.tagNames, OTEL_TAG_LE)
+	c.metricsNames = append(c.metricsNames[:0], OTEL_METRICS_BUCKET)
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		c.metricsValues = append(c.metricsValues[:0], float64(cumulative))
+		emit(timestamp, tagNames, append(c.tagValues, formatFloat(bound)), c.metricsNames, c.metricsValues)
+	}
+}
+
+// emitSummary emits a row of count/sum, and a row with tag 'quantile' for each quantile like Prometheus
+func (c *otelConverter) emitSummary(timestamp uint32, p *metricsv1.SummaryDataPoint, emit otelEmitter) {
+	c.metricsNames = append(c.metricsNames[:0], OTEL_METRICS_COUNT, OTEL_METRICS_SUM)
+	c.metricsValues = append(c.metricsValues[:0], float64(p.Count), p.Sum)
+	emit(timestamp, c.tagNames, c.tagValues, c.metricsNames, c.metricsValues)
+
+	tagNames := append(c.tagNames, OTEL_TAG_QUANTILE)
+	c.metricsNames = append(c.metricsNames[:0], OTEL_METRICS_VALUE)
+	for _, q := range p.QuantileValues {
+		c.metricsValues = append(c.metricsValues[:0], q.Value)
+		emit(timestamp, tagNames, append(c.tagValues, formatFloat(q.Quantile)), c.metricsNames, c.metricsValues)
+	}
+}
+
+func numberValue(p *metricsv1.NumberDataPoint) (float64, bool) {
+	if noRecordedValue(p.Flags) {
+		return 0, false
+	}
+	switch v := p.Value.(type) {
+	case *metricsv1.NumberDataPoint_AsDouble:
+		return v.AsDouble, true
+	case *metricsv1.NumberDataPoint_AsInt:
+		return float64(v.AsInt), true
+	}
+	return 0, false
+}
+
+func noRecordedValue(flags uint32) bool {
+	return flags&uint32(metricsv1.DataPointFlags_DATA_POINT_FLAGS_NO_RECORDED_VALUE_MASK) != 0
+}
+
+func otelTimestamp(timeUnixNano uint64, now time.Time) uint32 {
+	if timeUnixNano == 0 {
+		return uint32(now.Unix())
+	}
+	return uint32(timeUnixNano / uint64(time.Second))
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	} else if math.IsInf(v, -1) {
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func indexOf(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
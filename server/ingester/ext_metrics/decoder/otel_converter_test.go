@@ -0,0 +1,240 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package decoder
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	commonv1 "go.opentelemetry.io/proto/otlp/common/v1"
+	metricsv1 "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+type testRow struct {
+	timestamp     uint32
+	tags          map[string]string
+	metricsNames  []string
+	metricsValues []float64
+}
+
+func convertRows(c *otelConverter, metric *metricsv1.Metric, now time.Time) ([]testRow, int) {
+	rows := []testRow{}
+	dropped := c.convert(metric, "1/1/", []string{"service.name", "host.name"}, []string{"svc", "node1"}, now,
+		func(timestamp uint32, tagNames, tagValues, metricsNames []string, metricsValues []float64) {
+			row := testRow{timestamp: timestamp, tags: map[string]string{}}
+			for i := range tagNames {
+				row.tags[tagNames[i]] = tagValues[i]
+			}
+			row.metricsNames = append(row.metricsNames, metricsNames...)
+			row.metricsValues = append(row.metricsValues, metricsValues...)
+			rows = append(rows, row)
+		})
+	return rows, dropped
+}
+
+func newTestConverter() *otelConverter {
+	return newOTelConverter(NewOTelDeltaAccumulator(time.Minute, 100))
+}
+
+func stringAttribute(key, value string) *commonv1.KeyValue {
+	return &commonv1.KeyValue{Key: key, Value: &commonv1.AnyValue{Value: &commonv1.AnyValue_StringValue{StringValue: value}}}
+}
+
+func seconds(s int) uint64 {
+	return uint64(s) * uint64(time.Second)
+}
+
+func float64Ptr(v float64) *float64 {
+	return &v
+}
+
+func TestOTelGauge(t *testing.T) {
+	metric := &metricsv1.Metric{
+		Name: "cpu",
+		Data: &metricsv1.Metric_Gauge{Gauge: &metricsv1.Gauge{DataPoints: []*metricsv1.NumberDataPoint{
+			{
+				TimeUnixNano: seconds(100),
+				Value:        &metricsv1.NumberDataPoint_AsDouble{AsDouble: 0.5},
+				Attributes:   []*commonv1.KeyValue{stringAttribute("host.name", "node2"), stringAttribute("cpu", "0")},
+			},
+			{
+				TimeUnixNano: seconds(100),
+				Flags:        uint32(metricsv1.DataPointFlags_DATA_POINT_FLAGS_NO_RECORDED_VALUE_MASK),
+			},
+			{
+				TimeUnixNano: seconds(101),
+				Value:        &metricsv1.NumberDataPoint_AsInt{AsInt: 3},
+			},
+		}}},
+	}
+	rows, _ := convertRows(newTestConverter(), metric, time.Now())
+	expected := []testRow{
+		{100, map[string]string{"service.name": "svc", "host.name": "node2", "cpu": "0"}, []string{"value"}, []float64{0.5}},
+		{101, map[string]string{"service.name": "svc", "host.name": "node1"}, []string{"value"}, []float64{3}},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("expected %v, got %v", expected, rows)
+	}
+}
+
+func deltaSum(timestamp int, value int64) *metricsv1.Metric {
+	return &metricsv1.Metric{
+		Name: "requests",
+		Data: &metricsv1.Metric_Sum{Sum: &metricsv1.Sum{
+			AggregationTemporality: metricsv1.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA,
+			IsMonotonic:            true,
+			DataPoints: []*metricsv1.NumberDataPoint{
+				{TimeUnixNano: seconds(timestamp), Value: &metricsv1.NumberDataPoint_AsInt{AsInt: value}},
+			},
+		}},
+	}
+}
+
+func TestOTelDeltaSum(t *testing.T) {
+	c := newTestConverter()
+	now := time.Now()
+	values := []float64{}
+	for _, m := range []*metricsv1.Metric{deltaSum(10, 5), deltaSum(20, 3), deltaSum(20, 3), deltaSum(15, 1), deltaSum(30, 2)} {
+		rows, _ := convertRows(c, m, now)
+		for _, row := range rows {
+			values = append(values, row.metricsValues[0])
+		}
+	}
+	// the duplicated point is dropped, the out of order point is still accumulated
+	expected := []float64{5, 8, 9, 11}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("expected %v, got %v", expected, values)
+	}
+
+	// cumulative sums are written as they are
+	cumulative := deltaSum(40, 7)
+	cumulative.GetSum().AggregationTemporality = metricsv1.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE
+	if rows, _ := convertRows(c, cumulative, now); rows[0].metricsValues[0] != 7 {
+		t.Errorf("expected cumulative value 7, got %v", rows[0].metricsValues)
+	}
+}
+
+func TestOTelDeltaSeriesLimitAndExpiry(t *testing.T) {
+	c := newOTelConverter(NewOTelDeltaAccumulator(time.Minute, 1))
+	now := time.Now()
+	if _, dropped := convertRows(c, deltaSum(10, 1), now); dropped != 0 {
+		t.Errorf("expected no drop, got %d", dropped)
+	}
+	another := deltaSum(10, 1)
+	another.Name = "errors"
+	if _, dropped := convertRows(c, another, now); dropped != 1 {
+		t.Errorf("expected new series dropped when exceeding the limit, got %d", dropped)
+	}
+
+	c.accumulator.expire(now.Add(2 * time.Minute))
+	if len(c.accumulator.series) != 0 {
+		t.Errorf("expected series expired, got %d", len(c.accumulator.series))
+	}
+	if rows, _ := convertRows(c, deltaSum(20, 4), now); rows[0].metricsValues[0] != 4 {
+		t.Errorf("expected accumulation restarted, got %v", rows[0].metricsValues)
+	}
+}
+
+func TestOTelHistogram(t *testing.T) {
+	histogram := func(timestamp int, counts []uint64, sum float64) *metricsv1.Metric {
+		var count uint64
+		for _, c := range counts {
+			count += c
+		}
+		return &metricsv1.Metric{
+			Name: "latency",
+			Data: &metricsv1.Metric_Histogram{Histogram: &metricsv1.Histogram{
+				AggregationTemporality: metricsv1.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA,
+				DataPoints: []*metricsv1.HistogramDataPoint{{
+					TimeUnixNano:   seconds(timestamp),
+					Count:          count,
+					Sum:            float64Ptr(sum),
+					Min:            float64Ptr(1),
+					ExplicitBounds: []float64{10, 100},
+					BucketCounts:   counts,
+				}},
+			}},
+		}
+	}
+	c := newTestConverter()
+	now := time.Now()
+	convertRows(c, histogram(10, []uint64{1, 2, 0}, 120), now)
+	rows, _ := convertRows(c, histogram(20, []uint64{0, 1, 1}, 300), now)
+
+	expected := []testRow{
+		{20, map[string]string{"service.name": "svc", "host.name": "node1"}, []string{"count", "sum", "min"}, []float64{5, 420, 1}},
+		{20, map[string]string{"service.name": "svc", "host.name": "node1", "le": "10"}, []string{"bucket"}, []float64{1}},
+		{20, map[string]string{"service.name": "svc", "host.name": "node1", "le": "100"}, []string{"bucket"}, []float64{4}},
+		{20, map[string]string{"service.name": "svc", "host.name": "node1", "le": "+Inf"}, []string{"bucket"}, []float64{5}},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("expected %v, got %v", expected, rows)
+	}
+}
+
+func TestOTelExponentialHistogram(t *testing.T) {
+	p := &metricsv1.ExponentialHistogramDataPoint{
+		Count:     10,
+		Scale:     0, // base 2
+		ZeroCount: 1,
+		Positive:  &metricsv1.ExponentialHistogramDataPoint_Buckets{Offset: 1, BucketCounts: []uint64{3, 0, 4}},
+		Negative:  &metricsv1.ExponentialHistogramDataPoint_Buckets{Offset: 0, BucketCounts: []uint64{1, 1}},
+	}
+	h := exponentialHistogram(p)
+	// negative: [-4,-2), [-2,-1); zero; positive: (2,4], (8,16]
+	expectedBounds := []float64{-2, -1, 0, 4, 16, math.Inf(1)}
+	expectedCounts := []uint64{1, 1, 1, 3, 4, 0}
+	if !reflect.DeepEqual(h.bounds, expectedBounds) || !reflect.DeepEqual(h.counts, expectedCounts) {
+		t.Errorf("expected %v %v, got %v %v", expectedBounds, expectedCounts, h.bounds, h.counts)
+	}
+
+	// merge with a different scale
+	p2 := &metricsv1.ExponentialHistogramDataPoint{
+		Count:    2,
+		Scale:    1, // base sqrt(2)
+		Positive: &metricsv1.ExponentialHistogramDataPoint_Buckets{Offset: 3, BucketCounts: []uint64{2}},
+	}
+	h.merge(exponentialHistogram(p2))
+	expectedBounds = []float64{-2, -1, 0, 4, 16, math.Inf(1)}
+	expectedCounts = []uint64{1, 1, 1, 5, 4, 0}
+	if h.count != 12 || !reflect.DeepEqual(h.bounds, expectedBounds) || !reflect.DeepEqual(h.counts, expectedCounts) {
+		t.Errorf("expected %v %v, got %d %v %v", expectedBounds, expectedCounts, h.count, h.bounds, h.counts)
+	}
+}
+
+func TestOTelSummary(t *testing.T) {
+	metric := &metricsv1.Metric{
+		Name: "rpc",
+		Data: &metricsv1.Metric_Summary{Summary: &metricsv1.Summary{DataPoints: []*metricsv1.SummaryDataPoint{{
+			TimeUnixNano:   seconds(50),
+			Count:          4,
+			Sum:            10,
+			QuantileValues: []*metricsv1.SummaryDataPoint_ValueAtQuantile{{Quantile: 0.5, Value: 2}, {Quantile: 0.99, Value: 4}},
+		}}}},
+	}
+	rows, _ := convertRows(newTestConverter(), metric, time.Now())
+	expected := []testRow{
+		{50, map[string]string{"service.name": "svc", "host.name": "node1"}, []string{"count", "sum"}, []float64{4, 10}},
+		{50, map[string]string{"service.name": "svc", "host.name": "node1", "quantile": "0.5"}, []string{"value"}, []float64{2}},
+		{50, map[string]string{"service.name": "svc", "host.name": "node1", "quantile": "0.99"}, []string{"value"}, []float64{4}},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("expected %v, got %v", expected, rows)
+	}
+}
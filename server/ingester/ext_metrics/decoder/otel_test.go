@@ -0,0 +1,39 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package decoder
+
+import (
+	"testing"
+
+	commonv1 "go.opentelemetry.io/proto/otlp/common/v1"
+
+	"github.com/deepflowio/deepflow/server/ingester/pkg/otlpreceiver"
+)
+
+func TestParseOTelResource(t *testing.T) {
+	hostIP := &commonv1.KeyValue{Key: otlpreceiver.ATTRIBUTE_HOST_IP, Value: &commonv1.AnyValue{Value: &commonv1.AnyValue_ArrayValue{ArrayValue: &commonv1.ArrayValue{
+		Values: []*commonv1.AnyValue{{Value: &commonv1.AnyValue_StringValue{StringValue: "10.0.0.1"}}},
+	}}}}
+	r := parseOTelResource([]*commonv1.KeyValue{hostIP, stringAttribute(OTEL_POD_NAME, "web-0")})
+	if r.podName != "web-0" || r.ip.String() != "10.0.0.1" {
+		t.Errorf("unexpected resource %+v", r)
+	}
+	r = parseOTelResource([]*commonv1.KeyValue{hostIP, stringAttribute(otlpreceiver.ATTRIBUTE_APP_HOST_IP, "10.0.0.2")})
+	if r.ip.String() != "10.0.0.2" {
+		t.Errorf("expected app.host.ip preferred, got %s", r.ip)
+	}
+}
@@ -18,8 +18,10 @@ package ext_metrics
 
 import (
 	"strconv"
+	"sync/atomic"
 	"time"
 
+	collectormetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
 	_ "golang.org/x/net/context"
 	_ "google.golang.org/grpc"
 
@@ -28,6 +30,7 @@ import (
 	"github.com/deepflowio/deepflow/server/ingester/ext_metrics/dbwriter"
 	"github.com/deepflowio/deepflow/server/ingester/ext_metrics/decoder"
 	"github.com/deepflowio/deepflow/server/ingester/ingesterctl"
+	"github.com/deepflowio/deepflow/server/ingester/pkg/otlpreceiver"
 	"github.com/deepflowio/deepflow/server/libs/datatype"
 	"github.com/deepflowio/deepflow/server/libs/debug"
 	"github.com/deepflowio/deepflow/server/libs/grpc"
@@ -45,6 +48,7 @@ type ExtMetrics struct {
 	Telegraf           *Metricsor
 	DeepflowAgentStats *Metricsor
 	DeepflowStats      *Metricsor
+	OTelMetrics        *Metricsor
 }
 
 type Metricsor struct {
@@ -53,9 +57,14 @@ type Metricsor struct {
 	PlatformDataEnabled bool
 	PlatformDatas       []*grpc.PlatformInfoTable
 	Writers             [dbwriter.MAX_DB_ID]*dbwriter.ExtMetricsWriter
+
+	decodeQueues *dropletqueue.MultiQueue
+	queueCount   int
+	putCount     uint64
 }
 
-func NewExtMetrics(config *config.Config, recv *receiver.Receiver, platformDataManager *grpc.PlatformDataManager) (*ExtMetrics, error) {
+// otlpReceiver is nil if the otlp receiver of ingester is disabled
+func NewExtMetrics(config *config.Config, recv *receiver.Receiver, platformDataManager *grpc.PlatformDataManager, otlpReceiver *otlpreceiver.Receiver) (*ExtMetrics, error) {
 	manager := dropletqueue.NewManager(ingesterctl.INGESTERCTL_EXTMETRICS_QUEUE)
 
 	telegraf, err := NewMetricsor(datatype.MESSAGE_TYPE_TELEGRAF, []dbwriter.WriterDBID{dbwriter.EXT_METRICS_DB_ID}, config, platformDataManager, manager, recv, true)
@@ -70,11 +79,19 @@ func NewExtMetrics(config *config.Config, recv *receiver.Receiver, platformDataM
 	if err != nil {
 		return nil, err
 	}
+	otelMetrics, err := NewMetricsor(datatype.MESSAGE_TYPE_OPENTELEMETRY_METRICS, []dbwriter.WriterDBID{dbwriter.EXT_METRICS_DB_ID}, config, platformDataManager, manager, recv, true)
+	if err != nil {
+		return nil, err
+	}
+	if otlpReceiver != nil {
+		otlpReceiver.RegisterMetricsHandler(otelMetrics.putOTelMetrics)
+	}
 	return &ExtMetrics{
 		Config:             config,
 		Telegraf:           telegraf,
 		DeepflowAgentStats: deepflowAgentStats,
 		DeepflowStats:      deepflowStats,
+		OTelMetrics:        otelMetrics,
 	}, nil
 }
 
@@ -86,16 +103,25 @@ func NewMetricsor(msgType datatype.MessageType, flowTagTablePrefixs []dbwriter.W
 		queueCount,
 		1,
 		libqueue.OptionFlushIndicator(3*time.Second),
-		libqueue.OptionRelease(func(p interface{}) { receiver.ReleaseRecvBuffer(p.(*receiver.RecvBuffer)) }))
+		libqueue.OptionRelease(func(p interface{}) {
+			// the queues of OpenTelemetry metrics also contain requests from the otlp receiver
+			if recvBuffer, ok := p.(*receiver.RecvBuffer); ok {
+				receiver.ReleaseRecvBuffer(recvBuffer)
+			}
+		}))
 	recv.RegistHandler(msgType, decodeQueues, queueCount)
 
+	var otelDeltaAccumulator *decoder.OTelDeltaAccumulator
+	if msgType == datatype.MESSAGE_TYPE_OPENTELEMETRY_METRICS {
+		otelDeltaAccumulator = decoder.NewOTelDeltaAccumulator(time.Duration(config.OTelDeltaExpiry)*time.Second, config.OTelDeltaMaxSeries)
+	}
 	decoders := make([]*decoder.Decoder, queueCount)
 	platformDatas := make([]*grpc.PlatformInfoTable, queueCount)
 	for i := 0; i < queueCount; i++ {
 		if platformDataEnabled {
 			var err error
 			platformDatas[i], err = platformDataManager.NewPlatformInfoTable("ext-metrics-" + msgType.String() + "-" + strconv.Itoa(i))
-			if i == 0 {
+			if i == 0 && msgType == datatype.MESSAGE_TYPE_TELEGRAF {
 				debug.ServerRegisterSimple(CMD_PLATFORMDATA_EXT_METRICS, platformDatas[i])
 			}
 			if err != nil {
@@ -117,6 +143,7 @@ func NewMetricsor(msgType datatype.MessageType, flowTagTablePrefixs []dbwriter.W
 			queue.QueueReader(decodeQueues.FixedMultiQueue[i]),
 			metricsWriters,
 			config,
+			otelDeltaAccumulator,
 		)
 	}
 	return &Metricsor{
@@ -124,9 +151,18 @@ func NewMetricsor(msgType datatype.MessageType, flowTagTablePrefixs []dbwriter.W
 		Decoders:            decoders,
 		PlatformDataEnabled: platformDataEnabled,
 		PlatformDatas:       platformDatas,
+		decodeQueues:        decodeQueues,
+		queueCount:          queueCount,
 	}, nil
 }
 
+// putOTelMetrics is called by the otlp receiver, the requests are distributed to the decoders in turn like the
+// data from agents
+func (m *Metricsor) putOTelMetrics(source *otlpreceiver.Source, request *collectormetrics.ExportMetricsServiceRequest) {
+	index := atomic.AddUint64(&m.putCount, 1) % uint64(m.queueCount)
+	m.decodeQueues.Put(queue.HashKey(index), &decoder.OTelMetrics{Source: source, Request: request})
+}
+
 func (m *Metricsor) Start() {
 	if m.PlatformDataEnabled {
 		for _, platformData := range m.PlatformDatas {
@@ -151,11 +187,13 @@ func (s *ExtMetrics) Start() {
 	s.Telegraf.Start()
 	s.DeepflowAgentStats.Start()
 	s.DeepflowStats.Start()
+	s.OTelMetrics.Start()
 }
 
 func (s *ExtMetrics) Close() error {
 	s.Telegraf.Close()
 	s.DeepflowAgentStats.Close()
 	s.DeepflowStats.Close()
+	s.OTelMetrics.Close()
 	return nil
 }
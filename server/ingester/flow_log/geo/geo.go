@@ -17,11 +17,17 @@
 package geo
 
 import (
+	"net"
+	"time"
+
 	"github.com/deepflowio/deepflow/server/libs/geo"
 )
 
 var geoTree geo.GeoTree
 
+// 从 mmdb 文件加载, 流日志和 network_map 共用, 未配置时为 nil
+var geoIP *geo.GeoIP
+
 func NewGeoTree() {
 	geoTree = geo.NewNetmaskGeoTree()
 }
@@ -30,3 +36,31 @@ func QueryProvince(ip uint32) string {
 	region, _ := geoTree.Query(ip)
 	return geo.DecodeRegion(region)
 }
+
+// NewGeoIP loads the mmdb files and starts checking their modification, returns nil if no file is configured
+func NewGeoIP(files []string, reloadInterval int) (*geo.GeoIP, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+	g, err := geo.NewGeoIP(files, time.Duration(reloadInterval)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	g.Start()
+	geoIP = g
+	return g, nil
+}
+
+func GeoIPEnabled() bool {
+	return geoIP != nil
+}
+
+func QueryGeoIP(isIPv6 bool, ip4 uint32, ip6 net.IP) geo.IPGeo {
+	if geoIP == nil {
+		return geo.IPGeo{}
+	}
+	if isIPv6 {
+		return geoIP.Query(ip6)
+	}
+	return geoIP.QueryIPv4(ip4)
+}
@@ -27,10 +27,10 @@ func (l4 *L4FlowLog) QueryUniversalTags(utags *utag.UniversalTagsManager) (*utag
 
 func (l4 *L4FlowLog) EncodeTo(protocol config.ExportProtocol, utags *utag.UniversalTagsManager, cfg *config.ExporterCfg) (interface{}, error) {
 	switch protocol {
-	case config.PROTOCOL_KAFKA:
+	case config.PROTOCOL_KAFKA, config.PROTOCOL_HTTP:
 		tags0, tags1 := l4.QueryUniversalTags(utags)
 		k8sLabels0, k8sLabels1 := utags.QueryCustomK8sLabels(l4.OrgId, l4.PodID0), utags.QueryCustomK8sLabels(l4.OrgId, l4.PodID1)
-		return common.EncodeToJson(l4, int(l4.DataSource()), cfg, tags0, tags1, k8sLabels0, k8sLabels1), nil
+		return common.EncodeToJsonOrRecord(l4, int(l4.DataSource()), cfg, tags0, tags1, k8sLabels0, k8sLabels1), nil
 	default:
 		return nil, fmt.Errorf("l4_flow_log unsupport export to %s", protocol)
 	}
@@ -52,10 +52,10 @@ func (l7 *L7FlowLog) EncodeTo(protocol config.ExportProtocol, utags *utag.Univer
 	switch protocol {
 	case config.PROTOCOL_OTLP:
 		return l7.EncodeToOtlp(utags, cfg.ExportFieldCategoryBits), nil
-	case config.PROTOCOL_KAFKA:
+	case config.PROTOCOL_KAFKA, config.PROTOCOL_HTTP:
 		tags0, tags1 := l7.QueryUniversalTags(utags)
 		k8sLabels0, k8sLabels1 := utags.QueryCustomK8sLabels(l7.OrgId, l7.PodID0), utags.QueryCustomK8sLabels(l7.OrgId, l7.PodID1)
-		return common.EncodeToJson(l7, int(l7.DataSource()), cfg, tags0, tags1, k8sLabels0, k8sLabels1), nil
+		return common.EncodeToJsonOrRecord(l7, int(l7.DataSource()), cfg, tags0, tags1, k8sLabels0, k8sLabels1), nil
 	default:
 		return nil, fmt.Errorf("l7_flow_log unsupport export to %s", protocol)
 	}
@@ -49,6 +49,7 @@ type L4FlowLog struct {
 	TransportLayer
 	ApplicationLayer
 	Internet
+	GeoInfo
 	KnowledgeGraph
 	FlowInfo
 	Metrics
@@ -232,6 +233,48 @@ func (i *Internet) WriteBlock(block *ckdb.Block) {
 	block.Write(i.Province0, i.Province1)
 }
 
+// GeoInfo is looked up from the mmdb files of GeoIP, supports IPv4 and IPv6
+type GeoInfo struct {
+	Country0     string `json:"country_0" category:"$tag" sub:"network_layer"`
+	Country1     string `json:"country_1" category:"$tag" sub:"network_layer"`
+	Subdivision0 string `json:"subdivision_0" category:"$tag" sub:"network_layer"`
+	Subdivision1 string `json:"subdivision_1" category:"$tag" sub:"network_layer"`
+	City0        string `json:"city_0" category:"$tag" sub:"network_layer"`
+	City1        string `json:"city_1" category:"$tag" sub:"network_layer"`
+	ASN0         uint32 `json:"asn_0" category:"$tag" sub:"network_layer"`
+	ASN1         uint32 `json:"asn_1" category:"$tag" sub:"network_layer"`
+	ASOrg0       string `json:"as_org_0" category:"$tag" sub:"network_layer"`
+	ASOrg1       string `json:"as_org_1" category:"$tag" sub:"network_layer"`
+}
+
+var GeoInfoColumns = []*ckdb.Column{
+	ckdb.NewColumn("country_0", ckdb.LowCardinalityString).SetComment("ISO 3166-1 alpha-2"),
+	ckdb.NewColumn("country_1", ckdb.LowCardinalityString).SetComment("ISO 3166-1 alpha-2"),
+	ckdb.NewColumn("subdivision_0", ckdb.LowCardinalityString),
+	ckdb.NewColumn("subdivision_1", ckdb.LowCardinalityString),
+	ckdb.NewColumn("city_0", ckdb.LowCardinalityString),
+	ckdb.NewColumn("city_1", ckdb.LowCardinalityString),
+	ckdb.NewColumn("asn_0", ckdb.UInt32).SetIndex(ckdb.IndexNone),
+	ckdb.NewColumn("asn_1", ckdb.UInt32).SetIndex(ckdb.IndexNone),
+	ckdb.NewColumn("as_org_0", ckdb.LowCardinalityString),
+	ckdb.NewColumn("as_org_1", ckdb.LowCardinalityString),
+}
+
+func (g *GeoInfo) WriteBlock(block *ckdb.Block) {
+	block.Write(
+		g.Country0,
+		g.Country1,
+		g.Subdivision0,
+		g.Subdivision1,
+		g.City0,
+		g.City1,
+		g.ASN0,
+		g.ASN1,
+		g.ASOrg0,
+		g.ASOrg1,
+	)
+}
+
 type KnowledgeGraph struct {
 	RegionID0     uint16 `json:"region_id_0" category:"$tag" sub:"universal_tag"`
 	RegionID1     uint16 `json:"region_id_1" category:"$tag" sub:"universal_tag"`
@@ -707,6 +750,16 @@ func (i *Internet) Fill(f *pb.Flow) {
 	i.Province1 = geo.QueryProvince(f.FlowKey.IpDst)
 }
 
+func (g *GeoInfo) Fill(isIPv6 bool, ip40, ip41 uint32, ip60, ip61 net.IP) {
+	if !geo.GeoIPEnabled() {
+		return
+	}
+	geo0 := geo.QueryGeoIP(isIPv6, ip40, ip60)
+	g.Country0, g.Subdivision0, g.City0, g.ASN0, g.ASOrg0 = geo0.Country, geo0.Subdivision, geo0.City, geo0.ASN, geo0.ASOrg
+	geo1 := geo.QueryGeoIP(isIPv6, ip41, ip61)
+	g.Country1, g.Subdivision1, g.City1, g.ASN1, g.ASOrg1 = geo1.Country, geo1.Subdivision, geo1.City, geo1.ASN, geo1.ASOrg
+}
+
 func isLocalIP(isIPv6 bool, ip4 uint32, ip6 net.IP) bool {
 	ip := ip6
 	if !isIPv6 {
@@ -1031,6 +1084,7 @@ func L4FlowLogColumns() []*ckdb.Column {
 	columns = append(columns, TransportLayerColumns...)
 	columns = append(columns, ApplicationLayerColumns...)
 	columns = append(columns, InternetColumns...)
+	columns = append(columns, GeoInfoColumns...)
 	columns = append(columns, FlowInfoColumns...)
 	columns = append(columns, MetricsColumns...)
 	return columns
@@ -1044,6 +1098,7 @@ func (f *L4FlowLog) WriteBlock(block *ckdb.Block) {
 	f.TransportLayer.WriteBlock(block)
 	f.ApplicationLayer.WriteBlock(block)
 	f.Internet.WriteBlock(block)
+	f.GeoInfo.WriteBlock(block)
 	f.FlowInfo.WriteBlock(block)
 	f.Metrics.WriteBlock(block)
 }
@@ -1106,6 +1161,7 @@ func TaggedFlowToL4FlowLog(orgId, teamId uint16, f *pb.TaggedFlow, platformData
 	s.TransportLayer.Fill(f.Flow)
 	s.ApplicationLayer.Fill(f.Flow)
 	s.Internet.Fill(f.Flow)
+	s.GeoInfo.Fill(isIPV6, s.IP40, s.IP41, s.IP60, s.IP61)
 	s.KnowledgeGraph.FillL4(f.Flow, isIPV6, platformData)
 	s.FlowInfo.Fill(f.Flow)
 	s.Metrics.Fill(f.Flow)
@@ -44,6 +44,7 @@ var log = logging.MustGetLogger("flow_log.log_data")
 type L7Base struct {
 	// 知识图谱
 	KnowledgeGraph
+	GeoInfo
 
 	Time uint32 `json:"time" category:"$tag" sub:"flow_info"` // s
 	// 网络层
@@ -142,6 +143,7 @@ func L7BaseColumns() []*ckdb.Column {
 		ckdb.NewColumn("syscall_cap_seq_0", ckdb.UInt32).SetComment("Syscall序列号-请求"),
 		ckdb.NewColumn("syscall_cap_seq_1", ckdb.UInt32).SetComment("Syscall序列号-响应"),
 	)
+	columns = append(columns, GeoInfoColumns...)
 
 	return columns
 }
@@ -189,6 +191,7 @@ func (f *L7Base) WriteBlock(block *ckdb.Block) {
 		f.SyscallCoroutine1,
 		f.SyscallCapSeq0,
 		f.SyscallCapSeq1)
+	f.GeoInfo.WriteBlock(block)
 }
 
 type L7FlowLog struct {
@@ -612,6 +615,8 @@ func (b *L7Base) Fill(log *pb.AppProtoLogsData, platformData *grpc.PlatformInfoT
 	b.SyscallCapSeq0 = l.SyscallCapSeq_0
 	b.SyscallCapSeq1 = l.SyscallCapSeq_1
 
+	b.GeoInfo.Fill(!b.IsIPv4, b.IP40, b.IP41, b.IP60, b.IP61)
+
 	// 知识图谱
 	b.Protocol = uint8(log.Base.Protocol)
 
@@ -380,6 +380,7 @@ func (h *L7FlowLog) FillOTel(l *v1.Span, resAttributes []*v11.KeyValue, platform
 			}
 		}
 	}
+	h.L7Base.GeoInfo.Fill(!h.IsIPv4, h.IP40, h.IP41, h.IP60, h.IP61)
 	h.L7Base.KnowledgeGraph.FillOTel(h, platformData)
 	// only show data for services as 'server side'
 	if h.TapSide == flow_metrics.ServerApp.String() && h.ServerPort == 0 {
@@ -21,6 +21,7 @@ import (
 	"net"
 
 	"github.com/deepflowio/deepflow/server/ingester/common"
+	"github.com/deepflowio/deepflow/server/ingester/flow_log/geo"
 	"github.com/deepflowio/deepflow/server/libs/app"
 	"github.com/deepflowio/deepflow/server/libs/ckdb"
 	"github.com/deepflowio/deepflow/server/libs/datatype"
@@ -111,6 +112,16 @@ func getPlatformInfos(t *flow_metrics.Tag, platformData *grpc.PlatformInfoTable)
 	return info, info1
 }
 
+func fillGeoIP(t *flow_metrics.Tag) {
+	if !geo.GeoIPEnabled() {
+		return
+	}
+	geo0 := geo.QueryGeoIP(t.IsIPv4 == 0, t.IP, t.IP6)
+	t.Country0, t.Subdivision0, t.City0, t.ASN0, t.ASOrg0 = geo0.Country, geo0.Subdivision, geo0.City, geo0.ASN, geo0.ASOrg
+	geo1 := geo.QueryGeoIP(t.IsIPv4 == 0, t.IP1, t.IP61)
+	t.Country1, t.Subdivision1, t.City1, t.ASN1, t.ASOrg1 = geo1.Country, geo1.Subdivision, geo1.City, geo1.ASN, geo1.ASOrg
+}
+
 func DocumentExpand(doc app.Document, platformData *grpc.PlatformInfoTable) error {
 	t := doc.Tags()
 	t.SetID("") // 由于需要修改Tag增删Field，清空ID避免字段脏
@@ -130,6 +141,11 @@ func DocumentExpand(doc app.Document, platformData *grpc.PlatformInfoTable) erro
 	info, info1 := getPlatformInfos(t, platformData)
 	if t.Code&EdgeCode == EdgeCode {
 		t.Code |= EdgeAddCode
+		// only network_map is filled with geo information
+		if doc.Meter().ID() == flow_metrics.FLOW_ID {
+			t.Code |= flow_metrics.GeoIPPath
+			fillGeoIP(t)
+		}
 	} else {
 		t.Code |= MainAddCode
 	}
@@ -25,13 +25,15 @@ import (
 	"time"
 
 	"github.com/deepflowio/deepflow/server/ingester/app_log"
+	"github.com/deepflowio/deepflow/server/ingester/ckbackup"
 	"github.com/deepflowio/deepflow/server/ingester/ckmonitor"
 	"github.com/deepflowio/deepflow/server/ingester/datasource"
 	"github.com/deepflowio/deepflow/server/ingester/exporters"
+	"github.com/deepflowio/deepflow/server/ingester/pkg/otlpreceiver"
 	"github.com/deepflowio/deepflow/server/libs/grpc"
 	"github.com/deepflowio/deepflow/server/libs/logger"
 	"github.com/deepflowio/deepflow/server/libs/pool"
-	"github.com/deepflowio/deepflow/server/libs/receiver"
+	libreceiver "github.com/deepflowio/deepflow/server/libs/receiver"
 	"github.com/deepflowio/deepflow/server/libs/stats"
 
 	logging "github.com/op/go-logging"
@@ -49,6 +51,7 @@ import (
 	"github.com/deepflowio/deepflow/server/ingester/ext_metrics/ext_metrics"
 	flowlogcfg "github.com/deepflowio/deepflow/server/ingester/flow_log/config"
 	flowlog "github.com/deepflowio/deepflow/server/ingester/flow_log/flow_log"
+	flowloggeo "github.com/deepflowio/deepflow/server/ingester/flow_log/geo"
 	flowmetricscfg "github.com/deepflowio/deepflow/server/ingester/flow_metrics/config"
 	flowmetrics "github.com/deepflowio/deepflow/server/ingester/flow_metrics/flow_metrics"
 	pcapcfg "github.com/deepflowio/deepflow/server/ingester/pcap/config"
@@ -93,7 +96,31 @@ func Start(configPath string, shared *servercommon.ControllerIngesterShared) []i
 	stats.SetRemoteType(stats.REMOTE_TYPE_DFSTATSD)
 	stats.SetDFRemote(net.JoinHostPort("127.0.0.1", strconv.Itoa(int(cfg.ListenPort))))
 
-	receiver := receiver.NewReceiver(int(cfg.ListenPort), cfg.UDPReadBuffer, cfg.TCPReadBuffer, cfg.TCPReaderBuffer)
+	receiver := libreceiver.NewReceiver(int(cfg.ListenPort), cfg.UDPReadBuffer, cfg.TCPReadBuffer, cfg.TCPReaderBuffer)
+	if cfg.TLSReceiver.Enabled {
+		if err := receiver.EnableTLS(&libreceiver.TLSConfig{
+			ListenPort:            int(cfg.TLSReceiver.ListenPort),
+			CertFile:              cfg.TLSReceiver.CertFile,
+			KeyFile:               cfg.TLSReceiver.KeyFile,
+			ClientCAFile:          cfg.TLSReceiver.ClientCAFile,
+			ReloadInterval:        time.Duration(cfg.TLSReceiver.ReloadInterval) * time.Second,
+			IdentityCheckDisabled: cfg.TLSReceiver.IdentityCheckDisabled,
+		}); err != nil {
+			log.Errorf("enable tls receiver failed: %s", err)
+			time.Sleep(time.Second)
+			os.Exit(1)
+		}
+		// UDP is kept for statsd of deepflow-server itself
+		if cfg.TLSReceiver.PlaintextTCPDisabled {
+			receiver.SetServerType(libreceiver.UDP)
+		}
+	}
+
+	// receive OTLP data from OpenTelemetry SDKs and collectors directly, modules register their handlers before it starts
+	var otlpReceiver *otlpreceiver.Receiver
+	if cfg.OTLPReceiver.Enabled {
+		otlpReceiver = otlpreceiver.NewReceiver(&cfg.OTLPReceiver)
+	}
 
 	ingesterOrgHandler := NewOrgHandler(cfg)
 	closers := []io.Closer{}
@@ -173,6 +200,13 @@ func Start(configPath string, shared *servercommon.ControllerIngesterShared) []i
 			closers = append(closers, exporters)
 		}
 
+		// 加载 GeoIP 数据库, 流日志和 network_map 共用
+		geoIP, err := flowloggeo.NewGeoIP(cfg.GeoIP.MMDBFiles, cfg.GeoIP.ReloadInterval)
+		checkError(err)
+		if geoIP != nil {
+			closers = append(closers, geoIP)
+		}
+
 		// 写流日志数据
 		flowLog, err := flowlog.NewFlowLog(flowLogConfig, shared.TraceTreeQueue, receiver, platformDataManager, exporters)
 		checkError(err)
@@ -181,7 +215,7 @@ func Start(configPath string, shared *servercommon.ControllerIngesterShared) []i
 
 		if !cfg.StorageDisabled {
 			// 写ext_metrics数据
-			extMetrics, err := ext_metrics.NewExtMetrics(extMetricsConfig, receiver, platformDataManager)
+			extMetrics, err := ext_metrics.NewExtMetrics(extMetricsConfig, receiver, platformDataManager, otlpReceiver)
 			checkError(err)
 			extMetrics.Start()
 			closers = append(closers, extMetrics)
@@ -218,7 +252,7 @@ func Start(configPath string, shared *servercommon.ControllerIngesterShared) []i
 			ingesterOrgHandler.SetPromHandler(prometheus)
 
 			// write application log data
-			applicationLog, err := app_log.NewApplicationLogger(applicationLogConfig, receiver, platformDataManager)
+			applicationLog, err := app_log.NewApplicationLogger(applicationLogConfig, receiver, platformDataManager, otlpReceiver)
 			checkError(err)
 			applicationLog.Start()
 			closers = append(closers, applicationLog)
@@ -229,6 +263,12 @@ func Start(configPath string, shared *servercommon.ControllerIngesterShared) []i
 			cm.Start()
 			closers = append(closers, cm)
 
+			// backup clickhouse data on schedule, and handle the backup/restore commands of ingesterctl
+			cb, err := ckbackup.NewCKBackup(cfg)
+			checkError(err)
+			cb.Start()
+			closers = append(closers, cb)
+
 			// 初始化建表完成,再执行issu
 			time.Sleep(time.Second)
 			err = issu.Start()
@@ -241,6 +281,13 @@ func Start(configPath string, shared *servercommon.ControllerIngesterShared) []i
 	// receiver后启动，防止启动后收到数据无法处理，而上报异常日志
 	receiver.Start()
 	closers = append(closers, receiver)
+	if otlpReceiver != nil {
+		if err := otlpReceiver.Start(); err != nil {
+			log.Error(err)
+		} else {
+			closers = append(closers, otlpReceiver)
+		}
+	}
 	servercommon.SetOrgHandler(ingesterOrgHandler)
 
 	return closers
@@ -24,6 +24,7 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/deepflowio/deepflow/server/ingester/ckbackup"
 	"github.com/deepflowio/deepflow/server/ingester/common"
 	"github.com/deepflowio/deepflow/server/ingester/droplet/profiler"
 	"github.com/deepflowio/deepflow/server/ingester/droplet/queue"
@@ -93,6 +94,7 @@ func RegisterIngesterCommand(root *cobra.Command) {
 		nil,
 	))
 	ingesterCmd.AddCommand(RegisterDecodeTraceCommand(ip, uint16(orgId)))
+	ingesterCmd.AddCommand(ckbackup.RegisterClientCommand())
 
 	dropletCmd.AddCommand(queue.RegisterCommand(ingesterctl.INGESTERCTL_QUEUE, []string{
 		"1-receiver-to-statsd",
@@ -121,6 +123,7 @@ func RegisterIngesterCommand(root *cobra.Command) {
 	exportersCmd.AddCommand(debug.ClientRegisterSimple(ingesterctl.CMD_EXPORTER_PLATFORMDATA, debug.CmdHelper{"platformData", "show otlp platformData"}, nil))
 	exportersCmd.AddCommand(debug.ClientRegisterSimple(ingesterctl.CMD_KAFKA_EXPORTER, debug.CmdHelper{Cmd: "kafka", Helper: "show kafka exporter stats"}, nil))
 	exportersCmd.AddCommand(debug.ClientRegisterSimple(ingesterctl.CMD_PROMETHEUS_EXPORTER, debug.CmdHelper{Cmd: "prometheus", Helper: "show prometheus exporter stats"}, nil))
+	exportersCmd.AddCommand(debug.ClientRegisterSimple(ingesterctl.CMD_HTTP_EXPORTER, debug.CmdHelper{Cmd: "http", Helper: "show http exporter stats"}, nil))
 
 	profileCmd.AddCommand(debug.ClientRegisterSimple(ingesterctl.CMD_PLATFORMDATA_PROFILE, debug.CmdHelper{"platformData [filter]", "show profile platform data statistics"}, nil))
 
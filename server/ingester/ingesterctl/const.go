@@ -58,6 +58,8 @@ const (
 	CMD_EXPORTER_PLATFORMDATA
 	CMD_CONTINUOUS_PROFILER
 	CMD_ORG_SWITCH
+	CMD_HTTP_EXPORTER
+	CMD_CK_BACKUP
 )
 
 const (
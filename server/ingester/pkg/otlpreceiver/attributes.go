@@ -0,0 +1,114 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package otlpreceiver
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"strconv"
+
+	commonv1 "go.opentelemetry.io/proto/otlp/common/v1"
+)
+
+// resource attributes used to look up platform data
+const (
+	ATTRIBUTE_APP_HOST_IP = "app.host.ip" // added by deepflow-agent, or k8sattributesprocessor of otel-collector
+	ATTRIBUTE_POD_IP      = "k8s.pod.ip"
+	ATTRIBUTE_HOST_IP     = "host.ip"
+)
+
+// ResourceIP returns the instance ip in resource attributes, app.host.ip is preferred, then k8s.pod.ip and host.ip
+func ResourceIP(attributes []*commonv1.KeyValue) net.IP {
+	var appHostIP, podIP, hostIP net.IP
+	for _, attr := range attributes {
+		switch attr.Key {
+		case ATTRIBUTE_APP_HOST_IP:
+			appHostIP = net.ParseIP(attr.Value.GetStringValue())
+		case ATTRIBUTE_POD_IP:
+			podIP = net.ParseIP(attr.Value.GetStringValue())
+		case ATTRIBUTE_HOST_IP:
+			// host.ip is a string array by semantic conventions
+			if values := attr.Value.GetArrayValue().GetValues(); len(values) > 0 {
+				hostIP = net.ParseIP(values[0].GetStringValue())
+			} else {
+				hostIP = net.ParseIP(attr.Value.GetStringValue())
+			}
+		}
+	}
+	switch {
+	case appHostIP != nil:
+		return appHostIP
+	case podIP != nil:
+		return podIP
+	default:
+		return hostIP
+	}
+}
+
+// ValueString converts an AnyValue to string, bytes are encoded with base64 and arrays and maps are converted
+// to json, the same as the json encoding of OTLP. keys of maps are sorted, so that a value is always converted
+// to the same string, which is required by the series of metrics
+func ValueString(value *commonv1.AnyValue) string {
+	switch v := value.GetValue().(type) {
+	case *commonv1.AnyValue_StringValue:
+		return v.StringValue
+	case *commonv1.AnyValue_BoolValue:
+		return strconv.FormatBool(v.BoolValue)
+	case *commonv1.AnyValue_IntValue:
+		return strconv.FormatInt(v.IntValue, 10)
+	case *commonv1.AnyValue_DoubleValue:
+		return strconv.FormatFloat(v.DoubleValue, 'g', -1, 64)
+	case *commonv1.AnyValue_BytesValue:
+		return base64.StdEncoding.EncodeToString(v.BytesValue)
+	case *commonv1.AnyValue_ArrayValue, *commonv1.AnyValue_KvlistValue:
+		bytes, err := json.Marshal(valueInterface(value))
+		if err != nil {
+			return value.String()
+		}
+		return string(bytes)
+	}
+	return ""
+}
+
+func valueInterface(value *commonv1.AnyValue) interface{} {
+	switch v := value.GetValue().(type) {
+	case *commonv1.AnyValue_StringValue:
+		return v.StringValue
+	case *commonv1.AnyValue_BoolValue:
+		return v.BoolValue
+	case *commonv1.AnyValue_IntValue:
+		return v.IntValue
+	case *commonv1.AnyValue_DoubleValue:
+		return v.DoubleValue
+	case *commonv1.AnyValue_BytesValue:
+		return base64.StdEncoding.EncodeToString(v.BytesValue)
+	case *commonv1.AnyValue_ArrayValue:
+		values := make([]interface{}, 0, len(v.ArrayValue.GetValues()))
+		for _, value := range v.ArrayValue.GetValues() {
+			values = append(values, valueInterface(value))
+		}
+		return values
+	case *commonv1.AnyValue_KvlistValue:
+		values := make(map[string]interface{}, len(v.KvlistValue.GetValues()))
+		for _, kv := range v.KvlistValue.GetValues() {
+			values[kv.Key] = valueInterface(kv.Value)
+		}
+		return values
+	}
+	return nil
+}
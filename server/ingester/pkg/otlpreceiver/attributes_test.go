@@ -0,0 +1,74 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package otlpreceiver
+
+import (
+	"testing"
+
+	commonv1 "go.opentelemetry.io/proto/otlp/common/v1"
+)
+
+func stringValue(v string) *commonv1.AnyValue {
+	return &commonv1.AnyValue{Value: &commonv1.AnyValue_StringValue{StringValue: v}}
+}
+
+func TestResourceIP(t *testing.T) {
+	hostIP := &commonv1.KeyValue{Key: ATTRIBUTE_HOST_IP, Value: &commonv1.AnyValue{Value: &commonv1.AnyValue_ArrayValue{ArrayValue: &commonv1.ArrayValue{
+		Values: []*commonv1.AnyValue{stringValue("10.0.0.1")},
+	}}}}
+	testCases := []struct {
+		attributes []*commonv1.KeyValue
+		expected   string
+	}{
+		{[]*commonv1.KeyValue{hostIP, {Key: "k8s.pod.name", Value: stringValue("web-0")}}, "10.0.0.1"},
+		{[]*commonv1.KeyValue{hostIP, {Key: ATTRIBUTE_POD_IP, Value: stringValue("10.1.0.1")}}, "10.1.0.1"},
+		{[]*commonv1.KeyValue{{Key: ATTRIBUTE_APP_HOST_IP, Value: stringValue("10.0.0.2")}, hostIP}, "10.0.0.2"},
+		{[]*commonv1.KeyValue{{Key: ATTRIBUTE_HOST_IP, Value: stringValue("10.0.0.3")}}, "10.0.0.3"},
+		{nil, "<nil>"},
+	}
+	for _, tc := range testCases {
+		if ip := ResourceIP(tc.attributes); ip.String() != tc.expected {
+			t.Errorf("ip of %v is %s, expected %s", tc.attributes, ip, tc.expected)
+		}
+	}
+}
+
+func TestValueString(t *testing.T) {
+	testCases := []struct {
+		value    *commonv1.AnyValue
+		expected string
+	}{
+		{nil, ""},
+		{stringValue("hello"), "hello"},
+		{&commonv1.AnyValue{Value: &commonv1.AnyValue_BoolValue{BoolValue: true}}, "true"},
+		{&commonv1.AnyValue{Value: &commonv1.AnyValue_IntValue{IntValue: -3}}, "-3"},
+		{&commonv1.AnyValue{Value: &commonv1.AnyValue_DoubleValue{DoubleValue: 0.25}}, "0.25"},
+		{&commonv1.AnyValue{Value: &commonv1.AnyValue_BytesValue{BytesValue: []byte("df")}}, "ZGY="},
+		{&commonv1.AnyValue{Value: &commonv1.AnyValue_ArrayValue{ArrayValue: &commonv1.ArrayValue{Values: []*commonv1.AnyValue{
+			{Value: &commonv1.AnyValue_IntValue{IntValue: 1}},
+			{Value: &commonv1.AnyValue_KvlistValue{KvlistValue: &commonv1.KeyValueList{Values: []*commonv1.KeyValue{
+				{Key: "k", Value: stringValue("v")},
+				{Key: "b", Value: &commonv1.AnyValue{Value: &commonv1.AnyValue_BytesValue{BytesValue: []byte("df")}}},
+			}}}},
+		}}}}, `[1,{"b":"ZGY=","k":"v"}]`},
+	}
+	for _, tc := range testCases {
+		if value := ValueString(tc.value); value != tc.expected {
+			t.Errorf("value of %v is %s, expected %s", tc.value, value, tc.expected)
+		}
+	}
+}
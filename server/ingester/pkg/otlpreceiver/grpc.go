@@ -0,0 +1,60 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package otlpreceiver
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+
+	collectorlogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	collectormetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/grpc/peer"
+)
+
+type metricsService struct {
+	collectormetrics.UnimplementedMetricsServiceServer
+	receiver *Receiver
+}
+
+func (s *metricsService) Export(ctx context.Context, request *collectormetrics.ExportMetricsServiceRequest) (*collectormetrics.ExportMetricsServiceResponse, error) {
+	atomic.AddInt64(&s.receiver.counter.MetricsRequestCount, 1)
+	s.receiver.metricsHandler(s.receiver.source(peerIP(ctx)), request)
+	return &collectormetrics.ExportMetricsServiceResponse{}, nil
+}
+
+type logsService struct {
+	collectorlogs.UnimplementedLogsServiceServer
+	receiver *Receiver
+}
+
+func (s *logsService) Export(ctx context.Context, request *collectorlogs.ExportLogsServiceRequest) (*collectorlogs.ExportLogsServiceResponse, error) {
+	atomic.AddInt64(&s.receiver.counter.LogsRequestCount, 1)
+	s.receiver.logsHandler(s.receiver.source(peerIP(ctx)), request)
+	return &collectorlogs.ExportLogsServiceResponse{}, nil
+}
+
+func peerIP(ctx context.Context) net.IP {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	if addr, ok := p.Addr.(*net.TCPAddr); ok {
+		return addr.IP
+	}
+	return nil
+}
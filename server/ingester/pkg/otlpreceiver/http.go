@@ -0,0 +1,149 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package otlpreceiver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"sync/atomic"
+
+	collectorlogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	collectormetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	CONTENT_TYPE_PROTOBUF = "application/x-protobuf"
+	CONTENT_TYPE_JSON     = "application/json"
+)
+
+func (r *Receiver) handleHTTPMetrics(w http.ResponseWriter, req *http.Request) {
+	request := &collectormetrics.ExportMetricsServiceRequest{}
+	contentType, ok := r.readHTTPRequest(w, req, request)
+	if !ok {
+		return
+	}
+	atomic.AddInt64(&r.counter.MetricsRequestCount, 1)
+	r.metricsHandler(r.source(httpRemoteIP(req)), request)
+	writeHTTPResponse(w, contentType, http.StatusOK, &collectormetrics.ExportMetricsServiceResponse{})
+}
+
+func (r *Receiver) handleHTTPLogs(w http.ResponseWriter, req *http.Request) {
+	request := &collectorlogs.ExportLogsServiceRequest{}
+	contentType, ok := r.readHTTPRequest(w, req, request)
+	if !ok {
+		return
+	}
+	atomic.AddInt64(&r.counter.LogsRequestCount, 1)
+	r.logsHandler(r.source(httpRemoteIP(req)), request)
+	writeHTTPResponse(w, contentType, http.StatusOK, &collectorlogs.ExportLogsServiceResponse{})
+}
+
+// readHTTPRequest decodes the OTLP/HTTP request body into message, when failed, the error response is
+// written and false is returned
+func (r *Receiver) readHTTPRequest(w http.ResponseWriter, req *http.Request, message proto.Message) (string, bool) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeHTTPError(w, CONTENT_TYPE_PROTOBUF, http.StatusMethodNotAllowed, codes.Unimplemented, "method not allowed")
+		return "", false
+	}
+	contentType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil || (contentType != CONTENT_TYPE_PROTOBUF && contentType != CONTENT_TYPE_JSON) {
+		writeHTTPError(w, CONTENT_TYPE_PROTOBUF, http.StatusUnsupportedMediaType, codes.InvalidArgument,
+			fmt.Sprintf("unsupported content type '%s'", req.Header.Get("Content-Type")))
+		return "", false
+	}
+
+	body, err := r.readHTTPBody(req)
+	if err != nil {
+		r.error("otlp receiver read http request from %s failed: %s", req.RemoteAddr, err)
+		writeHTTPError(w, contentType, http.StatusBadRequest, codes.InvalidArgument, err.Error())
+		return "", false
+	}
+	if contentType == CONTENT_TYPE_JSON {
+		err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(body, message)
+	} else {
+		err = proto.Unmarshal(body, message)
+	}
+	if err != nil {
+		r.error("otlp receiver decode http request from %s failed: %s", req.RemoteAddr, err)
+		writeHTTPError(w, contentType, http.StatusBadRequest, codes.InvalidArgument, err.Error())
+		return "", false
+	}
+	return contentType, true
+}
+
+func (r *Receiver) readHTTPBody(req *http.Request) ([]byte, error) {
+	maxSize := int64(r.maxMessageSize())
+	var reader io.Reader = io.LimitReader(req.Body, maxSize+1)
+	switch req.Header.Get("Content-Encoding") {
+	case "", "identity":
+	case "gzip":
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer gzipReader.Close()
+		// limit the decompressed size as well
+		reader = io.LimitReader(gzipReader, maxSize+1)
+	default:
+		return nil, fmt.Errorf("unsupported content encoding '%s'", req.Header.Get("Content-Encoding"))
+	}
+
+	buffer := &bytes.Buffer{}
+	if _, err := buffer.ReadFrom(reader); err != nil {
+		return nil, err
+	}
+	if int64(buffer.Len()) > maxSize {
+		return nil, fmt.Errorf("request body exceeds %d bytes", maxSize)
+	}
+	return buffer.Bytes(), nil
+}
+
+func writeHTTPResponse(w http.ResponseWriter, contentType string, statusCode int, message proto.Message) {
+	var body []byte
+	if contentType == CONTENT_TYPE_JSON {
+		body, _ = protojson.Marshal(message)
+	} else {
+		contentType = CONTENT_TYPE_PROTOBUF
+		body, _ = proto.Marshal(message)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(statusCode)
+	w.Write(body)
+}
+
+// the response body of failed OTLP/HTTP requests is a google.rpc.Status message
+func writeHTTPError(w http.ResponseWriter, contentType string, statusCode int, code codes.Code, message string) {
+	writeHTTPResponse(w, contentType, statusCode, status.New(code, message).Proto())
+}
+
+func httpRemoteIP(req *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
@@ -0,0 +1,191 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package otlpreceiver
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	logging "github.com/op/go-logging"
+	collectorlogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	collectormetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/grpc"
+	_ "google.golang.org/grpc/encoding/gzip" // OTLP exporters compress requests with gzip by default
+
+	ingestercommon "github.com/deepflowio/deepflow/server/ingester/common"
+	"github.com/deepflowio/deepflow/server/ingester/config"
+)
+
+var log = logging.MustGetLogger("otlp_receiver")
+
+const (
+	HTTP_METRICS_PATH = "/v1/metrics"
+	HTTP_LOGS_PATH    = "/v1/logs"
+	HTTP_READ_TIMEOUT = time.Minute
+)
+
+// Source describes where an OTLP request comes from
+type Source struct {
+	OrgId   uint16
+	L3EpcID int32
+	IP      net.IP // address of the sender
+}
+
+// MetricsHandler takes over the request, it is called concurrently and should not block
+type MetricsHandler func(source *Source, request *collectormetrics.ExportMetricsServiceRequest)
+
+// LogsHandler takes over the request, it is called concurrently and should not block
+type LogsHandler func(source *Source, request *collectorlogs.ExportLogsServiceRequest)
+
+type Counter struct {
+	MetricsRequestCount int64 `statsd:"metrics-request-count"`
+	LogsRequestCount    int64 `statsd:"logs-request-count"`
+	ErrorCount          int64 `statsd:"err-count"`
+}
+
+// Receiver receives OTLP data over gRPC and HTTP, the data of each signal is handed to the
+// module registered for it, e.g. ext_metrics for metrics, app_log for logs.
+type Receiver struct {
+	config *config.OTLPReceiver
+
+	metricsHandler MetricsHandler
+	logsHandler    LogsHandler
+
+	grpcServer *grpc.Server
+	httpServer *http.Server
+	closed     int32
+
+	counter *Counter
+}
+
+func NewReceiver(cfg *config.OTLPReceiver) *Receiver {
+	return &Receiver{
+		config:  cfg,
+		counter: &Counter{},
+	}
+}
+
+// RegisterMetricsHandler should be called before Start
+func (r *Receiver) RegisterMetricsHandler(handler MetricsHandler) {
+	r.metricsHandler = handler
+}
+
+// RegisterLogsHandler should be called before Start
+func (r *Receiver) RegisterLogsHandler(handler LogsHandler) {
+	r.logsHandler = handler
+}
+
+func (r *Receiver) GetCounter() interface{} {
+	counter := &Counter{
+		MetricsRequestCount: atomic.SwapInt64(&r.counter.MetricsRequestCount, 0),
+		LogsRequestCount:    atomic.SwapInt64(&r.counter.LogsRequestCount, 0),
+		ErrorCount:          atomic.SwapInt64(&r.counter.ErrorCount, 0),
+	}
+	return counter
+}
+
+func (r *Receiver) Closed() bool {
+	return atomic.LoadInt32(&r.closed) == 1
+}
+
+func (r *Receiver) Start() error {
+	var grpcListener, httpListener net.Listener
+	var err error
+	if r.config.GRPCPort > 0 {
+		if grpcListener, err = net.Listen("tcp", fmt.Sprintf(":%d", r.config.GRPCPort)); err != nil {
+			return fmt.Errorf("otlp receiver listen grpc port %d failed: %s", r.config.GRPCPort, err)
+		}
+	}
+	if r.config.HTTPPort > 0 {
+		if httpListener, err = net.Listen("tcp", fmt.Sprintf(":%d", r.config.HTTPPort)); err != nil {
+			if grpcListener != nil {
+				grpcListener.Close()
+			}
+			return fmt.Errorf("otlp receiver listen http port %d failed: %s", r.config.HTTPPort, err)
+		}
+	}
+	r.serve(grpcListener, httpListener)
+	ingestercommon.RegisterCountableForIngester("otlp_receiver", r)
+	log.Infof("otlp receiver started, grpc port: %d, http port: %d", r.config.GRPCPort, r.config.HTTPPort)
+	return nil
+}
+
+func (r *Receiver) serve(grpcListener, httpListener net.Listener) {
+	if grpcListener != nil {
+		r.grpcServer = grpc.NewServer(grpc.MaxRecvMsgSize(r.maxMessageSize()))
+		if r.metricsHandler != nil {
+			collectormetrics.RegisterMetricsServiceServer(r.grpcServer, &metricsService{receiver: r})
+		}
+		if r.logsHandler != nil {
+			collectorlogs.RegisterLogsServiceServer(r.grpcServer, &logsService{receiver: r})
+		}
+		go func() {
+			if err := r.grpcServer.Serve(grpcListener); err != nil && !r.Closed() {
+				log.Errorf("otlp receiver grpc server stopped: %s", err)
+			}
+		}()
+	}
+	if httpListener != nil {
+		mux := http.NewServeMux()
+		if r.metricsHandler != nil {
+			mux.HandleFunc(HTTP_METRICS_PATH, r.handleHTTPMetrics)
+		}
+		if r.logsHandler != nil {
+			mux.HandleFunc(HTTP_LOGS_PATH, r.handleHTTPLogs)
+		}
+		r.httpServer = &http.Server{Handler: mux, ReadTimeout: HTTP_READ_TIMEOUT}
+		go func() {
+			if err := r.httpServer.Serve(httpListener); err != nil && err != http.ErrServerClosed {
+				log.Errorf("otlp receiver http server stopped: %s", err)
+			}
+		}()
+	}
+}
+
+func (r *Receiver) Close() error {
+	if !atomic.CompareAndSwapInt32(&r.closed, 0, 1) {
+		return nil
+	}
+	if r.grpcServer != nil {
+		r.grpcServer.Stop()
+	}
+	if r.httpServer != nil {
+		r.httpServer.Close()
+	}
+	return nil
+}
+
+func (r *Receiver) maxMessageSize() int {
+	return r.config.MaxMessageSize << 20
+}
+
+func (r *Receiver) source(ip net.IP) *Source {
+	return &Source{
+		OrgId:   uint16(r.config.OrgID),
+		L3EpcID: r.config.L3EpcID,
+		IP:      ip,
+	}
+}
+
+func (r *Receiver) error(format string, args ...interface{}) {
+	if atomic.AddInt64(&r.counter.ErrorCount, 1) == 1 {
+		log.Warningf(format, args...)
+	}
+}
@@ -0,0 +1,198 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package otlpreceiver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	collectorlogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	collectormetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	logsv1 "go.opentelemetry.io/proto/otlp/logs/v1"
+	metricsv1 "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	grpcgzip "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/deepflowio/deepflow/server/ingester/config"
+)
+
+func startTestReceiver(t *testing.T) (*Receiver, chan *collectormetrics.ExportMetricsServiceRequest, string, string) {
+	r := NewReceiver(&config.OTLPReceiver{Enabled: true, MaxMessageSize: 1, OrgID: 2, L3EpcID: 3})
+	requests := make(chan *collectormetrics.ExportMetricsServiceRequest, 10)
+	r.RegisterMetricsHandler(func(source *Source, request *collectormetrics.ExportMetricsServiceRequest) {
+		if source.OrgId != 2 || source.L3EpcID != 3 || !source.IP.IsLoopback() {
+			t.Errorf("unexpected source %+v", source)
+		}
+		requests <- request
+	})
+	grpcListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.serve(grpcListener, httpListener)
+	return r, requests, grpcListener.Addr().String(), "http://" + httpListener.Addr().String() + HTTP_METRICS_PATH
+}
+
+func testRequest() *collectormetrics.ExportMetricsServiceRequest {
+	return &collectormetrics.ExportMetricsServiceRequest{ResourceMetrics: []*metricsv1.ResourceMetrics{{
+		ScopeMetrics: []*metricsv1.ScopeMetrics{{Metrics: []*metricsv1.Metric{{
+			Name: "cpu",
+			Data: &metricsv1.Metric_Gauge{Gauge: &metricsv1.Gauge{DataPoints: []*metricsv1.NumberDataPoint{
+				{TimeUnixNano: 1, Value: &metricsv1.NumberDataPoint_AsDouble{AsDouble: 0.5}},
+			}}},
+		}}}},
+	}}}
+}
+
+func receive(t *testing.T, requests chan *collectormetrics.ExportMetricsServiceRequest) {
+	select {
+	case request := <-requests:
+		if !proto.Equal(request, testRequest()) {
+			t.Errorf("unexpected request %v", request)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("request not received")
+	}
+}
+
+func TestHTTPMetrics(t *testing.T) {
+	r, requests, _, url := startTestReceiver(t)
+	defer r.Close()
+
+	body, _ := proto.Marshal(testRequest())
+	resp, err := http.Post(url, CONTENT_TYPE_PROTOBUF, bytes.NewReader(body))
+	if err != nil || resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != CONTENT_TYPE_PROTOBUF {
+		t.Fatalf("post protobuf failed: %v %v", err, resp)
+	}
+	receive(t, requests)
+
+	body, _ = protojson.Marshal(testRequest())
+	compressed := &bytes.Buffer{}
+	writer := gzip.NewWriter(compressed)
+	writer.Write(body)
+	writer.Close()
+	req, _ := http.NewRequest(http.MethodPost, url, compressed)
+	req.Header.Set("Content-Type", CONTENT_TYPE_JSON)
+	req.Header.Set("Content-Encoding", "gzip")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil || resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != CONTENT_TYPE_JSON {
+		t.Fatalf("post gzip json failed: %v %v", err, resp)
+	}
+	receive(t, requests)
+
+	if resp, err = http.Post(url, "text/plain", bytes.NewReader(body)); err != nil || resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("expected status 415, got %v %v", err, resp)
+	}
+	if resp, err = http.Post(url, CONTENT_TYPE_PROTOBUF, bytes.NewReader([]byte("invalid"))); err != nil || resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %v %v", err, resp)
+	}
+	if resp, err = http.Post(url, CONTENT_TYPE_PROTOBUF, bytes.NewReader(make([]byte, 2<<20))); err != nil || resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 for too large body, got %v %v", err, resp)
+	}
+	if resp, err = http.Get(url); err != nil || resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405, got %v %v", err, resp)
+	}
+	if len(requests) != 0 {
+		t.Errorf("expected no more requests, got %d", len(requests))
+	}
+}
+
+func TestGRPCMetrics(t *testing.T) {
+	r, requests, addr, _ := startTestReceiver(t)
+	defer r.Close()
+
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client := collectormetrics.NewMetricsServiceClient(conn)
+	if _, err := client.Export(ctx, testRequest(), grpc.UseCompressor(grpcgzip.Name)); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, requests)
+}
+
+func TestLogs(t *testing.T) {
+	r := NewReceiver(&config.OTLPReceiver{Enabled: true, MaxMessageSize: 1})
+	requests := make(chan *collectorlogs.ExportLogsServiceRequest, 10)
+	r.RegisterLogsHandler(func(source *Source, request *collectorlogs.ExportLogsServiceRequest) {
+		requests <- request
+	})
+	grpcListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.serve(grpcListener, httpListener)
+	defer r.Close()
+
+	expected := &collectorlogs.ExportLogsServiceRequest{ResourceLogs: []*logsv1.ResourceLogs{{
+		ScopeLogs: []*logsv1.ScopeLogs{{LogRecords: []*logsv1.LogRecord{{TimeUnixNano: 1, SeverityText: "INFO"}}}},
+	}}}
+	receiveLogs := func() {
+		select {
+		case request := <-requests:
+			if !proto.Equal(request, expected) {
+				t.Errorf("unexpected request %v", request)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("request not received")
+		}
+	}
+
+	baseURL := "http://" + httpListener.Addr().String()
+	body, _ := proto.Marshal(expected)
+	resp, err := http.Post(baseURL+HTTP_LOGS_PATH, CONTENT_TYPE_PROTOBUF, bytes.NewReader(body))
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("post logs failed: %v %v", err, resp)
+	}
+	receiveLogs()
+	// metrics handler is not registered
+	if resp, err = http.Post(baseURL+HTTP_METRICS_PATH, CONTENT_TYPE_PROTOBUF, bytes.NewReader(body)); err != nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected status 404, got %v %v", err, resp)
+	}
+
+	conn, err := grpc.Dial(grpcListener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := collectorlogs.NewLogsServiceClient(conn).Export(ctx, expected); err != nil {
+		t.Fatal(err)
+	}
+	receiveLogs()
+}
@@ -114,6 +114,7 @@ const (
 	LowCardinalityString
 	ArrayLowCardinalityString
 	ENUM8
+	MapUInt8UInt64
 )
 
 var cloumnTypeString = []string{
@@ -152,6 +153,7 @@ var cloumnTypeString = []string{
 	LowCardinalityString:      "LowCardinality(String)",
 	ArrayLowCardinalityString: "Array(LowCardinality(String))",
 	ENUM8:                     "Enum8(%s)",
+	MapUInt8UInt64:            "Map(UInt8, UInt64)",
 }
 
 func (t ColumnType) HasDFTimeZone() bool {
@@ -0,0 +1,149 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package codec
+
+import (
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// protobuf wire format helpers for messages without generated go types, such as
+// messages of dynamic schemas or of third party protos which are not vendored
+
+// ConsumeProtoFields calls f with every field of message b, f returns the length
+// of the field value it consumed, or 0 to skip the field
+func ConsumeProtoFields(b []byte, f func(num protowire.Number, typ protowire.Type, b []byte) (int, error)) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		n, err := f(num, typ, b)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+func ConsumeProtoString(typ protowire.Type, b []byte, v *string) (int, error) {
+	if typ != protowire.BytesType {
+		return 0, fmt.Errorf("invalid wire type %d of string field", typ)
+	}
+	s, n := protowire.ConsumeString(b)
+	if n < 0 {
+		return 0, protowire.ParseError(n)
+	}
+	*v = s
+	return n, nil
+}
+
+// ConsumeProtoStrings appends the value of a repeated string field to v
+func ConsumeProtoStrings(typ protowire.Type, b []byte, v *[]string) (int, error) {
+	var s string
+	n, err := ConsumeProtoString(typ, b, &s)
+	if err == nil {
+		*v = append(*v, s)
+	}
+	return n, err
+}
+
+func ConsumeProtoVarint(typ protowire.Type, b []byte, v *uint64) (int, error) {
+	if typ != protowire.VarintType {
+		return 0, fmt.Errorf("invalid wire type %d of varint field", typ)
+	}
+	x, n := protowire.ConsumeVarint(b)
+	if n < 0 {
+		return 0, protowire.ParseError(n)
+	}
+	*v = x
+	return n, nil
+}
+
+func ConsumeProtoDouble(typ protowire.Type, b []byte, v *float64) (int, error) {
+	if typ != protowire.Fixed64Type {
+		return 0, fmt.Errorf("invalid wire type %d of double field", typ)
+	}
+	x, n := protowire.ConsumeFixed64(b)
+	if n < 0 {
+		return 0, protowire.ParseError(n)
+	}
+	*v = math.Float64frombits(x)
+	return n, nil
+}
+
+func AppendProtoString(b []byte, num protowire.Number, v string) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+// AppendProtoVarint appends an int32, int64, uint32, uint64 or bool field
+func AppendProtoVarint(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func AppendProtoDouble(b []byte, num protowire.Number, v float64) []byte {
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(v))
+}
+
+// AppendProtoMessage appends an embedded message field, m is the encoded message
+func AppendProtoMessage(b []byte, num protowire.Number, m []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m)
+}
+
+// AppendProtoPackedVarints appends a repeated int64 field, repeated scalars are packed by default in proto3
+func AppendProtoPackedVarints(b []byte, num protowire.Number, v []int64) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	size := 0
+	for _, x := range v {
+		size += protowire.SizeVarint(uint64(x))
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	b = protowire.AppendVarint(b, uint64(size))
+	for _, x := range v {
+		b = protowire.AppendVarint(b, uint64(x))
+	}
+	return b
+}
+
+// AppendProtoPackedDoubles appends a repeated double field, repeated scalars are packed by default in proto3
+func AppendProtoPackedDoubles(b []byte, num protowire.Number, v []float64) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	b = protowire.AppendVarint(b, uint64(len(v)*8))
+	for _, x := range v {
+		b = protowire.AppendFixed64(b, math.Float64bits(x))
+	}
+	return b
+}
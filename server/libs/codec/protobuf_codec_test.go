@@ -0,0 +1,74 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package codec
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestProtoCodec(t *testing.T) {
+	var b []byte
+	b = AppendProtoString(b, 1, "a")
+	b = AppendProtoString(b, 1, "")
+	b = AppendProtoVarint(b, 2, 1<<40)
+	b = AppendProtoDouble(b, 3, 1.5)
+	b = AppendProtoMessage(b, 4, AppendProtoString(nil, 1, "nested"))
+	b = AppendProtoPackedVarints(b, 5, []int64{1, 300, -1})
+	b = AppendProtoPackedDoubles(b, 6, []float64{0.5, 2})
+
+	var strs []string
+	var varint uint64
+	var double float64
+	var nested string
+	skipped := []protowire.Number{}
+	err := ConsumeProtoFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch num {
+		case 1:
+			return ConsumeProtoStrings(typ, b, &strs)
+		case 2:
+			return ConsumeProtoVarint(typ, b, &varint)
+		case 3:
+			return ConsumeProtoDouble(typ, b, &double)
+		case 4:
+			m, n := protowire.ConsumeBytes(b)
+			return n, ConsumeProtoFields(m, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+				return ConsumeProtoString(typ, b, &nested)
+			})
+		}
+		skipped = append(skipped, num)
+		return 0, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(strs, []string{"a", ""}) || varint != 1<<40 || double != 1.5 || nested != "nested" {
+		t.Fatalf("unexpected values %v %d %f %s", strs, varint, double, nested)
+	}
+	if !reflect.DeepEqual(skipped, []protowire.Number{5, 6}) {
+		t.Fatalf("unexpected skipped fields %v", skipped)
+	}
+
+	if _, err := ConsumeProtoVarint(protowire.BytesType, nil, &varint); err == nil {
+		t.Fatal("wire type mismatch should fail")
+	}
+	if err := ConsumeProtoFields(b[:len(b)-1], func(protowire.Number, protowire.Type, []byte) (int, error) { return 0, nil }); err == nil {
+		t.Fatal("truncated message should fail")
+	}
+}
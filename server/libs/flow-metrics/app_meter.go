@@ -198,6 +198,8 @@ type AppLatency struct {
 	RRTMax   uint32 `json:"rrt_max" category:"$metrics" sub:"delay"` // us
 	RRTSum   uint64 `json:"rrt_sum" category:"$metrics" sub:"delay"` // us
 	RRTCount uint32 `json:"rrt_count" category:"$metrics" sub:"delay"`
+	// 请求响应时延分布，用于计算分位数
+	RRTSketch LatencySketch
 }
 
 func (_ *AppLatency) Reverse() {
@@ -208,12 +210,14 @@ func (l *AppLatency) WriteToPB(p *pb.AppLatency) {
 	p.RrtMax = l.RRTMax
 	p.RrtSum = l.RRTSum
 	p.RrtCount = l.RRTCount
+	l.RRTSketch.WriteToPB(&p.RrtBucketIndexes, &p.RrtBucketCounts)
 }
 
 func (l *AppLatency) ReadFromPB(p *pb.AppLatency) {
 	l.RRTMax = p.RrtMax
 	l.RRTSum = p.RrtSum
 	l.RRTCount = p.RrtCount
+	l.RRTSketch.ReadFromPB(p.RrtBucketIndexes, p.RrtBucketCounts)
 }
 
 func (l *AppLatency) ConcurrentMerge(other *AppLatency) {
@@ -222,6 +226,7 @@ func (l *AppLatency) ConcurrentMerge(other *AppLatency) {
 	}
 	l.RRTSum += other.RRTSum
 	l.RRTCount += other.RRTCount
+	l.RRTSketch.Merge(&other.RRTSketch)
 }
 
 func (l *AppLatency) SequentialMerge(other *AppLatency) {
@@ -238,6 +243,7 @@ const (
 	APPLATENCY_RRT_MAX = iota
 	APPLATENCY_RRT_SUM
 	APPLATENCY_RRT_COUNT
+	APPLATENCY_RRT_BUCKETS
 )
 
 // Columns列和WriteBlock的列需要按顺序一一对应
@@ -246,12 +252,13 @@ func AppLatencyColumns() []*ckdb.Column {
 	columns = append(columns, ckdb.NewColumn("rrt_max", ckdb.UInt32).SetComment("所有请求响应时延最大值(us)"))
 	columns = append(columns, ckdb.NewColumn("rrt_sum", ckdb.Float64).SetComment("累计所有请求响应时延(us)"))
 	columns = append(columns, ckdb.NewColumn("rrt_count", ckdb.UInt64).SetComment("请求响应时延计算次数"))
+	columns = append(columns, ckdb.NewColumn("rrt_buckets", ckdb.MapUInt8UInt64).SetComment("请求响应时延分布, key为对数桶序号, value为次数"))
 	return columns
 }
 
 // WriteBlock和LatencyColumns的列需要按顺序一一对应
 func (l *AppLatency) WriteBlock(block *ckdb.Block) {
-	block.Write(l.RRTMax, float64(l.RRTSum), uint64(l.RRTCount))
+	block.Write(l.RRTMax, float64(l.RRTSum), uint64(l.RRTCount), l.RRTSketch.ToMap())
 }
 
 type AppAnomaly struct {
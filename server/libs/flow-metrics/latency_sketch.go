@@ -0,0 +1,164 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package flow_metrics
+
+import "math"
+
+// LatencySketch is a mergeable log-bucket histogram of latencies in microseconds.
+//
+// Bucket 0 holds latencies below 1us, bucket i (i >= 1) holds latencies in
+// [2^((i-1)/8), 2^(i/8)), so every power of two is split into
+// LATENCY_SKETCH_SUB_BUCKETS buckets and the relative error of a quantile is
+// about 4.4%. Only non-empty buckets are kept, sorted by index.
+//
+// Merge always builds new slices, so shallow copies of a meter (Clone) never
+// share mutable state.
+type LatencySketch struct {
+	Indexes []uint8
+	Counts  []uint32
+}
+
+const (
+	LATENCY_SKETCH_SUB_BUCKETS = 8
+	LATENCY_SKETCH_MAX_INDEX   = math.MaxUint8
+)
+
+// LatencySketchIndex returns the bucket index of a latency in microseconds
+func LatencySketchIndex(us uint32) uint8 {
+	if us == 0 {
+		return 0
+	}
+	index := int(math.Floor(math.Log2(float64(us))*LATENCY_SKETCH_SUB_BUCKETS)) + 1
+	if index > LATENCY_SKETCH_MAX_INDEX {
+		index = LATENCY_SKETCH_MAX_INDEX
+	}
+	return uint8(index)
+}
+
+// LatencySketchValue returns the representative latency (us) of a bucket,
+// which is the geometric middle of the bucket.
+// The querier uses the same formula in ClickHouse SQL, keep them consistent.
+func LatencySketchValue(index uint8) float64 {
+	if index == 0 {
+		return 0
+	}
+	return math.Pow(2, (float64(index)-0.5)/LATENCY_SKETCH_SUB_BUCKETS)
+}
+
+func (s *LatencySketch) Add(us uint32) {
+	s.Merge(&LatencySketch{Indexes: []uint8{LatencySketchIndex(us)}, Counts: []uint32{1}})
+}
+
+func (s *LatencySketch) IsEmpty() bool {
+	return len(s.Indexes) == 0
+}
+
+func (s *LatencySketch) Count() uint64 {
+	count := uint64(0)
+	for _, c := range s.Counts {
+		count += uint64(c)
+	}
+	return count
+}
+
+func (s *LatencySketch) Merge(other *LatencySketch) {
+	if other.IsEmpty() {
+		return
+	}
+	n := len(s.Indexes) + len(other.Indexes)
+	indexes := make([]uint8, 0, n)
+	counts := make([]uint32, 0, n)
+	i, j := 0, 0
+	for i < len(s.Indexes) && j < len(other.Indexes) {
+		switch {
+		case s.Indexes[i] < other.Indexes[j]:
+			indexes = append(indexes, s.Indexes[i])
+			counts = append(counts, s.Counts[i])
+			i++
+		case s.Indexes[i] > other.Indexes[j]:
+			indexes = append(indexes, other.Indexes[j])
+			counts = append(counts, other.Counts[j])
+			j++
+		default:
+			indexes = append(indexes, s.Indexes[i])
+			counts = append(counts, s.Counts[i]+other.Counts[j])
+			i++
+			j++
+		}
+	}
+	indexes = append(indexes, s.Indexes[i:]...)
+	counts = append(counts, s.Counts[i:]...)
+	indexes = append(indexes, other.Indexes[j:]...)
+	counts = append(counts, other.Counts[j:]...)
+	s.Indexes, s.Counts = indexes, counts
+}
+
+// Quantile returns the latency (us) at quantile q (0 <= q <= 1), returns 0 if the sketch is empty
+func (s *LatencySketch) Quantile(q float64) float64 {
+	total := s.Count()
+	if total == 0 {
+		return 0
+	}
+	rank := q * float64(total)
+	cumulative := uint64(0)
+	for i, c := range s.Counts {
+		cumulative += uint64(c)
+		if float64(cumulative) >= rank {
+			return LatencySketchValue(s.Indexes[i])
+		}
+	}
+	return LatencySketchValue(s.Indexes[len(s.Indexes)-1])
+}
+
+func (s *LatencySketch) WriteToPB(indexes, counts *[]uint32) {
+	*indexes = (*indexes)[:0]
+	*counts = (*counts)[:0]
+	for i, index := range s.Indexes {
+		*indexes = append(*indexes, uint32(index))
+		*counts = append(*counts, s.Counts[i])
+	}
+}
+
+// ReadFromPB tolerates unsorted, duplicated or out of range buckets sent by agents
+func (s *LatencySketch) ReadFromPB(indexes, counts []uint32) {
+	s.Indexes, s.Counts = nil, nil
+	if len(indexes) == 0 || len(indexes) != len(counts) {
+		return
+	}
+	var buckets [LATENCY_SKETCH_MAX_INDEX + 1]uint32
+	for i, index := range indexes {
+		if index > LATENCY_SKETCH_MAX_INDEX {
+			index = LATENCY_SKETCH_MAX_INDEX
+		}
+		buckets[index] += counts[i]
+	}
+	for i, c := range buckets {
+		if c > 0 {
+			s.Indexes = append(s.Indexes, uint8(i))
+			s.Counts = append(s.Counts, c)
+		}
+	}
+}
+
+// ToMap returns the value of the ClickHouse Map(UInt8, UInt64) column
+func (s *LatencySketch) ToMap() map[uint8]uint64 {
+	m := make(map[uint8]uint64, len(s.Indexes))
+	for i, index := range s.Indexes {
+		m[index] = uint64(s.Counts[i])
+	}
+	return m
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package flow_metrics
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestLatencySketchIndex(t *testing.T) {
+	if LatencySketchIndex(0) != 0 {
+		t.Error("0us should be in bucket 0")
+	}
+	if LatencySketchIndex(1) != 1 {
+		t.Error("1us should be in bucket 1")
+	}
+	if LatencySketchIndex(math.MaxUint32) != LATENCY_SKETCH_MAX_INDEX {
+		t.Error("index should be clamped")
+	}
+	for _, us := range []uint32{1, 7, 100, 999, 123456, 10000000} {
+		v := LatencySketchValue(LatencySketchIndex(us))
+		if math.Abs(v-float64(us))/float64(us) > 0.05 {
+			t.Errorf("value of %dus is %f, relative error too large", us, v)
+		}
+	}
+}
+
+func TestLatencySketchMerge(t *testing.T) {
+	a := LatencySketch{}
+	for _, us := range []uint32{1, 100, 100, 10000} {
+		a.Add(us)
+	}
+	b := LatencySketch{}
+	for _, us := range []uint32{0, 100, 1000} {
+		b.Add(us)
+	}
+	shared := a
+	a.Merge(&b)
+	if a.Count() != 7 || shared.Count() != 4 {
+		t.Errorf("merge result count %d, shallow copy count %d", a.Count(), shared.Count())
+	}
+	for i := 1; i < len(a.Indexes); i++ {
+		if a.Indexes[i-1] >= a.Indexes[i] {
+			t.Errorf("indexes not sorted: %v", a.Indexes)
+		}
+	}
+	if a.ToMap()[LatencySketchIndex(100)] != 3 {
+		t.Errorf("bucket of 100us: %v", a.ToMap())
+	}
+}
+
+func TestLatencySketchQuantile(t *testing.T) {
+	s := LatencySketch{}
+	if s.Quantile(0.99) != 0 {
+		t.Error("quantile of empty sketch should be 0")
+	}
+	for i := uint32(1); i <= 1000; i++ {
+		s.Add(i * 1000)
+	}
+	for _, q := range []float64{0.5, 0.9, 0.99} {
+		expected := q * 1000 * 1000
+		if v := s.Quantile(q); math.Abs(v-expected)/expected > 0.05 {
+			t.Errorf("quantile %f is %f, expected about %f", q, v, expected)
+		}
+	}
+}
+
+func TestLatencySketchPB(t *testing.T) {
+	s := LatencySketch{}
+	s.ReadFromPB([]uint32{9, 3, 9, 300}, []uint32{1, 2, 3, 4})
+	if !reflect.DeepEqual(s.Indexes, []uint8{3, 9, LATENCY_SKETCH_MAX_INDEX}) || !reflect.DeepEqual(s.Counts, []uint32{2, 4, 4}) {
+		t.Errorf("ReadFromPB result %v %v", s.Indexes, s.Counts)
+	}
+
+	var indexes, counts []uint32
+	s.WriteToPB(&indexes, &counts)
+	if !reflect.DeepEqual(indexes, []uint32{3, 9, LATENCY_SKETCH_MAX_INDEX}) || !reflect.DeepEqual(counts, []uint32{2, 4, 4}) {
+		t.Errorf("WriteToPB result %v %v", indexes, counts)
+	}
+
+	s.ReadFromPB([]uint32{1}, nil)
+	if !s.IsEmpty() {
+		t.Error("mismatched pb buckets should be ignored")
+	}
+}
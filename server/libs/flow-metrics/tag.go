@@ -94,6 +94,7 @@ const (
 )
 
 const (
+	GeoIPPath  Code = 1 << 61 // filled by ingester from GeoIP databases
 	TunnelIPID Code = 1 << 62
 )
 
@@ -335,6 +336,18 @@ type Field struct {
 	TagSource, TagSource1 uint8
 
 	TunnelIPID uint16
+
+	// 由ingester根据GeoIP数据库填充
+	Country0     string `json:"country_0" category:"$tag" sub:"network_layer" datasource:"nm"`
+	Country1     string `json:"country_1" category:"$tag" sub:"network_layer" datasource:"nm"`
+	Subdivision0 string `json:"subdivision_0" category:"$tag" sub:"network_layer" datasource:"nm"`
+	Subdivision1 string `json:"subdivision_1" category:"$tag" sub:"network_layer" datasource:"nm"`
+	City0        string `json:"city_0" category:"$tag" sub:"network_layer" datasource:"nm"`
+	City1        string `json:"city_1" category:"$tag" sub:"network_layer" datasource:"nm"`
+	ASN0         uint32 `json:"asn_0" category:"$tag" sub:"network_layer" datasource:"nm"`
+	ASN1         uint32 `json:"asn_1" category:"$tag" sub:"network_layer" datasource:"nm"`
+	ASOrg0       string `json:"as_org_0" category:"$tag" sub:"network_layer" datasource:"nm"`
+	ASOrg1       string `json:"as_org_1" category:"$tag" sub:"network_layer" datasource:"nm"`
 }
 
 func newMetricsMinuteTable(id MetricsTableID, engine ckdb.EngineType, version, cluster, storagePolicy, ckdbType string, ttl int, coldStorage *ckdb.ColdStorage) *ckdb.Table {
@@ -479,7 +492,7 @@ const (
 	BasePortCode = Protocol | ServerPort | IsKeyService
 
 	NETWORK         = BaseCode | BasePortCode | Direction
-	NETWORK_MAP     = BasePathCode | BasePortCode | TAPPort
+	NETWORK_MAP     = BasePathCode | BasePortCode | TAPPort | GeoIPPath
 	APPLICATION     = BaseCode | BasePortCode | Direction | L7Protocol
 	APPLICATION_MAP = BasePathCode | BasePortCode | TAPPort | L7Protocol
 
@@ -586,6 +599,20 @@ func (t *Tag) MarshalTo(b []byte) int {
 		offset += copy(b[offset:], ",acl_gid=")
 		offset += copy(b[offset:], strconv.FormatUint(uint64(t.ACLGID), 10))
 	}
+	if t.Code&GeoIPPath != 0 {
+		offset += copy(b[offset:], ",as_org_0="+t.ASOrg0)
+		offset += copy(b[offset:], ",as_org_1="+t.ASOrg1)
+		offset += copy(b[offset:], ",asn_0=")
+		offset += copy(b[offset:], strconv.FormatUint(uint64(t.ASN0), 10))
+		offset += copy(b[offset:], ",asn_1=")
+		offset += copy(b[offset:], strconv.FormatUint(uint64(t.ASN1), 10))
+		offset += copy(b[offset:], ",city_0="+t.City0)
+		offset += copy(b[offset:], ",city_1="+t.City1)
+		offset += copy(b[offset:], ",country_0="+t.Country0)
+		offset += copy(b[offset:], ",country_1="+t.Country1)
+		offset += copy(b[offset:], ",subdivision_0="+t.Subdivision0)
+		offset += copy(b[offset:], ",subdivision_1="+t.Subdivision1)
+	}
 	if t.Code&AZID != 0 {
 		offset += copy(b[offset:], ",az_id=")
 		offset += copy(b[offset:], strconv.FormatUint(uint64(t.AZID), 10))
@@ -914,6 +941,18 @@ func GenTagColumns(code Code) []*ckdb.Column {
 	if code&ACLGID != 0 {
 		columns = append(columns, ckdb.NewColumnWithGroupBy("acl_gid", ckdb.UInt16).SetComment("ACL组ID"))
 	}
+	if code&GeoIPPath != 0 {
+		columns = append(columns, ckdb.NewColumnWithGroupBy("as_org_0", ckdb.LowCardinalityString).SetComment("ip4/6_0对应的AS组织"))
+		columns = append(columns, ckdb.NewColumnWithGroupBy("as_org_1", ckdb.LowCardinalityString).SetComment("ip4/6_1对应的AS组织"))
+		columns = append(columns, ckdb.NewColumnWithGroupBy("asn_0", ckdb.UInt32).SetComment("ip4/6_0对应的AS号"))
+		columns = append(columns, ckdb.NewColumnWithGroupBy("asn_1", ckdb.UInt32).SetComment("ip4/6_1对应的AS号"))
+		columns = append(columns, ckdb.NewColumnWithGroupBy("city_0", ckdb.LowCardinalityString).SetComment("ip4/6_0对应的城市"))
+		columns = append(columns, ckdb.NewColumnWithGroupBy("city_1", ckdb.LowCardinalityString).SetComment("ip4/6_1对应的城市"))
+		columns = append(columns, ckdb.NewColumnWithGroupBy("country_0", ckdb.LowCardinalityString).SetComment("ip4/6_0对应的国家, ISO 3166-1 alpha-2"))
+		columns = append(columns, ckdb.NewColumnWithGroupBy("country_1", ckdb.LowCardinalityString).SetComment("ip4/6_1对应的国家, ISO 3166-1 alpha-2"))
+		columns = append(columns, ckdb.NewColumnWithGroupBy("subdivision_0", ckdb.LowCardinalityString).SetComment("ip4/6_0对应的省/州"))
+		columns = append(columns, ckdb.NewColumnWithGroupBy("subdivision_1", ckdb.LowCardinalityString).SetComment("ip4/6_1对应的省/州"))
+	}
 	if code&AZID != 0 {
 		columns = append(columns, ckdb.NewColumnWithGroupBy("az_id", ckdb.UInt16).SetComment("可用区ID"))
 	}
@@ -1127,6 +1166,9 @@ func (t *Tag) WriteBlock(block *ckdb.Block, time uint32) {
 	if code&ACLGID != 0 {
 		block.Write(t.ACLGID)
 	}
+	if code&GeoIPPath != 0 {
+		block.Write(t.ASOrg0, t.ASOrg1, t.ASN0, t.ASN1, t.City0, t.City1, t.Country0, t.Country1, t.Subdivision0, t.Subdivision1)
+	}
 	if code&AZID != 0 {
 		block.Write(t.AZID)
 	}
@@ -0,0 +1,302 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package geo
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/deepflowio/deepflow/server/libs/utils"
+)
+
+const (
+	DEFAULT_GEOIP_RELOAD_INTERVAL = time.Minute
+	GEOIP_CACHE_SIZE_PER_DATABASE = 1 << 20
+)
+
+type IPGeo struct {
+	Country     string // ISO 3166-1 alpha-2
+	Subdivision string
+	City        string
+	ASN         uint32
+	ASOrg       string
+}
+
+func (g *IPGeo) IsEmpty() bool {
+	return *g == IPGeo{}
+}
+
+// merge fills the empty fields of g with other
+func (g *IPGeo) merge(other *IPGeo) {
+	if g.Country == "" {
+		g.Country = other.Country
+	}
+	if g.Subdivision == "" {
+		g.Subdivision = other.Subdivision
+	}
+	if g.City == "" {
+		g.City = other.City
+	}
+	if g.ASN == 0 {
+		g.ASN = other.ASN
+	}
+	if g.ASOrg == "" {
+		g.ASOrg = other.ASOrg
+	}
+}
+
+// 依次尝试 MaxMind(GeoIP2/GeoLite2 City, Country, ASN) 和 IPinfo 的字段布局
+var (
+	countryPaths = [][]interface{}{
+		{"country", "iso_code"},
+		{"registered_country", "iso_code"},
+		{"country"},
+		{"country_code"},
+	}
+	subdivisionPaths = [][]interface{}{
+		{"subdivisions", 0, "names", "en"},
+		{"region"},
+	}
+	cityPaths = [][]interface{}{
+		{"city", "names", "en"},
+		{"city"},
+	}
+	asnPaths = [][]interface{}{
+		{"autonomous_system_number"},
+		{"asn"},
+	}
+	asOrgPaths = [][]interface{}{
+		{"autonomous_system_organization"},
+		{"as_name"},
+	}
+)
+
+func decodeString(record interface{}, paths [][]interface{}) string {
+	for _, path := range paths {
+		value, _ := valueAt(record, path...)
+		if s, ok := value.(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func decodeASN(record interface{}, paths [][]interface{}) uint32 {
+	for _, path := range paths {
+		value, found := valueAt(record, path...)
+		if !found {
+			continue
+		}
+		if s, ok := value.(string); ok {
+			// IPinfo: "AS15169"
+			if asn, err := strconv.ParseUint(strings.TrimPrefix(strings.ToUpper(s), "AS"), 10, 32); err == nil {
+				return uint32(asn)
+			}
+		} else if asn := toUint64(value); asn != 0 {
+			return uint32(asn)
+		}
+	}
+	return 0
+}
+
+// LookupGeo returns the geo information of ip, found is false if the ip is not in the database
+func (r *MMDBReader) LookupGeo(ip net.IP) (geo IPGeo, found bool, err error) {
+	offset, found, err := r.Lookup(ip)
+	if err != nil || !found {
+		return geo, false, err
+	}
+	geo, err = r.decodeGeo(offset)
+	return geo, err == nil, err
+}
+
+func (r *MMDBReader) decodeGeo(offset uintptr) (IPGeo, error) {
+	record, err := r.Decode(offset)
+	if err != nil {
+		return IPGeo{}, err
+	}
+	return IPGeo{
+		Country:     decodeString(record, countryPaths),
+		Subdivision: decodeString(record, subdivisionPaths),
+		City:        decodeString(record, cityPaths),
+		ASN:         decodeASN(record, asnPaths),
+		ASOrg:       decodeString(record, asOrgPaths),
+	}, nil
+}
+
+type geoDatabase struct {
+	file    string
+	modTime time.Time
+	reader  *MMDBReader
+
+	// 同一网段的 IP 指向同一条记录, 按记录的 offset 缓存解码结果
+	cache     sync.Map
+	cacheSize int32
+}
+
+func openGeoDatabase(file string) (*geoDatabase, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := NewMMDBReader(buf)
+	if err != nil {
+		return nil, fmt.Errorf("load mmdb file %s failed: %s", file, err)
+	}
+	return &geoDatabase{file: file, modTime: info.ModTime(), reader: reader}, nil
+}
+
+func (d *geoDatabase) query(ip net.IP) (*IPGeo, error) {
+	offset, found, err := d.reader.Lookup(ip)
+	if err != nil || !found {
+		return nil, err
+	}
+	if geo, ok := d.cache.Load(offset); ok {
+		return geo.(*IPGeo), nil
+	}
+	geo, err := d.reader.decodeGeo(offset)
+	if err != nil {
+		return nil, err
+	}
+	if atomic.LoadInt32(&d.cacheSize) < GEOIP_CACHE_SIZE_PER_DATABASE {
+		if _, loaded := d.cache.LoadOrStore(offset, &geo); !loaded {
+			atomic.AddInt32(&d.cacheSize, 1)
+		}
+	}
+	return &geo, nil
+}
+
+// GeoIP queries geo information from one or more mmdb files, e.g. a City database and an ASN database,
+// files are reloaded when they are modified.
+type GeoIP struct {
+	files          []string
+	reloadInterval time.Duration
+	databases      atomic.Value // []*geoDatabase
+	queryErrors    uint64
+	exit           chan struct{}
+	wg             sync.WaitGroup
+}
+
+func NewGeoIP(files []string, reloadInterval time.Duration) (*GeoIP, error) {
+	if reloadInterval <= 0 {
+		reloadInterval = DEFAULT_GEOIP_RELOAD_INTERVAL
+	}
+	g := &GeoIP{
+		files:          files,
+		reloadInterval: reloadInterval,
+		exit:           make(chan struct{}),
+	}
+	databases := make([]*geoDatabase, 0, len(files))
+	for _, file := range files {
+		database, err := openGeoDatabase(file)
+		if err != nil {
+			return nil, err
+		}
+		log.Infof("load geoip database %s, type %s, build epoch %d", file, database.reader.Metadata.DatabaseType, database.reader.Metadata.BuildEpoch)
+		databases = append(databases, database)
+	}
+	g.databases.Store(databases)
+	return g, nil
+}
+
+// Query merges the results of all databases in order, returns an empty IPGeo if nothing found
+func (g *GeoIP) Query(ip net.IP) IPGeo {
+	result := IPGeo{}
+	if g == nil {
+		return result
+	}
+	for _, database := range g.databases.Load().([]*geoDatabase) {
+		geo, err := database.query(ip)
+		if err != nil {
+			// 数据损坏时避免刷屏
+			if atomic.AddUint64(&g.queryErrors, 1)%10000 == 1 {
+				log.Warningf("query %s in geoip database %s failed: %s", ip, database.file, err)
+			}
+			continue
+		}
+		if geo != nil {
+			result.merge(geo)
+		}
+	}
+	return result
+}
+
+func (g *GeoIP) QueryIPv4(ip uint32) IPGeo {
+	if g == nil {
+		return IPGeo{}
+	}
+	return g.Query(utils.IpFromUint32(ip))
+}
+
+func (g *GeoIP) reload() {
+	databases := g.databases.Load().([]*geoDatabase)
+	newDatabases := make([]*geoDatabase, 0, len(databases))
+	reloaded := false
+	for _, database := range databases {
+		info, err := os.Stat(database.file)
+		if err != nil || info.ModTime().Equal(database.modTime) {
+			newDatabases = append(newDatabases, database)
+			continue
+		}
+		newDatabase, err := openGeoDatabase(database.file)
+		if err != nil {
+			log.Warningf("reload geoip database failed, keep using the old one: %s", err)
+			newDatabases = append(newDatabases, database)
+			continue
+		}
+		log.Infof("reload geoip database %s, type %s, build epoch %d", database.file, newDatabase.reader.Metadata.DatabaseType, newDatabase.reader.Metadata.BuildEpoch)
+		newDatabases = append(newDatabases, newDatabase)
+		reloaded = true
+	}
+	if reloaded {
+		g.databases.Store(newDatabases)
+	}
+}
+
+func (g *GeoIP) run() {
+	defer g.wg.Done()
+	ticker := time.NewTicker(g.reloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-g.exit:
+			return
+		case <-ticker.C:
+			g.reload()
+		}
+	}
+}
+
+func (g *GeoIP) Start() {
+	g.wg.Add(1)
+	go g.run()
+}
+
+func (g *GeoIP) Close() error {
+	close(g.exit)
+	g.wg.Wait()
+	return nil
+}
@@ -0,0 +1,96 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package geo
+
+import (
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// MMDB(MaxMind DB) 格式说明: https://maxmind.github.io/MaxMind-DB/
+// MaxMind GeoLite2/GeoIP2 与 IPinfo 发布的 mmdb 文件均为此格式, 由 maxminddb-golang 解析,
+// 其解码器限制了数据结构的嵌套深度, 损坏的文件不会导致栈溢出
+
+type MMDBReader struct {
+	Metadata maxminddb.Metadata
+
+	reader *maxminddb.Reader
+}
+
+func NewMMDBReader(buf []byte) (*MMDBReader, error) {
+	reader, err := maxminddb.FromBytes(buf)
+	if err != nil {
+		return nil, err
+	}
+	return &MMDBReader{Metadata: reader.Metadata, reader: reader}, nil
+}
+
+// Lookup returns the offset of the record in data section, found is false if the ip is not in the database
+func (r *MMDBReader) Lookup(ip net.IP) (offset uintptr, found bool, err error) {
+	offset, err = r.reader.LookupOffset(ip)
+	if err != nil || offset == maxminddb.NotFound {
+		return 0, false, err
+	}
+	return offset, true, nil
+}
+
+// Decode decodes the whole record at offset, maps are decoded as map[string]interface{},
+// arrays as []interface{} and unsigned integers as uint64
+func (r *MMDBReader) Decode(offset uintptr) (interface{}, error) {
+	var value interface{}
+	err := r.reader.Decode(offset, &value)
+	return value, err
+}
+
+// valueAt returns the value at path of a decoded record, path elements are map keys(string) or array indexes(int),
+// found is false if the path does not exist
+func valueAt(value interface{}, path ...interface{}) (interface{}, bool) {
+	for _, p := range path {
+		switch key := p.(type) {
+		case string:
+			m, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if value, ok = m[key]; !ok {
+				return nil, false
+			}
+		case int:
+			a, ok := value.([]interface{})
+			if !ok || key < 0 || key >= len(a) {
+				return nil, false
+			}
+			value = a[key]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func toUint64(v interface{}) uint64 {
+	switch i := v.(type) {
+	case uint64:
+		return i
+	case int:
+		if i > 0 {
+			return uint64(i)
+		}
+	}
+	return 0
+}
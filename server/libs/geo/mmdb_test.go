@@ -0,0 +1,317 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package geo
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+var mmdbMetadataStartMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+const MMDB_DATA_SECTION_SEPARATOR_SIZE = 16
+
+type mmdbDataType uint8
+
+const (
+	mmdbExtended mmdbDataType = iota
+	mmdbPointer
+	mmdbString
+	mmdbFloat64
+	mmdbBytes
+	mmdbUint16
+	mmdbUint32
+	mmdbMap
+	mmdbInt32
+	mmdbUint64
+	mmdbUint128
+	mmdbArray
+	mmdbContainer
+	mmdbEndMarker
+	mmdbBool
+	mmdbFloat32
+)
+
+// mmdbWriter builds a minimal IPv6 mmdb with record size 24 for tests
+type mmdbWriter struct {
+	data    bytes.Buffer
+	records [][2]int64 // >= 0: node index, < 0: -(data offset)-1, mmdbEmptyRecord: empty
+	strings map[string]int
+}
+
+const mmdbEmptyRecord = int64(1) << 40
+
+func newMMDBWriter() *mmdbWriter {
+	return &mmdbWriter{records: [][2]int64{{mmdbEmptyRecord, mmdbEmptyRecord}}, strings: map[string]int{}}
+}
+
+func writeCtrl(buf *bytes.Buffer, dataType mmdbDataType, size int) {
+	sizeField, extended := size, []byte(nil)
+	if size >= 29 {
+		// 测试数据不超过 284 字节
+		sizeField, extended = 29, []byte{byte(size - 29)}
+	}
+	if dataType > mmdbMap {
+		buf.WriteByte(byte(sizeField))
+		buf.WriteByte(byte(dataType - 7))
+	} else {
+		buf.WriteByte(byte(dataType)<<5 | byte(sizeField))
+	}
+	buf.Write(extended)
+}
+
+func encodeMMDBValue(buf *bytes.Buffer, strings map[string]int, v interface{}) {
+	switch value := v.(type) {
+	case string:
+		// 重复的字符串使用指针, 用于测试指针解码
+		if offset, ok := strings[value]; ok && strings != nil {
+			buf.WriteByte(byte(mmdbPointer)<<5 | byte(offset>>8))
+			buf.WriteByte(byte(offset))
+			return
+		}
+		if strings != nil {
+			strings[value] = buf.Len()
+		}
+		writeCtrl(buf, mmdbString, len(value))
+		buf.WriteString(value)
+	case uint16:
+		writeCtrl(buf, mmdbUint16, 2)
+		buf.Write([]byte{byte(value >> 8), byte(value)})
+	case uint32:
+		writeCtrl(buf, mmdbUint32, 4)
+		buf.Write([]byte{byte(value >> 24), byte(value >> 16), byte(value >> 8), byte(value)})
+	case uint64:
+		writeCtrl(buf, mmdbUint64, 8)
+		for i := 7; i >= 0; i-- {
+			buf.WriteByte(byte(value >> (i * 8)))
+		}
+	case bool:
+		size := 0
+		if value {
+			size = 1
+		}
+		writeCtrl(buf, mmdbBool, size)
+	case []interface{}:
+		writeCtrl(buf, mmdbArray, len(value))
+		for _, e := range value {
+			encodeMMDBValue(buf, strings, e)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		writeCtrl(buf, mmdbMap, len(value))
+		for _, k := range keys {
+			encodeMMDBValue(buf, strings, k)
+			encodeMMDBValue(buf, strings, value[k])
+		}
+	default:
+		panic("unsupported value")
+	}
+}
+
+func (w *mmdbWriter) insert(cidr string, value map[string]interface{}) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	ones, _ := ipNet.Mask.Size()
+	ip := ipNet.IP.To16()
+	if ipNet.IP.To4() != nil {
+		// IPv4 位于 ::/96
+		ip = make(net.IP, net.IPv6len)
+		copy(ip[12:], ipNet.IP.To4())
+		ones += 96
+	}
+	offset := w.data.Len()
+	encodeMMDBValue(&w.data, w.strings, value)
+
+	node := 0
+	for i := 0; i < ones; i++ {
+		bit := (ip[i/8] >> (7 - i%8)) & 1
+		if i == ones-1 {
+			w.records[node][bit] = -int64(offset) - 1
+			break
+		}
+		next := w.records[node][bit]
+		if next == mmdbEmptyRecord || next < 0 {
+			w.records = append(w.records, [2]int64{mmdbEmptyRecord, mmdbEmptyRecord})
+			next = int64(len(w.records) - 1)
+			w.records[node][bit] = next
+		}
+		node = int(next)
+	}
+}
+
+func (w *mmdbWriter) bytes(ipVersion uint16) []byte {
+	buf := bytes.Buffer{}
+	nodeCount := int64(len(w.records))
+	for _, record := range w.records {
+		for _, r := range record {
+			value := r
+			if r == mmdbEmptyRecord {
+				value = nodeCount
+			} else if r < 0 {
+				value = nodeCount + MMDB_DATA_SECTION_SEPARATOR_SIZE - r - 1
+			}
+			buf.Write([]byte{byte(value >> 16), byte(value >> 8), byte(value)})
+		}
+	}
+	buf.Write(make([]byte, MMDB_DATA_SECTION_SEPARATOR_SIZE))
+	buf.Write(w.data.Bytes())
+	buf.Write(mmdbMetadataStartMarker)
+	encodeMMDBValue(&buf, nil, map[string]interface{}{
+		"node_count":    uint32(nodeCount),
+		"record_size":   uint16(24),
+		"ip_version":    ipVersion,
+		"database_type": "Test",
+		"build_epoch":   uint64(1700000000),
+	})
+	return buf.Bytes()
+}
+
+func maxmindCityRecord(country, subdivision, city string) map[string]interface{} {
+	return map[string]interface{}{
+		"country":      map[string]interface{}{"iso_code": country, "names": map[string]interface{}{"en": country}},
+		"subdivisions": []interface{}{map[string]interface{}{"iso_code": "X", "names": map[string]interface{}{"en": subdivision}}},
+		"city":         map[string]interface{}{"names": map[string]interface{}{"en": city, "de": city}},
+		"location":     map[string]interface{}{"time_zone": "UTC", "accuracy_radius": uint16(10)},
+		"is_anycast":   true,
+	}
+}
+
+func TestMMDBReader(t *testing.T) {
+	w := newMMDBWriter()
+	w.insert("1.2.3.0/24", maxmindCityRecord("US", "California", "Mountain View"))
+	w.insert("2001:db8::/32", maxmindCityRecord("DE", "Hesse", "Frankfurt am Main"))
+	w.insert("10.0.0.0/8", map[string]interface{}{"country": "JP", "region": "Tokyo", "city": "Tokyo", "asn": "AS2497", "as_name": "Internet Initiative Japan Inc."})
+	r, err := NewMMDBReader(w.bytes(6))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		ip    string
+		found bool
+		geo   IPGeo
+	}{
+		{"1.2.3.4", true, IPGeo{Country: "US", Subdivision: "California", City: "Mountain View"}},
+		{"1.2.4.4", false, IPGeo{}},
+		{"2001:db8:1::1", true, IPGeo{Country: "DE", Subdivision: "Hesse", City: "Frankfurt am Main"}},
+		{"2001:db9::1", false, IPGeo{}},
+		{"10.1.1.1", true, IPGeo{Country: "JP", Subdivision: "Tokyo", City: "Tokyo", ASN: 2497, ASOrg: "Internet Initiative Japan Inc."}},
+	}
+	for _, c := range cases {
+		geo, found, err := r.LookupGeo(net.ParseIP(c.ip))
+		if err != nil || found != c.found || geo != c.geo {
+			t.Errorf("lookup %s: %+v %v %v, expected %+v", c.ip, geo, found, err, c.geo)
+		}
+	}
+
+	offset, _, _ := r.Lookup(net.ParseIP("1.2.3.4"))
+	value, err := r.Decode(offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value.(map[string]interface{})["is_anycast"] != true {
+		t.Errorf("decode record: %v", value)
+	}
+	v, found := valueAt(value, "location", "accuracy_radius")
+	if !found || v != uint64(10) {
+		t.Errorf("value at path: %v %v", v, found)
+	}
+	if _, found := valueAt(value, "subdivisions", 1); found {
+		t.Error("out of range array index should not be found")
+	}
+
+	// 嵌套过深的记录返回错误而不是栈溢出
+	var nested interface{} = "x"
+	for i := 0; i < 1024; i++ {
+		nested = []interface{}{nested}
+	}
+	w = newMMDBWriter()
+	w.insert("1.2.3.0/24", map[string]interface{}{"nested": nested})
+	r, err = NewMMDBReader(w.bytes(6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := r.LookupGeo(net.ParseIP("1.2.3.4")); err == nil {
+		t.Error("deeply nested record should fail")
+	}
+
+	if _, err := NewMMDBReader([]byte("not a mmdb file")); err == nil {
+		t.Error("invalid file should fail")
+	}
+}
+
+func TestGeoIPReload(t *testing.T) {
+	dir := t.TempDir()
+	cityFile := filepath.Join(dir, "city.mmdb")
+	asnFile := filepath.Join(dir, "asn.mmdb")
+
+	city := newMMDBWriter()
+	city.insert("1.2.3.0/24", maxmindCityRecord("US", "California", "Mountain View"))
+	asn := newMMDBWriter()
+	asn.insert("1.2.0.0/16", map[string]interface{}{"autonomous_system_number": uint32(15169), "autonomous_system_organization": "Google LLC"})
+	if err := os.WriteFile(cityFile, city.bytes(6), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(asnFile, asn.bytes(6), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := NewGeoIP([]string{cityFile, asnFile}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := IPGeo{Country: "US", Subdivision: "California", City: "Mountain View", ASN: 15169, ASOrg: "Google LLC"}
+	if geo := g.QueryIPv4(0x01020304); geo != expected {
+		t.Errorf("query result %+v, expected %+v", geo, expected)
+	}
+	if geo := g.Query(net.ParseIP("::1")); !geo.IsEmpty() {
+		t.Errorf("query result %+v, expected empty", geo)
+	}
+
+	city = newMMDBWriter()
+	city.insert("1.2.3.0/24", maxmindCityRecord("CA", "Ontario", "Toronto"))
+	if err := os.WriteFile(cityFile, city.bytes(6), 0644); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now().Add(time.Hour)
+	os.Chtimes(cityFile, modTime, modTime)
+	// 损坏的文件不会替换已加载的数据库
+	os.WriteFile(asnFile, []byte("broken"), 0644)
+	os.Chtimes(asnFile, modTime, modTime)
+	g.reload()
+
+	expected = IPGeo{Country: "CA", Subdivision: "Ontario", City: "Toronto", ASN: 15169, ASOrg: "Google LLC"}
+	if geo := g.QueryIPv4(0x01020304); geo != expected {
+		t.Errorf("query result after reload %+v, expected %+v", geo, expected)
+	}
+
+	var nilGeoIP *GeoIP
+	if geo := nilGeoIP.QueryIPv4(0x01020304); !geo.IsEmpty() {
+		t.Error("nil GeoIP should return empty result")
+	}
+}
@@ -384,6 +384,9 @@ type Receiver struct {
 	TCPReaderBuffer  int
 	TCPListener      net.Listener
 	TCPAddress       string
+	TLSListener      net.Listener
+	TLSAddress       string
+	certReloader     *certReloader
 	lastUDPFlushTime int64
 	lastTCPFlushTime int64
 	timeNow          int64
@@ -409,6 +412,9 @@ type ReceiverCounter struct {
 	UDPDisorder     uint64 `statsd:"udp_disorder"`      // 乱序个数
 	UDPDisorderSize uint64 `statsd:"udp_disorder_size"` // 乱序最大范围
 	NewBufferCount  uint64 `statsd:"new_buffer_count"`  // If the received data is large, you need to alloc memory, record the times.
+
+	TLSHandshakeFailed uint64 `statsd:"tls_handshake_failed"`
+	IdentityMismatch   uint64 `statsd:"identity_mismatch"` // frames which can not be checked against or mismatch the client certificate
 }
 
 func NewReceiver(
@@ -840,6 +846,12 @@ func ReadN(r *bufio.Reader, buffer []byte) error {
 }
 
 func (r *Receiver) handleTCPConnection(conn net.Conn) {
+	r.handleConnection(conn, nil)
+}
+
+// if identity is not nil, frames without FlowHeader, or whose agent id or org id in FlowHeader can not be checked or
+// mismatch it are rejected and the connection is closed
+func (r *Receiver) handleConnection(conn net.Conn, identity *PeerIdentity) {
 	defer conn.Close()
 	defer r.flushPutTCPQueues()
 	ip := parseRemoteIP(conn)
@@ -878,6 +890,13 @@ func (r *Receiver) handleTCPConnection(conn net.Conn) {
 			return
 		}
 
+		// frames without FlowHeader can not be attributed to the agent in the client certificate
+		if identity != nil && baseHeader.Type.HeaderType() != datatype.HEADER_TYPE_LT_VTAP {
+			atomic.AddUint64(&r.counter.IdentityMismatch, 1)
+			r.logTCPReceiveInvalidData(fmt.Sprintf("TLS client (%s) identity %s sent message type %d without flow header", conn.RemoteAddr().String(), identity, baseHeader.Type))
+			return
+		}
+
 		headerLen := datatype.MESSAGE_HEADER_LEN
 		metricsTimestamp, vtapID, teamID, orgID := uint32(0), uint16(0), uint32(0), uint16(0)
 		if baseHeader.Type.HeaderType() == datatype.HEADER_TYPE_LT_VTAP {
@@ -891,6 +910,19 @@ func (r *Receiver) handleTCPConnection(conn net.Conn) {
 
 			vtapID = flowHeader.AgentID
 			orgID, teamID = r.parseOrgIdTeamId(flowHeader)
+			if identity != nil {
+				// org id of old version headers or out of range is replaced by the default one, which can not be checked
+				if flowHeader.Version != datatype.LATEST_VERSION || flowHeader.OrgID > ckdb.MAX_ORG_ID {
+					atomic.AddUint64(&r.counter.IdentityMismatch, 1)
+					r.logTCPReceiveInvalidData(fmt.Sprintf("TLS client (%s) identity %s sent flow header version 0x%x org id %d which can not be checked", conn.RemoteAddr().String(), identity, flowHeader.Version, flowHeader.OrgID))
+					return
+				}
+				if !identity.Match(orgID, vtapID) {
+					atomic.AddUint64(&r.counter.IdentityMismatch, 1)
+					r.logTCPReceiveInvalidData(fmt.Sprintf("TLS client (%s) identity %s mismatch agent id %d org id %d in flow header", conn.RemoteAddr().String(), identity, vtapID, orgID))
+					return
+				}
+			}
 		}
 
 		dataLen := int(baseHeader.FrameSize) - headerLen
@@ -949,6 +981,13 @@ func (r *Receiver) Start() {
 		}
 		go r.ProcessTCPServer()
 	}
+	if r.certReloader != nil {
+		if r.TLSListener, err = net.Listen("tcp", r.TLSAddress); err != nil {
+			log.Errorf("TLS listen at %s failed: %s", r.TLSAddress, err)
+			os.Exit(-1)
+		}
+		go r.ProcessTLSServer()
+	}
 
 	stats.RegisterCountableWithModulePrefix("ingester_", "recviver", r)
 }
@@ -0,0 +1,266 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package receiver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/deepflowio/deepflow/server/libs/ckdb"
+)
+
+const (
+	TLS_HANDSHAKE_TIMEOUT         = 10 * time.Second
+	DEFAULT_TLS_RELOAD_INTERVAL   = time.Minute
+	PEER_IDENTITY_URI_SCHEME      = "deepflow"
+	PEER_IDENTITY_ANY_AGENT       = "*"
+	peerIdentityURIFormat         = "deepflow://org/<org-id>/agent/<agent-id>"
+	peerIdentityURIPathSegmentLen = 4
+)
+
+type TLSConfig struct {
+	ListenPort     int
+	CertFile       string
+	KeyFile        string
+	ClientCAFile   string // if set, clients must present certificates signed by it (mutual TLS)
+	ReloadInterval time.Duration
+	// By default, when mutual TLS is enabled, client certificates must carry the identity of the agent,
+	// and frames with different agent id or org id in FlowHeader are rejected.
+	IdentityCheckDisabled bool
+}
+
+// PeerIdentity is the agent identity in the client certificate, carried in URI SAN as
+// `deepflow://org/<org-id>/agent/<agent-id>`, agent id `*` means any agent of the organization.
+type PeerIdentity struct {
+	OrgID    uint16
+	AgentID  uint16
+	AnyAgent bool
+}
+
+func (i *PeerIdentity) String() string {
+	agentID := PEER_IDENTITY_ANY_AGENT
+	if !i.AnyAgent {
+		agentID = strconv.Itoa(int(i.AgentID))
+	}
+	return fmt.Sprintf("%s://org/%d/agent/%s", PEER_IDENTITY_URI_SCHEME, i.OrgID, agentID)
+}
+
+func (i *PeerIdentity) Match(orgID, agentID uint16) bool {
+	return i.OrgID == orgID && (i.AnyAgent || i.AgentID == agentID)
+}
+
+func ParsePeerIdentity(cert *x509.Certificate) (*PeerIdentity, error) {
+	for _, uri := range cert.URIs {
+		if uri.Scheme != PEER_IDENTITY_URI_SCHEME {
+			continue
+		}
+		return parsePeerIdentityURI(uri)
+	}
+	return nil, fmt.Errorf("certificate %s has no identity uri %s", cert.Subject, peerIdentityURIFormat)
+}
+
+func parsePeerIdentityURI(uri *url.URL) (*PeerIdentity, error) {
+	// host of `deepflow://org/1/agent/2` is `org`
+	segments := strings.Split(uri.Host+uri.Path, "/")
+	if len(segments) != peerIdentityURIPathSegmentLen || segments[0] != "org" || segments[2] != "agent" {
+		return nil, fmt.Errorf("identity uri %s invalid, should be %s", uri, peerIdentityURIFormat)
+	}
+	orgID, err := strconv.Atoi(segments[1])
+	if err != nil || orgID < ckdb.DEFAULT_ORG_ID || orgID > ckdb.MAX_ORG_ID {
+		return nil, fmt.Errorf("identity uri %s org id invalid", uri)
+	}
+	identity := &PeerIdentity{OrgID: uint16(orgID)}
+	if segments[3] == PEER_IDENTITY_ANY_AGENT {
+		identity.AnyAgent = true
+		return identity, nil
+	}
+	agentID, err := strconv.ParseUint(segments[3], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("identity uri %s agent id invalid", uri)
+	}
+	identity.AgentID = uint16(agentID)
+	return identity, nil
+}
+
+// certReloader loads server certificate and client CA, and reloads them when the files are modified,
+// new connections use the new certificates, established connections are not affected
+type certReloader struct {
+	config *TLSConfig
+
+	certificate atomic.Value // *tls.Certificate
+	clientCAs   atomic.Value // *x509.CertPool
+	modTime     time.Time
+}
+
+func newCertReloader(config *TLSConfig) (*certReloader, error) {
+	c := &certReloader{config: config}
+	if _, err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *certReloader) files() []string {
+	files := []string{c.config.CertFile, c.config.KeyFile}
+	if c.config.ClientCAFile != "" {
+		files = append(files, c.config.ClientCAFile)
+	}
+	return files
+}
+
+// reload loads the files if any of them is modified since the last loading
+func (c *certReloader) reload() (bool, error) {
+	var modTime time.Time
+	for _, file := range c.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return false, err
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	if !modTime.After(c.modTime) {
+		return false, nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(c.config.CertFile, c.config.KeyFile)
+	if err != nil {
+		return false, fmt.Errorf("load certificate %s and key %s failed: %s", c.config.CertFile, c.config.KeyFile, err)
+	}
+	var clientCAs *x509.CertPool
+	if c.config.ClientCAFile != "" {
+		caPEM, err := os.ReadFile(c.config.ClientCAFile)
+		if err != nil {
+			return false, err
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caPEM) {
+			return false, fmt.Errorf("no valid certificate in client ca file %s", c.config.ClientCAFile)
+		}
+	}
+	c.certificate.Store(&certificate)
+	c.clientCAs.Store(clientCAs)
+	c.modTime = modTime
+	return true, nil
+}
+
+func (c *certReloader) run(exited func() bool) {
+	interval := c.config.ReloadInterval
+	if interval <= 0 {
+		interval = DEFAULT_TLS_RELOAD_INTERVAL
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if exited() {
+			return
+		}
+		if reloaded, err := c.reload(); err != nil {
+			log.Warningf("reload tls certificates failed, keep using the old ones: %s", err)
+		} else if reloaded {
+			log.Infof("tls certificates reloaded")
+		}
+	}
+}
+
+func (c *certReloader) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			config := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*c.certificate.Load().(*tls.Certificate)},
+			}
+			if clientCAs := c.clientCAs.Load().(*x509.CertPool); clientCAs != nil {
+				config.ClientAuth = tls.RequireAndVerifyClientCert
+				config.ClientCAs = clientCAs
+			}
+			return config, nil
+		},
+	}
+}
+
+// handshake completes the TLS handshake and returns the peer identity if mutual TLS and identity check are enabled
+func (c *certReloader) handshake(conn *tls.Conn) (*PeerIdentity, error) {
+	conn.SetDeadline(time.Now().Add(TLS_HANDSHAKE_TIMEOUT))
+	if err := conn.Handshake(); err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+
+	peerCertificates := conn.ConnectionState().PeerCertificates
+	if c.config.IdentityCheckDisabled || len(peerCertificates) == 0 {
+		return nil, nil
+	}
+	return ParsePeerIdentity(peerCertificates[0])
+}
+
+// EnableTLS makes the receiver listen on TLSConfig.ListenPort for TLS connections, it should be called before Start
+func (r *Receiver) EnableTLS(config *TLSConfig) error {
+	reloader, err := newCertReloader(config)
+	if err != nil {
+		return err
+	}
+	r.TLSAddress = fmt.Sprintf("0.0.0.0:%d", config.ListenPort)
+	r.certReloader = reloader
+	return nil
+}
+
+func (r *Receiver) ProcessTLSServer() {
+	defer r.TLSListener.Close()
+	go r.certReloader.run(func() bool { return r.exit })
+	for !r.exit {
+		conn, err := r.TLSListener.Accept()
+		if err != nil {
+			log.Errorf("TLS accept error.%s ", err.Error())
+			time.Sleep(3 * time.Second)
+			continue
+		}
+		go r.handleTLSConnection(conn)
+	}
+}
+
+func (r *Receiver) handleTLSConnection(conn net.Conn) {
+	tlsConn := tls.Server(conn, r.certReloader.tlsConfig())
+	identity, err := r.certReloader.handshake(tlsConn)
+	if err != nil {
+		atomic.AddUint64(&r.counter.TLSHandshakeFailed, 1)
+		r.logTCPReceiveInvalidData(fmt.Sprintf("TLS client (%s) handshake failed: %s", conn.RemoteAddr(), err))
+		tlsConn.Close()
+		return
+	}
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		if err := tcpConn.SetReadBuffer(r.TCPReadBuffer); err != nil {
+			log.Warningf("TLS client (%s) set read buffer failed, err: %s", conn.RemoteAddr().String(), err)
+		}
+	}
+	if identity != nil {
+		log.Infof("TLS client (%s) connect success, identity: %s.", conn.RemoteAddr().String(), identity)
+	} else {
+		log.Infof("TLS client (%s) connect success.", conn.RemoteAddr().String())
+	}
+	r.handleConnection(tlsConn, identity)
+}
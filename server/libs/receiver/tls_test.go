@@ -0,0 +1,343 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package receiver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/deepflowio/deepflow/server/libs/datatype"
+	"github.com/deepflowio/deepflow/server/libs/queue"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns PEM encoded certificate and key
+func (ca *testCA) issue(t *testing.T, serial int64, commonName string, uris ...string) ([]byte, []byte) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	for _, uri := range uris {
+		u, _ := url.Parse(uri)
+		template.URIs = append(template.URIs, u)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func (ca *testCA) clientConfig(t *testing.T, uris ...string) *tls.Config {
+	certPEM, keyPEM := ca.issue(t, time.Now().UnixNano(), "agent", uris...)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return &tls.Config{RootCAs: pool, Certificates: []tls.Certificate{cert}, ServerName: "127.0.0.1"}
+}
+
+func writeFile(t *testing.T, path string, data []byte, modTime time.Time) {
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(path, modTime, modTime)
+}
+
+func TestParsePeerIdentity(t *testing.T) {
+	testCases := []struct {
+		uri      string
+		identity *PeerIdentity
+	}{
+		{"deepflow://org/1/agent/5", &PeerIdentity{OrgID: 1, AgentID: 5}},
+		{"deepflow://org/2/agent/*", &PeerIdentity{OrgID: 2, AnyAgent: true}},
+		{"deepflow://org/0/agent/5", nil},
+		{"deepflow://org/1/agent/70000", nil},
+		{"deepflow://org/1/vtap/5", nil},
+		{"spiffe://org/1/agent/5", nil},
+	}
+	for _, tc := range testCases {
+		u, _ := url.Parse(tc.uri)
+		identity, err := ParsePeerIdentity(&x509.Certificate{URIs: []*url.URL{u}})
+		if tc.identity == nil {
+			if err == nil {
+				t.Errorf("ParsePeerIdentity(%s) = %s, expected error", tc.uri, identity)
+			}
+			continue
+		}
+		if err != nil || *identity != *tc.identity || identity.String() != tc.uri {
+			t.Errorf("ParsePeerIdentity(%s) = %v, %v, expected %v", tc.uri, identity, err, tc.identity)
+		}
+	}
+
+	identity := &PeerIdentity{OrgID: 2, AnyAgent: true}
+	if !identity.Match(2, 10) || identity.Match(1, 10) {
+		t.Error("identity of any agent should match all agents of the org")
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	config := &TLSConfig{
+		CertFile:     filepath.Join(dir, "server.crt"),
+		KeyFile:      filepath.Join(dir, "server.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+	}
+	modTime := time.Now().Add(-time.Minute)
+	certPEM, keyPEM := ca.issue(t, 100, "server-1")
+	writeFile(t, config.CertFile, certPEM, modTime)
+	writeFile(t, config.KeyFile, keyPEM, modTime)
+	writeFile(t, config.ClientCAFile, ca.pem, modTime)
+
+	reloader, err := newCertReloader(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverName := func() string {
+		listener, err := tls.Listen("tcp", "127.0.0.1:0", reloader.tlsConfig())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer listener.Close()
+		go func() {
+			conn, err := listener.Accept()
+			if err == nil {
+				reloader.handshake(conn.(*tls.Conn))
+				conn.Close()
+			}
+		}()
+		conn, err := tls.Dial("tcp", listener.Addr().String(), ca.clientConfig(t, "deepflow://org/1/agent/5"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+	}
+	if name := serverName(); name != "server-1" {
+		t.Errorf("got server certificate %s", name)
+	}
+
+	if reloaded, err := reloader.reload(); reloaded || err != nil {
+		t.Errorf("reload() = %v, %v, files are not modified", reloaded, err)
+	}
+	certPEM, keyPEM = ca.issue(t, 101, "server-2")
+	writeFile(t, config.CertFile, certPEM, time.Now())
+	writeFile(t, config.KeyFile, keyPEM, time.Now())
+	if reloaded, err := reloader.reload(); !reloaded || err != nil {
+		t.Errorf("reload() = %v, %v, files are modified", reloaded, err)
+	}
+	if name := serverName(); name != "server-2" {
+		t.Errorf("got server certificate %s after reload", name)
+	}
+
+	// invalid files are not loaded, the old certificates are kept
+	writeFile(t, config.KeyFile, []byte("invalid"), time.Now().Add(time.Minute))
+	if _, err := reloader.reload(); err == nil {
+		t.Error("reload() expected error")
+	}
+	if name := serverName(); name != "server-2" {
+		t.Errorf("got server certificate %s after failed reload", name)
+	}
+}
+
+type testQueue struct {
+	sync.Mutex
+	items []interface{}
+}
+
+func (q *testQueue) Put(_ queue.HashKey, items ...interface{}) error {
+	q.Lock()
+	q.items = append(q.items, items...)
+	q.Unlock()
+	return nil
+}
+func (q *testQueue) Puts([]queue.HashKey, []interface{}) error { return nil }
+func (q *testQueue) Len(queue.HashKey) int                     { return 0 }
+func (q *testQueue) Close() error                              { return nil }
+
+func (q *testQueue) len() int {
+	q.Lock()
+	defer q.Unlock()
+	return len(q.items)
+}
+
+func encodeFrame(orgID, agentID uint16, payload []byte) []byte {
+	frame := make([]byte, datatype.MESSAGE_HEADER_LEN+datatype.FLOW_HEADER_LEN+len(payload))
+	baseHeader := datatype.BaseHeader{FrameSize: uint32(len(frame)), Type: datatype.MESSAGE_TYPE_PROTOCOLLOG}
+	baseHeader.Encode(frame)
+	flowHeader := datatype.FlowHeader{Version: datatype.LATEST_VERSION, OrgID: orgID, AgentID: agentID}
+	flowHeader.Encode(frame[datatype.MESSAGE_HEADER_LEN:])
+	copy(frame[datatype.MESSAGE_HEADER_LEN+datatype.FLOW_HEADER_LEN:], payload)
+	return frame
+}
+
+// encodeFrameWithoutFlowHeader encodes a frame of message type whose header type is not HEADER_TYPE_LT_VTAP
+func encodeFrameWithoutFlowHeader(msgType datatype.MessageType, payload []byte) []byte {
+	frame := make([]byte, datatype.MESSAGE_HEADER_LEN+len(payload))
+	baseHeader := datatype.BaseHeader{FrameSize: uint32(len(frame)), Type: msgType}
+	baseHeader.Encode(frame)
+	copy(frame[datatype.MESSAGE_HEADER_LEN:], payload)
+	return frame
+}
+
+func TestReceiverMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	config := &TLSConfig{
+		CertFile:     filepath.Join(dir, "server.crt"),
+		KeyFile:      filepath.Join(dir, "server.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+	}
+	certPEM, keyPEM := ca.issue(t, 100, "server")
+	writeFile(t, config.CertFile, certPEM, time.Now())
+	writeFile(t, config.KeyFile, keyPEM, time.Now())
+	writeFile(t, config.ClientCAFile, ca.pem, time.Now())
+
+	r := NewReceiver(0, 1<<20, 1<<20, 1<<16)
+	defer r.Close()
+	q := &testQueue{}
+	r.RegistHandler(datatype.MESSAGE_TYPE_PROTOCOLLOG, q, 1)
+	if err := r.EnableTLS(config); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	if r.TLSListener, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	go r.ProcessTLSServer()
+	addr := r.TLSListener.Addr().String()
+
+	waitClosed := func(conn *tls.Conn) bool {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, err := conn.Read(make([]byte, 1))
+		return err != nil && !os.IsTimeout(err)
+	}
+
+	// frames of the agent in the certificate are accepted
+	conn, err := tls.Dial("tcp", addr, ca.clientConfig(t, "deepflow://org/1/agent/5"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Write(encodeFrame(1, 5, []byte("hello")))
+	for i := 0; i < 50 && q.len() == 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if q.len() != 1 {
+		t.Fatalf("got %d frames, expected 1", q.len())
+	}
+	buffer := q.items[0].(*RecvBuffer)
+	if buffer.VtapID != 5 || buffer.OrgID != 1 || string(buffer.Buffer[buffer.Begin:buffer.End]) != "hello" {
+		t.Errorf("got frame of agent %d org %d", buffer.VtapID, buffer.OrgID)
+	}
+
+	// frames of other agents are rejected and the connection is closed
+	conn.Write(encodeFrame(1, 6, []byte("hello")))
+	if !waitClosed(conn) {
+		t.Error("connection should be closed after identity mismatch")
+	}
+	conn.Close()
+	if q.len() != 1 || atomic.LoadUint64(&r.counter.IdentityMismatch) != 1 {
+		t.Errorf("got %d frames, %d identity mismatch", q.len(), atomic.LoadUint64(&r.counter.IdentityMismatch))
+	}
+
+	// frames which can not be checked against the identity are rejected as well
+	oldVersionFrame := encodeFrame(1, 5, []byte("hello"))
+	binary.LittleEndian.PutUint16(oldVersionFrame[datatype.MESSAGE_HEADER_LEN+datatype.VERSION_OFFSET:], 0x10)
+	for i, frame := range [][]byte{
+		encodeFrameWithoutFlowHeader(datatype.MESSAGE_TYPE_SYSLOG, []byte("hello")),
+		encodeFrameWithoutFlowHeader(datatype.MESSAGE_TYPE_COMPRESS, []byte("hello")),
+		oldVersionFrame,
+	} {
+		conn, err := tls.Dial("tcp", addr, ca.clientConfig(t, "deepflow://org/1/agent/5"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Write(frame)
+		if !waitClosed(conn) {
+			t.Errorf("connection should be closed after frame %d", i)
+		}
+		conn.Close()
+	}
+	if q.len() != 1 || atomic.LoadUint64(&r.counter.IdentityMismatch) != 4 {
+		t.Errorf("got %d frames, %d identity mismatch", q.len(), atomic.LoadUint64(&r.counter.IdentityMismatch))
+	}
+
+	// client certificates without identity or not signed by the CA are rejected
+	for _, clientConfig := range []*tls.Config{ca.clientConfig(t), newTestCA(t).clientConfig(t, "deepflow://org/1/agent/5")} {
+		clientConfig.RootCAs = ca.clientConfig(t).RootCAs
+		conn, err := tls.Dial("tcp", addr, clientConfig)
+		if err == nil {
+			conn.Write(encodeFrame(1, 5, []byte("hello")))
+			if !waitClosed(conn) {
+				t.Error("connection should be closed")
+			}
+			conn.Close()
+		}
+	}
+	if q.len() != 1 {
+		t.Errorf("got %d frames, expected 1", q.len())
+	}
+}
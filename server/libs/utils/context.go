@@ -0,0 +1,37 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"context"
+	"sync"
+)
+
+type ctxKeyWaitGroup struct{}
+
+// GetWaitGroupInCtx returns the WaitGroup carried by ctx, goroutines which should be waited for on exit add to it
+func GetWaitGroupInCtx(ctx context.Context) *sync.WaitGroup {
+	if wg, ok := ctx.Value(ctxKeyWaitGroup{}).(*sync.WaitGroup); ok {
+		return wg
+	}
+
+	return nil
+}
+
+func NewWaitGroupCtx() (context.Context, context.CancelFunc) {
+	return context.WithCancel(context.WithValue(context.Background(), ctxKeyWaitGroup{}, new(sync.WaitGroup)))
+}
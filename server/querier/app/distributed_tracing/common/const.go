@@ -27,9 +27,17 @@ const (
 const (
 	DATABASE_FLOW_LOG = "flow_log"
 	TABLE_L7_FLOW_LOG = "l7_flow_log"
+	TABLE_TRACE_TREE  = "trace_tree"
 	TAG_TRACE_ID      = "trace_id"
 )
 
+// node type of trace map, client_node_type and server_node_type
+const (
+	NODE_TYPE_APP_SERVICE  = "app_service"
+	NODE_TYPE_AUTO_SERVICE = "auto_service"
+	NODE_TYPE_IP           = "ip"
+)
+
 const (
 	HEADER_KEY_X_ORG_ID = "X-Org-Id"
 )
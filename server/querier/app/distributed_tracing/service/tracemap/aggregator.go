@@ -0,0 +1,219 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracemap
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/deepflowio/deepflow/server/libs/tracetree"
+	"github.com/deepflowio/deepflow/server/libs/utils"
+	"github.com/deepflowio/deepflow/server/querier/app/distributed_tracing/common"
+	"github.com/deepflowio/deepflow/server/querier/app/distributed_tracing/model"
+)
+
+const (
+	AUTO_SERVICE_TYPE_INTERNET = 0
+	AUTO_SERVICE_TYPE_IP       = 255
+)
+
+type traceMapNode struct {
+	autoServiceType uint8
+	autoServiceID   uint32
+	appService      string
+	ip              string
+}
+
+func newNodeFromNodeInfo(n *tracetree.NodeInfo) traceMapNode {
+	return traceMapNode{
+		autoServiceType: n.AutoServiceType,
+		autoServiceID:   n.AutoServiceID,
+		appService:      n.AppService,
+		ip:              formatIP(n.IsIPv4, n.IP4, n.IP6),
+	}
+}
+
+// 由 span 客户端侧信息构造节点，用于解析伪链路
+func newClientNodeFromSpanInfo(s *tracetree.SpanInfo) traceMapNode {
+	return traceMapNode{
+		autoServiceType: s.AutoServiceType0,
+		autoServiceID:   s.AutoServiceID0,
+		appService:      s.AppService0,
+		ip:              formatIP(s.IsIPv4, s.IP40, s.IP60),
+	}
+}
+
+func formatIP(isIPv4 bool, ip4 uint32, ip6 net.IP) string {
+	if isIPv4 {
+		return utils.IpFromUint32(ip4).String()
+	}
+	return ip6.String()
+}
+
+// 只有无法关联到资源的节点才需要用 IP 区分
+func (n *traceMapNode) isIPNode() bool {
+	return n.autoServiceType == AUTO_SERVICE_TYPE_INTERNET || n.autoServiceType == AUTO_SERVICE_TYPE_IP
+}
+
+// uid encoding: auto_service_type+auto_service_id+app_service+ip
+func (n *traceMapNode) uid() string {
+	if n.isIPNode() {
+		return fmt.Sprintf("%d-%d-%s-%s", n.autoServiceType, n.autoServiceID, n.appService, n.ip)
+	}
+	return fmt.Sprintf("%d-%d-%s-", n.autoServiceType, n.autoServiceID, n.appService)
+}
+
+func (n *traceMapNode) nodeType() string {
+	if n.appService != "" {
+		return common.NODE_TYPE_APP_SERVICE
+	} else if n.isIPNode() {
+		return common.NODE_TYPE_IP
+	}
+	return common.NODE_TYPE_AUTO_SERVICE
+}
+
+type autoServiceKey struct {
+	autoServiceType uint8
+	autoServiceID   uint32
+}
+
+type autoServiceInfo struct {
+	name   string
+	iconID int
+}
+
+// TraceMapAggregator 将 trace_tree 中的 TreeNode 聚合为服务间的调用关系
+type TraceMapAggregator struct {
+	edges map[string]*model.RawTraceMap
+}
+
+func NewTraceMapAggregator() *TraceMapAggregator {
+	return &TraceMapAggregator{edges: make(map[string]*model.RawTraceMap)}
+}
+
+func (a *TraceMapAggregator) Len() int {
+	return len(a.edges)
+}
+
+func (a *TraceMapAggregator) AddTraceTree(t *tracetree.TraceTree) {
+	for i := range t.TreeNodes {
+		node := &t.TreeNodes[i]
+		server := newNodeFromNodeInfo(&node.NodeInfo)
+		if node.ParentNodeIndex >= 0 && int(node.ParentNodeIndex) < len(t.TreeNodes) {
+			client := newNodeFromNodeInfo(&t.TreeNodes[node.ParentNodeIndex].NodeInfo)
+			a.addEdge(&client, &server, node)
+			continue
+		}
+
+		// 根节点没有父节点，但其 span 的客户端侧可能是未被追踪到的服务 (如未部署 agent 的调用方)，
+		// 这类伪链路通过 span 客户端侧信息还原出调用方节点
+		// 同一节点可能有多个调用方，但节点上的统计量无法再按调用方拆分，只计入第一个调用方
+		stats := node
+		for j := range node.UniqParentSpanInfos {
+			client := newClientNodeFromSpanInfo(&node.UniqParentSpanInfos[j])
+			if client.uid() == server.uid() {
+				continue
+			}
+			a.addEdge(&client, &server, stats)
+			stats = nil
+		}
+	}
+}
+
+func (a *TraceMapAggregator) addEdge(client, server *traceMapNode, stats *tracetree.TreeNode) {
+	uid0, uid1 := client.uid(), server.uid()
+	key := uid0 + "|" + uid1
+	edge, ok := a.edges[key]
+	if !ok {
+		edge = &model.RawTraceMap{
+			AutoServiceId0:   uint(client.autoServiceID),
+			AutoServiceId1:   uint(server.autoServiceID),
+			AutoServiceType0: uint(client.autoServiceType),
+			AutoServiceType1: uint(server.autoServiceType),
+			Uid0:             uid0,
+			Uid1:             uid1,
+			IP0:              client.ip,
+			IP1:              server.ip,
+			AppService0:      client.appService,
+			AppService1:      server.appService,
+			ClientNodeType:   client.nodeType(),
+			ServerNodeType:   server.nodeType(),
+		}
+		a.edges[key] = edge
+	}
+	if stats == nil {
+		return
+	}
+	edge.ResponseTotal += uint(stats.ResponseTotal)
+	edge.ResponseStatusServerErrorCount += uint(stats.ResponseStatusServerErrorCount)
+	edge.ResponseDurationSum += stats.ResponseDurationSum
+}
+
+// autoServiceKeys 返回需要查询名称的 auto_service
+func (a *TraceMapAggregator) autoServiceKeys() []autoServiceKey {
+	keySet := make(map[autoServiceKey]struct{})
+	for _, edge := range a.edges {
+		for _, key := range []autoServiceKey{
+			{uint8(edge.AutoServiceType0), uint32(edge.AutoServiceId0)},
+			{uint8(edge.AutoServiceType1), uint32(edge.AutoServiceId1)},
+		} {
+			if key.autoServiceType != AUTO_SERVICE_TYPE_INTERNET && key.autoServiceType != AUTO_SERVICE_TYPE_IP {
+				keySet[key] = struct{}{}
+			}
+		}
+	}
+	keys := make([]autoServiceKey, 0, len(keySet))
+	for key := range keySet {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].autoServiceType != keys[j].autoServiceType {
+			return keys[i].autoServiceType < keys[j].autoServiceType
+		}
+		return keys[i].autoServiceID < keys[j].autoServiceID
+	})
+	return keys
+}
+
+// Result 填充 auto_service 名称和图标后返回聚合结果，结果按 uid 排序以保证输出稳定
+func (a *TraceMapAggregator) Result(autoServices map[autoServiceKey]autoServiceInfo) []*model.RawTraceMap {
+	result := make([]*model.RawTraceMap, 0, len(a.edges))
+	for _, edge := range a.edges {
+		edge.AutoService0, edge.ClientIconId = autoServiceName(autoServices, edge.AutoServiceType0, edge.AutoServiceId0, edge.IP0)
+		edge.AutoService1, edge.ServerIconId = autoServiceName(autoServices, edge.AutoServiceType1, edge.AutoServiceId1, edge.IP1)
+		result = append(result, edge)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Uid0 != result[j].Uid0 {
+			return result[i].Uid0 < result[j].Uid0
+		}
+		return result[i].Uid1 < result[j].Uid1
+	})
+	return result
+}
+
+func autoServiceName(autoServices map[autoServiceKey]autoServiceInfo, autoServiceType, autoServiceID uint, ip string) (string, int) {
+	if autoServiceType == AUTO_SERVICE_TYPE_INTERNET || autoServiceType == AUTO_SERVICE_TYPE_IP {
+		return ip, 0
+	}
+	info, ok := autoServices[autoServiceKey{uint8(autoServiceType), uint32(autoServiceID)}]
+	if !ok {
+		return "", 0
+	}
+	return info.name, info.iconID
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracemap
+
+import (
+	"testing"
+
+	"github.com/deepflowio/deepflow/server/libs/tracetree"
+	"github.com/deepflowio/deepflow/server/querier/app/distributed_tracing/common"
+)
+
+func TestTraceMapAggregator(t *testing.T) {
+	gateway := tracetree.NodeInfo{AutoServiceType: 11, AutoServiceID: 1, AppService: "gateway", IsIPv4: true, IP4: 0x0a000001}
+	order := tracetree.NodeInfo{AutoServiceType: 11, AutoServiceID: 2, AppService: "order", IsIPv4: true, IP4: 0x0a000002}
+	mysql := tracetree.NodeInfo{AutoServiceType: 11, AutoServiceID: 3, IsIPv4: true, IP4: 0x0a000003}
+	// gateway 的调用方是没有被追踪到的外部客户端，需要通过 span 客户端侧信息还原
+	client := tracetree.SpanInfo{AutoServiceType0: AUTO_SERVICE_TYPE_INTERNET, IsIPv4: true, IP40: 0xc0a80001}
+
+	tree := &tracetree.TraceTree{
+		TreeNodes: []tracetree.TreeNode{
+			{ParentNodeIndex: -1, NodeInfo: gateway, UniqParentSpanInfos: []tracetree.SpanInfo{client}, ResponseTotal: 1, ResponseDurationSum: 100},
+			{ParentNodeIndex: 0, NodeInfo: order, ResponseTotal: 2, ResponseStatusServerErrorCount: 1, ResponseDurationSum: 60},
+			{ParentNodeIndex: 1, NodeInfo: mysql, ResponseTotal: 3, ResponseDurationSum: 30},
+		},
+	}
+	aggregator := NewTraceMapAggregator()
+	aggregator.AddTraceTree(tree)
+	aggregator.AddTraceTree(tree)
+
+	keys := aggregator.autoServiceKeys()
+	if len(keys) != 3 {
+		t.Fatalf("autoServiceKeys() = %v, want 3 keys", keys)
+	}
+	result := aggregator.Result(map[autoServiceKey]autoServiceInfo{
+		{11, 1}: {name: "svc-gateway", iconID: 10},
+		{11, 2}: {name: "svc-order", iconID: 10},
+		{11, 3}: {name: "svc-mysql", iconID: 11},
+	})
+	if len(result) != 3 {
+		t.Fatalf("Result() returns %d edges, want 3", len(result))
+	}
+
+	edges := map[string]int{}
+	for i, r := range result {
+		edges[r.AutoService0+"->"+r.AutoService1] = i
+	}
+	pseudo, ok := edges["192.168.0.1->svc-gateway"]
+	if !ok {
+		t.Fatalf("pseudo link is not resolved, edges: %v", edges)
+	}
+	if r := result[pseudo]; r.ClientNodeType != common.NODE_TYPE_IP || r.ServerNodeType != common.NODE_TYPE_APP_SERVICE || r.ResponseTotal != 2 || r.ResponseDurationSum != 200 {
+		t.Errorf("unexpected pseudo link: %+v", r)
+	}
+	if r := result[edges["svc-gateway->svc-order"]]; r.ResponseTotal != 4 || r.ResponseStatusServerErrorCount != 2 || r.ResponseDurationSum != 120 || r.ClientIconId != 10 {
+		t.Errorf("unexpected edge gateway->order: %+v", r)
+	}
+	if r := result[edges["svc-order->svc-mysql"]]; r.ResponseTotal != 6 || r.ServerNodeType != common.NODE_TYPE_AUTO_SERVICE || r.ServerIconId != 11 {
+		t.Errorf("unexpected edge order->mysql: %+v", r)
+	}
+}
+
+func TestSplitTimeRange(t *testing.T) {
+	cases := []struct {
+		start, end, delta, iterations int
+		want                          [][2]int
+	}{
+		{0, 899, 300, 8, [][2]int{{0, 299}, {300, 599}, {600, 899}}},
+		{0, 100, 300, 8, [][2]int{{0, 100}}},
+		// too many windows, delta is enlarged
+		{0, 999, 100, 4, [][2]int{{0, 249}, {250, 499}, {500, 749}, {750, 999}}},
+		{10, 10, 0, 0, [][2]int{{10, 10}}},
+	}
+	for _, c := range cases {
+		got := splitTimeRange(c.start, c.end, c.delta, c.iterations)
+		if len(got) != len(c.want) {
+			t.Errorf("splitTimeRange(%d, %d, %d, %d) = %v, want %v", c.start, c.end, c.delta, c.iterations, got, c.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("splitTimeRange(%d, %d, %d, %d) = %v, want %v", c.start, c.end, c.delta, c.iterations, got, c.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,257 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracemap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/deepflowio/deepflow/server/libs/ckdb"
+	"github.com/deepflowio/deepflow/server/libs/codec"
+	"github.com/deepflowio/deepflow/server/libs/tracetree"
+	"github.com/deepflowio/deepflow/server/querier/app/distributed_tracing/common"
+	tracemap_config "github.com/deepflowio/deepflow/server/querier/app/distributed_tracing/config"
+	"github.com/deepflowio/deepflow/server/querier/app/distributed_tracing/model"
+	querier_common "github.com/deepflowio/deepflow/server/querier/common"
+	"github.com/deepflowio/deepflow/server/querier/config"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/client"
+)
+
+// traceMapQuerier 负责一次 trace map 请求中的所有 ClickHouse 查询
+type traceMapQuerier struct {
+	args        *model.TraceMap
+	cfg         *config.QuerierConfig
+	mapCfg      *tracemap_config.TraceMapConfig
+	database    string
+	seenTraceID map[string]struct{}
+	debugs      []interface{}
+}
+
+func newTraceMapQuerier(args *model.TraceMap, cfg *config.QuerierConfig) (*traceMapQuerier, error) {
+	orgID := querier_common.DEFAULT_ORG_ID
+	if args.OrgID != "" {
+		orgID = args.OrgID
+	}
+	orgIDInt, err := strconv.Atoi(orgID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid org id: %s", args.OrgID)
+	}
+	return &traceMapQuerier{
+		args:        args,
+		cfg:         cfg,
+		mapCfg:      &cfg.Tracemap,
+		database:    ckdb.OrgDatabasePrefix(uint16(orgIDInt)) + common.DATABASE_FLOW_LOG,
+		seenTraceID: make(map[string]struct{}),
+	}, nil
+}
+
+// queryTraceMap 聚合 [timeStart, timeEnd] 内的 trace_tree，返回聚合结果和参与聚合的 trace 数量
+func (q *traceMapQuerier) queryTraceMap(timeStart, timeEnd int) ([]*model.RawTraceMap, int, error) {
+	aggregator := NewTraceMapAggregator()
+	traceCount := 0
+	if q.args.QueryCondition == "" {
+		// 无查询条件时直接按时间扫描 trace_tree，不需要先查询 l7_flow_log
+		sql := fmt.Sprintf(
+			"SELECT %s, encoded_span_list FROM %s.%s WHERE time>=%d AND time<=%d ORDER BY time DESC LIMIT 1 BY %s LIMIT %d",
+			common.TAG_TRACE_ID, q.database, common.TABLE_TRACE_TREE, timeStart, timeEnd, common.TAG_TRACE_ID, q.mapCfg.MaxTracePerIteration,
+		)
+		count, err := q.aggregateTraceTrees(sql, aggregator)
+		if err != nil {
+			return nil, 0, err
+		}
+		traceCount += count
+	} else {
+		traceIDs, err := q.queryTraceIDs(timeStart, timeEnd)
+		if err != nil {
+			return nil, 0, err
+		}
+		batchSize := int(q.mapCfg.BatchTracesCountMax)
+		if batchSize <= 0 {
+			batchSize = len(traceIDs)
+		}
+		for i := 0; i < len(traceIDs); i += batchSize {
+			j := i + batchSize
+			if j > len(traceIDs) {
+				j = len(traceIDs)
+			}
+			count, err := q.aggregateTraceTrees(q.traceTreeSQL(timeStart, timeEnd, traceIDs[i:j]), aggregator)
+			if err != nil {
+				return nil, 0, err
+			}
+			traceCount += count
+		}
+	}
+
+	autoServices, err := q.queryAutoServices(aggregator.autoServiceKeys())
+	if err != nil {
+		return nil, 0, err
+	}
+	return aggregator.Result(autoServices), traceCount, nil
+}
+
+// queryTraceIDs 通过查询条件在 l7_flow_log 中筛选 trace_id，查询条件使用 querier 的 SQL 语法
+func (q *traceMapQuerier) queryTraceIDs(timeStart, timeEnd int) ([]string, error) {
+	sql := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE time>=%d AND time<=%d AND %s!='' AND (%s) GROUP BY %s LIMIT %d",
+		common.TAG_TRACE_ID, common.TABLE_L7_FLOW_LOG, timeStart, timeEnd, common.TAG_TRACE_ID, q.args.QueryCondition, common.TAG_TRACE_ID, q.mapCfg.MaxTracePerIteration,
+	)
+	ckEngine := &clickhouse.CHEngine{DB: common.DATABASE_FLOW_LOG}
+	ckEngine.Init()
+	querierArgs := querier_common.QuerierParams{
+		DB:      common.DATABASE_FLOW_LOG,
+		Sql:     sql,
+		Debug:   strconv.FormatBool(q.args.Debug),
+		Context: q.args.Context,
+		ORGID:   q.args.OrgID,
+	}
+	result, debug, err := ckEngine.ExecuteQuery(&querierArgs)
+	if debug != nil {
+		q.debugs = append(q.debugs, debug)
+	}
+	if err != nil {
+		log.Errorf("query trace id failed: %s, sql: %s", err, sql)
+		return nil, err
+	}
+
+	traceIDs := make([]string, 0, len(result.Values))
+	for _, value := range result.Values {
+		row, ok := value.([]interface{})
+		if !ok || len(row) == 0 {
+			continue
+		}
+		traceID, ok := row[0].(string)
+		if !ok || traceID == "" {
+			continue
+		}
+		if _, ok := q.seenTraceID[traceID]; ok {
+			continue
+		}
+		traceIDs = append(traceIDs, traceID)
+	}
+	return traceIDs, nil
+}
+
+// trace_tree 的写入时间晚于 span，向后多查询 TraceQueryDelta 以免遗漏在窗口末尾结束的 trace
+func (q *traceMapQuerier) traceTreeSQL(timeStart, timeEnd int, traceIDs []string) string {
+	searchIndices := make([]string, 0, len(traceIDs))
+	quotedTraceIDs := make([]string, 0, len(traceIDs))
+	for _, traceID := range traceIDs {
+		searchIndices = append(searchIndices, strconv.FormatUint(tracetree.HashSearchIndex(traceID), 10))
+		quotedTraceIDs = append(quotedTraceIDs, quoteString(traceID))
+	}
+	return fmt.Sprintf(
+		"SELECT %s, encoded_span_list FROM %s.%s WHERE time>=%d AND time<=%d AND search_index IN (%s) AND %s IN (%s) ORDER BY time DESC LIMIT 1 BY %s",
+		common.TAG_TRACE_ID, q.database, common.TABLE_TRACE_TREE, timeStart, timeEnd+int(q.mapCfg.TraceQueryDelta),
+		strings.Join(searchIndices, ","), common.TAG_TRACE_ID, strings.Join(quotedTraceIDs, ","), common.TAG_TRACE_ID,
+	)
+}
+
+// aggregateTraceTrees 解码 trace_tree 并聚合，已聚合过的 trace 会被跳过，返回本次聚合的 trace 数量
+func (q *traceMapQuerier) aggregateTraceTrees(sql string, aggregator *TraceMapAggregator) (int, error) {
+	rst, err := q.doQuery(q.database, sql)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	traceTree := &tracetree.TraceTree{}
+	decoder := &codec.SimpleDecoder{}
+	for _, value := range rst.Values {
+		row, ok := value.([]interface{})
+		if !ok || len(row) < 2 {
+			continue
+		}
+		traceID, _ := row[0].(string)
+		encoded, _ := row[1].(string)
+		if _, ok := q.seenTraceID[traceID]; ok {
+			continue
+		}
+		q.seenTraceID[traceID] = struct{}{}
+
+		decoder.Init([]byte(encoded))
+		if err := traceTree.Decode(decoder); err != nil {
+			log.Warningf("decode trace tree of trace_id %s failed: %s", traceID, err)
+			continue
+		}
+		aggregator.AddTraceTree(traceTree)
+		count++
+	}
+	return count, nil
+}
+
+func (q *traceMapQuerier) queryAutoServices(keys []autoServiceKey) (map[autoServiceKey]autoServiceInfo, error) {
+	autoServices := make(map[autoServiceKey]autoServiceInfo, len(keys))
+	if len(keys) == 0 {
+		return autoServices, nil
+	}
+	conditions := make([]string, 0, len(keys))
+	for _, key := range keys {
+		conditions = append(conditions, fmt.Sprintf("(%d,%d)", key.autoServiceType, key.autoServiceID))
+	}
+	sql := fmt.Sprintf(
+		"SELECT devicetype, deviceid, name, icon_id FROM flow_tag.device_map WHERE (devicetype, deviceid) IN (%s)",
+		strings.Join(conditions, ","),
+	)
+	rst, err := q.doQuery("flow_tag", sql)
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range rst.Values {
+		row, ok := value.([]interface{})
+		if !ok || len(row) < 4 {
+			continue
+		}
+		deviceType, _ := row[0].(int)
+		deviceID, _ := row[1].(int)
+		name, _ := row[2].(string)
+		iconID, _ := row[3].(int)
+		autoServices[autoServiceKey{uint8(deviceType), uint32(deviceID)}] = autoServiceInfo{name: name, iconID: iconID}
+	}
+	return autoServices, nil
+}
+
+func (q *traceMapQuerier) doQuery(db, sql string) (*querier_common.Result, error) {
+	chClient := client.Client{
+		Host:     q.cfg.Clickhouse.Host,
+		Port:     q.cfg.Clickhouse.Port,
+		UserName: q.cfg.Clickhouse.User,
+		Password: q.cfg.Clickhouse.Password,
+		DB:       db,
+		Context:  q.args.Context,
+	}
+	chClient.Debug = client.NewDebug(sql)
+	rst, err := chClient.DoQuery(&client.QueryParams{Sql: sql, ORGID: q.args.OrgID})
+	if q.args.Debug {
+		debug := *chClient.Debug
+		if q.mapCfg.DebugSqlLenMax > 0 && len(debug.Sql) > q.mapCfg.DebugSqlLenMax {
+			debug.Sql = debug.Sql[:q.mapCfg.DebugSqlLenMax] + "..."
+		}
+		q.debugs = append(q.debugs, debug)
+	}
+	if err != nil {
+		log.Errorf("query clickhouse failed: %s, sql: %s", err, sql)
+		return nil, err
+	}
+	return rst, nil
+}
+
+func quoteString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `'`, `\'`)
+	return "'" + s + "'"
+}
@@ -16,13 +16,120 @@
 package tracemap
 
 import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
 	"github.com/gin-gonic/gin"
+	logging "github.com/op/go-logging"
 
+	"github.com/deepflowio/deepflow/server/querier/app/distributed_tracing/common"
 	"github.com/deepflowio/deepflow/server/querier/app/distributed_tracing/model"
 	"github.com/deepflowio/deepflow/server/querier/config"
 )
 
+var log = logging.MustGetLogger("tracemap")
+
+// traceMapChunk 与 querier 的 router.Response 格式一致，每个时间窗口输出一行
+type traceMapChunk struct {
+	OptStatus   string      `json:"OPT_STATUS"`
+	Description string      `json:"DESCRIPTION"`
+	Result      interface{} `json:"result"`
+	Debug       interface{} `json:"debug,omitempty"`
+}
+
+type traceMapChunkResult struct {
+	TimeStart  int                  `json:"time_start"`
+	TimeEnd    int                  `json:"time_end"`
+	TraceCount int                  `json:"trace_count"`
+	TraceMap   []*model.RawTraceMap `json:"trace_map"`
+}
+
+// TraceMap 将时间范围切分为多个窗口，按窗口聚合 trace_tree 并以换行分隔的 JSON 流式返回，
+// 每个窗口内的聚合结果互不重叠 (同一 trace 只会被聚合一次)，调用方按 uid_0 + uid_1 累加即可得到整个时间范围的结果
 func TraceMap(args model.TraceMap, cfg *config.QuerierConfig, c *gin.Context, done chan bool, generator *TraceMapGenerator) {
-	done <- true
-	return
+	defer func() {
+		done <- true
+	}()
+
+	if args.TimeStart > args.TimeEnd {
+		writeError(c, http.StatusBadRequest, common.INVALID_PARAMETERS,
+			fmt.Sprintf("time_start(%d) should not be greater than time_end(%d)", args.TimeStart, args.TimeEnd))
+		return
+	}
+	querier, err := newTraceMapQuerier(&args, cfg)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, common.INVALID_PARAMETERS, err.Error())
+		return
+	}
+
+	for _, window := range splitTimeRange(args.TimeStart, args.TimeEnd, int(cfg.Tracemap.TraceQueryDelta), int(cfg.Tracemap.TraceIdQueryIterations)) {
+		if args.Context != nil && args.Context.Err() != nil {
+			log.Infof("trace map request canceled: %s", args.Context.Err())
+			return
+		}
+		traceMap, traceCount, err := querier.queryTraceMap(window[0], window[1])
+		if err != nil {
+			writeError(c, http.StatusInternalServerError, common.SERVER_ERROR, err.Error())
+			return
+		}
+		chunk := &traceMapChunk{
+			OptStatus: common.SUCCESS,
+			Result: &traceMapChunkResult{
+				TimeStart:  window[0],
+				TimeEnd:    window[1],
+				TraceCount: traceCount,
+				TraceMap:   traceMap,
+			},
+		}
+		if args.Debug {
+			chunk.Debug = querier.debugs
+		}
+		querier.debugs = nil
+		if err := writeChunk(c, chunk); err != nil {
+			log.Warningf("write trace map chunk failed: %s", err)
+			return
+		}
+	}
+}
+
+// splitTimeRange 按 delta 切分时间范围，窗口数量不超过 maxIterations，超出时增大窗口
+func splitTimeRange(timeStart, timeEnd, delta, maxIterations int) [][2]int {
+	if delta <= 0 {
+		delta = timeEnd - timeStart + 1
+	}
+	if maxIterations > 0 && timeEnd-timeStart+1 > delta*maxIterations {
+		delta = (timeEnd - timeStart + maxIterations) / maxIterations
+	}
+	windows := [][2]int{}
+	for start := timeStart; start <= timeEnd; start += delta {
+		end := start + delta - 1
+		if end > timeEnd {
+			end = timeEnd
+		}
+		windows = append(windows, [2]int{start, end})
+	}
+	return windows
+}
+
+func writeChunk(c *gin.Context, chunk *traceMapChunk) error {
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		return err
+	}
+	if _, err = c.Writer.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	c.Writer.Flush()
+	return nil
+}
+
+// writeError 在尚未输出任何数据时设置 HTTP 状态码，否则只能在流中追加一个错误块
+func writeError(c *gin.Context, httpCode int, optStatus, description string) {
+	if !c.Writer.Written() {
+		c.Status(httpCode)
+	}
+	if err := writeChunk(c, &traceMapChunk{OptStatus: optStatus, Description: description}); err != nil {
+		log.Warningf("write trace map error failed: %s", err)
+	}
 }
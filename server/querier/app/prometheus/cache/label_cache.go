@@ -0,0 +1,88 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cache
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/deepflowio/deepflow/server/libs/lru"
+)
+
+type labelItem[T any] struct {
+	data    T
+	expires time.Time
+}
+
+// LabelCache caches the results of metadata apis (label names, metric metadata),
+// they are expensive to collect from all databases but rarely change
+type LabelCache[T any] struct {
+	entries *lru.Cache[string, *labelItem[T]]
+	lock    *sync.Mutex
+	ttl     time.Duration
+}
+
+func NewLabelCache[T any](maxCount int, ttl time.Duration) *LabelCache[T] {
+	return &LabelCache[T]{
+		entries: lru.NewCache[string, *labelItem[T]](maxCount),
+		lock:    &sync.Mutex{},
+		ttl:     ttl,
+	}
+}
+
+func (c *LabelCache[T]) Get(key string) (data T, ok bool) {
+	if c.ttl <= 0 {
+		return data, false
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entry, ok := c.entries.Get(key)
+	if !ok {
+		return data, false
+	}
+	if time.Now().After(entry.expires) {
+		c.entries.Remove(key)
+		return data, false
+	}
+	return entry.data, true
+}
+
+func (c *LabelCache[T]) Add(key string, data T) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entries.Add(key, &labelItem[T]{data: data, expires: time.Now().Add(c.ttl)})
+}
+
+// LabelCacheKey generates cache key for metadata requests, start and end (unix seconds) are
+// aligned to ttl, so that requests from dashboards refreshing continuously could hit the cache
+func LabelCacheKey(api string, orgID string, matchers []string, start, end int64, ttl time.Duration) string {
+	align := int64(ttl.Seconds())
+	if align > 0 {
+		start = start - start%align
+		end = end - end%align
+	}
+	sortedMatchers := make([]string, len(matchers))
+	copy(sortedMatchers, matchers)
+	sort.Strings(sortedMatchers)
+	return fmt.Sprintf("%s-%s-%d-%d-%s", api, orgID, start, end, strings.Join(sortedMatchers, ","))
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLabelCacheKey(t *testing.T) {
+	ttl := 60 * time.Second
+	k1 := LabelCacheKey("labels", "1", []string{"b", "a"}, 1700000001, 1700003601, ttl)
+	k2 := LabelCacheKey("labels", "1", []string{"a", "b"}, 1700000010, 1700003610, ttl)
+	if k1 != k2 {
+		t.Errorf("keys in the same ttl window should be equal, got %s and %s", k1, k2)
+	}
+	k3 := LabelCacheKey("labels", "2", []string{"a", "b"}, 1700000010, 1700003610, ttl)
+	if k1 == k3 {
+		t.Errorf("keys of different orgs should not be equal, got %s", k1)
+	}
+}
+
+func TestLabelCache(t *testing.T) {
+	c := NewLabelCache[[]string](2, 50*time.Millisecond)
+	c.Add("a", []string{"__name__", "job"})
+	if v, ok := c.Get("a"); !ok || len(v) != 2 {
+		t.Errorf("label cache should hit, got %v %v", v, ok)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("label cache item should expire")
+	}
+
+	disabled := NewLabelCache[[]string](2, 0)
+	disabled.Add("a", []string{"job"})
+	if _, ok := disabled.Get("a"); ok {
+		t.Errorf("label cache should be disabled when ttl is 0")
+	}
+}
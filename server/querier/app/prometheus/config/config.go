@@ -28,6 +28,7 @@ type Prometheus struct {
 	ThanosReplicaLabels     []string        `yaml:"thanos-replica-labels"`
 	OperatorOffloading      bool            `default:"false" yaml:"operator-offloading"`
 	Cache                   PrometheusCache `yaml:"cache"`
+	Rules                   PrometheusRules `yaml:"rules"`
 }
 
 type PrometheusCache struct {
@@ -38,4 +39,15 @@ type PrometheusCache struct {
 	CacheFirstTimeout  int    `default:"10" yaml:"cache-first-timeout"`    // time out for first cache item load, unit: s, default: 10s
 	CacheCleanInterval int    `default:"3600" yaml:"cache-clean-interval"` // clean interval for cache, unit: s, default: 1h
 	CacheAllowTimeGap  int    `default:"1" yaml:"cache-allow-time-gap"`    // when query end time - cache end time <= allow gap: not update cache, unit: s, default: 1s
+	LabelCacheTTL      int    `default:"60" yaml:"label-cache-ttl"`        // ttl for label names and metadata cache, 0 means disabled, unit: s, default: 60s
+}
+
+type PrometheusRules struct {
+	Enabled            bool     `default:"false" yaml:"enabled"`
+	RuleFiles          []string `yaml:"rule-files"`                                 // prometheus rule group yaml files, support glob pattern
+	EvaluationInterval int      `default:"60" yaml:"evaluation-interval"`           // default evaluation interval of rule groups, unit: s, default: 60s
+	ReloadInterval     int      `default:"60" yaml:"reload-interval"`               // interval for reloading rule files, unit: s, default: 60s
+	OrgID              uint16   `default:"1" yaml:"org-id"`                         // organization which rules are evaluated in
+	IngesterAddress    string   `default:"127.0.0.1:20033" yaml:"ingester-address"` // receiver address of ingester, recording rule results and alert events are sent to it
+	SendTimeout        int      `default:"10" yaml:"send-timeout"`                  // timeout for sending data to ingester, unit: s, default: 10s
 }
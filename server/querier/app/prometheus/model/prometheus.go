@@ -55,10 +55,28 @@ type PromMetaParams struct {
 	EndTime     string
 	LabelName   string
 	OrgID       string
+	Metric      string
+	Limit       int
+	Matchers    []string
 	BlockTeamID []string
 	Context     context.Context
 }
 
+type PromMetadata struct {
+	Type string `json:"type"`
+	Help string `json:"help"`
+	Unit string `json:"unit"`
+}
+
+type PromBuildInfo struct {
+	Version   string `json:"version"`
+	Revision  string `json:"revision"`
+	Branch    string `json:"branch"`
+	BuildUser string `json:"buildUser"`
+	BuildDate string `json:"buildDate"`
+	GoVersion string `json:"goVersion"`
+}
+
 type PromQueryStats struct {
 	Duration   float64 `json:"duration,omitempty"`
 	SQL        string  `json:"sql,omitempty"`
@@ -0,0 +1,82 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+const (
+	RULE_TYPE_ALERTING  = "alerting"
+	RULE_TYPE_RECORDING = "recording"
+)
+
+// API Spec: https://prometheus.io/docs/prometheus/latest/querying/api/#rules
+type PromRuleDiscovery struct {
+	RuleGroups []*PromRuleGroup `json:"groups"`
+}
+
+type PromRuleGroup struct {
+	Name           string        `json:"name"`
+	File           string        `json:"file"`
+	Rules          []interface{} `json:"rules"`
+	Interval       float64       `json:"interval"`
+	Limit          int           `json:"limit"`
+	EvaluationTime float64       `json:"evaluationTime"`
+	LastEvaluation time.Time     `json:"lastEvaluation"`
+}
+
+type PromAlertingRule struct {
+	State          string        `json:"state"`
+	Name           string        `json:"name"`
+	Query          string        `json:"query"`
+	Duration       float64       `json:"duration"`
+	Labels         labels.Labels `json:"labels"`
+	Annotations    labels.Labels `json:"annotations"`
+	Alerts         []*PromAlert  `json:"alerts"`
+	Health         string        `json:"health"`
+	LastError      string        `json:"lastError,omitempty"`
+	EvaluationTime float64       `json:"evaluationTime"`
+	LastEvaluation time.Time     `json:"lastEvaluation"`
+	Type           string        `json:"type"`
+}
+
+type PromRecordingRule struct {
+	Name           string        `json:"name"`
+	Query          string        `json:"query"`
+	Labels         labels.Labels `json:"labels,omitempty"`
+	Health         string        `json:"health"`
+	LastError      string        `json:"lastError,omitempty"`
+	EvaluationTime float64       `json:"evaluationTime"`
+	LastEvaluation time.Time     `json:"lastEvaluation"`
+	Type           string        `json:"type"`
+}
+
+// API Spec: https://prometheus.io/docs/prometheus/latest/querying/api/#alerts
+type PromAlertDiscovery struct {
+	Alerts []*PromAlert `json:"alerts"`
+}
+
+type PromAlert struct {
+	Labels      labels.Labels `json:"labels"`
+	Annotations labels.Labels `json:"annotations"`
+	State       string        `json:"state"`
+	ActiveAt    *time.Time    `json:"activeAt,omitempty"`
+	Value       string        `json:"value"`
+}
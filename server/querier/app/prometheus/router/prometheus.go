@@ -30,6 +30,7 @@ import (
 	"github.com/prometheus/prometheus/promql/parser"
 
 	"github.com/deepflowio/deepflow/server/querier/app/prometheus/model"
+	"github.com/deepflowio/deepflow/server/querier/app/prometheus/rules"
 	"github.com/deepflowio/deepflow/server/querier/app/prometheus/service"
 	"github.com/deepflowio/deepflow/server/querier/common"
 	"github.com/deepflowio/deepflow/server/querier/config"
@@ -173,6 +174,82 @@ func promTagValuesReader(svc *service.PrometheusService) gin.HandlerFunc {
 	})
 }
 
+func promLabelsReader(svc *service.PrometheusService) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		block_team_id := c.Request.FormValue("block-team-id")
+		block_team_ids, err := splitStrings(block_team_id)
+		if err != nil {
+			code, obj := handleError(err)
+			c.JSON(code, obj)
+			return
+		}
+		// parse form first, or `match[]` in POST body will be lost
+		c.Request.ParseForm()
+		args := model.PromMetaParams{
+			StartTime:   c.Request.FormValue("start"),
+			EndTime:     c.Request.FormValue("end"),
+			Matchers:    c.Request.Form["match[]"],
+			Context:     c.Request.Context(),
+			BlockTeamID: block_team_ids,
+			OrgID:       c.Request.Header.Get(common.HEADER_KEY_X_ORG_ID),
+		}
+		result, err := svc.PromLabelNamesService(&args, c.Request.Context())
+		if err != nil {
+			code, obj := handleError(err)
+			c.JSON(code, obj)
+			return
+		}
+		c.JSON(200, result)
+	})
+}
+
+func promMetadataReader(svc *service.PrometheusService) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		args := model.PromMetaParams{
+			Metric:  c.Request.FormValue("metric"),
+			Context: c.Request.Context(),
+			OrgID:   c.Request.Header.Get(common.HEADER_KEY_X_ORG_ID),
+		}
+		limit := c.Request.FormValue("limit")
+		err := setRouterArgs(limit, &args.Limit, 0, strconv.Atoi)
+		if err != nil {
+			code, obj := handleError(err)
+			c.JSON(code, obj)
+			return
+		}
+		result, err := svc.PromMetadataService(&args, c.Request.Context())
+		if err != nil {
+			code, obj := handleError(err)
+			c.JSON(code, obj)
+			return
+		}
+		c.JSON(200, result)
+	})
+}
+
+func promBuildInfo(svc *service.PrometheusService) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		c.JSON(200, svc.PromBuildInfoService())
+	})
+}
+
+func promRules(ruleManager *rules.RuleManager) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		ruleType := c.Request.FormValue("type")
+		if ruleType != "" && ruleType != rules.RULE_TYPE_PARAM_ALERT && ruleType != rules.RULE_TYPE_PARAM_RECORD {
+			c.JSON(400, &model.PromQueryResponse{Error: "unsupported type: " + ruleType, Status: _STATUS_FAIL})
+			return
+		}
+		c.JSON(200, &model.PromQueryResponse{Data: ruleManager.RuleDiscovery(ruleType), Status: _STATUS_SUCCESS})
+	})
+}
+
+func promAlerts(ruleManager *rules.RuleManager) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		c.JSON(200, &model.PromQueryResponse{Data: ruleManager.AlertDiscovery(), Status: _STATUS_SUCCESS})
+	})
+}
+
 func promSeriesReader(svc *service.PrometheusService) gin.HandlerFunc {
 	return gin.HandlerFunc(func(c *gin.Context) {
 		args := model.PromQueryParams{
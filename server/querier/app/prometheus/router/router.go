@@ -17,19 +17,41 @@
 package router
 
 import (
+	"context"
+	"strconv"
+
 	"github.com/gin-gonic/gin"
 
+	"github.com/deepflowio/deepflow/server/libs/utils"
 	"github.com/deepflowio/deepflow/server/querier/app/prometheus/router/packet_adapter"
+	"github.com/deepflowio/deepflow/server/querier/app/prometheus/rules"
 	"github.com/deepflowio/deepflow/server/querier/app/prometheus/service"
 	"github.com/deepflowio/deepflow/server/querier/config"
 )
 
-func PrometheusRouter(e *gin.Engine) {
+func PrometheusRouter(ctx context.Context, e *gin.Engine) {
 	// only one instance during server lifetime
 	prometheusService := service.NewPrometheusService()
 	// Both SetRate and Acquire are expanded by 1000 times, making it suitable for small QPS scenarios.
 	prometheusService.QPSLeakyBucket.Init(uint64(config.Cfg.Prometheus.QPSLimit * 1000))
 
+	// rule manager evaluates alerting and recording rules in background
+	var ruleManager *rules.RuleManager
+	if config.Cfg.Prometheus.Rules.Enabled {
+		orgID := strconv.Itoa(int(config.Cfg.Prometheus.Rules.OrgID))
+		ruleManager = rules.NewRuleManager(&config.Cfg.Prometheus.Rules,
+			prometheusService.RuleQueryFunc(orgID), prometheusService.RuleQueryable(orgID), service.NewPrometheusLogger())
+		ruleManager.Start()
+		// stop evaluating rules and close the connection to ingester when server exits
+		wg := utils.GetWaitGroupInCtx(ctx)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-ctx.Done()
+			ruleManager.Stop()
+		}()
+	}
+
 	// api router for prometheus
 	e.POST("/api/v1/prom/read", Limiter(prometheusService.QPSLeakyBucket), promReader(prometheusService))
 
@@ -44,6 +66,9 @@ func PrometheusRouter(e *gin.Engine) {
 		promGroup.GET("/api/v1/series", promSeriesReader(prometheusService))
 		promGroup.POST("/api/v1/series", promSeriesReader(prometheusService))
 		promGroup.GET("/api/v1/label/:labelName/values", promTagValuesReader(prometheusService))
+		promGroup.GET("/api/v1/labels", promLabelsReader(prometheusService))
+		promGroup.POST("/api/v1/labels", promLabelsReader(prometheusService))
+		promGroup.GET("/api/v1/metadata", promMetadataReader(prometheusService))
 
 		// not use "/prom/api/v1/adapter/:name", suitable for map[rouer key]counter in statsd
 		for _, v := range []string{"label", "query_range", "query", "series"} {
@@ -56,4 +81,7 @@ func PrometheusRouter(e *gin.Engine) {
 	e.GET("/prom/api/v1/analysis", promQLAnalysis(prometheusService))
 	e.GET("/prom/api/v1/parse", promQLParse(prometheusService))
 	e.GET("/prom/api/v1/addfilter", promQLAddFilters(prometheusService))
+	e.GET("/prom/api/v1/status/buildinfo", promBuildInfo(prometheusService))
+	e.GET("/prom/api/v1/rules", promRules(ruleManager))
+	e.GET("/prom/api/v1/alerts", promAlerts(ruleManager))
 }
@@ -0,0 +1,213 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rules
+
+import (
+	"context"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	kitlog "github.com/go-kit/log"
+	logging "github.com/op/go-logging"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/storage"
+
+	"github.com/deepflowio/deepflow/server/querier/app/prometheus/config"
+	"github.com/deepflowio/deepflow/server/querier/app/prometheus/model"
+)
+
+var log = logging.MustGetLogger("prometheus.rules")
+
+const (
+	RULE_TYPE_PARAM_ALERT  = "alert"
+	RULE_TYPE_PARAM_RECORD = "record"
+)
+
+// RuleManager evaluates prometheus alerting and recording rules with the PromQL engine of querier,
+// results of recording rules are written back as prometheus metrics, firing alerts are written as alert events
+type RuleManager struct {
+	cfg     *config.PrometheusRules
+	manager *rules.Manager
+	sender  *IngesterSender
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+func NewRuleManager(cfg *config.PrometheusRules, queryFunc rules.QueryFunc, queryable storage.Queryable, logger kitlog.Logger) *RuleManager {
+	ctx, cancel := context.WithCancel(context.Background())
+	sender := NewIngesterSender(cfg.IngesterAddress, cfg.OrgID, time.Duration(cfg.SendTimeout)*time.Second)
+	notifier := newAlertNotifier(sender, cfg.OrgID)
+	manager := rules.NewManager(&rules.ManagerOptions{
+		ExternalURL: &url.URL{},
+		QueryFunc:   queryFunc,
+		NotifyFunc:  notifier.Notify,
+		Context:     ctx,
+		Appendable:  &ingesterAppendable{sender: sender},
+		Queryable:   queryable,
+		Logger:      logger,
+		// same as the default values of prometheus
+		OutageTolerance: time.Hour,
+		ForGracePeriod:  10 * time.Minute,
+		ResendDelay:     ALERT_RESEND_DELAY,
+	})
+	return &RuleManager{
+		cfg:     cfg,
+		manager: manager,
+		sender:  sender,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+}
+
+func (m *RuleManager) Start() {
+	if err := m.reload(); err != nil {
+		log.Errorf("load prometheus rule files failed: %s", err)
+	}
+	go m.manager.Run()
+	go m.reloadLoop()
+	log.Infof("prometheus rule manager started, rule files: %v", m.cfg.RuleFiles)
+}
+
+func (m *RuleManager) Stop() {
+	close(m.done)
+	m.manager.Stop()
+	m.cancel()
+	m.sender.Close()
+}
+
+func (m *RuleManager) reloadLoop() {
+	ticker := time.NewTicker(time.Duration(m.cfg.ReloadInterval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+			if err := m.reload(); err != nil {
+				log.Errorf("reload prometheus rule files failed: %s", err)
+			}
+		}
+	}
+}
+
+// reload reads rule files again, groups which are not changed keep their states
+func (m *RuleManager) reload() error {
+	files := []string{}
+	for _, pattern := range m.cfg.RuleFiles {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return err
+		}
+		files = append(files, matches...)
+	}
+	return m.manager.Update(time.Duration(m.cfg.EvaluationInterval)*time.Second, files, nil, "", nil)
+}
+
+// RuleDiscovery returns rule groups, ruleType is `alert` or `record`, empty means all rules
+func (m *RuleManager) RuleDiscovery(ruleType string) *model.PromRuleDiscovery {
+	discovery := &model.PromRuleDiscovery{RuleGroups: []*model.PromRuleGroup{}}
+	if m == nil {
+		return discovery
+	}
+	for _, group := range m.manager.RuleGroups() {
+		apiGroup := &model.PromRuleGroup{
+			Name:           group.Name(),
+			File:           group.File(),
+			Rules:          []interface{}{},
+			Interval:       group.Interval().Seconds(),
+			Limit:          group.Limit(),
+			EvaluationTime: group.GetEvaluationTime().Seconds(),
+			LastEvaluation: group.GetLastEvaluation(),
+		}
+		for _, rule := range group.Rules() {
+			lastError := ""
+			if rule.LastError() != nil {
+				lastError = rule.LastError().Error()
+			}
+			switch r := rule.(type) {
+			case *rules.AlertingRule:
+				if ruleType == RULE_TYPE_PARAM_RECORD {
+					continue
+				}
+				apiGroup.Rules = append(apiGroup.Rules, &model.PromAlertingRule{
+					State:          r.State().String(),
+					Name:           r.Name(),
+					Query:          r.Query().String(),
+					Duration:       r.HoldDuration().Seconds(),
+					Labels:         r.Labels(),
+					Annotations:    r.Annotations(),
+					Alerts:         rulesAlertsToAPIAlerts(r.ActiveAlerts()),
+					Health:         string(r.Health()),
+					LastError:      lastError,
+					EvaluationTime: r.GetEvaluationDuration().Seconds(),
+					LastEvaluation: r.GetEvaluationTimestamp(),
+					Type:           model.RULE_TYPE_ALERTING,
+				})
+			case *rules.RecordingRule:
+				if ruleType == RULE_TYPE_PARAM_ALERT {
+					continue
+				}
+				apiGroup.Rules = append(apiGroup.Rules, &model.PromRecordingRule{
+					Name:           r.Name(),
+					Query:          r.Query().String(),
+					Labels:         r.Labels(),
+					Health:         string(r.Health()),
+					LastError:      lastError,
+					EvaluationTime: r.GetEvaluationDuration().Seconds(),
+					LastEvaluation: r.GetEvaluationTimestamp(),
+					Type:           model.RULE_TYPE_RECORDING,
+				})
+			}
+		}
+		discovery.RuleGroups = append(discovery.RuleGroups, apiGroup)
+	}
+	return discovery
+}
+
+// AlertDiscovery returns all active alerts
+func (m *RuleManager) AlertDiscovery() *model.PromAlertDiscovery {
+	discovery := &model.PromAlertDiscovery{Alerts: []*model.PromAlert{}}
+	if m == nil {
+		return discovery
+	}
+	for _, rule := range m.manager.AlertingRules() {
+		discovery.Alerts = append(discovery.Alerts, rulesAlertsToAPIAlerts(rule.ActiveAlerts())...)
+	}
+	sort.Slice(discovery.Alerts, func(i, j int) bool {
+		return discovery.Alerts[i].Labels.String() < discovery.Alerts[j].Labels.String()
+	})
+	return discovery
+}
+
+func rulesAlertsToAPIAlerts(alerts []*rules.Alert) []*model.PromAlert {
+	apiAlerts := make([]*model.PromAlert, 0, len(alerts))
+	for _, alert := range alerts {
+		activeAt := alert.ActiveAt
+		apiAlerts = append(apiAlerts, &model.PromAlert{
+			Labels:      alert.Labels,
+			Annotations: alert.Annotations,
+			State:       strings.ToLower(alert.State.String()),
+			ActiveAt:    &activeAt,
+			Value:       strconv.FormatFloat(alert.Value, 'e', -1, 64),
+		})
+	}
+	return apiAlerts
+}
@@ -0,0 +1,352 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rules
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/storage"
+
+	"github.com/deepflowio/deepflow/message/alert_event"
+	"github.com/deepflowio/deepflow/server/libs/codec"
+	"github.com/deepflowio/deepflow/server/libs/datatype"
+	"github.com/deepflowio/deepflow/server/libs/datatype/prompb"
+	"github.com/deepflowio/deepflow/server/libs/flow-metrics/pb"
+)
+
+const (
+	// keep frames much smaller than datatype.MESSAGE_FRAME_SIZE_MAX
+	MAX_FRAME_PAYLOAD_SIZE = 256 << 10
+	// uncompressed size of time series in a remote write message, snappy output is at most about 1/6 larger
+	MAX_REMOTE_WRITE_SIZE = MAX_FRAME_PAYLOAD_SIZE
+
+	ALERT_EVENT_POLICY_TYPE_CUSTOM = 3
+	ALERT_EVENT_LEVEL_CRITICAL     = 1
+	ALERT_EVENT_LEVEL_ERROR        = 2
+	ALERT_EVENT_LEVEL_WARN         = 3
+	ALERT_EVENT_LEVEL_INFO         = 6
+
+	LABEL_SEVERITY = "severity"
+
+	// firing alerts are resent every ALERT_RESEND_DELAY
+	ALERT_RESEND_DELAY = time.Minute
+	ALERT_NOTIFIED_TTL = 10 * ALERT_RESEND_DELAY
+)
+
+var severityToEventLevel = map[string]uint32{
+	"critical": ALERT_EVENT_LEVEL_CRITICAL,
+	"error":    ALERT_EVENT_LEVEL_ERROR,
+	"warning":  ALERT_EVENT_LEVEL_WARN,
+	"warn":     ALERT_EVENT_LEVEL_WARN,
+	"info":     ALERT_EVENT_LEVEL_INFO,
+}
+
+// IngesterSender sends messages to the receiver of ingester, using the same frame format as deepflow-agent:
+// BaseHeader + FlowHeader + payload, payload is a sequence of length-prefixed messages
+type IngesterSender struct {
+	addr    string
+	orgID   uint16
+	timeout time.Duration
+
+	conn net.Conn
+	lock sync.Mutex
+}
+
+func NewIngesterSender(addr string, orgID uint16, timeout time.Duration) *IngesterSender {
+	return &IngesterSender{addr: addr, orgID: orgID, timeout: timeout}
+}
+
+// Send encodes messages into frames which are no larger than MAX_FRAME_PAYLOAD_SIZE and sends them,
+// a message larger than MAX_FRAME_PAYLOAD_SIZE is sent in a frame by itself
+func (s *IngesterSender) Send(msgType datatype.MessageType, messages [][]byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	encoder := &codec.SimpleEncoder{}
+	for _, message := range messages {
+		// 4 bytes of length prefix
+		if len(encoder.Bytes()) > 0 && len(encoder.Bytes())+4+len(message) > MAX_FRAME_PAYLOAD_SIZE {
+			if err := s.sendFrame(msgType, encoder.Bytes()); err != nil {
+				return err
+			}
+			encoder.Reset()
+		}
+		encoder.WriteBytes(message)
+	}
+	if len(encoder.Bytes()) > 0 {
+		return s.sendFrame(msgType, encoder.Bytes())
+	}
+	return nil
+}
+
+func (s *IngesterSender) sendFrame(msgType datatype.MessageType, payload []byte) error {
+	if s.conn == nil {
+		conn, err := net.DialTimeout("tcp", s.addr, s.timeout)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	headerLen := datatype.MESSAGE_HEADER_LEN + datatype.FLOW_HEADER_LEN
+	frame := make([]byte, headerLen, headerLen+len(payload))
+	baseHeader := datatype.BaseHeader{
+		FrameSize: uint32(headerLen + len(payload)),
+		Type:      msgType,
+	}
+	baseHeader.Encode(frame)
+	flowHeader := datatype.FlowHeader{
+		Version: datatype.LATEST_VERSION,
+		OrgID:   s.orgID,
+	}
+	flowHeader.Encode(frame[datatype.MESSAGE_HEADER_LEN:])
+	frame = append(frame, payload...)
+
+	s.conn.SetWriteDeadline(time.Now().Add(s.timeout))
+	if _, err := s.conn.Write(frame); err != nil {
+		// reconnect at next sending
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (s *IngesterSender) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// ingesterAppendable writes results of recording rules to the prometheus path of ingester
+type ingesterAppendable struct {
+	sender *IngesterSender
+}
+
+func (a *ingesterAppendable) Appender(ctx context.Context) storage.Appender {
+	return &ingesterAppender{sender: a.sender}
+}
+
+type ingesterAppender struct {
+	sender *IngesterSender
+	series []prompb.TimeSeries
+}
+
+func (a *ingesterAppender) Append(ref storage.SeriesRef, l labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
+	pbLabels := make([]prompb.Label, 0, len(l))
+	for _, label := range l {
+		pbLabels = append(pbLabels, prompb.Label{Name: label.Name, Value: label.Value})
+	}
+	a.series = append(a.series, prompb.TimeSeries{
+		Labels:  pbLabels,
+		Samples: []prompb.Sample{{Value: v, Timestamp: t}},
+	})
+	return 0, nil
+}
+
+func (a *ingesterAppender) AppendExemplar(ref storage.SeriesRef, l labels.Labels, e exemplar.Exemplar) (storage.SeriesRef, error) {
+	// exemplars are not supported by DeepFlow
+	return 0, nil
+}
+
+func (a *ingesterAppender) Commit() error {
+	if len(a.series) == 0 {
+		return nil
+	}
+	defer func() { a.series = a.series[:0] }()
+	// split series of a large recording rule into messages, each of which fits in a frame after compressing
+	var messages [][]byte
+	start, size := 0, 0
+	for i := range a.series {
+		seriesSize := a.series[i].Size()
+		if i > start && size+seriesSize > MAX_REMOTE_WRITE_SIZE {
+			message, err := encodeRemoteWrite(a.series[start:i])
+			if err != nil {
+				return err
+			}
+			messages = append(messages, message)
+			start, size = i, 0
+		}
+		size += seriesSize
+	}
+	message, err := encodeRemoteWrite(a.series[start:])
+	if err != nil {
+		return err
+	}
+	messages = append(messages, message)
+	return a.sender.Send(datatype.MESSAGE_TYPE_PROMETHEUS, messages)
+}
+
+func (a *ingesterAppender) Rollback() error {
+	a.series = a.series[:0]
+	return nil
+}
+
+// encodeRemoteWrite encodes time series the same as deepflow-agent forwarding prometheus remote write:
+// a snappy compressed prompb.WriteRequest wrapped in pb.PrometheusMetric
+func encodeRemoteWrite(series []prompb.TimeSeries) ([]byte, error) {
+	req := &prompb.WriteRequest{Timeseries: series}
+	data, err := req.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	metric := &pb.PrometheusMetric{Metrics: snappy.Encode(nil, data)}
+	return metric.Marshal()
+}
+
+type alertKey struct {
+	expr        string
+	fingerprint uint64
+}
+
+type notifiedAlert struct {
+	activeAt time.Time
+	lastSeen time.Time
+}
+
+// alertNotifier writes firing alerts as alert events. Rule manager resends firing alerts every
+// ResendDelay, an alert is written only once when it starts firing, identified by its rule
+// expression, label fingerprint and ActiveAt
+type alertNotifier struct {
+	sender *IngesterSender
+	orgID  uint16
+
+	lock      sync.Mutex
+	notified  map[alertKey]*notifiedAlert
+	lastPrune time.Time
+}
+
+func newAlertNotifier(sender *IngesterSender, orgID uint16) *alertNotifier {
+	return &alertNotifier{
+		sender:    sender,
+		orgID:     orgID,
+		notified:  make(map[alertKey]*notifiedAlert),
+		lastPrune: time.Now(),
+	}
+}
+
+func (n *alertNotifier) Notify(ctx context.Context, expr string, alerts ...*rules.Alert) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	now := time.Now()
+	n.prune(now)
+	messages := make([][]byte, 0, len(alerts))
+	newAlerts := make(map[alertKey]*notifiedAlert, len(alerts))
+	for _, alert := range alerts {
+		key := alertKey{expr: expr, fingerprint: alert.Labels.Hash()}
+		// resolved alerts are sent by rule manager too, only record firing ones
+		if alert.State != rules.StateFiring || !alert.ResolvedAt.IsZero() {
+			delete(n.notified, key)
+			continue
+		}
+		if notified, ok := n.notified[key]; ok && notified.activeAt.Equal(alert.ActiveAt) {
+			notified.lastSeen = now
+			continue
+		}
+		message, err := alertToEvent(alert, n.orgID).Marshal()
+		if err != nil {
+			log.Warningf("marshal alert event failed: %s", err)
+			continue
+		}
+		messages = append(messages, message)
+		newAlerts[key] = &notifiedAlert{activeAt: alert.ActiveAt, lastSeen: now}
+	}
+	if len(messages) == 0 {
+		return
+	}
+	if err := n.sender.Send(datatype.MESSAGE_TYPE_ALERT_EVENT, messages); err != nil {
+		// not recorded, they will be sent again at next resending
+		log.Warningf("send %d alert events to ingester failed: %s", len(messages), err)
+		return
+	}
+	for key, notified := range newAlerts {
+		n.notified[key] = notified
+	}
+}
+
+// prune forgets alerts which are not resent for a long time, e.g. their rules have been removed
+func (n *alertNotifier) prune(now time.Time) {
+	if now.Sub(n.lastPrune) < ALERT_NOTIFIED_TTL {
+		return
+	}
+	n.lastPrune = now
+	for key, notified := range n.notified {
+		if now.Sub(notified.lastSeen) > ALERT_NOTIFIED_TTL {
+			delete(n.notified, key)
+		}
+	}
+}
+
+func alertToEvent(alert *rules.Alert, orgID uint16) *alert_event.AlertEvent {
+	alertName := alert.Labels.Get(labels.AlertName)
+	eventLevel, ok := severityToEventLevel[strings.ToLower(alert.Labels.Get(LABEL_SEVERITY))]
+	if !ok {
+		eventLevel = ALERT_EVENT_LEVEL_WARN
+	}
+	// labels and annotations are kept as string tags, annotations are prefixed to avoid conflicts
+	tagKeys := make([]string, 0, len(alert.Labels)+len(alert.Annotations))
+	tagValues := make([]string, 0, len(alert.Labels)+len(alert.Annotations))
+	targetTags := make([]string, 0, len(alert.Labels))
+	for _, l := range alert.Labels {
+		tagKeys = append(tagKeys, l.Name)
+		tagValues = append(tagValues, l.Value)
+		if l.Name != labels.AlertName {
+			targetTags = append(targetTags, l.Name+"="+l.Value)
+		}
+	}
+	for _, a := range alert.Annotations {
+		tagKeys = append(tagKeys, "annotation_"+a.Name)
+		tagValues = append(tagValues, a.Value)
+	}
+	sort.Strings(targetTags)
+	timestamp := alert.FiredAt
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	var (
+		eventTime  = uint32(timestamp.Unix())
+		policyType = uint32(ALERT_EVENT_POLICY_TYPE_CUSTOM)
+		value      = alert.Value
+		target     = strings.Join(targetTags, ", ")
+		org        = uint32(orgID)
+	)
+	return &alert_event.AlertEvent{
+		Time:         &eventTime,
+		PolicyType:   &policyType,
+		AlertPolicy:  &alertName,
+		MetricValue:  &value,
+		EventLevel:   &eventLevel,
+		TargetTags:   &target,
+		TagStrKeys:   tagKeys,
+		TagStrValues: tagValues,
+		OrgId:        &org,
+	}
+}
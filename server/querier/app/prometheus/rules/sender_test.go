@@ -0,0 +1,270 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rules
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/rules"
+
+	"github.com/deepflowio/deepflow/message/alert_event"
+	"github.com/deepflowio/deepflow/server/libs/codec"
+	"github.com/deepflowio/deepflow/server/libs/datatype"
+	"github.com/deepflowio/deepflow/server/libs/datatype/prompb"
+	"github.com/deepflowio/deepflow/server/libs/flow-metrics/pb"
+)
+
+type testFrame struct {
+	baseHeader datatype.BaseHeader
+	flowHeader datatype.FlowHeader
+	messages   [][]byte
+}
+
+// startTestReceiver accepts one connection and decodes frames the same as the receiver of ingester
+func startTestReceiver(t *testing.T) (string, chan *testFrame) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames := make(chan *testFrame, 16)
+	go func() {
+		defer listener.Close()
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			header := make([]byte, datatype.MESSAGE_HEADER_LEN)
+			if _, err := io.ReadFull(conn, header); err != nil {
+				close(frames)
+				return
+			}
+			frame := &testFrame{}
+			frame.baseHeader.FrameSize = binary.BigEndian.Uint32(header)
+			frame.baseHeader.Type = datatype.MessageType(header[datatype.MESSAGE_TYPE_OFFSET])
+			body := make([]byte, int(frame.baseHeader.FrameSize)-datatype.MESSAGE_HEADER_LEN)
+			if _, err := io.ReadFull(conn, body); err != nil {
+				close(frames)
+				return
+			}
+			frame.flowHeader.Decode(body)
+			decoder := &codec.SimpleDecoder{}
+			decoder.Init(body[datatype.FLOW_HEADER_LEN:])
+			for !decoder.IsEnd() {
+				frame.messages = append(frame.messages, decoder.ReadBytes())
+			}
+			if decoder.Failed() {
+				t.Error("decode frame failed")
+			}
+			frames <- frame
+		}
+	}()
+	return listener.Addr().String(), frames
+}
+
+func receiveFrame(t *testing.T, frames chan *testFrame) *testFrame {
+	select {
+	case frame := <-frames:
+		if frame == nil {
+			t.Fatal("connection closed")
+		}
+		return frame
+	case <-time.After(5 * time.Second):
+		t.Fatal("receive frame timeout")
+	}
+	return nil
+}
+
+func TestIngesterAppender(t *testing.T) {
+	addr, frames := startTestReceiver(t)
+	sender := NewIngesterSender(addr, 2, time.Second)
+	defer sender.Close()
+
+	appender := (&ingesterAppendable{sender: sender}).Appender(context.Background())
+	appender.Append(0, labels.FromStrings(labels.MetricName, "job:requests:rate5m", "job", "api"), 1000, 3.5)
+	appender.Append(0, labels.FromStrings(labels.MetricName, "job:requests:rate5m", "job", "web"), 1000, 1.5)
+	if err := appender.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	frame := receiveFrame(t, frames)
+	if frame.baseHeader.Type != datatype.MESSAGE_TYPE_PROMETHEUS {
+		t.Errorf("message type got %d, want %d", frame.baseHeader.Type, datatype.MESSAGE_TYPE_PROMETHEUS)
+	}
+	if frame.flowHeader.OrgID != 2 {
+		t.Errorf("org id got %d, want 2", frame.flowHeader.OrgID)
+	}
+	if len(frame.messages) != 1 {
+		t.Fatalf("message count got %d, want 1", len(frame.messages))
+	}
+	metric := &pb.PrometheusMetric{}
+	if err := metric.Unmarshal(frame.messages[0]); err != nil {
+		t.Fatal(err)
+	}
+	data, err := snappy.Decode(nil, metric.Metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &prompb.WriteRequest{}
+	if err := req.Unmarshal(data); err != nil {
+		t.Fatal(err)
+	}
+	if len(req.Timeseries) != 2 {
+		t.Fatalf("time series count got %d, want 2", len(req.Timeseries))
+	}
+	ts := req.Timeseries[0]
+	if ts.Labels[1].Name != "job" || ts.Labels[1].Value != "api" || ts.Samples[0].Value != 3.5 || ts.Samples[0].Timestamp != 1000 {
+		t.Errorf("time series got %v", ts)
+	}
+}
+
+func TestAlertNotifier(t *testing.T) {
+	addr, frames := startTestReceiver(t)
+	sender := NewIngesterSender(addr, 1, time.Second)
+	defer sender.Close()
+
+	firedAt := time.Unix(1700000000, 0)
+	notifier := newAlertNotifier(sender, 1)
+	firing := &rules.Alert{
+		State:       rules.StateFiring,
+		Labels:      labels.FromStrings(labels.AlertName, "HighLatency", "service", "api", LABEL_SEVERITY, "critical"),
+		Annotations: labels.FromStrings("summary", "latency is high"),
+		Value:       0.8,
+		ActiveAt:    firedAt.Add(-time.Minute),
+		FiredAt:     firedAt,
+	}
+	notifier.Notify(context.Background(), "", firing, &rules.Alert{
+		// resolved alerts are ignored
+		State:      rules.StateInactive,
+		Labels:     labels.FromStrings(labels.AlertName, "HighLatency", "service", "web"),
+		FiredAt:    firedAt,
+		ResolvedAt: firedAt.Add(time.Minute),
+	})
+
+	frame := receiveFrame(t, frames)
+	if frame.baseHeader.Type != datatype.MESSAGE_TYPE_ALERT_EVENT {
+		t.Errorf("message type got %d, want %d", frame.baseHeader.Type, datatype.MESSAGE_TYPE_ALERT_EVENT)
+	}
+	if len(frame.messages) != 1 {
+		t.Fatalf("message count got %d, want 1", len(frame.messages))
+	}
+	event := &alert_event.AlertEvent{}
+	if err := event.Unmarshal(frame.messages[0]); err != nil {
+		t.Fatal(err)
+	}
+	if event.GetAlertPolicy() != "HighLatency" || event.GetEventLevel() != ALERT_EVENT_LEVEL_CRITICAL ||
+		event.GetTime() != uint32(firedAt.Unix()) || event.GetMetricValue() != 0.8 || event.GetOrgId() != 1 {
+		t.Errorf("alert event got %v", event)
+	}
+	if event.GetTargetTags() != "service=api, severity=critical" {
+		t.Errorf("target tags got %s", event.GetTargetTags())
+	}
+	if len(event.GetTagStrKeys()) != 4 || event.GetTagStrKeys()[3] != "annotation_summary" {
+		t.Errorf("tag keys got %v", event.GetTagStrKeys())
+	}
+
+	// resending a firing alert does not write the event again
+	notifier.Notify(context.Background(), "", firing)
+	// the same labels firing again after being resolved is a new event
+	notifier.Notify(context.Background(), "", &rules.Alert{State: rules.StateInactive, Labels: firing.Labels, ResolvedAt: firedAt.Add(2 * time.Minute)})
+	refired := *firing
+	refired.ActiveAt = firedAt.Add(3 * time.Minute)
+	refired.FiredAt = firedAt.Add(4 * time.Minute)
+	notifier.Notify(context.Background(), "", &refired)
+	frame = receiveFrame(t, frames)
+	if len(frame.messages) != 1 {
+		t.Fatalf("message count got %d, want 1", len(frame.messages))
+	}
+	event = &alert_event.AlertEvent{}
+	if err := event.Unmarshal(frame.messages[0]); err != nil {
+		t.Fatal(err)
+	}
+	if event.GetTime() != uint32(refired.FiredAt.Unix()) {
+		t.Errorf("alert event time got %d, want %d", event.GetTime(), refired.FiredAt.Unix())
+	}
+}
+
+func TestIngesterAppenderSplitMessages(t *testing.T) {
+	addr, frames := startTestReceiver(t)
+	sender := NewIngesterSender(addr, 1, time.Second)
+	defer sender.Close()
+
+	appender := (&ingesterAppendable{sender: sender}).Appender(context.Background())
+	const seriesCount = 20000
+	for i := 0; i < seriesCount; i++ {
+		appender.Append(0, labels.FromStrings(labels.MetricName, "job:requests:rate5m", "instance", strconv.Itoa(i), "path", strings.Repeat("/api", 8)), 1000, float64(i))
+	}
+	if err := appender.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	count, messageCount := 0, 0
+	for count < seriesCount {
+		frame := receiveFrame(t, frames)
+		messageCount += len(frame.messages)
+		if frame.baseHeader.FrameSize > datatype.MESSAGE_FRAME_SIZE_MAX {
+			t.Fatalf("frame size %d exceeds %d", frame.baseHeader.FrameSize, datatype.MESSAGE_FRAME_SIZE_MAX)
+		}
+		for _, message := range frame.messages {
+			metric := &pb.PrometheusMetric{}
+			if err := metric.Unmarshal(message); err != nil {
+				t.Fatal(err)
+			}
+			data, err := snappy.Decode(nil, metric.Metrics)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := &prompb.WriteRequest{}
+			if err := req.Unmarshal(data); err != nil {
+				t.Fatal(err)
+			}
+			count += len(req.Timeseries)
+		}
+	}
+	if count != seriesCount || messageCount < 2 {
+		t.Errorf("time series count got %d in %d messages, want %d in more than 1 message", count, messageCount, seriesCount)
+	}
+}
+
+func TestSendSplitFrames(t *testing.T) {
+	addr, frames := startTestReceiver(t)
+	sender := NewIngesterSender(addr, 1, time.Second)
+	defer sender.Close()
+
+	message := make([]byte, MAX_FRAME_PAYLOAD_SIZE/2)
+	if err := sender.Send(datatype.MESSAGE_TYPE_ALERT_EVENT, [][]byte{message, message, message}); err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for count < 3 {
+		frame := receiveFrame(t, frames)
+		if frame.baseHeader.FrameSize > datatype.MESSAGE_FRAME_SIZE_MAX {
+			t.Errorf("frame size %d exceeds %d", frame.baseHeader.FrameSize, datatype.MESSAGE_FRAME_SIZE_MAX)
+		}
+		count += len(frame.messages)
+	}
+}
@@ -0,0 +1,278 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/deepflowio/deepflow/server/querier/app/prometheus/cache"
+	"github.com/deepflowio/deepflow/server/querier/app/prometheus/model"
+	"github.com/deepflowio/deepflow/server/querier/common"
+	"github.com/deepflowio/deepflow/server/querier/config"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/client"
+	chCommon "github.com/deepflowio/deepflow/server/querier/engine/clickhouse/common"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/metrics"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/trans_prometheus"
+)
+
+const (
+	METADATA_TYPE_COUNTER = "counter"
+	METADATA_TYPE_GAUGE   = "gauge"
+	METADATA_TYPE_UNKNOWN = "unknown"
+
+	// PromQL engine is vendored from this version of prometheus, report it in buildinfo
+	// so that clients (e.g.: Grafana) could detect the supported features
+	PROMETHEUS_COMPATIBLE_VERSION = "2.36.2"
+)
+
+var buildInfo = model.PromBuildInfo{
+	Version:   PROMETHEUS_COMPATIBLE_VERSION,
+	GoVersion: runtime.Version(),
+}
+
+// SetBuildInfo sets version of deepflow-server which is reported in `/api/v1/status/buildinfo`
+func SetBuildInfo(branch, revision, compileTime string) {
+	buildInfo.Branch = branch
+	buildInfo.Revision = revision
+	buildInfo.BuildDate = compileTime
+}
+
+func (p *prometheusExecutor) getBuildInfo() *model.PromQueryResponse {
+	return &model.PromQueryResponse{Data: buildInfo, Status: _SUCCESS}
+}
+
+// API Spec: https://prometheus.io/docs/prometheus/latest/querying/api/#getting-label-names
+func (p *prometheusExecutor) getLabelNames(ctx context.Context, args *model.PromMetaParams) (result *model.PromQueryResponse, err error) {
+	orgID := args.OrgID
+	if orgID == "" {
+		orgID = common.DEFAULT_ORG_ID
+	}
+	start, end, err := parseMetaTimeRange(args.StartTime, args.EndTime)
+	if err != nil {
+		return nil, err
+	}
+	cacheKey := cache.LabelCacheKey("labels", orgID, args.Matchers, start.Unix(), end.Unix(), time.Duration(config.Cfg.Prometheus.Cache.LabelCacheTTL)*time.Second)
+	if names, ok := p.labelNamesCache.Get(cacheKey); ok {
+		return &model.PromQueryResponse{Data: names, Status: _SUCCESS}, nil
+	}
+
+	nameSet := map[string]struct{}{labels.MetricName: {}}
+	if len(args.Matchers) > 0 {
+		// with match[], label names come from the series selected in time range
+		seriesArgs := &model.PromQueryParams{
+			StartTime:   args.StartTime,
+			EndTime:     args.EndTime,
+			Matchers:    args.Matchers,
+			OrgID:       args.OrgID,
+			BlockTeamID: args.BlockTeamID,
+			Context:     args.Context,
+		}
+		if seriesArgs.StartTime == "" {
+			seriesArgs.StartTime = fmt.Sprintf("%d", start.Unix())
+		}
+		if seriesArgs.EndTime == "" {
+			seriesArgs.EndTime = fmt.Sprintf("%d", end.Unix())
+		}
+		series, err := p.series(context.WithValue(ctx, CtxKeyShowTag{}, true), seriesArgs)
+		if err != nil {
+			return nil, err
+		}
+		if seriesLabels, ok := series.Data.([]labels.Labels); ok {
+			for _, lbs := range seriesLabels {
+				for _, l := range lbs {
+					nameSet[l.Name] = struct{}{}
+				}
+			}
+		}
+	} else {
+		// without match[], label names come from the prometheus metrics and targets written in time range
+		labelNames, err := getLabelNamesInTimeRange(ctx, orgID, start, end)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range labelNames {
+			nameSet[name] = struct{}{}
+		}
+	}
+
+	names := make([]string, 0, len(nameSet))
+	for name := range nameSet {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	p.labelNamesCache.Add(cacheKey, names)
+	return &model.PromQueryResponse{Data: names, Status: _SUCCESS}, nil
+}
+
+// API Spec: https://prometheus.io/docs/prometheus/latest/querying/api/#querying-metric-metadata
+func (p *prometheusExecutor) getMetadata(ctx context.Context, args *model.PromMetaParams) (result *model.PromQueryResponse, err error) {
+	orgID := args.OrgID
+	if orgID == "" {
+		orgID = common.DEFAULT_ORG_ID
+	}
+	cacheKey := cache.LabelCacheKey("metadata", orgID, nil, 0, 0, 0)
+	metadata, ok := p.metadataCache.Get(cacheKey)
+	if !ok {
+		metadata = getAllMetadata(ctx, orgID)
+		p.metadataCache.Add(cacheKey, metadata)
+	}
+
+	data := map[string][]model.PromMetadata{}
+	if args.Metric != "" {
+		if m, ok := metadata[args.Metric]; ok {
+			data[args.Metric] = m
+		}
+		return &model.PromQueryResponse{Data: data, Status: _SUCCESS}, nil
+	}
+	// limit the number of metrics, sort names to return a stable result
+	metricNames := make([]string, 0, len(metadata))
+	for name := range metadata {
+		metricNames = append(metricNames, name)
+	}
+	sort.Strings(metricNames)
+	for _, name := range metricNames {
+		if args.Limit > 0 && len(data) >= args.Limit {
+			break
+		}
+		data[name] = metadata[name]
+	}
+	return &model.PromQueryResponse{Data: data, Status: _SUCCESS}, nil
+}
+
+// getLabelNamesInTimeRange returns the app label names of metrics and the target label names of targets
+// which have samples in [start, end]
+func getLabelNamesInTimeRange(ctx context.Context, orgID string, start, end time.Time) ([]string, error) {
+	chClient := client.Client{
+		Host:     config.Cfg.Clickhouse.Host,
+		Port:     config.Cfg.Clickhouse.Port,
+		UserName: config.Cfg.Clickhouse.User,
+		Password: config.Cfg.Clickhouse.Password,
+		DB:       chCommon.DB_NAME_PROMETHEUS,
+		Context:  ctx,
+	}
+	timeFilter := fmt.Sprintf("time >= %d AND time <= %d", start.Unix(), end.Unix())
+
+	names := []string{}
+	sql := fmt.Sprintf("SELECT DISTINCT metric_id FROM %s WHERE %s", TABLE_NAME_SAMPLES, timeFilter)
+	rst, err := chClient.DoQuery(&client.QueryParams{Sql: sql, ORGID: orgID})
+	if err != nil {
+		return nil, err
+	}
+	if prometheusMap, ok := trans_prometheus.ORGPrometheus[orgID]; ok && len(rst.Values) > 0 {
+		metricIDs := make(map[int]struct{}, len(rst.Values))
+		for _, row := range rst.Values {
+			if id, ok := row.([]interface{})[0].(int); ok {
+				metricIDs[id] = struct{}{}
+			}
+		}
+		for metricName, id := range prometheusMap.MetricNameToID {
+			if _, ok := metricIDs[id]; !ok {
+				continue
+			}
+			for _, appLabel := range prometheusMap.MetricAppLabelLayout[metricName] {
+				names = append(names, appLabel.AppLabelName)
+			}
+		}
+	}
+
+	sql = fmt.Sprintf("SELECT DISTINCT arrayJoin(splitByString(', ', dictGet('flow_tag.prometheus_target_label_layout_map', 'target_label_names', target_id))) AS label_name FROM %s WHERE %s", TABLE_NAME_SAMPLES, timeFilter)
+	rst, err = chClient.DoQuery(&client.QueryParams{Sql: sql, ORGID: orgID})
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rst.Values {
+		if name, ok := row.([]interface{})[0].(string); ok && name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func getAllMetadata(ctx context.Context, orgID string) map[string][]model.PromMetadata {
+	metadata := map[string][]model.PromMetadata{}
+	// prometheus native metrics, type and help are not stored when remote write
+	samples := clickhouse.GetTables(chCommon.DB_NAME_PROMETHEUS, "", orgID, false, ctx, nil)
+	for _, v := range samples.Values {
+		tableName := v.([]interface{})[0].(string)
+		metadata[tableName] = []model.PromMetadata{{Type: METADATA_TYPE_UNKNOWN}}
+	}
+	// deepflow native metrics, type and help come from db_descriptions
+	db := chCommon.DB_NAME_FLOW_METRICS
+	for _, table := range chCommon.DB_TABLE_MAP[db] {
+		tableMetrics, err := metrics.GetMetricsByDBTable(db, table, "", "", orgID, false, ctx)
+		if err != nil {
+			log.Warningf("get metrics of %s.%s failed: %s", db, table, err)
+			continue
+		}
+		for field, m := range tableMetrics {
+			if m.Category == METRICS_CATEGORY_TAG {
+				continue
+			}
+			meta := model.PromMetadata{
+				Type: metricsTypeToMetadataType(m.Type),
+				Help: m.Description,
+				Unit: m.Unit,
+			}
+			if meta.Help == "" {
+				meta.Help = m.DisplayName
+			}
+			for _, interval := range []string{"1m", "1s"} {
+				metricsName := fmt.Sprintf("%s__%s__%s__%s", db, table, field, interval)
+				metadata[metricsName] = []model.PromMetadata{meta}
+			}
+		}
+	}
+	return metadata
+}
+
+func metricsTypeToMetadataType(metricsType int) string {
+	switch metricsType {
+	case metrics.METRICS_TYPE_COUNTER:
+		return METADATA_TYPE_COUNTER
+	case metrics.METRICS_TYPE_UNKNOWN, metrics.METRICS_TYPE_OTHER:
+		return METADATA_TYPE_UNKNOWN
+	default:
+		return METADATA_TYPE_GAUGE
+	}
+}
+
+// default time range of metadata apis is the last 1 hour
+func parseMetaTimeRange(startTime, endTime string) (start time.Time, end time.Time, err error) {
+	end = time.Now()
+	if endTime != "" {
+		if end, err = parseTime(endTime); err != nil {
+			return
+		}
+	}
+	start = end.Add(-time.Hour)
+	if startTime != "" {
+		if start, err = parseTime(startTime); err != nil {
+			return
+		}
+	}
+	if start.After(end) {
+		err = fmt.Errorf("end timestamp must not be before start time")
+	}
+	return
+}
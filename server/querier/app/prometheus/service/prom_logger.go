@@ -26,7 +26,7 @@ type prometheusLogger struct {
 	pool *sync.Pool
 }
 
-func newPrometheusLogger() *prometheusLogger {
+func NewPrometheusLogger() *prometheusLogger {
 	return &prometheusLogger{
 		pool: &sync.Pool{
 			New: func() any {
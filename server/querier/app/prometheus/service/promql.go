@@ -84,9 +84,13 @@ type prometheusExecutor struct {
 	queryKeyGenerator *cache.WeakKeyGenerator
 	cacheKeyGenerator *cache.CacheKeyGenerator
 	locker            sync.Locker
+
+	labelNamesCache *cache.LabelCache[[]string]
+	metadataCache   *cache.LabelCache[map[string][]model.PromMetadata]
 }
 
 func NewPrometheusExecutor(delta time.Duration) *prometheusExecutor {
+	labelCacheTTL := time.Duration(config.Cfg.Prometheus.Cache.LabelCacheTTL) * time.Second
 	executor := &prometheusExecutor{
 		extraLabelCache: map[string]*lru.Cache[string, string]{},
 		lookbackDelta:   delta,
@@ -95,6 +99,9 @@ func NewPrometheusExecutor(delta time.Duration) *prometheusExecutor {
 		queryKeyGenerator: &cache.WeakKeyGenerator{},
 		cacheKeyGenerator: &cache.CacheKeyGenerator{},
 		locker:            &sync.Mutex{},
+
+		labelNamesCache: cache.NewLabelCache[[]string](config.Cfg.Prometheus.Cache.CacheMaxCount, labelCacheTTL),
+		metadataCache:   cache.NewLabelCache[map[string][]model.PromMetadata](config.Cfg.Prometheus.Cache.CacheMaxCount, labelCacheTTL),
 	}
 	go executor.triggerLoadExternalTag()
 	return executor
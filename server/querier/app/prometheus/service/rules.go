@@ -0,0 +1,78 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/storage"
+
+	"github.com/deepflowio/deepflow/server/querier/app/prometheus/model"
+	"github.com/deepflowio/deepflow/server/querier/config"
+)
+
+// RuleQueryFunc returns a query function for the rule manager, rule expressions are executed
+// as instant queries in the given organization, same as `/api/v1/query`
+func (s *PrometheusService) RuleQueryFunc(orgID string) rules.QueryFunc {
+	return func(ctx context.Context, q string, t time.Time) (promql.Vector, error) {
+		args := &model.PromQueryParams{
+			Promql:    q,
+			StartTime: strconv.FormatFloat(float64(t.UnixMilli())/1000, 'f', -1, 64),
+			OrgID:     orgID,
+			Slimit:    config.Cfg.Prometheus.SeriesLimit,
+			Context:   ctx,
+		}
+		args.EndTime = args.StartTime
+		result, err := s.executor.promQueryExecute(ctx, args, s.engine)
+		if err != nil {
+			return nil, err
+		}
+		data, ok := result.Data.(*model.PromQueryData)
+		if !ok {
+			return nil, errors.New("rule result is empty")
+		}
+		// converts scalar into vector results, same as rules.EngineQueryFunc
+		switch v := data.Result.(type) {
+		case promql.Vector:
+			return v, nil
+		case promql.Scalar:
+			return promql.Vector{promql.Sample{
+				Point:  promql.Point(v),
+				Metric: labels.Labels{},
+			}}, nil
+		default:
+			return nil, errors.New("rule result is not a vector or scalar")
+		}
+	}
+}
+
+// RuleQueryable returns a queryable for the rule manager to restore `for` state of alerts from `ALERTS_FOR_STATE`
+func (s *PrometheusService) RuleQueryable(orgID string) storage.Queryable {
+	reader := &prometheusReader{
+		orgID:                   orgID,
+		slimit:                  config.Cfg.Prometheus.SeriesLimit,
+		getExternalTagFromCache: s.executor.convertExternalTagToQuerierAllowTag,
+		addExternalTagToCache:   s.executor.addExtraLabelsToCache,
+	}
+	return &RemoteReadQuerierable{Args: &model.PromQueryParams{OrgID: orgID}, Ctx: context.Background(), reader: reader}
+}
@@ -51,7 +51,7 @@ type PrometheusService struct {
 func NewPrometheusService() *PrometheusService {
 	// query.max-samples set to same default value in prometheus, ref settings: https://github.com/prometheus/prometheus/blob/main/cmd/prometheus/main.go#L407
 	opts := promql.EngineOpts{
-		Logger:                   newPrometheusLogger(),
+		Logger:                   NewPrometheusLogger(),
 		Reg:                      nil,
 		MaxSamples:               config.Cfg.Prometheus.MaxSamples,
 		LookbackDelta:            defaultLookbackDelta,
@@ -96,6 +96,18 @@ func (s *PrometheusService) PromLabelValuesService(args *model.PromMetaParams, c
 	return s.executor.getTagValues(ctx, args)
 }
 
+func (s *PrometheusService) PromLabelNamesService(args *model.PromMetaParams, ctx context.Context) (*model.PromQueryResponse, error) {
+	return s.executor.getLabelNames(ctx, args)
+}
+
+func (s *PrometheusService) PromMetadataService(args *model.PromMetaParams, ctx context.Context) (*model.PromQueryResponse, error) {
+	return s.executor.getMetadata(ctx, args)
+}
+
+func (s *PrometheusService) PromBuildInfoService() *model.PromQueryResponse {
+	return s.executor.getBuildInfo()
+}
+
 func (s *PrometheusService) PromSeriesQueryService(args *model.PromQueryParams, ctx context.Context) (*model.PromQueryResponse, error) {
 	return s.executor.series(ctx, args)
 }
@@ -51,39 +51,50 @@ func Deserialize[T any](data []byte) (*T, error) {
 	return &result, err
 }
 
+// NewTLSClientConfig builds tls config for connecting to external apm, used by both http and grpc clients
+func NewTLSClientConfig(tlsConfig *config.TLSConfig) (*tls.Config, error) {
+	tlsClientConfig := &tls.Config{}
+	if tlsConfig.Insecure {
+		tlsClientConfig.InsecureSkipVerify = true
+		return tlsClientConfig, nil
+	}
+	clientTLSCert, err := tls.LoadX509KeyPair(tlsConfig.CertFile, tlsConfig.KeyFile)
+	if err != nil {
+		log.Errorf("load cert file fot tls verification false! err: %s", err)
+		return nil, err
+	}
+	certPool, err := x509.SystemCertPool()
+	if err != nil {
+		log.Errorf("create cert pool false! err: %s", err)
+		return nil, err
+	}
+	caCertPEM, err := os.ReadFile(tlsConfig.CAFile)
+	if err != nil {
+		log.Errorf("read ca file false! err: %s", err)
+		return nil, err
+	}
+
+	if ok := certPool.AppendCertsFromPEM(caCertPEM); !ok {
+		err = fmt.Errorf("invalid CA PEM in %s", tlsConfig.CAFile)
+		log.Error(err)
+		return nil, err
+	}
+	tlsClientConfig.RootCAs = certPool
+	tlsClientConfig.Certificates = []tls.Certificate{clientTLSCert}
+	return tlsClientConfig, nil
+}
+
 func prepareRequest(timeout time.Duration, tlsConfig *config.TLSConfig) (*http.Client, error) {
-	client := &http.Client{}
-	http.DefaultClient.Timeout = timeout
+	if timeout <= 0 {
+		timeout = config.DEFAULT_TIMEOUT
+	}
+	client := &http.Client{Timeout: timeout}
 	if tlsConfig != nil {
-		tlsClientConfig := &tls.Config{}
-		if tlsConfig.Insecure {
-			tlsClientConfig.InsecureSkipVerify = true
-		} else {
-			clientTLSCert, err := tls.LoadX509KeyPair(tlsConfig.CertFile, tlsConfig.KeyFile)
-			if err != nil {
-				log.Errorf("load cert file fot tls verification false! err: %s", err)
-				return nil, err
-			}
-			certPool, err := x509.SystemCertPool()
-			if err != nil {
-				log.Errorf("create cert pool false! err: %s", err)
-				return nil, err
-			}
-			caCertPEM, err := os.ReadFile(tlsConfig.CAFile)
-			if err != nil {
-				log.Errorf("read ca file false! err: %s", err)
-				return nil, err
-			}
-
-			if ok := certPool.AppendCertsFromPEM(caCertPEM); !ok {
-				log.Errorf("invalid cert for CA PEM! err: %s", err)
-				return nil, err
-			}
-			tlsClientConfig.RootCAs = certPool
-			tlsClientConfig.Certificates = []tls.Certificate{clientTLSCert}
+		tlsClientConfig, err := NewTLSClientConfig(tlsConfig)
+		if err != nil {
+			return nil, err
 		}
-
-		http.DefaultClient.Transport = &http.Transport{TLSClientConfig: tlsClientConfig}
+		client.Transport = &http.Transport{TLSClientConfig: tlsClientConfig}
 	}
 	return client, nil
 }
@@ -0,0 +1,83 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package common
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/config"
+)
+
+func writeTestKeyPair(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	return certFile, keyFile
+}
+
+func TestNewTLSClientConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestKeyPair(t, dir)
+
+	tlsConfig, err := NewTLSClientConfig(&config.TLSConfig{CAFile: certFile, CertFile: certFile, KeyFile: keyFile})
+	if err != nil || tlsConfig == nil || len(tlsConfig.Certificates) != 1 {
+		t.Fatalf("new tls config: %v %v", tlsConfig, err)
+	}
+
+	caFile := filepath.Join(dir, "ca.crt")
+	os.WriteFile(caFile, []byte("not a pem"), 0600)
+	tlsConfig, err = NewTLSClientConfig(&config.TLSConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile})
+	if err == nil || tlsConfig != nil || !strings.Contains(err.Error(), caFile) {
+		t.Errorf("invalid ca file should fail: %v %v", tlsConfig, err)
+	}
+}
+
+func TestPrepareRequestDefaultTimeout(t *testing.T) {
+	client, err := prepareRequest(0, nil)
+	if err != nil || client.Timeout != config.DEFAULT_TIMEOUT {
+		t.Errorf("client timeout got %v %v, want %v", client.Timeout, err, config.DEFAULT_TIMEOUT)
+	}
+}
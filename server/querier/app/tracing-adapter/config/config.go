@@ -18,6 +18,8 @@ package config
 
 import "time"
 
+const DEFAULT_TIMEOUT = 60 * time.Second
+
 type ExternalAPM struct {
 	Name        string            `yaml:"name"`
 	Addr        string            `yaml:"addr"` // e.g.: http://host:port
@@ -26,6 +28,14 @@ type ExternalAPM struct {
 	ExtraConfig map[string]string `yaml:"extra_config"`
 }
 
+// GetTimeout returns DEFAULT_TIMEOUT if timeout is not set
+func (c *ExternalAPM) GetTimeout() time.Duration {
+	if c.Timeout <= 0 {
+		return DEFAULT_TIMEOUT
+	}
+	return c.Timeout
+}
+
 type TLSConfig struct {
 	CAFile   string `yaml:"ca-file"`
 	CertFile string `yaml:"cert-file"`
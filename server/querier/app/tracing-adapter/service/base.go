@@ -17,9 +17,29 @@
 package service
 
 import (
+	"strconv"
+	"strings"
+
 	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/model"
 	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/service/packet_service"
 	"github.com/op/go-logging"
+	v1 "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+const (
+	// span kind in opentracing tag `span.kind` (jaeger) or zipkin `kind`, case insensitive
+	SpanKindServer   = "server"
+	SpanKindClient   = "client"
+	SpanKindProducer = "producer"
+	SpanKindConsumer = "consumer"
+	SpanKindInternal = "internal"
+
+	AttributeHTTPURL     = "http.url"
+	AttributeHTTPTarget  = "http.target"
+	AttributeHTTPPath    = "http.path"
+	AttributeDbOperation = "db.operation"
+	AttributeRPCMethod   = "rpc.method"
+	AttributeRPCService  = "rpc.service"
 )
 
 var (
@@ -32,6 +52,8 @@ func Register() error {
 		Adapters = make(map[string]model.TraceAdapter, 0)
 	}
 	Adapters["skywalking"] = &SkyWalkingAdapter{}
+	Adapters["jaeger"] = &JaegerAdapter{}
+	Adapters["zipkin"] = &ZipkinAdapter{}
 	subServices := packet_service.GetPacketServices()
 	if subServices != nil {
 		for k, v := range subServices {
@@ -41,3 +63,72 @@ func Register() error {
 	}
 	return nil
 }
+
+func spanKindToOTelSpanKind(kind string) int {
+	switch strings.ToLower(kind) {
+	case SpanKindServer:
+		return int(v1.Span_SPAN_KIND_SERVER)
+	case SpanKindClient:
+		return int(v1.Span_SPAN_KIND_CLIENT)
+	case SpanKindProducer:
+		return int(v1.Span_SPAN_KIND_PRODUCER)
+	case SpanKindConsumer:
+		return int(v1.Span_SPAN_KIND_CONSUMER)
+	case SpanKindInternal:
+		return int(v1.Span_SPAN_KIND_INTERNAL)
+	default:
+		return int(v1.Span_SPAN_KIND_UNSPECIFIED)
+	}
+}
+
+func spanKindToTapSide(kind string) string {
+	switch strings.ToLower(kind) {
+	case SpanKindServer, SpanKindConsumer:
+		return "s-app"
+	case SpanKindClient, SpanKindProducer:
+		return "c-app"
+	default:
+		return "app"
+	}
+}
+
+// spanIDToUniqueID uses 64 bits hex span id of jaeger/zipkin as unique id, it is unique in one trace
+func spanIDToUniqueID(spanID string, startTimeUs int64, index int) uint64 {
+	id, err := strconv.ParseUint(spanID, 16, 64)
+	if err != nil || id == 0 {
+		// same as skywalking adapter when segment id can not be parsed
+		return uint64(startTimeUs)<<32 | uint64(index*0xfff1)&0xffffff
+	}
+	return id
+}
+
+// attributesToSpanRequestInfo fills request info of span by opentracing/opentelemetry semantic conventions
+func attributesToSpanRequestInfo(attributes map[string]string, span *model.ExSpan) {
+	if method, ok := attributes[AttributeHTTPMethod]; ok {
+		// the only protocol can get from span now, same as skywalking adapter
+		span.L7Protocol, span.L7ProtocolStr = 20, "HTTP"
+		span.RequestType = method
+		for _, key := range []string{AttributeHTTPTarget, AttributeHTTPPath, AttributeHTTPURL} {
+			if resource, ok := attributes[key]; ok {
+				span.RequestResource = resource
+				break
+			}
+		}
+		for _, key := range []string{AttributeHTTPStatus_Code, AttributeHTTPStatusCode} {
+			if code, err := strconv.Atoi(attributes[key]); err == nil {
+				span.ResponseStatus = code
+				break
+			}
+		}
+		return
+	}
+	if statement, ok := attributes[AttributeDbStatement]; ok {
+		span.RequestType = attributes[AttributeDbOperation]
+		span.RequestResource = statement
+		return
+	}
+	if method, ok := attributes[AttributeRPCMethod]; ok {
+		span.RequestType = method
+		span.RequestResource = attributes[AttributeRPCService]
+	}
+}
@@ -0,0 +1,402 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/common"
+	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/config"
+	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/model"
+	"github.com/mitchellh/mapstructure"
+	"github.com/op/go-logging"
+	commonv1 "go.opentelemetry.io/proto/otlp/common/v1"
+	tracev1 "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const (
+	// jaeger-query http api, same as jaeger ui uses
+	jaeger_query_url = "api/traces"
+	// jaeger-query grpc api v3, spans are returned as OTLP, supported since jaeger v1.35:
+	// https://github.com/jaegertracing/jaeger-idl/blob/main/proto/api_v3/query_service.proto
+	jaeger_grpc_get_trace = "/jaeger.api_v3.QueryService/GetTrace"
+
+	JaegerProtocolHTTP = "http"
+	JaegerProtocolGRPC = "grpc"
+
+	JaegerRefTypeChildOf     = "CHILD_OF"
+	JaegerRefTypeFollowsFrom = "FOLLOWS_FROM"
+	JaegerTagSpanKind        = "span.kind"
+	JaegerTagHostname        = "hostname"
+	JaegerTagError           = "error"
+
+	JaegerAttributeServiceName = "service.name"
+	JaegerAttributeHostName    = "host.name"
+)
+
+type jaegerConfig struct {
+	Protocol string `mapstructure:"protocol"` // http or grpc, default: http
+}
+
+type jaegerTraceResponse struct {
+	Data   []*jaegerTrace `json:"data"`
+	Errors []jaegerError  `json:"errors"`
+}
+
+type jaegerError struct {
+	Code    int    `json:"code"`
+	Msg     string `json:"msg"`
+	TraceID string `json:"traceID"`
+}
+
+type jaegerTrace struct {
+	TraceID   string                    `json:"traceID"`
+	Spans     []*jaegerSpan             `json:"spans"`
+	Processes map[string]*jaegerProcess `json:"processes"`
+}
+
+type jaegerSpan struct {
+	TraceID       string            `json:"traceID"`
+	SpanID        string            `json:"spanID"`
+	OperationName string            `json:"operationName"`
+	References    []jaegerReference `json:"references"`
+	StartTime     int64             `json:"startTime"` // microseconds
+	Duration      int64             `json:"duration"`  // microseconds
+	Tags          []jaegerKeyValue  `json:"tags"`
+	ProcessID     string            `json:"processID"`
+	// process is embedded in span in grpc api
+	Process *jaegerProcess `json:"process,omitempty"`
+}
+
+type jaegerReference struct {
+	RefType string `json:"refType"`
+	TraceID string `json:"traceID"`
+	SpanID  string `json:"spanID"`
+}
+
+type jaegerKeyValue struct {
+	Key   string `json:"key"`
+	Type  string `json:"type"`
+	Value any    `json:"value"`
+}
+
+type jaegerProcess struct {
+	ServiceName string           `json:"serviceName"`
+	Tags        []jaegerKeyValue `json:"tags"`
+}
+
+type JaegerAdapter struct {
+}
+
+var log_jaeger = logging.MustGetLogger("tracing-adapter.jaeger")
+
+func (j *JaegerAdapter) GetTrace(traceID string, c *config.ExternalAPM) (*model.ExTrace, error) {
+	jaegerConf := &jaegerConfig{}
+	err := mapstructure.Decode(c.ExtraConfig, jaegerConf)
+	if err != nil {
+		log_jaeger.Errorf("cannot decode jaeger extra config %v, err: %s", c.ExtraConfig, err)
+		return nil, err
+	}
+	var spans []*jaegerSpan
+	switch jaegerConf.Protocol {
+	case "", JaegerProtocolHTTP:
+		spans, err = j.getTraceByHTTP(traceID, c)
+	case JaegerProtocolGRPC:
+		spans, err = j.getTraceByGRPC(traceID, c)
+	default:
+		err = fmt.Errorf("unsupported jaeger protocol: %s", jaegerConf.Protocol)
+	}
+	if err != nil || spans == nil {
+		return nil, err
+	}
+	return j.jaegerSpansToExTrace(spans), nil
+}
+
+func (j *JaegerAdapter) getTraceByHTTP(traceID string, c *config.ExternalAPM) ([]*jaegerSpan, error) {
+	scheme := "http"
+	if c.TLS != nil {
+		scheme = "https"
+	}
+	result, err := common.DoRequest(http.MethodGet, fmt.Sprintf("%s://%s/%s/%s", scheme, c.Addr, jaeger_query_url, traceID), nil, common.DefaultContentTypeHeader(), c.Timeout, c.TLS)
+	if err != nil || result == nil {
+		log_jaeger.Errorf("query jaeger trace %s at %s failed! err: %s", traceID, c.Addr, err)
+		return nil, err
+	}
+	traces, err := common.Deserialize[jaegerTraceResponse](result)
+	if err != nil || traces == nil {
+		log_jaeger.Errorf("deserialize failed! err: %s", err)
+		return nil, err
+	}
+	if len(traces.Errors) > 0 {
+		return nil, fmt.Errorf("query jaeger trace %s failed: %s", traceID, traces.Errors[0].Msg)
+	}
+	spans := []*jaegerSpan{}
+	for _, trace := range traces.Data {
+		for _, span := range trace.Spans {
+			if span.Process == nil {
+				span.Process = trace.Processes[span.ProcessID]
+			}
+			spans = append(spans, span)
+		}
+	}
+	return spans, nil
+}
+
+func (j *JaegerAdapter) getTraceByGRPC(traceID string, c *config.ExternalAPM) ([]*jaegerSpan, error) {
+	traceIDBytes, err := jaegerTraceIDToBytes(traceID)
+	if err != nil {
+		return nil, err
+	}
+	creds := insecure.NewCredentials()
+	if c.TLS != nil {
+		tlsConfig, err := common.NewTLSClientConfig(c.TLS)
+		if err != nil {
+			return nil, err
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.GetTimeout())
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, c.Addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		log_jaeger.Errorf("dial jaeger query %s failed! err: %s", c.Addr, err)
+		return nil, err
+	}
+	defer conn.Close()
+
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, jaeger_grpc_get_trace)
+	if err != nil {
+		return nil, err
+	}
+	// GetTraceRequest only requires `string trace_id = 1`, which has the same encoding as StringValue
+	if err := stream.SendMsg(&wrapperspb.StringValue{Value: hex.EncodeToString(traceIDBytes)}); err != nil {
+		return nil, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+	spans := []*jaegerSpan{}
+	for {
+		// chunks are OTLP TracesData
+		chunk := &tracev1.TracesData{}
+		err := stream.RecvMsg(chunk)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log_jaeger.Errorf("query jaeger trace %s at %s failed! err: %s", traceID, c.Addr, err)
+			return nil, err
+		}
+		spans = append(spans, otlpResourceSpansToJaegerSpans(chunk.ResourceSpans)...)
+	}
+	return spans, nil
+}
+
+func (j *JaegerAdapter) jaegerSpansToExTrace(spans []*jaegerSpan) *model.ExTrace {
+	exTrace := &model.ExTrace{}
+	exTrace.Spans = make([]model.ExSpan, 0, len(spans))
+	for i, jaegerSpan := range spans {
+		if jaegerSpan == nil {
+			continue
+		}
+		attributes := jaegerTagsToAttributes(jaegerSpan.Tags)
+		spanKind := attributes[JaegerTagSpanKind]
+		span := model.ExSpan{
+			Name:            jaegerSpan.OperationName,
+			ID:              spanIDToUniqueID(jaegerSpan.SpanID, jaegerSpan.StartTime, i),
+			StartTimeUs:     jaegerSpan.StartTime,
+			EndTimeUs:       jaegerSpan.StartTime + jaegerSpan.Duration,
+			TapSide:         spanKindToTapSide(spanKind),
+			TraceID:         jaegerSpan.TraceID,
+			SpanID:          jaegerSpan.SpanID,
+			ParentSpanID:    j.jaegerRefsToParentSpanID(jaegerSpan.References),
+			SpanKind:        spanKindToOTelSpanKind(spanKind),
+			Endpoint:        jaegerSpan.OperationName,
+			RequestResource: jaegerSpan.OperationName, // maybe overwrite by tags
+			SignalSource:    model.L7_FLOW_SIGNAL_SOURCE_OTEL,
+			Attribute:       attributes,
+		}
+		if jaegerSpan.Process != nil {
+			span.AppService = jaegerSpan.Process.ServiceName
+			span.ServiceUname = jaegerSpan.Process.ServiceName
+			for _, tag := range jaegerSpan.Process.Tags {
+				if tag.Key == JaegerTagHostname {
+					span.AppInstance = jaegerValueToString(tag.Value)
+				}
+			}
+		}
+		attributesToSpanRequestInfo(attributes, &span)
+		exTrace.Spans = append(exTrace.Spans, span)
+	}
+	return exTrace
+}
+
+func (j *JaegerAdapter) jaegerRefsToParentSpanID(refs []jaegerReference) string {
+	// in opentracing, a span only have ONE parent now, prefer CHILD_OF reference
+	for _, ref := range refs {
+		if ref.RefType == JaegerRefTypeChildOf {
+			return ref.SpanID
+		}
+	}
+	if len(refs) > 0 {
+		return refs[0].SpanID
+	}
+	return ""
+}
+
+func jaegerTagsToAttributes(tags []jaegerKeyValue) map[string]string {
+	attr := make(map[string]string, len(tags))
+	for _, v := range tags {
+		attr[v.Key] = jaegerValueToString(v.Value)
+	}
+	return attr
+}
+
+func jaegerValueToString(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		// numbers are decoded as float64 from json
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// jaegerTraceIDToBytes converts hex trace id to 16 bytes trace id (high 64 bits + low 64 bits) in jaeger model
+func jaegerTraceIDToBytes(traceID string) ([]byte, error) {
+	if len(traceID) > 32 {
+		return nil, fmt.Errorf("invalid jaeger trace id: %s", traceID)
+	}
+	traceIDBytes, err := hex.DecodeString(strings.Repeat("0", 32-len(traceID)) + traceID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jaeger trace id: %s", traceID)
+	}
+	return traceIDBytes, nil
+}
+
+// jaegerTraceIDToString formats trace id the same as jaeger model.TraceID.String()
+func jaegerTraceIDToString(traceID []byte) string {
+	if len(traceID) != 16 {
+		return hex.EncodeToString(traceID)
+	}
+	if strings.Trim(hex.EncodeToString(traceID[:8]), "0") == "" {
+		return hex.EncodeToString(traceID[8:])
+	}
+	return hex.EncodeToString(traceID)
+}
+
+// otlpResourceSpansToJaegerSpans converts spans of jaeger api_v3 to the model of jaeger http api,
+// reverse of the translation jaeger-query does: https://github.com/open-telemetry/opentelemetry-collector-contrib/tree/main/pkg/translator/jaeger
+func otlpResourceSpansToJaegerSpans(resourceSpans []*tracev1.ResourceSpans) []*jaegerSpan {
+	spans := []*jaegerSpan{}
+	for _, rs := range resourceSpans {
+		process := &jaegerProcess{}
+		for _, attr := range rs.GetResource().GetAttributes() {
+			switch attr.Key {
+			case JaegerAttributeServiceName:
+				process.ServiceName = attr.Value.GetStringValue()
+			case JaegerAttributeHostName:
+				process.Tags = append(process.Tags, jaegerKeyValue{Key: JaegerTagHostname, Value: attr.Value.GetStringValue()})
+			default:
+				process.Tags = append(process.Tags, otlpKeyValueToJaeger(attr))
+			}
+		}
+		for _, ss := range rs.ScopeSpans {
+			for _, s := range ss.Spans {
+				span := &jaegerSpan{
+					TraceID:       jaegerTraceIDToString(s.TraceId),
+					SpanID:        hex.EncodeToString(s.SpanId),
+					OperationName: s.Name,
+					StartTime:     int64(s.StartTimeUnixNano / 1e3),
+					Duration:      int64(s.EndTimeUnixNano/1e3) - int64(s.StartTimeUnixNano/1e3),
+					Process:       process,
+				}
+				if len(s.ParentSpanId) > 0 {
+					span.References = append(span.References, jaegerReference{
+						RefType: JaegerRefTypeChildOf,
+						TraceID: span.TraceID,
+						SpanID:  hex.EncodeToString(s.ParentSpanId),
+					})
+				}
+				for _, link := range s.Links {
+					span.References = append(span.References, jaegerReference{
+						RefType: JaegerRefTypeFollowsFrom,
+						TraceID: jaegerTraceIDToString(link.TraceId),
+						SpanID:  hex.EncodeToString(link.SpanId),
+					})
+				}
+				if kind, ok := otlpSpanKindToJaeger[s.Kind]; ok {
+					span.Tags = append(span.Tags, jaegerKeyValue{Key: JaegerTagSpanKind, Value: kind})
+				}
+				for _, attr := range s.Attributes {
+					span.Tags = append(span.Tags, otlpKeyValueToJaeger(attr))
+				}
+				if s.Status.GetCode() == tracev1.Status_STATUS_CODE_ERROR {
+					span.Tags = append(span.Tags, jaegerKeyValue{Key: JaegerTagError, Value: true})
+				}
+				spans = append(spans, span)
+			}
+		}
+	}
+	return spans
+}
+
+var otlpSpanKindToJaeger = map[tracev1.Span_SpanKind]string{
+	tracev1.Span_SPAN_KIND_SERVER:   SpanKindServer,
+	tracev1.Span_SPAN_KIND_CLIENT:   SpanKindClient,
+	tracev1.Span_SPAN_KIND_PRODUCER: SpanKindProducer,
+	tracev1.Span_SPAN_KIND_CONSUMER: SpanKindConsumer,
+	tracev1.Span_SPAN_KIND_INTERNAL: SpanKindInternal,
+}
+
+func otlpKeyValueToJaeger(kv *commonv1.KeyValue) jaegerKeyValue {
+	result := jaegerKeyValue{Key: kv.Key}
+	switch v := kv.Value.GetValue().(type) {
+	case *commonv1.AnyValue_StringValue:
+		result.Value = v.StringValue
+	case *commonv1.AnyValue_BoolValue:
+		result.Value = v.BoolValue
+	case *commonv1.AnyValue_IntValue:
+		result.Value = v.IntValue
+	case *commonv1.AnyValue_DoubleValue:
+		result.Value = v.DoubleValue
+	case *commonv1.AnyValue_BytesValue:
+		result.Value = hex.EncodeToString(v.BytesValue)
+	case nil:
+	default:
+		// arrays and maps are kept as json, the same as jaeger does
+		if data, err := protojson.Marshal(kv.Value); err == nil {
+			result.Value = string(data)
+		}
+	}
+	return result
+}
@@ -0,0 +1,255 @@
+package service
+
+import (
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/config"
+	. "github.com/smartystreets/goconvey/convey"
+	commonv1 "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcev1 "go.opentelemetry.io/proto/otlp/resource/v1"
+	v1 "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// recorded from jaeger-query `/api/traces/{traceID}` of hotrod example
+var jaeger_mock_data = `{
+    "data": [
+        {
+            "traceID": "3f6ffd7bbbc0dc0b",
+            "spans": [
+                {
+                    "traceID": "3f6ffd7bbbc0dc0b",
+                    "spanID": "3f6ffd7bbbc0dc0b",
+                    "operationName": "HTTP GET /dispatch",
+                    "references": [],
+                    "startTime": 1694428678774000,
+                    "duration": 701234,
+                    "tags": [
+                        {"key": "span.kind", "type": "string", "value": "server"},
+                        {"key": "http.method", "type": "string", "value": "GET"},
+                        {"key": "http.url", "type": "string", "value": "/dispatch?customer=123"},
+                        {"key": "http.status_code", "type": "int64", "value": 200},
+                        {"key": "sampler.param", "type": "bool", "value": true}
+                    ],
+                    "logs": [],
+                    "processID": "p1",
+                    "warnings": null
+                },
+                {
+                    "traceID": "3f6ffd7bbbc0dc0b",
+                    "spanID": "18a3b3f0f8a5c6d1",
+                    "operationName": "SQL SELECT",
+                    "references": [
+                        {"refType": "CHILD_OF", "traceID": "3f6ffd7bbbc0dc0b", "spanID": "3f6ffd7bbbc0dc0b"}
+                    ],
+                    "startTime": 1694428678780000,
+                    "duration": 312345,
+                    "tags": [
+                        {"key": "span.kind", "type": "string", "value": "client"},
+                        {"key": "db.statement", "type": "string", "value": "SELECT * FROM customer WHERE customer_id=123"},
+                        {"key": "peer.service", "type": "string", "value": "mysql"}
+                    ],
+                    "logs": [],
+                    "processID": "p2",
+                    "warnings": null
+                }
+            ],
+            "processes": {
+                "p1": {
+                    "serviceName": "frontend",
+                    "tags": [{"key": "hostname", "type": "string", "value": "frontend-7d9c8b-x2x"}]
+                },
+                "p2": {
+                    "serviceName": "customer",
+                    "tags": [{"key": "hostname", "type": "string", "value": "customer-5b8f6c-k8s"}]
+                }
+            },
+            "warnings": null
+        }
+    ],
+    "total": 0,
+    "limit": 0,
+    "offset": 0,
+    "errors": null
+}`
+
+var jaeger_mock_not_found = `{"data":null,"total":0,"limit":0,"offset":0,"errors":[{"code":404,"msg":"trace not found"}]}`
+
+func newHTTPTestServer(path string, body string) (*httptest.Server, *config.ExternalAPM) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	return server, &config.ExternalAPM{Addr: strings.TrimPrefix(server.URL, "http://"), Timeout: 5 * time.Second}
+}
+
+func TestGetJaegerTraceByHTTP(t *testing.T) {
+	jaegerAdapter := &JaegerAdapter{}
+	Convey("TestGetJaegerTraceByHTTP_Success", t, func() {
+		server, c := newHTTPTestServer("/api/traces/3f6ffd7bbbc0dc0b", jaeger_mock_data)
+		defer server.Close()
+		result, err := jaegerAdapter.GetTrace("3f6ffd7bbbc0dc0b", c)
+		So(err, ShouldBeNil)
+		So(len(result.Spans), ShouldEqual, 2)
+
+		root := result.Spans[0]
+		So(root.ID, ShouldEqual, 0x3f6ffd7bbbc0dc0b)
+		So(root.Name, ShouldEqual, "HTTP GET /dispatch")
+		So(root.TraceID, ShouldEqual, "3f6ffd7bbbc0dc0b")
+		So(root.ParentSpanID, ShouldEqual, "")
+		So(root.StartTimeUs, ShouldEqual, 1694428678774000)
+		So(root.EndTimeUs, ShouldEqual, 1694428678774000+701234)
+		So(root.TapSide, ShouldEqual, "s-app")
+		So(root.SpanKind, ShouldEqual, int(v1.Span_SPAN_KIND_SERVER))
+		So(root.AppService, ShouldEqual, "frontend")
+		So(root.AppInstance, ShouldEqual, "frontend-7d9c8b-x2x")
+		So(root.L7ProtocolStr, ShouldEqual, "HTTP")
+		So(root.RequestType, ShouldEqual, "GET")
+		So(root.RequestResource, ShouldEqual, "/dispatch?customer=123")
+		So(root.ResponseStatus, ShouldEqual, 200)
+		So(root.Attribute["sampler.param"], ShouldEqual, "true")
+
+		child := result.Spans[1]
+		So(child.ParentSpanID, ShouldEqual, "3f6ffd7bbbc0dc0b")
+		So(child.TapSide, ShouldEqual, "c-app")
+		So(child.AppService, ShouldEqual, "customer")
+		So(child.RequestResource, ShouldEqual, "SELECT * FROM customer WHERE customer_id=123")
+		So(child.Attribute["peer.service"], ShouldEqual, "mysql")
+	})
+
+	Convey("TestGetJaegerTraceByHTTP_NotFound", t, func() {
+		server, c := newHTTPTestServer("/api/traces/3f6ffd7bbbc0dc0b", jaeger_mock_not_found)
+		defer server.Close()
+		result, err := jaegerAdapter.GetTrace("3f6ffd7bbbc0dc0b", c)
+		So(err, ShouldNotBeNil)
+		So(result, ShouldBeNil)
+	})
+}
+
+func otlpStringAttribute(key, value string) *commonv1.KeyValue {
+	return &commonv1.KeyValue{Key: key, Value: &commonv1.AnyValue{Value: &commonv1.AnyValue_StringValue{StringValue: value}}}
+}
+
+// jaegerMockTracesData is the api_v3 response with the same content as jaeger_mock_data
+func jaegerMockTracesData() *v1.TracesData {
+	traceID, _ := hex.DecodeString("00000000000000003f6ffd7bbbc0dc0b")
+	rootSpanID, _ := hex.DecodeString("3f6ffd7bbbc0dc0b")
+	childSpanID, _ := hex.DecodeString("18a3b3f0f8a5c6d1")
+	root := &v1.Span{
+		TraceId:           traceID,
+		SpanId:            rootSpanID,
+		Name:              "HTTP GET /dispatch",
+		Kind:              v1.Span_SPAN_KIND_SERVER,
+		StartTimeUnixNano: 1694428678774000 * 1e3,
+		EndTimeUnixNano:   (1694428678774000 + 701234) * 1e3,
+		Attributes: []*commonv1.KeyValue{
+			otlpStringAttribute("http.method", "GET"),
+			otlpStringAttribute("http.url", "/dispatch?customer=123"),
+			{Key: "http.status_code", Value: &commonv1.AnyValue{Value: &commonv1.AnyValue_IntValue{IntValue: 200}}},
+			{Key: "sampler.ratio", Value: &commonv1.AnyValue{Value: &commonv1.AnyValue_DoubleValue{DoubleValue: 0.5}}},
+		},
+	}
+	child := &v1.Span{
+		TraceId:           traceID,
+		SpanId:            childSpanID,
+		ParentSpanId:      rootSpanID,
+		Name:              "SQL SELECT",
+		Kind:              v1.Span_SPAN_KIND_CLIENT,
+		StartTimeUnixNano: 1694428678780000 * 1e3,
+		EndTimeUnixNano:   (1694428678780000 + 312345) * 1e3,
+		Attributes: []*commonv1.KeyValue{
+			otlpStringAttribute("db.statement", "SELECT * FROM customer WHERE customer_id=123"),
+		},
+		Status: &v1.Status{Code: v1.Status_STATUS_CODE_ERROR},
+	}
+	resourceSpans := func(service, hostname string, span *v1.Span) *v1.ResourceSpans {
+		return &v1.ResourceSpans{
+			Resource: &resourcev1.Resource{Attributes: []*commonv1.KeyValue{
+				otlpStringAttribute("service.name", service),
+				otlpStringAttribute("host.name", hostname),
+			}},
+			ScopeSpans: []*v1.ScopeSpans{{Spans: []*v1.Span{span}}},
+		}
+	}
+	return &v1.TracesData{ResourceSpans: []*v1.ResourceSpans{
+		resourceSpans("frontend", "frontend-7d9c8b-x2x", root),
+		resourceSpans("customer", "customer-5b8f6c-k8s", child),
+	}}
+}
+
+func newJaegerGRPCTestServer(t *testing.T) (*grpc.Server, *config.ExternalAPM) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer(
+		grpc.UnknownServiceHandler(func(srv any, stream grpc.ServerStream) error {
+			method, _ := grpc.MethodFromServerStream(stream)
+			if method != jaeger_grpc_get_trace {
+				t.Errorf("unexpected grpc method: %s", method)
+			}
+			request := &wrapperspb.StringValue{}
+			if err := stream.RecvMsg(request); err != nil {
+				return err
+			}
+			if request.Value != "00000000000000003f6ffd7bbbc0dc0b" {
+				t.Errorf("unexpected trace id: %s", request.Value)
+			}
+			return stream.SendMsg(jaegerMockTracesData())
+		}),
+	)
+	go server.Serve(listener)
+	return server, &config.ExternalAPM{
+		Addr:        listener.Addr().String(),
+		Timeout:     5 * time.Second,
+		ExtraConfig: map[string]string{"protocol": JaegerProtocolGRPC},
+	}
+}
+
+func TestGetJaegerTraceByGRPC(t *testing.T) {
+	jaegerAdapter := &JaegerAdapter{}
+	Convey("TestGetJaegerTraceByGRPC_Success", t, func() {
+		server, c := newJaegerGRPCTestServer(t)
+		defer server.Stop()
+		result, err := jaegerAdapter.GetTrace("3f6ffd7bbbc0dc0b", c)
+		So(err, ShouldBeNil)
+		So(len(result.Spans), ShouldEqual, 2)
+
+		root := result.Spans[0]
+		So(root.TraceID, ShouldEqual, "3f6ffd7bbbc0dc0b")
+		So(root.SpanID, ShouldEqual, "3f6ffd7bbbc0dc0b")
+		So(root.StartTimeUs, ShouldEqual, 1694428678774000)
+		So(root.EndTimeUs, ShouldEqual, 1694428678774000+701234)
+		So(root.AppService, ShouldEqual, "frontend")
+		So(root.AppInstance, ShouldEqual, "frontend-7d9c8b-x2x")
+		So(root.ResponseStatus, ShouldEqual, 200)
+		So(root.Attribute["sampler.ratio"], ShouldEqual, "0.5")
+
+		child := result.Spans[1]
+		So(child.SpanID, ShouldEqual, "18a3b3f0f8a5c6d1")
+		So(child.ParentSpanID, ShouldEqual, "3f6ffd7bbbc0dc0b")
+		So(child.SpanKind, ShouldEqual, int(v1.Span_SPAN_KIND_CLIENT))
+		So(child.RequestResource, ShouldEqual, "SELECT * FROM customer WHERE customer_id=123")
+		So(child.AppInstance, ShouldEqual, "customer-5b8f6c-k8s")
+		So(child.Attribute["error"], ShouldEqual, "true")
+	})
+
+	Convey("TestGetJaegerTraceByGRPC_InvalidTraceID", t, func() {
+		result, err := jaegerAdapter.GetTrace("not-a-hex-id", &config.ExternalAPM{
+			Timeout:     time.Second,
+			ExtraConfig: map[string]string{"protocol": JaegerProtocolGRPC},
+		})
+		So(err, ShouldNotBeNil)
+		So(result, ShouldBeNil)
+	})
+}
@@ -0,0 +1,143 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/common"
+	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/config"
+	"github.com/deepflowio/deepflow/server/querier/app/tracing-adapter/model"
+	"github.com/mitchellh/mapstructure"
+	"github.com/op/go-logging"
+)
+
+const (
+	// zipkin api v2: https://zipkin.io/zipkin-api/#/default/get_trace__traceId_
+	zipkin_query_url = "api/v2/trace"
+)
+
+type zipkinConfig struct {
+	Auth string `mapstructure:"auth"` // basic auth
+}
+
+type zipkinSpan struct {
+	TraceID        string            `json:"traceId"`
+	ID             string            `json:"id"`
+	ParentID       string            `json:"parentId"`
+	Name           string            `json:"name"`
+	Kind           string            `json:"kind"`      // CLIENT, SERVER, PRODUCER, CONSUMER
+	Timestamp      int64             `json:"timestamp"` // microseconds
+	Duration       int64             `json:"duration"`  // microseconds
+	LocalEndpoint  *zipkinEndpoint   `json:"localEndpoint"`
+	RemoteEndpoint *zipkinEndpoint   `json:"remoteEndpoint"`
+	Tags           map[string]string `json:"tags"`
+}
+
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName"`
+	IPv4        string `json:"ipv4"`
+	IPv6        string `json:"ipv6"`
+	Port        int    `json:"port"`
+}
+
+type ZipkinAdapter struct {
+}
+
+var log_zipkin = logging.MustGetLogger("tracing-adapter.zipkin")
+
+func (z *ZipkinAdapter) GetTrace(traceID string, c *config.ExternalAPM) (*model.ExTrace, error) {
+	zipkinConf := &zipkinConfig{}
+	err := mapstructure.Decode(c.ExtraConfig, zipkinConf)
+	if err != nil {
+		log_zipkin.Errorf("cannot decode zipkin extra config %v, err: %s", c.ExtraConfig, err)
+		return nil, err
+	}
+	spans, err := z.getTrace(traceID, c, zipkinConf)
+	if err != nil || spans == nil {
+		return nil, err
+	}
+	return z.zipkinSpansToExTrace(spans), nil
+}
+
+func (z *ZipkinAdapter) appendAuthHeader(auth string) map[string]string {
+	header := common.DefaultContentTypeHeader()
+	if auth == "" {
+		return header
+	}
+	header["Authorization"] = fmt.Sprintf("Basic %s", auth)
+	return header
+}
+
+func (z *ZipkinAdapter) getTrace(traceID string, c *config.ExternalAPM, zipkinConf *zipkinConfig) ([]*zipkinSpan, error) {
+	scheme := "http"
+	if c.TLS != nil {
+		scheme = "https"
+	}
+	result, err := common.DoRequest(http.MethodGet, fmt.Sprintf("%s://%s/%s/%s", scheme, c.Addr, zipkin_query_url, traceID), nil, z.appendAuthHeader(zipkinConf.Auth), c.Timeout, c.TLS)
+	if err != nil || result == nil {
+		log_zipkin.Errorf("query zipkin trace %s at %s failed! err: %s", traceID, c.Addr, err)
+		return nil, err
+	}
+	spans, err := common.Deserialize[[]*zipkinSpan](result)
+	if err != nil || spans == nil {
+		log_zipkin.Errorf("deserialize failed! err: %s", err)
+		return nil, err
+	}
+	return *spans, nil
+}
+
+func (z *ZipkinAdapter) zipkinSpansToExTrace(spans []*zipkinSpan) *model.ExTrace {
+	exTrace := &model.ExTrace{}
+	exTrace.Spans = make([]model.ExSpan, 0, len(spans))
+	for i, zipkinSpan := range spans {
+		if zipkinSpan == nil {
+			continue
+		}
+		attributes := zipkinSpan.Tags
+		if attributes == nil {
+			attributes = map[string]string{}
+		}
+		span := model.ExSpan{
+			Name:            zipkinSpan.Name,
+			ID:              spanIDToUniqueID(zipkinSpan.ID, zipkinSpan.Timestamp, i),
+			StartTimeUs:     zipkinSpan.Timestamp,
+			EndTimeUs:       zipkinSpan.Timestamp + zipkinSpan.Duration,
+			TapSide:         spanKindToTapSide(zipkinSpan.Kind),
+			TraceID:         zipkinSpan.TraceID,
+			SpanID:          zipkinSpan.ID,
+			ParentSpanID:    zipkinSpan.ParentID,
+			SpanKind:        spanKindToOTelSpanKind(zipkinSpan.Kind),
+			Endpoint:        zipkinSpan.Name,
+			RequestResource: zipkinSpan.Name, // maybe overwrite by tags
+			SignalSource:    model.L7_FLOW_SIGNAL_SOURCE_OTEL,
+			Attribute:       attributes,
+		}
+		if zipkinSpan.LocalEndpoint != nil {
+			span.AppService = zipkinSpan.LocalEndpoint.ServiceName
+			span.ServiceUname = zipkinSpan.LocalEndpoint.ServiceName
+			span.AppInstance = zipkinSpan.LocalEndpoint.IPv4
+			if span.AppInstance == "" {
+				span.AppInstance = zipkinSpan.LocalEndpoint.IPv6
+			}
+		}
+		attributesToSpanRequestInfo(attributes, &span)
+		exTrace.Spans = append(exTrace.Spans, span)
+	}
+	return exTrace
+}
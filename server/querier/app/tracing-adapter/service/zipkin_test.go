@@ -0,0 +1,95 @@
+package service
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	v1 "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// recorded from zipkin `/api/v2/trace/{traceId}`
+var zipkin_mock_data = `[
+    {
+        "traceId": "5af7183fb1d4cf5f6b3a0b7e64f3c1a2",
+        "id": "6b221d5bc9e6496c",
+        "kind": "SERVER",
+        "name": "get /api",
+        "timestamp": 1694428678774000,
+        "duration": 207000,
+        "localEndpoint": {"serviceName": "backend", "ipv4": "192.168.99.101", "port": 9000},
+        "remoteEndpoint": {"ipv4": "172.19.0.2", "port": 58648},
+        "tags": {
+            "http.method": "GET",
+            "http.path": "/api",
+            "http.status_code": "200"
+        }
+    },
+    {
+        "traceId": "5af7183fb1d4cf5f6b3a0b7e64f3c1a2",
+        "parentId": "6b221d5bc9e6496c",
+        "id": "5b4185666d50f68b",
+        "kind": "CLIENT",
+        "name": "query",
+        "timestamp": 1694428678800000,
+        "duration": 50000,
+        "localEndpoint": {"serviceName": "backend", "ipv4": "192.168.99.101"},
+        "remoteEndpoint": {"serviceName": "mysql", "ipv4": "172.19.0.3", "port": 3306},
+        "tags": {
+            "db.statement": "SELECT 1"
+        }
+    },
+    {
+        "traceId": "5af7183fb1d4cf5f6b3a0b7e64f3c1a2",
+        "parentId": "6b221d5bc9e6496c",
+        "id": "0000000000000000",
+        "name": "local",
+        "timestamp": 1694428678900000,
+        "duration": 1000,
+        "localEndpoint": {"serviceName": "backend"}
+    }
+]`
+
+func TestGetZipkinTrace(t *testing.T) {
+	zipkinAdapter := &ZipkinAdapter{}
+	Convey("TestGetZipkinTrace_Success", t, func() {
+		server, c := newHTTPTestServer("/api/v2/trace/5af7183fb1d4cf5f6b3a0b7e64f3c1a2", zipkin_mock_data)
+		defer server.Close()
+		result, err := zipkinAdapter.GetTrace("5af7183fb1d4cf5f6b3a0b7e64f3c1a2", c)
+		So(err, ShouldBeNil)
+		So(len(result.Spans), ShouldEqual, 3)
+
+		serverSpan := result.Spans[0]
+		So(serverSpan.ID, ShouldEqual, 0x6b221d5bc9e6496c)
+		So(serverSpan.TraceID, ShouldEqual, "5af7183fb1d4cf5f6b3a0b7e64f3c1a2")
+		So(serverSpan.SpanID, ShouldEqual, "6b221d5bc9e6496c")
+		So(serverSpan.ParentSpanID, ShouldEqual, "")
+		So(serverSpan.EndTimeUs, ShouldEqual, 1694428678774000+207000)
+		So(serverSpan.TapSide, ShouldEqual, "s-app")
+		So(serverSpan.SpanKind, ShouldEqual, int(v1.Span_SPAN_KIND_SERVER))
+		So(serverSpan.AppService, ShouldEqual, "backend")
+		So(serverSpan.AppInstance, ShouldEqual, "192.168.99.101")
+		So(serverSpan.L7ProtocolStr, ShouldEqual, "HTTP")
+		So(serverSpan.RequestType, ShouldEqual, "GET")
+		So(serverSpan.RequestResource, ShouldEqual, "/api")
+		So(serverSpan.ResponseStatus, ShouldEqual, 200)
+
+		client := result.Spans[1]
+		So(client.ParentSpanID, ShouldEqual, "6b221d5bc9e6496c")
+		So(client.TapSide, ShouldEqual, "c-app")
+		So(client.RequestResource, ShouldEqual, "SELECT 1")
+
+		local := result.Spans[2]
+		So(local.TapSide, ShouldEqual, "app")
+		So(local.ID, ShouldBeGreaterThan, 0)
+		So(local.ID, ShouldNotEqual, serverSpan.ID)
+		So(local.Attribute, ShouldNotBeNil)
+	})
+
+	Convey("TestGetZipkinTrace_NotFound", t, func() {
+		server, c := newHTTPTestServer("/api/v2/trace/5af7183fb1d4cf5f6b3a0b7e64f3c1a2", zipkin_mock_data)
+		defer server.Close()
+		result, err := zipkinAdapter.GetTrace("0000000000000001", c)
+		So(err, ShouldNotBeNil)
+		So(result, ShouldBeNil)
+	})
+}
@@ -0,0 +1,214 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cache
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/deepflowio/deepflow/server/querier/common"
+)
+
+const (
+	QUERY_CACHE_STATUS_HIT    = "hit"
+	QUERY_CACHE_STATUS_MISS   = "miss"
+	QUERY_CACHE_STATUS_SHARED = "shared" // coalesced with a concurrent identical query
+)
+
+var (
+	// time conditions generated by grafana datasource, e.g.: `time>=1700000000 AND time<=1700003600`
+	timeConditionRegexp = regexp.MustCompile(`(?i)\b(time)\s*(>=|<=|>|<|=)\s*(\d{10})\b`)
+	whitespaceRegexp    = regexp.MustCompile(`\s+`)
+)
+
+type QueryResult struct {
+	Result map[string]interface{}
+	Debug  map[string]interface{}
+}
+
+type queryCacheItem struct {
+	key     string
+	result  *QueryResult
+	size    int
+	expires time.Time
+}
+
+// QueryCache caches results of `/v1/query` in memory, entries are evicted by ttl and
+// least recently used when total size exceeds maxMemory
+type QueryCache struct {
+	ttl         time.Duration
+	maxMemory   int
+	maxItemSize int
+
+	lock    sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	lruList *list.List
+
+	group singleflight.Group
+}
+
+func NewQueryCache(ttl time.Duration, maxMemory, maxItemSize int) *QueryCache {
+	return &QueryCache{
+		ttl:         ttl,
+		maxMemory:   maxMemory,
+		maxItemSize: maxItemSize,
+		entries:     make(map[string]*list.Element),
+		lruList:     list.New(),
+	}
+}
+
+// QueryCacheKey generates cache key of a query, sql is normalized and unix timestamps in time conditions
+// are aligned to align, so that dashboards refreshing continuously could share results in the same window.
+// All parameters which change the result are part of the key.
+func QueryCacheKey(args *common.QuerierParams, align time.Duration) string {
+	sql := strings.TrimSuffix(strings.TrimSpace(whitespaceRegexp.ReplaceAllString(args.Sql, " ")), ";")
+	if alignSeconds := int64(align.Seconds()); alignSeconds > 0 {
+		sql = timeConditionRegexp.ReplaceAllStringFunc(sql, func(cond string) string {
+			match := timeConditionRegexp.FindStringSubmatch(cond)
+			timestamp, err := strconv.ParseInt(match[3], 10, 64)
+			if err != nil {
+				return cond
+			}
+			return match[1] + match[2] + strconv.FormatInt(timestamp-timestamp%alignSeconds, 10)
+		})
+	}
+	return fmt.Sprintf("%s|%s|%s|%s|%t|%t|%s|%t|%s", args.ORGID, args.DB, args.DataSource, args.Debug,
+		args.NoPreWhere, args.UseQueryCache, args.QueryCacheTTL, args.SimpleSql, sql)
+}
+
+// Copy returns a copy of the result, maps and slices decoded from json are copied recursively so
+// that callers can modify their own results without affecting the cached one
+func (r *QueryResult) Copy() *QueryResult {
+	if r == nil {
+		return nil
+	}
+	result, _ := copyJSONValue(r.Result).(map[string]interface{})
+	debug, _ := copyJSONValue(r.Debug).(map[string]interface{})
+	return &QueryResult{Result: result, Debug: debug}
+}
+
+func copyJSONValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		if value == nil {
+			return value
+		}
+		m := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			m[k] = copyJSONValue(item)
+		}
+		return m
+	case []interface{}:
+		if value == nil {
+			return value
+		}
+		s := make([]interface{}, len(value))
+		for i, item := range value {
+			s[i] = copyJSONValue(item)
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+func (c *QueryCache) Get(key string) (*QueryResult, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*queryCacheItem)
+	if time.Now().After(entry.expires) {
+		c.removeElement(element)
+		return nil, false
+	}
+	c.lruList.MoveToFront(element)
+	return entry.result, true
+}
+
+// Add adds result to cache, results larger than maxItemSize are not cached
+func (c *QueryCache) Add(key string, result *QueryResult, size int) bool {
+	if c.ttl <= 0 || size > c.maxItemSize || size > c.maxMemory {
+		return false
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.removeElement(element)
+	}
+	for c.size+size > c.maxMemory {
+		c.removeElement(c.lruList.Back())
+	}
+	c.entries[key] = c.lruList.PushFront(&queryCacheItem{key: key, result: result, size: size, expires: time.Now().Add(c.ttl)})
+	c.size += size
+	return true
+}
+
+func (c *QueryCache) removeElement(element *list.Element) {
+	entry := c.lruList.Remove(element).(*queryCacheItem)
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+}
+
+// Size returns the count and total size of cached results
+func (c *QueryCache) Size() (int, int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.entries), c.size
+}
+
+// Do returns cached result of key if exists, otherwise executes query, concurrent calls with the
+// same key wait for the first one and share its result. Failed queries are not cached, but their
+// results (may be nil) are returned along with the error. Every caller gets its own copy of the result.
+func (c *QueryCache) Do(key string, query func() (*QueryResult, error)) (*QueryResult, string, error) {
+	if result, ok := c.Get(key); ok {
+		return result.Copy(), QUERY_CACHE_STATUS_HIT, nil
+	}
+	executed := false
+	v, err, _ := c.group.Do(key, func() (interface{}, error) {
+		executed = true
+		// the result may be added by another group which has just finished
+		if result, ok := c.Get(key); ok {
+			return result, nil
+		}
+		result, err := query()
+		if err != nil {
+			return result, err
+		}
+		if data, err := json.Marshal(result.Result); err == nil {
+			c.Add(key, result, len(data))
+		}
+		return result, nil
+	})
+	status := QUERY_CACHE_STATUS_MISS
+	if !executed {
+		status = QUERY_CACHE_STATUS_SHARED
+	}
+	result, _ := v.(*QueryResult)
+	return result.Copy(), status, err
+}
@@ -0,0 +1,169 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/deepflowio/deepflow/server/querier/common"
+)
+
+func TestQueryCacheKey(t *testing.T) {
+	align := 30 * time.Second
+	params := func(orgID, db, sql string) *common.QuerierParams {
+		return &common.QuerierParams{ORGID: orgID, DB: db, DataSource: "1m", Sql: sql}
+	}
+	base := QueryCacheKey(params("1", "flow_metrics", "SELECT Sum(byte) FROM network WHERE time>=1700000010 AND time<=1700003610"), align)
+	cases := []struct {
+		sql   string
+		equal bool
+	}{
+		// whitespaces and trailing semicolon are normalized
+		{"SELECT  Sum(byte)\n FROM network WHERE time>=1700000010 AND time<=1700003610;", true},
+		// timestamps in the same window
+		{"SELECT Sum(byte) FROM network WHERE time >= 1700000039 AND time <= 1700003639", true},
+		{"SELECT Sum(byte) FROM network WHERE time>=1700000040 AND time<=1700003640", false},
+		// string literals are kept
+		{"SELECT Sum(byte) FROM network WHERE time>=1700000010 AND time<=1700003610 AND pod='A'", false},
+		// other columns ending with time are not aligned
+		{"SELECT Sum(byte) FROM network WHERE start_time>=1700000010 AND time<=1700003610", false},
+	}
+	for _, c := range cases {
+		key := QueryCacheKey(params("1", "flow_metrics", c.sql), align)
+		if (key == base) != c.equal {
+			t.Errorf("QueryCacheKey(%s) = %s, base %s, want equal: %v", c.sql, key, base, c.equal)
+		}
+	}
+	if QueryCacheKey(params("2", "flow_metrics", "SELECT 1"), align) == QueryCacheKey(params("1", "flow_metrics", "SELECT 1"), align) {
+		t.Error("QueryCacheKey of different orgs should not be equal")
+	}
+	if QueryCacheKey(params("1", "flow_log", "SELECT 1"), align) == QueryCacheKey(params("1", "flow_metrics", "SELECT 1"), align) {
+		t.Error("QueryCacheKey of different dbs should not be equal")
+	}
+	// options which change the result
+	options := []func(p *common.QuerierParams){
+		func(p *common.QuerierParams) { p.Debug = "true" },
+		func(p *common.QuerierParams) { p.NoPreWhere = true },
+		func(p *common.QuerierParams) { p.UseQueryCache = true },
+		func(p *common.QuerierParams) { p.QueryCacheTTL = "60" },
+	}
+	baseKey := QueryCacheKey(params("1", "flow_metrics", "SELECT 1"), align)
+	for i, option := range options {
+		p := params("1", "flow_metrics", "SELECT 1")
+		option(p)
+		if QueryCacheKey(p, align) == baseKey {
+			t.Errorf("QueryCacheKey with option %d should not be equal", i)
+		}
+	}
+}
+
+func TestQueryCacheEviction(t *testing.T) {
+	c := NewQueryCache(time.Minute, 100, 50)
+	if c.Add("big", &QueryResult{}, 60) {
+		t.Error("result larger than max item size should not be cached")
+	}
+	c.Add("a", &QueryResult{}, 40)
+	c.Add("b", &QueryResult{}, 40)
+	// a is used recently, b will be evicted
+	c.Get("a")
+	c.Add("c", &QueryResult{}, 40)
+	if _, ok := c.Get("b"); ok {
+		t.Error("b should be evicted")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Error("a should be kept")
+	}
+	if count, size := c.Size(); count != 2 || size != 80 {
+		t.Errorf("Size() = %d, %d, want 2, 80", count, size)
+	}
+
+	c = NewQueryCache(time.Millisecond, 100, 50)
+	c.Add("a", &QueryResult{}, 10)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := c.Get("a"); ok {
+		t.Error("a should be expired")
+	}
+	if count, size := c.Size(); count != 0 || size != 0 {
+		t.Errorf("Size() = %d, %d, want 0, 0", count, size)
+	}
+}
+
+func TestQueryCacheDo(t *testing.T) {
+	c := NewQueryCache(time.Minute, 1<<20, 1<<20)
+	var executed int32
+	release := make(chan struct{})
+	query := func() (*QueryResult, error) {
+		atomic.AddInt32(&executed, 1)
+		<-release
+		return &QueryResult{Result: map[string]interface{}{"values": []int{1}}}, nil
+	}
+
+	// concurrent identical queries are coalesced
+	statuses := make(chan string, 10)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, status, err := c.Do("q", query)
+			if err != nil || result == nil {
+				t.Errorf("Do() = %v, %v", result, err)
+			}
+			statuses <- status
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(statuses)
+	count := map[string]int{}
+	for status := range statuses {
+		count[status]++
+	}
+	if executed != 1 || count[QUERY_CACHE_STATUS_MISS] != 1 || count[QUERY_CACHE_STATUS_SHARED] != 9 {
+		t.Errorf("executed %d times, statuses %v", executed, count)
+	}
+
+	// following queries hit the cache
+	if _, status, _ := c.Do("q", query); status != QUERY_CACHE_STATUS_HIT || executed != 1 {
+		t.Errorf("status %s, executed %d times", status, executed)
+	}
+
+	// results are copied, modifying one does not affect the cache
+	modified, _, _ := c.Do("q", query)
+	modified.Result["values"] = nil
+	modified.Result["columns"] = []interface{}{"byte"}
+	if cached, _, _ := c.Do("q", query); cached.Result["values"] == nil || cached.Result["columns"] != nil {
+		t.Errorf("cached result is modified: %v", cached.Result)
+	}
+
+	// failed queries are not cached
+	failed := func() (*QueryResult, error) {
+		return &QueryResult{Debug: map[string]interface{}{"sql": "SELECT 1"}}, errors.New("timeout")
+	}
+	result, status, err := c.Do("f", failed)
+	if err == nil || status != QUERY_CACHE_STATUS_MISS || result.Debug["sql"] != "SELECT 1" {
+		t.Errorf("Do() = %v, %s, %v", result, status, err)
+	}
+	if _, ok := c.Get("f"); ok {
+		t.Error("failed query should not be cached")
+	}
+}
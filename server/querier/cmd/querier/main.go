@@ -19,12 +19,15 @@ package main
 import (
 	"flag"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 
 	logging "github.com/op/go-logging"
 
 	"github.com/deepflowio/deepflow/server/common"
 	"github.com/deepflowio/deepflow/server/libs/logger"
+	"github.com/deepflowio/deepflow/server/libs/utils"
 	"github.com/deepflowio/deepflow/server/querier/querier"
 )
 
@@ -41,6 +44,13 @@ func main() {
 	if os.Getppid() != 1 {
 		logger.EnableStdoutLog()
 	}
+	ctx, cancel := utils.NewWaitGroupCtx()
 	shared := common.NewControllerIngesterShared()
-	querier.Start(*configPath, "", shared)
+	go querier.Start(ctx, *configPath, "", shared)
+
+	signalChannel := make(chan os.Signal, 1)
+	signal.Notify(signalChannel, syscall.SIGINT, syscall.SIGTERM)
+	<-signalChannel
+	cancel()
+	utils.GetWaitGroupInCtx(ctx).Wait()
 }
@@ -43,6 +43,7 @@ type TempoParams struct {
 	TagName     string
 	MinDuration string
 	MaxDuration string
+	Query       string
 	Limit       string
 	Debug       string
 	Filters     []*KeyValue
@@ -62,6 +62,8 @@ type QuerierConfig struct {
 	MaxPrometheusIdSubqueryLruEntry int                           `default:"8000" yaml:"max-prometheus-id-subquery-lru-entry"`
 	PrometheusIdSubqueryLruTimeout  int                           `default:"60" yaml:"prometheus-id-subquery-lru-timeout"`
 	AutoCustomTags                  []AutoCustomTags              `yaml:"auto-custom-tags" binding:"omitempty,dive"`
+	QueryCache                      QueryCache                    `yaml:"query-cache"`
+	Pcap                            Pcap                          `yaml:"pcap"`
 }
 
 type DeepflowApp struct {
@@ -97,6 +99,18 @@ type AutoCustomTags struct {
 	Description string   `default:"" yaml:"description"`
 }
 
+type QueryCache struct {
+	Enabled     bool `default:"false" yaml:"enabled"`
+	TTL         int  `default:"30" yaml:"ttl"`           // unit: s, time conditions in sql are aligned to ttl as well
+	MaxMemory   int  `default:"256" yaml:"max-memory"`   // unit: MB
+	MaxItemSize int  `default:"16" yaml:"max-item-size"` // unit: MB
+}
+
+type Pcap struct {
+	MaxFlowCount  int `default:"1000" yaml:"max-flow-count"`    // flows matched by l4_flow_log query condition
+	MaxBatchCount int `default:"100000" yaml:"max-batch-count"` // packet batches merged into one pcapng file
+}
+
 type ControllerConfig struct {
 	ListenPort int `default:"20417" yaml:"listen-port"`
 }
@@ -124,6 +138,16 @@ func (c *Config) Validate() error {
 	if c.TraceIdWithIndex.Type == "" {
 		c.TraceIdWithIndex.Type = "hash"
 	}
+	// tickers of rule manager panic on non-positive intervals
+	rules := &c.QuerierConfig.Prometheus.Rules
+	if rules.EvaluationInterval <= 0 {
+		log.Warningf("invalid prometheus rules evaluation-interval %d, use 60 instead", rules.EvaluationInterval)
+		rules.EvaluationInterval = 60
+	}
+	if rules.ReloadInterval <= 0 {
+		log.Warningf("invalid prometheus rules reload-interval %d, use 60 instead", rules.ReloadInterval)
+		rules.ReloadInterval = 60
+	}
 	return nil
 }
 
@@ -425,6 +425,11 @@ var (
 		input:  "select AAvg(`rrt`) AS `AAvg(rrt)`, AAvg(`error_ratio`) AS `AAvg(error_ratio)`, auto_service_id from vtap_app_port group by auto_service_id limit 1",
 		output: []string{"SELECT auto_service_id, AVGArray(arrayFilter(x -> x>0, `_grouparray_rrt_sum/rrt_count`)) AS `AAvg(rrt)`, AVG(`_div__sum_error__sum_response`)*100 AS `AAvg(error_ratio)` FROM (WITH if(SUM(response)>0, divide(SUM(error), SUM(response)), null) AS `divide_0diveider_as_null_sum_error_sum_response` SELECT if(auto_service_type in (0,255),subnet_id,auto_service_id) AS `auto_service_id`, groupArrayIf(rrt_sum/rrt_count, rrt_sum/rrt_count > 0) AS `_grouparray_rrt_sum/rrt_count`, `divide_0diveider_as_null_sum_error_sum_response` AS `_div__sum_error__sum_response` FROM flow_metrics.`application` GROUP BY if(auto_service_type in (0,255),subnet_id,auto_service_id) AS `auto_service_id`) GROUP BY `auto_service_id` LIMIT 1"},
 		db:     "flow_metrics",
+	}, {
+		name:   "sketch_percentile_vtap_app_port",
+		input:  "select Percentile(`rrt`, 0.99) AS `Percentile(rrt)`, auto_service_id from vtap_app_port group by auto_service_id limit 1",
+		output: []string{"SELECT if(auto_service_type in (0,255),subnet_id,auto_service_id) AS `auto_service_id`, if(arraySum(mapValues(sumMap(rrt_buckets)))>0, arrayElement(arrayMap(x -> if(x = 0, 0, pow(2, (x - 0.5) / 8)), mapKeys(sumMap(rrt_buckets))), arrayFirstIndex(c -> c >= 0.99 * arraySum(mapValues(sumMap(rrt_buckets))), arrayCumSum(mapValues(sumMap(rrt_buckets))))), quantileIf(0.99)(rrt_sum/rrt_count, rrt_sum/rrt_count > 0)) AS `Percentile(rrt)` FROM flow_metrics.`application` GROUP BY if(auto_service_type in (0,255),subnet_id,auto_service_id) AS `auto_service_id` LIMIT 1"},
+		db:     "flow_metrics",
 	}, {
 		name:   "division>=0_vtap_flow_edge_port",
 		input:  "select Avg(`bpp`) AS `Avg(bpp)`, Avg(`retrans_syn_ratio`) AS `Avg(retrans_syn_ratio)`, auto_service_id from vtap_flow_edge_port group by auto_service_id limit 1",
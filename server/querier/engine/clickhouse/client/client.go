@@ -28,6 +28,7 @@ import (
 	"unsafe"
 
 	clickhouse "github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
 	//"github.com/k0kubun/pp"
 
 	"github.com/deepflowio/deepflow/server/querier/common"
@@ -108,6 +109,26 @@ func (c *Client) Close() error {
 	return nil
 }
 
+// DoQueryRows executes sql and returns the rows to be read one by one without loading them into memory,
+// the caller must close the rows
+func (c *Client) DoQueryRows(sql string) (driver.Rows, error) {
+	if err := c.init(""); err != nil {
+		return nil, err
+	}
+	ctx := c.Context
+	if c.Context == nil {
+		ctx = context.Background()
+	}
+	rows, err := c.connection.Query(ctx, sql)
+	c.Debug.Sql = sql
+	if err != nil {
+		log.Errorf("query clickhouse Error: %s, sql: %s, query_uuid: %s", err, sql, c.Debug.QueryUUID)
+		c.Debug.Error = fmt.Sprintf("%s", err)
+		return nil, err
+	}
+	return rows, nil
+}
+
 func (c *Client) DoQuery(params *QueryParams) (result *common.Result, err error) {
 	sqlstr, callbacks, query_uuid, columnSchemaMap, simpleSql := params.Sql, params.Callbacks, params.QueryUUID, params.ColumnSchemaMap, params.SimpleSql
 	queryCacheStr := ""
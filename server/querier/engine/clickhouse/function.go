@@ -457,7 +457,42 @@ func (f *AggFunction) FormatInnerTag(m *view.Model) (innerAlias string) {
 	return ""
 }
 
+// Percentile of delay metrics with a latency sketch is calculated from the merged sketch,
+// instead of the quantile of per-row averages. The quantile of per-row averages is still used
+// when the sketch is empty, e.g. the data is written before agents fill the sketch
+func (f *AggFunction) transSketchPercentile(m *view.Model) view.Node {
+	outFunc := &view.SketchPercentileFunction{DefaultFunction: view.DefaultFunction{Name: view.FUNCTION_SKETCH_PCTL}}
+	if len(f.Args) > 1 {
+		outFunc.SetArgs(f.Args[1:])
+	}
+	field := f.Metrics.SketchField
+	if m.MetricsLevelFlag == view.MODEL_METRICS_LEVEL_FLAG_LAYERED {
+		// 内层按桶累加, 外层再次累加后计算分位数
+		innerFunction := view.DefaultFunction{
+			Name:   view.FUNCTION_SUM_MAP,
+			Fields: []view.Node{&view.Field{Value: field}},
+		}
+		field = innerFunction.SetAlias("", true)
+		innerFunction.SetFlag(view.METRICS_FLAG_INNER)
+		innerFunction.Init()
+		m.AddTag(&innerFunction)
+	}
+	outFunc.Fallback = f.trans(m).(view.Function)
+	outFunc.SetFields([]view.Node{&view.Field{Value: field}})
+	outFunc.SetFlag(view.METRICS_FLAG_OUTER)
+	outFunc.SetTime(m.Time)
+	outFunc.Init()
+	return outFunc
+}
+
 func (f *AggFunction) Trans(m *view.Model) view.Node {
+	if f.Name == view.FUNCTION_PCTL && f.Metrics.SketchField != "" {
+		return f.transSketchPercentile(m)
+	}
+	return f.trans(m)
+}
+
+func (f *AggFunction) trans(m *view.Model) view.Node {
 	var outFunc view.Function
 	if m.MetricsLevelFlag == view.MODEL_METRICS_LEVEL_FLAG_LAYERED && f.Name == view.FUNCTION_COUNT {
 		outFunc = &view.DefaultFunction{Name: view.FUNCTION_SUM}
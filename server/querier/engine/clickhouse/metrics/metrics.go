@@ -56,6 +56,7 @@ type Metrics struct {
 	Table       string // 所属表
 	Description string // 描述
 	TagType     string // Tag type of metric's tag type
+	SketchField string // 时延分布字段, 非空时 Percentile 基于该字段计算
 }
 
 func (m *Metrics) Replace(metrics *Metrics) {
@@ -66,6 +67,9 @@ func (m *Metrics) Replace(metrics *Metrics) {
 	if metrics.Condition != "" {
 		m.Condition = metrics.Condition
 	}
+	if metrics.SketchField != "" {
+		m.SketchField = metrics.SketchField
+	}
 }
 
 func (m *Metrics) SetIsAgg(isAgg bool) *Metrics {
@@ -73,6 +77,11 @@ func (m *Metrics) SetIsAgg(isAgg bool) *Metrics {
 	return m
 }
 
+func (m *Metrics) SetSketchField(sketchField string) *Metrics {
+	m.SketchField = sketchField
+	return m
+}
+
 func NewMetrics(
 	index int, dbField string, displayname string, unit string, metricType int, category string,
 	permissions []bool, condition string, table string, description string, tagType string,
@@ -55,8 +55,13 @@ const (
 	FUNCTION_ANY           = "Any"
 	FUNCTION_DERIVATIVE    = "nonNegativeDerivative"
 	FUNCTION_COUNTDISTINCT = "countDistinct"
+	FUNCTION_SKETCH_PCTL   = "Sketch_Percentile"
+	FUNCTION_SUM_MAP       = "sumMap"
 )
 
+// 与 libs/flow-metrics 中 LatencySketch 的分桶方式保持一致
+const SKETCH_SUB_BUCKETS = 8
+
 // 对外提供的算子与数据库实际算子转换
 var FUNC_NAME_MAP map[string]string = map[string]string{
 	FUNCTION_SUM:         "SUM",
@@ -109,6 +114,8 @@ func GetFunc(name string) Function {
 		return &DelayAvgFunction{DefaultFunction: DefaultFunction{Name: FUNC_NAME_MAP[FUNCTION_AAVG]}}
 	case FUNCTION_DERIVATIVE:
 		return &NonNegativeDerivativeFunction{DefaultFunction: DefaultFunction{Name: name}}
+	case FUNCTION_SKETCH_PCTL:
+		return &SketchPercentileFunction{DefaultFunction: DefaultFunction{Name: name}}
 	default:
 		return &DefaultFunction{Name: name}
 	}
@@ -980,3 +987,75 @@ func (f *NonNegativeDerivativeFunction) WriteTo(buf *bytes.Buffer) {
 		buf.WriteString("`")
 	}
 }
+
+// SketchPercentileFunction 基于时延分布(Map(UInt8, UInt64), key为对数桶序号)计算分位数
+// 例: Percentile(rrt, 99) => 合并所有桶后, 取累计次数首次达到 99% 的桶的代表值
+// 时延分布为空时(如采集器未上报分布的历史数据), 使用 Fallback 即原有的分位数计算方式
+type SketchPercentileFunction struct {
+	DefaultFunction
+	Fallback Function
+}
+
+// 兼容 Percentile(x, 50) 和 Percentile(x, 0.5) 两种写法, 统一转换为 0.5
+func normalizeQuantile(arg string) string {
+	q, err := strconv.ParseFloat(strings.TrimSpace(arg), 64)
+	if err != nil {
+		return arg
+	}
+	if q > 1 {
+		q = q / 100
+	}
+	return strconv.FormatFloat(q, 'f', -1, 64)
+}
+
+func (f *SketchPercentileFunction) SetArgs(args []string) {
+	f.Args = append([]string{}, args...)
+	if len(f.Args) > 0 {
+		f.Args[0] = normalizeQuantile(f.Args[0])
+	}
+}
+
+func (f *SketchPercentileFunction) quantile() string {
+	if len(f.Args) == 0 {
+		return "0.5"
+	}
+	return f.Args[0]
+}
+
+// Fallback 与 sketch 使用同一个归一化后的分位数, ClickHouse 的 quantile 只接受 [0, 1]
+func (f *SketchPercentileFunction) Init() {
+	f.DefaultFunction.Init()
+	if f.Fallback != nil {
+		f.Fallback.SetArgs([]string{f.quantile()})
+	}
+}
+
+func (f *SketchPercentileFunction) GetWiths() []Node {
+	withs := f.DefaultFunction.GetWiths()
+	if f.Fallback != nil {
+		withs = append(withs, f.Fallback.GetWiths()...)
+	}
+	return withs
+}
+
+func (f *SketchPercentileFunction) WriteTo(buf *bytes.Buffer) {
+	sketch := fmt.Sprintf("%s(%s)", FUNCTION_SUM_MAP, f.Fields[0].ToString())
+	pctl := fmt.Sprintf(
+		"arrayElement(arrayMap(x -> if(x = 0, 0, pow(2, (x - 0.5) / %d)), mapKeys(%s)), arrayFirstIndex(c -> c >= %s * arraySum(mapValues(%s)), arrayCumSum(mapValues(%s))))",
+		SKETCH_SUB_BUCKETS, sketch, f.quantile(), sketch, sketch,
+	)
+	if f.Fallback != nil {
+		buf.WriteString(fmt.Sprintf("if(arraySum(mapValues(%s))>0, %s, ", sketch, pctl))
+		f.Fallback.WriteTo(buf)
+		buf.WriteString(")")
+	} else {
+		buf.WriteString(pctl)
+	}
+	buf.WriteString(f.Math)
+	if f.Alias != "" {
+		buf.WriteString(" AS ")
+		buf.WriteString("`")
+		buf.WriteString(strings.Trim(f.Alias, "`"))
+		buf.WriteString("`")
+	}
+}
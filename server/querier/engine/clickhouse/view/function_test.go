@@ -0,0 +1,52 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package view
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSketchPercentileFunction(t *testing.T) {
+	newFunc := func(fallback Function) *SketchPercentileFunction {
+		f := &SketchPercentileFunction{DefaultFunction: DefaultFunction{Name: FUNCTION_SKETCH_PCTL}, Fallback: fallback}
+		f.SetArgs([]string{"50"})
+		f.SetFields([]Node{&Field{Value: "rrt_buckets"}})
+		f.SetAlias("p50", false)
+		f.Init()
+		return f
+	}
+	sketch := "arrayElement(arrayMap(x -> if(x = 0, 0, pow(2, (x - 0.5) / 8)), mapKeys(sumMap(rrt_buckets))), arrayFirstIndex(c -> c >= 0.5 * arraySum(mapValues(sumMap(rrt_buckets))), arrayCumSum(mapValues(sumMap(rrt_buckets)))))"
+
+	buf := &bytes.Buffer{}
+	newFunc(nil).WriteTo(buf)
+	if expected := sketch + " AS `p50`"; buf.String() != expected {
+		t.Errorf("expected %s, got %s", expected, buf.String())
+	}
+
+	// the quantile of per-row averages is used when the sketch is empty
+	fallback := GetFunc(FUNCTION_PCTL)
+	fallback.SetArgs([]string{"50"})
+	fallback.SetIgnoreZero(true)
+	fallback.SetFields([]Node{&Field{Value: "rrt_sum/rrt_count"}})
+	buf.Reset()
+	newFunc(fallback).WriteTo(buf)
+	expected := "if(arraySum(mapValues(sumMap(rrt_buckets)))>0, " + sketch + ", quantileIf(0.5)(rrt_sum/rrt_count, rrt_sum/rrt_count > 0)) AS `p50`"
+	if buf.String() != expected {
+		t.Errorf("expected %s, got %s", expected, buf.String())
+	}
+}
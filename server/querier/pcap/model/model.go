@@ -0,0 +1,36 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import "context"
+
+// PcapDownload 指定需要下载的流量包，flow_ids、query_condition 和 acl_gid 至少指定一个，多个条件同时指定时取交集
+type PcapDownload struct {
+	FlowIDs        []uint64 `json:"flow_ids"`
+	QueryCondition string   `json:"query_condition"` // filter of l4_flow_log, e.g.: pod_0='frontend' AND server_port=80
+	AclGid         uint16   `json:"acl_gid"`
+	TimeStart      int      `json:"time_start" binding:"required"`
+	TimeEnd        int      `json:"time_end" binding:"required"`
+	Context        context.Context
+	OrgID          string
+}
+
+type PcapBatch struct {
+	AgentID   uint16
+	StartTime uint64 // ns, not later than the timestamp of any packet in the batch
+	Batch     []byte
+}
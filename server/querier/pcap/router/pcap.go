@@ -0,0 +1,73 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	logging "github.com/op/go-logging"
+
+	querier_common "github.com/deepflowio/deepflow/server/querier/common"
+	"github.com/deepflowio/deepflow/server/querier/config"
+	"github.com/deepflowio/deepflow/server/querier/pcap/common"
+	"github.com/deepflowio/deepflow/server/querier/pcap/model"
+	"github.com/deepflowio/deepflow/server/querier/pcap/service"
+	"github.com/deepflowio/deepflow/server/querier/router"
+)
+
+var log = logging.MustGetLogger("pcap")
+
+func PcapRouter(e *gin.Engine, cfg *config.QuerierConfig) {
+	e.POST("/v1/pcap/download", pcapDownload(cfg))
+}
+
+func pcapDownload(cfg *config.QuerierConfig) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		var args model.PcapDownload
+
+		// 参数校验
+		err := c.ShouldBindBodyWith(&args, binding.JSON)
+		if err != nil {
+			router.BadRequestResponse(c, querier_common.INVALID_POST_DATA, err.Error())
+			return
+		}
+		args.Context = c.Request.Context()
+		args.OrgID = c.Request.Header.Get(common.HEADER_KEY_X_ORG_ID)
+
+		reader, err := service.QueryPcapBatches(&args, cfg)
+		if err != nil {
+			router.JsonResponse(c, nil, nil, err)
+			return
+		}
+		defer reader.Close()
+
+		c.Header("Content-Type", common.CONTENT_TYPE_PCAPNG)
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=deepflow-%d-%d.pcapng", args.TimeStart, args.TimeEnd))
+		if _, err := service.WritePcapBatches(c.Writer, reader); err != nil {
+			if c.Writer.Written() {
+				log.Warningf("write pcapng failed: %s", err)
+				return
+			}
+			// 尚未输出任何数据时返回错误信息
+			c.Writer.Header().Del("Content-Type")
+			c.Writer.Header().Del("Content-Disposition")
+			router.JsonResponse(c, nil, nil, err)
+		}
+	})
+}
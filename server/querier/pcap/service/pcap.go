@@ -0,0 +1,231 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	logging "github.com/op/go-logging"
+
+	"github.com/deepflowio/deepflow/server/libs/ckdb"
+	querier_common "github.com/deepflowio/deepflow/server/querier/common"
+	"github.com/deepflowio/deepflow/server/querier/config"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/client"
+	"github.com/deepflowio/deepflow/server/querier/pcap/common"
+	"github.com/deepflowio/deepflow/server/querier/pcap/model"
+)
+
+var log = logging.MustGetLogger("pcap")
+
+// PcapBatchReader 按 start_time 顺序逐行读取 packet batch，不会一次性加载到内存中
+type PcapBatchReader struct {
+	rows     driver.Rows
+	next     *model.PcapBatch
+	count    int
+	maxCount int
+	sql      string
+}
+
+func (r *PcapBatchReader) read() (*model.PcapBatch, error) {
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			log.Errorf("read packet batch failed: %s, sql: %s", err, r.sql)
+			return nil, err
+		}
+		return nil, nil
+	}
+	var (
+		agentID   uint16
+		startTime int64
+		batch     string
+	)
+	if err := r.rows.Scan(&agentID, &startTime, &batch); err != nil {
+		log.Errorf("scan packet batch failed: %s, sql: %s", err, r.sql)
+		return nil, err
+	}
+	r.count++
+	return &model.PcapBatch{AgentID: agentID, StartTime: uint64(startTime), Batch: []byte(batch)}, nil
+}
+
+// Next 返回下一个 packet batch，读取完成时返回 nil
+func (r *PcapBatchReader) Next() (*model.PcapBatch, error) {
+	if batch := r.next; batch != nil {
+		r.next = nil
+		return batch, nil
+	}
+	return r.read()
+}
+
+func (r *PcapBatchReader) Close() error {
+	if r.count == r.maxCount {
+		log.Warningf("packet batch count reaches max-batch-count %d, the pcap file may be incomplete", r.maxCount)
+	}
+	return r.rows.Close()
+}
+
+// QueryPcapBatches 查询满足条件的 packet batch，没有满足条件的数据时返回 RESOURCE_NOT_FOUND，
+// 返回的 PcapBatchReader 需要调用者关闭
+func QueryPcapBatches(args *model.PcapDownload, cfg *config.QuerierConfig) (*PcapBatchReader, error) {
+	if len(args.FlowIDs) == 0 && args.QueryCondition == "" && args.AclGid == 0 {
+		return nil, querier_common.NewError(querier_common.INVALID_POST_DATA, "one of flow_ids, query_condition and acl_gid must be specified")
+	}
+	if args.TimeStart > args.TimeEnd {
+		return nil, querier_common.NewError(querier_common.INVALID_POST_DATA, fmt.Sprintf("time_start(%d) should not be greater than time_end(%d)", args.TimeStart, args.TimeEnd))
+	}
+	orgID := querier_common.DEFAULT_ORG_ID
+	if args.OrgID != "" {
+		orgID = args.OrgID
+	}
+	orgIDInt, err := strconv.Atoi(orgID)
+	if err != nil {
+		return nil, querier_common.NewError(querier_common.INVALID_POST_DATA, fmt.Sprintf("invalid org id: %s", args.OrgID))
+	}
+
+	flowIDs := args.FlowIDs
+	if args.QueryCondition != "" {
+		queryFlowIDs, err := queryFlowIDs(args, cfg)
+		if err != nil {
+			return nil, err
+		}
+		if len(flowIDs) > 0 {
+			flowIDs = intersectFlowIDs(flowIDs, queryFlowIDs)
+		} else {
+			flowIDs = queryFlowIDs
+		}
+		if len(flowIDs) == 0 {
+			return nil, querier_common.NewError(querier_common.RESOURCE_NOT_FOUND, "no flow matches query_condition")
+		}
+	}
+
+	whereSlice := []string{fmt.Sprintf("time>=%d", args.TimeStart), fmt.Sprintf("time<=%d", args.TimeEnd)}
+	if len(flowIDs) > 0 {
+		ids := make([]string, 0, len(flowIDs))
+		for _, id := range flowIDs {
+			ids = append(ids, strconv.FormatUint(id, 10))
+		}
+		whereSlice = append(whereSlice, fmt.Sprintf("%s IN (%s)", common.TAG_FLOW_ID, strings.Join(ids, ",")))
+	}
+	if args.AclGid != 0 {
+		whereSlice = append(whereSlice, fmt.Sprintf("has(acl_gids, %d)", args.AclGid))
+	}
+	sql := fmt.Sprintf(
+		"SELECT agent_id, toUnixTimestamp64Nano(start_time), packet_batch FROM %s%s.%s WHERE %s ORDER BY start_time LIMIT %d",
+		ckdb.OrgDatabasePrefix(uint16(orgIDInt)), common.DATABASE_FLOW_LOG, common.TABLE_L7_PACKET, strings.Join(whereSlice, " AND "), cfg.Pcap.MaxBatchCount,
+	)
+	chClient := client.Client{
+		Host:     cfg.Clickhouse.Host,
+		Port:     cfg.Clickhouse.Port,
+		UserName: cfg.Clickhouse.User,
+		Password: cfg.Clickhouse.Password,
+		DB:       common.DATABASE_FLOW_LOG,
+		Context:  args.Context,
+	}
+	chClient.Debug = client.NewDebug(sql)
+	rows, err := chClient.DoQueryRows(sql)
+	if err != nil {
+		log.Errorf("query packet batch failed: %s, sql: %s", err, sql)
+		return nil, err
+	}
+
+	reader := &PcapBatchReader{rows: rows, maxCount: cfg.Pcap.MaxBatchCount, sql: sql}
+	// 预读第一个 batch，在输出响应前判断是否有数据
+	reader.next, err = reader.read()
+	if err != nil || reader.next == nil {
+		rows.Close()
+		if err == nil {
+			err = querier_common.NewError(querier_common.RESOURCE_NOT_FOUND, "no packet batch found")
+		}
+		return nil, err
+	}
+	return reader, nil
+}
+
+// queryFlowIDs 通过 l4_flow_log 的查询条件获取 flow_id，查询条件使用 querier 的 SQL 语法
+func queryFlowIDs(args *model.PcapDownload, cfg *config.QuerierConfig) ([]uint64, error) {
+	sql := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE time>=%d AND time<=%d AND (%s) GROUP BY %s LIMIT %d",
+		common.TAG_FLOW_ID, common.TABLE_L4_FLOW_LOG, args.TimeStart, args.TimeEnd, args.QueryCondition, common.TAG_FLOW_ID, cfg.Pcap.MaxFlowCount,
+	)
+	ckEngine := &clickhouse.CHEngine{DB: common.DATABASE_FLOW_LOG}
+	ckEngine.Init()
+	querierArgs := querier_common.QuerierParams{
+		DB:      common.DATABASE_FLOW_LOG,
+		Sql:     sql,
+		Context: args.Context,
+		ORGID:   args.OrgID,
+	}
+	result, debug, err := ckEngine.ExecuteQuery(&querierArgs)
+	if err != nil {
+		log.Errorf("query flow id failed: %s, debug: %v", err, debug)
+		return nil, err
+	}
+	flowIDs := make([]uint64, 0, len(result.Values))
+	for _, value := range result.Values {
+		row, ok := value.([]interface{})
+		if !ok || len(row) == 0 {
+			continue
+		}
+		// flow_id 为 UInt64，querier 统一转换为 int 返回
+		if flowID, ok := row[0].(int); ok {
+			flowIDs = append(flowIDs, uint64(flowID))
+		}
+	}
+	return flowIDs, nil
+}
+
+func intersectFlowIDs(a, b []uint64) []uint64 {
+	set := make(map[uint64]struct{}, len(b))
+	for _, id := range b {
+		set[id] = struct{}{}
+	}
+	result := make([]uint64, 0, len(a))
+	for _, id := range a {
+		if _, ok := set[id]; ok {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// WritePcapBatches 逐个解析 packet batch 并以 pcapng 格式流式写入 w，没有有效的包时不写入任何数据并返回错误
+func WritePcapBatches(w io.Writer, reader *PcapBatchReader) (int64, error) {
+	writer := NewPcapngWriter(w)
+	for {
+		batch, err := reader.Next()
+		if err != nil {
+			return writer.Written(), err
+		}
+		if batch == nil {
+			break
+		}
+		if err := writer.WriteBatch(batch); err != nil {
+			// batch 损坏时保留已解析出的包，不影响其他 batch
+			log.Warning(err)
+		}
+	}
+	n, err := writer.Flush()
+	if err == nil && writer.PacketCount() == 0 {
+		return n, errors.New("no valid packet in packet batches")
+	}
+	return n, err
+}
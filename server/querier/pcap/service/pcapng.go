@@ -0,0 +1,284 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/deepflowio/deepflow/server/querier/pcap/model"
+)
+
+// https://www.ietf.org/archive/id/draft-gharris-opsawg-pcap-01.html
+const (
+	PCAP_MAGIC_MICROSECOND = 0xa1b2c3d4
+	PCAP_MAGIC_NANOSECOND  = 0xa1b23c4d
+
+	PCAP_FILE_HEADER_LEN   = 24
+	PCAP_RECORD_HEADER_LEN = 16
+)
+
+// https://www.ietf.org/archive/id/draft-ietf-opsawg-pcapng-01.html
+const (
+	PCAPNG_BLOCK_TYPE_SHB = 0x0a0d0d0a
+	PCAPNG_BLOCK_TYPE_IDB = 0x00000001
+	PCAPNG_BLOCK_TYPE_EPB = 0x00000006
+
+	PCAPNG_BYTE_ORDER_MAGIC = 0x1a2b3c4d
+
+	PCAPNG_OPT_ENDOFOPT       = 0
+	PCAPNG_OPT_SHB_USERAPPL   = 4
+	PCAPNG_OPT_IF_NAME        = 2
+	PCAPNG_OPT_IF_DESCRIPTION = 3
+	PCAPNG_OPT_IF_TSRESOL     = 9
+
+	// 所有接口的时间戳统一为纳秒
+	PCAPNG_TSRESOL_NANOSECOND = 9
+)
+
+type pcapPacket struct {
+	timestamp   uint64 // ns
+	seq         uint64 // 时间戳相同时保持写入顺序
+	interfaceID uint32
+	origLen     uint32
+	data        []byte
+}
+
+type pcapInterface struct {
+	agentID  uint16
+	linkType uint16
+}
+
+// packetHeap 按时间戳排序的最小堆
+type packetHeap []pcapPacket
+
+func (h packetHeap) Len() int { return len(h) }
+func (h packetHeap) Less(i, j int) bool {
+	return h[i].timestamp < h[j].timestamp || (h[i].timestamp == h[j].timestamp && h[i].seq < h[j].seq)
+}
+func (h packetHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *packetHeap) Push(x interface{}) { *h = append(*h, x.(pcapPacket)) }
+func (h *packetHeap) Pop() interface{} {
+	old := *h
+	p := old[len(old)-1]
+	old[len(old)-1] = pcapPacket{}
+	*h = old[:len(old)-1]
+	return p
+}
+
+// PcapngWriter 解析 pcap 格式的 packet batch，按时间戳合并后流式输出为一个 pcapng 文件
+// batch 需要按 StartTime 顺序写入，早于后续 batch StartTime 的包即可输出，只有时间范围重叠的 batch 的包会缓存在内存中
+// 每个 agent 的每种链路类型对应 pcapng 中的一个接口，section header 和接口描述在第一个包之前输出，没有包时不输出任何数据
+type PcapngWriter struct {
+	bw                *pcapngBlockWriter
+	interfaces        []pcapInterface
+	interfaceID       map[pcapInterface]uint32
+	started           bool
+	writtenInterfaces int
+	pending           packetHeap
+	seq               uint64
+	packetCount       int
+}
+
+func NewPcapngWriter(w io.Writer) *PcapngWriter {
+	return &PcapngWriter{bw: &pcapngBlockWriter{w: w}, interfaceID: make(map[pcapInterface]uint32)}
+}
+
+func (m *PcapngWriter) WriteBatch(batch *model.PcapBatch) error {
+	// 后续 batch 的包不会早于当前 batch 的 StartTime
+	m.flushBefore(batch.StartTime)
+
+	data := batch.Batch
+	if len(data) < PCAP_FILE_HEADER_LEN {
+		return fmt.Errorf("packet batch of agent %d is too short: %d", batch.AgentID, len(data))
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	var nanosecond bool
+	switch binary.LittleEndian.Uint32(data) {
+	case PCAP_MAGIC_MICROSECOND:
+	case PCAP_MAGIC_NANOSECOND:
+		nanosecond = true
+	default:
+		switch binary.BigEndian.Uint32(data) {
+		case PCAP_MAGIC_MICROSECOND:
+		case PCAP_MAGIC_NANOSECOND:
+			nanosecond = true
+		default:
+			return fmt.Errorf("unknown magic 0x%x of packet batch of agent %d", binary.LittleEndian.Uint32(data), batch.AgentID)
+		}
+		order = binary.BigEndian
+	}
+	// link type 只有低 16 位有效，高位为 FCS 等信息
+	iface := pcapInterface{agentID: batch.AgentID, linkType: uint16(order.Uint32(data[20:]))}
+	id, ok := m.interfaceID[iface]
+	if !ok {
+		id = uint32(len(m.interfaces))
+		m.interfaceID[iface] = id
+		m.interfaces = append(m.interfaces, iface)
+	}
+
+	for offset := PCAP_FILE_HEADER_LEN; offset < len(data); {
+		if offset+PCAP_RECORD_HEADER_LEN > len(data) {
+			return fmt.Errorf("packet record header of agent %d is truncated at offset %d", batch.AgentID, offset)
+		}
+		seconds := uint64(order.Uint32(data[offset:]))
+		fraction := uint64(order.Uint32(data[offset+4:]))
+		capLen := int(order.Uint32(data[offset+8:]))
+		origLen := order.Uint32(data[offset+12:])
+		offset += PCAP_RECORD_HEADER_LEN
+		if offset+capLen > len(data) {
+			return fmt.Errorf("packet record of agent %d is truncated at offset %d", batch.AgentID, offset)
+		}
+		if !nanosecond {
+			fraction *= 1000
+		}
+		m.seq++
+		heap.Push(&m.pending, pcapPacket{
+			timestamp:   seconds*1000000000 + fraction,
+			seq:         m.seq,
+			interfaceID: id,
+			origLen:     origLen,
+			data:        data[offset : offset+capLen],
+		})
+		offset += capLen
+	}
+	return nil
+}
+
+func (m *PcapngWriter) flushBefore(timestamp uint64) {
+	for len(m.pending) > 0 && m.pending[0].timestamp < timestamp {
+		p := heap.Pop(&m.pending).(pcapPacket)
+		m.writePacket(&p)
+	}
+}
+
+func (m *PcapngWriter) writePacket(p *pcapPacket) {
+	if !m.started {
+		m.bw.writeSectionHeader()
+		m.started = true
+	}
+	// 接口描述需要在使用该接口的包之前输出
+	for ; m.writtenInterfaces < len(m.interfaces); m.writtenInterfaces++ {
+		m.bw.writeInterfaceDescription(m.interfaces[m.writtenInterfaces])
+	}
+	m.bw.writeEnhancedPacket(p)
+	m.packetCount++
+}
+
+// Flush 输出所有缓存的包，返回写入的字节数
+func (m *PcapngWriter) Flush() (int64, error) {
+	for len(m.pending) > 0 {
+		p := heap.Pop(&m.pending).(pcapPacket)
+		m.writePacket(&p)
+	}
+	return m.bw.n, m.bw.err
+}
+
+func (m *PcapngWriter) PacketCount() int {
+	return m.packetCount
+}
+
+func (m *PcapngWriter) Written() int64 {
+	return m.bw.n
+}
+
+type pcapngBlockWriter struct {
+	w   io.Writer
+	buf []byte
+	n   int64
+	err error
+}
+
+func (b *pcapngBlockWriter) beginBlock(blockType uint32) {
+	b.buf = b.buf[:0]
+	b.buf = appendU32(b.buf, blockType)
+	b.buf = appendU32(b.buf, 0) // total length, filled in endBlock
+}
+
+func (b *pcapngBlockWriter) endBlock() {
+	if b.err != nil {
+		return
+	}
+	totalLen := uint32(len(b.buf) + 4)
+	binary.LittleEndian.PutUint32(b.buf[4:], totalLen)
+	b.buf = appendU32(b.buf, totalLen)
+	n, err := b.w.Write(b.buf)
+	b.n += int64(n)
+	b.err = err
+}
+
+func (b *pcapngBlockWriter) appendOption(code uint16, value []byte) {
+	b.buf = appendU16(b.buf, code)
+	b.buf = appendU16(b.buf, uint16(len(value)))
+	b.buf = appendPadded(b.buf, value)
+}
+
+func (b *pcapngBlockWriter) writeSectionHeader() {
+	b.beginBlock(PCAPNG_BLOCK_TYPE_SHB)
+	b.buf = appendU32(b.buf, PCAPNG_BYTE_ORDER_MAGIC)
+	b.buf = appendU16(b.buf, 1) // major version
+	b.buf = appendU16(b.buf, 0) // minor version
+	// section length -1: not specified
+	b.buf = appendU32(b.buf, 0xffffffff)
+	b.buf = appendU32(b.buf, 0xffffffff)
+	b.appendOption(PCAPNG_OPT_SHB_USERAPPL, []byte("deepflow"))
+	b.appendOption(PCAPNG_OPT_ENDOFOPT, nil)
+	b.endBlock()
+}
+
+func (b *pcapngBlockWriter) writeInterfaceDescription(iface pcapInterface) {
+	b.beginBlock(PCAPNG_BLOCK_TYPE_IDB)
+	b.buf = appendU16(b.buf, iface.linkType)
+	b.buf = appendU16(b.buf, 0) // reserved
+	// snap length 0: 不限制，后续 batch 的 snap length 可能更大
+	b.buf = appendU32(b.buf, 0)
+	b.appendOption(PCAPNG_OPT_IF_NAME, []byte(fmt.Sprintf("agent-%d", iface.agentID)))
+	b.appendOption(PCAPNG_OPT_IF_DESCRIPTION, []byte(fmt.Sprintf("packets captured by deepflow-agent %d", iface.agentID)))
+	b.appendOption(PCAPNG_OPT_IF_TSRESOL, []byte{PCAPNG_TSRESOL_NANOSECOND})
+	b.appendOption(PCAPNG_OPT_ENDOFOPT, nil)
+	b.endBlock()
+}
+
+func (b *pcapngBlockWriter) writeEnhancedPacket(p *pcapPacket) {
+	b.beginBlock(PCAPNG_BLOCK_TYPE_EPB)
+	b.buf = appendU32(b.buf, p.interfaceID)
+	b.buf = appendU32(b.buf, uint32(p.timestamp>>32))
+	b.buf = appendU32(b.buf, uint32(p.timestamp))
+	b.buf = appendU32(b.buf, uint32(len(p.data)))
+	b.buf = appendU32(b.buf, p.origLen)
+	b.buf = appendPadded(b.buf, p.data)
+	b.endBlock()
+}
+
+func appendU16(buf []byte, v uint16) []byte {
+	return append(buf, byte(v), byte(v>>8))
+}
+
+func appendU32(buf []byte, v uint32) []byte {
+	return append(buf, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+// 数据需要按 4 字节对齐
+func appendPadded(buf, data []byte) []byte {
+	buf = append(buf, data...)
+	for i := len(data); i%4 != 0; i++ {
+		buf = append(buf, 0)
+	}
+	return buf
+}
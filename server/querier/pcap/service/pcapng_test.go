@@ -0,0 +1,122 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/deepflowio/deepflow/server/querier/pcap/model"
+)
+
+func newPacketBatch(magic uint32, records [][3]uint32) []byte {
+	buf := &bytes.Buffer{}
+	for _, v := range []interface{}{magic, uint16(2), uint16(4), uint32(0), uint32(0), uint32(65535), uint32(1)} {
+		binary.Write(buf, binary.LittleEndian, v)
+	}
+	// record: seconds, fraction, payload byte
+	for _, r := range records {
+		binary.Write(buf, binary.LittleEndian, []uint32{r[0], r[1], 1, 60})
+		buf.WriteByte(byte(r[2]))
+	}
+	return buf.Bytes()
+}
+
+func TestPcapngWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	writer := NewPcapngWriter(buf)
+	// batches are ordered by start time, packets of overlapped batches are merged
+	batches := []*model.PcapBatch{
+		{AgentID: 1, StartTime: 10000001000, Batch: newPacketBatch(PCAP_MAGIC_MICROSECOND, [][3]uint32{{10, 1, 'a'}, {12, 0, 'c'}})},
+		{AgentID: 2, StartTime: 10000001500, Batch: newPacketBatch(PCAP_MAGIC_NANOSECOND, [][3]uint32{{10, 1500, 'b'}, {13, 0, 'd'}})},
+		{AgentID: 1, StartTime: 11000000000, Batch: newPacketBatch(PCAP_MAGIC_MICROSECOND, [][3]uint32{{11, 0, 'e'}})},
+	}
+	for _, b := range batches {
+		if err := writer.WriteBatch(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// packets earlier than the start time of the last batch are written
+	if writer.PacketCount() != 2 || writer.Written() == 0 {
+		t.Errorf("PacketCount() = %d, Written() = %d before flush, want 2 packets written", writer.PacketCount(), writer.Written())
+	}
+	if err := writer.WriteBatch(&model.PcapBatch{AgentID: 3, StartTime: 11000000000, Batch: []byte{1, 2, 3}}); err == nil {
+		t.Error("WriteBatch should fail for truncated batch")
+	}
+
+	n, err := writer.Flush()
+	if err != nil || n != int64(buf.Len()) {
+		t.Fatalf("Flush() = %d, %v, buffer length %d", n, err, buf.Len())
+	}
+	if writer.PacketCount() != 5 {
+		t.Fatalf("PacketCount() = %d, want 5", writer.PacketCount())
+	}
+
+	// walk through blocks: 1 SHB, 2 IDB (one per agent, written before its first packet), 5 EPB ordered by timestamp
+	data := buf.Bytes()
+	var blockTypes []uint32
+	var payloads []byte
+	var interfaces []uint32
+	var lastTimestamp uint64
+	for offset := 0; offset < len(data); {
+		blockType := binary.LittleEndian.Uint32(data[offset:])
+		blockLen := int(binary.LittleEndian.Uint32(data[offset+4:]))
+		if blockLen%4 != 0 || binary.LittleEndian.Uint32(data[offset+blockLen-4:]) != uint32(blockLen) {
+			t.Fatalf("invalid block length %d at offset %d", blockLen, offset)
+		}
+		blockTypes = append(blockTypes, blockType)
+		if blockType == PCAPNG_BLOCK_TYPE_EPB {
+			body := data[offset+8:]
+			interfaces = append(interfaces, binary.LittleEndian.Uint32(body))
+			timestamp := uint64(binary.LittleEndian.Uint32(body[4:]))<<32 | uint64(binary.LittleEndian.Uint32(body[8:]))
+			if timestamp < lastTimestamp {
+				t.Errorf("packets are not ordered by timestamp: %d < %d", timestamp, lastTimestamp)
+			}
+			lastTimestamp = timestamp
+			payloads = append(payloads, body[20])
+		}
+		offset += blockLen
+	}
+	wantTypes := []uint32{PCAPNG_BLOCK_TYPE_SHB, PCAPNG_BLOCK_TYPE_IDB, PCAPNG_BLOCK_TYPE_EPB, PCAPNG_BLOCK_TYPE_IDB}
+	for i, want := range wantTypes {
+		if blockTypes[i] != want {
+			t.Errorf("block %d type = 0x%x, want 0x%x", i, blockTypes[i], want)
+		}
+	}
+	if string(payloads) != "abecd" {
+		t.Errorf("packets order = %s, want abecd", payloads)
+	}
+	if len(interfaces) != 5 || interfaces[0] != 0 || interfaces[1] != 1 {
+		t.Errorf("interface ids = %v", interfaces)
+	}
+	if lastTimestamp != 13*1000000000 {
+		t.Errorf("last timestamp = %d, want %d", lastTimestamp, 13*1000000000)
+	}
+}
+
+func TestPcapngWriterEmpty(t *testing.T) {
+	buf := &bytes.Buffer{}
+	writer := NewPcapngWriter(buf)
+	if err := writer.WriteBatch(&model.PcapBatch{AgentID: 1, Batch: newPacketBatch(PCAP_MAGIC_MICROSECOND, nil)}); err != nil {
+		t.Fatal(err)
+	}
+	// nothing is written without packets, so that an error can still be responded
+	if n, err := writer.Flush(); n != 0 || err != nil || buf.Len() != 0 {
+		t.Errorf("Flush() = %d, %v, buffer length %d, want nothing written", n, err, buf.Len())
+	}
+}
@@ -44,6 +44,27 @@ const (
 
 const DATA_FORMAT_GRAFANA = "grafana"
 
+const (
+	PROFILE_FORMAT_JSON  = "json"
+	PROFILE_FORMAT_PPROF = "pprof"
+
+	CONTENT_TYPE_PPROF = "application/octet-stream"
+	PPROF_FILE_NAME    = "profile.pb.gz"
+)
+
+const (
+	PYROSCOPE_LABEL_SERVICE_NAME = "service_name"
+	PYROSCOPE_ROOT_NAME          = "total"
+	PYROSCOPE_OTHER_NAME         = "other"
+	PYROSCOPE_MAX_NODES_DEFAULT  = 8192
+	PYROSCOPE_TIMELINE_POINTS    = 1024
+	PYROSCOPE_TIMELINE_STEP_MIN  = 10 // seconds
+	PYROSCOPE_LABEL_VALUES_LIMIT = 10000
+
+	CONTENT_TYPE_CONNECT_PROTO = "application/proto"
+	CONTENT_TYPE_CONNECT_JSON  = "application/json"
+)
+
 var LOCATION_TYPE_MAP = map[string]string{
 	"[c] ": "C", // cuda functions
 	"[k] ": "K", // kernel function
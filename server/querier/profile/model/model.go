@@ -29,7 +29,18 @@ type Profile struct {
 	Debug               bool   `json:"debug"`
 	Context             context.Context
 	OrgID               string
-	MaxKernelStackDepth *int `json:"max_kernel_stack_depth"` // default: -1
+	MaxKernelStackDepth *int   `json:"max_kernel_stack_depth"`                      // default: -1
+	Format              string `json:"format" binding:"omitempty,oneof=json pprof"` // default: json, pprof: gzip'ed profile.proto
+}
+
+// ProfileDiff compares the profile of Profile.TimeStart~TimeEnd (baseline)
+// with CompareTimeStart~CompareTimeEnd (comparison)
+type ProfileDiff struct {
+	Profile
+	CompareTimeStart int     `json:"compare_time_start" binding:"required"`
+	CompareTimeEnd   int     `json:"compare_time_end" binding:"required"`
+	CompareTagFilter *string `json:"compare_tag_filter"` // default: same as tag_filter
+	Normalize        bool    `json:"normalize"`          // scale baseline to the total value of comparison
 }
 
 type ProfileGrafana struct {
@@ -0,0 +1,196 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+)
+
+// Pyroscope query API, messages of querier.v1 and types.v1 in grafana/pyroscope,
+// json field names follow the protojson mapping used by Connect
+
+type PyroscopeRender struct {
+	Query    string `form:"query" binding:"required"` // e.g.: app.on-cpu{pod="x"}
+	From     string `form:"from"`                     // now-1h, unix seconds or milliseconds
+	Until    string `form:"until"`
+	MaxNodes int    `form:"max-nodes"`
+	Format   string `form:"format"` // only json
+}
+
+type FlamebearerProfile struct {
+	Version     int                  `json:"version"`
+	Flamebearer Flamebearer          `json:"flamebearer"`
+	Metadata    FlamebearerMeta      `json:"metadata"`
+	Timeline    *FlamebearerTimeline `json:"timeline"`
+}
+
+type Flamebearer struct {
+	Names    []string  `json:"names"`
+	Levels   [][]int64 `json:"levels"`
+	NumTicks int64     `json:"numTicks"`
+	MaxSelf  int64     `json:"maxSelf"`
+}
+
+type FlamebearerMeta struct {
+	Format     string `json:"format"`
+	SpyName    string `json:"spyName"`
+	SampleRate int    `json:"sampleRate"`
+	Units      string `json:"units"`
+	Name       string `json:"name"`
+}
+
+type FlamebearerTimeline struct {
+	StartTime     int64   `json:"startTime"`
+	Samples       []int64 `json:"samples"`
+	DurationDelta int64   `json:"durationDelta"`
+}
+
+// JsonInt64 accepts both numbers and strings, protojson encodes int64 as string
+type JsonInt64 int64
+
+func (i *JsonInt64) UnmarshalJSON(b []byte) error {
+	b = bytes.Trim(b, `"`)
+	if len(b) == 0 || string(b) == "null" {
+		return nil
+	}
+	v, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		return err
+	}
+	*i = JsonInt64(v)
+	return nil
+}
+
+const (
+	TimeSeriesAggregationTypeSum     = 0
+	TimeSeriesAggregationTypeAverage = 1
+)
+
+// TimeSeriesAggregation accepts both the enum name and number
+type TimeSeriesAggregation int
+
+func (a *TimeSeriesAggregation) UnmarshalJSON(b []byte) error {
+	var name string
+	if err := json.Unmarshal(b, &name); err == nil {
+		switch name {
+		case "TIME_SERIES_AGGREGATION_TYPE_AVERAGE":
+			*a = TimeSeriesAggregationTypeAverage
+		default:
+			*a = TimeSeriesAggregationTypeSum
+		}
+		return nil
+	}
+	var v int
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*a = TimeSeriesAggregation(v)
+	return nil
+}
+
+type ProfileTypesRequest struct {
+	Start JsonInt64 `json:"start"` // milliseconds
+	End   JsonInt64 `json:"end"`
+}
+
+type ProfileType struct {
+	ID         string `json:"ID"`
+	Name       string `json:"name"`
+	SampleType string `json:"sampleType"`
+	SampleUnit string `json:"sampleUnit"`
+	PeriodType string `json:"periodType"`
+	PeriodUnit string `json:"periodUnit"`
+}
+
+type ProfileTypesResponse struct {
+	ProfileTypes []ProfileType `json:"profileTypes"`
+}
+
+type LabelNamesRequest struct {
+	Matchers []string  `json:"matchers"`
+	Start    JsonInt64 `json:"start"`
+	End      JsonInt64 `json:"end"`
+}
+
+type LabelNamesResponse struct {
+	Names []string `json:"names"`
+}
+
+type LabelValuesRequest struct {
+	Name     string    `json:"name"`
+	Matchers []string  `json:"matchers"`
+	Start    JsonInt64 `json:"start"`
+	End      JsonInt64 `json:"end"`
+}
+
+type LabelValuesResponse struct {
+	Names []string `json:"names"`
+}
+
+type SelectMergeStacktracesRequest struct {
+	ProfileTypeID string    `json:"profileTypeID"`
+	LabelSelector string    `json:"labelSelector"`
+	Start         JsonInt64 `json:"start"`
+	End           JsonInt64 `json:"end"`
+	MaxNodes      JsonInt64 `json:"maxNodes"`
+}
+
+type FlameGraph struct {
+	Names   []string          `json:"names"`
+	Levels  []FlameGraphLevel `json:"levels"`
+	Total   int64             `json:"total"`
+	MaxSelf int64             `json:"maxSelf"`
+}
+
+type FlameGraphLevel struct {
+	Values []int64 `json:"values"`
+}
+
+type SelectMergeStacktracesResponse struct {
+	Flamegraph *FlameGraph `json:"flamegraph"`
+}
+
+type SelectSeriesRequest struct {
+	ProfileTypeID string                `json:"profileTypeID"`
+	LabelSelector string                `json:"labelSelector"`
+	Start         JsonInt64             `json:"start"`
+	End           JsonInt64             `json:"end"`
+	GroupBy       []string              `json:"groupBy"`
+	Step          float64               `json:"step"` // seconds
+	Aggregation   TimeSeriesAggregation `json:"aggregation"`
+}
+
+type LabelPair struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type Point struct {
+	Value     float64 `json:"value"`
+	Timestamp int64   `json:"timestamp"` // milliseconds
+}
+
+type Series struct {
+	Labels []LabelPair `json:"labels"`
+	Points []Point     `json:"points"`
+}
+
+type SelectSeriesResponse struct {
+	Series []Series `json:"series"`
+}
@@ -0,0 +1,194 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/deepflowio/deepflow/server/libs/codec"
+)
+
+// protobuf codec of the Pyroscope querier messages (api/querier/v1/querier.proto and
+// api/types/v1/types.proto), only the fields used by the querier are decoded
+
+func consumeProtoInt64(typ protowire.Type, b []byte, v *JsonInt64) (int, error) {
+	var x uint64
+	n, err := codec.ConsumeProtoVarint(typ, b, &x)
+	*v = JsonInt64(x)
+	return n, err
+}
+
+func (r *ProfileTypesRequest) UnmarshalProto(b []byte) error {
+	return codec.ConsumeProtoFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch num {
+		case 1:
+			return consumeProtoInt64(typ, b, &r.Start)
+		case 2:
+			return consumeProtoInt64(typ, b, &r.End)
+		}
+		return 0, nil
+	})
+}
+
+func (r *LabelNamesRequest) UnmarshalProto(b []byte) error {
+	return codec.ConsumeProtoFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch num {
+		case 1:
+			return codec.ConsumeProtoStrings(typ, b, &r.Matchers)
+		case 2:
+			return consumeProtoInt64(typ, b, &r.Start)
+		case 3:
+			return consumeProtoInt64(typ, b, &r.End)
+		}
+		return 0, nil
+	})
+}
+
+func (r *LabelValuesRequest) UnmarshalProto(b []byte) error {
+	return codec.ConsumeProtoFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch num {
+		case 1:
+			return codec.ConsumeProtoString(typ, b, &r.Name)
+		case 2:
+			return codec.ConsumeProtoStrings(typ, b, &r.Matchers)
+		case 3:
+			return consumeProtoInt64(typ, b, &r.Start)
+		case 4:
+			return consumeProtoInt64(typ, b, &r.End)
+		}
+		return 0, nil
+	})
+}
+
+func (r *SelectMergeStacktracesRequest) UnmarshalProto(b []byte) error {
+	return codec.ConsumeProtoFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch num {
+		case 1:
+			return codec.ConsumeProtoString(typ, b, &r.ProfileTypeID)
+		case 2:
+			return codec.ConsumeProtoString(typ, b, &r.LabelSelector)
+		case 3:
+			return consumeProtoInt64(typ, b, &r.Start)
+		case 4:
+			return consumeProtoInt64(typ, b, &r.End)
+		case 5:
+			return consumeProtoInt64(typ, b, &r.MaxNodes)
+		}
+		return 0, nil
+	})
+}
+
+func (r *SelectSeriesRequest) UnmarshalProto(b []byte) error {
+	return codec.ConsumeProtoFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch num {
+		case 1:
+			return codec.ConsumeProtoString(typ, b, &r.ProfileTypeID)
+		case 2:
+			return codec.ConsumeProtoString(typ, b, &r.LabelSelector)
+		case 3:
+			return consumeProtoInt64(typ, b, &r.Start)
+		case 4:
+			return consumeProtoInt64(typ, b, &r.End)
+		case 5:
+			return codec.ConsumeProtoStrings(typ, b, &r.GroupBy)
+		case 6:
+			return codec.ConsumeProtoDouble(typ, b, &r.Step)
+		case 7:
+			var v JsonInt64
+			n, err := consumeProtoInt64(typ, b, &v)
+			r.Aggregation = TimeSeriesAggregation(v)
+			return n, err
+		}
+		return 0, nil
+	})
+}
+
+func (p *ProfileType) MarshalProto() []byte {
+	var b []byte
+	b = codec.AppendProtoString(b, 1, p.ID)
+	b = codec.AppendProtoString(b, 2, p.Name)
+	b = codec.AppendProtoString(b, 4, p.SampleType)
+	b = codec.AppendProtoString(b, 5, p.SampleUnit)
+	b = codec.AppendProtoString(b, 6, p.PeriodType)
+	b = codec.AppendProtoString(b, 7, p.PeriodUnit)
+	return b
+}
+
+func (r *ProfileTypesResponse) MarshalProto() []byte {
+	var b []byte
+	for i := range r.ProfileTypes {
+		b = codec.AppendProtoMessage(b, 1, r.ProfileTypes[i].MarshalProto())
+	}
+	return b
+}
+
+func (r *LabelNamesResponse) MarshalProto() []byte {
+	var b []byte
+	for _, name := range r.Names {
+		b = codec.AppendProtoString(b, 1, name)
+	}
+	return b
+}
+
+func (r *LabelValuesResponse) MarshalProto() []byte {
+	return (*LabelNamesResponse)(r).MarshalProto()
+}
+
+func (g *FlameGraph) MarshalProto() []byte {
+	var b []byte
+	for _, name := range g.Names {
+		b = codec.AppendProtoString(b, 1, name)
+	}
+	for _, level := range g.Levels {
+		b = codec.AppendProtoMessage(b, 2, codec.AppendProtoPackedVarints(nil, 1, level.Values))
+	}
+	b = codec.AppendProtoVarint(b, 3, uint64(g.Total))
+	b = codec.AppendProtoVarint(b, 4, uint64(g.MaxSelf))
+	return b
+}
+
+func (r *SelectMergeStacktracesResponse) MarshalProto() []byte {
+	if r.Flamegraph == nil {
+		return nil
+	}
+	return codec.AppendProtoMessage(nil, 1, r.Flamegraph.MarshalProto())
+}
+
+func (s *Series) MarshalProto() []byte {
+	var b []byte
+	for _, label := range s.Labels {
+		var l []byte
+		l = codec.AppendProtoString(l, 1, label.Name)
+		l = codec.AppendProtoString(l, 2, label.Value)
+		b = codec.AppendProtoMessage(b, 1, l)
+	}
+	for _, point := range s.Points {
+		var p []byte
+		p = codec.AppendProtoDouble(p, 1, point.Value)
+		p = codec.AppendProtoVarint(p, 2, uint64(point.Timestamp))
+		b = codec.AppendProtoMessage(b, 2, p)
+	}
+	return b
+}
+
+func (r *SelectSeriesResponse) MarshalProto() []byte {
+	var b []byte
+	for i := range r.Series {
+		b = codec.AppendProtoMessage(b, 1, r.Series[i].MarshalProto())
+	}
+	return b
+}
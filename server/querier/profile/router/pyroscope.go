@@ -0,0 +1,260 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/deepflowio/deepflow/server/querier/config"
+	"github.com/deepflowio/deepflow/server/querier/profile/common"
+	"github.com/deepflowio/deepflow/server/querier/profile/model"
+	"github.com/deepflowio/deepflow/server/querier/profile/service"
+	"github.com/deepflowio/deepflow/server/querier/router"
+)
+
+const pyroscopeQuerierService = "/pyroscope/querier.v1.QuerierService/"
+
+// Pyroscope query API, for Grafana Pyroscope datasource and Pyroscope UI
+func PyroscopeRouter(e *gin.Engine, cfg *config.QuerierConfig) {
+	// legacy HTTP API
+	e.GET("/pyroscope/render", pyroscopeRender(cfg))
+	e.GET("/pyroscope/labels", pyroscopeLabels(cfg))
+	e.GET("/pyroscope/label-values", pyroscopeLabelValues(cfg))
+
+	// Connect unary API, application/proto and application/json are supported
+	e.POST(pyroscopeQuerierService+"ProfileTypes", pyroscopeProfileTypes(cfg))
+	e.POST(pyroscopeQuerierService+"LabelNames", pyroscopeConnectLabelNames(cfg))
+	e.POST(pyroscopeQuerierService+"LabelValues", pyroscopeConnectLabelValues(cfg))
+	e.POST(pyroscopeQuerierService+"SelectMergeStacktraces", pyroscopeSelectMergeStacktraces(cfg))
+	e.POST(pyroscopeQuerierService+"SelectSeries", pyroscopeSelectSeries(cfg))
+}
+
+func newPyroscopeQuerier(c *gin.Context, cfg *config.QuerierConfig) *service.PyroscopeQuerier {
+	return service.NewPyroscopeQuerier(cfg, c.Request.Context(), c.Request.Header.Get(common.HEADER_KEY_X_ORG_ID))
+}
+
+func pyroscopeRender(cfg *config.QuerierConfig) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		var args model.PyroscopeRender
+
+		// 参数校验
+		if err := c.ShouldBindQuery(&args); err != nil {
+			router.BadRequestResponse(c, common.INVALID_PARAMETERS, err.Error())
+			return
+		}
+		result, err := newPyroscopeQuerier(c, cfg).Render(args)
+		pyroscopeJsonResponse(c, result, err)
+	})
+}
+
+// legacy label API, the time range is the same as render
+func pyroscopeLabels(cfg *config.QuerierConfig) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		start, end, err := getPyroscopeTimeRange(c)
+		if err != nil {
+			router.BadRequestResponse(c, common.INVALID_PARAMETERS, err.Error())
+			return
+		}
+		args := model.LabelNamesRequest{Start: start, End: end}
+		if query := c.Query("query"); query != "" {
+			args.Matchers = []string{query}
+		}
+		result, err := newPyroscopeQuerier(c, cfg).LabelNames(args)
+		if err != nil {
+			pyroscopeJsonResponse(c, nil, err)
+			return
+		}
+		pyroscopeJsonResponse(c, result.Names, nil)
+	})
+}
+
+func pyroscopeLabelValues(cfg *config.QuerierConfig) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		start, end, err := getPyroscopeTimeRange(c)
+		if err != nil {
+			router.BadRequestResponse(c, common.INVALID_PARAMETERS, err.Error())
+			return
+		}
+		args := model.LabelValuesRequest{Name: c.Query("label"), Start: start, End: end}
+		if query := c.Query("query"); query != "" {
+			args.Matchers = []string{query}
+		}
+		result, err := newPyroscopeQuerier(c, cfg).LabelValues(args)
+		if err != nil {
+			pyroscopeJsonResponse(c, nil, err)
+			return
+		}
+		pyroscopeJsonResponse(c, result.Names, nil)
+	})
+}
+
+// returns milliseconds
+func getPyroscopeTimeRange(c *gin.Context) (start, end model.JsonInt64, err error) {
+	from, until := c.DefaultQuery("from", "now-1h"), c.DefaultQuery("until", "now")
+	fromSecond, err := service.ParsePyroscopeTime(from)
+	if err != nil {
+		return
+	}
+	untilSecond, err := service.ParsePyroscopeTime(until)
+	if err != nil {
+		return
+	}
+	return model.JsonInt64(fromSecond * 1000), model.JsonInt64(untilSecond * 1000), nil
+}
+
+func pyroscopeJsonResponse(c *gin.Context, result interface{}, err error) {
+	if err == nil {
+		c.JSON(http.StatusOK, result)
+		return
+	}
+	var serviceError *service.ServiceError
+	if errors.As(err, &serviceError) && serviceError.Status == common.INVALID_PARAMETERS {
+		router.BadRequestResponse(c, serviceError.Status, serviceError.Message)
+		return
+	}
+	router.InternalErrorResponse(c, nil, nil, common.SERVER_ERROR, err.Error())
+}
+
+type connectRequest interface {
+	UnmarshalProto(b []byte) error
+}
+
+type connectResponse interface {
+	MarshalProto() []byte
+}
+
+// https://connectrpc.com/docs/protocol/#error-end-stream
+type connectError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func connectErrorResponse(c *gin.Context, status int, code, message string) {
+	c.JSON(status, connectError{Code: code, Message: message})
+}
+
+// decode the unary request, returns false if the response has been written
+func bindConnectRequest(c *gin.Context, args connectRequest) (isProto bool, ok bool) {
+	contentType, _, _ := mime.ParseMediaType(c.ContentType())
+	switch contentType {
+	case common.CONTENT_TYPE_CONNECT_PROTO:
+		isProto = true
+	case common.CONTENT_TYPE_CONNECT_JSON:
+	default:
+		c.Header("Accept-Post", common.CONTENT_TYPE_CONNECT_PROTO+", "+common.CONTENT_TYPE_CONNECT_JSON)
+		c.Status(http.StatusUnsupportedMediaType)
+		return
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		connectErrorResponse(c, http.StatusBadRequest, "invalid_argument", err.Error())
+		return
+	}
+	if isProto {
+		err = args.UnmarshalProto(body)
+	} else if len(body) > 0 {
+		err = json.Unmarshal(body, args)
+	}
+	if err != nil {
+		connectErrorResponse(c, http.StatusBadRequest, "invalid_argument", err.Error())
+		return
+	}
+	return isProto, true
+}
+
+func writeConnectResponse(c *gin.Context, isProto bool, result connectResponse, err error) {
+	if err != nil {
+		var serviceError *service.ServiceError
+		if errors.As(err, &serviceError) && serviceError.Status == common.INVALID_PARAMETERS {
+			connectErrorResponse(c, http.StatusBadRequest, "invalid_argument", serviceError.Message)
+		} else {
+			connectErrorResponse(c, http.StatusInternalServerError, "internal", err.Error())
+		}
+		return
+	}
+	if isProto {
+		c.Data(http.StatusOK, common.CONTENT_TYPE_CONNECT_PROTO, result.MarshalProto())
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func pyroscopeProfileTypes(cfg *config.QuerierConfig) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		var args model.ProfileTypesRequest
+		isProto, ok := bindConnectRequest(c, &args)
+		if !ok {
+			return
+		}
+		result, err := newPyroscopeQuerier(c, cfg).ProfileTypes(args)
+		writeConnectResponse(c, isProto, result, err)
+	})
+}
+
+func pyroscopeConnectLabelNames(cfg *config.QuerierConfig) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		var args model.LabelNamesRequest
+		isProto, ok := bindConnectRequest(c, &args)
+		if !ok {
+			return
+		}
+		result, err := newPyroscopeQuerier(c, cfg).LabelNames(args)
+		writeConnectResponse(c, isProto, result, err)
+	})
+}
+
+func pyroscopeConnectLabelValues(cfg *config.QuerierConfig) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		var args model.LabelValuesRequest
+		isProto, ok := bindConnectRequest(c, &args)
+		if !ok {
+			return
+		}
+		result, err := newPyroscopeQuerier(c, cfg).LabelValues(args)
+		writeConnectResponse(c, isProto, result, err)
+	})
+}
+
+func pyroscopeSelectMergeStacktraces(cfg *config.QuerierConfig) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		var args model.SelectMergeStacktracesRequest
+		isProto, ok := bindConnectRequest(c, &args)
+		if !ok {
+			return
+		}
+		result, err := newPyroscopeQuerier(c, cfg).SelectMergeStacktraces(args)
+		writeConnectResponse(c, isProto, result, err)
+	})
+}
+
+func pyroscopeSelectSeries(cfg *config.QuerierConfig) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		var args model.SelectSeriesRequest
+		isProto, ok := bindConnectRequest(c, &args)
+		if !ok {
+			return
+		}
+		result, err := newPyroscopeQuerier(c, cfg).SelectSeries(args)
+		writeConnectResponse(c, isProto, result, err)
+	})
+}
@@ -17,6 +17,7 @@
 package router
 
 import (
+	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -31,6 +32,7 @@ import (
 
 func ProfileRouter(e *gin.Engine, cfg *config.QuerierConfig) {
 	e.POST("/v1/profile/ProfileTracing", profile(cfg))
+	e.POST("/v1/profile/ProfileTracingDiff", profileDiff(cfg))
 	e.POST("/v1/profile/ProfileGrafana", profileGrafana(cfg))
 }
 
@@ -44,13 +46,14 @@ func profile(cfg *config.QuerierConfig) gin.HandlerFunc {
 			router.BadRequestResponse(c, common.INVALID_POST_DATA, err.Error())
 			return
 		}
-		args.Context = c.Request.Context()
-		args.OrgID = c.Request.Header.Get(common.HEADER_KEY_X_ORG_ID)
-		if args.MaxKernelStackDepth == nil {
-			var maxKernelStackDepth = common.MAX_KERNEL_STACK_DEPTH_DEFAULT
-			args.MaxKernelStackDepth = &maxKernelStackDepth
-		}
+		setProfileArgs(c, &args)
 		result, debug, err := service.Profile(args, cfg)
+		if err == nil && args.Format == common.PROFILE_FORMAT_PPROF {
+			encoder := service.NewPprofEncoder(args.ProfileEventType)
+			encoder.AddTree(&result, false, 1.0)
+			pprofResponse(c, encoder, args.TimeStart, args.TimeEnd)
+			return
+		}
 		if err == nil && !args.Debug {
 			debug = nil
 		}
@@ -58,6 +61,56 @@ func profile(cfg *config.QuerierConfig) gin.HandlerFunc {
 	})
 }
 
+func profileDiff(cfg *config.QuerierConfig) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		var args model.ProfileDiff
+
+		// 参数校验
+		err := c.ShouldBindBodyWith(&args, binding.JSON)
+		if err != nil {
+			router.BadRequestResponse(c, common.INVALID_POST_DATA, err.Error())
+			return
+		}
+		setProfileArgs(c, &args.Profile)
+		baseline, comparison, debug, err := service.ProfileDiff(args, cfg)
+		if err == nil && args.Format == common.PROFILE_FORMAT_PPROF {
+			// baseline samples are labeled as pprof::base, the same as `go tool pprof -diff_base`
+			ratio := 1.0
+			if args.Normalize {
+				ratio = service.NormalizeRatio(&baseline, &comparison)
+			}
+			encoder := service.NewPprofEncoder(args.ProfileEventType)
+			encoder.AddTree(&comparison, false, 1.0)
+			encoder.AddTree(&baseline, true, ratio)
+			pprofResponse(c, encoder, args.CompareTimeStart, args.CompareTimeEnd)
+			return
+		}
+		if err == nil && !args.Debug {
+			debug = nil
+		}
+		result := service.DiffProfileTree(&baseline, &comparison, args.ProfileEventType, args.Normalize)
+		router.JsonResponse(c, result, debug, err)
+	})
+}
+
+func setProfileArgs(c *gin.Context, args *model.Profile) {
+	args.Context = c.Request.Context()
+	args.OrgID = c.Request.Header.Get(common.HEADER_KEY_X_ORG_ID)
+	if args.MaxKernelStackDepth == nil {
+		var maxKernelStackDepth = common.MAX_KERNEL_STACK_DEPTH_DEFAULT
+		args.MaxKernelStackDepth = &maxKernelStackDepth
+	}
+}
+
+func pprofResponse(c *gin.Context, encoder *service.PprofEncoder, timeStart, timeEnd int) {
+	c.Header("Content-Type", common.CONTENT_TYPE_PPROF)
+	c.Header("Content-Disposition", "attachment; filename="+common.PPROF_FILE_NAME)
+	c.Status(http.StatusOK)
+	if err := encoder.WriteTo(c.Writer, timeStart, timeEnd); err != nil {
+		c.Error(err)
+	}
+}
+
 func profileGrafana(cfg *config.QuerierConfig) gin.HandlerFunc {
 	return gin.HandlerFunc(func(c *gin.Context) {
 		args := model.ProfileGrafana{}
@@ -0,0 +1,215 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"math"
+	"sync"
+
+	"github.com/deepflowio/deepflow/server/querier/config"
+	"github.com/deepflowio/deepflow/server/querier/profile/model"
+)
+
+type ProfileDiffDebug struct {
+	Baseline   interface{} `json:"baseline"`
+	Comparison interface{} `json:"comparison"`
+}
+
+// ProfileDiff queries baseline and comparison profile trees in parallel
+func ProfileDiff(args model.ProfileDiff, cfg *config.QuerierConfig) (baseline, comparison model.ProfileTree, debug interface{}, err error) {
+	compareArgs := args.Profile
+	compareArgs.TimeStart = args.CompareTimeStart
+	compareArgs.TimeEnd = args.CompareTimeEnd
+	if args.CompareTagFilter != nil {
+		compareArgs.TagFilter = *args.CompareTagFilter
+	}
+
+	var baselineErr, comparisonErr error
+	diffDebug := ProfileDiffDebug{}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		baseline, diffDebug.Baseline, baselineErr = Profile(args.Profile, cfg)
+	}()
+	go func() {
+		defer wg.Done()
+		comparison, diffDebug.Comparison, comparisonErr = Profile(compareArgs, cfg)
+	}()
+	wg.Wait()
+
+	debug = diffDebug
+	if baselineErr != nil {
+		err = baselineErr
+	} else {
+		err = comparisonErr
+	}
+	return
+}
+
+type diffNode struct {
+	locationID   int
+	parentNodeID int
+	// [baseline_self, baseline_total, comparison_self, comparison_total]
+	values [4]int
+}
+
+type profileDiffMerger struct {
+	locations    []string
+	locationToID map[string]int
+	nodes        []diffNode
+	pathToNodeID map[string]int
+}
+
+// DiffProfileTree merges baseline and comparison by function stack, the result has
+// the same functions/node_values shape as a single profile tree, self_value and
+// total_value are comparison values, *_delta are comparison minus baseline
+func DiffProfileTree(baseline, comparison *model.ProfileTree, profileEventType string, normalize bool) (result model.ProfileTree) {
+	merger := &profileDiffMerger{
+		locationToID: make(map[string]int),
+		pathToNodeID: make(map[string]int),
+	}
+	// comparison first, keep the root node at index 0
+	merger.add(comparison, 2)
+	merger.add(baseline, 0)
+	if len(merger.nodes) == 0 {
+		return
+	}
+
+	ratio := 1.0
+	if normalize {
+		ratio = NormalizeRatio(baseline, comparison)
+	}
+	scale := func(v int) int {
+		return scaleValue(v, ratio)
+	}
+
+	result.Functions = merger.locations
+	functionValues := make([][]int, len(merger.locations))
+	typeValues := make([][]int, len(merger.locations))
+	for i := range functionValues {
+		functionValues[i] = []int{0, 0, 0, 0}
+		typeValues[i] = []int{0, 0}
+	}
+	result.NodeValues.Values = make([][]int, 0, len(merger.nodes))
+	for _, node := range merger.nodes {
+		selfDelta := node.values[2] - scale(node.values[0])
+		totalDelta := node.values[3] - scale(node.values[1])
+		result.NodeValues.Values = append(result.NodeValues.Values, []int{
+			node.locationID, node.parentNodeID, node.values[2], node.values[3], selfDelta, totalDelta,
+		})
+		functionValue := functionValues[node.locationID]
+		functionValue[0] += node.values[2]
+		functionValue[1] += node.values[3]
+		functionValue[2] += selfDelta
+		functionValue[3] += totalDelta
+		typeValues[node.locationID][0] += node.values[0] + node.values[2]
+		typeValues[node.locationID][1] += node.values[1] + node.values[3]
+	}
+	result.FunctionTypes = GetLocationType(merger.locations, typeValues, profileEventType)
+	result.FunctionValues.Values = functionValues
+	result.FunctionValues.Columns = []string{"self_value", "total_value", "self_value_delta", "total_value_delta"}
+	result.NodeValues.Columns = []string{"function_id", "parent_node_id", "self_value", "total_value", "self_value_delta", "total_value_delta"}
+	return
+}
+
+// NormalizeRatio returns the ratio to scale baseline values to the total value of comparison,
+// it is 1 if either of them is empty
+func NormalizeRatio(baseline, comparison *model.ProfileTree) float64 {
+	baselineTotal, comparisonTotal := rootTotalValue(baseline), rootTotalValue(comparison)
+	if baselineTotal <= 0 || comparisonTotal <= 0 {
+		return 1.0
+	}
+	return float64(comparisonTotal) / float64(baselineTotal)
+}
+
+func rootTotalValue(tree *model.ProfileTree) int {
+	if tree == nil {
+		return 0
+	}
+	total := 0
+	for _, node := range tree.NodeValues.Values {
+		if node[1] < 0 {
+			total += node[3]
+		}
+	}
+	return total
+}
+
+func scaleValue(v int, ratio float64) int {
+	if ratio == 1.0 {
+		return v
+	}
+	return int(math.Round(float64(v) * ratio))
+}
+
+// add merges tree into the result, values are written at valueOffset (0: baseline, 2: comparison)
+func (m *profileDiffMerger) add(tree *model.ProfileTree, valueOffset int) {
+	if tree == nil || len(tree.NodeValues.Values) == 0 {
+		return
+	}
+	nodes := tree.NodeValues.Values
+	mergedIDs := make([]int, len(nodes))
+	paths := make([]string, len(nodes))
+	for i := range mergedIDs {
+		mergedIDs[i] = -1
+	}
+
+	var addNode func(i int) int
+	addNode = func(i int) int {
+		if mergedIDs[i] >= 0 {
+			return mergedIDs[i]
+		}
+		// node_values columns: ["function_id", "parent_node_id", "self_value", "total_value"]
+		location := tree.Functions[nodes[i][0]]
+		parentID := -1
+		// function names never contain ';', they are split by it
+		path := location
+		if parent := nodes[i][1]; parent >= 0 && parent < len(nodes) {
+			parentID = addNode(parent)
+			path = paths[parent] + ";" + location
+		}
+		paths[i] = path
+
+		nodeID, ok := m.pathToNodeID[path]
+		if !ok {
+			locationID, ok := m.locationToID[location]
+			if !ok {
+				locationID = len(m.locations)
+				m.locationToID[location] = locationID
+				m.locations = append(m.locations, location)
+			}
+			nodeID = len(m.nodes)
+			m.pathToNodeID[path] = nodeID
+			m.nodes = append(m.nodes, diffNode{locationID: locationID, parentNodeID: parentID})
+		}
+		m.nodes[nodeID].values[valueOffset] += nodes[i][2]
+		m.nodes[nodeID].values[valueOffset+1] += nodes[i][3]
+		mergedIDs[i] = nodeID
+		return nodeID
+	}
+
+	// the root node has no parent, make sure it is merged first
+	for i, node := range nodes {
+		if node[1] < 0 {
+			addNode(i)
+		}
+	}
+	for i := range nodes {
+		addNode(i)
+	}
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/deepflowio/deepflow/server/querier/profile/model"
+)
+
+func newTestProfileTree(functions []string, nodes [][]int) *model.ProfileTree {
+	tree := &model.ProfileTree{Functions: functions}
+	tree.NodeValues.Columns = []string{"function_id", "parent_node_id", "self_value", "total_value"}
+	tree.NodeValues.Values = nodes
+	return tree
+}
+
+func TestDiffProfileTree(t *testing.T) {
+	// root -> a -> b(5), root -> c(5)
+	baseline := newTestProfileTree(
+		[]string{"app", "b", "a", "c"},
+		[][]int{{0, -1, 0, 10}, {1, 2, 5, 5}, {2, 0, 0, 5}, {3, 0, 5, 5}},
+	)
+	// root -> a -> b(12), root -> d(4), nodes are leaf first
+	comparison := newTestProfileTree(
+		[]string{"app", "d", "b", "a"},
+		[][]int{{0, -1, 0, 16}, {1, 0, 4, 4}, {2, 3, 12, 12}, {3, 0, 0, 12}},
+	)
+
+	result := DiffProfileTree(baseline, comparison, "on-cpu", false)
+	if !reflect.DeepEqual(result.Functions, []string{"app", "d", "a", "b", "c"}) {
+		t.Fatalf("unexpected functions %v", result.Functions)
+	}
+	expected := [][]int{
+		{0, -1, 0, 16, 0, 6}, // app
+		{1, 0, 4, 4, 4, 4},   // app;d
+		{2, 0, 0, 12, 0, 7},  // app;a
+		{3, 2, 12, 12, 7, 7}, // app;a;b
+		{4, 0, 0, 0, -5, -5}, // app;c
+	}
+	if !reflect.DeepEqual(result.NodeValues.Values, expected) {
+		t.Fatalf("unexpected node values %v", result.NodeValues.Values)
+	}
+	if result.FunctionValues.Values[3][2] != 7 || result.FunctionValues.Values[4][3] != -5 {
+		t.Fatalf("unexpected function values %v", result.FunctionValues.Values)
+	}
+
+	// baseline is scaled by 16/10
+	result = DiffProfileTree(baseline, comparison, "on-cpu", true)
+	if delta := result.NodeValues.Values[3][4]; delta != 12-8 {
+		t.Fatalf("unexpected normalized self delta %d", delta)
+	}
+
+	if result := DiffProfileTree(&model.ProfileTree{}, &model.ProfileTree{}, "on-cpu", false); len(result.Functions) != 0 {
+		t.Fatalf("unexpected result of empty trees %v", result)
+	}
+}
@@ -0,0 +1,145 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"sort"
+
+	"github.com/deepflowio/deepflow/server/querier/profile/common"
+	"github.com/deepflowio/deepflow/server/querier/profile/model"
+)
+
+/*
+convert the profile tree to the flamebearer format of Pyroscope, every level is
+a list of [x_offset, total, self, name_index] quadruples, x_offset is the delta
+to the end of the previous node in the same level, such as:
+
+	names:  ["total", "main", "foo", "bar"]
+	levels: [
+		[0, 100, 0, 0],
+		[0, 100, 10, 1],
+		[0, 60, 60, 3, 0, 30, 30, 2],
+	]
+
+the same levels are used by FlameGraph of querier.v1.SelectMergeStacktraces
+*/
+func NewFlamebearer(tree *model.ProfileTree, maxNodes int) model.Flamebearer {
+	nodes := tree.NodeValues.Values
+	if len(nodes) == 0 {
+		return model.Flamebearer{
+			Names:  []string{common.PYROSCOPE_ROOT_NAME},
+			Levels: [][]int64{{0, 0, 0, 0}},
+		}
+	}
+
+	// columns of node_values: ["function_id", "parent_node_id", "self_value", "total_value"]
+	children := make([][]int, len(nodes))
+	for i := 1; i < len(nodes); i++ {
+		parent := nodes[i][1]
+		children[parent] = append(children[parent], i)
+	}
+	for i := range children {
+		c := children[i]
+		sort.Slice(c, func(a, b int) bool {
+			return tree.Functions[nodes[c[a]][0]] < tree.Functions[nodes[c[b]][0]]
+		})
+	}
+
+	w := &flamebearerWalker{
+		tree:      tree,
+		children:  children,
+		nameIndex: map[string]int64{},
+	}
+	// only the top maxNodes nodes are kept, the others are merged into `other`
+	if maxNodes > 0 && len(nodes) > maxNodes {
+		totals := make([]int, len(nodes))
+		for i, node := range nodes {
+			totals[i] = node[3]
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(totals)))
+		w.minValue = totals[maxNodes-1]
+	}
+	w.walk(common.PYROSCOPE_ROOT_NAME, nodes[0][3], nodes[0][2], children[0], 0, 0)
+
+	return model.Flamebearer{
+		Names:    w.names,
+		Levels:   w.levels,
+		NumTicks: int64(nodes[0][3]),
+		MaxSelf:  w.maxSelf,
+	}
+}
+
+func NewFlameGraph(tree *model.ProfileTree, maxNodes int) *model.FlameGraph {
+	fb := NewFlamebearer(tree, maxNodes)
+	flameGraph := &model.FlameGraph{
+		Names:   fb.Names,
+		Levels:  make([]model.FlameGraphLevel, len(fb.Levels)),
+		Total:   fb.NumTicks,
+		MaxSelf: fb.MaxSelf,
+	}
+	for i, level := range fb.Levels {
+		flameGraph.Levels[i].Values = level
+	}
+	return flameGraph
+}
+
+type flamebearerWalker struct {
+	tree      *model.ProfileTree
+	children  [][]int
+	minValue  int
+	names     []string
+	nameIndex map[string]int64
+	levels    [][]int64
+	levelEnds []int64
+	maxSelf   int64
+}
+
+func (w *flamebearerWalker) getNameIndex(name string) int64 {
+	index, ok := w.nameIndex[name]
+	if !ok {
+		index = int64(len(w.names))
+		w.nameIndex[name] = index
+		w.names = append(w.names, name)
+	}
+	return index
+}
+
+func (w *flamebearerWalker) walk(name string, total, self int, children []int, level int, x int64) {
+	if len(w.levels) <= level {
+		w.levels = append(w.levels, []int64{})
+		w.levelEnds = append(w.levelEnds, 0)
+	}
+	w.levels[level] = append(w.levels[level], x-w.levelEnds[level], int64(total), int64(self), w.getNameIndex(name))
+	w.levelEnds[level] = x + int64(total)
+	if int64(self) > w.maxSelf {
+		w.maxSelf = int64(self)
+	}
+
+	other := 0
+	for _, child := range children {
+		node := w.tree.NodeValues.Values[child]
+		if node[3] < w.minValue {
+			other += node[3]
+			continue
+		}
+		w.walk(w.tree.Functions[node[0]], node[3], node[2], w.children[child], level+1, x)
+		x += int64(node[3])
+	}
+	if other > 0 {
+		w.walk(common.PYROSCOPE_OTHER_NAME, other, other, nil, level+1, x)
+	}
+}
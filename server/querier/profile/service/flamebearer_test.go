@@ -0,0 +1,65 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/deepflowio/deepflow/server/querier/profile/model"
+)
+
+func TestNewFlamebearer(t *testing.T) {
+	// root -> a -> b(5), root -> c(5)
+	tree := newTestProfileTree(
+		[]string{"app", "b", "c", "a"},
+		[][]int{{0, -1, 0, 10}, {1, 3, 5, 5}, {2, 0, 5, 5}, {3, 0, 0, 5}},
+	)
+	fb := NewFlamebearer(tree, 0)
+	if !reflect.DeepEqual(fb.Names, []string{"total", "a", "b", "c"}) {
+		t.Fatalf("unexpected names %v", fb.Names)
+	}
+	expected := [][]int64{
+		{0, 10, 0, 0},
+		{0, 5, 0, 1, 0, 5, 5, 3},
+		{0, 5, 5, 2},
+	}
+	if !reflect.DeepEqual(fb.Levels, expected) {
+		t.Fatalf("unexpected levels %v", fb.Levels)
+	}
+	if fb.NumTicks != 10 || fb.MaxSelf != 5 {
+		t.Fatalf("unexpected numTicks %d or maxSelf %d", fb.NumTicks, fb.MaxSelf)
+	}
+
+	// root -> a(8), root -> c(1), root -> d(1), c and d are merged into other
+	tree = newTestProfileTree(
+		[]string{"app", "a", "c", "d"},
+		[][]int{{0, -1, 0, 10}, {1, 0, 8, 8}, {2, 0, 1, 1}, {3, 0, 1, 1}},
+	)
+	fb = NewFlamebearer(tree, 2)
+	if !reflect.DeepEqual(fb.Names, []string{"total", "a", "other"}) {
+		t.Fatalf("unexpected names %v", fb.Names)
+	}
+	if !reflect.DeepEqual(fb.Levels[1], []int64{0, 8, 8, 1, 0, 2, 2, 2}) {
+		t.Fatalf("unexpected levels %v", fb.Levels)
+	}
+
+	fb = NewFlamebearer(&model.ProfileTree{}, 0)
+	if fb.NumTicks != 0 || !reflect.DeepEqual(fb.Levels, [][]int64{{0, 0, 0, 0}}) {
+		t.Fatalf("unexpected flamebearer of empty tree %v", fb)
+	}
+}
@@ -0,0 +1,142 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"compress/gzip"
+	"io"
+	"strings"
+
+	// generated from github.com/google/pprof/proto/profile.proto
+	pprofpb "github.com/pyroscope-io/pyroscope/pkg/storage/tree"
+
+	"github.com/deepflowio/deepflow/server/querier/profile/model"
+)
+
+// samples with this label are subtracted by `go tool pprof`, the same as -diff_base
+const pprofBaseLabel = "pprof::base"
+
+// PprofEncoder converts profile trees to a gzip'ed pprof profile.proto,
+// every node with self value becomes a sample of its whole stack
+type PprofEncoder struct {
+	stringToIndex map[string]int64
+	functionToID  map[string]uint64
+	profile       pprofpb.Profile
+}
+
+func NewPprofEncoder(profileEventType string) *PprofEncoder {
+	e := &PprofEncoder{
+		stringToIndex: map[string]int64{"": 0},
+		functionToID:  make(map[string]uint64),
+	}
+	e.profile.StringTable = []string{""}
+	valueType := &pprofpb.ValueType{
+		Type: e.stringIndex(profileEventType),
+		Unit: e.stringIndex(getPprofSampleUnit(profileEventType)),
+	}
+	e.profile.SampleType = []*pprofpb.ValueType{valueType}
+	e.profile.PeriodType = valueType
+	return e
+}
+
+// AddTree appends samples of tree, values of a base tree are multiplied by ratio and negated
+func (e *PprofEncoder) AddTree(tree *model.ProfileTree, base bool, ratio float64) {
+	var labels []*pprofpb.Label
+	if base {
+		labels = []*pprofpb.Label{{Key: e.stringIndex(pprofBaseLabel), Str: e.stringIndex("true")}}
+	}
+	nodes := tree.NodeValues.Values
+	for _, node := range nodes {
+		// node_values columns: ["function_id", "parent_node_id", "self_value", "total_value", ...]
+		if node[2] == 0 || node[1] < 0 {
+			continue
+		}
+		value := int64(scaleValue(node[2], ratio))
+		if value == 0 {
+			continue
+		}
+		if base {
+			value = -value
+		}
+		var locationIDs []uint64
+		// from leaf to root, the root node (app_service) is not a real function
+		for n := node; n[1] >= 0 && n[1] < len(nodes); n = nodes[n[1]] {
+			locationIDs = append(locationIDs, e.functionID(tree.Functions[n[0]]))
+		}
+		e.profile.Sample = append(e.profile.Sample, &pprofpb.Sample{LocationId: locationIDs, Value: []int64{value}, Label: labels})
+	}
+}
+
+// functionID returns the id of name, one location with a single line is added
+// for each function, they share the same id
+func (e *PprofEncoder) functionID(name string) uint64 {
+	if id, ok := e.functionToID[name]; ok {
+		return id
+	}
+	id := uint64(len(e.profile.Function) + 1)
+	nameIndex := e.stringIndex(name)
+	e.profile.Function = append(e.profile.Function, &pprofpb.Function{Id: id, Name: nameIndex, SystemName: nameIndex})
+	e.profile.Location = append(e.profile.Location, &pprofpb.Location{Id: id, Line: []*pprofpb.Line{{FunctionId: id}}})
+	e.functionToID[name] = id
+	return id
+}
+
+func (e *PprofEncoder) stringIndex(s string) int64 {
+	if index, ok := e.stringToIndex[s]; ok {
+		return index
+	}
+	index := int64(len(e.profile.StringTable))
+	e.profile.StringTable = append(e.profile.StringTable, s)
+	e.stringToIndex[s] = index
+	return index
+}
+
+// WriteTo writes the gzip'ed profile, timeStart and timeEnd are in seconds
+func (e *PprofEncoder) WriteTo(w io.Writer, timeStart, timeEnd int) error {
+	b, err := e.Encode(timeStart, timeEnd)
+	if err != nil {
+		return err
+	}
+	gw := gzip.NewWriter(w)
+	if _, err := gw.Write(b); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// Encode returns the uncompressed profile.proto
+func (e *PprofEncoder) Encode(timeStart, timeEnd int) ([]byte, error) {
+	e.profile.TimeNanos = int64(timeStart) * 1e9
+	e.profile.DurationNanos = 0
+	if timeEnd > timeStart {
+		e.profile.DurationNanos = int64(timeEnd-timeStart) * 1e9
+	}
+	return e.profile.MarshalVT()
+}
+
+func getPprofSampleUnit(profileEventType string) string {
+	switch {
+	case profileEventType == "cpu":
+		return "nanoseconds"
+	case profileEventType == "off-cpu":
+		return "microseconds"
+	case strings.HasPrefix(profileEventType, "mem-") || strings.HasSuffix(profileEventType, "_space"):
+		return "bytes"
+	default:
+		return "count"
+	}
+}
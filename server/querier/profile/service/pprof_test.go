@@ -0,0 +1,92 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	pprofpb "github.com/pyroscope-io/pyroscope/pkg/storage/tree"
+)
+
+func TestPprofEncoder(t *testing.T) {
+	tree := newTestProfileTree(
+		[]string{"app", "b", "a", "c"},
+		[][]int{{0, -1, 0, 10}, {1, 2, 5, 5}, {2, 0, 0, 5}, {3, 0, 5, 5}},
+	)
+	encoder := NewPprofEncoder("on-cpu")
+	encoder.AddTree(tree, false, 1.0)
+	encoder.AddTree(tree, true, 1.0)
+
+	var buf bytes.Buffer
+	if err := encoder.WriteTo(&buf, 100, 160); err != nil {
+		t.Fatal(err)
+	}
+	gr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(gr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	profile := &pprofpb.Profile{}
+	if err := profile.UnmarshalVT(b); err != nil {
+		t.Fatal(err)
+	}
+	// 2 samples (b and c) of each tree, the root is not a function
+	if len(profile.Sample) != 4 || len(profile.Location) != 3 || len(profile.Function) != 3 {
+		t.Fatalf("unexpected profile %v", profile)
+	}
+	if len(profile.StringTable) == 0 || profile.StringTable[0] != "" {
+		t.Fatalf("string_table[0] must be empty, got %v", profile.StringTable)
+	}
+	if profile.TimeNanos != 100e9 || profile.DurationNanos != 60e9 {
+		t.Fatalf("unexpected time %d and duration %d", profile.TimeNanos, profile.DurationNanos)
+	}
+	base := profile.Sample[2]
+	if len(base.Label) != 1 || profile.StringTable[base.Label[0].Key] != pprofBaseLabel || base.Value[0] != -5 {
+		t.Fatalf("unexpected base sample %v", base)
+	}
+}
+
+func TestPprofEncoderNormalize(t *testing.T) {
+	baseline := newTestProfileTree(
+		[]string{"app", "b", "a", "c"},
+		[][]int{{0, -1, 0, 10}, {1, 2, 5, 5}, {2, 0, 0, 5}, {3, 0, 5, 5}},
+	)
+	comparison := newTestProfileTree(
+		[]string{"app", "b", "a"},
+		[][]int{{0, -1, 0, 16}, {1, 2, 16, 16}, {2, 0, 0, 16}},
+	)
+	encoder := NewPprofEncoder("on-cpu")
+	encoder.AddTree(comparison, false, 1.0)
+	encoder.AddTree(baseline, true, NormalizeRatio(baseline, comparison))
+
+	// baseline is scaled by 16/10
+	values := []int64{}
+	for _, sample := range encoder.profile.Sample {
+		values = append(values, sample.Value[0])
+	}
+	if !reflect.DeepEqual(values, []int64{16, -8, -8}) {
+		t.Fatalf("unexpected sample values %v", values)
+	}
+}
@@ -0,0 +1,564 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	querier_common "github.com/deepflowio/deepflow/server/querier/common"
+	"github.com/deepflowio/deepflow/server/querier/config"
+	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse"
+	tagdescription "github.com/deepflowio/deepflow/server/querier/engine/clickhouse/tag"
+	"github.com/deepflowio/deepflow/server/querier/profile/common"
+	"github.com/deepflowio/deepflow/server/querier/profile/model"
+)
+
+// tags which could not be used as a label name of Pyroscope (e.g.: k8s.label.xxx) are not exported
+var pyroscopeLabelNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// PyroscopeQuerier serves the Pyroscope query API with the profile.in_process table
+type PyroscopeQuerier struct {
+	cfg   *config.QuerierConfig
+	ctx   context.Context
+	orgID string
+}
+
+func NewPyroscopeQuerier(cfg *config.QuerierConfig, ctx context.Context, orgID string) *PyroscopeQuerier {
+	return &PyroscopeQuerier{cfg: cfg, ctx: ctx, orgID: orgID}
+}
+
+// profile type ID: <language>:<event_type>:<sample_unit>:<event_type>:<sample_unit>, e.g.: Golang:cpu:nanoseconds:cpu:nanoseconds
+func newPyroscopeProfileType(languageType, eventType string) model.ProfileType {
+	unit := getPprofSampleUnit(eventType)
+	return model.ProfileType{
+		ID:         fmt.Sprintf("%s:%s:%s:%s:%s", languageType, eventType, unit, eventType, unit),
+		Name:       languageType,
+		SampleType: eventType,
+		SampleUnit: unit,
+		PeriodType: eventType,
+		PeriodUnit: unit,
+	}
+}
+
+func parsePyroscopeProfileTypeID(id string) (languageType, eventType string, err error) {
+	parts := strings.Split(id, ":")
+	if len(parts) != 5 || parts[0] == "" || parts[1] == "" {
+		return "", "", NewError(common.INVALID_PARAMETERS, fmt.Sprintf("invalid profile type id: %s", id))
+	}
+	return parts[0], parts[1], nil
+}
+
+func pyroscopeLabelToTag(name string) string {
+	if name == common.PYROSCOPE_LABEL_SERVICE_NAME {
+		return "app_service"
+	}
+	return name
+}
+
+func escapePyroscopeValue(v string) string {
+	return strings.Replace(v, "'", "''", -1)
+}
+
+// convert label selector such as {service_name="x", pod=~"y.*"} to querier filters
+func parsePyroscopeSelector(selector string) ([]string, error) {
+	selector = strings.TrimSpace(selector)
+	if selector == "" || selector == "{}" {
+		return nil, nil
+	}
+	matchers, err := parser.ParseMetricSelector(selector)
+	if err != nil {
+		return nil, NewError(common.INVALID_PARAMETERS, fmt.Sprintf("invalid label selector %s: %s", selector, err))
+	}
+	filters := make([]string, 0, len(matchers))
+	for _, matcher := range matchers {
+		if matcher.Name == labels.MetricName {
+			continue
+		}
+		var operation string
+		switch matcher.Type {
+		case labels.MatchEqual:
+			operation = "="
+		case labels.MatchNotEqual:
+			operation = "!="
+		case labels.MatchRegexp:
+			operation = "REGEXP"
+		case labels.MatchNotRegexp:
+			operation = "NOT REGEXP"
+		}
+		value := matcher.Value
+		if matcher.Type == labels.MatchRegexp || matcher.Type == labels.MatchNotRegexp {
+			// Prometheus regexps are fully anchored
+			value = "^(?:" + value + ")$"
+		}
+		filters = append(filters, fmt.Sprintf("`%s` %s '%s'", pyroscopeLabelToTag(matcher.Name), operation, escapePyroscopeValue(value)))
+	}
+	return filters, nil
+}
+
+func pyroscopeTimeFilters(start, end int64) []string {
+	return []string{fmt.Sprintf("time>=%d", start), fmt.Sprintf("time<=%d", end)}
+}
+
+// start and end in milliseconds
+func (q *PyroscopeQuerier) profileTypeFilters(profileTypeID, labelSelector string, start, end int64) (filters []string, eventType string, err error) {
+	languageType, eventType, err := parsePyroscopeProfileTypeID(profileTypeID)
+	if err != nil {
+		return
+	}
+	labelFilters, err := parsePyroscopeSelector(labelSelector)
+	if err != nil {
+		return
+	}
+	filters = pyroscopeTimeFilters(start/1000, end/1000)
+	filters = append(filters,
+		fmt.Sprintf("profile_language_type='%s'", escapePyroscopeValue(languageType)),
+		fmt.Sprintf("profile_event_type='%s'", escapePyroscopeValue(eventType)),
+	)
+	filters = append(filters, labelFilters...)
+	return
+}
+
+func (q *PyroscopeQuerier) query(sql string) (*querier_common.Result, error) {
+	ckEngine := &clickhouse.CHEngine{DB: common.DATABASE_PROFILE}
+	ckEngine.Init()
+	querierArgs := querier_common.QuerierParams{
+		DB:      common.DATABASE_PROFILE,
+		Sql:     sql,
+		Debug:   "false",
+		Context: q.ctx,
+		ORGID:   q.orgID,
+	}
+	result, debug, err := ckEngine.ExecuteQuery(&querierArgs)
+	if err != nil {
+		log.Errorf("ExecuteQuery failed: %v %v", debug, err)
+		return nil, err
+	}
+	return result, nil
+}
+
+func getColumnIndexes(result *querier_common.Result) map[string]int {
+	indexes := make(map[string]int, len(result.Columns))
+	for i, column := range result.Columns {
+		if name, ok := column.(string); ok {
+			indexes[name] = i
+		}
+	}
+	return indexes
+}
+
+func pyroscopeValueToString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func pyroscopeValueToFloat(value interface{}) float64 {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	case string:
+		f, _ := strconv.ParseFloat(v, 64)
+		return f
+	}
+	return 0
+}
+
+// returns unix seconds
+func pyroscopeValueToTime(value interface{}) int64 {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Unix()
+	case string:
+		if t, err := time.ParseInLocation("2006-01-02 15:04:05", v, time.Local); err == nil {
+			return t.Unix()
+		}
+		i, _ := strconv.ParseInt(v, 10, 64)
+		return i
+	}
+	return int64(pyroscopeValueToFloat(value))
+}
+
+func (q *PyroscopeQuerier) ProfileTypes(args model.ProfileTypesRequest) (*model.ProfileTypesResponse, error) {
+	sql := fmt.Sprintf(
+		"SELECT profile_language_type, profile_event_type FROM %s WHERE %s GROUP BY profile_language_type, profile_event_type",
+		common.TABLE_PROFILE, strings.Join(pyroscopeTimeFilters(int64(args.Start)/1000, int64(args.End)/1000), " AND "),
+	)
+	result, err := q.query(sql)
+	if err != nil {
+		return nil, err
+	}
+	indexes := getColumnIndexes(result)
+	languageIndex, ok1 := indexes["profile_language_type"]
+	eventIndex, ok2 := indexes["profile_event_type"]
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("Not all fields found")
+	}
+	response := &model.ProfileTypesResponse{ProfileTypes: []model.ProfileType{}}
+	for _, value := range result.Values {
+		row, ok := value.([]interface{})
+		if !ok {
+			continue
+		}
+		languageType := pyroscopeValueToString(row[languageIndex])
+		eventType := pyroscopeValueToString(row[eventIndex])
+		if languageType == "" || eventType == "" {
+			continue
+		}
+		response.ProfileTypes = append(response.ProfileTypes, newPyroscopeProfileType(languageType, eventType))
+	}
+	sort.Slice(response.ProfileTypes, func(i, j int) bool {
+		return response.ProfileTypes[i].ID < response.ProfileTypes[j].ID
+	})
+	return response, nil
+}
+
+// label names are the tags of in_process, matchers are ignored
+func (q *PyroscopeQuerier) LabelNames(args model.LabelNamesRequest) (*model.LabelNamesResponse, error) {
+	start, end := int64(args.Start)/1000, int64(args.End)/1000
+	sql := fmt.Sprintf("SHOW tags FROM %s.%s WHERE time >= %d AND time <= %d", common.DATABASE_PROFILE, common.TABLE_PROFILE, start, end)
+	data, err := tagdescription.GetTagDescriptions(common.DATABASE_PROFILE, common.TABLE_PROFILE, sql, "", q.orgID, false, q.ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]struct{}{common.PYROSCOPE_LABEL_SERVICE_NAME: {}}
+	for _, value := range data.Values {
+		row, ok := value.([]interface{})
+		if !ok || len(row) == 0 {
+			continue
+		}
+		name, ok := row[0].(string)
+		if !ok || name == "app_service" || !pyroscopeLabelNameRegexp.MatchString(name) {
+			continue
+		}
+		names[name] = struct{}{}
+	}
+	response := &model.LabelNamesResponse{Names: make([]string, 0, len(names))}
+	for name := range names {
+		response.Names = append(response.Names, name)
+	}
+	sort.Strings(response.Names)
+	return response, nil
+}
+
+func (q *PyroscopeQuerier) LabelValues(args model.LabelValuesRequest) (*model.LabelValuesResponse, error) {
+	if !pyroscopeLabelNameRegexp.MatchString(args.Name) {
+		return nil, NewError(common.INVALID_PARAMETERS, fmt.Sprintf("invalid label name: %s", args.Name))
+	}
+	filters := pyroscopeTimeFilters(int64(args.Start)/1000, int64(args.End)/1000)
+	for _, matcher := range args.Matchers {
+		labelFilters, err := parsePyroscopeSelector(matcher)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, labelFilters...)
+	}
+	tag := pyroscopeLabelToTag(args.Name)
+	sql := fmt.Sprintf(
+		"SELECT `%s` FROM %s WHERE %s GROUP BY `%s` LIMIT %d",
+		tag, common.TABLE_PROFILE, strings.Join(filters, " AND "), tag, common.PYROSCOPE_LABEL_VALUES_LIMIT,
+	)
+	result, err := q.query(sql)
+	if err != nil {
+		return nil, err
+	}
+	response := &model.LabelValuesResponse{Names: []string{}}
+	for _, value := range result.Values {
+		row, ok := value.([]interface{})
+		if !ok || len(row) == 0 {
+			continue
+		}
+		if v := pyroscopeValueToString(row[0]); v != "" {
+			response.Names = append(response.Names, v)
+		}
+	}
+	sort.Strings(response.Names)
+	return response, nil
+}
+
+func (q *PyroscopeQuerier) generateProfile(eventType string, filters []string, start, end int64) (model.ProfileTree, error) {
+	maxKernelStackDepth := common.MAX_KERNEL_STACK_DEPTH_DEFAULT
+	args := model.Profile{
+		AppService:          common.PYROSCOPE_ROOT_NAME,
+		ProfileEventType:    eventType,
+		TimeStart:           int(start),
+		TimeEnd:             int(end),
+		Context:             q.ctx,
+		OrgID:               q.orgID,
+		MaxKernelStackDepth: &maxKernelStackDepth,
+	}
+	tree, _, err := GenerateProfile(args, q.cfg, strings.Join(filters, " AND "))
+	return tree, err
+}
+
+func (q *PyroscopeQuerier) SelectMergeStacktraces(args model.SelectMergeStacktracesRequest) (*model.SelectMergeStacktracesResponse, error) {
+	filters, eventType, err := q.profileTypeFilters(args.ProfileTypeID, args.LabelSelector, int64(args.Start), int64(args.End))
+	if err != nil {
+		return nil, err
+	}
+	tree, err := q.generateProfile(eventType, filters, int64(args.Start)/1000, int64(args.End)/1000)
+	if err != nil {
+		return nil, err
+	}
+	maxNodes := int(args.MaxNodes)
+	if maxNodes <= 0 {
+		maxNodes = common.PYROSCOPE_MAX_NODES_DEFAULT
+	}
+	return &model.SelectMergeStacktracesResponse{Flamegraph: NewFlameGraph(&tree, maxNodes)}, nil
+}
+
+func (q *PyroscopeQuerier) SelectSeries(args model.SelectSeriesRequest) (*model.SelectSeriesResponse, error) {
+	filters, _, err := q.profileTypeFilters(args.ProfileTypeID, args.LabelSelector, int64(args.Start), int64(args.End))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range args.GroupBy {
+		if !pyroscopeLabelNameRegexp.MatchString(name) {
+			return nil, NewError(common.INVALID_PARAMETERS, fmt.Sprintf("invalid group by label: %s", name))
+		}
+	}
+	step := int(math.Ceil(args.Step))
+	if step <= 0 {
+		step = common.PYROSCOPE_TIMELINE_STEP_MIN
+	}
+	series, err := q.selectSeries(filters, args.GroupBy, step, args.Aggregation)
+	if err != nil {
+		return nil, err
+	}
+	return &model.SelectSeriesResponse{Series: series}, nil
+}
+
+func (q *PyroscopeQuerier) selectSeries(filters, groupBy []string, step int, aggregation model.TimeSeriesAggregation) ([]model.Series, error) {
+	function := "Sum"
+	if aggregation == model.TimeSeriesAggregationTypeAverage {
+		function = "Avg"
+	}
+	groupByTags := ""
+	for _, name := range groupBy {
+		groupByTags += fmt.Sprintf(", `%s`", pyroscopeLabelToTag(name))
+	}
+	sql := fmt.Sprintf(
+		"SELECT %s(%s) AS value, time(time, %d) AS timestamp%s FROM %s WHERE %s GROUP BY timestamp%s LIMIT %d",
+		function, common.PROFILE_VALUE, step, groupByTags, common.TABLE_PROFILE, strings.Join(filters, " AND "), groupByTags, q.cfg.Profile.FlameQueryLimit,
+	)
+	result, err := q.query(sql)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes := getColumnIndexes(result)
+	valueIndex, ok1 := indexes["value"]
+	timestampIndex, ok2 := indexes["timestamp"]
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("Not all fields found")
+	}
+	labelIndexes := make([]int, len(groupBy))
+	for i, name := range groupBy {
+		index, ok := indexes[pyroscopeLabelToTag(name)]
+		if !ok {
+			return nil, fmt.Errorf("field %s not found", name)
+		}
+		labelIndexes[i] = index
+	}
+
+	seriesIndexes := map[string]int{}
+	series := []model.Series{}
+	for _, value := range result.Values {
+		row, ok := value.([]interface{})
+		if !ok {
+			continue
+		}
+		labelPairs := make([]model.LabelPair, len(groupBy))
+		keys := make([]string, len(groupBy))
+		for i, name := range groupBy {
+			labelPairs[i] = model.LabelPair{Name: name, Value: pyroscopeValueToString(row[labelIndexes[i]])}
+			keys[i] = labelPairs[i].Value
+		}
+		key := strings.Join(keys, "\x00")
+		index, ok := seriesIndexes[key]
+		if !ok {
+			index = len(series)
+			seriesIndexes[key] = index
+			series = append(series, model.Series{Labels: labelPairs})
+		}
+		series[index].Points = append(series[index].Points, model.Point{
+			Value:     pyroscopeValueToFloat(row[valueIndex]),
+			Timestamp: pyroscopeValueToTime(row[timestampIndex]) * 1000,
+		})
+	}
+	for i := range series {
+		points := series[i].Points
+		sort.Slice(points, func(a, b int) bool { return points[a].Timestamp < points[b].Timestamp })
+	}
+	return series, nil
+}
+
+// parse time of the render API: now, now-1h, now-7d, unix seconds or milliseconds
+func ParsePyroscopeTime(s string) (int64, error) {
+	return parsePyroscopeTime(s, time.Now())
+}
+
+func parsePyroscopeTime(s string, now time.Time) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "now" {
+		return now.Unix(), nil
+	}
+	if strings.HasPrefix(s, "now-") {
+		d := s[len("now-"):]
+		unit := time.Duration(0)
+		switch {
+		case strings.HasSuffix(d, "d"):
+			unit = 24 * time.Hour
+		case strings.HasSuffix(d, "w"):
+			unit = 7 * 24 * time.Hour
+		}
+		if unit != 0 {
+			n, err := strconv.Atoi(d[:len(d)-1])
+			if err != nil {
+				return 0, fmt.Errorf("invalid time %s", s)
+			}
+			return now.Add(-time.Duration(n) * unit).Unix(), nil
+		}
+		duration, err := time.ParseDuration(d)
+		if err != nil {
+			return 0, fmt.Errorf("invalid time %s", s)
+		}
+		return now.Add(-duration).Unix(), nil
+	}
+	t, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %s", s)
+	}
+	if t > 1e12 {
+		t /= 1000
+	}
+	return t, nil
+}
+
+// parse query of the render API: <app_service>.<profile_event_type>{<label selector>}
+func parsePyroscopeRenderQuery(query string) (appService, eventType, selector string, err error) {
+	name := query
+	if i := strings.Index(query, "{"); i >= 0 {
+		name, selector = query[:i], query[i:]
+	}
+	name = strings.TrimSpace(name)
+	i := strings.LastIndex(name, ".")
+	if i <= 0 || i == len(name)-1 {
+		err = NewError(common.INVALID_PARAMETERS, fmt.Sprintf("invalid query %s, should be <app>.<profile_type>{<labels>}", query))
+		return
+	}
+	return name[:i], name[i+1:], selector, nil
+}
+
+func (q *PyroscopeQuerier) Render(args model.PyroscopeRender) (*model.FlamebearerProfile, error) {
+	appService, eventType, selector, err := parsePyroscopeRenderQuery(args.Query)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if args.From == "" {
+		args.From = "now-1h"
+	}
+	if args.Until == "" {
+		args.Until = "now"
+	}
+	start, err := parsePyroscopeTime(args.From, now)
+	if err != nil {
+		return nil, NewError(common.INVALID_PARAMETERS, err.Error())
+	}
+	end, err := parsePyroscopeTime(args.Until, now)
+	if err != nil {
+		return nil, NewError(common.INVALID_PARAMETERS, err.Error())
+	}
+	if start > end {
+		return nil, NewError(common.INVALID_PARAMETERS, "from should be earlier than until")
+	}
+
+	labelFilters, err := parsePyroscopeSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	filters := pyroscopeTimeFilters(start, end)
+	filters = append(filters,
+		fmt.Sprintf("app_service='%s'", escapePyroscopeValue(appService)),
+		fmt.Sprintf("profile_event_type='%s'", escapePyroscopeValue(eventType)),
+	)
+	filters = append(filters, labelFilters...)
+
+	tree, err := q.generateProfile(eventType, filters, start, end)
+	if err != nil {
+		return nil, err
+	}
+	maxNodes := args.MaxNodes
+	if maxNodes <= 0 {
+		maxNodes = common.PYROSCOPE_MAX_NODES_DEFAULT
+	}
+
+	step := (end - start + common.PYROSCOPE_TIMELINE_POINTS - 1) / common.PYROSCOPE_TIMELINE_POINTS
+	if step < common.PYROSCOPE_TIMELINE_STEP_MIN {
+		step = common.PYROSCOPE_TIMELINE_STEP_MIN
+	}
+	series, err := q.selectSeries(filters, nil, int(step), model.TimeSeriesAggregationTypeSum)
+	if err != nil {
+		return nil, err
+	}
+	timeline := &model.FlamebearerTimeline{
+		StartTime:     start / step * step,
+		DurationDelta: step,
+	}
+	timeline.Samples = make([]int64, (end-timeline.StartTime)/step+1)
+	for _, s := range series {
+		for _, point := range s.Points {
+			i := (point.Timestamp/1000 - timeline.StartTime) / step
+			if i >= 0 && i < int64(len(timeline.Samples)) {
+				timeline.Samples[i] += int64(point.Value)
+			}
+		}
+	}
+
+	return &model.FlamebearerProfile{
+		Version:     1,
+		Flamebearer: NewFlamebearer(&tree, maxNodes),
+		Metadata: model.FlamebearerMeta{
+			Format:     "single",
+			SampleRate: 100,
+			Units:      getPprofSampleUnit(eventType),
+			Name:       eventType,
+		},
+		Timeline: timeline,
+	}, nil
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParsePyroscopeSelector(t *testing.T) {
+	filters, err := parsePyroscopeSelector(`{service_name="a'b", pod_ns!="x", pod=~"web-.*", host!~"h1|h2"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"`app_service` = 'a''b'",
+		"`pod_ns` != 'x'",
+		"`pod` REGEXP '^(?:web-.*)$'",
+		"`host` NOT REGEXP '^(?:h1|h2)$'",
+	}
+	if !reflect.DeepEqual(filters, expected) {
+		t.Fatalf("unexpected filters %v", filters)
+	}
+	if filters, err := parsePyroscopeSelector("{}"); err != nil || len(filters) != 0 {
+		t.Fatalf("unexpected filters %v of empty selector, err: %v", filters, err)
+	}
+	if _, err := parsePyroscopeSelector(`{pod=}`); err == nil {
+		t.Fatal("invalid selector should fail")
+	}
+}
+
+func TestParsePyroscopeRenderQuery(t *testing.T) {
+	app, eventType, selector, err := parsePyroscopeRenderQuery(`my.app.on-cpu{pod="x"}`)
+	if err != nil || app != "my.app" || eventType != "on-cpu" || selector != `{pod="x"}` {
+		t.Fatalf("unexpected result %s %s %s, err: %v", app, eventType, selector, err)
+	}
+	if _, _, _, err := parsePyroscopeRenderQuery("app"); err == nil {
+		t.Fatal("query without profile type should fail")
+	}
+}
+
+func TestParsePyroscopeTime(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	cases := map[string]int64{
+		"now":           1700000000,
+		"now-1h":        1700000000 - 3600,
+		"now-7d":        1700000000 - 7*86400,
+		"1690000000":    1690000000,
+		"1690000000123": 1690000000,
+	}
+	for s, expected := range cases {
+		if v, err := parsePyroscopeTime(s, now); err != nil || v != expected {
+			t.Errorf("parse %s: got %d, expected %d, err: %v", s, v, expected, err)
+		}
+	}
+	if _, err := parsePyroscopeTime("yesterday", now); err == nil {
+		t.Error("invalid time should fail")
+	}
+}
+
+func TestPyroscopeProfileType(t *testing.T) {
+	profileType := newPyroscopeProfileType("Golang", "cpu")
+	if profileType.ID != "Golang:cpu:nanoseconds:cpu:nanoseconds" {
+		t.Fatalf("unexpected profile type id %s", profileType.ID)
+	}
+	languageType, eventType, err := parsePyroscopeProfileTypeID(profileType.ID)
+	if err != nil || languageType != "Golang" || eventType != "cpu" {
+		t.Fatalf("unexpected result %s %s, err: %v", languageType, eventType, err)
+	}
+	if _, _, err := parsePyroscopeProfileTypeID("cpu"); err == nil {
+		t.Fatal("invalid profile type id should fail")
+	}
+}
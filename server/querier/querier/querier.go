@@ -17,6 +17,7 @@
 package querier
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -37,6 +38,7 @@ import (
 	"github.com/deepflowio/deepflow/server/querier/common"
 	"github.com/deepflowio/deepflow/server/querier/config"
 	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse/trans_prometheus"
+	pcap_router "github.com/deepflowio/deepflow/server/querier/pcap/router"
 	profile_router "github.com/deepflowio/deepflow/server/querier/profile/router"
 	"github.com/deepflowio/deepflow/server/querier/router"
 	"github.com/deepflowio/deepflow/server/querier/statsd"
@@ -45,7 +47,7 @@ import (
 
 var log = logging.MustGetLogger("querier")
 
-func Start(configPath, serverLogFile string, shared *servercommon.ControllerIngesterShared) {
+func Start(ctx context.Context, configPath, serverLogFile string, shared *servercommon.ControllerIngesterShared) {
 	ServerCfg := config.DefaultConfig()
 	ServerCfg.Load(configPath)
 	config.Cfg = &ServerCfg.QuerierConfig
@@ -90,7 +92,9 @@ func Start(configPath, serverLogFile string, shared *servercommon.ControllerInge
 	r.Use(ErrHandle())
 	router.QueryRouter(r)
 	profile_router.ProfileRouter(r, &cfg)
-	prometheus_router.PrometheusRouter(r)
+	profile_router.PyroscopeRouter(r, &cfg)
+	pcap_router.PcapRouter(r, &cfg)
+	prometheus_router.PrometheusRouter(ctx, r)
 	tracing_adapter.TracingAdapterRouter(r)
 	distributed_tracing.TraceMapRouter(r, &cfg, tracemap_generator)
 	registerRouterCounter(r.Routes())
@@ -66,6 +66,7 @@ func tempoSearchReader() gin.HandlerFunc {
 		args := common.TempoParams{
 			MinDuration: c.Query("minDuration"),
 			MaxDuration: c.Query("maxDuration"),
+			Query:       c.Query("q"),
 			Limit:       c.Query("limit"),
 			StartTime:   c.Query("start"),
 			EndTime:     c.Query("end"),
@@ -17,12 +17,73 @@
 package service
 
 import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/deepflowio/deepflow/server/querier/cache"
 	"github.com/deepflowio/deepflow/server/querier/common"
+	"github.com/deepflowio/deepflow/server/querier/config"
 	"github.com/deepflowio/deepflow/server/querier/engine"
 	"github.com/deepflowio/deepflow/server/querier/engine/clickhouse"
+	"github.com/deepflowio/deepflow/server/querier/statsd"
+)
+
+var (
+	queryCache     *cache.QueryCache
+	queryCacheOnce sync.Once
 )
 
+func getQueryCache() *cache.QueryCache {
+	queryCacheOnce.Do(func() {
+		cfg := config.Cfg.QueryCache
+		queryCache = cache.NewQueryCache(time.Duration(cfg.TTL)*time.Second, cfg.MaxMemory<<20, cfg.MaxItemSize<<20)
+	})
+	return queryCache
+}
+
 func Execute(args *common.QuerierParams) (jsonData map[string]interface{}, debug map[string]interface{}, err error) {
+	if config.Cfg == nil || !config.Cfg.QueryCache.Enabled {
+		return execute(args)
+	}
+	queryCache := getQueryCache()
+	key := cache.QueryCacheKey(args, time.Duration(config.Cfg.QueryCache.TTL)*time.Second)
+	result, status, err := queryCache.Do(key, func() (*cache.QueryResult, error) {
+		// the query is shared by coalesced requests, it must not be canceled with the request
+		// that happens to execute it, only the trace span of the request is kept
+		ctx, cancel := context.WithTimeout(trace.ContextWithSpan(context.Background(), trace.SpanFromContext(args.Context)),
+			time.Duration(config.Cfg.Clickhouse.Timeout)*time.Second)
+		defer cancel()
+		sharedArgs := *args
+		sharedArgs.Context = ctx
+		jsonData, debug, err := execute(&sharedArgs)
+		return &cache.QueryResult{Result: jsonData, Debug: debug}, err
+	})
+	counter := &statsd.ClickhouseCounter{}
+	switch status {
+	case cache.QUERY_CACHE_STATUS_HIT:
+		counter.QueryCacheHit = 1
+	case cache.QUERY_CACHE_STATUS_SHARED:
+		counter.QueryCacheShared = 1
+	default:
+		counter.QueryCacheMiss = 1
+	}
+	statsd.QuerierCounter.WriteQueryCache(counter)
+	if result == nil {
+		return nil, nil, err
+	}
+	// result is a copy owned by this request, debug info belongs to the query which was actually executed
+	debug = result.Debug
+	if debug == nil {
+		debug = make(map[string]interface{}, 1)
+	}
+	debug["query_cache"] = status
+	return result.Result, debug, err
+}
+
+func execute(args *common.QuerierParams) (jsonData map[string]interface{}, debug map[string]interface{}, err error) {
 	db := getDbBy()
 	var engine engine.Engine
 	switch db {
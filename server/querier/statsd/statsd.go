@@ -40,6 +40,10 @@ type ClickhouseCounter struct {
 	ApiTimeAvg   uint64 `statsd:"api_time_avg"`
 	ApiTimeMax   uint64 `statsd:"api_time_max"`
 	ApiCount     uint64 `statsd:"api_count"`
+	// result cache of `/v1/query`
+	QueryCacheHit    uint64 `statsd:"query_cache_hit"`
+	QueryCacheMiss   uint64 `statsd:"query_cache_miss"`
+	QueryCacheShared uint64 `statsd:"query_cache_shared"`
 }
 
 type Counter struct {
@@ -77,6 +81,16 @@ func (c *Counter) WriteApi(qc *ClickhouseCounter) {
 	}()
 }
 
+func (c *Counter) WriteQueryCache(qc *ClickhouseCounter) {
+	go func() {
+		c.writeCkM.Lock()
+		defer c.writeCkM.Unlock()
+		c.ck.QueryCacheHit += qc.QueryCacheHit
+		c.ck.QueryCacheMiss += qc.QueryCacheMiss
+		c.ck.QueryCacheShared += qc.QueryCacheShared
+	}()
+}
+
 func (c *Counter) GetCounter() interface{} {
 	counter := &ClickhouseCounter{}
 	counter, c.ck = c.ck, counter
@@ -60,6 +60,18 @@ var SEARCH_FIELDS = []string{
 	"trace_id as traceID", "app_service as rootServiceName", "endpoint as rootTraceName", "toUnixTimestamp64Micro(start_time) as startTimeUnixNano", "response_duration/1000 as durationMs",
 }
 
+var TRACEQL_SEARCH_FIELDS = []string{
+	"trace_id", "deepflow_span_id", "deepflow_parent_span_id", "app_service", "endpoint", "toUnixTimestamp64Micro(start_time) as startTimeUnixNano", "response_duration",
+}
+
+const (
+	TRACEQL_DEFAULT_LIMIT = 20
+	// max spans fetched for each spanset filter
+	TRACEQL_SPANSET_LIMIT = 10000
+	// max spans fetched to resolve ancestors for the descendant operator
+	TRACEQL_TRACE_SPANS_LIMIT = 100000
+)
+
 var SPAN_ATTRS_MAP = map[string]string{
 	"service.name": L7_FLOW_LOG_SERVICE_NAME,
 	"name":         L7_TRACING_ENDPOINT,
@@ -359,14 +371,16 @@ func TraceSearch(args *common.TempoParams) (resp map[string]interface{}, debug m
 		},
 		"traces": []map[string]interface{}{},
 	}
-	sql := fmt.Sprintf("select %s from %s", strings.Join(SEARCH_FIELDS, ", "), TABLE_NAME_L7_FLOW_LOG)
-	filters := []string{"trace_id != ''"}
-	if args.StartTime != "" {
-		filters = append(filters, fmt.Sprintf("time>=%s", args.StartTime))
-	}
-	if args.EndTime != "" {
-		filters = append(filters, fmt.Sprintf("time<=%s", args.EndTime))
+	if args.Query != "" {
+		traces, debug, err := TraceQLSearch(args)
+		if err != nil {
+			return nil, debug, err
+		}
+		resp["traces"] = traces
+		return resp, debug, nil
 	}
+	sql := fmt.Sprintf("select %s from %s", strings.Join(SEARCH_FIELDS, ", "), TABLE_NAME_L7_FLOW_LOG)
+	filters := traceSearchTimeFilters(args)
 	for _, kv := range args.Filters {
 		key := kv.Key
 		if k, ok := SPAN_ATTRS_MAP[kv.Key]; ok {
@@ -430,6 +444,120 @@ func TraceSearch(args *common.TempoParams) (resp map[string]interface{}, debug m
 	return resp, debug, err
 }
 
+func traceSearchTimeFilters(args *common.TempoParams) []string {
+	filters := []string{"trace_id != ''"}
+	if args.StartTime != "" {
+		filters = append(filters, fmt.Sprintf("time>=%s", args.StartTime))
+	}
+	if args.EndTime != "" {
+		filters = append(filters, fmt.Sprintf("time<=%s", args.EndTime))
+	}
+	return filters
+}
+
+func TraceQLSearch(args *common.TempoParams) (traces []map[string]interface{}, debug map[string]interface{}, err error) {
+	expr, err := ParseTraceQL(args.Query)
+	if err != nil {
+		return nil, nil, err
+	}
+	limit := TRACEQL_DEFAULT_LIMIT
+	if args.Limit != "" {
+		limit, err = strconv.Atoi(args.Limit)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid limit %s", args.Limit)
+		}
+	}
+	evaluator := &TraceQLEvaluator{
+		QuerySpans: func(cond string) ([]*TraceQLSpan, error) {
+			filters := traceSearchTimeFilters(args)
+			if cond != "" {
+				filters = append(filters, cond)
+			}
+			sql := fmt.Sprintf(
+				"select %s from %s WHERE %s ORDER BY startTimeUnixNano desc LIMIT %d",
+				strings.Join(TRACEQL_SEARCH_FIELDS, ", "), TABLE_NAME_L7_FLOW_LOG, strings.Join(filters, " AND "), TRACEQL_SPANSET_LIMIT,
+			)
+			var result []*TraceQLSpan
+			result, debug, err = queryTraceQLSpans(args, sql)
+			return result, err
+		},
+		QueryTraceSpans: func(traceIDs []string) ([]*TraceQLSpan, error) {
+			quoted := make([]string, 0, len(traceIDs))
+			for _, traceID := range traceIDs {
+				quoted = append(quoted, fmt.Sprintf("'%s'", escapeTraceQLString(traceID)))
+			}
+			filters := traceSearchTimeFilters(args)
+			filters = append(filters, fmt.Sprintf("trace_id IN (%s)", strings.Join(quoted, ",")))
+			sql := fmt.Sprintf(
+				"select %s from %s WHERE %s LIMIT %d",
+				strings.Join(TRACEQL_SEARCH_FIELDS, ", "), TABLE_NAME_L7_FLOW_LOG, strings.Join(filters, " AND "), TRACEQL_TRACE_SPANS_LIMIT,
+			)
+			var result []*TraceQLSpan
+			result, debug, err = queryTraceQLSpans(args, sql)
+			return result, err
+		},
+	}
+	spanSets, err := evaluator.Eval(expr)
+	if err != nil {
+		return nil, debug, err
+	}
+	return TraceQLSearchResult(spanSets, limit), debug, nil
+}
+
+func queryTraceQLSpans(args *common.TempoParams, sql string) ([]*TraceQLSpan, map[string]interface{}, error) {
+	querierArgs := common.QuerierParams{
+		DB:         "flow_log",
+		Sql:        sql,
+		DataSource: "",
+		Debug:      args.Debug,
+		QueryUUID:  uuid.New().String(),
+		Context:    args.Context,
+	}
+	ckEngine := &clickhouse.CHEngine{DB: querierArgs.DB, DataSource: querierArgs.DataSource}
+	ckEngine.Init()
+	result, debug, err := ckEngine.ExecuteQuery(&querierArgs)
+	if err != nil {
+		log.Errorf("%v %v", debug, err)
+		return nil, debug, err
+	}
+	spans := make([]*TraceQLSpan, 0, len(result.Values))
+	for _, d := range result.Values {
+		value := d.([]interface{})
+		if len(value) < len(TRACEQL_SEARCH_FIELDS) {
+			continue
+		}
+		spans = append(spans, &TraceQLSpan{
+			TraceID:      fmt.Sprint(value[0]),
+			SpanID:       fmt.Sprint(value[1]),
+			ParentSpanID: fmt.Sprint(value[2]),
+			ServiceName:  fmt.Sprint(value[3]),
+			Name:         fmt.Sprint(value[4]),
+			StartTimeUs:  toInt64(value[5]),
+			DurationUs:   toInt64(value[6]),
+		})
+	}
+	return spans, debug, nil
+}
+
+func toInt64(value interface{}) int64 {
+	switch v := value.(type) {
+	case int:
+		return int64(v)
+	case int64:
+		return v
+	case uint64:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case float64:
+		return int64(v)
+	case string:
+		i, _ := strconv.ParseInt(v, 10, 64)
+		return i
+	}
+	return 0
+}
+
 func decodeIdBytes(id string, length int, idMap map[string][]byte) []byte {
 	idBytes := []byte{}
 	if len(id) == length*2 {
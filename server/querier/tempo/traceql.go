@@ -0,0 +1,905 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tempo
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// TraceQL support for the Tempo search API.
+//
+// Supported syntax:
+//   - spanset filters: { <field expression> }, where field expressions are
+//     comparisons combined with && / || and grouped by parentheses
+//   - attributes: span.<key>, resource.<key>, .<key> and the intrinsics
+//     duration, name, status and kind, resource.<key> only supports the
+//     resource attributes that l7_flow_log keeps in columns, see
+//     TRACEQL_RESOURCE_ATTRS_MAP and TRACEQL_RESOURCE_TAGS_MAP
+//   - comparison operators: = != > >= < <= =~ !~
+//   - spanset operators: && || > (child) >> (descendant), with parentheses
+//
+// Every spanset filter is translated into a WHERE clause of querier SQL on
+// l7_flow_log, spanset operators are evaluated over deepflow_span_id and
+// deepflow_parent_span_id of the matched spans.
+
+const (
+	TRACEQL_SCOPE_NONE      = ""
+	TRACEQL_SCOPE_SPAN      = "span"
+	TRACEQL_SCOPE_RESOURCE  = "resource"
+	TRACEQL_SCOPE_INTRINSIC = "intrinsic"
+
+	TRACEQL_INTRINSIC_DURATION = "duration"
+	TRACEQL_INTRINSIC_NAME     = "name"
+	TRACEQL_INTRINSIC_STATUS   = "status"
+	TRACEQL_INTRINSIC_KIND     = "kind"
+)
+
+// OTel span attributes that DeepFlow stores in native l7_flow_log columns
+var TRACEQL_SPAN_ATTRS_MAP = map[string]string{
+	"http.method":      "request_type",
+	"http.status_code": "response_code",
+	"http.host":        "request_domain",
+	"http.target":      "request_resource",
+}
+
+// OTel resource attributes that DeepFlow stores in native l7_flow_log columns,
+// other resource attributes are mixed with span attributes in attribute_names
+var TRACEQL_RESOURCE_ATTRS_MAP = map[string]string{
+	"service.name":        L7_FLOW_LOG_SERVICE_NAME,
+	"service.instance.id": "app_instance",
+}
+
+// OTel resource attributes that match the universal tags of DeepFlow, the
+// resource of a span is the client side for c-app spans and the server side
+// for the others
+var TRACEQL_RESOURCE_TAGS_MAP = map[string]string{
+	"k8s.cluster.name":   "pod_cluster",
+	"k8s.namespace.name": "pod_ns",
+	"k8s.node.name":      "pod_node",
+	"k8s.pod.name":       "pod",
+}
+
+// native columns that can be compared with > >= < <=
+var TRACEQL_NUMERIC_COLUMNS = map[string]bool{
+	"response_code": true,
+}
+
+// TraceQL status -> values of response_status
+var TRACEQL_STATUS_MAP = map[string][]int{
+	"ok":    {0},
+	"unset": {2},
+	"error": {1, 3, 4},
+}
+
+// TraceQL kind -> values of tap_side
+var TRACEQL_KIND_MAP = map[string]string{
+	"server":   "s-app",
+	"client":   "c-app",
+	"internal": "app",
+}
+
+type traceQLTokenType int
+
+const (
+	tokenEOF traceQLTokenType = iota
+	tokenLBrace
+	tokenRBrace
+	tokenLParen
+	tokenRParen
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenEq
+	tokenNeq
+	tokenGt
+	tokenGte
+	tokenLt
+	tokenLte
+	tokenRegex
+	tokenNotRegex
+	tokenDescendant
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenDuration
+)
+
+type traceQLToken struct {
+	typ traceQLTokenType
+	val string
+	pos int
+}
+
+// two-character operators must be checked before single-character ones
+var traceQLOperators = []struct {
+	text string
+	typ  traceQLTokenType
+}{
+	{"&&", tokenAnd},
+	{"||", tokenOr},
+	{">>", tokenDescendant},
+	{">=", tokenGte},
+	{"<=", tokenLte},
+	{"!=", tokenNeq},
+	{"=~", tokenRegex},
+	{"!~", tokenNotRegex},
+	{"{", tokenLBrace},
+	{"}", tokenRBrace},
+	{"(", tokenLParen},
+	{")", tokenRParen},
+	{"=", tokenEq},
+	{">", tokenGt},
+	{"<", tokenLt},
+	{"!", tokenNot},
+}
+
+var traceQLDurationUnits = []string{"ns", "us", "µs", "ms", "s", "m", "h"}
+
+func isTraceQLIdentChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("._-/:", r)
+}
+
+func lexTraceQL(query string) ([]traceQLToken, error) {
+	tokens := []traceQLToken{}
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		if unicode.IsSpace(r) {
+			i++
+			continue
+		}
+		if r == '"' || r == '`' {
+			value, next, err := lexTraceQLString(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, traceQLToken{typ: tokenString, val: value, pos: i})
+			i = next
+			continue
+		}
+		if unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])) {
+			start := i
+			i++
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			number := string(runes[start:i])
+			unit := ""
+			for i < len(runes) && unicode.IsLetter(runes[i]) {
+				unit += string(runes[i])
+				i++
+			}
+			if unit == "" {
+				tokens = append(tokens, traceQLToken{typ: tokenNumber, val: number, pos: start})
+				continue
+			}
+			if !isTraceQLDurationUnit(unit) {
+				return nil, fmt.Errorf("traceql: invalid duration unit %q at %d", unit, start)
+			}
+			tokens = append(tokens, traceQLToken{typ: tokenDuration, val: number + unit, pos: start})
+			continue
+		}
+		if isTraceQLIdentChar(r) {
+			start := i
+			for i < len(runes) && isTraceQLIdentChar(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, traceQLToken{typ: tokenIdent, val: string(runes[start:i]), pos: start})
+			continue
+		}
+		matched := false
+		for _, op := range traceQLOperators {
+			if strings.HasPrefix(string(runes[i:]), op.text) {
+				tokens = append(tokens, traceQLToken{typ: op.typ, val: op.text, pos: i})
+				i += len([]rune(op.text))
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("traceql: unexpected character %q at %d", r, i)
+		}
+	}
+	tokens = append(tokens, traceQLToken{typ: tokenEOF, pos: len(runes)})
+	return tokens, nil
+}
+
+func lexTraceQLString(runes []rune, start int) (string, int, error) {
+	quote := runes[start]
+	var sb strings.Builder
+	for i := start + 1; i < len(runes); i++ {
+		r := runes[i]
+		if r == quote {
+			return sb.String(), i + 1, nil
+		}
+		if r == '\\' && quote == '"' && i+1 < len(runes) {
+			i++
+			switch runes[i] {
+			case 'n':
+				sb.WriteRune('\n')
+			case 't':
+				sb.WriteRune('\t')
+			default:
+				sb.WriteRune(runes[i])
+			}
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return "", 0, fmt.Errorf("traceql: unterminated string at %d", start)
+}
+
+func isTraceQLDurationUnit(unit string) bool {
+	for _, u := range traceQLDurationUnits {
+		if u == unit {
+			return true
+		}
+	}
+	return false
+}
+
+// TraceQLSpansetExpr is a spanset filter or an operation between spansets
+type TraceQLSpansetExpr interface {
+	String() string
+}
+
+// TraceQLFieldExpr is a condition inside a spanset filter
+type TraceQLFieldExpr interface {
+	ToSQL() (string, error)
+}
+
+type TraceQLSpansetFilter struct {
+	// nil means all spans
+	Cond TraceQLFieldExpr
+}
+
+func (f *TraceQLSpansetFilter) String() string {
+	if f.Cond == nil {
+		return "{}"
+	}
+	sql, _ := f.Cond.ToSQL()
+	return "{" + sql + "}"
+}
+
+type TraceQLSpansetOperation struct {
+	Op  string
+	LHS TraceQLSpansetExpr
+	RHS TraceQLSpansetExpr
+}
+
+func (o *TraceQLSpansetOperation) String() string {
+	return fmt.Sprintf("(%s %s %s)", o.LHS.String(), o.Op, o.RHS.String())
+}
+
+type TraceQLBinaryCond struct {
+	Op  string
+	LHS TraceQLFieldExpr
+	RHS TraceQLFieldExpr
+}
+
+func (b *TraceQLBinaryCond) ToSQL() (string, error) {
+	lhs, err := b.LHS.ToSQL()
+	if err != nil {
+		return "", err
+	}
+	rhs, err := b.RHS.ToSQL()
+	if err != nil {
+		return "", err
+	}
+	op := "AND"
+	if b.Op == "||" {
+		op = "OR"
+	}
+	return fmt.Sprintf("(%s %s %s)", lhs, op, rhs), nil
+}
+
+type TraceQLNotCond struct {
+	Cond TraceQLFieldExpr
+}
+
+func (n *TraceQLNotCond) ToSQL() (string, error) {
+	sql, err := n.Cond.ToSQL()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("NOT (%s)", sql), nil
+}
+
+type TraceQLAttribute struct {
+	Scope string
+	Name  string
+}
+
+type TraceQLValue struct {
+	Type traceQLTokenType
+	Text string
+}
+
+type TraceQLComparison struct {
+	Attr  TraceQLAttribute
+	Op    string
+	Value TraceQLValue
+}
+
+func (c *TraceQLComparison) ToSQL() (string, error) {
+	if c.Attr.Scope == TRACEQL_SCOPE_INTRINSIC {
+		switch c.Attr.Name {
+		case TRACEQL_INTRINSIC_DURATION:
+			return c.durationToSQL()
+		case TRACEQL_INTRINSIC_STATUS:
+			return c.statusToSQL()
+		case TRACEQL_INTRINSIC_KIND:
+			return c.kindToSQL()
+		case TRACEQL_INTRINSIC_NAME:
+			return c.columnToSQL(L7_TRACING_ENDPOINT)
+		}
+		return "", fmt.Errorf("traceql: unsupported intrinsic %s", c.Attr.Name)
+	}
+	if c.Attr.Scope != TRACEQL_SCOPE_RESOURCE {
+		if column, ok := TRACEQL_SPAN_ATTRS_MAP[c.Attr.Name]; ok {
+			return c.columnToSQL(column)
+		}
+	}
+	if c.Attr.Scope != TRACEQL_SCOPE_SPAN {
+		if column, ok := TRACEQL_RESOURCE_ATTRS_MAP[c.Attr.Name]; ok {
+			return c.columnToSQL(column)
+		}
+		if tag, ok := TRACEQL_RESOURCE_TAGS_MAP[c.Attr.Name]; ok {
+			return c.resourceTagToSQL(tag)
+		}
+	}
+	if c.Attr.Scope == TRACEQL_SCOPE_RESOURCE {
+		return "", fmt.Errorf("traceql: resource attribute %s is not supported", c.Attr.Name)
+	}
+	return c.columnToSQL(fmt.Sprintf("`attribute.%s`", c.Attr.Name))
+}
+
+func (c *TraceQLComparison) resourceTagToSQL(tag string) (string, error) {
+	client, err := c.columnToSQL(tag + "_0")
+	if err != nil {
+		return "", err
+	}
+	server, err := c.columnToSQL(tag + "_1")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("((tap_side='%s' AND %s) OR (tap_side!='%s' AND %s))",
+		TRACEQL_KIND_MAP["client"], client, TRACEQL_KIND_MAP["client"], server), nil
+}
+
+func (c *TraceQLComparison) durationToSQL() (string, error) {
+	var us int64
+	switch c.Value.Type {
+	case tokenDuration:
+		d, err := time.ParseDuration(c.Value.Text)
+		if err != nil {
+			return "", fmt.Errorf("traceql: invalid duration %s", c.Value.Text)
+		}
+		us = d.Microseconds()
+	case tokenNumber:
+		// a bare number is taken as nanoseconds, as Tempo does
+		ns, err := strconv.ParseInt(c.Value.Text, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("traceql: invalid duration %s", c.Value.Text)
+		}
+		us = ns / 1000
+	default:
+		return "", fmt.Errorf("traceql: duration must be compared with a duration, got %s", c.Value.Text)
+	}
+	switch c.Op {
+	case "=", "!=", ">", ">=", "<", "<=":
+		return fmt.Sprintf("response_duration%s%d", c.Op, us), nil
+	}
+	return "", fmt.Errorf("traceql: operator %s is not supported on duration", c.Op)
+}
+
+func (c *TraceQLComparison) statusToSQL() (string, error) {
+	codes, ok := TRACEQL_STATUS_MAP[c.Value.Text]
+	if !ok || c.Value.Type != tokenIdent {
+		return "", fmt.Errorf("traceql: invalid status %s", c.Value.Text)
+	}
+	values := make([]string, 0, len(codes))
+	for _, code := range codes {
+		values = append(values, strconv.Itoa(code))
+	}
+	switch c.Op {
+	case "=":
+		return fmt.Sprintf("response_status IN (%s)", strings.Join(values, ",")), nil
+	case "!=":
+		return fmt.Sprintf("response_status NOT IN (%s)", strings.Join(values, ",")), nil
+	}
+	return "", fmt.Errorf("traceql: operator %s is not supported on status", c.Op)
+}
+
+func (c *TraceQLComparison) kindToSQL() (string, error) {
+	tapSide, ok := TRACEQL_KIND_MAP[c.Value.Text]
+	if !ok || c.Value.Type != tokenIdent {
+		return "", fmt.Errorf("traceql: invalid kind %s", c.Value.Text)
+	}
+	switch c.Op {
+	case "=", "!=":
+		return fmt.Sprintf("tap_side%s'%s'", c.Op, tapSide), nil
+	}
+	return "", fmt.Errorf("traceql: operator %s is not supported on kind", c.Op)
+}
+
+func (c *TraceQLComparison) columnToSQL(column string) (string, error) {
+	value := c.Value.Text
+	quoted := fmt.Sprintf("'%s'", escapeTraceQLString(value))
+	switch c.Op {
+	case "=~":
+		return fmt.Sprintf("%s regexp %s", column, quoted), nil
+	case "!~":
+		return fmt.Sprintf("%s not regexp %s", column, quoted), nil
+	case "=", "!=":
+		if TRACEQL_NUMERIC_COLUMNS[column] && c.Value.Type == tokenNumber {
+			return fmt.Sprintf("%s%s%s", column, c.Op, value), nil
+		}
+		return fmt.Sprintf("%s%s%s", column, c.Op, quoted), nil
+	case ">", ">=", "<", "<=":
+		if !TRACEQL_NUMERIC_COLUMNS[column] || c.Value.Type != tokenNumber {
+			return "", fmt.Errorf("traceql: operator %s is not supported on %s", c.Op, column)
+		}
+		return fmt.Sprintf("%s%s%s", column, c.Op, value), nil
+	}
+	return "", fmt.Errorf("traceql: unsupported operator %s", c.Op)
+}
+
+func escapeTraceQLString(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\\\")
+	return strings.ReplaceAll(s, "'", "\\'")
+}
+
+type traceQLParser struct {
+	tokens []traceQLToken
+	pos    int
+}
+
+// ParseTraceQL parses a TraceQL query into a spanset expression
+func ParseTraceQL(query string) (TraceQLSpansetExpr, error) {
+	tokens, err := lexTraceQL(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &traceQLParser{tokens: tokens}
+	expr, err := p.parseSpansetOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.typ != tokenEOF {
+		return nil, fmt.Errorf("traceql: unexpected %q at %d", t.val, t.pos)
+	}
+	return expr, nil
+}
+
+func (p *traceQLParser) peek() traceQLToken {
+	return p.tokens[p.pos]
+}
+
+func (p *traceQLParser) next() traceQLToken {
+	t := p.tokens[p.pos]
+	if t.typ != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *traceQLParser) expect(typ traceQLTokenType, text string) error {
+	t := p.next()
+	if t.typ != typ {
+		if t.typ == tokenEOF {
+			return fmt.Errorf("traceql: expected %q but query ended", text)
+		}
+		return fmt.Errorf("traceql: expected %q but got %q at %d", text, t.val, t.pos)
+	}
+	return nil
+}
+
+// precedence of spanset operators: || < && < structural (> >>)
+func (p *traceQLParser) parseSpansetOr() (TraceQLSpansetExpr, error) {
+	lhs, err := p.parseSpansetAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().typ == tokenOr {
+		p.next()
+		rhs, err := p.parseSpansetAnd()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &TraceQLSpansetOperation{Op: "||", LHS: lhs, RHS: rhs}
+	}
+	return lhs, nil
+}
+
+func (p *traceQLParser) parseSpansetAnd() (TraceQLSpansetExpr, error) {
+	lhs, err := p.parseSpansetStructural()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().typ == tokenAnd {
+		p.next()
+		rhs, err := p.parseSpansetStructural()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &TraceQLSpansetOperation{Op: "&&", LHS: lhs, RHS: rhs}
+	}
+	return lhs, nil
+}
+
+func (p *traceQLParser) parseSpansetStructural() (TraceQLSpansetExpr, error) {
+	lhs, err := p.parseSpansetPrimary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().typ == tokenGt || p.peek().typ == tokenDescendant {
+		op := p.next().val
+		rhs, err := p.parseSpansetPrimary()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &TraceQLSpansetOperation{Op: op, LHS: lhs, RHS: rhs}
+	}
+	return lhs, nil
+}
+
+func (p *traceQLParser) parseSpansetPrimary() (TraceQLSpansetExpr, error) {
+	t := p.next()
+	switch t.typ {
+	case tokenLParen:
+		expr, err := p.parseSpansetOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenRParen, ")"); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	case tokenLBrace:
+		if p.peek().typ == tokenRBrace {
+			p.next()
+			return &TraceQLSpansetFilter{}, nil
+		}
+		cond, err := p.parseFieldOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenRBrace, "}"); err != nil {
+			return nil, err
+		}
+		return &TraceQLSpansetFilter{Cond: cond}, nil
+	case tokenEOF:
+		return nil, fmt.Errorf("traceql: expected spanset but query ended")
+	}
+	return nil, fmt.Errorf("traceql: expected spanset but got %q at %d", t.val, t.pos)
+}
+
+func (p *traceQLParser) parseFieldOr() (TraceQLFieldExpr, error) {
+	lhs, err := p.parseFieldAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().typ == tokenOr {
+		p.next()
+		rhs, err := p.parseFieldAnd()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &TraceQLBinaryCond{Op: "||", LHS: lhs, RHS: rhs}
+	}
+	return lhs, nil
+}
+
+func (p *traceQLParser) parseFieldAnd() (TraceQLFieldExpr, error) {
+	lhs, err := p.parseFieldUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().typ == tokenAnd {
+		p.next()
+		rhs, err := p.parseFieldUnary()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &TraceQLBinaryCond{Op: "&&", LHS: lhs, RHS: rhs}
+	}
+	return lhs, nil
+}
+
+func (p *traceQLParser) parseFieldUnary() (TraceQLFieldExpr, error) {
+	switch p.peek().typ {
+	case tokenNot:
+		p.next()
+		cond, err := p.parseFieldUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &TraceQLNotCond{Cond: cond}, nil
+	case tokenLParen:
+		p.next()
+		cond, err := p.parseFieldOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenRParen, ")"); err != nil {
+			return nil, err
+		}
+		return cond, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *traceQLParser) parseComparison() (TraceQLFieldExpr, error) {
+	t := p.next()
+	if t.typ != tokenIdent {
+		return nil, fmt.Errorf("traceql: expected attribute but got %q at %d", t.val, t.pos)
+	}
+	attr, err := parseTraceQLAttribute(t.val)
+	if err != nil {
+		return nil, err
+	}
+	opToken := p.next()
+	switch opToken.typ {
+	case tokenEq, tokenNeq, tokenGt, tokenGte, tokenLt, tokenLte, tokenRegex, tokenNotRegex:
+	default:
+		return nil, fmt.Errorf("traceql: expected comparison operator after %s at %d", t.val, opToken.pos)
+	}
+	valueToken := p.next()
+	switch valueToken.typ {
+	case tokenString, tokenNumber, tokenDuration, tokenIdent:
+	default:
+		return nil, fmt.Errorf("traceql: expected value after %s at %d", opToken.val, valueToken.pos)
+	}
+	return &TraceQLComparison{
+		Attr:  attr,
+		Op:    opToken.val,
+		Value: TraceQLValue{Type: valueToken.typ, Text: valueToken.val},
+	}, nil
+}
+
+func parseTraceQLAttribute(text string) (TraceQLAttribute, error) {
+	switch {
+	case strings.HasPrefix(text, TRACEQL_SCOPE_SPAN+"."):
+		return TraceQLAttribute{Scope: TRACEQL_SCOPE_SPAN, Name: strings.TrimPrefix(text, TRACEQL_SCOPE_SPAN+".")}, nil
+	case strings.HasPrefix(text, TRACEQL_SCOPE_RESOURCE+"."):
+		return TraceQLAttribute{Scope: TRACEQL_SCOPE_RESOURCE, Name: strings.TrimPrefix(text, TRACEQL_SCOPE_RESOURCE+".")}, nil
+	case strings.HasPrefix(text, ".") && len(text) > 1:
+		return TraceQLAttribute{Scope: TRACEQL_SCOPE_NONE, Name: text[1:]}, nil
+	}
+	switch text {
+	case TRACEQL_INTRINSIC_DURATION, TRACEQL_INTRINSIC_NAME, TRACEQL_INTRINSIC_STATUS, TRACEQL_INTRINSIC_KIND:
+		return TraceQLAttribute{Scope: TRACEQL_SCOPE_INTRINSIC, Name: text}, nil
+	}
+	return TraceQLAttribute{}, fmt.Errorf("traceql: unknown attribute %s, use span., resource. or . scope", text)
+}
+
+// TraceQLSpan is one l7_flow_log row matched by a spanset filter
+type TraceQLSpan struct {
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	ServiceName  string
+	Name         string
+	StartTimeUs  int64
+	DurationUs   int64
+}
+
+// matched spans grouped by trace id
+type TraceQLSpanSets map[string][]*TraceQLSpan
+
+type TraceQLEvaluator struct {
+	// returns the spans matching a querier SQL condition, empty means all spans
+	QuerySpans func(cond string) ([]*TraceQLSpan, error)
+	// returns span id and parent span id of every span of the given traces
+	QueryTraceSpans func(traceIDs []string) ([]*TraceQLSpan, error)
+}
+
+func (e *TraceQLEvaluator) Eval(expr TraceQLSpansetExpr) (TraceQLSpanSets, error) {
+	switch expr := expr.(type) {
+	case *TraceQLSpansetFilter:
+		cond := ""
+		if expr.Cond != nil {
+			var err error
+			if cond, err = expr.Cond.ToSQL(); err != nil {
+				return nil, err
+			}
+		}
+		spans, err := e.QuerySpans(cond)
+		if err != nil {
+			return nil, err
+		}
+		result := TraceQLSpanSets{}
+		for _, span := range spans {
+			result[span.TraceID] = append(result[span.TraceID], span)
+		}
+		return result, nil
+	case *TraceQLSpansetOperation:
+		lhs, err := e.Eval(expr.LHS)
+		if err != nil {
+			return nil, err
+		}
+		rhs, err := e.Eval(expr.RHS)
+		if err != nil {
+			return nil, err
+		}
+		switch expr.Op {
+		case "||":
+			for traceID, spans := range rhs {
+				lhs[traceID] = append(lhs[traceID], spans...)
+			}
+			return lhs, nil
+		case "&&":
+			result := TraceQLSpanSets{}
+			for traceID, spans := range lhs {
+				if rspans, ok := rhs[traceID]; ok {
+					result[traceID] = append(spans, rspans...)
+				}
+			}
+			return result, nil
+		case ">":
+			return e.evalChild(lhs, rhs), nil
+		case ">>":
+			return e.evalDescendant(lhs, rhs)
+		}
+		return nil, fmt.Errorf("traceql: unsupported spanset operator %s", expr.Op)
+	}
+	return nil, fmt.Errorf("traceql: unsupported expression %s", expr.String())
+}
+
+func spanIDSet(spans []*TraceQLSpan) map[string]bool {
+	ids := make(map[string]bool, len(spans))
+	for _, span := range spans {
+		if span.SpanID != "" {
+			ids[span.SpanID] = true
+		}
+	}
+	return ids
+}
+
+// rhs spans whose parent is a lhs span
+func (e *TraceQLEvaluator) evalChild(lhs, rhs TraceQLSpanSets) TraceQLSpanSets {
+	result := TraceQLSpanSets{}
+	for traceID, rspans := range rhs {
+		lspans, ok := lhs[traceID]
+		if !ok {
+			continue
+		}
+		parents := spanIDSet(lspans)
+		for _, span := range rspans {
+			if span.ParentSpanID != "" && parents[span.ParentSpanID] {
+				result[traceID] = append(result[traceID], span)
+			}
+		}
+	}
+	return result
+}
+
+// rhs spans that have a lhs span among their ancestors
+func (e *TraceQLEvaluator) evalDescendant(lhs, rhs TraceQLSpanSets) (TraceQLSpanSets, error) {
+	traceIDs := []string{}
+	for traceID := range rhs {
+		if _, ok := lhs[traceID]; ok {
+			traceIDs = append(traceIDs, traceID)
+		}
+	}
+	result := TraceQLSpanSets{}
+	if len(traceIDs) == 0 {
+		return result, nil
+	}
+	sort.Strings(traceIDs)
+	family, err := e.QueryTraceSpans(traceIDs)
+	if err != nil {
+		return nil, err
+	}
+	// trace id -> span id -> parent span id
+	parentMap := map[string]map[string]string{}
+	for _, span := range family {
+		if span.SpanID == "" || span.ParentSpanID == "" || span.SpanID == span.ParentSpanID {
+			continue
+		}
+		if _, ok := parentMap[span.TraceID]; !ok {
+			parentMap[span.TraceID] = map[string]string{}
+		}
+		parentMap[span.TraceID][span.SpanID] = span.ParentSpanID
+	}
+	for _, traceID := range traceIDs {
+		ancestors := spanIDSet(lhs[traceID])
+		parents := parentMap[traceID]
+		for _, span := range rhs[traceID] {
+			visited := map[string]bool{}
+			parent := span.ParentSpanID
+			for parent != "" && !visited[parent] {
+				if ancestors[parent] {
+					result[traceID] = append(result[traceID], span)
+					break
+				}
+				visited[parent] = true
+				parent = parents[parent]
+			}
+		}
+	}
+	return result, nil
+}
+
+// TraceQLSearchResult converts matched spansets into the traces of Tempo search response,
+// sorted by start time desc and cut off at limit
+func TraceQLSearchResult(spanSets TraceQLSpanSets, limit int) []map[string]interface{} {
+	type matchedTrace struct {
+		root  *TraceQLSpan
+		spans []*TraceQLSpan
+	}
+	traces := make([]*matchedTrace, 0, len(spanSets))
+	for _, spans := range spanSets {
+		if len(spans) == 0 {
+			continue
+		}
+		// deduplicate spans that are captured at multiple tap sides
+		seen := map[string]bool{}
+		unique := make([]*TraceQLSpan, 0, len(spans))
+		for _, span := range spans {
+			key := span.SpanID
+			if key == "" {
+				key = fmt.Sprintf("%s-%d", span.Name, span.StartTimeUs)
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			unique = append(unique, span)
+		}
+		sort.Slice(unique, func(i, j int) bool { return unique[i].StartTimeUs < unique[j].StartTimeUs })
+		traces = append(traces, &matchedTrace{root: unique[0], spans: unique})
+	}
+	sort.Slice(traces, func(i, j int) bool {
+		if traces[i].root.StartTimeUs != traces[j].root.StartTimeUs {
+			return traces[i].root.StartTimeUs > traces[j].root.StartTimeUs
+		}
+		return traces[i].root.TraceID < traces[j].root.TraceID
+	})
+	if limit > 0 && len(traces) > limit {
+		traces = traces[:limit]
+	}
+	result := make([]map[string]interface{}, 0, len(traces))
+	for _, t := range traces {
+		spans := make([]map[string]interface{}, 0, len(t.spans))
+		for _, span := range t.spans {
+			spans = append(spans, map[string]interface{}{
+				"spanID":            strings.TrimPrefix(span.SpanID, "0x"),
+				"startTimeUnixNano": strconv.FormatInt(span.StartTimeUs*1000, 10),
+				"durationNanos":     strconv.FormatInt(span.DurationUs*1000, 10),
+			})
+		}
+		result = append(result, map[string]interface{}{
+			"traceID":           t.root.TraceID,
+			"rootServiceName":   t.root.ServiceName,
+			"rootTraceName":     t.root.Name,
+			"startTimeUnixNano": strconv.FormatInt(t.root.StartTimeUs*1000, 10),
+			"durationMs":        t.root.DurationUs / 1000,
+			"spanSet": map[string]interface{}{
+				"spans":   spans,
+				"matched": len(spans),
+			},
+		})
+	}
+	return result
+}
@@ -0,0 +1,187 @@
+/*
+ * Copyright (c) 2024 Yunshan Networks
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tempo
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseTraceQLToSQL(t *testing.T) {
+	cases := []struct {
+		query  string
+		output string
+	}{
+		{`{}`, `{}`},
+		{`{ resource.service.name = "frontend" }`, `{app_service='frontend'}`},
+		{`{ .service.name != "db" }`, `{app_service!='db'}`},
+		{`{ span.http.status_code >= 500 }`, `{response_code>=500}`},
+		{`{ span.http.method = "GET" && duration > 100ms }`, `{(request_type='GET' AND response_duration>100000)}`},
+		{`{ status = error || (kind = server && name =~ "/api.*") }`, `{(response_status IN (1,3,4) OR (tap_side='s-app' AND endpoint regexp '/api.*'))}`},
+		{`{ resource.service.instance.id = "i-1" && span.service.name = "x" }`, "{(app_instance='i-1' AND `attribute.service.name`='x')}"},
+		{`{ resource.k8s.namespace.name =~ "prod-.*" }`, `{((tap_side='c-app' AND pod_ns_0 regexp 'prod-.*') OR (tap_side!='c-app' AND pod_ns_1 regexp 'prod-.*'))}`},
+		{`{ .k8s.pod.name = "web-0" }`, `{((tap_side='c-app' AND pod_0='web-0') OR (tap_side!='c-app' AND pod_1='web-0'))}`},
+		{`{ status = error }`, `{response_status IN (1,3,4)}`},
+		{`{ span.db.statement !~ "select.*" }`, "{`attribute.db.statement` not regexp 'select.*'}"},
+		{`{ span.user = "o'neil" }`, "{`attribute.user`='o\\'neil'}"},
+		{`{ !(status = ok) }`, `{NOT (response_status IN (0))}`},
+		{`{ .a = "1" } && { .b = "2" } || { .c = "3" }`, "(({`attribute.a`='1'} && {`attribute.b`='2'}) || {`attribute.c`='3'})"},
+		{`{ .a = "1" } >> { .b = "2" } && { .c = "3" }`, "(({`attribute.a`='1'} >> {`attribute.b`='2'}) && {`attribute.c`='3'})"},
+		{`({ .a = "1" } || { .b = "2" }) > { .c = "3" }`, "(({`attribute.a`='1'} || {`attribute.b`='2'}) > {`attribute.c`='3'})"},
+	}
+	for _, c := range cases {
+		expr, err := ParseTraceQL(c.query)
+		if err != nil {
+			t.Errorf("ParseTraceQL(%s) error: %s", c.query, err)
+			continue
+		}
+		if out := expr.String(); out != c.output {
+			t.Errorf("ParseTraceQL(%s)\n got: %s\nwant: %s", c.query, out, c.output)
+		}
+	}
+}
+
+func TestParseTraceQLError(t *testing.T) {
+	queries := []string{
+		`{ .a = "1" `,
+		`{ foo = "1" }`,
+		`{ .a "1" }`,
+		`{ .a = "1" } &&`,
+		`{ .a = "1" } | count() > 1`,
+		`{ duration > 10xs }`,
+		`{ .a = "unterminated }`,
+	}
+	for _, q := range queries {
+		if _, err := ParseTraceQL(q); err == nil {
+			t.Errorf("ParseTraceQL(%s) should fail", q)
+		}
+	}
+	// valid syntax but can not be translated
+	invalid := []string{
+		`{ span.db.name > 1 }`,
+		`{ status = broken }`,
+		`{ resource.deployment.environment = "prod" }`,
+		`{ duration = "fast" }`,
+	}
+	for _, q := range invalid {
+		expr, err := ParseTraceQL(q)
+		if err != nil {
+			t.Errorf("ParseTraceQL(%s) error: %s", q, err)
+			continue
+		}
+		if _, err := expr.(*TraceQLSpansetFilter).Cond.ToSQL(); err == nil {
+			t.Errorf("ToSQL(%s) should fail", q)
+		}
+	}
+}
+
+// trace t1: a -> b -> c, trace t2: d -> e
+var traceQLTestSpans = []*TraceQLSpan{
+	{TraceID: "t1", SpanID: "a", ServiceName: "frontend", Name: "GET /", StartTimeUs: 100, DurationUs: 9000},
+	{TraceID: "t1", SpanID: "b", ParentSpanID: "a", ServiceName: "api", Name: "GET /api", StartTimeUs: 200},
+	{TraceID: "t1", SpanID: "c", ParentSpanID: "b", ServiceName: "db", Name: "SELECT", StartTimeUs: 300},
+	{TraceID: "t2", SpanID: "d", ServiceName: "frontend", Name: "GET /", StartTimeUs: 400},
+	{TraceID: "t2", SpanID: "e", ParentSpanID: "d", ServiceName: "api", Name: "GET /api", StartTimeUs: 500},
+}
+
+func newTestTraceQLEvaluator() *TraceQLEvaluator {
+	return &TraceQLEvaluator{
+		QuerySpans: func(cond string) ([]*TraceQLSpan, error) {
+			// only app_service='x' conditions are used in tests
+			service := strings.TrimSuffix(strings.TrimPrefix(cond, "app_service='"), "'")
+			spans := []*TraceQLSpan{}
+			for _, span := range traceQLTestSpans {
+				if cond == "" || span.ServiceName == service {
+					spans = append(spans, span)
+				}
+			}
+			return spans, nil
+		},
+		QueryTraceSpans: func(traceIDs []string) ([]*TraceQLSpan, error) {
+			spans := []*TraceQLSpan{}
+			for _, span := range traceQLTestSpans {
+				for _, traceID := range traceIDs {
+					if span.TraceID == traceID {
+						spans = append(spans, span)
+					}
+				}
+			}
+			return spans, nil
+		},
+	}
+}
+
+func TestTraceQLEvaluator(t *testing.T) {
+	cases := []struct {
+		query string
+		// trace id -> matched span ids
+		output map[string]string
+	}{
+		{`{ .service.name = "db" }`, map[string]string{"t1": "c"}},
+		{`{ .service.name = "frontend" } > { .service.name = "api" }`, map[string]string{"t1": "b", "t2": "e"}},
+		{`{ .service.name = "frontend" } > { .service.name = "db" }`, map[string]string{}},
+		{`{ .service.name = "frontend" } >> { .service.name = "db" }`, map[string]string{"t1": "c"}},
+		{`{ .service.name = "db" } && { .service.name = "api" }`, map[string]string{"t1": "bc"}},
+		{`{ .service.name = "db" } || { .service.name = "api" }`, map[string]string{"t1": "bc", "t2": "e"}},
+	}
+	for _, c := range cases {
+		expr, err := ParseTraceQL(c.query)
+		if err != nil {
+			t.Fatalf("ParseTraceQL(%s) error: %s", c.query, err)
+		}
+		spanSets, err := newTestTraceQLEvaluator().Eval(expr)
+		if err != nil {
+			t.Fatalf("Eval(%s) error: %s", c.query, err)
+		}
+		output := map[string]string{}
+		for traceID, spans := range spanSets {
+			ids := []string{}
+			for _, span := range spans {
+				ids = append(ids, span.SpanID)
+			}
+			sort.Strings(ids)
+			output[traceID] = strings.Join(ids, "")
+		}
+		if len(output) != len(c.output) {
+			t.Errorf("Eval(%s) got %v, want %v", c.query, output, c.output)
+			continue
+		}
+		for traceID, ids := range c.output {
+			if output[traceID] != ids {
+				t.Errorf("Eval(%s) got %v, want %v", c.query, output, c.output)
+				break
+			}
+		}
+	}
+}
+
+func TestTraceQLSearchResult(t *testing.T) {
+	expr, _ := ParseTraceQL(`{}`)
+	spanSets, _ := newTestTraceQLEvaluator().Eval(expr)
+	traces := TraceQLSearchResult(spanSets, 1)
+	if len(traces) != 1 {
+		t.Fatalf("TraceQLSearchResult got %d traces, want 1", len(traces))
+	}
+	if traces[0]["traceID"] != "t2" || traces[0]["startTimeUnixNano"] != "400000" {
+		t.Errorf("TraceQLSearchResult got %v, want the latest trace t2", traces[0])
+	}
+	traces = TraceQLSearchResult(spanSets, 0)
+	if traces[1]["rootServiceName"] != "frontend" || traces[1]["durationMs"] != int64(9) {
+		t.Errorf("TraceQLSearchResult got %v, want root span a of t1", traces[1])
+	}
+}